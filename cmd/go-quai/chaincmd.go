@@ -0,0 +1,260 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dominant-strategies/go-quai/cmd/utils"
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/consensus"
+	"github.com/dominant-strategies/go-quai/core"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/node"
+	"github.com/dominant-strategies/go-quai/quai/quaiconfig"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "exports the blocks of a slice into a file",
+	Long: `exports the canonical blocks of a prime, region or zone chain into a file.
+The node of the slice should be stopped while exporting. By default the whole
+chain is exported, a range can be chosen with the --first and --last flags.
+With --format=rlp every block is written as an RLP string holding its protobuf
+encoding, with --format=proto as a ProtoWorkObject message prefixed by its
+uvarint encoded length. If the file ends with .gz the output is gzipped.`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runExport,
+	SilenceUsage: true,
+	Example:      `go-quai export --location="[0,0]" --first=1 --last=1000 zone-0-0.rlp.gz`,
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "imports the blocks of a slice from a file",
+	Long: `imports blocks previously written by the export command into the database
+of a prime, region or zone chain. The node of the slice must be stopped while
+importing. The seal of every block is verified and the blocks are stored as
+candidates, which the node queues for appending once it is started. An
+interrupted import resumes after the last imported block when it is run again.
+The --format flag must match the one the file was exported with. If the file
+ends with .gz it is read as gzipped.`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runImport,
+	SilenceUsage: true,
+	Example:      `go-quai import --location="[0,0]" zone-0-0.rlp.gz`,
+}
+
+var (
+	chainLocation string
+	chainFormat   string
+	exportFirst   uint64
+	exportLast    int64
+)
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+
+	for _, cmd := range []*cobra.Command{exportCmd, importCmd} {
+		cmd.Flags().StringVar(&chainLocation, "location", "", `Location of the chain, i.e. "prime", "[0]" or "[0,0]", defaults to --`+utils.LocationFlag.Name)
+		cmd.Flags().StringVar(&chainFormat, "format", core.ExportFormatRLP, "Encoding of the blocks, either "+core.ExportFormatRLP+" or "+core.ExportFormatProto)
+	}
+	exportCmd.Flags().Uint64Var(&exportFirst, "first", 0, "Number of the first block to export")
+	exportCmd.Flags().Int64Var(&exportLast, "last", -1, "Number of the last block to export, defaults to the head block")
+}
+
+// chainCmdLocation returns the location selected with the --location flag,
+// falling back to the node location.
+func chainCmdLocation() (common.Location, error) {
+	if chainLocation != "" {
+		return utils.ParseNodeLocation(chainLocation)
+	}
+	return utils.GetNodeLocation()
+}
+
+func runExport(cmd *cobra.Command, args []string) (err error) {
+	location, err := chainCmdLocation()
+	if err != nil {
+		return err
+	}
+	logger := log.Global
+	stack, _ := utils.MakeOfflineNode(location, logger)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(stack, true)
+	defer chaindb.Close()
+
+	head := rawdb.ReadHeadBlock(chaindb)
+	if head == nil {
+		return errors.New("no head block found")
+	}
+	last := head.NumberU64(location.Context())
+	if exportLast >= 0 {
+		if uint64(exportLast) > last {
+			return fmt.Errorf("last block #%d is above the head block #%d", exportLast, last)
+		}
+		last = uint64(exportLast)
+	}
+	out, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	var writer io.Writer = out
+	if strings.HasSuffix(args[0], ".gz") {
+		writer = gzip.NewWriter(writer)
+	}
+	start := time.Now()
+	if err := core.ExportBlocks(chaindb, writer, exportFirst, last, chainFormat, logger); err != nil {
+		return err
+	}
+	// Closing the gzip writer flushes the end of the compressed stream
+	if gz, ok := writer.(*gzip.Writer); ok {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	logger.WithFields(log.Fields{
+		"file":    args[0],
+		"first":   exportFirst,
+		"last":    last,
+		"elapsed": common.PrettyDuration(time.Since(start)),
+	}).Info("Exported blocks")
+	return nil
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	location, err := chainCmdLocation()
+	if err != nil {
+		return err
+	}
+	nodeCtx := location.Context()
+	logger := log.Global
+	stack, cfg := utils.MakeOfflineNode(location, logger)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(stack, false)
+	defer chaindb.Close()
+
+	engine := makeOfflineEngine(stack, &cfg.Quai, location, chaindb, logger)
+
+	in, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	var reader io.Reader = in
+	if strings.HasSuffix(args[0], ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	stream, err := core.NewBlockDecoder(reader, chainFormat, location)
+	if err != nil {
+		return err
+	}
+
+	// The blocks up to the last imported one are skipped without being looked
+	// up, which resumes an interrupted import right after it
+	var resume uint64
+	if hash := rawdb.ReadLastImportedBlockHash(chaindb); hash != (common.Hash{}) {
+		if number := rawdb.ReadHeaderNumber(chaindb, hash); number != nil {
+			resume = *number
+			logger.WithFields(log.Fields{
+				"number": resume,
+				"hash":   hash,
+			}).Info("Resuming chain import")
+		}
+	}
+	var (
+		batch    = chaindb.NewBatch()
+		batched  = make(map[common.Hash]struct{})
+		start    = time.Now()
+		reported = time.Now()
+		imported uint64
+		skipped  uint64
+	)
+	for index := 0; ; index++ {
+		block, err := stream.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("block %d: failed to parse: %v", index, err)
+		}
+		hash, number := block.Hash(), block.NumberU64(nodeCtx)
+		if number <= resume || rawdb.ReadHeaderNumber(chaindb, hash) != nil {
+			skipped++
+			continue
+		}
+		if number == 0 {
+			return fmt.Errorf("block #0 [%x…]: genesis does not match the database", hash.Bytes()[:4])
+		}
+		parent := block.ParentHash(nodeCtx)
+		if _, ok := batched[parent]; !ok && rawdb.ReadHeaderNumber(chaindb, parent) == nil {
+			return fmt.Errorf("block #%d [%x…]: %v", number, hash.Bytes()[:4], consensus.ErrUnknownAncestor)
+		}
+		if _, err := engine.VerifySeal(block.WorkObjectHeader()); err != nil {
+			return fmt.Errorf("block #%d [%x…]: %v", number, hash.Bytes()[:4], err)
+		}
+		rawdb.WriteWorkObject(batch, hash, block, types.BlockObject, nodeCtx)
+		rawdb.WriteLastImportedBlockHash(batch, hash)
+		batched[hash] = struct{}{}
+		imported++
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+			batched = make(map[common.Hash]struct{})
+		}
+		if time.Since(reported) >= 8*time.Second {
+			logger.WithFields(log.Fields{
+				"number":   number,
+				"imported": imported,
+				"skipped":  skipped,
+				"elapsed":  common.PrettyDuration(time.Since(start)),
+			}).Info("Importing blocks")
+			reported = time.Now()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	logger.WithFields(log.Fields{
+		"file":     args[0],
+		"imported": imported,
+		"skipped":  skipped,
+		"elapsed":  common.PrettyDuration(time.Since(start)),
+	}).Info("Imported blocks")
+	return nil
+}
+
+// makeOfflineEngine creates the consensus engine configured for the slice,
+// which is used to verify the seals of imported blocks.
+func makeOfflineEngine(stack *node.Node, config *quaiconfig.Config, location common.Location, db ethdb.Database, logger *log.Logger) consensus.Engine {
+	if config.ConsensusEngine == "blake3" {
+		blake3Config := config.Blake3Pow
+		blake3Config.NodeLocation = location
+		return quaiconfig.CreateBlake3ConsensusEngine(stack, location, &blake3Config, nil, false, db, logger)
+	}
+	progpowConfig := config.Progpow
+	progpowConfig.NodeLocation = location
+	return quaiconfig.CreateProgpowConsensusEngine(stack, location, &progpowConfig, nil, false, db, logger)
+}
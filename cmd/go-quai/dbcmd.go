@@ -0,0 +1,518 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dominant-strategies/go-quai/cmd/utils"
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/rlp"
+	"github.com/dominant-strategies/go-quai/trie"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "low level database operations",
+	Long: `inspects and maintains the database of a prime, region or zone chain. The
+node of the slice should be stopped while the database is opened. Keys and
+prefixes are given in hex with a 0x prefix, or as plain strings otherwise.`,
+}
+
+var dbInspectCmd = &cobra.Command{
+	Use:   "inspect [prefix [start]]",
+	Short: "shows the storage size of every category of data",
+	Long: `traverses the key-value store and the ancient store of the slice and shows
+the storage size and the number of items of every category of data. The
+traversal can be limited to the keys with the given prefix, starting at the
+given key.`,
+	Args:         cobra.MaximumNArgs(2),
+	RunE:         runDBInspect,
+	SilenceUsage: true,
+	Example:      `go-quai db inspect --location="[0,0]"`,
+}
+
+var dbStatsCmd = &cobra.Command{
+	Use:          "stats",
+	Short:        "shows the internal statistics of the database engine",
+	Args:         cobra.NoArgs,
+	RunE:         runDBStats,
+	SilenceUsage: true,
+	Example:      `go-quai db stats --location="[0,0]"`,
+}
+
+var dbCompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "compacts the key-value store",
+	Long: `compacts the whole key-value store of the slice, discarding the deleted and
+overwritten entries. This can take a long time on a large database.`,
+	Args:         cobra.NoArgs,
+	RunE:         runDBCompact,
+	SilenceUsage: true,
+	Example:      `go-quai db compact --location="[0,0]"`,
+}
+
+var dbGetCmd = &cobra.Command{
+	Use:          "get <key>",
+	Short:        "shows the value stored at a key",
+	Args:         cobra.ExactArgs(1),
+	RunE:         runDBGet,
+	SilenceUsage: true,
+	Example:      `go-quai db get --location="[0,0]" LastWorkObject`,
+}
+
+var dbDeletePrefixCmd = &cobra.Command{
+	Use:   "delete-prefix <prefix>",
+	Short: "deletes all the keys with a prefix",
+	Long: `deletes all the keys of the key-value store starting with the given prefix
+and compacts the deleted range.`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runDBDeletePrefix,
+	SilenceUsage: true,
+	Example:      `go-quai db delete-prefix --location="[0,0]" 0x6175`,
+}
+
+var dbCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "checks the consistency of the database",
+	Long: `checks that every canonical block number maps to a stored block whose hash
+maps back to the number. In zones that index the UTXOs by address, it also
+checks that the index and the UTXO set of the block it reflects agree, naming
+the addresses owning UTXOs missing from the index.`,
+	Args:         cobra.NoArgs,
+	RunE:         runDBCheck,
+	SilenceUsage: true,
+	Example:      `go-quai db check --location="[0,0]"`,
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+
+	for _, cmd := range []*cobra.Command{dbInspectCmd, dbStatsCmd, dbCompactCmd, dbGetCmd, dbDeletePrefixCmd, dbCheckCmd} {
+		cmd.Flags().StringVar(&chainLocation, "location", "", `Location of the chain, i.e. "prime", "[0]" or "[0,0]", defaults to --`+utils.LocationFlag.Name)
+		dbCmd.AddCommand(cmd)
+	}
+}
+
+// openChainDB opens the database of the slice selected with the --location
+// flag, along with the name of its engine. A database opened for writing is
+// opened without its freezer, which would otherwise start moving blocks to the
+// ancient store. The returned function closes the database and the node.
+func openChainDB(readonly bool) (ethdb.Database, string, func(), error) {
+	location, err := chainCmdLocation()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	stack, _ := utils.MakeOfflineNode(location, log.Global)
+	var chaindb ethdb.Database
+	if readonly {
+		chaindb = utils.MakeChainDatabase(stack, readonly)
+	} else {
+		chaindb = utils.MakeChainKeyValueDatabase(stack, readonly)
+	}
+	engine := rawdb.PreexistingDatabase(stack.ResolvePath("chaindata"))
+	return chaindb, engine, func() {
+		chaindb.Close()
+		stack.Close()
+	}, nil
+}
+
+// parseDBKey decodes a key or prefix given in hex with a 0x prefix, or as a
+// plain string otherwise.
+func parseDBKey(arg string) ([]byte, error) {
+	if strings.HasPrefix(arg, "0x") || strings.HasPrefix(arg, "0X") {
+		return hexutil.Decode(arg)
+	}
+	return []byte(arg), nil
+}
+
+func runDBInspect(cmd *cobra.Command, args []string) error {
+	var prefix, start []byte
+	var err error
+	if len(args) > 0 {
+		if prefix, err = parseDBKey(args[0]); err != nil {
+			return err
+		}
+	}
+	if len(args) > 1 {
+		if start, err = parseDBKey(args[1]); err != nil {
+			return err
+		}
+	}
+	chaindb, _, closeDB, err := openChainDB(true)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	return rawdb.InspectDatabase(chaindb, prefix, start, log.Global)
+}
+
+// printDBStats prints the statistics of the database engine. Leveldb serves
+// named properties, pebble returns all of its metrics for any property.
+func printDBStats(db ethdb.Database, engine string) {
+	var properties []string
+	switch engine {
+	case "pebble":
+		properties = []string{""}
+	default:
+		properties = []string{"leveldb.stats", "leveldb.iostats"}
+	}
+	for _, property := range properties {
+		stats, err := db.Stat(property)
+		if err != nil {
+			log.Global.WithFields(log.Fields{
+				"property": property,
+				"err":      err,
+			}).Warn("Failed to read database stats")
+			continue
+		}
+		if stats != "" {
+			fmt.Println(stats)
+		}
+	}
+}
+
+func runDBStats(cmd *cobra.Command, args []string) error {
+	chaindb, engine, closeDB, err := openChainDB(true)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	printDBStats(chaindb, engine)
+	return nil
+}
+
+func runDBCompact(cmd *cobra.Command, args []string) error {
+	chaindb, engine, closeDB, err := openChainDB(false)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	logger := log.Global
+	logger.Info("Stats before compaction")
+	printDBStats(chaindb, engine)
+
+	start := time.Now()
+	logger.Info("Triggering compaction")
+	if err := chaindb.Compact(nil, nil); err != nil {
+		logger.WithField("err", err).Error("Compaction failed")
+		return err
+	}
+	logger.WithField("elapsed", common.PrettyDuration(time.Since(start))).Info("Compaction done")
+	printDBStats(chaindb, engine)
+	return nil
+}
+
+func runDBGet(cmd *cobra.Command, args []string) error {
+	key, err := parseDBKey(args[0])
+	if err != nil {
+		return err
+	}
+	chaindb, _, closeDB, err := openChainDB(true)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	data, err := chaindb.Get(key)
+	if err != nil {
+		return fmt.Errorf("key %#x: %v", key, err)
+	}
+	fmt.Printf("key %#x: %#x\n", key, data)
+	return nil
+}
+
+func runDBDeletePrefix(cmd *cobra.Command, args []string) error {
+	prefix, err := parseDBKey(args[0])
+	if err != nil {
+		return err
+	}
+	if len(prefix) == 0 {
+		return errors.New("refusing to delete the whole database")
+	}
+	chaindb, _, closeDB, err := openChainDB(false)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	var (
+		logger   = log.Global
+		batch    = chaindb.NewBatch()
+		it       = chaindb.NewIterator(prefix, nil)
+		start    = time.Now()
+		reported = time.Now()
+		deleted  uint64
+	)
+	defer it.Release()
+
+	for it.Next() {
+		if err := batch.Delete(it.Key()); err != nil {
+			return err
+		}
+		deleted++
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(reported) >= 8*time.Second {
+			logger.WithFields(log.Fields{
+				"deleted": deleted,
+				"elapsed": common.PrettyDuration(time.Since(start)),
+			}).Info("Deleting keys")
+			reported = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	// Compact the deleted range, which ends right after the last key with the
+	// prefix
+	end := common.CopyBytes(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			end = end[:i+1]
+			break
+		}
+		if i == 0 {
+			end = nil
+		}
+	}
+	if err := chaindb.Compact(prefix, end); err != nil {
+		return err
+	}
+	logger.WithFields(log.Fields{
+		"prefix":  hexutil.Encode(prefix),
+		"deleted": deleted,
+		"elapsed": common.PrettyDuration(time.Since(start)),
+	}).Info("Deleted keys")
+	return nil
+}
+
+func runDBCheck(cmd *cobra.Command, args []string) error {
+	location, err := chainCmdLocation()
+	if err != nil {
+		return err
+	}
+	logger := log.Global
+	stack, cfg := utils.MakeOfflineNode(location, logger)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(stack, true)
+	defer chaindb.Close()
+
+	issues, err := checkCanonicalChain(chaindb, location)
+	if err != nil {
+		return err
+	}
+	if location.Context() == common.ZONE_CTX {
+		if !cfg.Quai.IndexAddressUtxos {
+			logger.Info("Address UTXO indexing is disabled, skipping the address index check")
+		} else {
+			indexIssues, err := checkAddressUtxoIndex(chaindb, location)
+			if err != nil {
+				return err
+			}
+			issues += indexIssues
+		}
+	}
+	if issues > 0 {
+		return fmt.Errorf("found %d inconsistencies", issues)
+	}
+	logger.Info("Database is consistent")
+	return nil
+}
+
+// checkCanonicalChain checks that every number up to the head block has a
+// canonical hash, that the block of the hash is stored and that the hash maps
+// back to the number. It returns the number of inconsistencies found.
+func checkCanonicalChain(db ethdb.Database, location common.Location) (int, error) {
+	logger := log.Global
+	head := rawdb.ReadHeadBlock(db)
+	if head == nil {
+		return 0, errors.New("no head block found")
+	}
+	var (
+		last     = head.NumberU64(location.Context())
+		start    = time.Now()
+		reported = time.Now()
+		issues   int
+	)
+	for number := uint64(0); number <= last; number++ {
+		hash := rawdb.ReadCanonicalHash(db, number)
+		switch {
+		case hash == (common.Hash{}):
+			fmt.Printf("block #%d: missing canonical hash\n", number)
+			issues++
+		case len(rawdb.ReadWorkObjectHeaderProto(db, hash)) == 0:
+			fmt.Printf("block #%d [%x]: canonical hash without header\n", number, hash)
+			issues++
+		case len(rawdb.ReadWorkObjectBodyProto(db, hash)) == 0:
+			fmt.Printf("block #%d [%x]: canonical hash without body\n", number, hash)
+			issues++
+		default:
+			if n := rawdb.ReadHeaderNumber(db, hash); n == nil || *n != number {
+				fmt.Printf("block #%d [%x]: hash does not map back to the number\n", number, hash)
+				issues++
+			}
+		}
+		if time.Since(reported) >= 8*time.Second {
+			logger.WithFields(log.Fields{
+				"number":  number,
+				"last":    last,
+				"elapsed": common.PrettyDuration(time.Since(start)),
+			}).Info("Checking canonical chain")
+			reported = time.Now()
+		}
+	}
+	if rawdb.ReadCanonicalHash(db, last) != head.Hash() {
+		fmt.Printf("head block #%d [%x] is not canonical\n", last, head.Hash())
+		issues++
+	}
+	logger.WithFields(log.Fields{
+		"blocks":  last + 1,
+		"issues":  issues,
+		"elapsed": common.PrettyDuration(time.Since(start)),
+	}).Info("Checked canonical chain")
+	return issues, nil
+}
+
+// checkAddressUtxoIndex checks the address UTXO index against the UTXO set of
+// the block it reflects. Every indexed outpoint has to be unspent and owned by
+// the address it is indexed under, and every address has to have as many
+// outpoints indexed as it owns UTXOs. The outpoints of the UTXO trie can't be
+// recovered from its hashed keys unless the preimages were recorded, which they
+// aren't by default, so the UTXOs missing from the index are found by owner.
+// It returns the number of inconsistencies found.
+func checkAddressUtxoIndex(db ethdb.Database, location common.Location) (int, error) {
+	logger := log.Global
+	nodeCtx := location.Context()
+
+	if rawdb.ReadAddressUtxoIndexProgress(db) != nil {
+		logger.Info("Address UTXO index is being rebuilt, skipping the address index check")
+		return 0, nil
+	}
+	indexHead := rawdb.ReadAddressUtxoIndexHead(db)
+	if indexHead == (common.Hash{}) {
+		logger.Info("Address UTXO index is not built yet, skipping the address index check")
+		return 0, nil
+	}
+	block := rawdb.ReadHeader(db, indexHead)
+	if block == nil {
+		fmt.Printf("address UTXO index head %x: missing header\n", indexHead)
+		return 1, nil
+	}
+	utxoRoot := block.UTXORoot()
+	if block.NumberU64(nodeCtx) == 0 {
+		utxoRoot = types.EmptyRootHash
+	}
+	utxoTrie, err := trie.NewSecure(utxoRoot, trie.NewDatabase(db))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open the UTXO trie of block %x: %v", block.Hash(), err)
+	}
+	logger.WithFields(log.Fields{
+		"number": block.NumberU64(nodeCtx),
+		"hash":   block.Hash(),
+	}).Info("Checking address UTXO index")
+
+	var (
+		start   = time.Now()
+		issues  int
+		indexed = make(map[types.OutPoint]common.Address)
+		// The number of indexed outpoints and of UTXOs per address
+		indexedByOwner = make(map[common.AddressBytes]int)
+		utxosByOwner   = make(map[common.AddressBytes]int)
+		keyLen         = len(rawdb.AddressUtxosPrefix) + common.AddressLength + common.HashLength + 2
+	)
+	// Every indexed outpoint has to be in the UTXO set, owned by its address
+	it := db.NewIterator(rawdb.AddressUtxosPrefix, nil)
+	for it.Next() {
+		if len(it.Key()) != keyLen {
+			continue
+		}
+		// The key is the address followed by the outpoint
+		key := it.Key()[len(rawdb.AddressUtxosPrefix):]
+		address := common.BytesToAddress(key[:common.AddressLength], location)
+		outpoint := types.OutPoint{
+			TxHash: common.BytesToHash(key[common.AddressLength : common.AddressLength+common.HashLength]),
+			Index:  binary.BigEndian.Uint16(key[common.AddressLength+common.HashLength:]),
+		}
+		indexed[outpoint] = address
+		indexedByOwner[address.Bytes20()]++
+		enc, err := utxoTrie.TryGet(state.UTXOKey(outpoint.TxHash, outpoint.Index))
+		if err != nil {
+			it.Release()
+			return issues, err
+		}
+		if len(enc) == 0 {
+			fmt.Printf("address %s: indexed outpoint %x:%d is not a UTXO\n", address.Hex(), outpoint.TxHash, outpoint.Index)
+			issues++
+			continue
+		}
+		utxo := new(types.UtxoEntry)
+		if err := rlp.DecodeBytes(enc, utxo); err != nil {
+			fmt.Printf("UTXO %x:%d: undecodable entry: %v\n", outpoint.TxHash, outpoint.Index, err)
+			issues++
+			continue
+		}
+		if owner := common.BytesToAddress(utxo.Address, location); !owner.Equal(address) {
+			fmt.Printf("UTXO %x:%d: indexed under address %s instead of %s\n", outpoint.TxHash, outpoint.Index, address.Hex(), owner.Hex())
+			issues++
+		}
+	}
+	it.Release()
+
+	// Every UTXO has to be indexed under its owner
+	var utxos uint64
+	utxoIt := trie.NewIterator(utxoTrie.NodeIterator(nil))
+	for utxoIt.Next() {
+		utxos++
+		utxo := new(types.UtxoEntry)
+		if err := rlp.DecodeBytes(utxoIt.Value, utxo); err != nil {
+			fmt.Printf("UTXO trie key %x: undecodable entry: %v\n", utxoIt.Key, err)
+			issues++
+			continue
+		}
+		utxosByOwner[common.BytesToAddress(utxo.Address, location).Bytes20()]++
+	}
+	if utxoIt.Err != nil {
+		return issues, utxoIt.Err
+	}
+	for owner, count := range utxosByOwner {
+		if count > indexedByOwner[owner] {
+			address := common.Bytes20ToAddress(owner, location)
+			fmt.Printf("address %s: owns %d UTXOs, %d indexed\n", address.Hex(), count, indexedByOwner[owner])
+			issues++
+		}
+	}
+	if utxos != uint64(len(indexed)) {
+		fmt.Printf("address UTXO index holds %d outpoints, the UTXO set %d\n", len(indexed), utxos)
+		issues++
+	}
+	logger.WithFields(log.Fields{
+		"indexed": len(indexed),
+		"utxos":   utxos,
+		"issues":  issues,
+		"elapsed": common.PrettyDuration(time.Since(start)),
+	}).Info("Checked address UTXO index")
+	return issues, nil
+}
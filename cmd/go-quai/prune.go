@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dominant-strategies/go-quai/cmd/utils"
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/state/pruner"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/log"
+)
+
+var pruneStateCmd = &cobra.Command{
+	Use:   "prune-state",
+	Short: "prunes the stale state of a slice",
+	Long: `prunes all the state of a slice that does not belong to the target block.
+The node of the slice must be stopped while pruning. By default the state of
+the block 127 blocks below the head is kept, a different target can be chosen
+with the --block or --root flags. An interrupted pruning is resumed by running
+the command again, or automatically the next time the node starts.`,
+	RunE:         runPruneState,
+	SilenceUsage: true,
+	Example:      `go-quai prune-state --node.location="[0,0]"`,
+}
+
+var (
+	pruneTargetRoot  string
+	pruneTargetBlock int64
+	pruneBloomSize   uint64
+)
+
+func init() {
+	rootCmd.AddCommand(pruneStateCmd)
+
+	pruneStateCmd.Flags().StringVar(&pruneTargetRoot, "root", "", "State root to keep, defaults to the state of HEAD-127")
+	pruneStateCmd.Flags().Int64Var(&pruneTargetBlock, "block", -1, "Number of the canonical block whose state to keep, defaults to HEAD-127")
+	pruneStateCmd.Flags().Uint64Var(&pruneBloomSize, "bloomfilter-size", 2048, "Size of the bloom filter used while pruning, in megabytes")
+}
+
+func runPruneState(cmd *cobra.Command, args []string) error {
+	location, err := utils.GetNodeLocation()
+	if err != nil {
+		return err
+	}
+	if location.Context() != common.ZONE_CTX {
+		return errors.New("only zone chains hold state that can be pruned")
+	}
+	if pruneTargetRoot != "" && pruneTargetBlock >= 0 {
+		return errors.New("--root and --block are mutually exclusive")
+	}
+	logger := log.Global
+	stack, cfg := utils.MakeOfflineNode(location, logger)
+	defer stack.Close()
+
+	chaindb := utils.MakeChainDatabase(stack, false)
+	defer chaindb.Close()
+
+	var root common.Hash
+	switch {
+	case pruneTargetRoot != "":
+		root = common.HexToHash(pruneTargetRoot)
+	case pruneTargetBlock >= 0:
+		hash := rawdb.ReadCanonicalHash(chaindb, uint64(pruneTargetBlock))
+		if hash == (common.Hash{}) {
+			return fmt.Errorf("block #%d not found", pruneTargetBlock)
+		}
+		block := rawdb.ReadWorkObjectHeaderOnly(chaindb, hash, types.BlockObject)
+		if block == nil {
+			return fmt.Errorf("block #%d not found", pruneTargetBlock)
+		}
+		root = block.EVMRoot()
+	}
+	p, err := pruner.NewPruner(chaindb, stack.ResolvePath(""), stack.ResolvePath(cfg.Quai.TrieCleanCacheJournal), pruneBloomSize, logger, location)
+	if err != nil {
+		logger.WithField("err", err).Error("Failed to open snapshot tree")
+		return err
+	}
+	logger.WithFields(log.Fields{
+		"location": location.Name(),
+		"root":     root,
+	}).Info("Pruning state")
+	if err = p.Prune(root, location); err != nil {
+		logger.WithField("err", err).Error("Failed to prune state")
+		return err
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"path"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -21,6 +22,7 @@ import (
 	"github.com/dominant-strategies/go-quai/quai"
 	"github.com/dominant-strategies/go-quai/quai/quaiconfig"
 	"github.com/dominant-strategies/go-quai/quaistats"
+	"github.com/dominant-strategies/go-quai/rpc"
 	"github.com/syndtr/goleveldb/leveldb"
 )
 
@@ -61,6 +63,43 @@ func GetRunningZones() []common.Location {
 	return runningSlices
 }
 
+// GetNodeLocation returns the slice location selected with the location flag.
+// The location is given in the same format as the running slices, i.e. "[0,1]"
+// for a zone, "[0]" for a region and "prime" or "[]" for prime.
+func GetNodeLocation() (common.Location, error) {
+	value := strings.TrimSpace(viper.GetString(LocationFlag.Name))
+	if value == "" {
+		return nil, fmt.Errorf("no location specified, use --%s", LocationFlag.Name)
+	}
+	return ParseNodeLocation(value)
+}
+
+// ParseNodeLocation parses a slice location given in the format accepted by
+// the location flag.
+func ParseNodeLocation(value string) (common.Location, error) {
+	value = strings.TrimSpace(value)
+	if value == "prime" {
+		return common.Location{}, nil
+	}
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("invalid location: %s", value)
+	}
+	location := common.Location{}
+	if inner := strings.TrimSpace(value[1 : len(value)-1]); inner != "" {
+		for _, part := range strings.Split(inner, ",") {
+			index, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid location: %s", value)
+			}
+			location = append(location, byte(index))
+		}
+	}
+	if len(location) > common.HierarchyDepth-1 || location.Region() > common.MaxRegions || location.Zone() > common.MaxZones {
+		return nil, fmt.Errorf("invalid location: %s", value)
+	}
+	return location, nil
+}
+
 // getRegionsRunning returns the regions running
 func GetRunningRegions(runningSlices []common.Location) []byte {
 	runningRegions := []byte{}
@@ -117,6 +156,14 @@ func defaultNodeConfig() node.Config {
 	return cfg
 }
 
+// MakeOfflineNode loads the quai configuration of the given slice location
+// and creates a node instance that is never started. It is meant for the
+// offline maintenance commands, which open the databases of the slice while
+// no node is running on them.
+func MakeOfflineNode(nodeLocation common.Location, logger *log.Logger) (*node.Node, quaiconfig.QuaiConfig) {
+	return makeConfigNode([]common.Location{nodeLocation}, nodeLocation, 0, logger)
+}
+
 // makeFullNode loads quai configuration and creates the Quai backend.
 func makeFullNode(p2p quai.NetworkingAPI, nodeLocation common.Location, slicesRunning []common.Location, currentExpansionNumber uint8, genesisBlock *types.WorkObject, logger *log.Logger) (*node.Node, quaiapi.Backend) {
 	stack, cfg := makeConfigNode(slicesRunning, nodeLocation, currentExpansionNumber, logger)
@@ -149,6 +196,21 @@ func RegisterQuaiStatsService(stack *node.Node, backend quaiapi.Backend, url str
 	}
 }
 
+// MakeRPCAuth returns the authentication provider of the clients connecting
+// to the RPC endpoints of the running slices, or nil if the endpoints do not
+// require authentication.
+func MakeRPCAuth() rpc.HTTPAuth {
+	path := viper.GetString(JWTSecretFlag.Name)
+	if path == "" {
+		return nil
+	}
+	secret, err := node.ReadJWTSecret(path)
+	if err != nil {
+		Fatalf("Failed to load the JWT secret: %v", err)
+	}
+	return node.NewJWTAuth(secret)
+}
+
 // Fatalf formats a message to standard error and exits the program.
 // The message is also printed to standard output if standard error
 // is redirected to a different file.
@@ -36,6 +36,7 @@ import (
 	"github.com/dominant-strategies/go-quai/params"
 	"github.com/dominant-strategies/go-quai/quai/gasprice"
 	"github.com/dominant-strategies/go-quai/quai/quaiconfig"
+	"github.com/dominant-strategies/go-quai/rpc"
 )
 
 const (
@@ -75,6 +76,7 @@ var NodeFlags = []Flag{
 	LocationFlag,
 	SoloFlag,
 	DBEngineFlag,
+	FreezerThresholdFlag,
 	NetworkIdFlag,
 	SlicesRunningFlag,
 	GenesisNonceFlag,
@@ -96,6 +98,8 @@ var NodeFlags = []Flag{
 	CachePreimagesFlag,
 	ConsensusEngineFlag,
 	MinerGasPriceFlag,
+	StratumEnabledFlag,
+	StratumListenAddrFlag,
 	UnlockedAccountFlag,
 	PasswordFileFlag,
 	VMEnableDebugFlag,
@@ -112,6 +116,9 @@ var NodeFlags = []Flag{
 	QuaiStatsURLFlag,
 	SendFullStatsFlag,
 	IndexAddressUtxos,
+	IndexAddressHistory,
+	IndexEtxs,
+	StateSyncFlag,
 	StartingExpansionNumberFlag,
 	NodeLogLevelFlag,
 }
@@ -142,9 +149,20 @@ var RPCFlags = []Flag{
 	WSApiFlag,
 	WSAllowedOriginsFlag,
 	WSPathPrefixFlag,
+	JWTSecretFlag,
+	JWTNamespacesFlag,
+	IPCDisabledFlag,
+	IPCPathFlag,
 	PreloadJSFlag,
 	RPCGlobalTxFeeCapFlag,
 	RPCGlobalGasCapFlag,
+	RPCBatchItemsFlag,
+	RPCResponseSizeFlag,
+	RPCRateLimitFlag,
+	RPCRateBurstFlag,
+	RPCRateByTokenFlag,
+	RPCMethodCostsFlag,
+	RPCSlowCallThresholdFlag,
 }
 
 var PeersFlags = []Flag{
@@ -269,6 +287,12 @@ var (
 		Usage: "Backing database implementation to use ('leveldb' or 'pebble')" + generateEnvDoc(c_NodeFlagPrefix+"db-engine"),
 	}
 
+	FreezerThresholdFlag = Flag{
+		Name:  c_NodeFlagPrefix + "freezer-threshold",
+		Value: quaiconfig.Defaults.DatabaseFreezerThreshold,
+		Usage: "Number of blocks below the most recent prime block kept in the database before moving them to the ancient store" + generateEnvDoc(c_NodeFlagPrefix+"freezer-threshold"),
+	}
+
 	NetworkIdFlag = Flag{
 		Name:  c_NodeFlagPrefix + "networkid",
 		Value: 1,
@@ -461,6 +485,18 @@ var (
 		Usage: "Minimum gas price for mining a transaction" + generateEnvDoc(c_NodeFlagPrefix+"miner-gasprice"),
 	}
 
+	StratumEnabledFlag = Flag{
+		Name:  c_NodeFlagPrefix + "stratum",
+		Value: false,
+		Usage: "Enable the stratum server for external miners in the zone" + generateEnvDoc(c_NodeFlagPrefix+"stratum"),
+	}
+
+	StratumListenAddrFlag = Flag{
+		Name:  c_NodeFlagPrefix + "stratum-addr",
+		Value: "127.0.0.1",
+		Usage: "Stratum server listening interface" + generateEnvDoc(c_NodeFlagPrefix+"stratum-addr"),
+	}
+
 	UnlockedAccountFlag = Flag{
 		Name:  c_NodeFlagPrefix + "unlock",
 		Value: "",
@@ -542,7 +578,25 @@ var (
 	IndexAddressUtxos = Flag{
 		Name:  c_NodeFlagPrefix + "index-address-utxos",
 		Value: false,
-		Usage: "Index address utxos" + generateEnvDoc(c_NodeFlagPrefix+"index-address-utxos"),
+		Usage: "Index address utxos, an existing database is indexed in the background" + generateEnvDoc(c_NodeFlagPrefix+"index-address-utxos"),
+	}
+
+	IndexAddressHistory = Flag{
+		Name:  c_NodeFlagPrefix + "index-address-history",
+		Value: false,
+		Usage: "Index the transactions of every address, served by quai_getAddressHistory" + generateEnvDoc(c_NodeFlagPrefix+"index-address-history"),
+	}
+
+	IndexEtxs = Flag{
+		Name:  c_NodeFlagPrefix + "index-etxs",
+		Value: false,
+		Usage: "Index the blocks rolling up, including and queueing every etx, served by quai_getEtxStatus" + generateEnvDoc(c_NodeFlagPrefix+"index-etxs"),
+	}
+
+	StateSyncFlag = Flag{
+		Name:  c_NodeFlagPrefix + "state-sync",
+		Value: false,
+		Usage: "Download the state of a recent block from the peers instead of processing the chain from genesis" + generateEnvDoc(c_NodeFlagPrefix+"state-sync"),
 	}
 
 	EnvironmentFlag = Flag{
@@ -648,6 +702,30 @@ var (
 		Usage: "HTTP path prefix on which JSON-RPC is served. Use '/' to serve on all paths." + generateEnvDoc(c_RPCFlagPrefix+"ws-rpcprefix"),
 	}
 
+	JWTSecretFlag = Flag{
+		Name:  c_RPCFlagPrefix + "jwt-secret",
+		Value: "",
+		Usage: "Path to a hex encoded 32 byte secret; if set, HTTP-RPC and WS-RPC requests must carry a HS256 JWT signed with it" + generateEnvDoc(c_RPCFlagPrefix+"jwt-secret"),
+	}
+
+	JWTNamespacesFlag = Flag{
+		Name:  c_RPCFlagPrefix + "jwt-namespaces",
+		Value: "",
+		Usage: "Comma separated list of API namespaces requiring a JWT, i.e. admin,miner,debug (default = all)" + generateEnvDoc(c_RPCFlagPrefix+"jwt-namespaces"),
+	}
+
+	IPCDisabledFlag = Flag{
+		Name:  c_RPCFlagPrefix + "ipcdisable",
+		Value: false,
+		Usage: "Disable the IPC-RPC server" + generateEnvDoc(c_RPCFlagPrefix+"ipcdisable"),
+	}
+
+	IPCPathFlag = Flag{
+		Name:  c_RPCFlagPrefix + "ipcpath",
+		Value: node.DefaultIPCPath,
+		Usage: "Filename for the IPC socket within the data directory of each slice (the slice name is prefixed to the file name of explicit paths)" + generateEnvDoc(c_RPCFlagPrefix+"ipcpath"),
+	}
+
 	PreloadJSFlag = Flag{
 		Name:  c_RPCFlagPrefix + "preload",
 		Value: "",
@@ -665,6 +743,48 @@ var (
 		Value: quaiconfig.Defaults.RPCGasCap,
 		Usage: "Sets a cap on gas that can be used in eth_call/estimateGas (0=infinite)" + generateEnvDoc(c_RPCFlagPrefix+"gascap"),
 	}
+
+	RPCBatchItemsFlag = Flag{
+		Name:  c_RPCFlagPrefix + "batch-items",
+		Value: node.DefaultConfig.RPCLimits.BatchItems,
+		Usage: "Maximum number of requests in a HTTP-RPC or WS-RPC batch (0 = no limit)" + generateEnvDoc(c_RPCFlagPrefix+"batch-items"),
+	}
+
+	RPCResponseSizeFlag = Flag{
+		Name:  c_RPCFlagPrefix + "response-size",
+		Value: node.DefaultConfig.RPCLimits.ResponseSize,
+		Usage: "Maximum size in bytes of the response to a HTTP-RPC or WS-RPC call or batch (0 = no limit)" + generateEnvDoc(c_RPCFlagPrefix+"response-size"),
+	}
+
+	RPCRateLimitFlag = Flag{
+		Name:  c_RPCFlagPrefix + "ratelimit",
+		Value: 0,
+		Usage: "Cost of the requests each client can make per second, calls cost 1 unless listed in rpc.method-costs (0 = no limit)" + generateEnvDoc(c_RPCFlagPrefix+"ratelimit"),
+	}
+
+	RPCRateBurstFlag = Flag{
+		Name:  c_RPCFlagPrefix + "ratelimit-burst",
+		Value: 0,
+		Usage: "Cost of the requests each client can make at once (0 = one second of rpc.ratelimit)" + generateEnvDoc(c_RPCFlagPrefix+"ratelimit-burst"),
+	}
+
+	RPCRateByTokenFlag = Flag{
+		Name:  c_RPCFlagPrefix + "ratelimit-by-token",
+		Value: false,
+		Usage: "Limit the request rate per subject of the verified JWT instead of per IP for the requests carrying one" + generateEnvDoc(c_RPCFlagPrefix+"ratelimit-by-token"),
+	}
+
+	RPCMethodCostsFlag = Flag{
+		Name:  c_RPCFlagPrefix + "method-costs",
+		Value: "",
+		Usage: "Comma separated list of method=cost pairs overriding the default costs of the rate limit, i.e. quai_getLogs=50" + generateEnvDoc(c_RPCFlagPrefix+"method-costs"),
+	}
+
+	RPCSlowCallThresholdFlag = Flag{
+		Name:  c_RPCFlagPrefix + "slow-call-threshold",
+		Value: node.DefaultConfig.RPCSlowCallThreshold,
+		Usage: "Duration above which HTTP-RPC and WS-RPC calls are logged (0 = disabled)" + generateEnvDoc(c_RPCFlagPrefix+"slow-call-threshold"),
+	}
 )
 
 var (
@@ -791,6 +911,40 @@ func generateEnvDoc(flag string) string {
 	return fmt.Sprintf(" [%s]", envVar)
 }
 
+// setRPCLimits applies the limits of the HTTP-RPC and WS-RPC requests.
+func setRPCLimits(cfg *node.Config) {
+	if viper.IsSet(RPCBatchItemsFlag.Name) {
+		cfg.RPCLimits.BatchItems = viper.GetInt(RPCBatchItemsFlag.Name)
+	}
+	if viper.IsSet(RPCResponseSizeFlag.Name) {
+		cfg.RPCLimits.ResponseSize = viper.GetInt(RPCResponseSizeFlag.Name)
+	}
+	if viper.IsSet(RPCRateLimitFlag.Name) {
+		cfg.RPCLimits.RequestRate = float64(viper.GetInt(RPCRateLimitFlag.Name))
+	}
+	if viper.IsSet(RPCRateBurstFlag.Name) {
+		cfg.RPCLimits.RequestBurst = viper.GetInt(RPCRateBurstFlag.Name)
+	}
+	if viper.IsSet(RPCRateByTokenFlag.Name) {
+		cfg.RPCLimits.RateByToken = viper.GetBool(RPCRateByTokenFlag.Name)
+	}
+	if viper.IsSet(RPCMethodCostsFlag.Name) {
+		costs := make(map[string]int, len(rpc.DefaultMethodCosts))
+		for method, cost := range rpc.DefaultMethodCosts {
+			costs[method] = cost
+		}
+		for _, pair := range SplitAndTrim(viper.GetString(RPCMethodCostsFlag.Name)) {
+			method, value, found := strings.Cut(pair, "=")
+			cost, err := strconv.Atoi(strings.TrimSpace(value))
+			if !found || err != nil || cost < 0 {
+				Fatalf("Invalid method cost %q, expected method=cost", pair)
+			}
+			costs[strings.TrimSpace(method)] = cost
+		}
+		cfg.RPCLimits.MethodCosts = costs
+	}
+}
+
 // setNodeUserIdent creates the user identifier from CLI flags.
 func setNodeUserIdent(cfg *node.Config) {
 	if identity := viper.GetString(IdentityFlag.Name); len(identity) > 0 {
@@ -810,6 +964,17 @@ func SplitAndTrim(input string) (ret []string) {
 	return ret
 }
 
+// setIPC creates an IPC path configuration from the set command line flags,
+// returning an empty string if IPC was explicitly disabled, or the set path.
+func setIPC(cfg *node.Config) {
+	switch {
+	case viper.GetBool(IPCDisabledFlag.Name):
+		cfg.IPCPath = ""
+	case viper.IsSet(IPCPathFlag.Name):
+		cfg.IPCPath = viper.GetString(IPCPathFlag.Name)
+	}
+}
+
 // setHTTP creates the HTTP RPC listener interface string from the set
 // command line flags, returning empty if the HTTP endpoint is disabled.
 func setHTTP(cfg *node.Config, nodeLocation common.Location) {
@@ -876,6 +1041,20 @@ func setWS(cfg *node.Config, nodeLocation common.Location) {
 	}
 }
 
+// GetStratumPort returns the port of the stratum server of a slice, only the
+// zones run one
+func GetStratumPort(nodeLocation common.Location) int {
+	switch nodeLocation.Context() {
+	case common.PRIME_CTX:
+		return 3001
+	case common.REGION_CTX:
+		return 3002 + nodeLocation.Region()
+	case common.ZONE_CTX:
+		return 3200 + 20*nodeLocation.Region() + nodeLocation.Zone()
+	}
+	panic("node location is not valid")
+}
+
 func GetWSPort(nodeLocation common.Location) int {
 	switch nodeLocation.Context() {
 	case common.PRIME_CTX:
@@ -1011,11 +1190,23 @@ func MakePasswordList() []string {
 
 // SetNodeConfig applies node-related command line flags to the config.
 func SetNodeConfig(cfg *node.Config, nodeLocation common.Location, logger *log.Logger) {
+	setIPC(cfg)
 	setHTTP(cfg, nodeLocation)
 	setWS(cfg, nodeLocation)
 	setNodeUserIdent(cfg)
 	setDataDir(cfg)
 
+	if viper.IsSet(JWTSecretFlag.Name) {
+		cfg.JWTSecret = viper.GetString(JWTSecretFlag.Name)
+	}
+	if viper.IsSet(JWTNamespacesFlag.Name) {
+		cfg.JWTNamespaces = SplitAndTrim(viper.GetString(JWTNamespacesFlag.Name))
+	}
+	setRPCLimits(cfg)
+	if viper.IsSet(RPCSlowCallThresholdFlag.Name) {
+		cfg.RPCSlowCallThreshold = viper.GetDuration(RPCSlowCallThresholdFlag.Name)
+	}
+
 	if viper.IsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = viper.GetString(KeyStoreDirFlag.Name)
 	}
@@ -1344,6 +1535,9 @@ func SetQuaiConfig(stack *node.Node, cfg *quaiconfig.Config, slicesRunning []com
 	if viper.IsSet(AncientDirFlag.Name) {
 		cfg.DatabaseFreezer = viper.GetString(AncientDirFlag.Name)
 	}
+	if viper.IsSet(FreezerThresholdFlag.Name) {
+		cfg.DatabaseFreezerThreshold = viper.GetUint64(FreezerThresholdFlag.Name)
+	}
 
 	if viper.IsSet(CacheNoPrefetchFlag.Name) {
 		cfg.NoPrefetch = viper.GetBool(CacheNoPrefetchFlag.Name)
@@ -1384,6 +1578,12 @@ func SetQuaiConfig(stack *node.Node, cfg *quaiconfig.Config, slicesRunning []com
 		cfg.EnablePreimageRecording = viper.GetBool(VMEnableDebugFlag.Name)
 	}
 	cfg.IndexAddressUtxos = viper.GetBool(IndexAddressUtxos.Name)
+	cfg.IndexAddressHistory = viper.GetBool(IndexAddressHistory.Name)
+	cfg.IndexEtxs = viper.GetBool(IndexEtxs.Name)
+	if viper.GetBool(StratumEnabledFlag.Name) && nodeLocation.Context() == common.ZONE_CTX {
+		cfg.Miner.StratumAddr = fmt.Sprintf("%s:%d", viper.GetString(StratumListenAddrFlag.Name), GetStratumPort(nodeLocation))
+	}
+	cfg.StateSync = viper.GetBool(StateSyncFlag.Name)
 
 	if viper.IsSet(RPCGlobalGasCapFlag.Name) {
 		cfg.RPCGasCap = viper.GetUint64(RPCGlobalGasCapFlag.Name)
@@ -1501,7 +1701,22 @@ func MakeChainDatabase(stack *node.Node, readonly bool) ethdb.Database {
 		chainDb ethdb.Database
 	)
 	name := "chaindata"
-	chainDb, err = stack.OpenDatabaseWithFreezer(name, cache, handles, viper.GetString(AncientDirFlag.Name), "", readonly, stack.Config().NodeLocation)
+	chainDb, err = stack.OpenDatabaseWithFreezer(name, cache, handles, viper.GetString(AncientDirFlag.Name), viper.GetUint64(FreezerThresholdFlag.Name), "", readonly, stack.Config().NodeLocation)
+	if err != nil {
+		Fatalf("Could not open database: %v", err)
+	}
+	return chainDb
+}
+
+// MakeChainKeyValueDatabase opens the key-value store of the chain database
+// without its freezer, so that no block is moved to the ancient store while the
+// database is maintained offline.
+func MakeChainKeyValueDatabase(stack *node.Node, readonly bool) ethdb.Database {
+	var (
+		cache   = viper.GetInt(CacheFlag.Name) * viper.GetInt(CacheDatabaseFlag.Name) / 100
+		handles = MakeDatabaseHandles()
+	)
+	chainDb, err := stack.OpenDatabase("chaindata", cache, handles, "", readonly)
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
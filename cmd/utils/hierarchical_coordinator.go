@@ -243,7 +243,7 @@ func (hc *HierarchicalCoordinator) TriggerTreeExpansion(block *types.WorkObject)
 			// Add the new zone to the new slices list
 			// Add the subclient to the already existing regions
 			suburl := fmt.Sprintf("ws://127.0.0.1:%d", 8100+20*i+(int(newZones)-1))
-			subClient, err := quaiclient.Dial(suburl, nil)
+			subClient, err := quaiclient.DialWithAuth(suburl, MakeRPCAuth(), nil)
 			if err != nil {
 				log.Global.WithFields(log.Fields{
 					"index": i,
@@ -269,7 +269,7 @@ func (hc *HierarchicalCoordinator) TriggerTreeExpansion(block *types.WorkObject)
 		// Update the SubClient for the Prime
 		// Add the subclient to the already existing regions
 		suburl := fmt.Sprintf("ws://127.0.0.1:%d", 8002+(newRegions-1))
-		subClient, err := quaiclient.Dial(suburl, nil)
+		subClient, err := quaiclient.DialWithAuth(suburl, MakeRPCAuth(), nil)
 		if err != nil {
 			log.Global.WithFields(log.Fields{
 				"index": newRegions - 1,
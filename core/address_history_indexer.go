@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/crypto"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+const (
+	// addressHistoryThrottling is the time to wait between processing two
+	// consecutive address history sections.
+	addressHistoryThrottling = 10 * time.Millisecond
+)
+
+// AddressHistoryIndexer implements a core.ChainIndexer, recording for every
+// address the canonical transactions, ETXs and coinbase outputs that touched it.
+//
+// Sections are only indexed once they are confirmed. A reorg below an indexed
+// section makes the chain indexer index it again, and the entries of the blocks
+// that were reorged out are told apart by their block hash when read.
+type AddressHistoryIndexer struct {
+	db       ethdb.Database // database instance to write index data into
+	config   *params.ChainConfig
+	nodeCtx  int
+	location common.Location
+	batch    ethdb.Batch // batch of the section being processed
+	logger   *log.Logger
+}
+
+// NewAddressHistoryIndexer returns a chain indexer that records the history of
+// the addresses of the canonical chain.
+func NewAddressHistoryIndexer(db ethdb.Database, config *params.ChainConfig, size, confirms uint64, logger *log.Logger) *ChainIndexer {
+	backend := &AddressHistoryIndexer{
+		db:       db,
+		config:   config,
+		nodeCtx:  config.Location.Context(),
+		location: config.Location,
+		logger:   logger,
+	}
+	table := rawdb.NewTable(db, string(rawdb.AddressHistoryIndexPrefix), db.Location(), db.Logger())
+
+	return NewChainIndexer(db, table, backend, size, confirms, addressHistoryThrottling, "addresshistory", backend.nodeCtx, logger)
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new address history
+// section.
+func (a *AddressHistoryIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	a.batch = a.db.NewBatch()
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the transactions of a
+// block to the history of the addresses they touched.
+func (a *AddressHistoryIndexer) Process(ctx context.Context, header *types.WorkObject, bloom types.Bloom) error {
+	block := rawdb.ReadWorkObject(a.db, header.Hash(), types.BlockObject)
+	if block == nil {
+		return fmt.Errorf("block #%d [%x] body not found", header.NumberU64(a.nodeCtx), header.Hash())
+	}
+	number := block.NumberU64(a.nodeCtx)
+	signer := types.MakeSigner(a.config, block.Number(a.nodeCtx))
+	for i, tx := range block.Transactions() {
+		touched, err := a.touchedAddresses(tx, signer, block.ParentHash(a.nodeCtx))
+		if err != nil {
+			return fmt.Errorf("tx %d [%x] of block #%d: %w", i, tx.Hash(), number, err)
+		}
+		for address, flags := range touched {
+			rawdb.WriteAddressHistoryEntry(a.batch, common.Bytes20ToAddress(address, a.location), &rawdb.AddressHistoryEntry{
+				BlockNumber: number,
+				BlockHash:   block.Hash(),
+				TxIndex:     uint32(i),
+				TxHash:      tx.Hash(),
+				Flags:       flags,
+			})
+		}
+	}
+	if a.batch.ValueSize() >= ethdb.IdealBatchSize {
+		if err := a.batch.Write(); err != nil {
+			return err
+		}
+		a.batch.Reset()
+	}
+	return nil
+}
+
+// touchedAddresses returns the addresses a transaction touched together with
+// the address history flags telling how
+func (a *AddressHistoryIndexer) touchedAddresses(tx *types.Transaction, signer types.Signer, parentHash common.Hash) (map[common.AddressBytes]uint8, error) {
+	touched := make(map[common.AddressBytes]uint8)
+	coinbase := types.IsCoinBaseTx(tx, parentHash, a.location)
+	received := rawdb.AddressHistoryReceived
+	if coinbase {
+		received |= rawdb.AddressHistoryCoinbase
+	}
+	switch tx.Type() {
+	case types.QuaiTxType:
+		if !coinbase {
+			from, err := types.Sender(signer, tx)
+			if err != nil {
+				return nil, err
+			}
+			touched[from.Bytes20()] |= rawdb.AddressHistorySent
+		}
+		if tx.To() != nil {
+			touched[tx.To().Bytes20()] |= received
+		}
+	case types.QiTxType:
+		if !coinbase {
+			for _, txIn := range tx.TxIn() {
+				touched[crypto.PubkeyBytesToAddress(txIn.PubKey, a.location).Bytes20()] |= rawdb.AddressHistorySent
+			}
+		}
+		for _, txOut := range tx.TxOut() {
+			touched[common.BytesToAddress(txOut.Address, a.location).Bytes20()] |= received
+		}
+	case types.ExternalTxType:
+		if tx.To() != nil {
+			touched[tx.To().Bytes20()] |= rawdb.AddressHistoryReceived | rawdb.AddressHistoryEtx
+		}
+	}
+	return touched, nil
+}
+
+// Commit implements core.ChainIndexerBackend, writing the address history of
+// the section into the database.
+func (a *AddressHistoryIndexer) Commit() error {
+	return a.batch.Write()
+}
+
+// Prune returns an empty error since we don't support pruning here.
+func (a *AddressHistoryIndexer) Prune(threshold uint64) error {
+	return nil
+}
@@ -0,0 +1,459 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/log"
+)
+
+const (
+	// c_addressUtxoUndoRetention is the number of blocks below the head of the
+	// address utxo index the undo data is kept for. A deeper reorg rebuilds
+	// the index.
+	c_addressUtxoUndoRetention = 1024
+	// c_addressUtxoIndexCheckpoint is the number of blocks scanned between two
+	// checkpoints of an address utxo index rebuild
+	c_addressUtxoIndexCheckpoint = 1000
+	// c_addressUtxoIndexRetryInterval is the interval the end of a state sync
+	// is polled at before the address utxo index is rebuilt
+	c_addressUtxoIndexRetryInterval = time.Second
+)
+
+var errAddressUtxoIndexRebuilding = errors.New("address utxo index is being rebuilt")
+
+// indexAddressUtxos reports whether the address utxo index is maintained
+func (hc *HeaderChain) indexAddressUtxos() bool {
+	return hc.indexerConfig != nil && hc.indexerConfig.IndexAddressUtxos &&
+		hc.NodeCtx() == common.ZONE_CTX && hc.ProcessingState()
+}
+
+// initAddressUtxoIndex removes the address utxo index if the indexing was
+// turned off. If the indexing is turned on and the index does not reflect any
+// block yet, because the indexing was just turned on for an existing database
+// or an earlier rebuild was interrupted, the index is rebuilt in the background.
+func (hc *HeaderChain) initAddressUtxoIndex() {
+	db := hc.bc.db
+	if !hc.indexAddressUtxos() {
+		it := db.NewIterator(rawdb.AddressUtxosPrefix, nil)
+		exists := it.Next()
+		it.Release()
+		if !exists && rawdb.ReadAddressUtxoIndexHead(db) == (common.Hash{}) && rawdb.ReadAddressUtxoIndexProgress(db) == nil {
+			return
+		}
+		// Indexing was turned off, delete all prior entries and compact the table
+		start := time.Now()
+		rawdb.DeleteAddressUtxoIndex(db)
+		rawdb.DeleteLegacyAddressUtxos(db)
+		rawdb.DeleteAddressUtxoIndexVersion(db)
+		end := common.CopyBytes(rawdb.AddressUtxosPrefix)
+		end[len(end)-1]++
+		db.Compact(rawdb.AddressUtxosPrefix, end)
+		hc.logger.WithField("elapsed", common.PrettyDuration(time.Since(start))).Info("Removed the address utxo index")
+		return
+	}
+	if version := rawdb.ReadAddressUtxoIndexVersion(db); version != rawdb.AddressUtxoIndexVersion {
+		// The entries of an older layout cannot be updated, drop them and
+		// rebuild the index from the chain. The lists of the unversioned
+		// layouts are still served until the rebuild is done.
+		hc.logger.WithFields(log.Fields{
+			"from": version,
+			"to":   rawdb.AddressUtxoIndexVersion,
+		}).Info("Migrating the address utxo index")
+		rawdb.DeleteAddressUtxoIndex(db)
+		rawdb.WriteAddressUtxoIndexVersion(db, rawdb.AddressUtxoIndexVersion)
+	}
+	if rawdb.ReadAddressUtxoIndexHead(db) != (common.Hash{}) {
+		return
+	}
+	hc.addressIndexLegacy.Store(rawdb.HasLegacyAddressUtxos(db))
+	hc.addressIndexRebuilding.Store(true)
+	hc.wg.Add(1)
+	go hc.rebuildAddressUtxoIndex()
+}
+
+// updateAddressUtxoIndex moves the address utxo index to the current header
+// with the undo data recorded when the blocks were processed, reverting the
+// blocks that were reorged out first. If the undo data of a block is missing
+// the index is rebuilt. The caller must hold headermu.
+func (hc *HeaderChain) updateAddressUtxoIndex() {
+	if !hc.indexAddressUtxos() || hc.addressIndexRebuilding.Load() {
+		return
+	}
+	db := hc.bc.db
+	head := hc.CurrentHeader()
+	indexHash := rawdb.ReadAddressUtxoIndexHead(db)
+	if indexHash == head.Hash() || !rawdb.ReadProcessedState(db, head.Hash()) {
+		return
+	}
+	indexHead := hc.GetHeaderByHash(indexHash)
+	if indexHead == nil {
+		hc.logger.WithField("hash", indexHash).Warn("Address utxo index head not found, rebuilding the index")
+		hc.startAddressUtxoIndexRebuild()
+		return
+	}
+	reverted, applied, err := hc.addressUtxoIndexRoute(indexHead, head)
+	if err != nil {
+		hc.logger.WithField("err", err).Warn("Address utxo index cannot be moved to the head, rebuilding the index")
+		hc.startAddressUtxoIndexRebuild()
+		return
+	}
+	nodeCtx := hc.NodeCtx()
+	location := hc.NodeLocation()
+	batch := db.NewBatch()
+	for _, undo := range reverted {
+		revertAddressUtxoUndo(batch, undo, location)
+	}
+	for _, undo := range applied {
+		applyAddressUtxoUndo(batch, undo, location)
+	}
+	rawdb.WriteAddressUtxoIndexHead(batch, head.Hash())
+
+	// Drop the undo data that went out of the reorg range of the index
+	number := head.NumberU64(nodeCtx)
+	for i := uint64(0); i < uint64(len(applied)) && number-i > c_addressUtxoUndoRetention; i++ {
+		if hash := rawdb.ReadCanonicalHash(db, number-i-c_addressUtxoUndoRetention); hash != (common.Hash{}) {
+			rawdb.DeleteAddressUtxoUndo(batch, hash)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		hc.logger.WithField("err", err).Error("Failed to update the address utxo index")
+	}
+}
+
+// addressUtxoIndexRoute returns the undo data of the blocks to revert from the
+// index head down to the common ancestor with the new head, newest first, and
+// of the blocks to apply from the common ancestor up to the new head, oldest
+// first.
+func (hc *HeaderChain) addressUtxoIndexRoute(from, to *types.WorkObject) ([]*rawdb.AddressUtxoUndo, []*rawdb.AddressUtxoUndo, error) {
+	nodeCtx := hc.NodeCtx()
+	var reverted, applied []*rawdb.AddressUtxoUndo
+	for from.Hash() != to.Hash() {
+		if len(reverted)+len(applied) > 2*c_addressUtxoUndoRetention {
+			return nil, nil, errors.New("address utxo index is too far from the head")
+		}
+		var err error
+		if from.NumberU64(nodeCtx) >= to.NumberU64(nodeCtx) {
+			var undo *rawdb.AddressUtxoUndo
+			if undo, from, err = hc.addressUtxoUndoAndParent(from); err != nil {
+				return nil, nil, err
+			}
+			reverted = append(reverted, undo)
+		} else {
+			var undo *rawdb.AddressUtxoUndo
+			if undo, to, err = hc.addressUtxoUndoAndParent(to); err != nil {
+				return nil, nil, err
+			}
+			applied = append(applied, undo)
+		}
+	}
+	for i, j := 0, len(applied)-1; i < j; i, j = i+1, j-1 {
+		applied[i], applied[j] = applied[j], applied[i]
+	}
+	return reverted, applied, nil
+}
+
+// addressUtxoUndoAndParent returns the address utxo undo data and the parent
+// header of a block
+func (hc *HeaderChain) addressUtxoUndoAndParent(header *types.WorkObject) (*rawdb.AddressUtxoUndo, *types.WorkObject, error) {
+	undo := rawdb.ReadAddressUtxoUndo(hc.bc.db, header.Hash())
+	if undo == nil {
+		return nil, nil, fmt.Errorf("missing address utxo undo data of block %x", header.Hash())
+	}
+	parent := hc.GetHeaderByHash(header.ParentHash(hc.NodeCtx()))
+	if parent == nil {
+		return nil, nil, fmt.Errorf("missing parent of block %x", header.Hash())
+	}
+	return undo, parent, nil
+}
+
+// applyAddressUtxoUndo updates the address utxo index with the outputs spent
+// and created by a block
+func applyAddressUtxoUndo(db ethdb.KeyValueWriter, undo *rawdb.AddressUtxoUndo, location common.Location) {
+	for _, utxo := range undo.Spent {
+		rawdb.DeleteAddressUtxo(db, common.BytesToAddress(utxo.Address, location), utxo.TxHash, utxo.Index)
+	}
+	for _, utxo := range undo.Created {
+		rawdb.WriteAddressUtxo(db, common.BytesToAddress(utxo.Address, location), addressUtxoOutpoint(utxo))
+	}
+}
+
+// revertAddressUtxoUndo reverts the update of the address utxo index with the
+// outputs spent and created by a block
+func revertAddressUtxoUndo(db ethdb.KeyValueWriter, undo *rawdb.AddressUtxoUndo, location common.Location) {
+	for _, utxo := range undo.Created {
+		rawdb.DeleteAddressUtxo(db, common.BytesToAddress(utxo.Address, location), utxo.TxHash, utxo.Index)
+	}
+	for _, utxo := range undo.Spent {
+		rawdb.WriteAddressUtxo(db, common.BytesToAddress(utxo.Address, location), addressUtxoOutpoint(utxo))
+	}
+}
+
+func addressUtxoOutpoint(utxo *rawdb.AddressUtxoChange) *types.OutpointAndDenomination {
+	return &types.OutpointAndDenomination{
+		TxHash:       utxo.TxHash,
+		Index:        utxo.Index,
+		Denomination: utxo.Denomination,
+		Lock:         utxo.Lock,
+	}
+}
+
+// newAddressUtxoUndo returns the undo data of the outputs created and spent by
+// a block, ordered by outpoint
+func newAddressUtxoUndo(created, spent map[types.OutPoint]*types.UtxoEntry) *rawdb.AddressUtxoUndo {
+	changes := func(utxos map[types.OutPoint]*types.UtxoEntry) []*rawdb.AddressUtxoChange {
+		list := make([]*rawdb.AddressUtxoChange, 0, len(utxos))
+		for outpoint, utxo := range utxos {
+			list = append(list, &rawdb.AddressUtxoChange{
+				Address:      utxo.Address,
+				TxHash:       outpoint.TxHash,
+				Index:        outpoint.Index,
+				Denomination: utxo.Denomination,
+				Lock:         utxo.Lock,
+			})
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if cmp := bytes.Compare(list[i].TxHash.Bytes(), list[j].TxHash.Bytes()); cmp != 0 {
+				return cmp < 0
+			}
+			return list[i].Index < list[j].Index
+		})
+		return list
+	}
+	return &rawdb.AddressUtxoUndo{Created: changes(created), Spent: changes(spent)}
+}
+
+// startAddressUtxoIndexRebuild drops the head of the address utxo index and
+// rebuilds the index in the background
+func (hc *HeaderChain) startAddressUtxoIndexRebuild() {
+	rawdb.DeleteAddressUtxoIndexHead(hc.bc.db)
+	rawdb.DeleteAddressUtxoIndexProgress(hc.bc.db)
+	hc.addressIndexRebuilding.Store(true)
+	hc.wg.Add(1)
+	go hc.rebuildAddressUtxoIndex()
+}
+
+// rebuildAddressUtxoIndex indexes the UTXO set of a recent block with a
+// processed state. The outpoints of the UTXO trie cannot be recovered from its
+// hashed keys, so the canonical blocks up to that block are scanned for the
+// outputs they created, and the ones still unspent in its UTXO set are indexed.
+// The progress is checkpointed so that an interrupted rebuild resumes where it
+// stopped. Once done the index is moved to the current header.
+func (hc *HeaderChain) rebuildAddressUtxoIndex() {
+	defer hc.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			hc.logger.WithFields(log.Fields{
+				"error":      r,
+				"stacktrace": string(debug.Stack()),
+			}).Fatal("Go-Quai Panicked")
+		}
+	}()
+	var (
+		db       = hc.bc.db
+		nodeCtx  = hc.NodeCtx()
+		location = hc.NodeLocation()
+		target   *types.WorkObject
+	)
+	progress := rawdb.ReadAddressUtxoIndexProgress(db)
+	if progress != nil {
+		target = hc.GetHeaderByHash(progress.Target)
+		if target == nil {
+			progress = nil
+		}
+	}
+	if progress == nil {
+		if target = hc.addressUtxoIndexTarget(); target == nil {
+			return
+		}
+		// Drop whatever is left of an earlier index
+		rawdb.DeleteAddressUtxoIndex(db)
+		progress = &rawdb.AddressUtxoIndexProgress{Target: target.Hash(), Next: 1}
+		rawdb.WriteAddressUtxoIndexProgress(db, progress)
+	}
+	targetNumber := target.NumberU64(nodeCtx)
+	hc.logger.WithFields(log.Fields{
+		"number": targetNumber,
+		"hash":   target.Hash(),
+		"from":   progress.Next,
+	}).Info("Rebuilding the address utxo index")
+
+	var (
+		start   = time.Now()
+		logged  = time.Now()
+		utxos   int
+		batch   = db.NewBatch()
+		scanned uint64
+	)
+	// The UTXO trie keeps the nodes it resolved in memory, so it is reopened
+	// at every checkpoint
+	openState := func() (*state.StateDB, error) {
+		if hc.IsGenesisHash(target.Hash()) {
+			return hc.StateAt(types.EmptyRootHash, types.EmptyRootHash, types.EmptyRootHash)
+		}
+		return hc.StateAt(target.EVMRoot(), target.UTXORoot(), target.EtxSetRoot())
+	}
+	statedb, err := openState()
+	if err != nil {
+		hc.logger.WithField("err", err).Error("Failed to open the state of the address utxo index rebuild")
+		return
+	}
+	for number := progress.Next; number <= targetNumber; number++ {
+		if atomic.LoadInt32(&hc.running) == 1 {
+			break
+		}
+		block := hc.GetBlock(rawdb.ReadCanonicalHash(db, number), number)
+		if block == nil {
+			hc.logger.WithField("number", number).Error("Missing canonical block, aborting the address utxo index rebuild")
+			return
+		}
+		for _, outpoint := range hc.createdOutpoints(block) {
+			utxo := statedb.GetUTXO(outpoint.TxHash, outpoint.Index)
+			if utxo == nil {
+				continue
+			}
+			rawdb.WriteAddressUtxo(batch, common.BytesToAddress(utxo.Address, location), &types.OutpointAndDenomination{
+				TxHash:       outpoint.TxHash,
+				Index:        outpoint.Index,
+				Denomination: utxo.Denomination,
+				Lock:         utxo.Lock,
+			})
+			utxos++
+		}
+		progress.Next = number + 1
+		scanned++
+		if scanned%c_addressUtxoIndexCheckpoint == 0 || batch.ValueSize() >= ethdb.IdealBatchSize {
+			rawdb.WriteAddressUtxoIndexProgress(batch, progress)
+			if err := batch.Write(); err != nil {
+				hc.logger.WithField("err", err).Error("Failed to write the address utxo index")
+				return
+			}
+			batch.Reset()
+			if statedb, err = openState(); err != nil {
+				hc.logger.WithField("err", err).Error("Failed to open the state of the address utxo index rebuild")
+				return
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			hc.logger.WithFields(log.Fields{
+				"number":  number,
+				"target":  targetNumber,
+				"utxos":   utxos,
+				"elapsed": common.PrettyDuration(time.Since(start)),
+			}).Info("Rebuilding the address utxo index")
+			logged = time.Now()
+		}
+	}
+	if err := statedb.Error(); err != nil {
+		hc.logger.WithField("err", err).Error("Failed to read the UTXO set of the address utxo index rebuild")
+		return
+	}
+	rawdb.WriteAddressUtxoIndexProgress(batch, progress)
+	if err := batch.Write(); err != nil {
+		hc.logger.WithField("err", err).Error("Failed to write the address utxo index")
+		return
+	}
+	if progress.Next <= targetNumber {
+		// Stopped, the rebuild resumes from the checkpoint on the next start
+		return
+	}
+
+	hc.headermu.Lock()
+	defer hc.headermu.Unlock()
+
+	if hc.GetCanonicalHash(targetNumber) != target.Hash() {
+		// The blocks scanned may not be the ancestors of the target anymore
+		hc.logger.WithField("hash", target.Hash()).Warn("Address utxo index rebuild target was reorged, starting over")
+		rawdb.DeleteAddressUtxoIndexProgress(db)
+		hc.wg.Add(1)
+		go hc.rebuildAddressUtxoIndex()
+		return
+	}
+	batch.Reset()
+	rawdb.WriteAddressUtxoIndexHead(batch, target.Hash())
+	rawdb.DeleteAddressUtxoIndexProgress(batch)
+	if err := batch.Write(); err != nil {
+		hc.logger.WithField("err", err).Error("Failed to write the address utxo index")
+		return
+	}
+	hc.addressIndexRebuilding.Store(false)
+	if hc.addressIndexLegacy.Load() {
+		rawdb.DeleteLegacyAddressUtxos(db)
+		hc.addressIndexLegacy.Store(false)
+	}
+	hc.logger.WithFields(log.Fields{
+		"number":  targetNumber,
+		"hash":    target.Hash(),
+		"utxos":   utxos,
+		"elapsed": common.PrettyDuration(time.Since(start)),
+	}).Info("Finished rebuilding the address utxo index")
+
+	hc.updateAddressUtxoIndex()
+}
+
+// addressUtxoIndexTarget waits until the state is synced and returns the most
+// recent block with a processed state, or nil if the chain was stopped.
+func (hc *HeaderChain) addressUtxoIndexTarget() *types.WorkObject {
+	for hc.StateSyncing() {
+		if atomic.LoadInt32(&hc.running) == 1 {
+			return nil
+		}
+		time.Sleep(c_addressUtxoIndexRetryInterval)
+	}
+	current := hc.CurrentHeader()
+	for current != nil && !hc.IsGenesisHash(current.Hash()) && !rawdb.ReadProcessedState(hc.bc.db, current.Hash()) {
+		current = hc.GetHeaderByHash(current.ParentHash(hc.NodeCtx()))
+	}
+	return current
+}
+
+// createdOutpoints returns the outpoints of the outputs a block may have added
+// to the UTXO set of the zone
+func (hc *HeaderChain) createdOutpoints(block *types.WorkObject) []types.OutPoint {
+	var outpoints []types.OutPoint
+	nodeCtx := hc.NodeCtx()
+	if hc.IsGenesisHash(block.ParentHash(nodeCtx)) {
+		// The genesis Qi allocations are added to the UTXO set of the first block
+		outpoints = append(outpoints, GenesisUtxoOutpoints(hc.NodeLocation(), hc.logger)...)
+	}
+	for _, tx := range block.Transactions() {
+		switch tx.Type() {
+		case types.QiTxType:
+			for i := range tx.TxOut() {
+				outpoints = append(outpoints, types.OutPoint{TxHash: tx.Hash(), Index: uint16(i)})
+			}
+		case types.ExternalTxType:
+			if !tx.To().IsInQiLedgerScope() {
+				continue
+			}
+			if !tx.ETXSender().Location().Equal(*tx.To().Location()) {
+				outpoints = append(outpoints, types.OutPoint{TxHash: tx.OriginatingTxHash(), Index: tx.ETXIndex()})
+				continue
+			}
+			// A conversion creates the outputs its gas pays for, the same way
+			// the state processor does
+			primeTerminus := hc.GetHeaderByHash(block.PrimeTerminus())
+			if primeTerminus == nil {
+				hc.logger.WithField("hash", block.PrimeTerminus()).Error("Missing prime terminus, the outputs of the conversions can't be indexed")
+				continue
+			}
+			gasTable := hc.config.GasTable(block.Number(nodeCtx), block.ExpansionNumber())
+			outputs := ConversionOutputs(misc.QuaiToQi(primeTerminus, tx.Value()), tx.Gas(), gasTable)
+			for i := range outputs {
+				outpoints = append(outpoints, types.OutPoint{TxHash: tx.Hash(), Index: uint16(i)})
+			}
+		}
+	}
+	return outpoints
+}
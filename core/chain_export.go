@@ -0,0 +1,137 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/rlp"
+)
+
+// Block export formats. In the RLP format every block is an RLP string
+// holding the protobuf encoding of the block, in the proto format every
+// block is a ProtoWorkObject message prefixed by its uvarint encoded length.
+const (
+	ExportFormatRLP   = "rlp"
+	ExportFormatProto = "proto"
+)
+
+var errUnknownExportFormat = errors.New("unknown block export format")
+
+// ExportBlocks writes the canonical blocks numbered first to last of the
+// chain stored in db to the given writer in the given format.
+func ExportBlocks(db ethdb.Reader, w io.Writer, first uint64, last uint64, format string, logger *log.Logger) error {
+	if first > last {
+		return fmt.Errorf("export failed: first (%d) is greater than last (%d)", first, last)
+	}
+	if format != ExportFormatRLP && format != ExportFormatProto {
+		return errUnknownExportFormat
+	}
+	logger.WithFields(log.Fields{
+		"count":  last - first + 1,
+		"format": format,
+	}).Info("Exporting batch of blocks")
+
+	var (
+		start    = time.Now()
+		reported = time.Now()
+	)
+	for nr := first; nr <= last; nr++ {
+		hash := rawdb.ReadCanonicalHash(db, nr)
+		if hash == (common.Hash{}) {
+			return fmt.Errorf("export failed on #%d: not found", nr)
+		}
+		block := rawdb.ReadWorkObject(db, hash, types.BlockObject)
+		if block == nil {
+			return fmt.Errorf("export failed on #%d: not found", nr)
+		}
+		if err := writeBlock(w, block, format); err != nil {
+			return fmt.Errorf("export failed on #%d: %v", nr, err)
+		}
+		if time.Since(reported) >= 8*time.Second {
+			logger.WithFields(log.Fields{
+				"exported": nr - first + 1,
+				"elapsed":  common.PrettyDuration(time.Since(start)),
+			}).Info("Exporting blocks")
+			reported = time.Now()
+		}
+	}
+	return nil
+}
+
+// writeBlock encodes a single block in the given format into w.
+func writeBlock(w io.Writer, block *types.WorkObject, format string) error {
+	protoBlock, err := block.ProtoEncode(types.BlockObject)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case ExportFormatRLP:
+		data, err := proto.Marshal(protoBlock)
+		if err != nil {
+			return err
+		}
+		return rlp.Encode(w, data)
+	case ExportFormatProto:
+		_, err := protodelim.MarshalTo(w, protoBlock)
+		return err
+	}
+	return errUnknownExportFormat
+}
+
+// BlockDecoder reads back the blocks written by ExportBlocks.
+type BlockDecoder struct {
+	format   string
+	location common.Location
+	stream   *rlp.Stream
+	reader   *bufio.Reader
+}
+
+// NewBlockDecoder creates a decoder reading blocks of the given format from r.
+func NewBlockDecoder(r io.Reader, format string, location common.Location) (*BlockDecoder, error) {
+	d := &BlockDecoder{format: format, location: location}
+	switch format {
+	case ExportFormatRLP:
+		d.stream = rlp.NewStream(r, 0)
+	case ExportFormatProto:
+		d.reader = bufio.NewReader(r)
+	default:
+		return nil, errUnknownExportFormat
+	}
+	return d, nil
+}
+
+// Next decodes the next block of the stream. It returns io.EOF once all the
+// blocks have been read.
+func (d *BlockDecoder) Next() (*types.WorkObject, error) {
+	protoBlock := new(types.ProtoWorkObject)
+	switch d.format {
+	case ExportFormatRLP:
+		data, err := d.stream.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		if err := proto.Unmarshal(data, protoBlock); err != nil {
+			return nil, err
+		}
+	case ExportFormatProto:
+		if err := (protodelim.UnmarshalOptions{MaxSize: -1}).UnmarshalFrom(d.reader, protoBlock); err != nil {
+			return nil, err
+		}
+	}
+	block := new(types.WorkObject)
+	if err := block.ProtoDecode(protoBlock, d.location, types.BlockObject); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
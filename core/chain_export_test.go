@@ -0,0 +1,63 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/log"
+)
+
+func TestExportBlocksFormats(t *testing.T) {
+	location := common.Location{0, 0}
+	db := rawdb.NewMemoryDatabase(log.Global)
+
+	var hashes []common.Hash
+	parent := common.Hash{}
+	for i := int64(0); i < 4; i++ {
+		block := types.EmptyHeader(common.ZONE_CTX)
+		block.WorkObjectHeader().SetLocation(location)
+		block.WorkObjectHeader().SetNumber(big.NewInt(i))
+		block.WorkObjectHeader().SetParentHash(parent)
+		block.WorkObjectHeader().SetHeaderHash(block.Header().Hash())
+
+		rawdb.WriteWorkObject(db, block.Hash(), block, types.BlockObject, common.ZONE_CTX)
+		rawdb.WriteCanonicalHash(db, block.Hash(), uint64(i))
+		hashes = append(hashes, block.Hash())
+		parent = block.Hash()
+	}
+	for _, format := range []string{ExportFormatRLP, ExportFormatProto} {
+		var buf bytes.Buffer
+		if err := ExportBlocks(db, &buf, 1, 3, format, log.Global); err != nil {
+			t.Fatalf("%s: failed to export blocks: %v", format, err)
+		}
+		stream, err := NewBlockDecoder(&buf, format, location)
+		if err != nil {
+			t.Fatalf("%s: failed to create decoder: %v", format, err)
+		}
+		for i := 1; ; i++ {
+			block, err := stream.Next()
+			if err == io.EOF {
+				if i != 4 {
+					t.Errorf("%s: decoded %d blocks, want 3", format, i-1)
+				}
+				break
+			} else if err != nil {
+				t.Fatalf("%s: block %d: failed to decode: %v", format, i, err)
+			}
+			if block.Hash() != hashes[i] {
+				t.Errorf("%s: block %d: hash mismatch: have %x, want %x", format, i, block.Hash(), hashes[i])
+			}
+		}
+	}
+	if err := ExportBlocks(db, io.Discard, 1, 3, "json", log.Global); err != errUnknownExportFormat {
+		t.Errorf("export error mismatch: have %v, want %v", err, errUnknownExportFormat)
+	}
+	if _, err := NewBlockDecoder(&bytes.Buffer{}, "json", location); err != errUnknownExportFormat {
+		t.Errorf("decoder error mismatch: have %v, want %v", err, errUnknownExportFormat)
+	}
+}
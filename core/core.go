@@ -29,6 +29,7 @@ import (
 	"github.com/dominant-strategies/go-quai/params"
 	"github.com/dominant-strategies/go-quai/quaiclient"
 	"github.com/dominant-strategies/go-quai/rlp"
+	"github.com/dominant-strategies/go-quai/rpc"
 	"github.com/dominant-strategies/go-quai/trie"
 )
 
@@ -73,6 +74,8 @@ type Core struct {
 
 	normalListBackoff uint64 // normalListBackoff is the multiple on c_normalListProcCounter which delays the proc on normal list
 
+	importedBlocks []types.HashAndNumber // blocks of an offline chain import left to append, oldest first
+
 	quit chan struct{} // core quit channel
 
 	logger *log.Logger
@@ -80,10 +83,11 @@ type Core struct {
 
 type IndexerConfig struct {
 	IndexAddressUtxos bool
+	IndexEtxs         bool
 }
 
-func NewCore(db ethdb.Database, config *Config, isLocalBlock func(block *types.WorkObject) bool, txConfig *TxPoolConfig, txLookupLimit *uint64, chainConfig *params.ChainConfig, slicesRunning []common.Location, currentExpansionNumber uint8, genesisBlock *types.WorkObject, domClientUrl string, subClientUrls []string, engine consensus.Engine, cacheConfig *CacheConfig, vmConfig vm.Config, indexerConfig *IndexerConfig, genesis *Genesis, logger *log.Logger) (*Core, error) {
-	slice, err := NewSlice(db, config, txConfig, txLookupLimit, isLocalBlock, chainConfig, slicesRunning, currentExpansionNumber, genesisBlock, domClientUrl, subClientUrls, engine, cacheConfig, indexerConfig, vmConfig, genesis, logger)
+func NewCore(db ethdb.Database, config *Config, isLocalBlock func(block *types.WorkObject) bool, txConfig *TxPoolConfig, txLookupLimit *uint64, chainConfig *params.ChainConfig, slicesRunning []common.Location, currentExpansionNumber uint8, genesisBlock *types.WorkObject, domClientUrl string, subClientUrls []string, rpcAuth rpc.HTTPAuth, engine consensus.Engine, cacheConfig *CacheConfig, vmConfig vm.Config, indexerConfig *IndexerConfig, genesis *Genesis, logger *log.Logger) (*Core, error) {
+	slice, err := NewSlice(db, config, txConfig, txLookupLimit, isLocalBlock, chainConfig, slicesRunning, currentExpansionNumber, genesisBlock, domClientUrl, subClientUrls, rpcAuth, engine, cacheConfig, indexerConfig, vmConfig, genesis, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -106,6 +110,8 @@ func NewCore(db ethdb.Database, config *Config, isLocalBlock func(block *types.W
 	remoteTxQueue, _ := lru.New[common.Hash, types.Transaction](c_maxRemoteTxQueue)
 	c.remoteTxQueue = remoteTxQueue
 
+	c.loadImportedBlocks()
+
 	go c.updateAppendQueue()
 	go c.startStatsTimer()
 	if c.NodeCtx() == common.ZONE_CTX && c.ProcessingState() {
@@ -207,16 +213,23 @@ func (c *Core) InsertChain(blocks types.WorkObjects) (int, error) {
 	return len(blocks), nil
 }
 
+// maxFutureBlocks returns the number of blocks ahead of the current header the
+// append queue attempts to append
+func (c *Core) maxFutureBlocks() uint64 {
+	switch c.NodeCtx() {
+	case common.REGION_CTX:
+		return c_maxFutureBlocksRegion
+	case common.ZONE_CTX:
+		return c_maxFutureBlocksZone
+	default:
+		return c_maxFutureBlocksPrime
+	}
+}
+
 // procAppendQueue sorts the append queue and attempts to append
 func (c *Core) procAppendQueue() {
 	nodeCtx := c.NodeLocation().Context()
-
-	maxFutureBlocks := c_maxFutureBlocksPrime
-	if nodeCtx == common.REGION_CTX {
-		maxFutureBlocks = c_maxFutureBlocksRegion
-	} else if nodeCtx == common.ZONE_CTX {
-		maxFutureBlocks = c_maxFutureBlocksZone
-	}
+	maxFutureBlocks := c.maxFutureBlocks()
 
 	// Sort the blocks by number and retry attempts and try to insert them
 	// blocks will be aged out of the append queue after the retry threhsold
@@ -393,6 +406,85 @@ func (c *Core) addToAppendQueue(block *types.WorkObject) error {
 	return nil
 }
 
+// loadImportedBlocks reads the blocks stored by an offline chain import which
+// are not appended yet, for queueImportedBlocks to feed them to the append
+// queue. The import marker is removed if all of them have been appended.
+func (c *Core) loadImportedBlocks() {
+	nodeCtx := c.NodeCtx()
+	db := c.sl.sliceDb
+	hash := rawdb.ReadLastImportedBlockHash(db)
+	if hash == (common.Hash{}) {
+		return
+	}
+	var imported []types.HashAndNumber
+	for c.GetHeaderByHash(hash) == nil {
+		block := rawdb.ReadWorkObjectHeaderOnly(db, hash, types.BlockObject)
+		if block == nil || block.NumberU64(nodeCtx) == 0 {
+			break
+		}
+		imported = append(imported, types.HashAndNumber{Hash: hash, Number: block.NumberU64(nodeCtx)})
+		hash = block.ParentHash(nodeCtx)
+	}
+	if len(imported) == 0 {
+		rawdb.DeleteLastImportedBlockHash(db)
+		return
+	}
+	for i, j := 0, len(imported)-1; i < j; i, j = i+1, j-1 {
+		imported[i], imported[j] = imported[j], imported[i]
+	}
+	c.importedBlocks = imported
+	c.logger.WithFields(log.Fields{
+		"count": len(imported),
+		"first": imported[0].Number,
+		"last":  imported[len(imported)-1].Number,
+	}).Info("Appending imported blocks")
+}
+
+// queueImportedBlocks feeds the append queue with the imported blocks within
+// the range it appends above the current header, so that the whole import is
+// appended as the chain advances. The import marker is removed once all the
+// imported blocks have been appended or left behind by the chain.
+func (c *Core) queueImportedBlocks() {
+	if len(c.importedBlocks) == 0 {
+		return
+	}
+	nodeCtx := c.NodeCtx()
+	head := c.CurrentHeader().NumberU64(nodeCtx)
+	for len(c.importedBlocks) > 0 {
+		next := c.importedBlocks[0]
+		if c.GetHeaderByHash(next.Hash) == nil && next.Number+c_appendQueueRemoveThreshold >= head {
+			break
+		}
+		c.importedBlocks = c.importedBlocks[1:]
+	}
+	if len(c.importedBlocks) == 0 {
+		c.importedBlocks = nil
+		rawdb.DeleteLastImportedBlockHash(c.sl.sliceDb)
+		c.logger.Info("Appended the imported blocks")
+		return
+	}
+	maxFutureBlocks := c.maxFutureBlocks()
+	for _, imported := range c.importedBlocks {
+		if imported.Number >= head+maxFutureBlocks {
+			break
+		}
+		if c.appendQueue.Contains(imported.Hash) {
+			continue
+		}
+		block := rawdb.ReadWorkObjectHeaderOnly(c.sl.sliceDb, imported.Hash, types.BlockObject)
+		if block == nil {
+			c.logger.WithField("hash", imported.Hash).Warn("Imported block not found")
+			break
+		}
+		if err := c.addToAppendQueue(block); err != nil {
+			c.logger.WithFields(log.Fields{
+				"hash": imported.Hash,
+				"err":  err,
+			}).Warn("Failed to queue imported block")
+		}
+	}
+}
+
 // removeFromAppendQueue removes a block from the append queue
 func (c *Core) removeFromAppendQueue(block *types.WorkObject) {
 	c.appendQueue.Remove(block.Hash())
@@ -413,6 +505,7 @@ func (c *Core) updateAppendQueue() {
 	for {
 		select {
 		case <-futureTimer.C:
+			c.queueImportedBlocks()
 			c.procAppendQueue()
 		case <-c.quit:
 			return
@@ -909,6 +1002,64 @@ func (c *Core) GetTerminiByHash(hash common.Hash) *types.Termini {
 	return c.sl.hc.GetTerminiByHash(hash)
 }
 
+// StateSyncing reports whether the state is being downloaded from the peers
+func (c *Core) StateSyncing() bool {
+	return c.sl.hc.StateSyncing()
+}
+
+// StartStateSync stops processing the appended blocks until the state of a
+// pivot block has been downloaded from the peers
+func (c *Core) StartStateSync() {
+	c.sl.hc.StartStateSync()
+}
+
+// SetStateSyncPivot records the block whose state is being downloaded
+func (c *Core) SetStateSyncPivot(pivot *types.WorkObject) {
+	c.sl.hc.SetStateSyncPivot(pivot)
+}
+
+// CompleteStateSync switches the chain back to full processing from the pivot
+// block whose state has been downloaded
+func (c *Core) CompleteStateSync(pivot *types.WorkObject) error {
+	return c.sl.hc.CompleteStateSync(pivot)
+}
+
+// NewStateSync creates the download schedulers of the EVM, UTXO and ETX tries
+// of the given block
+func (c *Core) NewStateSync(block *types.WorkObject) []*trie.Sync {
+	db := c.sl.sliceDb
+	return []*trie.Sync{
+		state.NewStateSync(block.EVMRoot(), db, nil, nil),
+		trie.NewSync(block.UTXORoot(), db, nil, nil),
+		trie.NewSync(block.EtxSetRoot(), db, nil, nil),
+	}
+}
+
+// CommitStateSync writes the trie nodes downloaded by the scheduler to the
+// database along with the number of state entries pulled so far
+func (c *Core) CommitStateSync(sched *trie.Sync, pulled uint64) error {
+	batch := c.sl.sliceDb.NewBatch()
+	if err := sched.Commit(batch); err != nil {
+		return err
+	}
+	rawdb.WriteFastTrieProgress(batch, pulled)
+	return batch.Write()
+}
+
+// StateSyncProgress returns the number of state entries pulled by the state sync
+func (c *Core) StateSyncProgress() uint64 {
+	return rawdb.ReadFastTrieProgress(c.sl.sliceDb)
+}
+
+// GetDomTerminiByHash asks the dom chain for the termini it stored for a given
+// header hash. It fails in prime, and if the dom has not appended the header yet
+func (c *Core) GetDomTerminiByHash(hash common.Hash) (*types.Termini, error) {
+	if c.sl.domClient == nil {
+		return nil, ErrDomClientNotUp
+	}
+	return c.sl.domClient.GetTerminiByHash(context.Background(), hash)
+}
+
 // SubscribeChainSideEvent registers a subscription of ChainSideEvent.
 func (c *Core) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Subscription {
 	return c.sl.hc.SubscribeChainSideEvent(ch)
@@ -972,14 +1123,15 @@ func (c *Core) SubscribeBlockProcessingEvent(ch chan<- bool) event.Subscription
 	return c.sl.hc.bc.SubscribeBlockProcessingEvent(ch)
 }
 
-// Export writes the active chain to the given writer.
-func (c *Core) Export(w io.Writer) error {
-	return c.sl.hc.Export(w)
+// Export writes the active chain to the given writer in the given format.
+func (c *Core) Export(w io.Writer, format string) error {
+	return c.sl.hc.Export(w, format)
 }
 
-// ExportN writes a subset of the active chain to the given writer.
-func (c *Core) ExportN(w io.Writer, first uint64, last uint64) error {
-	return c.sl.hc.ExportN(w, first, last)
+// ExportN writes a subset of the active chain to the given writer in the given
+// format.
+func (c *Core) ExportN(w io.Writer, first uint64, last uint64, format string) error {
+	return c.sl.hc.ExportN(w, first, last, format)
 }
 
 // Snapshots returns the blockchain snapshot tree.
@@ -995,6 +1147,10 @@ func (c *Core) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscription {
 	return c.sl.txPool.SubscribeNewTxsEvent(ch)
 }
 
+func (c *Core) SubscribeMissingTxsEvent(ch chan<- MissingTxsEvent) event.Subscription {
+	return c.sl.txPool.SubscribeMissingTxsEvent(ch)
+}
+
 func (c *Core) SetExtra(extra []byte) error {
 	return c.sl.miner.SetExtra(extra)
 }
@@ -1146,6 +1302,10 @@ func (c *Core) GetUTXOsByAddress(addr common.Address) ([]*types.UtxoEntry, error
 	return c.sl.hc.bc.processor.GetUTXOsByAddress(addr)
 }
 
+func (c *Core) GetOutpointsByAddress(addr common.Address) ([]*types.OutpointAndDenomination, error) {
+	return c.sl.hc.bc.processor.GetOutpointsByAddress(addr)
+}
+
 //----------------//
 // TxPool methods //
 //----------------//
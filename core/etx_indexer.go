@@ -0,0 +1,48 @@
+package core
+
+import (
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/log"
+)
+
+// indexEtxs reports whether the slices crossed by the etxs are indexed. Each
+// slice indexes the etxs at the stage it handles them: the region indexes the
+// block rolling up the etxs emitted by its zones, the prime indexes the block
+// including the rollups of its regions and the destination zone indexes the
+// dom block delivering the etxs to its etx set.
+func (sl *Slice) indexEtxs() bool {
+	return sl.hc.indexerConfig != nil && sl.hc.indexerConfig.IndexEtxs
+}
+
+// writeEtxLifecycle records that the etxs crossed the slice at the given block
+func (sl *Slice) writeEtxLifecycle(batch ethdb.KeyValueWriter, block *types.WorkObject, etxs types.Transactions) {
+	number := block.NumberU64(sl.NodeCtx())
+	for _, etx := range etxs {
+		rawdb.WriteEtxLifecycleEntry(batch, etx.Hash(), block.Hash(), number)
+	}
+}
+
+// indexSubRollup records the etxs rolled up by the subordinate chain into the
+// given block. The rollup is collected while appending the block, so it is
+// expected to be found in the cache or in the database.
+func (sl *Slice) indexSubRollup(batch ethdb.KeyValueWriter, block *types.WorkObject) {
+	if sl.NodeCtx() == common.ZONE_CTX {
+		return
+	}
+	subRollup, exists := sl.hc.subRollupCache.Get(block.Hash())
+	if !exists || subRollup == nil {
+		var err error
+		subRollup, err = sl.hc.CollectSubRollup(block)
+		if err != nil {
+			sl.logger.WithFields(log.Fields{
+				"hash": block.Hash(),
+				"err":  err,
+			}).Warn("Unable to index the etxs of the sub rollup")
+			return
+		}
+	}
+	sl.writeEtxLifecycle(batch, block, subRollup)
+}
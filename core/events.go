@@ -8,6 +8,10 @@ import (
 // NewTxsEvent is posted when a batch of transactions enter the transaction pool.
 type NewTxsEvent struct{ Txs []*types.Transaction }
 
+// MissingTxsEvent is posted when remote Qi transactions are rejected for
+// spending outputs of transactions the pool does not know.
+type MissingTxsEvent struct{ Hashes []common.Hash }
+
 // NewMinedBlockEvent is posted when a block has been imported.
 type NewMinedBlockEvent struct{ Block *types.WorkObject }
 
@@ -94,6 +94,7 @@ func NewEVMBlockContext(header *types.WorkObject, chain ChainContext, author *co
 		GetHash:            GetHashFn(header, chain),
 		Coinbase:           beneficiary,
 		BlockNumber:        new(big.Int).Set(header.Number(chain.NodeCtx())),
+		ExpansionNumber:    header.ExpansionNumber(),
 		Time:               new(big.Int).SetUint64(timestamp),
 		Difficulty:         new(big.Int).Set(header.Difficulty()),
 		BaseFee:            baseFee,
@@ -57,21 +57,41 @@ type Blockchain interface {
 
 // ID is a fork identifier
 type ID struct {
-	Hash [4]byte // CRC32 checksum of the genesis block and passed fork block numbers
+	Hash [4]byte // CRC32 checksum of the genesis block and passed fork expansion and block numbers
 	Next uint64  // Block number of the next upcoming fork, or 0 if no forks are known
 }
 
+// forks holds the sorted fork block and fork expansion numbers of a chain,
+// without the ones active from genesis.
+type forks struct {
+	blocks     []uint64
+	expansions []uint64
+}
+
 // Filter is a fork id filter to validate a remotely advertised ID.
 type Filter func(id ID) error
 
-// NewID calculates the Quai fork ID from the chain config, genesis hash, and head.
-func NewID(config *params.ChainConfig, genesis common.Hash, head uint64) ID {
-	// Calculate the starting checksum from the genesis hash
-	hash := crc32.ChecksumIEEE(genesis[:])
+// NewID calculates the Quai fork ID from the chain config, genesis hash, and
+// head block and expansion numbers.
+func NewID(config *params.ChainConfig, genesis common.Hash, head uint64, expansion uint8) ID {
+	return newID(gatherForks(config), genesis, head, expansion)
+}
 
+// newID is the internal version of NewID, taking the gathered forks instead of
+// the chain config.
+func newID(forks forks, genesis common.Hash, head uint64, expansion uint8) ID {
+	// Calculate the starting checksum from the genesis hash and the passed
+	// expansion forks
+	hash := crc32.ChecksumIEEE(genesis[:])
+	for _, fork := range forks.expansions {
+		if fork > uint64(expansion) {
+			break
+		}
+		hash = checksumUpdateExpansion(hash, fork)
+	}
 	// Calculate the current fork checksum and the next fork block
 	var next uint64
-	for _, fork := range gatherForks(config) {
+	for _, fork := range forks.blocks {
 		if fork <= head {
 			// Fork already passed, checksum the previous hash and the fork number
 			hash = checksumUpdate(hash, fork)
@@ -85,10 +105,12 @@ func NewID(config *params.ChainConfig, genesis common.Hash, head uint64) ID {
 
 // NewIDWithChain calculates the Quai fork ID from an existing chain instance.
 func NewIDWithChain(chain Blockchain) ID {
+	head := chain.CurrentHeader()
 	return NewID(
 		chain.Config(),
 		chain.Genesis().Hash(),
-		chain.CurrentHeader().Number(chain.Config().Location.Context()).Uint64(),
+		head.Number(chain.Config().Location.Context()).Uint64(),
+		head.ExpansionNumber(),
 	)
 }
 
@@ -96,103 +118,141 @@ func NewIDWithChain(chain Blockchain) ID {
 // based on the local chain's status.
 func NewFilter(chain Blockchain) Filter {
 	return newFilter(
-		chain.Config(),
+		gatherForks(chain.Config()),
 		chain.Genesis().Hash(),
-		func() uint64 {
-			return chain.CurrentHeader().Number(chain.Config().Location.Context()).Uint64()
+		func() (uint64, uint8) {
+			head := chain.CurrentHeader()
+			return head.Number(chain.Config().Location.Context()).Uint64(), head.ExpansionNumber()
 		},
 	)
 }
 
-// NewStaticFilter creates a filter at block zero.
+// NewStaticFilter creates a filter at block and expansion zero.
 func NewStaticFilter(config *params.ChainConfig, genesis common.Hash) Filter {
-	head := func() uint64 { return 0 }
-	return newFilter(config, genesis, head)
+	head := func() (uint64, uint8) { return 0, 0 }
+	return newFilter(gatherForks(config), genesis, head)
 }
 
-// newFilter is the internal version of NewFilter, taking closures as its arguments
-// instead of a chain. The reason is to allow testing it without having to simulate
-// an entire blockchain.
-func newFilter(config *params.ChainConfig, genesis common.Hash, headfn func() uint64) Filter {
-	// Calculate the all the valid fork hash and fork next combos
-	var (
-		forks = gatherForks(config)
-		sums  = make([][4]byte, len(forks)+1) // 0th is the genesis
-	)
-	hash := crc32.ChecksumIEEE(genesis[:])
-	sums[0] = checksumToBytes(hash)
-	for i, fork := range forks {
-		hash = checksumUpdate(hash, fork)
-		sums[i+1] = checksumToBytes(hash)
+// newFilter is the internal version of NewFilter, taking the gathered forks and
+// closures as its arguments instead of a chain. The reason is to allow testing it
+// without having to simulate an entire blockchain.
+func newFilter(forks forks, genesis common.Hash, headfn func() (uint64, uint8)) Filter {
+	// Calculate the starting checksums of every expansion the hierarchy can be
+	// in, bases[i] covering the genesis and the first i expansion forks
+	bases := make([]uint32, len(forks.expansions)+1)
+	bases[0] = crc32.ChecksumIEEE(genesis[:])
+	for i, fork := range forks.expansions {
+		bases[i+1] = checksumUpdateExpansion(bases[i], fork)
 	}
-	// Add two sentries to simplify the fork checks and don't require special
-	// casing the last one.
-	forks = append(forks, math.MaxUint64) // Last fork will never be passed
+	// Calculate the all the valid fork hash combos of every expansion, sums[i][0]
+	// being the starting checksum of the expansion
+	sums := make([][][4]byte, len(bases))
+	for i, hash := range bases {
+		sums[i] = make([][4]byte, len(forks.blocks)+1)
+		sums[i][0] = checksumToBytes(hash)
+		for j, fork := range forks.blocks {
+			hash = checksumUpdate(hash, fork)
+			sums[i][j+1] = checksumToBytes(hash)
+		}
+	}
+	// Add a sentry to simplify the fork checks and don't require special casing
+	// the last one.
+	blocks := append(append([]uint64{}, forks.blocks...), math.MaxUint64) // Last fork will never be passed
 
 	// Create a validator that will filter out incompatible chains
 	return func(id ID) error {
-		// Run the fork checksum validation ruleset:
-		//   1. If local and remote FORK_CSUM matches, compare local head to FORK_NEXT.
-		//        The two nodes are in the same fork state currently. They might know
-		//        of differing future forks, but that's not relevant until the fork
-		//        triggers (might be postponed, nodes might be updated to match).
-		//      1a. A remotely announced but remotely not passed block is already passed
-		//          locally, disconnect, since the chains are incompatible.
-		//      1b. No remotely announced fork; or not yet passed locally, connect.
-		//   2. If the remote FORK_CSUM is a subset of the local past forks and the
-		//      remote FORK_NEXT matches with the locally following fork block number,
-		//      connect.
-		//        Remote node is currently syncing. It might eventually diverge from
-		//        us, but at this current point in time we don't have enough information.
-		//   3. If the remote FORK_CSUM is a superset of the local past forks and can
-		//      be completed with locally known future forks, connect.
-		//        Local node is currently syncing. It might eventually diverge from
-		//        the remote, but at this current point in time we don't have enough
-		//        information.
-		//   4. Reject in all other cases.
-		head := headfn()
-		for i, fork := range forks {
-			// If our head is beyond this fork, continue to the next (we have a dummy
-			// fork of maxuint64 as the last item to always fail this check eventually).
-			if head >= fork {
+		head, expansion := headfn()
+		passed := 0
+		for passed < len(forks.expansions) && forks.expansions[passed] <= uint64(expansion) {
+			passed++
+		}
+		err := validateBlockForks(sums[passed], blocks, head, id)
+		if err != ErrLocalIncompatibleOrStale {
+			return err
+		}
+		// The remote checksum doesn't match our expansion, check if the remote is
+		// at a past or future expansion of our chain (rule #4). One of the nodes
+		// is syncing across an expansion fork, the block forks can't be compared
+		// until it caught up.
+		for i := range sums {
+			if i == passed {
 				continue
 			}
-			// Found the first unpassed fork block, check if our current state matches
-			// the remote checksum (rule #1).
-			if sums[i] == id.Hash {
-				// Fork checksum matched, check if a remote future fork block already passed
-				// locally without the local node being aware of it (rule #1a).
-				if id.Next > 0 && head >= id.Next {
-					return ErrLocalIncompatibleOrStale
-				}
-				// Haven't passed locally a remote-only fork, accept the connection (rule #1b).
-				return nil
-			}
-			// The local and remote nodes are in different forks currently, check if the
-			// remote checksum is a subset of our local forks (rule #2).
-			for j := 0; j < i; j++ {
-				if sums[j] == id.Hash {
-					// Remote checksum is a subset, validate based on the announced next fork
-					if forks[j] != id.Next {
-						return ErrRemoteStale
-					}
+			for _, sum := range sums[i] {
+				if sum == id.Hash {
 					return nil
 				}
 			}
-			// Remote chain is not a subset of our local one, check if it's a superset by
-			// any chance, signalling that we're simply out of sync (rule #3).
-			for j := i + 1; j < len(sums); j++ {
-				if sums[j] == id.Hash {
-					// Yay, remote checksum is a superset, ignore upcoming forks
-					return nil
+		}
+		return err
+	}
+}
+
+// validateBlockForks validates a remote fork ID against the fork checksums and
+// block numbers of the local expansion, the last fork being a sentry that is
+// never passed.
+func validateBlockForks(sums [][4]byte, forks []uint64, head uint64, id ID) error {
+	//   1. If local and remote FORK_CSUM matches, compare local head to FORK_NEXT.
+	//        The two nodes are in the same fork state currently. They might know
+	//        of differing future forks, but that's not relevant until the fork
+	//        triggers (might be postponed, nodes might be updated to match).
+	//      1a. A remotely announced but remotely not passed block is already passed
+	//          locally, disconnect, since the chains are incompatible.
+	//      1b. No remotely announced fork; or not yet passed locally, connect.
+	//   2. If the remote FORK_CSUM is a subset of the local past forks and the
+	//      remote FORK_NEXT matches with the locally following fork block number,
+	//      connect.
+	//        Remote node is currently syncing. It might eventually diverge from
+	//        us, but at this current point in time we don't have enough information.
+	//   3. If the remote FORK_CSUM is a superset of the local past forks and can
+	//      be completed with locally known future forks, connect.
+	//        Local node is currently syncing. It might eventually diverge from
+	//        the remote, but at this current point in time we don't have enough
+	//        information.
+	//   4. If the remote FORK_CSUM is one of another expansion of the local chain,
+	//      connect (see newFilter).
+	//   5. Reject in all other cases.
+	for i, fork := range forks {
+		// If our head is beyond this fork, continue to the next (we have a dummy
+		// fork of maxuint64 as the last item to always fail this check eventually).
+		if head >= fork {
+			continue
+		}
+		// Found the first unpassed fork block, check if our current state matches
+		// the remote checksum (rule #1).
+		if sums[i] == id.Hash {
+			// Fork checksum matched, check if a remote future fork block already passed
+			// locally without the local node being aware of it (rule #1a).
+			if id.Next > 0 && head >= id.Next {
+				return ErrLocalIncompatibleOrStale
+			}
+			// Haven't passed locally a remote-only fork, accept the connection (rule #1b).
+			return nil
+		}
+		// The local and remote nodes are in different forks currently, check if the
+		// remote checksum is a subset of our local forks (rule #2).
+		for j := 0; j < i; j++ {
+			if sums[j] == id.Hash {
+				// Remote checksum is a subset, validate based on the announced next fork
+				if forks[j] != id.Next {
+					return ErrRemoteStale
 				}
+				return nil
+			}
+		}
+		// Remote chain is not a subset of our local one, check if it's a superset by
+		// any chance, signalling that we're simply out of sync (rule #3).
+		for j := i + 1; j < len(sums); j++ {
+			if sums[j] == id.Hash {
+				// Yay, remote checksum is a superset, ignore upcoming forks
+				return nil
 			}
-			// No exact, subset or superset match. We are on differing chains, reject.
-			return ErrLocalIncompatibleOrStale
 		}
-		log.Global.WithField("id", id).Error("Impossible fork ID validation")
-		return nil // Something's very wrong, accept rather than reject
+		// No exact, subset or superset match. We are on differing chains, reject.
+		return ErrLocalIncompatibleOrStale
 	}
+	log.Global.WithField("id", id).Error("Impossible fork ID validation")
+	return nil // Something's very wrong, accept rather than reject
 }
 
 // checksumUpdate calculates the next IEEE CRC32 checksum based on the previous
@@ -203,6 +263,13 @@ func checksumUpdate(hash uint32, fork uint64) uint32 {
 	return crc32.Update(hash, crc32.IEEETable, blob[:])
 }
 
+// checksumUpdateExpansion calculates the next IEEE CRC32 checksum based on the
+// previous one and a fork expansion number. The expansion is checksummed as a
+// single byte, which keeps it apart from the 8 bytes of a fork block number.
+func checksumUpdateExpansion(hash uint32, fork uint64) uint32 {
+	return crc32.Update(hash, crc32.IEEETable, []byte{byte(fork)})
+}
+
 // checksumToBytes converts a uint32 checksum into a [4]byte array.
 func checksumToBytes(hash uint32) [4]byte {
 	var blob [4]byte
@@ -210,29 +277,37 @@ func checksumToBytes(hash uint32) [4]byte {
 	return blob
 }
 
-// gatherForks gathers all the known forks and creates a sorted list out of them.
-func gatherForks(config *params.ChainConfig) []uint64 {
-	// Gather all the fork block numbers via reflection
+// gatherForks gathers all the known fork block and fork expansion numbers and
+// creates sorted lists out of them.
+func gatherForks(config *params.ChainConfig) forks {
+	// Gather all the fork block and expansion numbers via reflection
 	kind := reflect.TypeOf(params.ChainConfig{})
 	conf := reflect.ValueOf(config).Elem()
 
-	var forks []uint64
+	var blocks, expansions []uint64
 	for i := 0; i < kind.NumField(); i++ {
 		// Fetch the next field and skip non-fork rules
 		field := kind.Field(i)
-		if !strings.HasSuffix(field.Name, "Block") {
-			continue
-		}
-		if field.Type != reflect.TypeOf(new(big.Int)) {
-			continue
-		}
-		// Extract the fork rule block number and aggregate it
-		rule := conf.Field(i).Interface().(*big.Int)
-		if rule != nil {
-			forks = append(forks, rule.Uint64())
+		switch {
+		case strings.HasSuffix(field.Name, "Block") && field.Type == reflect.TypeOf(new(big.Int)):
+			// Extract the fork rule block number and aggregate it
+			if rule := conf.Field(i).Interface().(*big.Int); rule != nil {
+				blocks = append(blocks, rule.Uint64())
+			}
+		case strings.HasSuffix(field.Name, "Expansion") && field.Type == reflect.TypeOf(new(uint8)):
+			// Extract the fork rule expansion number and aggregate it
+			if rule := conf.Field(i).Interface().(*uint8); rule != nil {
+				expansions = append(expansions, uint64(*rule))
+			}
 		}
 	}
-	// Sort the fork block numbers to permit chronological XOR
+	return forks{blocks: sortForks(blocks), expansions: sortForks(expansions)}
+}
+
+// sortForks sorts and deduplicates fork numbers, dropping the ones active from
+// genesis.
+func sortForks(forks []uint64) []uint64 {
+	// Sort the fork numbers to permit chronological XOR
 	for i := 0; i < len(forks); i++ {
 		for j := i + 1; j < len(forks); j++ {
 			if forks[i] > forks[j] {
@@ -240,14 +315,14 @@ func gatherForks(config *params.ChainConfig) []uint64 {
 			}
 		}
 	}
-	// Deduplicate block numbers applying multiple forks
+	// Deduplicate numbers applying multiple forks
 	for i := 1; i < len(forks); i++ {
 		if forks[i] == forks[i-1] {
 			forks = append(forks[:i], forks[i+1:]...)
 			i--
 		}
 	}
-	// Skip any forks in block 0, that's the genesis ruleset
+	// Skip any forks at 0, that's the genesis ruleset
 	if len(forks) > 0 && forks[0] == 0 {
 		forks = forks[1:]
 	}
@@ -0,0 +1,82 @@
+package forkid
+
+import (
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+var (
+	testGenesis = common.Hash{0x01}
+	testForks   = forks{blocks: []uint64{100, 200}, expansions: []uint64{2}}
+)
+
+func TestGatherForks(t *testing.T) {
+	// No fork is scheduled, the genesis ruleset applies everywhere
+	if forks := gatherForks(params.TestChainConfig); len(forks.blocks) != 0 || len(forks.expansions) != 0 {
+		t.Errorf("unexpected forks: %v", forks)
+	}
+	if have := sortForks([]uint64{2, 0, 1, 2}); len(have) != 2 || have[0] != 1 || have[1] != 2 {
+		t.Errorf("sorted forks mismatch: have %v, want [1 2]", have)
+	}
+}
+
+func TestID(t *testing.T) {
+	// Passing a block fork or an expansion fork changes the checksum
+	ids := []ID{
+		newID(testForks, testGenesis, 0, 0),
+		newID(testForks, testGenesis, 100, 0),
+		newID(testForks, testGenesis, 0, 2),
+		newID(testForks, testGenesis, 100, 2),
+	}
+	for i := range ids {
+		for j := i + 1; j < len(ids); j++ {
+			if ids[i].Hash == ids[j].Hash {
+				t.Errorf("ids %d and %d share the checksum %x", i, j, ids[i].Hash)
+			}
+		}
+	}
+	if ids[0].Next != 100 || ids[1].Next != 200 {
+		t.Errorf("next fork mismatch: have %d and %d, want 100 and 200", ids[0].Next, ids[1].Next)
+	}
+	// Expansions past the last expansion fork don't change the checksum
+	if id := newID(testForks, testGenesis, 100, 3); id != ids[3] {
+		t.Errorf("id mismatch: have %v, want %v", id, ids[3])
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		head      uint64
+		expansion uint8
+		id        ID
+		err       error
+	}{
+		// Same block and expansion forks passed, connect
+		{150, 2, newID(testForks, testGenesis, 120, 2), nil},
+		{150, 0, newID(testForks, testGenesis, 120, 0), nil},
+		// Remote is syncing the block forks of our expansion, connect
+		{250, 2, newID(testForks, testGenesis, 120, 2), nil},
+		// We are syncing the block forks of our expansion, connect
+		{50, 2, newID(testForks, testGenesis, 250, 2), nil},
+		// Remote announces a fork we have already passed, reject
+		{250, 2, ID{Hash: newID(testForks, testGenesis, 250, 2).Hash, Next: 240}, ErrLocalIncompatibleOrStale},
+		// Remote is syncing, but announces another next fork, reject
+		{250, 2, ID{Hash: newID(testForks, testGenesis, 120, 2).Hash, Next: 190}, ErrRemoteStale},
+		// Remote is still before the expansion fork, connect
+		{150, 2, newID(testForks, testGenesis, 250, 0), nil},
+		// Remote is already past the expansion fork, connect
+		{150, 0, newID(testForks, testGenesis, 50, 2), nil},
+		// Remote is on another chain, reject
+		{150, 2, newID(testForks, common.Hash{0x02}, 150, 2), ErrLocalIncompatibleOrStale},
+		// Remote has passed an expansion fork we don't know of, reject
+		{150, 2, newID(forks{blocks: testForks.blocks, expansions: []uint64{2, 3}}, testGenesis, 150, 3), ErrLocalIncompatibleOrStale},
+	}
+	for i, test := range tests {
+		filter := newFilter(testForks, testGenesis, func() (uint64, uint8) { return test.head, test.expansion })
+		if err := filter(test.id); err != test.err {
+			t.Errorf("test %d: validation error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+}
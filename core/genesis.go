@@ -222,15 +222,49 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, nodeLoca
 	if genesis == nil && stored != params.ProgpowColosseumGenesisHash {
 		return storedcfg, stored, nil
 	}
-	// Check config compatibility and write the config. Compatibility errors
-	// are returned to the caller unless we're already at block zero.
-	height := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadHeaderHash(db))
+	if err := writeCompatibleChainConfig(db, stored, storedcfg, newcfg); err != nil {
+		return newcfg, stored, err
+	}
+	return newcfg, stored, nil
+}
+
+// SetupChainConfig checks the chain config of a slice whose genesis block is
+// set up through the expansion trigger against the config stored for that
+// genesis block, and stores it. Nothing is checked until the slice has a
+// genesis block.
+func SetupChainConfig(db ethdb.Database, genesisHash common.Hash, newcfg *params.ChainConfig, logger *log.Logger) error {
+	if (genesisHash == common.Hash{}) {
+		return nil
+	}
+	storedcfg := rawdb.ReadChainConfig(db, genesisHash)
+	if storedcfg == nil {
+		logger.WithField("hash", genesisHash).Info("Writing chain config of the expansion genesis block")
+		rawdb.WriteChainConfig(db, genesisHash, newcfg)
+		return nil
+	}
+	return writeCompatibleChainConfig(db, genesisHash, storedcfg, newcfg)
+}
+
+// writeCompatibleChainConfig checks the config compatibility and writes the
+// config. Compatibility errors are returned to the caller unless we're
+// already at block zero.
+func writeCompatibleChainConfig(db ethdb.Database, genesisHash common.Hash, storedcfg, newcfg *params.ChainConfig) error {
+	head := rawdb.ReadHeadBlockHash(db)
+	height := rawdb.ReadHeaderNumber(db, head)
 	if height == nil {
-		return newcfg, stored, fmt.Errorf("missing block number for head header hash")
+		return fmt.Errorf("missing block number for head block hash")
+	}
+	var expansion uint8
+	if header := rawdb.ReadHeader(db, head); header != nil {
+		expansion = header.ExpansionNumber()
+	}
+	compatErr := storedcfg.CheckCompatible(newcfg, *height, expansion)
+	if compatErr != nil && *height != 0 {
+		return compatErr
 	}
 
-	rawdb.WriteChainConfig(db, stored, newcfg)
-	return newcfg, stored, nil
+	rawdb.WriteChainConfig(db, genesisHash, newcfg)
+	return nil
 }
 
 func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
@@ -513,7 +547,7 @@ func ReadGenesisQiAlloc(filename string, logger *log.Logger) map[string]GenesisU
 
 // WriteGenesisUtxoSet writes the genesis utxo set to the database
 func AddGenesisUtxos(state *state.StateDB, nodeLocation common.Location, logger *log.Logger) {
-	qiAlloc := ReadGenesisQiAlloc("genallocs/gen_alloc_qi_"+nodeLocation.Name()+".json", logger)
+	qiAlloc := ReadGenesisQiAlloc(genesisQiAllocFile(nodeLocation), logger)
 	// logger.WithField("alloc", len(qiAlloc)).Info("Allocating genesis accounts")
 	for addressString, utxo := range qiAlloc {
 		addr := common.HexToAddress(addressString, nodeLocation)
@@ -539,3 +573,19 @@ func AddGenesisUtxos(state *state.StateDB, nodeLocation common.Location, logger
 		}
 	}
 }
+
+// GenesisUtxoOutpoints returns the outpoints of the genesis Qi allocations of
+// the zone, which AddGenesisUtxos adds to the UTXO set of its first block
+func GenesisUtxoOutpoints(nodeLocation common.Location, logger *log.Logger) []types.OutPoint {
+	qiAlloc := ReadGenesisQiAlloc(genesisQiAllocFile(nodeLocation), logger)
+	outpoints := make([]types.OutPoint, 0, len(qiAlloc))
+	for _, utxo := range qiAlloc {
+		outpoints = append(outpoints, types.OutPoint{TxHash: common.HexToHash(utxo.Hash), Index: uint16(utxo.Index)})
+	}
+	return outpoints
+}
+
+// genesisQiAllocFile returns the path of the genesis Qi allocations of a zone
+func genesisQiAllocFile(nodeLocation common.Location) string {
+	return "genallocs/gen_alloc_qi_" + nodeLocation.Name() + ".json"
+}
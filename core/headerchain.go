@@ -76,6 +76,10 @@ type HeaderChain struct {
 	heads           []*types.WorkObject
 	slicesRunning   []common.Location
 	processingState bool
+	stateSyncing    atomic.Bool // true while the state of a pivot block is downloaded from the peers
+
+	addressIndexRebuilding atomic.Bool // true while the address utxo index is rebuilt in the background
+	addressIndexLegacy     atomic.Bool // true while the lists of the unversioned address utxo index layouts are kept for the rebuild
 
 	logger *log.Logger
 
@@ -127,6 +131,10 @@ func NewHeaderChain(db ethdb.Database, engine consensus.Engine, pEtxsRollupFetch
 
 	// Record if the chain is processing state
 	hc.processingState = hc.setStateProcessing()
+	// Resume an interrupted state sync
+	if rawdb.ReadLastPivotNumber(db) != nil {
+		hc.stateSyncing.Store(true)
+	}
 
 	pendingEtxsRollup, _ := lru.New[common.Hash, types.PendingEtxsRollup](c_maxPendingEtxsRollup)
 	hc.pendingEtxsRollup = pendingEtxsRollup
@@ -147,8 +155,8 @@ func NewHeaderChain(db ethdb.Database, engine consensus.Engine, pEtxsRollupFetch
 	heads := make([]*types.WorkObject, 0)
 	hc.heads = heads
 
-	// Initialize the UTXO cache
-	hc.InitializeAddressUtxoCache()
+	// Remove or rebuild the address utxo index as configured
+	hc.initAddressUtxoIndex()
 
 	return hc, nil
 }
@@ -321,6 +329,41 @@ func (hc *HeaderChain) ProcessingState() bool {
 	return hc.processingState
 }
 
+// StateSyncing reports whether the state is being downloaded from the peers, in
+// which case the appended blocks are not processed
+func (hc *HeaderChain) StateSyncing() bool {
+	return hc.stateSyncing.Load()
+}
+
+// StartStateSync stops processing the appended blocks until the state of a
+// pivot block has been downloaded
+func (hc *HeaderChain) StartStateSync() {
+	hc.stateSyncing.Store(true)
+}
+
+// SetStateSyncPivot records the block whose state is being downloaded, so that
+// an interrupted state sync is resumed after a restart
+func (hc *HeaderChain) SetStateSyncPivot(pivot *types.WorkObject) {
+	rawdb.WriteLastPivotNumber(hc.headerDb, pivot.NumberU64(hc.NodeCtx()))
+}
+
+// CompleteStateSync marks the downloaded state of the pivot block as processed
+// and processes the blocks appended on top of it since the sync started. The
+// chain is left state syncing if the blocks fail to process, so that the sync
+// is retried.
+func (hc *HeaderChain) CompleteStateSync(pivot *types.WorkObject) error {
+	rawdb.WriteProcessedState(hc.headerDb, pivot.Hash())
+	hc.stateSyncing.Store(false)
+	if hc.CurrentHeader().Hash() != pivot.Hash() {
+		if err := hc.SetCurrentState(hc.CurrentHeader()); err != nil {
+			hc.stateSyncing.Store(true)
+			return err
+		}
+	}
+	rawdb.DeleteLastPivotNumber(hc.headerDb)
+	return nil
+}
+
 func (hc *HeaderChain) setStateProcessing() bool {
 	nodeCtx := hc.NodeCtx()
 	for _, slice := range hc.slicesRunning {
@@ -368,6 +411,7 @@ func (hc *HeaderChain) SetCurrentHeader(head *types.WorkObject) error {
 	if prevHeader.Hash() == head.Hash() {
 		return nil
 	}
+	defer hc.updateAddressUtxoIndex()
 
 	// write the head block hash to the db
 	rawdb.WriteHeadBlockHash(hc.headerDb, head.Hash())
@@ -458,9 +502,10 @@ func (hc *HeaderChain) SetCurrentState(head *types.WorkObject) error {
 	defer hc.headermu.Unlock()
 
 	nodeCtx := hc.NodeCtx()
-	if nodeCtx != common.ZONE_CTX || !hc.ProcessingState() {
+	if nodeCtx != common.ZONE_CTX || !hc.ProcessingState() || hc.StateSyncing() {
 		return nil
 	}
+	defer hc.updateAddressUtxoIndex()
 
 	current := types.CopyWorkObject(head)
 	var headersWithoutState []*types.WorkObject
@@ -863,14 +908,15 @@ func (hc *HeaderChain) CalculateBaseFee(header *types.WorkObject) *big.Int {
 	return misc.CalcBaseFee(hc.Config(), header)
 }
 
-// Export writes the active chain to the given writer.
-func (hc *HeaderChain) Export(w io.Writer) error {
-	return hc.ExportN(w, uint64(0), hc.CurrentHeader().NumberU64(hc.NodeCtx()))
+// Export writes the active chain to the given writer in the given format.
+func (hc *HeaderChain) Export(w io.Writer, format string) error {
+	return hc.ExportN(w, uint64(0), hc.CurrentHeader().NumberU64(hc.NodeCtx()), format)
 }
 
-// ExportN writes a subset of the active chain to the given writer.
-func (hc *HeaderChain) ExportN(w io.Writer, first uint64, last uint64) error {
-	return nil
+// ExportN writes a subset of the active chain to the given writer in the given
+// format.
+func (hc *HeaderChain) ExportN(w io.Writer, first uint64, last uint64, format string) error {
+	return ExportBlocks(hc.headerDb, w, first, last, format, hc.logger)
 }
 
 // GetBlockFromCacheOrDb looks up the body cache first and then checks the db
@@ -1006,65 +1052,6 @@ func (hc *HeaderChain) SlicesRunning() []common.Location {
 	return hc.slicesRunning
 }
 
-// InitializeAddressUtxoCache initializes the address utxo cache.
-// It is important to manage this set since nodes can enable / disable
-// address indexing between start ups. When a node disables address utxo
-// indexing, prior entries in the table will need to be removed.
-func (hc *HeaderChain) InitializeAddressUtxoCache() error {
-
-	start := time.Now()
-
-	it := hc.bc.db.NewIterator(rawdb.AddressUtxosPrefix, nil)
-	defer it.Release()
-
-	utxoCount := 0
-	for it.Next() {
-		utxoCount += 1
-		// If we are no longer running with address utxo indexing,
-		// delete all prior entries
-		if !hc.indexerConfig.IndexAddressUtxos {
-			err := hc.bc.db.Delete(it.Key())
-			if err != nil {
-				return err
-			}
-		} else {
-			break
-		}
-	}
-
-	// If we are no longer running with address utxo indexing,
-	// delete all prior entries and compact the table
-	if !hc.indexerConfig.IndexAddressUtxos {
-		end := common.CopyBytes(rawdb.AddressUtxosPrefix)
-		end[len(end)-1]++
-
-		hc.bc.db.Compact(rawdb.AddressUtxosPrefix, end)
-		return nil
-	}
-
-	// If there are utxos in the database, then we need to index them
-	// into the address utxo table
-	if utxoCount == 0 {
-		it := hc.bc.db.NewIterator([]byte("ut"), nil)
-		defer it.Release()
-
-		for it.Next() {
-			data := it.Value()
-			utxo := new(types.UtxoEntry)
-			if err := rlp.Decode(bytes.NewReader(data), utxo); err != nil {
-				return nil
-			}
-			address := common.BytesToAddress(utxo.Address, hc.NodeLocation())
-			addressUtxos := rawdb.ReadAddressUtxos(hc.bc.db, address)
-			addressUtxos = append(addressUtxos, utxo)
-			rawdb.WriteAddressUtxos(hc.bc.db, address, addressUtxos)
-		}
-	}
-
-	hc.logger.Info("Finished initializing address utxo cache", "duration", time.Since(start))
-	return nil
-}
-
 // ComputeEfficiencyScore calculates the efficiency score for the given header
 func (hc *HeaderChain) ComputeEfficiencyScore(parent *types.WorkObject) uint16 {
 	deltaS := new(big.Int).Add(parent.ParentDeltaS(common.REGION_CTX), parent.ParentDeltaS(common.ZONE_CTX))
@@ -28,16 +28,19 @@ import (
 	"github.com/dominant-strategies/go-quai/ethdb"
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/params"
-	"github.com/dominant-strategies/go-quai/rlp"
 	"google.golang.org/protobuf/proto"
 )
 
 // ReadCanonicalHash retrieves the hash assigned to a canonical block number.
 func ReadCanonicalHash(db ethdb.Reader, number uint64) common.Hash {
 	data, _ := db.Get(headerHashKey(number))
-
 	if len(data) == 0 {
-		return common.Hash{}
+		// The canonical mapping of the frozen blocks is only kept in the
+		// ancient store
+		data, _ = db.Ancient(freezerHashTable, number)
+		if len(data) == 0 {
+			return common.Hash{}
+		}
 	}
 	return common.BytesToHash(data)
 }
@@ -178,6 +181,32 @@ func WriteHeadBlockHash(db ethdb.KeyValueWriter, hash common.Hash) {
 	}
 }
 
+// ReadLastImportedBlockHash retrieves the hash of the last block stored by an
+// offline chain import.
+func ReadLastImportedBlockHash(db ethdb.KeyValueReader) common.Hash {
+	data, _ := db.Get(lastImportedBlockKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteLastImportedBlockHash stores the hash of the last block stored by an
+// offline chain import.
+func WriteLastImportedBlockHash(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Put(lastImportedBlockKey, hash.Bytes()); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to store last imported block's hash")
+	}
+}
+
+// DeleteLastImportedBlockHash deletes the hash of the last block stored by an
+// offline chain import.
+func DeleteLastImportedBlockHash(db ethdb.KeyValueWriter) {
+	if err := db.Delete(lastImportedBlockKey); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to delete last imported block's hash")
+	}
+}
+
 // ReadLastPivotNumber retrieves the number of the last pivot block. If the node
 // full synced, the last pivot will always be nil.
 func ReadLastPivotNumber(db ethdb.KeyValueReader) *uint64 {
@@ -206,6 +235,14 @@ func WriteLastPivotNumber(db ethdb.KeyValueWriter, pivot uint64) {
 	}
 }
 
+// DeleteLastPivotNumber removes the number of the last pivot block once the
+// state sync is complete.
+func DeleteLastPivotNumber(db ethdb.KeyValueWriter) {
+	if err := db.Delete(lastPivotKey); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to delete pivot block number")
+	}
+}
+
 // ReadFastTrieProgress retrieves the number of tries nodes fast synced to allow
 // reporting correct numbers across restarts.
 func ReadFastTrieProgress(db ethdb.KeyValueReader) uint64 {
@@ -563,18 +600,45 @@ func DeleteTermini(db ethdb.KeyValueWriter, hash common.Hash) {
 	}
 }
 
+// readAncientBlockData retrieves the data of the given kind belonging to a block
+// from the ancient store. Extra hash comparison is necessary since the ancient
+// store only maintains the canonical data.
+func readAncientBlockData(db ethdb.Reader, kind string, hash common.Hash) []byte {
+	number := ReadHeaderNumber(db, hash)
+	if number == nil {
+		return nil
+	}
+	if h, err := db.Ancient(freezerHashTable, *number); err != nil || common.BytesToHash(h) != hash {
+		return nil
+	}
+	data, _ := db.Ancient(kind, *number)
+	return data
+}
+
+// ReadWorkObjectHeaderProto retrieves the work object header of a block in its
+// raw proto database encoding.
+func ReadWorkObjectHeaderProto(db ethdb.Reader, hash common.Hash) []byte {
+	data, _ := db.Get(blockWorkObjectHeaderKey(hash))
+	if len(data) > 0 {
+		return data
+	}
+	// The freezer moves the data of the block into the ancient store before
+	// deleting it from the key-value store, so it has to be in there if the
+	// block was frozen.
+	return readAncientBlockData(db, freezerHeaderTable, hash)
+}
+
 // ReadWorkObjectHeader retreive's the work object header stored in hash.
 func ReadWorkObjectHeader(db ethdb.Reader, hash common.Hash, woType types.WorkObjectView) *types.WorkObjectHeader {
-	var key []byte
+	var data []byte
 	switch woType {
 	case types.BlockObject:
-		key = blockWorkObjectHeaderKey(hash)
+		data = ReadWorkObjectHeaderProto(db, hash)
 	case types.TxObject:
-		key = txWorkObjectHeaderKey(hash)
+		data, _ = db.Get(txWorkObjectHeaderKey(hash))
 	case types.PhObject:
-		key = phWorkObjectHeaderKey(hash)
+		data, _ = db.Get(phWorkObjectHeaderKey(hash))
 	}
-	data, _ := db.Get(key)
 	if len(data) == 0 {
 		return nil
 	}
@@ -683,10 +747,19 @@ func DeleteBlockWithoutNumber(db ethdb.KeyValueWriter, hash common.Hash, number
 	deleteHeaderWithoutNumber(db, hash, number)
 }
 
+// ReadWorkObjectBodyProto retrieves the work object body stored in hash in its
+// raw proto database encoding.
+func ReadWorkObjectBodyProto(db ethdb.Reader, hash common.Hash) []byte {
+	data, _ := db.Get(workObjectBodyKey(hash))
+	if len(data) > 0 {
+		return data
+	}
+	return readAncientBlockData(db, freezerBodiesTable, hash)
+}
+
 // ReadWorkObjectBody retreive's the work object body stored in hash.
 func ReadWorkObjectBody(db ethdb.Reader, hash common.Hash) *types.WorkObjectBody {
-	key := workObjectBodyKey(hash)
-	data, _ := db.Get(key)
+	data := ReadWorkObjectBodyProto(db, hash)
 	if len(data) == 0 {
 		return nil
 	}
@@ -709,8 +782,7 @@ func ReadWorkObjectBody(db ethdb.Reader, hash common.Hash) *types.WorkObjectBody
 }
 
 func ReadWorkObjectBodyHeaderOnly(db ethdb.Reader, hash common.Hash) *types.WorkObjectBody {
-	key := workObjectBodyKey(hash)
-	data, _ := db.Get(key)
+	data := ReadWorkObjectBodyProto(db, hash)
 	if len(data) == 0 {
 		return nil
 	}
@@ -1600,36 +1672,6 @@ func DeleteInboundEtxs(db ethdb.KeyValueWriter, hash common.Hash) {
 	}
 }
 
-func WriteAddressUtxos(db ethdb.KeyValueWriter, address common.Address, utxos []*types.UtxoEntry) {
-	data, err := rlp.EncodeToBytes(utxos)
-	if err != nil {
-		db.Logger().WithField("err", err).Fatal("Failed to rlp encode utxos")
-	}
-	if err := db.Put(addressUtxosKey(address), data); err != nil {
-		db.Logger().WithField("err", err).Fatal("Failed to store utxos")
-	}
-}
-
-func ReadAddressUtxos(db ethdb.Reader, address common.Address) []*types.UtxoEntry {
-	// Try to look up the data in leveldb.
-	data, _ := db.Get(addressUtxosKey(address))
-	if len(data) == 0 {
-		return nil
-	}
-	utxos := []*types.UtxoEntry{}
-	if err := rlp.Decode(bytes.NewReader(data), &utxos); err != nil {
-		db.Logger().WithField("err", err).Error("Invalid utxos RLP")
-		return nil
-	}
-	return utxos
-}
-
-func DeleteAddressUtxos(db ethdb.KeyValueWriter, address common.Address) {
-	if err := db.Delete(addressUtxosKey(address)); err != nil {
-		db.Logger().WithField("err", err).Fatal("Failed to delete utxos")
-	}
-}
-
 func WriteGenesisHashes(db ethdb.KeyValueWriter, hashes common.Hashes) {
 	protoHashes := hashes.ProtoEncode()
 	data, err := proto.Marshal(protoHashes)
@@ -7,38 +7,41 @@ import (
 
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/log"
 )
 
 // Tests block header storage and retrieval operations.
 func TestHeaderStorage(t *testing.T) {
-	db := NewMemoryDatabase()
+	db := NewMemoryDatabase(log.Global)
 
 	// Create a test header to move around the database and make sure it's really new
-	header := types.EmptyHeader(2)
-	header.SetParentHash(common.Hash{1}, common.ZONE_CTX)
+	header := types.EmptyHeader(2).Header()
+	header.SetParentHash(common.Hash{1}, common.REGION_CTX)
 	header.SetBaseFee(big.NewInt(1))
+	hash, number := header.Hash(), header.NumberU64(common.REGION_CTX)
 
-	if entry := ReadHeader(db, header.Hash(), common.ZONE_CTX); entry != nil {
-		t.Fatalf("Non existent header returned: %v", entry)
+	if HasHeader(db, hash, number) {
+		t.Fatalf("Non existent header returned: %v", hash)
 	}
-	t.Log("Header Hash stored", header.Hash())
+	t.Log("Header Hash stored", hash)
 	// Write and verify the header in the database
-	WriteHeader(db, header.Header(), common.ZONE_CTX)
-	if entry := ReadHeader(db, header.Hash(), header.Number(common.ZONE_CTX).Uint64()); entry == nil {
-		t.Fatalf("Stored header not found with hash %s", entry.Hash())
-	} else if entry.Hash() != header.Hash() {
-		t.Fatalf("Retrieved header mismatch: have %v, want %v", entry, header)
+	WriteHeader(db, header, common.REGION_CTX)
+	if entry := ReadHeaderNumber(db, hash); entry == nil || *entry != number {
+		t.Fatalf("Stored header number mismatch: have %v, want %d", entry, number)
+	}
+	if entry := ReadHeaderProto(db, hash, number); len(entry) == 0 {
+		t.Fatalf("Stored header not found with hash %s", hash)
 	}
 	// Delete the header and verify the execution
-	DeleteHeader(db, header.Hash(), header.Number(common.ZONE_CTX).Uint64())
-	if entry := ReadHeader(db, header.Hash(), header.Number(common.ZONE_CTX).Uint64()); entry != nil {
-		t.Fatalf("Deleted header returned: %v", entry)
+	DeleteHeader(db, hash, number)
+	if HasHeader(db, hash, number) || ReadHeaderNumber(db, hash) != nil {
+		t.Fatalf("Deleted header returned: %v", hash)
 	}
 }
 
 // Tests termini storage and retrieval operations.
 func TestTerminiStorage(t *testing.T) {
-	db := NewMemoryDatabase()
+	db := NewMemoryDatabase(log.Global)
 
 	// Create a test termini to move around the database and make sure it's really new
 	termini := types.EmptyTermini()
@@ -62,7 +65,7 @@ func TestTerminiStorage(t *testing.T) {
 
 // Tests inbound etx storage and retrieval operations.
 func TestInboundEtxsStorage(t *testing.T) {
-	db := NewMemoryDatabase()
+	db := NewMemoryDatabase(log.Global)
 	hash := common.Hash{1}
 
 	to := common.BytesToAddress([]byte{0x01}, common.Location{0, 0})
@@ -104,14 +107,14 @@ func TestInboundEtxsStorage(t *testing.T) {
 
 // Tests block header storage and retrieval operations.
 func TestWorkObjectStorage(t *testing.T) {
-	db := NewMemoryDatabase()
+	db := NewMemoryDatabase(log.Global)
 
 	// Create a test header to move around the database and make sure it's really new
 	woBody := &types.WorkObjectBody{}
 	woBody.SetTransactions([]*types.Transaction{})
 	woBody.SetExtTransactions([]*types.Transaction{})
 	woBody.SetHeader(types.EmptyHeader(2).Header())
-	header := types.NewWorkObject(types.NewWorkObjectHeader(types.EmptyRootHash, types.EmptyRootHash, big.NewInt(11), big.NewInt(30000), types.EmptyRootHash, types.BlockNonce{23}, common.LocationFromAddressBytes([]byte{0x01, 0x01})), woBody, nil)
+	header := types.NewWorkObject(types.NewWorkObjectHeader(types.EmptyRootHash, types.EmptyRootHash, big.NewInt(11), big.NewInt(30000), types.EmptyRootHash, types.BlockNonce{23}, 0, common.LocationFromAddressBytes([]byte{0x01, 0x01})), woBody, nil)
 
 	if entry := ReadWorkObject(db, header.Hash(), types.BlockObject); entry != nil {
 		t.Fatalf("Non existent header returned: %v", entry)
@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"bytes"
+	"encoding/binary"
 	"math/big"
 
 	"github.com/dominant-strategies/go-quai/common"
@@ -25,6 +26,7 @@ import (
 	"github.com/dominant-strategies/go-quai/ethdb"
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/params"
+	"github.com/dominant-strategies/go-quai/rlp"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -193,3 +195,442 @@ func DeleteBloombits(db ethdb.Database, bit uint, from uint64, to uint64) {
 		db.Logger().WithField("err", it.Error()).Fatal("Failed to delete bloom bits")
 	}
 }
+
+// AddressUtxoIndexVersion is the layout of the address utxo index entries. The
+// first index stored the utxos of an address as a single RLP list under the
+// address, without any version, and was later changed to a list of outpoints.
+// Both lists are still readable with ReadLegacyAddressUtxos. Version 1 stores
+// an entry per outpoint.
+const AddressUtxoIndexVersion = 1
+
+// addressUtxoValue is the value stored for an output in the address utxo index.
+type addressUtxoValue struct {
+	Denomination uint8
+	Lock         *big.Int
+}
+
+// AddressUtxoChange is an output created or spent by a block, as recorded to
+// update the address utxo index and to revert the update on a reorg.
+type AddressUtxoChange struct {
+	Address      []byte
+	TxHash       common.Hash
+	Index        uint16
+	Denomination uint8
+	Lock         *big.Int
+}
+
+// AddressUtxoUndo holds the outputs created and spent by a block.
+type AddressUtxoUndo struct {
+	Created []*AddressUtxoChange
+	Spent   []*AddressUtxoChange
+}
+
+// AddressUtxoIndexProgress is the position of an interrupted rebuild of the
+// address utxo index. Target is the block whose UTXO set is being indexed and
+// Next is the number of the next block scanned for the outputs it created.
+type AddressUtxoIndexProgress struct {
+	Target common.Hash
+	Next   uint64
+}
+
+// WriteAddressUtxo adds an unspent output to the index of its address.
+func WriteAddressUtxo(db ethdb.KeyValueWriter, address common.Address, outpoint *types.OutpointAndDenomination) {
+	data, err := rlp.EncodeToBytes(&addressUtxoValue{Denomination: outpoint.Denomination, Lock: outpoint.Lock})
+	if err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to rlp encode address utxo")
+	}
+	if err := db.Put(addressUtxoKey(address, outpoint.TxHash, outpoint.Index), data); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to store address utxo")
+	}
+}
+
+// DeleteAddressUtxo removes an output from the index of its address.
+func DeleteAddressUtxo(db ethdb.KeyValueWriter, address common.Address, txHash common.Hash, index uint16) {
+	if err := db.Delete(addressUtxoKey(address, txHash, index)); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to delete address utxo")
+	}
+}
+
+// ReadAddressOutpoints retrieves the unspent outpoints indexed for an address,
+// ordered by outpoint.
+func ReadAddressOutpoints(db ethdb.Database, address common.Address) []*types.OutpointAndDenomination {
+	prefix := addressUtxosKey(address)
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var outpoints []*types.OutpointAndDenomination
+	for it.Next() {
+		key := it.Key()[len(prefix):]
+		if len(key) != common.HashLength+2 {
+			continue
+		}
+		value := new(addressUtxoValue)
+		if err := rlp.DecodeBytes(it.Value(), value); err != nil {
+			db.Logger().WithFields(log.Fields{
+				"address": address,
+				"err":     err,
+			}).Error("Invalid address utxo RLP")
+			continue
+		}
+		outpoints = append(outpoints, &types.OutpointAndDenomination{
+			TxHash:       common.BytesToHash(key[:common.HashLength]),
+			Index:        binary.BigEndian.Uint16(key[common.HashLength:]),
+			Denomination: value.Denomination,
+			Lock:         value.Lock,
+		})
+	}
+	return outpoints
+}
+
+// ReadAddressUtxos retrieves the unspent outputs indexed for an address.
+func ReadAddressUtxos(db ethdb.Database, address common.Address) []*types.UtxoEntry {
+	outpoints := ReadAddressOutpoints(db, address)
+	if outpoints == nil {
+		return nil
+	}
+	utxos := make([]*types.UtxoEntry, 0, len(outpoints))
+	for _, outpoint := range outpoints {
+		utxos = append(utxos, types.NewUtxoEntry(types.NewTxOut(outpoint.Denomination, address.Bytes(), outpoint.Lock)))
+	}
+	return utxos
+}
+
+// ReadLegacyAddressUtxos retrieves the utxos of an address stored by the
+// unversioned layouts of the index as a single RLP list under the address. The
+// list holds the outpoints of the address, or its utxos without the outpoints
+// in the first layout. Nothing is returned if the address has no such list.
+func ReadLegacyAddressUtxos(db ethdb.KeyValueReader, address common.Address) ([]*types.OutpointAndDenomination, []*types.UtxoEntry) {
+	data, _ := db.Get(addressUtxosKey(address))
+	if len(data) == 0 {
+		return nil, nil
+	}
+	outpoints := []*types.OutpointAndDenomination{}
+	if err := rlp.DecodeBytes(data, &outpoints); err == nil {
+		return outpoints, nil
+	}
+	utxos := []*types.UtxoEntry{}
+	if err := rlp.DecodeBytes(data, &utxos); err != nil {
+		db.Logger().WithFields(log.Fields{
+			"address": address,
+			"err":     err,
+		}).Error("Invalid legacy address utxos RLP")
+		return nil, nil
+	}
+	return nil, utxos
+}
+
+// HasLegacyAddressUtxos reports whether the address utxo index holds lists of
+// the unversioned layouts.
+func HasLegacyAddressUtxos(db ethdb.Iteratee) bool {
+	it := db.NewIterator(AddressUtxosPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		if isLegacyAddressUtxosKey(it.Key()) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteLegacyAddressUtxos removes the lists of the unversioned layouts from
+// the address utxo index.
+func DeleteLegacyAddressUtxos(db ethdb.Database) {
+	it := db.NewIterator(AddressUtxosPrefix, nil)
+	defer it.Release()
+
+	batch := db.NewBatch()
+	for it.Next() {
+		if !isLegacyAddressUtxosKey(it.Key()) {
+			continue
+		}
+		batch.Delete(it.Key())
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				db.Logger().WithField("err", err).Fatal("Failed to delete legacy address utxos")
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to delete legacy address utxos")
+	}
+}
+
+// ReadAddressUtxoUndo retrieves the outputs created and spent by a block, as
+// recorded for the address utxo index.
+func ReadAddressUtxoUndo(db ethdb.KeyValueReader, hash common.Hash) *AddressUtxoUndo {
+	data, _ := db.Get(addressUtxoUndoKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+	undo := new(AddressUtxoUndo)
+	if err := rlp.DecodeBytes(data, undo); err != nil {
+		db.Logger().WithFields(log.Fields{
+			"hash": hash,
+			"err":  err,
+		}).Error("Invalid address utxo undo RLP")
+		return nil
+	}
+	return undo
+}
+
+// WriteAddressUtxoUndo stores the outputs created and spent by a block.
+func WriteAddressUtxoUndo(db ethdb.KeyValueWriter, hash common.Hash, undo *AddressUtxoUndo) {
+	data, err := rlp.EncodeToBytes(undo)
+	if err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to rlp encode address utxo undo")
+	}
+	if err := db.Put(addressUtxoUndoKey(hash), data); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to store address utxo undo")
+	}
+}
+
+// DeleteAddressUtxoUndo removes the outputs created and spent by a block.
+func DeleteAddressUtxoUndo(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Delete(addressUtxoUndoKey(hash)); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to delete address utxo undo")
+	}
+}
+
+// ReadAddressUtxoIndexHead retrieves the hash of the block the address utxo
+// index reflects.
+func ReadAddressUtxoIndexHead(db ethdb.KeyValueReader) common.Hash {
+	data, _ := db.Get(addressUtxoIndexHeadKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteAddressUtxoIndexHead stores the hash of the block the address utxo
+// index reflects.
+func WriteAddressUtxoIndexHead(db ethdb.KeyValueWriter, hash common.Hash) {
+	if err := db.Put(addressUtxoIndexHeadKey, hash.Bytes()); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to store address utxo index head")
+	}
+}
+
+// DeleteAddressUtxoIndexHead removes the head of the address utxo index.
+func DeleteAddressUtxoIndexHead(db ethdb.KeyValueWriter) {
+	if err := db.Delete(addressUtxoIndexHeadKey); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to delete address utxo index head")
+	}
+}
+
+// ReadAddressUtxoIndexProgress retrieves the position of an interrupted
+// address utxo index rebuild.
+func ReadAddressUtxoIndexProgress(db ethdb.KeyValueReader) *AddressUtxoIndexProgress {
+	data, _ := db.Get(addressUtxoIndexProgressKey)
+	if len(data) == 0 {
+		return nil
+	}
+	progress := new(AddressUtxoIndexProgress)
+	if err := rlp.DecodeBytes(data, progress); err != nil {
+		db.Logger().WithField("err", err).Error("Invalid address utxo index progress RLP")
+		return nil
+	}
+	return progress
+}
+
+// WriteAddressUtxoIndexProgress stores the position of an address utxo index
+// rebuild.
+func WriteAddressUtxoIndexProgress(db ethdb.KeyValueWriter, progress *AddressUtxoIndexProgress) {
+	data, err := rlp.EncodeToBytes(progress)
+	if err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to rlp encode address utxo index progress")
+	}
+	if err := db.Put(addressUtxoIndexProgressKey, data); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to store address utxo index progress")
+	}
+}
+
+// DeleteAddressUtxoIndexProgress removes the position of an address utxo
+// index rebuild.
+func DeleteAddressUtxoIndexProgress(db ethdb.KeyValueWriter) {
+	if err := db.Delete(addressUtxoIndexProgressKey); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to delete address utxo index progress")
+	}
+}
+
+// ReadAddressUtxoIndexVersion retrieves the layout version of the address utxo
+// index, 0 if the index predates the versioning.
+func ReadAddressUtxoIndexVersion(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get(addressUtxoIndexVersionKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteAddressUtxoIndexVersion stores the layout version of the address utxo
+// index.
+func WriteAddressUtxoIndexVersion(db ethdb.KeyValueWriter, version uint64) {
+	if err := db.Put(addressUtxoIndexVersionKey, encodeBlockNumber(version)); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to store address utxo index version")
+	}
+}
+
+// DeleteAddressUtxoIndexVersion removes the layout version of the address utxo
+// index.
+func DeleteAddressUtxoIndexVersion(db ethdb.KeyValueWriter) {
+	if err := db.Delete(addressUtxoIndexVersionKey); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to delete address utxo index version")
+	}
+}
+
+// DeleteAddressUtxoIndex removes the address utxo index, its undo data and
+// its metadata. The lists of the unversioned layouts are kept, see
+// DeleteLegacyAddressUtxos.
+func DeleteAddressUtxoIndex(db ethdb.Database) {
+	for _, prefix := range [][]byte{AddressUtxosPrefix, addressUtxoUndoPrefix} {
+		it := db.NewIterator(prefix, nil)
+		batch := db.NewBatch()
+		for it.Next() {
+			if isLegacyAddressUtxosKey(it.Key()) {
+				continue
+			}
+			batch.Delete(it.Key())
+			if batch.ValueSize() >= ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					db.Logger().WithField("err", err).Fatal("Failed to delete address utxo index")
+				}
+				batch.Reset()
+			}
+		}
+		it.Release()
+		if err := batch.Write(); err != nil {
+			db.Logger().WithField("err", err).Fatal("Failed to delete address utxo index")
+		}
+	}
+	DeleteAddressUtxoIndexHead(db)
+	DeleteAddressUtxoIndexProgress(db)
+}
+
+// Flags of an address history entry, telling how the transaction touched the
+// address.
+const (
+	AddressHistorySent     uint8 = 1 << iota // the address paid for the transaction or spent an output in it
+	AddressHistoryReceived                   // the address is a recipient of the transaction
+	AddressHistoryCoinbase                   // the transaction is the coinbase of the block
+	AddressHistoryEtx                        // the transaction is an external transaction
+)
+
+// AddressHistoryPositionLength is the length of the position of an address
+// history entry, the block number followed by the transaction index.
+const AddressHistoryPositionLength = 8 + 4
+
+// AddressHistoryEntry is a canonical transaction that touched an address.
+type AddressHistoryEntry struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	TxIndex     uint32
+	TxHash      common.Hash
+	Flags       uint8
+}
+
+// Position returns the position of the entry in the history of its address.
+func (entry *AddressHistoryEntry) Position() []byte {
+	return AddressHistoryPosition(entry.BlockNumber, entry.TxIndex)
+}
+
+// addressHistoryValue is the value stored for an address history entry.
+type addressHistoryValue struct {
+	BlockHash common.Hash
+	TxHash    common.Hash
+	Flags     uint8
+}
+
+// AddressHistoryPosition returns the position of a transaction in the history
+// of an address, the big endian block number followed by the big endian
+// transaction index.
+func AddressHistoryPosition(number uint64, txIndex uint32) []byte {
+	position := make([]byte, AddressHistoryPositionLength)
+	binary.BigEndian.PutUint64(position, number)
+	binary.BigEndian.PutUint32(position[8:], txIndex)
+	return position
+}
+
+// WriteAddressHistoryEntry adds a transaction to the history of an address.
+func WriteAddressHistoryEntry(db ethdb.KeyValueWriter, address common.Address, entry *AddressHistoryEntry) {
+	data, err := rlp.EncodeToBytes(&addressHistoryValue{BlockHash: entry.BlockHash, TxHash: entry.TxHash, Flags: entry.Flags})
+	if err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to rlp encode address history entry")
+	}
+	if err := db.Put(addressHistoryKey(address, entry.BlockNumber, entry.TxIndex), data); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to store address history entry")
+	}
+}
+
+// ReadAddressHistory retrieves up to limit entries of the history of an
+// address, starting at the given position and up to the given block number.
+// The entries are ordered by position and may include transactions of blocks
+// that were reorged out since they were indexed.
+func ReadAddressHistory(db ethdb.Database, address common.Address, start []byte, end uint64, limit int) []*AddressHistoryEntry {
+	prefix := addressHistoryKey(address, 0, 0)[:len(addressHistoryPrefix)+common.AddressLength]
+	it := db.NewIterator(prefix, start)
+	defer it.Release()
+
+	var entries []*AddressHistoryEntry
+	for len(entries) < limit && it.Next() {
+		position := it.Key()[len(prefix):]
+		if len(position) != AddressHistoryPositionLength {
+			continue
+		}
+		number := binary.BigEndian.Uint64(position)
+		if number > end {
+			break
+		}
+		value := new(addressHistoryValue)
+		if err := rlp.DecodeBytes(it.Value(), value); err != nil {
+			db.Logger().WithFields(log.Fields{
+				"address": address,
+				"err":     err,
+			}).Error("Invalid address history entry RLP")
+			continue
+		}
+		entries = append(entries, &AddressHistoryEntry{
+			BlockNumber: number,
+			BlockHash:   value.BlockHash,
+			TxIndex:     binary.BigEndian.Uint32(position[8:]),
+			TxHash:      value.TxHash,
+			Flags:       value.Flags,
+		})
+	}
+	return entries
+}
+
+// EtxLifecycleEntry is a block at which an ETX crossed a slice: the region
+// block rolling it up, the prime block including it, or the destination zone
+// block delivering it to the ETX set.
+type EtxLifecycleEntry struct {
+	BlockHash   common.Hash
+	BlockNumber uint64
+}
+
+// WriteEtxLifecycleEntry records that an ETX crossed the slice at the given block.
+func WriteEtxLifecycleEntry(db ethdb.KeyValueWriter, etxHash common.Hash, blockHash common.Hash, number uint64) {
+	if err := db.Put(etxLifecycleKey(etxHash, blockHash), encodeBlockNumber(number)); err != nil {
+		db.Logger().WithField("err", err).Fatal("Failed to store etx lifecycle entry")
+	}
+}
+
+// ReadEtxLifecycleEntries retrieves all the blocks at which an ETX crossed the
+// slice. Blocks of every fork the ETX was seen on are returned.
+func ReadEtxLifecycleEntries(db ethdb.Iteratee, etxHash common.Hash) []EtxLifecycleEntry {
+	prefix := etxLifecycleKey(etxHash, common.Hash{})[:len(etxLifecyclePrefix)+common.HashLength]
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var entries []EtxLifecycleEntry
+	for it.Next() {
+		if len(it.Key()) != len(prefix)+common.HashLength || len(it.Value()) != 8 {
+			continue
+		}
+		entries = append(entries, EtxLifecycleEntry{
+			BlockHash:   common.BytesToHash(it.Key()[len(prefix):]),
+			BlockNumber: binary.BigEndian.Uint64(it.Value()),
+		})
+	}
+	return entries
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 	"sync/atomic"
 	"time"
 
@@ -125,10 +126,11 @@ func NewDatabase(db ethdb.KeyValueStore) ethdb.Database {
 
 // NewDatabaseWithFreezer creates a high level database on top of a given key-
 // value data store with a freezer moving immutable chain segments into cold
-// storage.
-func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace string, readonly bool, nodeCtx int, logger *log.Logger, location common.Location) (ethdb.Database, error) {
+// storage. The canonical blocks more than threshold blocks below the prime
+// terminus of the head are moved into the freezer.
+func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace string, readonly bool, threshold uint64, nodeCtx int, logger *log.Logger, location common.Location) (ethdb.Database, error) {
 	// Create the idle freezer instance
-	frdb, err := newFreezer(freezer, namespace, readonly, logger)
+	frdb, err := newFreezer(freezer, namespace, readonly, threshold, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -196,6 +198,22 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace st
 			// feezer.
 		}
 	}
+	// Freezer is consistent with the key-value database, permit combining the two
+	if !frdb.readonly {
+		frdb.wg.Add(1)
+		go func() {
+			defer frdb.wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logger.WithFields(log.Fields{
+						"error":      r,
+						"stacktrace": string(debug.Stack()),
+					}).Fatal("Go-Quai Panicked")
+				}
+			}()
+			frdb.freeze(db, nodeCtx)
+		}()
+	}
 	return &freezerdb{
 		KeyValueStore: db,
 		AncientStore:  frdb,
@@ -231,10 +249,10 @@ const (
 	dbLeveldb = "leveldb"
 )
 
-// hasPreexistingDb checks the given data directory whether a database is already
-// instantiated at that location, and if so, returns the type of database (or the
-// empty string).
-func hasPreexistingDb(path string) string {
+// PreexistingDatabase checks the given data directory whether a database is
+// already instantiated at that location, and if so, returns the type of database
+// (or the empty string).
+func PreexistingDatabase(path string) string {
 	if _, err := os.Stat(filepath.Join(path, "CURRENT")); err != nil {
 		return "" // No pre-existing db
 	}
@@ -256,6 +274,7 @@ type OpenOptions struct {
 	Namespace         string // the namespace for database relevant metrics
 	Cache             int    // the capacity(in megabytes) of the data caching
 	Handles           int    // number of files to be open simultaneously
+	AncientsThreshold uint64 // the number of blocks below the prime terminus kept out of the ancients
 	ReadOnly          bool
 }
 
@@ -266,7 +285,7 @@ type OpenOptions struct {
 //	db is non-existent |  leveldb default  |  specified type
 //	db is existent     |  from db          |  specified type (if compatible)
 func openKeyValueDatabase(o OpenOptions, logger *log.Logger, location common.Location) (ethdb.Database, error) {
-	existingDb := hasPreexistingDb(o.Directory)
+	existingDb := PreexistingDatabase(o.Directory)
 	if len(existingDb) != 0 && len(o.Type) != 0 && o.Type != existingDb {
 		return nil, fmt.Errorf("db.engine choice was %v but found pre-existing %v database in specified data directory", o.Type, existingDb)
 	}
@@ -299,7 +318,7 @@ func Open(o OpenOptions, nodeCtx int, logger *log.Logger, location common.Locati
 	if len(o.AncientsDirectory) == 0 {
 		return kvdb, nil
 	}
-	frdb, err := NewDatabaseWithFreezer(kvdb, o.AncientsDirectory, o.Namespace, o.ReadOnly, nodeCtx, logger, location)
+	frdb, err := NewDatabaseWithFreezer(kvdb, o.AncientsDirectory, o.Namespace, o.ReadOnly, o.AncientsThreshold, nodeCtx, logger, location)
 	if err != nil {
 		kvdb.Close()
 		return nil, err
@@ -351,10 +370,26 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte, logger *log.
 		// Key-value store statistics
 		headers         stat
 		bodies          stat
+		txObjects       stat
+		phObjects       stat
 		receipts        stat
-		tds             stat
 		numHashPairings stat
 		hashNumPairings stat
+		termini         stat
+		manifests       stat
+		interlinks      stat
+		blooms          stat
+		pendingHeaders  stat
+		pendingEtxs     stat
+		inboundEtxs     stat
+		etxSets         stat
+		spentUtxos      stat
+		addressUtxos    stat
+		addressUndos    stat
+		addressHistory  stat
+		etxLifecycles   stat
+		processedStates stat
+		expansionData   stat
 		tries           stat
 		codes           stat
 		txLookups       stat
@@ -367,12 +402,8 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte, logger *log.
 		ancientHeadersSize  common.StorageSize
 		ancientBodiesSize   common.StorageSize
 		ancientReceiptsSize common.StorageSize
-		ancientTdsSize      common.StorageSize
 		ancientHashesSize   common.StorageSize
-
-		// Les statistic
-		chtTrieNodes   stat
-		bloomTrieNodes stat
+		ancientEtxSetsSize  common.StorageSize
 
 		// Meta- and unaccounted data
 		metadata    stat
@@ -381,6 +412,15 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte, logger *log.
 		// Totals
 		total common.StorageSize
 	)
+	// hashKey reports whether the key is the prefix followed by a hash
+	hashKey := func(key, prefix []byte) bool {
+		return bytes.HasPrefix(key, prefix) && len(key) == len(prefix)+common.HashLength
+	}
+	// numberHashKey reports whether the key is the prefix followed by a block
+	// number and a hash
+	numberHashKey := func(key, prefix []byte) bool {
+		return bytes.HasPrefix(key, prefix) && len(key) == len(prefix)+8+common.HashLength
+	}
 	// Inspect key-value database first.
 	for it.Next() {
 		var (
@@ -389,51 +429,78 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte, logger *log.
 		)
 		total += size
 		switch {
-		case bytes.HasPrefix(key, headerPrefix) && len(key) == (len(headerPrefix)+8+common.HashLength):
+		case hashKey(key, blockWorkObjectHeaderPrefix):
 			headers.Add(size)
-		case bytes.HasPrefix(key, blockBodyPrefix) && len(key) == (len(blockBodyPrefix)+8+common.HashLength):
+		case hashKey(key, workObjectBodyPrefix):
 			bodies.Add(size)
-		case bytes.HasPrefix(key, blockReceiptsPrefix) && len(key) == (len(blockReceiptsPrefix)+8+common.HashLength):
+		case hashKey(key, txWorkObjectHeaderPrefix):
+			txObjects.Add(size)
+		case hashKey(key, phWorkObjectHeaderPrefix):
+			phObjects.Add(size)
+		case numberHashKey(key, blockReceiptsPrefix):
 			receipts.Add(size)
-		case bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerTDSuffix):
-			tds.Add(size)
-		case bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerHashSuffix):
+		case bytes.HasPrefix(key, headerPrefix) && len(key) == len(headerPrefix)+8+len(headerHashSuffix) && bytes.HasSuffix(key, headerHashSuffix):
 			numHashPairings.Add(size)
-		case bytes.HasPrefix(key, headerNumberPrefix) && len(key) == (len(headerNumberPrefix)+common.HashLength):
+		case hashKey(key, headerNumberPrefix):
 			hashNumPairings.Add(size)
+		case hashKey(key, terminiPrefix):
+			termini.Add(size)
+		case hashKey(key, manifestPrefix):
+			manifests.Add(size)
+		case hashKey(key, interlinkPrefix):
+			interlinks.Add(size)
+		case hashKey(key, bloomPrefix):
+			blooms.Add(size)
+		case hashKey(key, pendingHeaderPrefix), hashKey(key, phTerminiPrefix), hashKey(key, pbBodyPrefix), bytes.Equal(key, pbBodyHashPrefix):
+			pendingHeaders.Add(size)
+		case hashKey(key, pendingEtxsPrefix), hashKey(key, pendingEtxsRollupPrefix):
+			pendingEtxs.Add(size)
+		case hashKey(key, inboundEtxsPrefix), hashKey(key, etxPrefix):
+			inboundEtxs.Add(size)
+		case numberHashKey(key, etxSetHashesPrefix):
+			etxSets.Add(size)
+		case hashKey(key, spentUTXOsPrefix):
+			spentUtxos.Add(size)
+		case bytes.HasPrefix(key, AddressUtxosPrefix) && len(key) == len(AddressUtxosPrefix)+common.AddressLength+common.HashLength+2:
+			addressUtxos.Add(size)
+		case hashKey(key, addressUtxoUndoPrefix):
+			addressUndos.Add(size)
+		case bytes.HasPrefix(key, addressHistoryPrefix) && len(key) == len(addressHistoryPrefix)+common.AddressLength+AddressHistoryPositionLength:
+			addressHistory.Add(size)
+		case bytes.HasPrefix(key, AddressHistoryIndexPrefix):
+			addressHistory.Add(size)
+		case bytes.HasPrefix(key, etxLifecyclePrefix) && len(key) == len(etxLifecyclePrefix)+2*common.HashLength:
+			etxLifecycles.Add(size)
+		case hashKey(key, processedStatePrefix):
+			processedStates.Add(size)
+		case hashKey(key, expansionStatusPrefix), hashKey(key, efficiencyScorePrefix):
+			expansionData.Add(size)
 		case len(key) == common.HashLength:
 			tries.Add(size)
-		case bytes.HasPrefix(key, CodePrefix) && len(key) == len(CodePrefix)+common.HashLength:
+		case hashKey(key, CodePrefix):
 			codes.Add(size)
-		case bytes.HasPrefix(key, txLookupPrefix) && len(key) == (len(txLookupPrefix)+common.HashLength):
+		case hashKey(key, txLookupPrefix):
 			txLookups.Add(size)
-		case bytes.HasPrefix(key, SnapshotAccountPrefix) && len(key) == (len(SnapshotAccountPrefix)+common.HashLength):
+		case hashKey(key, SnapshotAccountPrefix):
 			accountSnaps.Add(size)
 		case bytes.HasPrefix(key, SnapshotStoragePrefix) && len(key) == (len(SnapshotStoragePrefix)+2*common.HashLength):
 			storageSnaps.Add(size)
-		case bytes.HasPrefix(key, preimagePrefix) && len(key) == (len(preimagePrefix)+common.HashLength):
+		case hashKey(key, preimagePrefix):
 			preimages.Add(size)
-		case bytes.HasPrefix(key, configPrefix) && len(key) == (len(configPrefix)+common.HashLength):
+		case hashKey(key, configPrefix):
 			metadata.Add(size)
 		case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == (len(bloomBitsPrefix)+10+common.HashLength):
 			bloomBits.Add(size)
 		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
 			bloomBits.Add(size)
-		case bytes.HasPrefix(key, []byte("cht-")) ||
-			bytes.HasPrefix(key, []byte("chtIndexV2-")) ||
-			bytes.HasPrefix(key, []byte("chtRootV2-")): // Canonical hash trie
-			chtTrieNodes.Add(size)
-		case bytes.HasPrefix(key, []byte("blt-")) ||
-			bytes.HasPrefix(key, []byte("bltIndex-")) ||
-			bytes.HasPrefix(key, []byte("bltRoot-")): // Bloomtrie sub
-			bloomTrieNodes.Add(size)
 		default:
 			var accounted bool
 			for _, meta := range [][]byte{
-				databaseVersionKey, headHeaderKey, headWorkObjectKey, lastPivotKey,
-				fastTrieProgressKey, snapshotDisabledKey, snapshotRootKey, snapshotJournalKey,
-				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey,
-				uncleanShutdownKey, badWorkObjectKey,
+				databaseVersionKey, headHeaderKey, headWorkObjectKey, headsHashesKey, phCacheKey,
+				phHeadKey, lastPivotKey, lastImportedBlockKey, fastTrieProgressKey, snapshotDisabledKey,
+				snapshotRootKey, snapshotJournalKey, snapshotGeneratorKey, snapshotRecoveryKey,
+				snapshotSyncStatusKey, txIndexTailKey, fastTxLookupLimitKey, uncleanShutdownKey,
+				badWorkObjectKey, genesisHashesKey, addressUtxoIndexHeadKey, addressUtxoIndexProgressKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -455,8 +522,8 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte, logger *log.
 		}
 	}
 	// Inspect append-only file store then.
-	ancientSizes := []*common.StorageSize{&ancientHeadersSize, &ancientBodiesSize, &ancientReceiptsSize, &ancientHashesSize, &ancientTdsSize}
-	for i, category := range []string{freezerHeaderTable, freezerBodiesTable, freezerReceiptTable, freezerHashTable, freezerDifficultyTable, freezerEtxSetsTable} {
+	ancientSizes := []*common.StorageSize{&ancientHeadersSize, &ancientBodiesSize, &ancientReceiptsSize, &ancientHashesSize, &ancientEtxSetsSize}
+	for i, category := range []string{freezerHeaderTable, freezerBodiesTable, freezerReceiptTable, freezerHashTable, freezerEtxSetsTable} {
 		if size, err := db.AncientSize(category); err == nil {
 			*ancientSizes[i] += common.StorageSize(size)
 			total += common.StorageSize(size)
@@ -469,12 +536,28 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte, logger *log.
 	}
 	// Display the database statistic.
 	stats := [][]string{
-		{"Key-Value store", "Headers", headers.Size(), headers.Count()},
-		{"Key-Value store", "Bodies", bodies.Size(), bodies.Count()},
+		{"Key-Value store", "Work object headers", headers.Size(), headers.Count()},
+		{"Key-Value store", "Work object bodies", bodies.Size(), bodies.Count()},
+		{"Key-Value store", "Transaction work objects", txObjects.Size(), txObjects.Count()},
+		{"Key-Value store", "Pending header work objects", phObjects.Size(), phObjects.Count()},
 		{"Key-Value store", "Receipt lists", receipts.Size(), receipts.Count()},
-		{"Key-Value store", "Difficulties", tds.Size(), tds.Count()},
 		{"Key-Value store", "Block number->hash", numHashPairings.Size(), numHashPairings.Count()},
 		{"Key-Value store", "Block hash->number", hashNumPairings.Size(), hashNumPairings.Count()},
+		{"Key-Value store", "Termini", termini.Size(), termini.Count()},
+		{"Key-Value store", "Manifests", manifests.Size(), manifests.Count()},
+		{"Key-Value store", "Interlink hashes", interlinks.Size(), interlinks.Count()},
+		{"Key-Value store", "Blooms", blooms.Size(), blooms.Count()},
+		{"Key-Value store", "Pending headers", pendingHeaders.Size(), pendingHeaders.Count()},
+		{"Key-Value store", "Pending etxs", pendingEtxs.Size(), pendingEtxs.Count()},
+		{"Key-Value store", "Inbound etxs", inboundEtxs.Size(), inboundEtxs.Count()},
+		{"Key-Value store", "Etx sets", etxSets.Size(), etxSets.Count()},
+		{"Key-Value store", "Spent UTXOs", spentUtxos.Size(), spentUtxos.Count()},
+		{"Key-Value store", "Address UTXO index", addressUtxos.Size(), addressUtxos.Count()},
+		{"Key-Value store", "Address UTXO undo data", addressUndos.Size(), addressUndos.Count()},
+		{"Key-Value store", "Address history index", addressHistory.Size(), addressHistory.Count()},
+		{"Key-Value store", "ETX lifecycle index", etxLifecycles.Size(), etxLifecycles.Count()},
+		{"Key-Value store", "Processed states", processedStates.Size(), processedStates.Count()},
+		{"Key-Value store", "Expansion data", expansionData.Size(), expansionData.Count()},
 		{"Key-Value store", "Transaction index", txLookups.Size(), txLookups.Count()},
 		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
 		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
@@ -483,13 +566,11 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte, logger *log.
 		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
 		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
 		{"Key-Value store", "Singleton metadata", metadata.Size(), metadata.Count()},
-		{"Ancient store", "Headers", ancientHeadersSize.String(), ancients.String()},
-		{"Ancient store", "Bodies", ancientBodiesSize.String(), ancients.String()},
+		{"Ancient store", "Work object headers", ancientHeadersSize.String(), ancients.String()},
+		{"Ancient store", "Work object bodies", ancientBodiesSize.String(), ancients.String()},
 		{"Ancient store", "Receipt lists", ancientReceiptsSize.String(), ancients.String()},
-		{"Ancient store", "Difficulties", ancientTdsSize.String(), ancients.String()},
 		{"Ancient store", "Block number->hash", ancientHashesSize.String(), ancients.String()},
-		{"Light client", "CHT trie nodes", chtTrieNodes.Size(), chtTrieNodes.Count()},
-		{"Light client", "Bloom trie nodes", bloomTrieNodes.Size(), bloomTrieNodes.Count()},
+		{"Ancient store", "Etx sets", ancientEtxSetsSize.String(), ancients.String()},
 	}
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{"Database", "Category", "Size", "Items"})
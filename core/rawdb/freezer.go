@@ -30,7 +30,6 @@ import (
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/ethdb"
 	"github.com/dominant-strategies/go-quai/log"
-	"github.com/dominant-strategies/go-quai/params"
 	"github.com/prometheus/tsdb/fileutil"
 )
 
@@ -75,7 +74,7 @@ type freezer struct {
 	// 64-bit aligned fields can be atomic. The struct is guaranteed to be so aligned,
 	// so take advantage of that (https://golang.org/pkg/sync/atomic/#pkg-note-BUG).
 	frozen    uint64 // Number of blocks already frozen
-	threshold uint64 // Number of recent blocks not to freeze (params.FullImmutabilityThreshold apart from tests)
+	threshold uint64 // Number of blocks below the prime terminus of the head not to freeze
 
 	readonly     bool
 	tables       map[string]*freezerTable // Data tables for storing everything
@@ -92,7 +91,7 @@ type freezer struct {
 
 // newFreezer creates a chain freezer that moves ancient chain data into
 // append-only flat file containers.
-func newFreezer(datadir string, namespace string, readonly bool, logger *log.Logger) (*freezer, error) {
+func newFreezer(datadir string, namespace string, readonly bool, threshold uint64, logger *log.Logger) (*freezer, error) {
 	// Create the initial freezer object
 	// Ensure the datadir is not a symbolic link if it exists.
 	if info, err := os.Lstat(datadir); !os.IsNotExist(err) {
@@ -110,7 +109,7 @@ func newFreezer(datadir string, namespace string, readonly bool, logger *log.Log
 	// Open all the supported data tables
 	freezer := &freezer{
 		readonly:     readonly,
-		threshold:    params.FullImmutabilityThreshold,
+		threshold:    threshold,
 		tables:       make(map[string]*freezerTable),
 		instanceLock: lock,
 		trigger:      make(chan chan struct{}),
@@ -301,9 +300,14 @@ func (f *freezer) Sync() error {
 // freeze is a background thread that periodically checks the blockchain for any
 // import progress and moves ancient data from the fast database into the freezer.
 //
+// Only the canonical blocks more than threshold blocks below the prime terminus
+// of the current head are frozen, the prime coincident blocks being the most
+// settled points of every slice. The zone blocks are only frozen once their
+// state has been processed, so that their receipts are frozen along with them.
+//
 // This functionality is deliberately broken off from block importing to avoid
 // incurring additional data shuffling delays on block propagation.
-func (f *freezer) freeze(db ethdb.KeyValueStore, nodeCtx int, location common.Location) {
+func (f *freezer) freeze(db ethdb.KeyValueStore, nodeCtx int) {
 	nfdb := &nofreezedb{KeyValueStore: db}
 
 	var (
@@ -339,42 +343,42 @@ func (f *freezer) freeze(db ethdb.KeyValueStore, nodeCtx int, location common.Lo
 			backoff = true
 			continue
 		}
-		number := ReadHeaderNumber(nfdb, hash)
+		head := ReadHeader(nfdb, hash)
+		if head == nil {
+			f.logger.WithField("hash", hash).Error("Current full block header unavailable")
+			backoff = true
+			continue
+		}
+		number := ReadHeaderNumber(nfdb, head.PrimeTerminus())
 		threshold := atomic.LoadUint64(&f.threshold)
 
 		switch {
 		case number == nil:
-			f.logger.WithField("hash", hash).Error("Current full block number unavailable")
+			f.logger.WithFields(log.Fields{
+				"hash":          hash,
+				"primeTerminus": head.PrimeTerminus(),
+			}).Debug("Prime terminus number unavailable")
 			backoff = true
 			continue
 
 		case *number < threshold:
 			f.logger.WithFields(log.Fields{
 				"number":    *number,
-				"hash":      hash,
+				"hash":      head.PrimeTerminus(),
 				"threshold": threshold,
-			}).Debug("Current full block not old enough")
+			}).Debug("Prime terminus not old enough")
 			backoff = true
 			continue
 
 		case *number-threshold <= f.frozen:
 			f.logger.WithFields(log.Fields{
 				"number": *number,
-				"hash":   hash,
+				"hash":   head.PrimeTerminus(),
 				"frozen": f.frozen,
 			}).Debug("Ancient blocks frozen already")
 			backoff = true
 			continue
 		}
-		head := ReadHeader(nfdb, hash)
-		if head == nil {
-			f.logger.WithFields(log.Fields{
-				"number": *number,
-				"hash":   hash,
-			}).Error("Current full block header unavailable")
-			backoff = true
-			continue
-		}
 		// Seems we have data ready to be frozen, process in usable batches
 		limit := *number - threshold
 		if limit-f.frozen > freezerBatchLimit {
@@ -384,6 +388,10 @@ func (f *freezer) freeze(db ethdb.KeyValueStore, nodeCtx int, location common.Lo
 			start    = time.Now()
 			first    = f.frozen
 			ancients = make([]common.Hash, 0, limit-f.frozen)
+			// The blocks of a zone without processed state never get receipts
+			// once the state of the head has been processed, as they are below
+			// the pivot of a state sync
+			headProcessed = nodeCtx == common.ZONE_CTX && ReadProcessedState(nfdb, hash)
 		)
 		for f.frozen <= limit {
 			// Retrieves all the components of the canonical block
@@ -392,7 +400,7 @@ func (f *freezer) freeze(db ethdb.KeyValueStore, nodeCtx int, location common.Lo
 				f.logger.WithField("number", f.frozen).Error("Canonical hash missing, can't freeze")
 				break
 			}
-			header := ReadHeaderProto(nfdb, hash, f.frozen)
+			header := ReadWorkObjectHeaderProto(nfdb, hash)
 			if len(header) == 0 {
 				f.logger.WithFields(log.Fields{
 					"number": f.frozen,
@@ -400,7 +408,7 @@ func (f *freezer) freeze(db ethdb.KeyValueStore, nodeCtx int, location common.Lo
 				}).Error("Block header missing, can't freeze")
 				break
 			}
-			body := ReadBodyProto(nfdb, hash, f.frozen)
+			body := ReadWorkObjectBodyProto(nfdb, hash)
 			if len(body) == 0 {
 				f.logger.WithFields(log.Fields{
 					"number": f.frozen,
@@ -408,22 +416,30 @@ func (f *freezer) freeze(db ethdb.KeyValueStore, nodeCtx int, location common.Lo
 				}).Error("Block body missing, can't freeze")
 				break
 			}
-			receipts := ReadReceiptsProto(nfdb, hash, f.frozen)
-			if len(receipts) == 0 {
-				f.logger.WithFields(log.Fields{
-					"number": f.frozen,
-					"hash":   hash,
-				}).Error("Block receipts missing, can't freeze")
-				break
-			}
-			etxSet, err := ReadEtxSetProto(nfdb, hash, f.frozen)
-			if err != nil {
-				f.logger.WithFields(log.Fields{
-					"number": f.frozen,
-					"hash":   hash,
-				}).Error("Total etxset missing, can't freeze")
-				break
+			// Receipts are only stored by the zones once the state of the block
+			// has been processed, so the freezing stops at the first block still
+			// waiting for its receipts and is retried later
+			if nodeCtx == common.ZONE_CTX && !HasReceipts(nfdb, hash, f.frozen) {
+				if ReadProcessedState(nfdb, hash) {
+					f.logger.WithFields(log.Fields{
+						"number": f.frozen,
+						"hash":   hash,
+					}).Error("Block receipts missing, can't freeze")
+					break
+				}
+				if !headProcessed {
+					f.logger.WithFields(log.Fields{
+						"number": f.frozen,
+						"hash":   hash,
+					}).Debug("Block state not processed yet, can't freeze")
+					break
+				}
 			}
+			receipts := ReadReceiptsProto(nfdb, hash, f.frozen)
+			// Etx sets are only stored along with the processed state by the
+			// older versions of the chain, so they are frozen empty for the
+			// blocks which have none
+			etxSet, _ := ReadEtxSetProto(nfdb, hash, f.frozen)
 			f.logger.WithFields(log.Fields{
 				"number": f.frozen,
 				"hash":   hash,
@@ -434,16 +450,19 @@ func (f *freezer) freeze(db ethdb.KeyValueStore, nodeCtx int, location common.Lo
 			}
 			ancients = append(ancients, hash)
 		}
-		// Batch of blocks have been frozen, flush them before wiping from leveldb
+		// Batch of blocks have been frozen, flush them before wiping from the
+		// key-value store
 		if err := f.Sync(); err != nil {
 			f.logger.WithField("err", err).Fatal("Failed to flush frozen tables")
 		}
-		// Wipe out all data from the active database
+		// Wipe out all data from the active database. The side chain blocks are
+		// only indexed by hash and are left in the key-value store.
 		batch := db.NewBatch()
 		for i := 0; i < len(ancients); i++ {
 			// Always keep the genesis block in active database
 			if first+uint64(i) != 0 {
 				DeleteBlockWithoutNumber(batch, ancients[i], first+uint64(i), types.BlockObject)
+				DeleteEtxSet(batch, ancients[i], first+uint64(i))
 				DeleteCanonicalHash(batch, first+uint64(i))
 			}
 		}
@@ -452,77 +471,16 @@ func (f *freezer) freeze(db ethdb.KeyValueStore, nodeCtx int, location common.Lo
 		}
 		batch.Reset()
 
-		// Wipe out side chains also and track dangling side chians
-		var dangling []common.Hash
-		for number := first; number < f.frozen; number++ {
-			// Always keep the genesis block in active database
-			if number != 0 {
-				dangling = ReadAllHashes(db, number)
-				for _, hash := range dangling {
-					f.logger.WithFields(log.Fields{
-						"number": number,
-						"hash":   hash,
-					}).Trace("Deleting side chain")
-					DeleteWorkObject(batch, hash, number, types.BlockObject)
-				}
-			}
-		}
-		if err := batch.Write(); err != nil {
-			f.logger.WithField("err", err).Fatal("Failed to delete frozen side blocks")
-		}
-		batch.Reset()
-
-		// Step into the future and delete and dangling side chains
-		if f.frozen > 0 {
-			tip := f.frozen
-			for len(dangling) > 0 {
-				drop := make(map[common.Hash]struct{})
-				for _, hash := range dangling {
-					f.logger.WithFields(log.Fields{
-						"number": tip - 1,
-						"hash":   hash,
-					}).Trace("Deleting parent from freezer")
-					drop[hash] = struct{}{}
-				}
-				children := ReadAllHashes(db, tip)
-				for i := 0; i < len(children); i++ {
-					// Dig up the child and ensure it's dangling
-					child := ReadHeader(nfdb, children[i])
-					if child == nil {
-						f.logger.WithFields(log.Fields{
-							"number": tip,
-							"hash":   children[i],
-						}).Error("Missing dangling header")
-						continue
-					}
-					if _, ok := drop[child.ParentHash(nodeCtx)]; !ok {
-						children = append(children[:i], children[i+1:]...)
-						i--
-						continue
-					}
-					// Delete all block data associated with the child
-					f.logger.WithFields(log.Fields{
-						"number": tip,
-						"hash":   children[i],
-						"parent": child.ParentHash(nodeCtx),
-					}).Debug("Deleting dangling block")
-					DeleteWorkObject(batch, children[i], tip, types.BlockObject)
-				}
-				dangling = children
-				tip++
-			}
-			if err := batch.Write(); err != nil {
-				f.logger.WithField("err", err).Fatal("Failed to delete dangling side blocks")
-			}
-		}
 		// Log something friendly for the user
-		context := []interface{}{
-			"blocks", f.frozen - first, "elapsed", common.PrettyDuration(time.Since(start)), "number", f.frozen - 1,
+		fields := log.Fields{
+			"blocks":  f.frozen - first,
+			"elapsed": common.PrettyDuration(time.Since(start)),
+			"number":  f.frozen - 1,
 		}
 		if n := len(ancients); n > 0 {
-			context = append(context, []interface{}{"hash", ancients[n-1]}...)
+			fields["hash"] = ancients[n-1]
 		}
-		f.logger.WithField("context", context).Info("Deep froze chain segment")
+		f.logger.WithFields(fields).Info("Deep froze chain segment")
 
 		// Avoid database thrashing with tiny writes
 		if f.frozen-first < freezerBatchLimit {
@@ -0,0 +1,150 @@
+package rawdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/ethdb/memorydb"
+	"github.com/dominant-strategies/go-quai/log"
+)
+
+// newTestFreezer opens a freezer in a temporary directory on top of a key-value
+// store, the freezer is closed along with the test
+func newTestFreezer(t *testing.T) (*freezerdb, *freezer) {
+	t.Helper()
+	f, err := newFreezer(t.TempDir(), "", false, 0, log.Global)
+	if err != nil {
+		t.Fatalf("failed to open freezer: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return &freezerdb{KeyValueStore: memorydb.New(log.Global), AncientStore: f}, f
+}
+
+// startFreeze runs the freeze loop and waits for its first pass to finish
+func startFreeze(db *freezerdb, f *freezer, nodeCtx int) {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.freeze(db.KeyValueStore, nodeCtx)
+	}()
+	triggerFreeze(f)
+}
+
+// triggerFreeze waits for the running pass of the freeze loop to finish, then
+// runs another one and waits for it as well
+func triggerFreeze(f *freezer) {
+	triggered := make(chan struct{})
+	f.trigger <- triggered
+	<-triggered
+}
+
+// writeTestChain writes a canonical zone chain whose head has its parent as
+// prime terminus
+func writeTestChain(db ethdb.Database, length int) []*types.WorkObject {
+	blocks := make([]*types.WorkObject, length)
+	parent := common.Hash{}
+	for i := range blocks {
+		block := types.EmptyHeader(common.ZONE_CTX)
+		if i > 0 {
+			block.Header().SetPrimeTerminus(blocks[i-1].Hash())
+		}
+		block.WorkObjectHeader().SetLocation(common.Location{0, 0})
+		block.WorkObjectHeader().SetNumber(big.NewInt(int64(i)))
+		block.WorkObjectHeader().SetParentHash(parent)
+		block.WorkObjectHeader().SetHeaderHash(block.Header().Hash())
+
+		WriteWorkObject(db, block.Hash(), block, types.BlockObject, common.ZONE_CTX)
+		WriteHeaderNumber(db, block.Hash(), uint64(i))
+		WriteCanonicalHash(db, block.Hash(), uint64(i))
+		blocks[i] = block
+		parent = block.Hash()
+	}
+	WriteHeadBlockHash(db, parent)
+	return blocks
+}
+
+func writeTestReceipts(db ethdb.Database, block *types.WorkObject) {
+	receipts := types.Receipts{{
+		Type:              types.QuaiTxType,
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: 21000,
+		Logs:              []*types.Log{},
+		TxHash:            block.Hash(),
+		GasUsed:           21000,
+	}}
+	WriteReceipts(db, block.Hash(), block.NumberU64(common.ZONE_CTX), receipts)
+}
+
+func TestFreezeWaitsForReceipts(t *testing.T) {
+	db, f := newTestFreezer(t)
+	// The prime terminus of the head is block #6, the blocks up to it are frozen
+	blocks := writeTestChain(db, 8)
+	for _, block := range blocks[:3] {
+		writeTestReceipts(db, block)
+	}
+	startFreeze(db, f, common.ZONE_CTX)
+
+	// Block #3 has no receipts yet, it is kept with the blocks above it
+	if frozen, _ := db.Ancients(); frozen != 3 {
+		t.Fatalf("frozen blocks mismatch: have %d, want 3", frozen)
+	}
+	for i, block := range blocks[:6] {
+		hash, number := block.Hash(), uint64(i)
+		if have := ReadCanonicalHash(db, number); have != hash {
+			t.Errorf("block %d: canonical hash mismatch: have %x, want %x", i, have, hash)
+		}
+		if len(ReadWorkObjectHeaderProto(db, hash)) == 0 || len(ReadWorkObjectBodyProto(db, hash)) == 0 {
+			t.Errorf("block %d: not found", i)
+		}
+		if has := HasReceipts(db, hash, number); has != (i < 3) {
+			t.Errorf("block %d: receipts presence mismatch: have %v, want %v", i, has, i < 3)
+		}
+		if i < 3 && len(ReadReceiptsProto(db, hash, number)) == 0 {
+			t.Errorf("block %d: receipts not found", i)
+		}
+		// The frozen blocks are removed from the key-value store, except for
+		// the genesis
+		kept, _ := db.Has(blockWorkObjectHeaderKey(hash))
+		if want := i == 0 || i >= 3; kept != want {
+			t.Errorf("block %d: key-value presence mismatch: have %v, want %v", i, kept, want)
+		}
+	}
+	// The freezing resumes once the block has been processed
+	for _, block := range blocks[3:6] {
+		writeTestReceipts(db, block)
+	}
+	triggerFreeze(f)
+	if frozen, _ := db.Ancients(); frozen != 6 {
+		t.Fatalf("frozen blocks mismatch: have %d, want 6", frozen)
+	}
+	for i, block := range blocks[3:6] {
+		if !HasReceipts(db, block.Hash(), uint64(i+3)) {
+			t.Errorf("block %d: receipts not found", i+3)
+		}
+	}
+}
+
+func TestFreezeStateSynced(t *testing.T) {
+	db, f := newTestFreezer(t)
+	blocks := writeTestChain(db, 8)
+
+	// The state of the head was downloaded, the blocks below it are never
+	// processed and are frozen without receipts
+	WriteProcessedState(db, blocks[7].Hash())
+	startFreeze(db, f, common.ZONE_CTX)
+	if frozen, _ := db.Ancients(); frozen != 7 {
+		t.Fatalf("frozen blocks mismatch: have %d, want 7", frozen)
+	}
+	// A processed block missing its receipts is never frozen
+	db, f = newTestFreezer(t)
+	blocks = writeTestChain(db, 8)
+	WriteProcessedState(db, blocks[7].Hash())
+	WriteProcessedState(db, blocks[2].Hash())
+	startFreeze(db, f, common.ZONE_CTX)
+	if frozen, _ := db.Ancients(); frozen != 2 {
+		t.Fatalf("frozen blocks mismatch: have %d, want 2", frozen)
+	}
+}
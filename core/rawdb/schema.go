@@ -47,6 +47,9 @@ var (
 	// lastPivotKey tracks the last pivot block used by fast sync (to reenable on sethead).
 	lastPivotKey = []byte("LastPivot")
 
+	// lastImportedBlockKey tracks the last block stored by an offline chain import.
+	lastImportedBlockKey = []byte("LastImportedBlock")
+
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
@@ -83,6 +86,15 @@ var (
 	// genesisHashesKey tracks the list of genesis hashes
 	genesisHashesKey = []byte("GenesisHashes")
 
+	// addressUtxoIndexHeadKey tracks the block the address utxo index reflects.
+	addressUtxoIndexHeadKey = []byte("AddressUtxoIndexHead")
+
+	// addressUtxoIndexProgressKey tracks the progress of an address utxo index rebuild across restarts.
+	addressUtxoIndexProgressKey = []byte("AddressUtxoIndexProgress")
+
+	// addressUtxoIndexVersionKey tracks the layout of the address utxo index entries.
+	addressUtxoIndexVersionKey = []byte("AddressUtxoIndexVersion")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -107,7 +119,10 @@ var (
 	inboundEtxsPrefix           = []byte("ie")    // inboundEtxsPrefix + hash -> types.Transactions
 	UtxoPrefix                  = []byte("ut")    // outpointPrefix + hash -> types.Outpoint
 	spentUTXOsPrefix            = []byte("sutxo") // spentUTXOsPrefix + hash -> []types.SpentTxOut
-	AddressUtxosPrefix          = []byte("au")    // addressUtxosPrefix + hash -> []types.UtxoEntry
+	AddressUtxosPrefix          = []byte("au")    // AddressUtxosPrefix + address + tx hash + index (uint16 big endian) -> denomination and lock
+	addressUtxoUndoPrefix       = []byte("uu")    // addressUtxoUndoPrefix + hash -> AddressUtxoUndo
+	addressHistoryPrefix        = []byte("ah")    // addressHistoryPrefix + address + num (uint64 big endian) + tx index (uint32 big endian) -> addressHistoryValue
+	etxLifecyclePrefix          = []byte("el")    // etxLifecyclePrefix + etx hash + block hash -> num (uint64 big endian)
 	processedStatePrefix        = []byte("ps")    // processedStatePrefix + hash -> boolean
 
 	blockBodyPrefix         = []byte("b")   // blockBodyPrefix + num (uint64 big endian) + hash -> block body
@@ -133,7 +148,8 @@ var (
 	configPrefix   = []byte("quai-config-") // config prefix for the db
 
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
-	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	BloomBitsIndexPrefix      = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
+	AddressHistoryIndexPrefix = []byte("iA") // AddressHistoryIndexPrefix is the data table of the address history indexer to track its progress
 )
 
 const (
@@ -149,22 +165,18 @@ const (
 	// freezerReceiptTable indicates the name of the freezer receipts table.
 	freezerReceiptTable = "receipts"
 
-	// freezerDifficultyTable indicates the name of the freezer total difficulty table.
-	freezerDifficultyTable = "diffs"
-
 	// freezerEtxSetsTable indicates the name of the etx set table.
 	freezerEtxSetsTable = "etxSets"
 )
 
 // FreezerNoSnappy configures whether compression is disabled for the ancient-tables.
-// Hashes and difficulties don't compress well.
+// Hashes don't compress well.
 var FreezerNoSnappy = map[string]bool{
-	freezerHeaderTable:     false,
-	freezerHashTable:       true,
-	freezerBodiesTable:     false,
-	freezerReceiptTable:    false,
-	freezerDifficultyTable: true,
-	freezerEtxSetsTable:    false,
+	freezerHeaderTable:  false,
+	freezerHashTable:    true,
+	freezerBodiesTable:  false,
+	freezerReceiptTable: false,
+	freezerEtxSetsTable: false,
 }
 
 // LegacyTxLookupEntry is the legacy TxLookupEntry definition with some unnecessary
@@ -384,6 +396,43 @@ func inboundEtxsKey(hash common.Hash) []byte {
 	return append(inboundEtxsPrefix, hash.Bytes()...)
 }
 
+// addressUtxosKey = AddressUtxosPrefix + address
 func addressUtxosKey(address common.Address) []byte {
 	return append(AddressUtxosPrefix, address.Bytes()...)
 }
+
+// isLegacyAddressUtxosKey reports whether the key is an addressUtxosKey, under
+// which the unversioned layouts of the index stored all the utxos of an address
+func isLegacyAddressUtxosKey(key []byte) bool {
+	return len(key) == len(AddressUtxosPrefix)+common.AddressLength && bytes.HasPrefix(key, AddressUtxosPrefix)
+}
+
+// addressUtxoKey = AddressUtxosPrefix + address + tx hash + index (uint16 big endian)
+func addressUtxoKey(address common.Address, txHash common.Hash, index uint16) []byte {
+	key := make([]byte, 0, len(AddressUtxosPrefix)+common.AddressLength+common.HashLength+2)
+	key = append(key, AddressUtxosPrefix...)
+	key = append(key, address.Bytes()...)
+	key = append(key, txHash.Bytes()...)
+	return binary.BigEndian.AppendUint16(key, index)
+}
+
+// addressHistoryKey = addressHistoryPrefix + address + num (uint64 big endian) + tx index (uint32 big endian)
+func addressHistoryKey(address common.Address, number uint64, txIndex uint32) []byte {
+	key := make([]byte, 0, len(addressHistoryPrefix)+common.AddressLength+AddressHistoryPositionLength)
+	key = append(key, addressHistoryPrefix...)
+	key = append(key, address.Bytes()...)
+	return append(key, AddressHistoryPosition(number, txIndex)...)
+}
+
+// etxLifecycleKey = etxLifecyclePrefix + etx hash + block hash
+func etxLifecycleKey(etxHash common.Hash, blockHash common.Hash) []byte {
+	key := make([]byte, 0, len(etxLifecyclePrefix)+2*common.HashLength)
+	key = append(key, etxLifecyclePrefix...)
+	key = append(key, etxHash.Bytes()...)
+	return append(key, blockHash.Bytes()...)
+}
+
+// addressUtxoUndoKey = addressUtxoUndoPrefix + hash
+func addressUtxoUndoKey(hash common.Hash) []byte {
+	return append(addressUtxoUndoPrefix, hash.Bytes()...)
+}
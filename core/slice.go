@@ -24,6 +24,7 @@ import (
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/params"
 	"github.com/dominant-strategies/go-quai/quaiclient"
+	"github.com/dominant-strategies/go-quai/rpc"
 	"github.com/dominant-strategies/go-quai/trie"
 )
 
@@ -66,6 +67,7 @@ type Slice struct {
 
 	domClient  *quaiclient.Client
 	subClients []*quaiclient.Client
+	rpcAuth    rpc.HTTPAuth // authenticates the dom and sub clients, if the slices require it
 
 	wg               sync.WaitGroup
 	scope            event.SubscriptionScope
@@ -87,7 +89,7 @@ type Slice struct {
 	logger         *log.Logger
 }
 
-func NewSlice(db ethdb.Database, config *Config, txConfig *TxPoolConfig, txLookupLimit *uint64, isLocalBlock func(block *types.WorkObject) bool, chainConfig *params.ChainConfig, slicesRunning []common.Location, currentExpansionNumber uint8, genesisBlock *types.WorkObject, domClientUrl string, subClientUrls []string, engine consensus.Engine, cacheConfig *CacheConfig, indexerConfig *IndexerConfig, vmConfig vm.Config, genesis *Genesis, logger *log.Logger) (*Slice, error) {
+func NewSlice(db ethdb.Database, config *Config, txConfig *TxPoolConfig, txLookupLimit *uint64, isLocalBlock func(block *types.WorkObject) bool, chainConfig *params.ChainConfig, slicesRunning []common.Location, currentExpansionNumber uint8, genesisBlock *types.WorkObject, domClientUrl string, subClientUrls []string, rpcAuth rpc.HTTPAuth, engine consensus.Engine, cacheConfig *CacheConfig, indexerConfig *IndexerConfig, vmConfig vm.Config, genesis *Genesis, logger *log.Logger) (*Slice, error) {
 	nodeCtx := chainConfig.Location.Context()
 	sl := &Slice{
 		config:         chainConfig,
@@ -95,6 +97,7 @@ func NewSlice(db ethdb.Database, config *Config, txConfig *TxPoolConfig, txLooku
 		sliceDb:        db,
 		quit:           make(chan struct{}),
 		badHashesCache: make(map[common.Hash]bool),
+		rpcAuth:        rpcAuth,
 		logger:         logger,
 	}
 
@@ -142,7 +145,7 @@ func NewSlice(db ethdb.Database, config *Config, txConfig *TxPoolConfig, txLooku
 					}).Fatal("Go-Quai Panicked")
 				}
 			}()
-			sl.domClient = makeDomClient(domClientUrl, sl.logger)
+			sl.domClient = makeDomClient(domClientUrl, sl.rpcAuth, sl.logger)
 		}()
 	}
 
@@ -312,10 +315,16 @@ func (sl *Slice) Append(header *types.WorkObject, domPendingHeader *types.WorkOb
 				// We also need to store the pendingEtxRollup to the dom
 				pEtxRollup := types.PendingEtxsRollup{header, subRollup}
 				sl.AddPendingEtxsRollup(pEtxRollup)
+				if sl.indexEtxs() {
+					sl.writeEtxLifecycle(batch, block, subRollup)
+				}
 			}
 			time6_3 = common.PrettyDuration(time.Since(start))
 		}
 	}
+	if nodeCtx == common.PRIME_CTX && sl.indexEtxs() {
+		sl.indexSubRollup(batch, block)
+	}
 
 	time7 := common.PrettyDuration(time.Since(start))
 
@@ -345,6 +354,9 @@ func (sl *Slice) Append(header *types.WorkObject, domPendingHeader *types.WorkOb
 			// it in the future if dom switch happens
 			// This should be pruned at the re-org tolerance depth
 			rawdb.WriteInboundEtxs(sl.sliceDb, block.Hash(), newInboundEtxs)
+			if sl.indexEtxs() {
+				sl.writeEtxLifecycle(batch, block, newInboundEtxs)
+			}
 		}
 
 		setHead = sl.poem(sl.engine.TotalLogS(sl.hc, block), sl.engine.TotalLogS(sl.hc, sl.hc.CurrentHeader()))
@@ -692,7 +704,8 @@ func (sl *Slice) generateSlicePendingHeader(block *types.WorkObject, newTermini
 	nodeCtx := sl.NodeLocation().Context()
 	var localPendingHeader *types.WorkObject
 	var err error
-	if subReorg {
+	// The pending header can only be filled once the state is available
+	if subReorg && !sl.hc.StateSyncing() {
 		// Upate the local pending header
 		localPendingHeader, err = sl.miner.worker.GeneratePendingHeader(block, fill)
 		if err != nil {
@@ -1546,11 +1559,11 @@ func (sl *Slice) SubscribeMissingBlockEvent(ch chan<- types.BlockRequest) event.
 }
 
 // MakeDomClient creates the quaiclient for the given domurl
-func makeDomClient(domurl string, logger *log.Logger) *quaiclient.Client {
+func makeDomClient(domurl string, auth rpc.HTTPAuth, logger *log.Logger) *quaiclient.Client {
 	if domurl == "" {
 		logger.Fatal("dom client url is empty")
 	}
-	domClient, err := quaiclient.Dial(domurl, logger)
+	domClient, err := quaiclient.DialWithAuth(domurl, auth, logger)
 	if err != nil {
 		logger.WithField("err", err).Fatal("Error connecting to the dominant go-quai client")
 	}
@@ -1569,7 +1582,7 @@ func (sl *Slice) makeSubClients(suburls []string, logger *log.Logger) {
 	}()
 	for i, suburl := range suburls {
 		if suburl != "" {
-			subClient, err := quaiclient.Dial(suburl, logger)
+			subClient, err := quaiclient.DialWithAuth(suburl, sl.rpcAuth, logger)
 			if err != nil {
 				logger.WithFields(log.Fields{
 					"index": i,
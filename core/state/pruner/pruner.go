@@ -351,6 +351,20 @@ func (p *Pruner) Prune(root common.Hash, location common.Location) error {
 	if err := extractGenesis(p.db, p.stateBloom, location); err != nil {
 		return err
 	}
+	// The UTXO and ETX set tries share the database with the account trie,
+	// so the ones of every block from the target to the head have to be
+	// retained as well.
+	blocks, err := findRetainedBlocks(p.db, root, location)
+	if err != nil {
+		return err
+	}
+	roots := make([]common.Hash, 0, 2*len(blocks))
+	for _, block := range blocks {
+		roots = append(roots, block.UTXORoot(), block.EtxSetRoot())
+	}
+	if err := extractTries(p.db, p.stateBloom, roots); err != nil {
+		return err
+	}
 	filterName := bloomFilterName(p.datadir, root)
 
 	p.logger.WithField("name", filterName).Info("Writing state bloom to disk")
@@ -425,6 +439,69 @@ func RecoverPruning(datadir string, db ethdb.Database, trieCachePath string, loc
 	return prune(snaptree, stateBloomRoot, db, stateBloom, stateBloomPath, middleRoots, time.Now(), logger)
 }
 
+// findRetainedBlocks walks the chain back from the head block until it finds
+// the block whose state root is the pruning target. The blocks from the head
+// down to the target are returned.
+func findRetainedBlocks(db ethdb.Database, root common.Hash, location common.Location) ([]*types.WorkObject, error) {
+	nodeCtx := location.Context()
+	var blocks []*types.WorkObject
+	block := rawdb.ReadHeadBlock(db)
+	for block != nil {
+		blocks = append(blocks, block)
+		if block.EVMRoot() == root {
+			return blocks, nil
+		}
+		if block.NumberU64(nodeCtx) == 0 {
+			break
+		}
+		block = rawdb.ReadWorkObjectHeaderOnly(db, block.ParentHash(nodeCtx), types.BlockObject)
+	}
+	return nil, fmt.Errorf("no canonical block found with state root %x", root)
+}
+
+// extractTrie commits all the nodes of the trie with the given root into the
+// given bloomfilter.
+func extractTrie(db ethdb.Database, stateBloom *stateBloom, root common.Hash) error {
+	return extractTries(db, stateBloom, []common.Hash{root})
+}
+
+// extractTries commits all the nodes of the tries with the given roots into
+// the given bloomfilter. The subtries shared by several roots are only
+// traversed once.
+func extractTries(db ethdb.Database, stateBloom *stateBloom, roots []common.Hash) error {
+	seen := make(map[common.Hash]struct{})
+	for _, root := range roots {
+		if root == emptyRoot || root == (common.Hash{}) {
+			continue
+		}
+		if _, ok := seen[root]; ok {
+			continue
+		}
+		t, err := trie.NewSecure(root, trie.NewDatabase(db))
+		if err != nil {
+			return err
+		}
+		iter := t.NodeIterator(nil)
+		for descend := true; iter.Next(descend); {
+			descend = true
+			hash := iter.Hash()
+			if hash == (common.Hash{}) {
+				continue
+			}
+			if _, ok := seen[hash]; ok {
+				descend = false
+				continue
+			}
+			seen[hash] = struct{}{}
+			stateBloom.Put(hash.Bytes(), nil)
+		}
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // extractGenesis loads the genesis state and commits all the state entries
 // into the given bloomfilter.
 func extractGenesis(db ethdb.Database, stateBloom *stateBloom, location common.Location) error {
@@ -436,6 +513,12 @@ func extractGenesis(db ethdb.Database, stateBloom *stateBloom, location common.L
 	if genesis == nil {
 		return errors.New("missing genesis block")
 	}
+	if err := extractTrie(db, stateBloom, genesis.UTXORoot()); err != nil {
+		return err
+	}
+	if err := extractTrie(db, stateBloom, genesis.EtxSetRoot()); err != nil {
+		return err
+	}
 	t, err := trie.NewSecure(genesis.EVMRoot(), trie.NewDatabase(db))
 	if err != nil {
 		return err
@@ -0,0 +1,147 @@
+package pruner
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/state/snapshot"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/trie"
+)
+
+var testLocation = common.Location{0, 0}
+
+// waitSnapshot waits for the generation of the disk layer of the snapshot
+func waitSnapshot(t *testing.T, snaptree *snapshot.Tree, root common.Hash) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := snaptree.Snapshot(root).Account(common.HexToHash("0xff")); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("snapshot generation timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// generateChain writes a chain of n blocks on top of an empty genesis. Every
+// block credits an account, creates a UTXO and pushes an ETX, so all the blocks
+// have distinct state, UTXO and ETX set roots.
+func generateChain(t *testing.T, db ethdb.Database, n int) []*types.WorkObject {
+	nodeCtx := testLocation.Context()
+	genesis := types.EmptyHeader(nodeCtx)
+	rawdb.WriteWorkObject(db, genesis.Hash(), genesis, types.BlockObject, nodeCtx)
+	rawdb.WriteCanonicalHash(db, genesis.Hash(), 0)
+
+	snaptree, err := snapshot.New(db, trie.NewDatabase(db), 256, genesis.EVMRoot(), true, false, log.Global)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitSnapshot(t, snaptree, genesis.EVMRoot())
+
+	var (
+		stateDb = state.NewDatabase(db)
+		utxoDb  = state.NewDatabase(db)
+		etxDb   = state.NewDatabase(db)
+		blocks  = []*types.WorkObject{genesis}
+		account = common.InternalAddress{testLocation.BytePrefix(), 0x01}
+		qiAddr  = common.InternalAddress{testLocation.BytePrefix(), 0x81}
+		to      = common.BytesToAddress(account.Bytes(), testLocation)
+	)
+	for i := 1; i <= n; i++ {
+		parent := blocks[i-1]
+		statedb, err := state.New(parent.EVMRoot(), parent.UTXORoot(), parent.EtxSetRoot(), stateDb, utxoDb, etxDb, snaptree, testLocation, log.Global)
+		if err != nil {
+			t.Fatal(err)
+		}
+		statedb.AddBalance(account, big.NewInt(1))
+		if err := statedb.CreateUTXO(common.Hash{byte(i)}, 0, &types.UtxoEntry{Denomination: 1, Address: qiAddr.Bytes(), Lock: big.NewInt(0)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := statedb.PushETX(types.NewTx(&types.ExternalTx{OriginatingTxHash: common.Hash{byte(i)}, To: &to, Value: big.NewInt(1), Sender: to})); err != nil {
+			t.Fatal(err)
+		}
+		root, err := statedb.Commit(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		utxoRoot, err := statedb.CommitUTXOs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		etxRoot, err := statedb.CommitETXs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stateDb.TrieDB().Commit(root, false, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := utxoDb.TrieDB().Commit(utxoRoot, false, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := etxDb.TrieDB().Commit(etxRoot, false, nil); err != nil {
+			t.Fatal(err)
+		}
+		block := types.EmptyHeader(nodeCtx)
+		block.SetParentHash(parent.Hash(), nodeCtx)
+		block.SetNumber(big.NewInt(int64(i)), nodeCtx)
+		block.Header().SetEVMRoot(root)
+		block.Header().SetUTXORoot(utxoRoot)
+		block.Header().SetEtxSetRoot(etxRoot)
+		block.WorkObjectHeader().SetHeaderHash(block.Header().Hash())
+		rawdb.WriteWorkObject(db, block.Hash(), block, types.BlockObject, nodeCtx)
+		rawdb.WriteCanonicalHash(db, block.Hash(), uint64(i))
+		rawdb.WriteHeadBlockHash(db, block.Hash())
+		blocks = append(blocks, block)
+	}
+	if _, err := snaptree.Journal(blocks[n].EVMRoot()); err != nil {
+		t.Fatal(err)
+	}
+	return blocks
+}
+
+// Tests that pruning keeps the UTXO and ETX sets of the head, and not only the
+// ones of the pruning target.
+func TestPruneRetainsHeadUTXOs(t *testing.T) {
+	const blockCount = 10
+	db := rawdb.NewMemoryDatabase(log.Global)
+	blocks := generateChain(t, db, blockCount)
+
+	datadir := t.TempDir()
+	pruner, err := NewPruner(db, datadir, filepath.Join(datadir, "triecache"), 256, log.Global, testLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := blocks[blockCount/2]
+	if err := pruner.Prune(target.EVMRoot(), testLocation); err != nil {
+		t.Fatal(err)
+	}
+	// The account state of the target is retained along with the UTXO and ETX
+	// sets of all the blocks down to it
+	head := blocks[blockCount]
+	statedb, err := state.New(target.EVMRoot(), head.UTXORoot(), head.EtxSetRoot(), state.NewDatabase(db), state.NewDatabase(db), state.NewDatabase(db), nil, testLocation, log.Global)
+	if err != nil {
+		t.Fatalf("failed to open the head state: %v", err)
+	}
+	for i := 1; i <= blockCount; i++ {
+		if utxo := statedb.GetUTXO(common.Hash{byte(i)}, 0); utxo == nil {
+			t.Errorf("utxo of block %d missing from the head utxo set", i)
+		}
+	}
+	if index, err := statedb.GetNewestIndex(); err != nil {
+		t.Fatalf("failed to read the head etx set: %v", err)
+	} else if index.Uint64() != blockCount {
+		t.Fatalf("head etx set has %d etxs, want %d", index.Uint64(), blockCount)
+	}
+	if err := statedb.Error(); err != nil {
+		t.Fatal(err)
+	}
+}
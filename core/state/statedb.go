@@ -137,6 +137,11 @@ type StateDB struct {
 
 	preimages map[common.Hash][]byte
 
+	// UTXOs created and spent since TrackUTXOChanges was called, nil when the
+	// changes are not tracked
+	createdUTXOs map[types.OutPoint]*types.UtxoEntry
+	spentUTXOs   map[types.OutPoint]*types.UtxoEntry
+
 	// Per-transaction access list
 	accessList *accessList
 
@@ -564,7 +569,7 @@ func (s *StateDB) GetUTXO(txHash common.Hash, outputIndex uint16) *types.UtxoEnt
 	if metrics_config.MetricsEnabled() {
 		defer func(start time.Time) { stateMetrics.WithLabelValues("GetUTXO").Add(float64(time.Since(start))) }(time.Now())
 	}
-	enc, err := s.utxoTrie.TryGet(utxoKey(txHash, outputIndex))
+	enc, err := s.utxoTrie.TryGet(UTXOKey(txHash, outputIndex))
 	if err != nil {
 		s.setError(fmt.Errorf("getUTXO (%x) error: %v", txHash, err))
 		return nil
@@ -589,8 +594,16 @@ func (s *StateDB) DeleteUTXO(txHash common.Hash, outputIndex uint16) {
 	if metrics_config.MetricsEnabled() {
 		defer func(start time.Time) { stateMetrics.WithLabelValues("DeleteUTXO").Add(float64(time.Since(start))) }(time.Now())
 	}
+	if s.createdUTXOs != nil {
+		outpoint := types.OutPoint{TxHash: txHash, Index: outputIndex}
+		if _, ok := s.createdUTXOs[outpoint]; ok {
+			delete(s.createdUTXOs, outpoint)
+		} else if utxo := s.GetUTXO(txHash, outputIndex); utxo != nil {
+			s.spentUTXOs[outpoint] = utxo
+		}
+	}
 	// Delete the utxo from the trie
-	if err := s.utxoTrie.TryDelete(utxoKey(txHash, outputIndex)); err != nil {
+	if err := s.utxoTrie.TryDelete(UTXOKey(txHash, outputIndex)); err != nil {
 		s.setError(fmt.Errorf("deleteUTXO (%x) error: %v", txHash, err))
 	}
 }
@@ -611,12 +624,27 @@ func (s *StateDB) CreateUTXO(txHash common.Hash, outputIndex uint16, utxo *types
 	if err != nil {
 		panic(fmt.Errorf("can't encode UTXO entry at %x: %v", txHash, err))
 	}
-	if err := s.utxoTrie.TryUpdate(utxoKey(txHash, outputIndex), data); err != nil {
+	if err := s.utxoTrie.TryUpdate(UTXOKey(txHash, outputIndex), data); err != nil {
 		s.setError(fmt.Errorf("createUTXO (%x) error: %v", txHash, err))
 	}
+	if s.createdUTXOs != nil {
+		s.createdUTXOs[types.OutPoint{TxHash: txHash, Index: outputIndex}] = utxo
+	}
 	return nil
 }
 
+// TrackUTXOChanges starts recording the UTXOs created and spent in the state.
+func (s *StateDB) TrackUTXOChanges() {
+	s.createdUTXOs = make(map[types.OutPoint]*types.UtxoEntry)
+	s.spentUTXOs = make(map[types.OutPoint]*types.UtxoEntry)
+}
+
+// UTXOChanges returns the UTXOs created and spent since TrackUTXOChanges was
+// called. A UTXO created and spent in between is in neither of the sets.
+func (s *StateDB) UTXOChanges() (created, spent map[types.OutPoint]*types.UtxoEntry) {
+	return s.createdUTXOs, s.spentUTXOs
+}
+
 func (s *StateDB) CommitUTXOs() (common.Hash, error) {
 	// Track the amount of time wasted on committing the utxos to the trie
 	if metrics_config.MetricsEnabled() {
@@ -638,7 +666,7 @@ func (s *StateDB) UTXORoot() common.Hash {
 
 func (s *StateDB) GetUTXOProof(hash common.Hash, index uint16) ([][]byte, error) {
 	var proof proofList
-	err := s.utxoTrie.Prove(utxoKey(hash, index), 0, &proof)
+	err := s.utxoTrie.Prove(UTXOKey(hash, index), 0, &proof)
 	return proof, err
 }
 
@@ -985,6 +1013,15 @@ func (s *StateDB) Copy() *StateDB {
 	for hash, preimage := range s.preimages {
 		state.preimages[hash] = preimage
 	}
+	if s.createdUTXOs != nil {
+		state.TrackUTXOChanges()
+		for outpoint, utxo := range s.createdUTXOs {
+			state.createdUTXOs[outpoint] = utxo
+		}
+		for outpoint, utxo := range s.spentUTXOs {
+			state.spentUTXOs[outpoint] = utxo
+		}
+	}
 	// Do we need to copy the access list? In practice: No. At the start of a
 	// transaction, the access list is empty. In practice, we only ever copy state
 	// _between_ transactions/blocks, never in the middle of a transaction.
@@ -1317,8 +1354,10 @@ func (s *StateDB) SlotInAccessList(addr common.Address, slot common.Hash) (addre
 	return s.accessList.Contains(addr.Bytes20(), slot)
 }
 
+// UTXOKey returns the key of an outpoint in the UTXO trie, the big endian output
+// index followed by the transaction hash.
 // This can be optimized via VLQ encoding as btcd has done
-func utxoKey(hash common.Hash, index uint16) []byte {
+func UTXOKey(hash common.Hash, index uint16) []byte {
 	indexBytes := make([]byte, 2)
 	binary.BigEndian.PutUint16(indexBytes, index)
 	return append(indexBytes, hash.Bytes()...)
@@ -19,6 +19,7 @@ package core
 import (
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"sync"
 	"time"
@@ -239,6 +240,9 @@ func (p *StateProcessor) Process(block *types.WorkObject) (types.Receipts, []*ty
 	if err != nil {
 		return types.Receipts{}, []*types.Transaction{}, []*types.Log{}, nil, 0, err
 	}
+	if p.hc.indexAddressUtxos() {
+		statedb.TrackUTXOChanges()
+	}
 	if len(block.Transactions()) == 0 {
 		return types.Receipts{}, []*types.Transaction{}, []*types.Log{}, statedb, 0, nil
 	}
@@ -282,6 +286,7 @@ func (p *StateProcessor) Process(block *types.WorkObject) (types.Receipts, []*ty
 		return nil, nil, nil, nil, 0, err
 	}
 	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, p.config, p.vmConfig)
+	gasTable := vmenv.GasTable()
 	time3 := common.PrettyDuration(time.Since(start))
 
 	// Iterate over and process the individual transactions.
@@ -311,7 +316,7 @@ func (p *StateProcessor) Process(block *types.WorkObject) (types.Receipts, []*ty
 			if _, ok := senders[tx.Hash()]; ok {
 				checkSig = false
 			}
-			fees, etxs, err := ProcessQiTx(tx, p.hc, true, checkSig, header, statedb, gp, usedGas, p.hc.pool.signer, p.hc.NodeLocation(), *p.config.ChainID, &etxRLimit, &etxPLimit)
+			fees, etxs, err := ProcessQiTx(tx, p.hc, true, checkSig, header, statedb, gp, usedGas, p.hc.pool.signer, p.hc.NodeLocation(), *p.config.ChainID, gasTable, &etxRLimit, &etxPLimit)
 			if err != nil {
 				return nil, nil, nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
 			}
@@ -357,33 +362,18 @@ func (p *StateProcessor) Process(block *types.WorkObject) (types.Receipts, []*ty
 						return nil, nil, nil, nil, 0, fmt.Errorf("could not find prime terminus header %032x", header.PrimeTerminus())
 					}
 					value := misc.QuaiToQi(primeTerminus, etx.Value()) // convert Quai to Qi
-					txGas := etx.Gas()
-					denominations := misc.FindMinDenominations(value)
-					outputIndex := uint16(0)
-					// Iterate over the denominations in descending order
-					for denomination := types.MaxDenomination; denomination >= 0; denomination-- {
-						// If the denomination count is zero, skip it
-						if denominations[uint8(denomination)] == 0 {
-							continue
+					for i, denomination := range ConversionOutputs(value, etx.Gas(), gasTable) {
+						outputIndex := uint16(i)
+						if err := gp.SubGas(gasTable.CallValueTransfer); err != nil {
+							return nil, nil, nil, nil, 0, err
 						}
-						for j := uint8(0); j < denominations[uint8(denomination)]; j++ {
-							if txGas < params.CallValueTransferGas || outputIndex >= types.MaxOutputIndex {
-								// No more gas, the rest of the denominations are lost but the tx is still valid
-								break
-							}
-							txGas -= params.CallValueTransferGas
-							if err := gp.SubGas(params.CallValueTransferGas); err != nil {
-								return nil, nil, nil, nil, 0, err
-							}
-							*usedGas += params.CallValueTransferGas    // In the future we may want to determine what a fair gas cost is
-							totalEtxGas += params.CallValueTransferGas // In the future we may want to determine what a fair gas cost is
-							// the ETX hash is guaranteed to be unique
-							if err := statedb.CreateUTXO(etx.Hash(), outputIndex, types.NewUtxoEntry(types.NewTxOut(uint8(denomination), etx.To().Bytes(), lock))); err != nil {
-								return nil, nil, nil, nil, 0, err
-							}
-							log.Global.Infof("Converting Quai to Qi %032x with denomination %d index %d lock %d", tx.Hash(), denomination, outputIndex, lock)
-							outputIndex++
+						*usedGas += gasTable.CallValueTransfer    // In the future we may want to determine what a fair gas cost is
+						totalEtxGas += gasTable.CallValueTransfer // In the future we may want to determine what a fair gas cost is
+						// the ETX hash is guaranteed to be unique
+						if err := statedb.CreateUTXO(etx.Hash(), outputIndex, types.NewUtxoEntry(types.NewTxOut(denomination, etx.To().Bytes(), lock))); err != nil {
+							return nil, nil, nil, nil, 0, err
 						}
+						log.Global.Infof("Converting Quai to Qi %032x with denomination %d index %d lock %d", tx.Hash(), denomination, outputIndex, lock)
 					}
 				} else {
 					// There are no more checks to be made as the ETX is worked so add it to the set
@@ -391,11 +381,11 @@ func (p *StateProcessor) Process(block *types.WorkObject) (types.Receipts, []*ty
 						return nil, nil, nil, nil, 0, err
 					}
 					// This Qi ETX should cost more gas
-					if err := gp.SubGas(params.CallValueTransferGas); err != nil {
+					if err := gp.SubGas(gasTable.CallValueTransfer); err != nil {
 						return nil, nil, nil, nil, 0, err
 					}
-					*usedGas += params.CallValueTransferGas    // In the future we may want to determine what a fair gas cost is
-					totalEtxGas += params.CallValueTransferGas // In the future we may want to determine what a fair gas cost is
+					*usedGas += gasTable.CallValueTransfer    // In the future we may want to determine what a fair gas cost is
+					totalEtxGas += gasTable.CallValueTransfer // In the future we may want to determine what a fair gas cost is
 				}
 				timeEtxDelta := time.Since(startTimeEtx)
 				timeEtx += timeEtxDelta
@@ -673,7 +663,7 @@ func ValidateQiTxInputs(tx *types.Transaction, chain ChainContext, statedb *stat
 
 }
 
-func ValidateQiTxOutputsAndSignature(tx *types.Transaction, chain ChainContext, totalQitIn *big.Int, currentHeader *types.WorkObject, signer types.Signer, location common.Location, chainId big.Int, etxRLimit, etxPLimit int) (*big.Int, error) {
+func ValidateQiTxOutputsAndSignature(tx *types.Transaction, chain ChainContext, totalQitIn *big.Int, currentHeader *types.WorkObject, signer types.Signer, location common.Location, chainId big.Int, gasTable params.GasTable, etxRLimit, etxPLimit int) (*big.Int, error) {
 
 	intrinsicGas := types.CalculateIntrinsicQiTxGas(tx)
 	usedGas := intrinsicGas
@@ -755,7 +745,7 @@ func ValidateQiTxOutputsAndSignature(tx *types.Transaction, chain ChainContext,
 			}
 
 			// We should require some kind of extra fee here
-			usedGas += params.ETXGas
+			usedGas += gasTable.ETX
 			numEtxs++
 		}
 	}
@@ -770,7 +760,7 @@ func ValidateQiTxOutputsAndSignature(tx *types.Transaction, chain ChainContext,
 	// the fee to pay the basefee/miner is the difference between inputs and outputs
 	txFeeInQit := new(big.Int).Sub(totalQitIn, totalQitOut)
 	// Check tx against required base fee and gas
-	requiredGas := intrinsicGas + (numEtxs * (params.TxGas + params.ETXGas)) // Each ETX costs extra gas that is paid in the origin
+	requiredGas := intrinsicGas + (numEtxs * (gasTable.Tx + gasTable.ETX)) // Each ETX costs extra gas that is paid in the origin
 	if requiredGas < intrinsicGas {
 		// Overflow
 		return nil, fmt.Errorf("tx %032x has too many ETXs to calculate required gas", tx.Hash())
@@ -787,7 +777,7 @@ func ValidateQiTxOutputsAndSignature(tx *types.Transaction, chain ChainContext,
 		if ETXPCount > etxPLimit {
 			return nil, fmt.Errorf("tx [%v] emits too many cross-prime ETXs for block. emitted: %d, limit: %d", tx.Hash().Hex(), ETXPCount, etxPLimit)
 		}
-		usedGas += params.ETXGas
+		usedGas += gasTable.ETX
 		txFeeInQit.Sub(txFeeInQit, txFeeInQit) // Fee goes entirely to gas to pay for conversion
 	}
 
@@ -817,10 +807,32 @@ func ValidateQiTxOutputsAndSignature(tx *types.Transaction, chain ChainContext,
 	return txFeeInQit, nil
 }
 
+// ConversionOutputs returns the denominations of the outputs created by a
+// Quai to Qi conversion of the given Qi value, in the order of their output
+// indices. The value is split into the fewest outputs, largest denomination
+// first, and only the outputs the gas of the conversion pays for are created,
+// the rest of the value being lost.
+func ConversionOutputs(value *big.Int, gas uint64, gasTable params.GasTable) []uint8 {
+	var outputs []uint8
+	denominations := misc.FindMinDenominations(value)
+	// Iterate over the denominations in descending order
+	for denomination := types.MaxDenomination; denomination >= 0; denomination-- {
+		for j := uint8(0); j < denominations[uint8(denomination)]; j++ {
+			if gas < gasTable.CallValueTransfer || len(outputs) >= types.MaxOutputIndex {
+				// No more gas, the rest of the denominations are lost but the tx is still valid
+				return outputs
+			}
+			gas -= gasTable.CallValueTransfer
+			outputs = append(outputs, uint8(denomination))
+		}
+	}
+	return outputs
+}
+
 // ProcessQiTx processes a QiTx by spending the inputs and creating the outputs.
 // Math is performed to verify the fee provided is sufficient to cover the gas cost.
 // updateState is set to update the statedb in the case of the state processor, but not in the case of the txpool.
-func ProcessQiTx(tx *types.Transaction, chain ChainContext, updateState bool, checkSig bool, currentHeader *types.WorkObject, statedb *state.StateDB, gp *types.GasPool, usedGas *uint64, signer types.Signer, location common.Location, chainId big.Int, etxRLimit, etxPLimit *int) (*big.Int, []*types.ExternalTx, error) {
+func ProcessQiTx(tx *types.Transaction, chain ChainContext, updateState bool, checkSig bool, currentHeader *types.WorkObject, statedb *state.StateDB, gp *types.GasPool, usedGas *uint64, signer types.Signer, location common.Location, chainId big.Int, gasTable params.GasTable, etxRLimit, etxPLimit *int) (*big.Int, []*types.ExternalTx, error) {
 	// Sanity checks
 	if tx == nil || tx.Type() != types.QiTxType {
 		return nil, nil, fmt.Errorf("tx %032x is not a QiTx", tx.Hash())
@@ -954,9 +966,9 @@ func ProcessQiTx(tx *types.Transaction, chain ChainContext, updateState bool, ch
 			}
 
 			// We should require some kind of extra fee here
-			etxInner := types.ExternalTx{Value: big.NewInt(int64(txOut.Denomination)), To: &toAddr, Sender: common.ZeroAddress(location), OriginatingTxHash: tx.Hash(), ETXIndex: uint16(txOutIdx), Gas: params.TxGas}
-			*usedGas += params.ETXGas
-			if err := gp.SubGas(params.ETXGas); err != nil {
+			etxInner := types.ExternalTx{Value: big.NewInt(int64(txOut.Denomination)), To: &toAddr, Sender: common.ZeroAddress(location), OriginatingTxHash: tx.Hash(), ETXIndex: uint16(txOutIdx), Gas: gasTable.Tx}
+			*usedGas += gasTable.ETX
+			if err := gp.SubGas(gasTable.ETX); err != nil {
 				return nil, nil, err
 			}
 			etxs = append(etxs, &etxInner)
@@ -981,7 +993,7 @@ func ProcessQiTx(tx *types.Transaction, chain ChainContext, updateState bool, ch
 	// the fee to pay the basefee/miner is the difference between inputs and outputs
 	txFeeInQit := new(big.Int).Sub(totalQitIn, totalQitOut)
 	// Check tx against required base fee and gas
-	requiredGas := intrinsicGas + (uint64(len(etxs)) * (params.TxGas + params.ETXGas)) // Each ETX costs extra gas that is paid in the origin
+	requiredGas := intrinsicGas + (uint64(len(etxs)) * (gasTable.Tx + gasTable.ETX)) // Each ETX costs extra gas that is paid in the origin
 	if requiredGas < intrinsicGas {
 		// Overflow
 		return nil, nil, fmt.Errorf("tx %032x has too many ETXs to calculate required gas", tx.Hash())
@@ -1007,8 +1019,8 @@ func ProcessQiTx(tx *types.Transaction, chain ChainContext, updateState bool, ch
 			return nil, nil, fmt.Errorf("tx [%v] emits too many cross-prime ETXs for block. emitted: %d, limit: %d", tx.Hash().Hex(), ETXPCount, etxPLimit)
 		}
 		etxInner := types.ExternalTx{Value: totalConvertQitOut, To: &convertAddress, Sender: common.ZeroAddress(location), OriginatingTxHash: tx.Hash(), Gas: remainingGas.Uint64()} // Value is in Qits not Denomination
-		*usedGas += params.ETXGas
-		if err := gp.SubGas(params.ETXGas); err != nil {
+		*usedGas += gasTable.ETX
+		if err := gp.SubGas(gasTable.ETX); err != nil {
 			return nil, nil, err
 		}
 		etxs = append(etxs, &etxInner)
@@ -1074,6 +1086,10 @@ func (p *StateProcessor) Apply(batch ethdb.Batch, block *types.WorkObject) ([]*t
 	}
 	time4 := common.PrettyDuration(time.Since(start))
 	rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(nodeCtx), receipts)
+	// Record the outputs created and spent for the address utxo index
+	if created, spent := statedb.UTXOChanges(); created != nil {
+		rawdb.WriteAddressUtxoUndo(batch, block.Hash(), newAddressUtxoUndo(created, spent))
+	}
 	time4_5 := common.PrettyDuration(time.Since(start))
 	// Create bloom filter and write it to cache/db
 	bloom := types.CreateBloom(receipts)
@@ -1403,9 +1419,15 @@ func (p *StateProcessor) StateAtBlock(block *types.WorkObject, reexec uint64, ba
 	return statedb, nil
 }
 
-// stateAtTransaction returns the execution environment of a certain transaction.
+// StateAtTransaction returns the execution environment of a certain transaction.
+// Coinbase and Qi transactions are not executed by the EVM, so for those the
+// returned message is nil and the state is positioned right before the
+// transaction; callers are expected to inspect the transaction type.
 func (p *StateProcessor) StateAtTransaction(block *types.WorkObject, txIndex int, reexec uint64) (Message, vm.BlockContext, *state.StateDB, error) {
-	nodeCtx := p.hc.NodeCtx()
+	var (
+		nodeCtx      = p.hc.NodeCtx()
+		nodeLocation = p.hc.NodeLocation()
+	)
 	// Short circuit if it's genesis block.
 	if block.NumberU64(nodeCtx) == 0 {
 		return nil, vm.BlockContext{}, nil, errors.New("no transaction in genesis")
@@ -1424,25 +1446,80 @@ func (p *StateProcessor) StateAtTransaction(block *types.WorkObject, txIndex int
 	if txIndex == 0 && len(block.Transactions()) == 0 {
 		return nil, vm.BlockContext{}, statedb, nil
 	}
+	context, err := NewEVMBlockContext(block, p.hc, nil)
+	if err != nil {
+		return nil, vm.BlockContext{}, nil, err
+	}
+	var (
+		signer  = types.MakeSigner(p.hc.Config(), block.Number(nodeCtx))
+		gp      = new(types.GasPool).AddGas(block.GasLimit())
+		usedGas = new(uint64)
+		// The block has already been validated, so the ETX limits do not
+		// need to be enforced again while replaying it
+		etxRLimit = math.MaxInt
+		etxPLimit = math.MaxInt
+	)
 	// Recompute transactions up to the target index.
-	signer := types.MakeSigner(p.hc.Config(), block.Number(nodeCtx))
 	for idx, tx := range block.Transactions() {
+		// Coinbase outputs are only added after all transactions are processed
+		if idx == 0 && types.IsCoinBaseTx(tx, block.ParentHash(nodeCtx), nodeLocation) {
+			if idx == txIndex {
+				return nil, context, statedb, nil
+			}
+			continue
+		}
+		if tx.Type() == types.QiTxType {
+			if idx == txIndex {
+				return nil, context, statedb, nil
+			}
+			if _, _, err := ProcessQiTx(tx, p.hc, true, false, block, statedb, gp, usedGas, signer, nodeLocation, *p.hc.Config().ChainID, p.hc.Config().GasTable(block.Number(nodeCtx), block.ExpansionNumber()), &etxRLimit, &etxPLimit); err != nil {
+				return nil, vm.BlockContext{}, nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
+			}
+			continue
+		}
 		// Assemble the transaction call message and return if the requested offset
-		msg, _ := tx.AsMessage(signer, block.BaseFee())
-		txContext := NewEVMTxContext(msg)
-		context, err := NewEVMBlockContext(block, p.hc, nil)
+		msg, err := tx.AsMessage(signer, block.BaseFee())
 		if err != nil {
-			return nil, vm.BlockContext{}, nil, err
+			return nil, vm.BlockContext{}, nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
+		}
+		var prevZeroBal *big.Int
+		if tx.Type() == types.ExternalTxType {
+			if tx.To().IsInQiLedgerScope() {
+				if idx == txIndex {
+					return nil, context, statedb, nil
+				}
+				// Conversions create locked UTXOs that cannot be spent within
+				// this block, so only plain Qi ETXs have to be replayed
+				if !tx.ETXSender().Location().Equal(*tx.To().Location()) {
+					if err := statedb.CreateUTXO(tx.OriginatingTxHash(), tx.ETXIndex(), types.NewUtxoEntry(types.NewTxOut(uint8(tx.Value().Uint64()), tx.To().Bytes(), big.NewInt(0)))); err != nil {
+						return nil, vm.BlockContext{}, nil, err
+					}
+				}
+				continue
+			}
+			if tx.ETXSender().Location().Equal(*tx.To().Location()) { // Qi->Quai Conversion
+				primeTerminus := p.hc.GetHeaderByHash(block.PrimeTerminus())
+				if primeTerminus == nil {
+					return nil, vm.BlockContext{}, nil, fmt.Errorf("could not find prime terminus header %032x", block.PrimeTerminus())
+				}
+				msg.SetLock(new(big.Int).Add(block.Number(nodeCtx), big.NewInt(params.ConversionLockPeriod)))
+				msg.SetValue(misc.QiToQuai(primeTerminus, tx.Value()))
+				msg.SetData([]byte{})
+			}
+			prevZeroBal = prepareApplyETX(statedb, msg.Value(), nodeLocation)
 		}
 		if idx == txIndex {
 			return msg, context, statedb, nil
 		}
 		// Not yet the searched for transaction, execute on top of the current state
-		vmenv := vm.NewEVM(context, txContext, statedb, p.hc.Config(), vm.Config{})
+		vmenv := vm.NewEVM(context, NewEVMTxContext(msg), statedb, p.hc.Config(), vm.Config{})
 		statedb.Prepare(tx.Hash(), idx)
-		if _, err := ApplyMessage(vmenv, msg, new(types.GasPool).AddGas(tx.Gas())); err != nil {
+		if _, err := ApplyMessage(vmenv, msg, gp); err != nil {
 			return nil, vm.BlockContext{}, nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
 		}
+		if prevZeroBal != nil {
+			statedb.SetBalance(common.ZeroInternal(nodeLocation), prevZeroBal)
+		}
 		// Ensure any modifications are committed to the state
 		statedb.Finalise(true)
 	}
@@ -1475,6 +1552,33 @@ func prepareApplyETX(statedb *state.StateDB, value *big.Int, nodeLocation common
 }
 
 func (p *StateProcessor) GetUTXOsByAddress(address common.Address) ([]*types.UtxoEntry, error) {
+	if p.hc.addressIndexRebuilding.Load() {
+		if !p.hc.addressIndexLegacy.Load() {
+			return nil, errAddressUtxoIndexRebuilding
+		}
+		// The index of an older node is served until it is rebuilt
+		outpoints, utxos := rawdb.ReadLegacyAddressUtxos(p.hc.bc.db, address)
+		for _, outpoint := range outpoints {
+			utxos = append(utxos, types.NewUtxoEntry(types.NewTxOut(outpoint.Denomination, address.Bytes(), outpoint.Lock)))
+		}
+		return utxos, nil
+	}
 	utxos := rawdb.ReadAddressUtxos(p.hc.bc.db, address)
 	return utxos, nil
 }
+
+func (p *StateProcessor) GetOutpointsByAddress(address common.Address) ([]*types.OutpointAndDenomination, error) {
+	if p.hc.addressIndexRebuilding.Load() {
+		if !p.hc.addressIndexLegacy.Load() {
+			return nil, errAddressUtxoIndexRebuilding
+		}
+		// The first layout did not record the outpoints
+		outpoints, utxos := rawdb.ReadLegacyAddressUtxos(p.hc.bc.db, address)
+		if utxos != nil {
+			return nil, errAddressUtxoIndexRebuilding
+		}
+		return outpoints, nil
+	}
+	outpoints := rawdb.ReadAddressOutpoints(p.hc.bc.db, address)
+	return outpoints, nil
+}
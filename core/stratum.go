@@ -0,0 +1,915 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/consensus"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/event"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/params"
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+const (
+	// c_stratumJobHistory is the number of recent jobs shares are accepted for
+	c_stratumJobHistory = 16
+	// c_stratumMaxConnections is the maximum number of miner connections served at once
+	c_stratumMaxConnections = 4096
+	// c_stratumMaxRequestSize is the maximum size of a single request line
+	c_stratumMaxRequestSize = 4096
+	// c_stratumMaxWorkerName is the maximum length of a worker name
+	c_stratumMaxWorkerName = 128
+	// c_stratumReadTimeout is the time a connection may stay silent before it is closed
+	c_stratumReadTimeout = 10 * time.Minute
+	// c_stratumWriteTimeout is the time allowed to deliver a message to a miner
+	c_stratumWriteTimeout = 10 * time.Second
+	// c_stratumHashrateWindow is the period the hashrate of a worker is averaged over
+	c_stratumHashrateWindow = 10 * time.Minute
+	// c_stratumStatsInterval is the interval the miner statistics are logged at
+	c_stratumStatsInterval = time.Minute
+)
+
+// stratumError is an error reported to a miner, encoded as the [code, message,
+// traceback] triple used by the stratum pools.
+type stratumError struct {
+	code    int
+	message string
+}
+
+func (e *stratumError) Error() string { return e.message }
+
+func (e *stratumError) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.code, e.message, nil})
+}
+
+var (
+	errStratumUnknownMethod  = &stratumError{20, "unknown method"}
+	errStratumInvalidParams  = &stratumError{20, "invalid parameters"}
+	errStratumInvalidNonce   = &stratumError{20, "nonce does not start with the extranonce"}
+	errStratumInvalidHeader  = &stratumError{20, "header hash does not match the job"}
+	errStratumJobNotFound    = &stratumError{21, "job not found"}
+	errStratumDuplicateShare = &stratumError{22, "duplicate share"}
+	errStratumLowDifficulty  = &stratumError{23, "low difficulty share"}
+	errStratumUnauthorized   = &stratumError{24, "unauthorized worker"}
+	errStratumNotSubscribed  = &stratumError{25, "not subscribed"}
+)
+
+// StratumBackend broadcasts the blocks and workshares found by the stratum
+// miners to the network.
+type StratumBackend interface {
+	BroadcastBlock(block *types.WorkObject, location common.Location) error
+	BroadcastHeader(header *types.WorkObject, location common.Location) error
+	BroadcastWorkShare(workShare *types.WorkObjectHeader, location common.Location) error
+}
+
+// stratumCore is the part of the core the stratum server gets the pending
+// headers from and assembles the mined blocks with
+type stratumCore interface {
+	Config() *params.ChainConfig
+	Engine() consensus.Engine
+	NodeLocation() common.Location
+	GetPendingHeader() (*types.WorkObject, error)
+	SubscribePendingHeader(ch chan<- *types.WorkObject) event.Subscription
+	ConstructLocalMinedBlock(woHeader *types.WorkObject) (*types.WorkObject, error)
+}
+
+// StratumWorkerStats are the statistics of a worker connected to the stratum
+// server. The hashrate is estimated from the work of the accepted shares.
+type StratumWorkerStats struct {
+	Name        string         `json:"name"`
+	Connections hexutil.Uint64 `json:"connections"`
+	Hashrate    hexutil.Uint64 `json:"hashrate"`
+	Shares      hexutil.Uint64 `json:"shares"`
+	Blocks      hexutil.Uint64 `json:"blocks"`
+	Rejected    hexutil.Uint64 `json:"rejected"`
+	LastShare   hexutil.Uint64 `json:"lastShare"`
+}
+
+// StratumServer serves the pending headers of a zone to external miners over
+// the stratum v1 protocol. New pending headers are pushed to the miners as jobs
+// as soon as the worker produces them, and the submitted shares are validated
+// with the consensus engine before they are broadcast as blocks or workshares.
+//
+// The progpow job format is [jobId, sealHash, height, cleanJobs] and shares are
+// submitted as [worker, jobId, nonce, sealHash, mixHash]. The blake3pow job
+// format is [jobId, sealHash, cleanJobs] and shares are submitted as [worker,
+// jobId, nonce]. The share target is sent with mining.set_target whenever it
+// changes.
+type StratumServer struct {
+	addr    string
+	core    stratumCore
+	backend StratumBackend
+	engine  consensus.Engine
+	progpow bool
+	logger  *log.Logger
+
+	listener net.Listener
+
+	jobMu      sync.RWMutex
+	job        *stratumJob // most recent job
+	jobs       *lru.Cache[string, *stratumJob]
+	jobCounter uint64
+
+	sessionMu   sync.Mutex
+	sessions    map[*stratumSession]struct{}
+	extranonces map[uint16]struct{}
+	extranonce  uint16
+
+	workerMu sync.Mutex
+	workers  map[string]*stratumWorker
+
+	pendingHeaderCh  chan *types.WorkObject
+	pendingHeaderSub event.Subscription
+	quit             chan struct{}
+	wg               sync.WaitGroup
+}
+
+// stratumJob is a pending header handed out to the miners
+type stratumJob struct {
+	id       string
+	header   *types.WorkObject
+	sealHash common.Hash
+	target   *big.Int // target a share has to meet to be a workshare
+	work     *big.Int // expected number of hashes to find a share
+
+	lock   sync.Mutex
+	nonces map[types.BlockNonce]struct{} // nonces already submitted
+}
+
+// stratumSession is a connection of a miner
+type stratumSession struct {
+	server     *StratumServer
+	conn       net.Conn
+	id         string
+	extranonce []byte
+	writeLock  sync.Mutex
+
+	lock       sync.Mutex
+	subscribed bool
+	workers    map[string]struct{} // authorized worker names
+	target     *big.Int            // last target sent to the miner
+}
+
+// stratumWorker accumulates the statistics of a worker name
+type stratumWorker struct {
+	connections int
+	since       time.Time
+	shares      []stratumShare // shares accepted within the hashrate window
+	accepted    uint64
+	blocks      uint64
+	rejected    uint64
+	lastShare   time.Time
+}
+
+type stratumShare struct {
+	time time.Time
+	work *big.Int
+}
+
+type stratumRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type stratumResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  *stratumError   `json:"error"`
+}
+
+type stratumNotification struct {
+	ID     *uint64       `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// NewStratumServer creates a stratum server listening on the given address.
+// The server only accepts connections once it is started.
+func NewStratumServer(addr string, core *Core, backend StratumBackend, logger *log.Logger) *StratumServer {
+	return newStratumServer(addr, core, backend, logger)
+}
+
+func newStratumServer(addr string, core stratumCore, backend StratumBackend, logger *log.Logger) *StratumServer {
+	jobs, _ := lru.New[string, *stratumJob](c_stratumJobHistory)
+	return &StratumServer{
+		addr:            addr,
+		core:            core,
+		backend:         backend,
+		engine:          core.Engine(),
+		progpow:         core.Config().ConsensusEngine != "blake3",
+		logger:          logger,
+		jobs:            jobs,
+		sessions:        make(map[*stratumSession]struct{}),
+		extranonces:     make(map[uint16]struct{}),
+		workers:         make(map[string]*stratumWorker),
+		pendingHeaderCh: make(chan *types.WorkObject, 1),
+		quit:            make(chan struct{}),
+	}
+}
+
+// Start opens the listener and starts serving the miners
+func (s *StratumServer) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.pendingHeaderSub = s.core.SubscribePendingHeader(s.pendingHeaderCh)
+	if header, err := s.core.GetPendingHeader(); err == nil && header != nil {
+		s.newJob(header)
+	}
+	s.wg.Add(3)
+	go s.acceptLoop()
+	go s.jobLoop()
+	go s.statsLoop()
+
+	s.logger.WithFields(log.Fields{
+		"addr":      listener.Addr(),
+		"algorithm": s.algorithm(),
+	}).Info("Stratum server started")
+	return nil
+}
+
+// Stop closes the listener and all the miner connections
+func (s *StratumServer) Stop() {
+	if s.listener == nil {
+		return
+	}
+	close(s.quit)
+	s.listener.Close()
+	s.pendingHeaderSub.Unsubscribe()
+
+	s.sessionMu.Lock()
+	for session := range s.sessions {
+		session.conn.Close()
+	}
+	s.sessionMu.Unlock()
+
+	s.wg.Wait()
+	s.logger.Info("Stratum server stopped")
+}
+
+// Workers returns the statistics of the workers that are connected or
+// submitted shares within the hashrate window, ordered by name.
+func (s *StratumServer) Workers() []StratumWorkerStats {
+	s.workerMu.Lock()
+	defer s.workerMu.Unlock()
+
+	now := time.Now()
+	stats := make([]StratumWorkerStats, 0, len(s.workers))
+	for name, worker := range s.workers {
+		var lastShare uint64
+		if !worker.lastShare.IsZero() {
+			lastShare = uint64(worker.lastShare.Unix())
+		}
+		stats = append(stats, StratumWorkerStats{
+			Name:        name,
+			Connections: hexutil.Uint64(worker.connections),
+			Hashrate:    hexutil.Uint64(worker.hashrate(now)),
+			Shares:      hexutil.Uint64(worker.accepted),
+			Blocks:      hexutil.Uint64(worker.blocks),
+			Rejected:    hexutil.Uint64(worker.rejected),
+			LastShare:   hexutil.Uint64(lastShare),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+func (s *StratumServer) algorithm() string {
+	if s.progpow {
+		return "progpow"
+	}
+	return "blake3pow"
+}
+
+func (s *StratumServer) stopped() bool {
+	select {
+	case <-s.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// acceptLoop accepts the miner connections until the server stops
+func (s *StratumServer) acceptLoop() {
+	defer s.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.WithFields(log.Fields{
+				"error":      r,
+				"stacktrace": string(debug.Stack()),
+			}).Fatal("Go-Quai Panicked")
+		}
+	}()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if s.stopped() {
+				return
+			}
+			s.logger.WithField("err", err).Warn("Failed to accept stratum connection")
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		session := s.newSession(conn)
+		if session == nil {
+			s.logger.WithField("addr", conn.RemoteAddr()).Debug("Too many stratum connections, dropping")
+			conn.Close()
+			continue
+		}
+		s.wg.Add(1)
+		go session.serve()
+	}
+}
+
+// newSession registers a connection and assigns it an unused two byte
+// extranonce, the prefix of all the nonces the miner submits, so that the
+// miners do not search the same nonces. It returns nil if the connection limit
+// is reached.
+func (s *StratumServer) newSession(conn net.Conn) *stratumSession {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+
+	if len(s.sessions) >= c_stratumMaxConnections {
+		return nil
+	}
+	for {
+		s.extranonce++
+		if _, used := s.extranonces[s.extranonce]; !used {
+			break
+		}
+	}
+	extranonce := binary.BigEndian.AppendUint16(nil, s.extranonce)
+	session := &stratumSession{
+		server:     s,
+		conn:       conn,
+		id:         hex.EncodeToString(extranonce),
+		extranonce: extranonce,
+		workers:    make(map[string]struct{}),
+	}
+	s.sessions[session] = struct{}{}
+	s.extranonces[s.extranonce] = struct{}{}
+	return session
+}
+
+// dropSession unregisters a closed connection and its workers
+func (s *StratumServer) dropSession(session *stratumSession) {
+	s.sessionMu.Lock()
+	delete(s.sessions, session)
+	delete(s.extranonces, binary.BigEndian.Uint16(session.extranonce))
+	s.sessionMu.Unlock()
+
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	s.workerMu.Lock()
+	defer s.workerMu.Unlock()
+	for name := range session.workers {
+		if worker, ok := s.workers[name]; ok {
+			worker.connections--
+		}
+	}
+}
+
+// jobLoop turns the pending headers of the worker into jobs
+func (s *StratumServer) jobLoop() {
+	defer s.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.WithFields(log.Fields{
+				"error":      r,
+				"stacktrace": string(debug.Stack()),
+			}).Fatal("Go-Quai Panicked")
+		}
+	}()
+	for {
+		select {
+		case header := <-s.pendingHeaderCh:
+			s.newJob(header)
+		case <-s.pendingHeaderSub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// newJob creates a job for the pending header and pushes it to the miners.
+// The miners are asked to drop their current work if the parent changed.
+func (s *StratumServer) newJob(header *types.WorkObject) {
+	header = types.CopyWorkObject(header)
+	sealHash := header.SealHash()
+
+	s.jobMu.Lock()
+	if s.job != nil && s.job.sealHash == sealHash {
+		s.jobMu.Unlock()
+		return
+	}
+	clean := s.job == nil || s.job.header.ParentHash(common.ZONE_CTX) != header.ParentHash(common.ZONE_CTX)
+	s.jobCounter++
+	job := newStratumJob(fmt.Sprintf("%x", s.jobCounter), header, sealHash)
+	s.job = job
+	s.jobs.Add(job.id, job)
+	s.jobMu.Unlock()
+
+	s.sessionMu.Lock()
+	sessions := make([]*stratumSession, 0, len(s.sessions))
+	for session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.sessionMu.Unlock()
+
+	// Deliver the job in parallel so that a slow miner does not delay the others
+	var wg sync.WaitGroup
+	for _, session := range sessions {
+		wg.Add(1)
+		go func(session *stratumSession) {
+			defer wg.Done()
+			session.sendJob(job, clean)
+		}(session)
+	}
+	wg.Wait()
+}
+
+// currentJob returns the most recent job, or nil if there is none yet
+func (s *StratumServer) currentJob() *stratumJob {
+	s.jobMu.RLock()
+	defer s.jobMu.RUnlock()
+	return s.job
+}
+
+// statsLoop logs the statistics of the miners and forgets the workers that
+// disconnected and stopped submitting shares
+func (s *StratumServer) statsLoop() {
+	defer s.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.WithFields(log.Fields{
+				"error":      r,
+				"stacktrace": string(debug.Stack()),
+			}).Fatal("Go-Quai Panicked")
+		}
+	}()
+	ticker := time.NewTicker(c_stratumStatsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.pruneWorkers()
+			s.sessionMu.Lock()
+			connections := len(s.sessions)
+			s.sessionMu.Unlock()
+			if connections == 0 {
+				continue
+			}
+			var hashrate, shares, blocks uint64
+			workers := s.Workers()
+			for _, worker := range workers {
+				hashrate += uint64(worker.Hashrate)
+				shares += uint64(worker.Shares)
+				blocks += uint64(worker.Blocks)
+			}
+			s.logger.WithFields(log.Fields{
+				"connections": connections,
+				"workers":     len(workers),
+				"hashrate":    hashrate,
+				"shares":      shares,
+				"blocks":      blocks,
+			}).Info("Stratum miners")
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *StratumServer) pruneWorkers() {
+	s.workerMu.Lock()
+	defer s.workerMu.Unlock()
+
+	now := time.Now()
+	for name, worker := range s.workers {
+		worker.pruneShares(now)
+		if worker.connections == 0 && now.Sub(worker.lastShare) > c_stratumHashrateWindow {
+			delete(s.workers, name)
+		}
+	}
+}
+
+// authorizeWorker counts a new connection of the worker
+func (s *StratumServer) authorizeWorker(name string) {
+	s.workerMu.Lock()
+	defer s.workerMu.Unlock()
+
+	worker, ok := s.workers[name]
+	if !ok {
+		worker = &stratumWorker{since: time.Now()}
+		s.workers[name] = worker
+	}
+	worker.connections++
+}
+
+// recordShare updates the statistics of the worker with a submitted share
+func (s *StratumServer) recordShare(name string, work *big.Int, block bool, accepted bool) {
+	s.workerMu.Lock()
+	defer s.workerMu.Unlock()
+
+	worker, ok := s.workers[name]
+	if !ok {
+		return
+	}
+	if !accepted {
+		worker.rejected++
+		return
+	}
+	now := time.Now()
+	worker.accepted++
+	if block {
+		worker.blocks++
+	}
+	worker.lastShare = now
+	worker.shares = append(worker.shares, stratumShare{time: now, work: work})
+	worker.pruneShares(now)
+}
+
+// submit validates a share and broadcasts it as a block if it meets the
+// difficulty of the header, or as a workshare otherwise
+func (s *StratumServer) submit(session *stratumSession, params []json.RawMessage) *stratumError {
+	wantParams := 3
+	if s.progpow {
+		wantParams = 5
+	}
+	if len(params) < wantParams {
+		return errStratumInvalidParams
+	}
+	var name, jobID string
+	if json.Unmarshal(params[0], &name) != nil || json.Unmarshal(params[1], &jobID) != nil {
+		return errStratumInvalidParams
+	}
+	if !session.authorized(name) {
+		return errStratumUnauthorized
+	}
+	job, header, err := s.decodeShare(session, jobID, params)
+	if err != nil {
+		s.recordShare(name, nil, false, false)
+		return err
+	}
+	block, err := s.submitShare(name, job, header)
+	if err != nil {
+		s.recordShare(name, nil, false, false)
+		return err
+	}
+	s.recordShare(name, job.work, block, true)
+	return nil
+}
+
+// decodeShare decodes a submitted share and returns its job along with the
+// header of the job with the nonce of the share
+func (s *StratumServer) decodeShare(session *stratumSession, jobID string, params []json.RawMessage) (*stratumJob, *types.WorkObjectHeader, *stratumError) {
+	job, ok := s.jobs.Get(jobID)
+	if !ok {
+		return nil, nil, errStratumJobNotFound
+	}
+	nonce, err := decodeStratumHex(params[2], len(types.BlockNonce{}))
+	if err != nil {
+		return nil, nil, errStratumInvalidParams
+	}
+	if !bytes.HasPrefix(nonce, session.extranonce) {
+		return nil, nil, errStratumInvalidNonce
+	}
+	header := job.workObjectHeader(types.EncodeNonce(binary.BigEndian.Uint64(nonce)))
+	if s.progpow {
+		sealHash, err := decodeStratumHex(params[3], common.HashLength)
+		if err != nil {
+			return nil, nil, errStratumInvalidParams
+		}
+		if common.BytesToHash(sealHash) != job.sealHash {
+			return nil, nil, errStratumInvalidHeader
+		}
+		mixHash, err := decodeStratumHex(params[4], common.HashLength)
+		if err != nil {
+			return nil, nil, errStratumInvalidParams
+		}
+		header.SetMixHash(common.BytesToHash(mixHash))
+	}
+	if !job.addNonce(header.Nonce()) {
+		return nil, nil, errStratumDuplicateShare
+	}
+	return job, header, nil
+}
+
+// submitShare broadcasts a decoded share. It returns true if the share was a
+// block.
+func (s *StratumServer) submitShare(name string, job *stratumJob, header *types.WorkObjectHeader) (bool, *stratumError) {
+	if _, err := s.engine.VerifySeal(header); err == nil {
+		err := s.submitBlock(name, job, header)
+		if err == nil {
+			return true, nil
+		}
+		// Still try to get the work counted as a workshare
+		s.logger.WithFields(log.Fields{
+			"worker": name,
+			"hash":   header.Hash(),
+			"err":    err,
+		}).Error("Failed to construct the block found by a stratum miner")
+	}
+	if !s.engine.CheckIfValidWorkShare(header) {
+		return false, errStratumLowDifficulty
+	}
+	if err := s.backend.BroadcastWorkShare(header, s.core.NodeLocation()); err != nil {
+		s.logger.WithField("err", err).Error("Error broadcasting work share")
+	}
+	s.logger.WithFields(log.Fields{
+		"worker": name,
+		"number": header.NumberU64(),
+		"hash":   header.Hash(),
+	}).Debug("Stratum miner found a work share")
+	return false, nil
+}
+
+// submitBlock assembles the block of a share that meets the difficulty of the
+// header and broadcasts it
+func (s *StratumServer) submitBlock(name string, job *stratumJob, header *types.WorkObjectHeader) error {
+	block, err := s.core.ConstructLocalMinedBlock(types.NewWorkObject(header, job.header.Body(), nil))
+	if err != nil {
+		return err
+	}
+	location := s.core.NodeLocation()
+	if err := s.backend.BroadcastBlock(block, location); err != nil {
+		s.logger.WithField("err", err).Error("Error broadcasting block")
+	}
+	if err := s.backend.BroadcastHeader(block, location); err != nil {
+		s.logger.WithField("err", err).Error("Error broadcasting header")
+	}
+	s.logger.WithFields(log.Fields{
+		"worker": name,
+		"number": block.NumberU64(common.ZONE_CTX),
+		"hash":   block.Hash(),
+	}).Info("Stratum miner found a block")
+	return nil
+}
+
+func newStratumJob(id string, header *types.WorkObject, sealHash common.Hash) *stratumJob {
+	// The workshare threshold is a number of bits below the difficulty, see
+	// CheckIfValidWorkShare in the consensus engines
+	difficulty := header.Difficulty()
+	work := new(big.Int).Set(difficulty)
+	if bits := difficulty.BitLen() - 1; bits > params.WorkSharesThresholdDiff {
+		work = new(big.Int).Lsh(common.Big1, uint(bits-params.WorkSharesThresholdDiff))
+	}
+	return &stratumJob{
+		id:       id,
+		header:   header,
+		sealHash: sealHash,
+		target:   consensus.DifficultyToTarget(work),
+		work:     work,
+		nonces:   make(map[types.BlockNonce]struct{}),
+	}
+}
+
+// workObjectHeader returns a new header of the job with the given nonce
+func (job *stratumJob) workObjectHeader(nonce types.BlockNonce) *types.WorkObjectHeader {
+	wh := job.header.WorkObjectHeader()
+	return types.NewWorkObjectHeader(wh.HeaderHash(), wh.ParentHash(), wh.Number(), wh.Difficulty(), wh.TxHash(), nonce, wh.Time(), wh.Location())
+}
+
+// addNonce records a submitted nonce and returns false if it was submitted before
+func (job *stratumJob) addNonce(nonce types.BlockNonce) bool {
+	job.lock.Lock()
+	defer job.lock.Unlock()
+	if _, ok := job.nonces[nonce]; ok {
+		return false
+	}
+	job.nonces[nonce] = struct{}{}
+	return true
+}
+
+// notifyParams returns the mining.notify parameters of the job in the format
+// of the consensus engine
+func (job *stratumJob) notifyParams(progpow bool, clean bool) []interface{} {
+	if progpow {
+		return []interface{}{job.id, hex.EncodeToString(job.sealHash.Bytes()), job.header.NumberU64(common.ZONE_CTX), clean}
+	}
+	return []interface{}{job.id, hex.EncodeToString(job.sealHash.Bytes()), clean}
+}
+
+// hashrate returns the hashes per second of the worker over the hashrate window
+func (worker *stratumWorker) hashrate(now time.Time) uint64 {
+	work := new(big.Int)
+	for _, share := range worker.shares {
+		if now.Sub(share.time) <= c_stratumHashrateWindow {
+			work.Add(work, share.work)
+		}
+	}
+	window := c_stratumHashrateWindow
+	if elapsed := now.Sub(worker.since); elapsed < window {
+		window = elapsed
+	}
+	if window < time.Second {
+		window = time.Second
+	}
+	return work.Div(work, big.NewInt(int64(window/time.Second))).Uint64()
+}
+
+// pruneShares drops the shares that fell out of the hashrate window
+func (worker *stratumWorker) pruneShares(now time.Time) {
+	i := 0
+	for i < len(worker.shares) && now.Sub(worker.shares[i].time) > c_stratumHashrateWindow {
+		i++
+	}
+	worker.shares = worker.shares[i:]
+}
+
+// serve reads and answers the requests of the miner until the connection closes
+func (session *stratumSession) serve() {
+	s := session.server
+	defer s.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.WithFields(log.Fields{
+				"error":      r,
+				"stacktrace": string(debug.Stack()),
+			}).Fatal("Go-Quai Panicked")
+		}
+	}()
+	defer s.dropSession(session)
+	defer session.conn.Close()
+
+	s.logger.WithField("addr", session.conn.RemoteAddr()).Debug("Stratum miner connected")
+	scanner := bufio.NewScanner(session.conn)
+	scanner.Buffer(make([]byte, 0, c_stratumMaxRequestSize), c_stratumMaxRequestSize)
+	for {
+		session.conn.SetReadDeadline(time.Now().Add(c_stratumReadTimeout))
+		if !scanner.Scan() {
+			break
+		}
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var req stratumRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.logger.WithFields(log.Fields{
+				"addr": session.conn.RemoteAddr(),
+				"err":  err,
+			}).Debug("Invalid stratum request")
+			return
+		}
+		if err := session.handle(&req); err != nil {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && !s.stopped() {
+		s.logger.WithFields(log.Fields{
+			"addr": session.conn.RemoteAddr(),
+			"err":  err,
+		}).Debug("Stratum miner disconnected")
+	}
+}
+
+// handle answers a single request. It only returns an error if the response
+// could not be written.
+func (session *stratumSession) handle(req *stratumRequest) error {
+	s := session.server
+	switch req.Method {
+	case "mining.subscribe":
+		session.lock.Lock()
+		session.subscribed = true
+		session.lock.Unlock()
+		result := []interface{}{
+			[]string{"mining.notify", session.id, "EthereumStratum/1.0.0"},
+			hex.EncodeToString(session.extranonce),
+		}
+		return session.respond(req.ID, result, nil)
+
+	case "mining.extranonce.subscribe":
+		return session.respond(req.ID, true, nil)
+
+	case "mining.authorize":
+		var name string
+		if len(req.Params) == 0 || json.Unmarshal(req.Params[0], &name) != nil || name == "" || len(name) > c_stratumMaxWorkerName {
+			return session.respond(req.ID, nil, errStratumInvalidParams)
+		}
+		if !session.authorize(name) {
+			return session.respond(req.ID, nil, errStratumNotSubscribed)
+		}
+		if err := session.respond(req.ID, true, nil); err != nil {
+			return err
+		}
+		if job := s.currentJob(); job != nil {
+			return session.sendJob(job, true)
+		}
+		return nil
+
+	case "mining.submit":
+		if err := s.submit(session, req.Params); err != nil {
+			return session.respond(req.ID, nil, err)
+		}
+		return session.respond(req.ID, true, nil)
+
+	default:
+		return session.respond(req.ID, nil, errStratumUnknownMethod)
+	}
+}
+
+// authorize adds a worker to the session. It returns false if the miner did
+// not subscribe first.
+func (session *stratumSession) authorize(name string) bool {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	if !session.subscribed {
+		return false
+	}
+	if _, ok := session.workers[name]; !ok {
+		session.workers[name] = struct{}{}
+		session.server.authorizeWorker(name)
+	}
+	return true
+}
+
+func (session *stratumSession) authorized(name string) bool {
+	session.lock.Lock()
+	defer session.lock.Unlock()
+	_, ok := session.workers[name]
+	return ok
+}
+
+// sendJob sends a job to the miner, preceded by the share target if it changed
+func (session *stratumSession) sendJob(job *stratumJob, clean bool) error {
+	session.lock.Lock()
+	if len(session.workers) == 0 {
+		session.lock.Unlock()
+		return nil
+	}
+	newTarget := session.target == nil || session.target.Cmp(job.target) != 0
+	session.target = job.target
+	session.lock.Unlock()
+
+	if newTarget {
+		target := hex.EncodeToString(common.BigToHash(job.target).Bytes())
+		if err := session.notify("mining.set_target", []interface{}{target}); err != nil {
+			return err
+		}
+	}
+	return session.notify("mining.notify", job.notifyParams(session.server.progpow, clean))
+}
+
+func (session *stratumSession) respond(id json.RawMessage, result interface{}, err *stratumError) error {
+	return session.write(&stratumResponse{ID: id, Result: result, Error: err})
+}
+
+func (session *stratumSession) notify(method string, params []interface{}) error {
+	return session.write(&stratumNotification{Method: method, Params: params})
+}
+
+// write sends a message to the miner and closes the connection on failure
+func (session *stratumSession) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	session.writeLock.Lock()
+	defer session.writeLock.Unlock()
+	session.conn.SetWriteDeadline(time.Now().Add(c_stratumWriteTimeout))
+	if _, err := session.conn.Write(append(data, '\n')); err != nil {
+		session.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// decodeStratumHex decodes a hex string parameter of the given byte length,
+// with or without the 0x prefix
+func decodeStratumHex(raw json.RawMessage, length int) ([]byte, error) {
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		return nil, err
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(str, "0x"), "0X"))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != length {
+		return nil, errors.New("invalid length")
+	}
+	return data, nil
+}
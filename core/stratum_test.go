@@ -0,0 +1,259 @@
+package core
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/consensus"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+var (
+	errTestSeal      = errors.New("invalid seal")
+	errTestConstruct = errors.New("failed to construct block")
+)
+
+// testStratumCore assembles the mined blocks of a zone, the other methods of
+// stratumCore panic
+type testStratumCore struct {
+	stratumCore
+	config    *params.ChainConfig
+	engine    *testStratumEngine
+	construct error
+}
+
+func (c *testStratumCore) Config() *params.ChainConfig   { return c.config }
+func (c *testStratumCore) Engine() consensus.Engine      { return c.engine }
+func (c *testStratumCore) NodeLocation() common.Location { return common.Location{0, 0} }
+func (c *testStratumCore) ConstructLocalMinedBlock(wo *types.WorkObject) (*types.WorkObject, error) {
+	if c.construct != nil {
+		return nil, c.construct
+	}
+	return wo, nil
+}
+
+// testStratumEngine accepts the nonces of the blocks as seals, and the nonces
+// of both the blocks and the workshares as workshares
+type testStratumEngine struct {
+	consensus.Engine
+	blocks map[types.BlockNonce]bool
+	shares map[types.BlockNonce]bool
+}
+
+func (e *testStratumEngine) VerifySeal(header *types.WorkObjectHeader) (common.Hash, error) {
+	if e.blocks[header.Nonce()] {
+		return common.Hash{}, nil
+	}
+	return common.Hash{}, errTestSeal
+}
+
+func (e *testStratumEngine) CheckIfValidWorkShare(header *types.WorkObjectHeader) bool {
+	return e.blocks[header.Nonce()] || e.shares[header.Nonce()]
+}
+
+// testStratumBackend counts the broadcast blocks, headers and workshares
+type testStratumBackend struct {
+	blocks, headers, shares int
+}
+
+func (b *testStratumBackend) BroadcastBlock(*types.WorkObject, common.Location) error {
+	b.blocks++
+	return nil
+}
+
+func (b *testStratumBackend) BroadcastHeader(*types.WorkObject, common.Location) error {
+	b.headers++
+	return nil
+}
+
+func (b *testStratumBackend) BroadcastWorkShare(*types.WorkObjectHeader, common.Location) error {
+	b.shares++
+	return nil
+}
+
+// newTestStratumServer returns a server with a single job, along with a
+// session of a miner authorized as "worker"
+func newTestStratumServer(t *testing.T, engine string) (*StratumServer, *stratumSession, *testStratumCore, *testStratumBackend) {
+	t.Helper()
+	core := &testStratumCore{
+		config: &params.ChainConfig{ConsensusEngine: engine},
+		engine: &testStratumEngine{
+			blocks: make(map[types.BlockNonce]bool),
+			shares: make(map[types.BlockNonce]bool),
+		},
+	}
+	backend := new(testStratumBackend)
+	s := newStratumServer("127.0.0.1:0", core, backend, log.Global)
+
+	header := types.EmptyHeader(common.ZONE_CTX)
+	header.WorkObjectHeader().SetLocation(common.Location{0, 0})
+	header.WorkObjectHeader().SetNumber(big.NewInt(1))
+	header.WorkObjectHeader().SetDifficulty(big.NewInt(1 << 20))
+	header.WorkObjectHeader().SetHeaderHash(header.Header().Hash())
+	s.newJob(header)
+
+	session := s.newSession(nil)
+	session.subscribed = true
+	if !session.authorize("worker") {
+		t.Fatalf("failed to authorize the worker")
+	}
+	return s, session, core, backend
+}
+
+// testNonce returns a nonce in the search space of the given extranonce
+func testNonce(extranonce []byte, n uint64) types.BlockNonce {
+	return types.EncodeNonce(uint64(binary.BigEndian.Uint16(extranonce))<<48 | n)
+}
+
+func testShareParams(jobID string, nonce types.BlockNonce, sealHash common.Hash) []json.RawMessage {
+	params := []json.RawMessage{}
+	for _, param := range []string{"worker", jobID, hex.EncodeToString(nonce.Bytes()), hex.EncodeToString(sealHash.Bytes()), hex.EncodeToString(common.Hash{0x01}.Bytes())} {
+		raw, _ := json.Marshal(param)
+		params = append(params, raw)
+	}
+	return params
+}
+
+func TestStratumDecodeShare(t *testing.T) {
+	s, session, _, _ := newTestStratumServer(t, "progpow")
+	job := s.currentJob()
+	other := s.newSession(nil)
+
+	tests := []struct {
+		jobID    string
+		nonce    types.BlockNonce
+		sealHash common.Hash
+		err      *stratumError
+	}{
+		{job.id, testNonce(session.extranonce, 1), job.sealHash, nil},
+		{"ff", testNonce(session.extranonce, 2), job.sealHash, errStratumJobNotFound},
+		// The nonce belongs to the search space of another miner
+		{job.id, testNonce(other.extranonce, 3), job.sealHash, errStratumInvalidNonce},
+		{job.id, testNonce(session.extranonce, 4), common.Hash{0x02}, errStratumInvalidHeader},
+		// A nonce is accepted once per job
+		{job.id, testNonce(session.extranonce, 1), job.sealHash, errStratumDuplicateShare},
+		{job.id, testNonce(session.extranonce, 5), job.sealHash, nil},
+	}
+	for i, test := range tests {
+		decoded, header, err := s.decodeShare(session, test.jobID, testShareParams(test.jobID, test.nonce, test.sealHash))
+		if err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if decoded != job {
+			t.Errorf("test %d: job mismatch: have %s, want %s", i, decoded.id, job.id)
+		}
+		if header.Nonce() != test.nonce {
+			t.Errorf("test %d: nonce mismatch: have %x, want %x", i, header.Nonce(), test.nonce)
+		}
+		if header.SealHash() != job.sealHash {
+			t.Errorf("test %d: seal hash mismatch: have %x, want %x", i, header.SealHash(), job.sealHash)
+		}
+		if header.MixHash() != (common.Hash{0x01}) {
+			t.Errorf("test %d: mix hash mismatch: have %x", i, header.MixHash())
+		}
+	}
+}
+
+func TestStratumSubmitShare(t *testing.T) {
+	s, session, core, backend := newTestStratumServer(t, "blake3")
+	job := s.currentJob()
+	block, share, low, failed := testNonce(session.extranonce, 1), testNonce(session.extranonce, 2), testNonce(session.extranonce, 3), testNonce(session.extranonce, 4)
+	core.engine.blocks[block] = true
+	core.engine.blocks[failed] = true
+	core.engine.shares[share] = true
+
+	tests := []struct {
+		nonce     types.BlockNonce
+		construct error
+		err       *stratumError
+		block     bool
+		broadcast testStratumBackend
+	}{
+		// A share meeting the difficulty of the header is broadcast as a block
+		{block, nil, nil, true, testStratumBackend{blocks: 1, headers: 1}},
+		{share, nil, nil, false, testStratumBackend{shares: 1}},
+		{low, nil, errStratumLowDifficulty, false, testStratumBackend{}},
+		// The work of a block which fails to be assembled still counts as a
+		// workshare
+		{failed, errTestConstruct, nil, false, testStratumBackend{shares: 1}},
+	}
+	var blocks, accepted, rejected uint64
+	for i, test := range tests {
+		*backend = testStratumBackend{}
+		core.construct = test.construct
+
+		err := s.submit(session, testShareParams(job.id, test.nonce, job.sealHash))
+		if err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+		if *backend != test.broadcast {
+			t.Errorf("test %d: broadcasts mismatch: have %+v, want %+v", i, *backend, test.broadcast)
+		}
+		switch {
+		case err != nil:
+			rejected++
+		case test.block:
+			blocks++
+			accepted++
+		default:
+			accepted++
+		}
+		worker := s.workers["worker"]
+		if worker.accepted != accepted || worker.blocks != blocks || worker.rejected != rejected {
+			t.Errorf("test %d: worker stats mismatch: have %d/%d/%d, want %d/%d/%d", i, worker.accepted, worker.blocks, worker.rejected, accepted, blocks, rejected)
+		}
+		if len(worker.shares) != int(accepted) {
+			t.Errorf("test %d: recorded shares mismatch: have %d, want %d", i, len(worker.shares), accepted)
+		}
+	}
+}
+
+func TestStratumWorkerHashrate(t *testing.T) {
+	now := time.Now()
+	work := big.NewInt(600)
+
+	tests := []struct {
+		since    time.Duration // connection time before now
+		shares   []time.Duration
+		hashrate uint64
+		kept     int
+	}{
+		// The shares are averaged over the hashrate window
+		{20 * time.Minute, []time.Duration{5 * time.Minute, time.Minute}, 2, 2},
+		// The shares which fell out of the window are dropped
+		{20 * time.Minute, []time.Duration{15 * time.Minute, 11 * time.Minute, 5 * time.Minute}, 1, 1},
+		{20 * time.Minute, []time.Duration{15 * time.Minute}, 0, 0},
+		// A worker connected for less than the window is averaged over the
+		// time since it connected, and over a second at least
+		{time.Minute, []time.Duration{30 * time.Second}, 10, 1},
+		{0, []time.Duration{0, 0}, 1200, 2},
+	}
+	for i, test := range tests {
+		worker := &stratumWorker{since: now.Add(-test.since)}
+		for _, age := range test.shares {
+			worker.shares = append(worker.shares, stratumShare{time: now.Add(-age), work: work})
+		}
+		if hashrate := worker.hashrate(now); hashrate != test.hashrate {
+			t.Errorf("test %d: hashrate mismatch: have %d, want %d", i, hashrate, test.hashrate)
+		}
+		worker.pruneShares(now)
+		if len(worker.shares) != test.kept {
+			t.Errorf("test %d: kept shares mismatch: have %d, want %d", i, len(worker.shares), test.kept)
+		}
+		if hashrate := worker.hashrate(now); hashrate != test.hashrate {
+			t.Errorf("test %d: pruned hashrate mismatch: have %d, want %d", i, hashrate, test.hashrate)
+		}
+	}
+}
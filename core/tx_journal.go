@@ -20,6 +20,7 @@ import (
 	"errors"
 	"io"
 	"os"
+	"sync"
 
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/core/types"
@@ -45,6 +46,7 @@ func (*devNull) Close() error                      { return nil }
 type txJournal struct {
 	path   string         // Filesystem path to store the transactions at
 	writer io.WriteCloser // Output stream to write new transactions into
+	mu     sync.Mutex     // Guards the writer, Qi transactions are journaled outside of the pool lock
 	logger *log.Logger
 }
 
@@ -71,8 +73,14 @@ func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
 	defer input.Close()
 
 	// Temporarily discard any journal additions (don't double add on load)
+	journal.mu.Lock()
 	journal.writer = new(devNull)
-	defer func() { journal.writer = nil }()
+	journal.mu.Unlock()
+	defer func() {
+		journal.mu.Lock()
+		journal.writer = nil
+		journal.mu.Unlock()
+	}()
 
 	// Inject all transactions from the journal into the pool
 	stream := rlp.NewStream(input, 0)
@@ -123,6 +131,9 @@ func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
 
 // insert adds the specified transaction to the local disk journal.
 func (journal *txJournal) insert(tx *types.Transaction) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
 	if journal.writer == nil {
 		return errNoActiveJournal
 	}
@@ -133,8 +144,11 @@ func (journal *txJournal) insert(tx *types.Transaction) error {
 }
 
 // rotate regenerates the transaction journal based on the current contents of
-// the transaction pool.
-func (journal *txJournal) rotate(all map[common.InternalAddress]types.Transactions) error {
+// the transaction pool, including the local Qi transactions.
+func (journal *txJournal) rotate(all map[common.InternalAddress]types.Transactions, qiTxs types.Transactions) error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
 	// Close the current journal (if any is open)
 	if journal.writer != nil {
 		if err := journal.writer.Close(); err != nil {
@@ -157,6 +171,13 @@ func (journal *txJournal) rotate(all map[common.InternalAddress]types.Transactio
 		}
 		journaled += len(txs)
 	}
+	for _, tx := range qiTxs {
+		if err = rlp.Encode(replacement, tx); err != nil {
+			replacement.Close()
+			return err
+		}
+	}
+	journaled += len(qiTxs)
 	replacement.Close()
 
 	// Replace the live journal with the newly generated one
@@ -171,6 +192,7 @@ func (journal *txJournal) rotate(all map[common.InternalAddress]types.Transactio
 	journal.logger.WithFields(log.Fields{
 		"transactions": journaled,
 		"accounts":     len(all),
+		"qi":           len(qiTxs),
 	}).Info("Regenerated local transaction journal")
 
 	return nil
@@ -178,6 +200,9 @@ func (journal *txJournal) rotate(all map[common.InternalAddress]types.Transactio
 
 // close flushes the transaction journal contents to disk and closes the file.
 func (journal *txJournal) close() error {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
 	var err error
 
 	if journal.writer != nil {
@@ -17,6 +17,7 @@
 package core
 
 import (
+	"bytes"
 	"container/heap"
 	"math"
 	"math/big"
@@ -620,3 +621,109 @@ func (l *txPricedList) SetBaseFee(baseFee *big.Int) {
 	l.urgent.baseFee = baseFee
 	l.Reheap()
 }
+
+// qiFeeRateCmp compares the fee per byte paid by two Qi transactions, returning
+// -1, 0 or 1 if the rate of a is lower, equal or higher than the rate of b.
+func qiFeeRateCmp(a *types.Transaction, aFee *big.Int, b *types.Transaction, bFee *big.Int) int {
+	// Cross multiply to avoid losing precision on the division by size
+	aRate := new(big.Int).Mul(aFee, new(big.Int).SetUint64(uint64(b.Size())))
+	bRate := new(big.Int).Mul(bFee, new(big.Int).SetUint64(uint64(a.Size())))
+	return aRate.Cmp(bRate)
+}
+
+// qiPricedTx is a Qi transaction tracked by the qiPricedList.
+type qiPricedTx struct {
+	tx    *types.Transaction
+	fee   *big.Int
+	index int // Position in the heap, maintained by the heap.Interface methods
+}
+
+// qiPriceHeap is a heap.Interface implementation over Qi transactions for
+// retrieving the transactions paying the lowest fee per byte.
+type qiPriceHeap []*qiPricedTx
+
+func (h qiPriceHeap) Len() int { return len(h) }
+func (h qiPriceHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h qiPriceHeap) Less(i, j int) bool {
+	switch qiFeeRateCmp(h[i].tx, h[i].fee, h[j].tx, h[j].fee) {
+	case -1:
+		return true
+	case 1:
+		return false
+	default:
+		// Break ties on the hash to keep eviction deterministic
+		return bytes.Compare(h[i].tx.Hash().Bytes(), h[j].tx.Hash().Bytes()) < 0
+	}
+}
+
+func (h *qiPriceHeap) Push(x interface{}) {
+	item := x.(*qiPricedTx)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *qiPriceHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[0 : n-1]
+	return x
+}
+
+// qiPricedList is a fee per byte sorted heap of the remote Qi pool contents,
+// used to pick the cheapest transactions to evict when the Qi pool fills up.
+type qiPricedList struct {
+	items map[common.Hash]*qiPricedTx
+	heap  qiPriceHeap
+}
+
+// newQiPricedList creates an empty Qi price-sorted transaction list.
+func newQiPricedList() *qiPricedList {
+	return &qiPricedList{
+		items: make(map[common.Hash]*qiPricedTx),
+	}
+}
+
+// Put inserts a new Qi transaction paying the given fee into the heap.
+func (l *qiPricedList) Put(tx *types.Transaction, fee *big.Int) {
+	if _, ok := l.items[tx.Hash()]; ok {
+		return
+	}
+	item := &qiPricedTx{tx: tx, fee: fee}
+	l.items[tx.Hash()] = item
+	heap.Push(&l.heap, item)
+}
+
+// Remove drops the transaction with the given hash from the heap.
+func (l *qiPricedList) Remove(hash common.Hash) {
+	item, ok := l.items[hash]
+	if !ok {
+		return
+	}
+	delete(l.items, hash)
+	heap.Remove(&l.heap, item.index)
+}
+
+// Cheapest returns the transaction paying the lowest fee per byte, or nil if
+// the list is empty.
+func (l *qiPricedList) Cheapest() *types.Transaction {
+	if len(l.heap) == 0 {
+		return nil
+	}
+	return l.heap[0].tx
+}
+
+// Underpriced checks whether a transaction paying the given fee pays no more
+// per byte than the cheapest transaction in the list.
+func (l *qiPricedList) Underpriced(tx *types.Transaction, fee *big.Int) bool {
+	if len(l.heap) == 0 {
+		return false
+	}
+	return qiFeeRateCmp(tx, fee, l.heap[0].tx, l.heap[0].fee) <= 0
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+)
+
+func TestQiPricedList(t *testing.T) {
+	key := newQiTestKey(t)
+	prevOut := types.OutPoint{TxHash: common.Hash{0x01}, Index: 0}
+	var (
+		low  = newTestQiTx(t, key, prevOut, 1)
+		mid  = newTestQiTx(t, key, prevOut, 1)
+		high = newTestQiTx(t, key, prevOut, 1)
+		// Pays the fee of mid with a larger transaction
+		large = newTestQiTx(t, key, prevOut, 1, 1, 1, 1)
+	)
+	l := newQiPricedList()
+	if l.Cheapest() != nil || l.Underpriced(low, big.NewInt(1)) {
+		t.Fatal("empty list priced a transaction")
+	}
+	l.Put(high, big.NewInt(300))
+	l.Put(mid, big.NewInt(200))
+	l.Put(large, big.NewInt(200))
+	l.Put(low, big.NewInt(100))
+	l.Put(low, big.NewInt(1000)) // already tracked, ignored
+
+	for i, want := range []*types.Transaction{low, large, mid, high} {
+		cheapest := l.Cheapest()
+		if cheapest != want {
+			t.Fatalf("step %d: cheapest mismatch: have %x, want %x", i, cheapest.Hash(), want.Hash())
+		}
+		l.Remove(cheapest.Hash())
+		l.Remove(cheapest.Hash()) // already removed, ignored
+	}
+	if l.Cheapest() != nil || len(l.items) != 0 {
+		t.Fatal("list not empty after removing all transactions")
+	}
+
+	// Removing from the middle of the heap keeps it ordered
+	l.Put(high, big.NewInt(300))
+	l.Put(mid, big.NewInt(200))
+	l.Put(low, big.NewInt(100))
+	l.Remove(low.Hash())
+	if cheapest := l.Cheapest(); cheapest != mid {
+		t.Fatalf("cheapest mismatch: have %x, want %x", cheapest.Hash(), mid.Hash())
+	}
+	// Only a transaction paying more per byte than the cheapest is not
+	// underpriced
+	if !l.Underpriced(low, big.NewInt(200)) {
+		t.Error("transaction paying the cheapest rate not underpriced")
+	}
+	if l.Underpriced(low, big.NewInt(201)) {
+		t.Error("transaction paying above the cheapest rate underpriced")
+	}
+	if !l.Underpriced(large, big.NewInt(201)) {
+		t.Error("larger transaction paying below the cheapest rate not underpriced")
+	}
+}
+
+func TestQiPricedListTies(t *testing.T) {
+	key := newQiTestKey(t)
+	prevOut := types.OutPoint{TxHash: common.Hash{0x01}, Index: 0}
+	a, b := newTestQiTx(t, key, prevOut, 1), newTestQiTx(t, key, prevOut, 1)
+	if bytes.Compare(a.Hash().Bytes(), b.Hash().Bytes()) > 0 {
+		a, b = b, a
+	}
+	// Equal rates are ordered by hash whatever the insertion order
+	for _, order := range [][]*types.Transaction{{a, b}, {b, a}} {
+		l := newQiPricedList()
+		for _, tx := range order {
+			l.Put(tx, big.NewInt(100))
+		}
+		if cheapest := l.Cheapest(); cheapest != a {
+			t.Errorf("cheapest mismatch: have %x, want %x", cheapest.Hash(), a.Hash())
+		}
+	}
+}
@@ -59,6 +59,11 @@ const (
 	// c_reorgCounterThreshold determines the frequency of the timing prints
 	// around important functions in txpool
 	c_reorgCounterThreshold = 200
+
+	// c_qiIncludedLocalsCacheSize is the number of local Qi transactions
+	// included in blocks that are remembered, so they are re-added as local
+	// if they are reorged out
+	c_qiIncludedLocalsCacheSize = 4096
 )
 
 var (
@@ -128,6 +133,10 @@ var (
 	slotsGauge     = txpoolMetrics.WithLabelValues("slots")
 	qiTxGauge      = txpoolMetrics.WithLabelValues("qi")
 
+	// Qi pool metrics
+	qiReplaceMeter  = txpoolMetrics.WithLabelValues("qi:replace")  // Double spends replaced by a higher fee
+	qiEvictionMeter = txpoolMetrics.WithLabelValues("qi:eviction") // Dropped to make room for better paying txs
+
 	reheapTimer = metrics_config.NewTimer("Reheap", "Reheap timer")
 )
 
@@ -282,23 +291,28 @@ func (config *TxPoolConfig) sanitize(logger *log.Logger) TxPoolConfig {
 // current state) and future transactions. Transactions move between those
 // two states over time as they are received and processed.
 type TxPool struct {
-	config      TxPoolConfig
-	chainconfig *params.ChainConfig
-	chain       blockChain
-	gasPrice    *big.Int
-	txFeed      event.Feed
-	scope       event.SubscriptionScope
-	signer      types.Signer
-	mu          sync.RWMutex
-	qiMu        sync.RWMutex
-
-	currentState  *state.StateDB // Current state in the blockchain head
-	pendingNonces *txNoncer      // Pending state tracking virtual nonces
-	currentMaxGas uint64         // Current gas limit for transaction caps
+	config        TxPoolConfig
+	chainconfig   *params.ChainConfig
+	chain         blockChain
+	gasPrice      *big.Int
+	txFeed        event.Feed
+	missingTxFeed event.Feed
+	scope         event.SubscriptionScope
+	signer        types.Signer
+	mu            sync.RWMutex
+	qiMu          sync.RWMutex
+
+	currentState  *state.StateDB  // Current state in the blockchain head
+	pendingNonces *txNoncer       // Pending state tracking virtual nonces
+	currentMaxGas uint64          // Current gas limit for transaction caps
+	gasTable      params.GasTable // Gas prices of the block on top of the head
 
 	locals         *accountSet                                     // Set of local transaction to exempt from eviction rules
 	journal        *txJournal                                      // Journal of local transaction to back up to disk
 	qiPool         map[common.Hash]*types.TxWithMinerFee           // Qi pool to store Qi transactions
+	qiSpenders     map[types.OutPoint]common.Hash                  // Outpoint to hash of the pooled Qi tx spending it
+	qiPriced       *qiPricedList                                   // Remote Qi transactions sorted by fee per byte
+	qiLocals       map[common.Hash]struct{}                        // Hashes of the locally submitted Qi transactions
 	pending        map[common.InternalAddress]*txList              // All currently processable transactions
 	queue          map[common.InternalAddress]*txList              // Queued but non-processable transactions
 	beats          map[common.InternalAddress]time.Time            // Last heartbeat from each known account
@@ -310,6 +324,8 @@ type TxPool struct {
 	localTxsCount  int                                             // count of txs in last 1 min. Purely for logging purpose
 	remoteTxsCount int                                             // count of txs in last 1 min. Purely for logging purpose
 
+	qiIncludedLocals *lru.Cache[common.Hash, struct{}] // Hashes of the local Qi transactions included in recent blocks
+
 	reOrgCounter int // keeps track of the number of times the runReorg is called, it is reset every c_reorgCounterThreshold times
 
 	chainHeadCh     chan ChainHeadEvent
@@ -371,6 +387,9 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		signer:          types.LatestSigner(chainconfig),
 		pending:         make(map[common.InternalAddress]*txList),
 		qiPool:          make(map[common.Hash]*types.TxWithMinerFee),
+		qiSpenders:      make(map[types.OutPoint]common.Hash),
+		qiPriced:        newQiPricedList(),
+		qiLocals:        make(map[common.Hash]struct{}),
 		queue:           make(map[common.InternalAddress]*txList),
 		beats:           make(map[common.InternalAddress]time.Time),
 		sendersCh:       make(chan newSender, config.SendersChBuffer),
@@ -388,6 +407,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		logger:          logger,
 	}
 	pool.senders, _ = lru.New[common.Hash, common.InternalAddress](int(config.MaxSenders))
+	pool.qiIncludedLocals, _ = lru.New[common.Hash, struct{}](c_qiIncludedLocalsCacheSize)
 	pool.locals = newAccountSet(pool.signer)
 	for _, addr := range config.Locals {
 		logger.WithField("address", addr).Debug("Setting new local account")
@@ -409,7 +429,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		if err := pool.journal.load(pool.AddLocals); err != nil {
 			logger.WithField("err", err).Warn("Failed to load transaction journal")
 		}
-		if err := pool.journal.rotate(pool.local()); err != nil {
+		if err := pool.journal.rotate(pool.local(), pool.localQi()); err != nil {
 			logger.WithField("err", err).Warn("Failed to rotate transaction journal")
 		}
 	}
@@ -500,7 +520,7 @@ func (pool *TxPool) loop() {
 		case <-journal.C:
 			if pool.journal != nil {
 				pool.mu.Lock()
-				if err := pool.journal.rotate(pool.local()); err != nil {
+				if err := pool.journal.rotate(pool.local(), pool.localQi()); err != nil {
 					pool.logger.WithField("err", err).Warn("Failed to rotate local tx journal")
 				}
 				pool.mu.Unlock()
@@ -530,6 +550,12 @@ func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscripti
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeMissingTxsEvent registers a subscription of MissingTxsEvent and
+// starts sending event to the given channel.
+func (pool *TxPool) SubscribeMissingTxsEvent(ch chan<- MissingTxsEvent) event.Subscription {
+	return pool.scope.Track(pool.missingTxFeed.Subscribe(ch))
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -697,6 +723,20 @@ func (pool *TxPool) local() map[common.InternalAddress]types.Transactions {
 	return txs
 }
 
+// localQi retrieves all currently pooled local Qi transactions.
+func (pool *TxPool) localQi() types.Transactions {
+	pool.qiMu.RLock()
+	defer pool.qiMu.RUnlock()
+
+	txs := make(types.Transactions, 0, len(pool.qiLocals))
+	for hash := range pool.qiLocals {
+		if pooled, ok := pool.qiPool[hash]; ok {
+			txs = append(txs, pooled.Tx())
+		}
+	}
+	return txs
+}
+
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction) error {
@@ -761,7 +801,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction) error {
 		return ErrInsufficientFunds
 	}
 	// Ensure the transaction has more gas than the basic tx fee.
-	intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil)
+	intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, pool.gasTable)
 	if err != nil {
 		return err
 	}
@@ -953,6 +993,17 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 	return old != nil, nil
 }
 
+// journalQiTx adds the specified local Qi transaction to the local disk journal
+// if journaling is enabled.
+func (pool *TxPool) journalQiTx(tx *types.Transaction) {
+	if pool.journal == nil {
+		return
+	}
+	if err := pool.journal.insert(tx); err != nil {
+		pool.logger.WithField("err", err).Warn("Failed to journal local Qi transaction")
+	}
+}
+
 // journalTx adds the specified transaction to the local disk journal if it is
 // deemed to have been sent from a local account.
 func (pool *TxPool) journalTx(from common.InternalAddress, tx *types.Transaction) {
@@ -1114,9 +1165,18 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 	}
 	if len(qiNews) > 0 {
 		pool.qiMu.Lock()
-		qiErrs := pool.addQiTxsLocked(qiNews)
+		qiErrs := pool.addQiTxsLocked(qiNews, local)
+		var unknown []common.Hash
+		if !local {
+			unknown = pool.unknownQiInputsLocked(qiNews)
+		}
 		pool.qiMu.Unlock()
 		errs = append(errs, qiErrs...)
+		// Ask for the transactions creating the outputs spent by the rejected
+		// remote transactions, which the node may have never received
+		if len(unknown) > 0 {
+			pool.missingTxFeed.Send(MissingTxsEvent{unknown})
+		}
 	}
 	if len(news) == 0 {
 		return errs
@@ -1140,9 +1200,11 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 	return errs
 }
 
-// addQiTx adds Qi transactions to the Qi pool.
+// addQiTx adds Qi transactions to the Qi pool. Local transactions are also
+// written to the journal. Journaled transactions whose inputs were spent in
+// the meantime fail the input validation and are dropped on replay.
 // The qiMu lock must be held by the caller.
-func (pool *TxPool) addQiTxsLocked(txs types.Transactions) []error {
+func (pool *TxPool) addQiTxsLocked(txs types.Transactions, local bool) []error {
 	errs := make([]error, 0)
 	currentBlock := pool.chain.CurrentBlock()
 	etxRLimit := len(currentBlock.Transactions()) / params.ETXRegionMaxFraction
@@ -1169,7 +1231,7 @@ func (pool *TxPool) addQiTxsLocked(txs types.Transactions) []error {
 		totalQitIns = append(totalQitIns, totalQitIn)
 	}
 	for i, tx := range transactionsWithoutErrors {
-		fee, err := ValidateQiTxOutputsAndSignature(tx, pool.chain, totalQitIns[i], currentBlock, pool.signer, pool.chainconfig.Location, *pool.chainconfig.ChainID, etxRLimit, etxPLimit)
+		fee, err := ValidateQiTxOutputsAndSignature(tx, pool.chain, totalQitIns[i], currentBlock, pool.signer, pool.chainconfig.Location, *pool.chainconfig.ChainID, pool.gasTable, etxRLimit, etxPLimit)
 		if err != nil {
 			pool.logger.WithFields(logrus.Fields{
 				"tx":  tx.Hash().String(),
@@ -1183,12 +1245,50 @@ func (pool *TxPool) addQiTxsLocked(txs types.Transactions) []error {
 			errs = append(errs, err)
 			continue
 		}
-		if uint64(len(pool.qiPool))+1 > pool.config.QiPoolSize {
-			// If the pool is full, don't accept the transaction
-			errs = append(errs, ErrTxPoolOverflow)
+		// Double spends of pooled outpoints are only accepted as replacements
+		// paying a sufficiently higher fee than everything they conflict with
+		conflicts := pool.qiConflictsLocked(tx)
+		if len(conflicts) > 0 && !pool.qiReplaceable(tx, fee, conflicts) {
+			pool.logger.WithFields(logrus.Fields{
+				"tx":        tx.Hash().String(),
+				"fee":       fee,
+				"conflicts": len(conflicts),
+			}).Debug("Discarding underpriced Qi double spend")
+			errs = append(errs, ErrReplaceUnderpriced)
+			continue
+		}
+		// If the pool is full, only accept a remote transaction if it pays more
+		// per byte than the cheapest pooled remote one
+		if !local && uint64(len(pool.qiPool)-len(conflicts))+1 > pool.config.QiPoolSize && pool.qiPriced.Underpriced(tx, fee) {
+			underpricedTxMeter.Add(1)
+			errs = append(errs, ErrUnderpriced)
 			continue
 		}
+		for _, conflict := range conflicts {
+			pool.removeQiTxLocked(conflict.Tx().Hash())
+			qiReplaceMeter.Add(1)
+		}
+		// Only the remote transactions are priced, the local ones are never
+		// evicted
+		for uint64(len(pool.qiPool))+1 > pool.config.QiPoolSize {
+			cheapest := pool.qiPriced.Cheapest()
+			if cheapest == nil {
+				break
+			}
+			pool.logger.WithField("tx", cheapest.Hash().String()).Debug("Evicting cheapest Qi transaction")
+			pool.removeQiTxLocked(cheapest.Hash())
+			qiEvictionMeter.Add(1)
+		}
 		pool.qiPool[tx.Hash()] = txWithMinerFee
+		for _, txIn := range tx.TxIn() {
+			pool.qiSpenders[txIn.PreviousOutPoint] = tx.Hash()
+		}
+		if local {
+			pool.qiLocals[tx.Hash()] = struct{}{}
+			pool.journalQiTx(tx)
+		} else {
+			pool.qiPriced.Put(tx, fee)
+		}
 		pool.queueTxEvent(tx)
 		select {
 		case pool.sendersCh <- newSender{tx.Hash(), common.InternalAddress{}}: // There is no "sender" for Qi transactions, but the sig is good
@@ -1204,6 +1304,97 @@ func (pool *TxPool) addQiTxsLocked(txs types.Transactions) []error {
 	return errs
 }
 
+// qiConflictsLocked returns the pooled Qi transactions spending any of the
+// outpoints spent by the given transaction.
+// The qiMu lock must be held by the caller.
+func (pool *TxPool) qiConflictsLocked(tx *types.Transaction) []*types.TxWithMinerFee {
+	var conflicts []*types.TxWithMinerFee
+	seen := make(map[common.Hash]struct{})
+	for _, txIn := range tx.TxIn() {
+		spender, ok := pool.qiSpenders[txIn.PreviousOutPoint]
+		if !ok {
+			continue
+		}
+		if _, ok := seen[spender]; ok {
+			continue
+		}
+		seen[spender] = struct{}{}
+		if pooled, ok := pool.qiPool[spender]; ok {
+			conflicts = append(conflicts, pooled)
+		}
+	}
+	return conflicts
+}
+
+// qiReplaceable checks whether a Qi transaction paying the given fee may
+// replace the conflicting pooled transactions. The replacement has to pay at
+// least PriceBump percent more than the conflicts combined, and no less per
+// byte than any of them, so that miners always prefer it.
+func (pool *TxPool) qiReplaceable(tx *types.Transaction, fee *big.Int, conflicts []*types.TxWithMinerFee) bool {
+	replacedFees := new(big.Int)
+	for _, conflict := range conflicts {
+		if qiFeeRateCmp(tx, fee, conflict.Tx(), conflict.MinerFee()) < 0 {
+			return false
+		}
+		replacedFees.Add(replacedFees, conflict.MinerFee())
+	}
+	threshold := new(big.Int).Mul(replacedFees, big.NewInt(100+int64(pool.config.PriceBump)))
+	threshold.Div(threshold, big.NewInt(100))
+	return fee.Cmp(replacedFees) > 0 && fee.Cmp(threshold) >= 0
+}
+
+// unknownQiInputsLocked returns the hashes of the transactions whose outputs
+// are spent by the given Qi transactions that were not pooled, if the outputs
+// are neither in the current state nor created by a pooled transaction.
+// The qiMu lock must be held by the caller.
+func (pool *TxPool) unknownQiInputsLocked(txs types.Transactions) []common.Hash {
+	var (
+		unknown []common.Hash
+		seen    = make(map[common.Hash]struct{})
+	)
+	for _, tx := range txs {
+		if _, ok := pool.qiPool[tx.Hash()]; ok {
+			continue
+		}
+		for _, txIn := range tx.TxIn() {
+			prevOut := txIn.PreviousOutPoint
+			if _, ok := seen[prevOut.TxHash]; ok {
+				continue
+			}
+			if _, ok := pool.qiPool[prevOut.TxHash]; ok {
+				continue
+			}
+			if pool.currentState.GetUTXO(prevOut.TxHash, prevOut.Index) != nil {
+				continue
+			}
+			seen[prevOut.TxHash] = struct{}{}
+			unknown = append(unknown, prevOut.TxHash)
+		}
+	}
+	return unknown
+}
+
+// removeQiTxLocked removes a single Qi transaction from the pool together with
+// its outpoint and price index entries. It reports whether the transaction
+// was pooled.
+// The qiMu lock must be held by the caller.
+func (pool *TxPool) removeQiTxLocked(hash common.Hash) bool {
+	pooled, ok := pool.qiPool[hash]
+	if !ok {
+		return false
+	}
+	delete(pool.qiPool, hash)
+	delete(pool.qiLocals, hash)
+	pool.qiPriced.Remove(hash)
+	for _, txIn := range pooled.Tx().TxIn() {
+		if pool.qiSpenders[txIn.PreviousOutPoint] == hash {
+			delete(pool.qiSpenders, txIn.PreviousOutPoint)
+		}
+	}
+	qiTxGauge.Sub(1)
+	return true
+}
+
 func (pool *TxPool) RemoveQiTx(tx *types.Transaction) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -1214,26 +1405,34 @@ func (pool *TxPool) RemoveQiTx(tx *types.Transaction) {
 		}
 	}()
 	pool.qiMu.Lock()
-	delete(pool.qiPool, tx.Hash())
+	pool.removeQiTxLocked(tx.Hash())
 	pool.qiMu.Unlock()
-	qiTxGauge.Sub(1)
 }
 
 func (pool *TxPool) RemoveQiTxs(txs []*common.Hash) {
 	pool.qiMu.Lock()
 	for _, tx := range txs {
-		delete(pool.qiPool, *tx)
+		pool.removeQiTxLocked(*tx)
 	}
 	pool.qiMu.Unlock()
-	qiTxGauge.Sub(float64(len(txs)))
 }
 
+// removeQiTxsLocked removes Qi transactions included in a block, along with any
+// pooled transaction that double spends one of their inputs. The local ones are
+// remembered so they stay local if the block is reorged out.
 // Mempool lock must be held.
 func (pool *TxPool) removeQiTxsLocked(txs []*types.Transaction) {
 	for _, tx := range txs {
-		delete(pool.qiPool, tx.Hash())
+		if _, local := pool.qiLocals[tx.Hash()]; local {
+			pool.qiIncludedLocals.Add(tx.Hash(), struct{}{})
+		}
+		pool.removeQiTxLocked(tx.Hash())
+		for _, txIn := range tx.TxIn() {
+			if spender, ok := pool.qiSpenders[txIn.PreviousOutPoint]; ok {
+				pool.removeQiTxLocked(spender)
+			}
+		}
 	}
-	qiTxGauge.Sub(float64(len(txs)))
 }
 
 // addTxsLocked attempts to queue a batch of transactions if they are valid.
@@ -1285,9 +1484,18 @@ func (pool *TxPool) Status(hashes []common.Hash) []TxStatus {
 	return status
 }
 
-// Get returns a transaction if it is contained in the pool and nil otherwise.
+// Get returns a transaction if it is contained in the pool or in the Qi pool
+// and nil otherwise.
 func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
-	return pool.all.Get(hash)
+	if tx := pool.all.Get(hash); tx != nil {
+		return tx
+	}
+	pool.qiMu.RLock()
+	defer pool.qiMu.RUnlock()
+	if tx, ok := pool.qiPool[hash]; ok {
+		return tx.Tx()
+	}
+	return nil
 }
 
 // Has returns an indicator whether txpool has a transaction cached with the
@@ -1715,15 +1923,22 @@ func (pool *TxPool) reset(oldHead, newHead *types.WorkObject) {
 	pool.currentState = statedb
 	pool.pendingNonces = newTxNoncer(statedb)
 	pool.currentMaxGas = newHead.GasLimit()
+	pool.gasTable = pool.chainconfig.GasTable(new(big.Int).Add(newHead.Number(nodeCtx), common.Big1), newHead.ExpansionNumber())
 
 	// Inject any transactions discarded due to reorgs
 	pool.logger.WithField("count", len(reinject)).Debug("Reinjecting stale transactions")
 	senderCacher.recover(pool.signer, reinject)
 
-	qiTxs := make([]*types.Transaction, 0)
+	// The local Qi transactions are re-added as local, so they keep being journaled
+	localQiTxs, qiTxs := make([]*types.Transaction, 0), make([]*types.Transaction, 0)
 	for _, tx := range reinject {
 		if tx.Type() == types.QiTxType {
-			qiTxs = append(qiTxs, tx)
+			if pool.qiIncludedLocals.Contains(tx.Hash()) {
+				pool.qiIncludedLocals.Remove(tx.Hash())
+				localQiTxs = append(localQiTxs, tx)
+			} else {
+				qiTxs = append(qiTxs, tx)
+			}
 		}
 	}
 	var wg sync.WaitGroup
@@ -1735,7 +1950,8 @@ func (pool *TxPool) reset(oldHead, newHead *types.WorkObject) {
 	wg.Add(1)
 	go func() {
 		pool.qiMu.Lock()
-		pool.addQiTxsLocked(qiTxs)
+		pool.addQiTxsLocked(localQiTxs, true)
+		pool.addQiTxsLocked(qiTxs, false)
 		pool.qiMu.Unlock()
 		wg.Done()
 	}()
@@ -0,0 +1,276 @@
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/crypto"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+var qiTestLocation = common.Location{0, 0}
+
+// qiTestKey is a key owning Qi outputs in the test zone
+type qiTestKey struct {
+	priv *btcec.PrivateKey
+	pub  []byte
+	addr common.Address
+}
+
+// newQiTestKey returns a key whose address is in the Qi ledger of the test zone
+func newQiTestKey(t testing.TB) *qiTestKey {
+	for {
+		priv, err := btcec.NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub := priv.PubKey().SerializeUncompressed()
+		addr := crypto.PubkeyBytesToAddress(pub, qiTestLocation)
+		if addr.Location().Equal(qiTestLocation) && addr.IsInQiLedgerScope() {
+			return &qiTestKey{priv: priv, pub: pub, addr: addr}
+		}
+	}
+}
+
+// qiTestChain serves the head block the Qi pool validates against, any other
+// call panics
+type qiTestChain struct {
+	blockChain
+	head *types.WorkObject
+}
+
+func (c *qiTestChain) CurrentBlock() *types.WorkObject { return c.head }
+
+// newTestQiPool returns a pool holding at most size Qi transactions, whose
+// state has no output yet. Replacements have to pay 10% more.
+func newTestQiPool(t testing.TB, size uint64) *TxPool {
+	config := *params.TestChainConfig
+	config.Location = qiTestLocation
+
+	head := types.EmptyHeader(common.ZONE_CTX)
+	head.WorkObjectHeader().SetLocation(qiTestLocation)
+	head.Header().SetBaseFee(big.NewInt(params.GWei))
+	head.Header().SetGasLimit(params.GenesisGasLimit)
+
+	db := state.NewDatabase(rawdb.NewMemoryDatabase(log.Global))
+	statedb, err := state.New(types.EmptyRootHash, types.EmptyRootHash, types.EmptyRootHash, db, db, db, nil, qiTestLocation, log.Global)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &TxPool{
+		config:       TxPoolConfig{QiPoolSize: size, PriceBump: 10},
+		chainconfig:  &config,
+		chain:        &qiTestChain{head: head},
+		signer:       types.LatestSigner(&config),
+		currentState: statedb,
+		gasTable:     config.GasTable(head.Number(common.ZONE_CTX), head.ExpansionNumber()),
+		qiPool:       make(map[common.Hash]*types.TxWithMinerFee),
+		qiSpenders:   make(map[types.OutPoint]common.Hash),
+		qiPriced:     newQiPricedList(),
+		qiLocals:     make(map[common.Hash]struct{}),
+		sendersCh:    make(chan newSender, 64),
+		logger:       log.Global,
+	}
+}
+
+// fundQi creates an output of the given denomination owned by the key in the
+// state of the pool
+func fundQi(t testing.TB, pool *TxPool, key *qiTestKey, denomination uint8) types.OutPoint {
+	outpoint := types.OutPoint{TxHash: common.BytesToHash(key.pub[1:33]), Index: 0}
+	if err := pool.currentState.CreateUTXO(outpoint.TxHash, outpoint.Index, types.NewUtxoEntry(types.NewTxOut(denomination, key.addr.Bytes(), big.NewInt(0)))); err != nil {
+		t.Fatal(err)
+	}
+	return outpoint
+}
+
+// newTestQiTx returns a Qi transaction signed by the key, spending its output
+// into outputs of the given denominations sent to fresh addresses
+func newTestQiTx(t testing.TB, key *qiTestKey, prevOut types.OutPoint, denominations ...uint8) *types.Transaction {
+	inner := &types.QiTx{
+		ChainID: params.TestChainConfig.ChainID,
+		TxIn:    types.TxIns{*types.NewTxIn(&prevOut, key.pub, nil)},
+	}
+	for _, denomination := range denominations {
+		inner.TxOut = append(inner.TxOut, *types.NewTxOut(denomination, newQiTestKey(t).addr.Bytes(), big.NewInt(0)))
+	}
+	config := *params.TestChainConfig
+	config.Location = qiTestLocation
+	digest := types.LatestSigner(&config).Hash(types.NewTx(inner))
+	sig, err := schnorr.Sign(key.priv, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner.Signature = sig
+	return types.NewTx(inner)
+}
+
+// addQi adds a single Qi transaction to the pool
+func addQi(pool *TxPool, tx *types.Transaction, local bool) error {
+	if errs := pool.addQiTxsLocked(types.Transactions{tx}, local); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// qiPooled fails the test unless the pool holds exactly the given transactions
+func qiPooled(t *testing.T, pool *TxPool, txs ...*types.Transaction) {
+	t.Helper()
+	if len(pool.qiPool) != len(txs) {
+		t.Errorf("pooled transactions mismatch: have %d, want %d", len(pool.qiPool), len(txs))
+	}
+	for _, tx := range txs {
+		if _, ok := pool.qiPool[tx.Hash()]; !ok {
+			t.Errorf("transaction %x not pooled", tx.Hash())
+		}
+	}
+}
+
+func TestQiReplaceable(t *testing.T) {
+	pool := newTestQiPool(t, 16)
+	key := newQiTestKey(t)
+	prevOut := types.OutPoint{TxHash: common.Hash{0x01}, Index: 0}
+
+	conflict := func(fee int64) *types.TxWithMinerFee {
+		tx, err := types.NewTxWithMinerFee(newTestQiTx(t, key, prevOut, 1), nil, big.NewInt(fee))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	replacement := newTestQiTx(t, key, prevOut, 0)
+	// A larger replacement pays less per byte for the same fee
+	large := newTestQiTx(t, key, prevOut, 0, 0, 0, 0)
+
+	tests := []struct {
+		tx        *types.Transaction
+		fee       int64
+		conflicts []*types.TxWithMinerFee
+		replace   bool
+	}{
+		{replacement, 100, []*types.TxWithMinerFee{conflict(100)}, false},
+		{replacement, 109, []*types.TxWithMinerFee{conflict(100)}, false},
+		{replacement, 110, []*types.TxWithMinerFee{conflict(100)}, true},
+		{replacement, 219, []*types.TxWithMinerFee{conflict(100), conflict(100)}, false},
+		{replacement, 220, []*types.TxWithMinerFee{conflict(100), conflict(100)}, true},
+		// The bump applies to the sum of the replaced fees
+		{replacement, 1100, []*types.TxWithMinerFee{conflict(1000), conflict(1)}, false},
+		{replacement, 1101, []*types.TxWithMinerFee{conflict(1000), conflict(1)}, true},
+		{large, 110, []*types.TxWithMinerFee{conflict(100)}, false},
+	}
+	for i, test := range tests {
+		if have := pool.qiReplaceable(test.tx, big.NewInt(test.fee), test.conflicts); have != test.replace {
+			t.Errorf("test %d: replaceable mismatch: have %v, want %v", i, have, test.replace)
+		}
+	}
+}
+
+func TestQiDoubleSpend(t *testing.T) {
+	pool := newTestQiPool(t, 16)
+	key, other := newQiTestKey(t), newQiTestKey(t)
+	prevOut, otherOut := fundQi(t, pool, key, 6), fundQi(t, pool, other, 6)
+
+	original := newTestQiTx(t, key, prevOut, 5) // pays 250
+	if err := addQi(pool, original, false); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	unrelated := newTestQiTx(t, other, otherOut, 5)
+	if err := addQi(pool, unrelated, false); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	// A double spend paying the same fee is rejected
+	if err := addQi(pool, newTestQiTx(t, key, prevOut, 5), false); err != ErrReplaceUnderpriced {
+		t.Fatalf("double spend error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	qiPooled(t, pool, original, unrelated)
+
+	// A double spend paying enough more replaces the pooled spender
+	replacement := newTestQiTx(t, key, prevOut, 4) // pays 400
+	if err := addQi(pool, replacement, false); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	qiPooled(t, pool, replacement, unrelated)
+	if spender := pool.qiSpenders[prevOut]; spender != replacement.Hash() {
+		t.Errorf("spender mismatch: have %x, want %x", spender, replacement.Hash())
+	}
+	if _, ok := pool.qiPriced.items[original.Hash()]; ok {
+		t.Error("replaced transaction still priced")
+	}
+	// Including the spender of an outpoint drops the pooled double spends
+	pool.removeQiTxsLocked(types.Transactions{newTestQiTx(t, key, prevOut, 3)})
+	qiPooled(t, pool, unrelated)
+	if _, ok := pool.qiSpenders[prevOut]; ok {
+		t.Error("spent outpoint still tracked")
+	}
+}
+
+func TestQiEvictionKeepsLocals(t *testing.T) {
+	pool := newTestQiPool(t, 2)
+	spend := func(fee uint8) *types.Transaction {
+		key := newQiTestKey(t)
+		return newTestQiTx(t, key, fundQi(t, pool, key, 6), fee)
+	}
+	local := spend(5) // pays 250, the cheapest
+	remote := spend(4)
+	for _, add := range []struct {
+		tx    *types.Transaction
+		local bool
+	}{{local, true}, {remote, false}} {
+		if err := addQi(pool, add.tx, add.local); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	// The pool is full, the cheapest remote transaction makes room
+	better := spend(3)
+	if err := addQi(pool, better, false); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	qiPooled(t, pool, local, better)
+
+	// A remote transaction paying no more than the pooled remotes is rejected
+	// even if a cheaper local one is pooled
+	if err := addQi(pool, spend(3), false); err != ErrUnderpriced {
+		t.Fatalf("underpriced error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	// Local transactions are accepted regardless of their fee, and only the
+	// remote ones are evicted for them
+	local2, local3 := spend(5), spend(5)
+	for _, tx := range []*types.Transaction{local2, local3} {
+		if err := addQi(pool, tx, true); err != nil {
+			t.Fatalf("failed to add local transaction: %v", err)
+		}
+	}
+	qiPooled(t, pool, local, local2, local3)
+	if cheapest := pool.qiPriced.Cheapest(); cheapest != nil {
+		t.Errorf("local transaction %x priced", cheapest.Hash())
+	}
+}
+
+func TestQiUnknownInputs(t *testing.T) {
+	pool := newTestQiPool(t, 16)
+	key := newQiTestKey(t)
+	funded := fundQi(t, pool, key, 6)
+	pooled := newTestQiTx(t, key, funded, 5)
+	if err := addQi(pool, pooled, false); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	unknown := types.OutPoint{TxHash: common.Hash{0x02}, Index: 0}
+	orphan := newTestQiTx(t, key, unknown, 5)
+	if err := addQi(pool, orphan, false); err == nil {
+		t.Fatal("transaction spending an unknown output added")
+	}
+	// Only the creator of the output that is neither in the state nor in the
+	// pool is unknown
+	chained := newTestQiTx(t, key, types.OutPoint{TxHash: pooled.Hash(), Index: 0}, 5)
+	have := pool.unknownQiInputsLocked(types.Transactions{pooled, orphan, chained, orphan})
+	if len(have) != 1 || have[0] != unknown.TxHash {
+		t.Errorf("unknown inputs mismatch: have %x, want [%x]", have, unknown.TxHash)
+	}
+}
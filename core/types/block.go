@@ -1183,3 +1183,11 @@ type BlockRequest struct {
 	Hash    common.Hash
 	Entropy *big.Int
 }
+
+// HeaderRangeRequest asks the peers for Count headers starting at the number
+// Start, leaving out Skip headers between two consecutive ones
+type HeaderRangeRequest struct {
+	Start *big.Int
+	Count uint64
+	Skip  uint64
+}
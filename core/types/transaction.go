@@ -934,6 +934,12 @@ func NewTxWithMinerFee(tx *Transaction, baseFee *big.Int, qiTxFee *big.Int) (*Tx
 	}, nil
 }
 
+// Tx returns the wrapped transaction.
+func (t *TxWithMinerFee) Tx() *Transaction { return t.tx }
+
+// MinerFee returns the fee the wrapped transaction pays to the miner.
+func (t *TxWithMinerFee) MinerFee() *big.Int { return t.minerFee }
+
 // TxByPriceAndTime implements both the sort and the heap interface, making it useful
 // for all at once sorting as well as individually adding and removing elements.
 type TxByPriceAndTime []*TxWithMinerFee
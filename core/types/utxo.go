@@ -234,3 +234,17 @@ func NewUtxoEntry(txOut *TxOut) *UtxoEntry {
 		Lock:         txOut.Lock,
 	}
 }
+
+// OutpointAndDenomination is an unspent output as tracked by the address
+// utxo index, identified by the outpoint that created it.
+type OutpointAndDenomination struct {
+	TxHash       common.Hash
+	Index        uint16
+	Denomination uint8
+	Lock         *big.Int // Block height the entry unlocks. 0 = unlocked
+}
+
+// OutPoint returns the outpoint of the indexed output.
+func (entry *OutpointAndDenomination) OutPoint() OutPoint {
+	return OutPoint{TxHash: entry.TxHash, Index: entry.Index}
+}
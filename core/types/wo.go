@@ -1104,6 +1104,9 @@ type WorkObjectHeaderView struct {
 	*WorkObject
 }
 
+// WorkObjectHeaderViews is the list of headers answering a HeaderRangeRequest
+type WorkObjectHeaderViews []*WorkObjectHeaderView
+
 ////////////////////////////////////////////////////////////
 ////////////// View Conversion/Getter Methods //////////////
 ////////////////////////////////////////////////////////////
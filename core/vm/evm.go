@@ -78,12 +78,13 @@ type BlockContext struct {
 	CheckIfEtxEligible CheckIfEtxEligibleFunc
 
 	// Block information
-	Coinbase    common.Address // Provides information for COINBASE
-	GasLimit    uint64         // Provides information for GASLIMIT
-	BlockNumber *big.Int       // Provides information for NUMBER
-	Time        *big.Int       // Provides information for TIME
-	Difficulty  *big.Int       // Provides information for DIFFICULTY
-	BaseFee     *big.Int       // Provides information for BASEFEE
+	Coinbase        common.Address // Provides information for COINBASE
+	GasLimit        uint64         // Provides information for GASLIMIT
+	BlockNumber     *big.Int       // Provides information for NUMBER
+	ExpansionNumber uint8          // Selects the forks activated by expansion
+	Time            *big.Int       // Provides information for TIME
+	Difficulty      *big.Int       // Provides information for DIFFICULTY
+	BaseFee         *big.Int       // Provides information for BASEFEE
 
 	// Prime Terminus information for the given block
 	EtxEligibleSlices common.Hash
@@ -124,6 +125,8 @@ type EVM struct {
 	chainConfig *params.ChainConfig
 	// chain rules contains the chain rules for the current epoch
 	chainRules params.Rules
+	// gasTable contains the gas prices for the current epoch
+	gasTable params.GasTable
 	// virtual machine configuration options used to initialise the
 	// evm.
 	Config Config
@@ -151,7 +154,8 @@ func NewEVM(blockCtx BlockContext, txCtx TxContext, statedb StateDB, chainConfig
 		StateDB:     statedb,
 		Config:      config,
 		chainConfig: chainConfig,
-		chainRules:  chainConfig.Rules(blockCtx.BlockNumber),
+		chainRules:  chainConfig.Rules(blockCtx.BlockNumber, blockCtx.ExpansionNumber),
+		gasTable:    chainConfig.GasTable(blockCtx.BlockNumber, blockCtx.ExpansionNumber),
 		ETXCache:    make([]*types.Transaction, 0),
 	}
 	evm.interpreter = NewEVMInterpreter(evm, config)
@@ -181,6 +185,11 @@ func (evm *EVM) Interpreter() *EVMInterpreter {
 	return evm.interpreter
 }
 
+// GasTable returns the gas prices of the current epoch
+func (evm *EVM) GasTable() params.GasTable {
+	return evm.gasTable
+}
+
 // Call executes the contract associated with the addr with the given input as
 // parameters. It also handles any necessary value transfer required and takes
 // the necessary steps to create accounts and reverses the state in case of an
@@ -634,18 +643,18 @@ func (evm *EVM) CreateETX(toAddr common.Address, fromAddr common.Address, gas ui
 	} else if conversion && value.Cmp(params.MinQuaiConversionAmount) < 0 {
 		return []byte{}, 0, fmt.Errorf("CreateETX conversion error: %d is not sufficient value, required amount: %d", value, params.MinQuaiConversionAmount)
 	}
-	if gas < params.ETXGas {
-		return []byte{}, 0, fmt.Errorf("CreateETX error: %d is not sufficient gas, required amount: %d", gas, params.ETXGas)
+	if gas < evm.gasTable.ETX {
+		return []byte{}, 0, fmt.Errorf("CreateETX error: %d is not sufficient gas, required amount: %d", gas, evm.gasTable.ETX)
 	}
 	fromInternal, err := fromAddr.InternalAndQuaiAddress()
 	if err != nil {
 		return []byte{}, 0, fmt.Errorf("CreateETX error: %s", err.Error())
 	}
 
-	gas = gas - params.ETXGas
+	gas = gas - evm.gasTable.ETX
 
-	if gas < params.TxGas { // ETX must have enough gas to create a transaction
-		return []byte{}, 0, fmt.Errorf("CreateETX error: %d is not sufficient gas for ETX, required amount: %d", gas, params.TxGas)
+	if gas < evm.gasTable.Tx { // ETX must have enough gas to create a transaction
+		return []byte{}, 0, fmt.Errorf("CreateETX error: %d is not sufficient gas for ETX, required amount: %d", gas, evm.gasTable.Tx)
 	}
 
 	// Fail if we're trying to transfer more than the available balance
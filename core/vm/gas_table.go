@@ -127,12 +127,12 @@ func gasSStore(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySi
 	original := evm.StateDB.GetCommittedState(internalContractAddr, x.Bytes32())
 	if original == current {
 		if original == (common.Hash{}) { // create slot (2.1.1)
-			return params.SstoreSetGas, nil
+			return evm.gasTable.SstoreSet, nil
 		}
 		if value == (common.Hash{}) { // delete slot (2.1.2b)
 			evm.StateDB.AddRefund(params.SstoreClearsScheduleRefund)
 		}
-		return params.SstoreResetGas, nil // write existing slot (2.1.2)
+		return evm.gasTable.SstoreReset, nil // write existing slot (2.1.2)
 	}
 	if original != (common.Hash{}) {
 		if current == (common.Hash{}) { // recreate slot (2.2.1.1)
@@ -143,9 +143,9 @@ func gasSStore(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySi
 	}
 	if original == value {
 		if original == (common.Hash{}) { // reset to original inexistent slot (2.2.2.1)
-			evm.StateDB.AddRefund(params.SstoreSetGas - params.SloadGas)
+			evm.StateDB.AddRefund(evm.gasTable.SstoreSet - params.SloadGas)
 		} else { // reset to original existing slot (2.2.2.2)
-			evm.StateDB.AddRefund(params.SstoreResetGas - params.SloadGas)
+			evm.StateDB.AddRefund(evm.gasTable.SstoreReset - params.SloadGas)
 		}
 	}
 	return params.SloadGas, nil // dirty update (2.2)
@@ -256,10 +256,10 @@ func gasCall(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize
 		return 0, err
 	}
 	if transfersValue && evm.StateDB.Empty(address) {
-		gas += params.CallNewAccountGas
+		gas += evm.gasTable.CallNewAccount
 	}
 	if transfersValue {
-		gas += params.CallValueTransferGas
+		gas += evm.gasTable.CallValueTransfer
 	}
 	memoryGas, err := memoryGasCost(mem, memorySize)
 	if err != nil {
@@ -290,7 +290,7 @@ func gasCallCode(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memory
 		overflow bool
 	)
 	if stack.Back(2).Sign() != 0 {
-		gas += params.CallValueTransferGas
+		gas += evm.gasTable.CallValueTransfer
 	}
 	if gas, overflow = math.SafeAdd(gas, memoryGas); overflow {
 		return 0, ErrGasUintOverflow
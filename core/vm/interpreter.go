@@ -67,10 +67,11 @@ type EVMInterpreter struct {
 func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 	// We use the STOP instruction whether to see
 	// the jump table was initialised. If it was not
-	// we'll set the default jump table.
+	// we'll set the jump table of the active fork.
 	if cfg.JumpTable[STOP] == nil {
-		jt := instructionSet
-		cfg.JumpTable = jt
+		// Forks changing the instructions select their jump table from
+		// evm.chainRules here, no fork is scheduled yet.
+		cfg.JumpTable = instructionSet
 	}
 
 	return &EVMInterpreter{
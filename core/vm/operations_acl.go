@@ -61,12 +61,12 @@ func makeGasSStoreFunc(clearingRefund uint64) gasFunc {
 		original := evm.StateDB.GetCommittedState(internalAddr, x.Bytes32())
 		if original == current {
 			if original == (common.Hash{}) { // create slot (2.1.1)
-				return cost + params.SstoreSetGas, nil
+				return cost + evm.gasTable.SstoreSet, nil
 			}
 			if value == (common.Hash{}) { // delete slot (2.1.2b)
 				evm.StateDB.AddRefund(clearingRefund)
 			}
-			return cost + (params.SstoreResetGas - params.ColdSloadCost), nil // write existing slot (2.1.2)
+			return cost + (evm.gasTable.SstoreReset - params.ColdSloadCost), nil // write existing slot (2.1.2)
 		}
 		if original != (common.Hash{}) {
 			if current == (common.Hash{}) { // recreate slot (2.2.1.1)
@@ -77,9 +77,9 @@ func makeGasSStoreFunc(clearingRefund uint64) gasFunc {
 		}
 		if original == value {
 			if original == (common.Hash{}) { // reset to original inexistent slot (2.2.2.1)
-				evm.StateDB.AddRefund(params.SstoreSetGas - params.WarmStorageReadCost)
+				evm.StateDB.AddRefund(evm.gasTable.SstoreSet - params.WarmStorageReadCost)
 			} else { // reset to original existing slot (2.2.2.2)
-				evm.StateDB.AddRefund((params.SstoreResetGas - params.ColdSloadCost) - params.WarmStorageReadCost)
+				evm.StateDB.AddRefund((evm.gasTable.SstoreReset - params.ColdSloadCost) - params.WarmStorageReadCost)
 			}
 		}
 		return cost + params.WarmStorageReadCost, nil // dirty update (2.2)
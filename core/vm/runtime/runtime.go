@@ -108,7 +108,7 @@ func Execute(code, input []byte, cfg *Config) ([]byte, *state.StateDB, error) {
 	if err != nil {
 		return []byte{}, nil, err
 	}
-	rules := cfg.ChainConfig.Rules(vmenv.Context.BlockNumber)
+	rules := cfg.ChainConfig.Rules(vmenv.Context.BlockNumber, vmenv.Context.ExpansionNumber)
 	cfg.State.PrepareAccessList(cfg.Origin, &address, vm.ActivePrecompiles(rules), nil)
 
 	cfg.State.CreateAccount(internal)
@@ -140,7 +140,7 @@ func Create(input []byte, cfg *Config) ([]byte, common.Address, uint64, error) {
 		vmenv  = NewEnv(cfg)
 		sender = vm.AccountRef(cfg.Origin)
 	)
-	rules := cfg.ChainConfig.Rules(vmenv.Context.BlockNumber)
+	rules := cfg.ChainConfig.Rules(vmenv.Context.BlockNumber, vmenv.Context.ExpansionNumber)
 	cfg.State.PrepareAccessList(cfg.Origin, nil, vm.ActivePrecompiles(rules), nil)
 
 	// Call the code with the given configuration.
@@ -169,7 +169,7 @@ func Call(address common.Address, input []byte, cfg *Config) ([]byte, uint64, er
 
 	statedb := cfg.State
 
-	rules := cfg.ChainConfig.Rules(vmenv.Context.BlockNumber)
+	rules := cfg.ChainConfig.Rules(vmenv.Context.BlockNumber, vmenv.Context.ExpansionNumber)
 	statedb.PrepareAccessList(cfg.Origin, &address, vm.ActivePrecompiles(rules), nil)
 
 	// Call the code with the given configuration.
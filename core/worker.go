@@ -178,6 +178,8 @@ type Config struct {
 	GasPrice   *big.Int       // Minimum gas price for mining a transaction
 	Recommit   time.Duration  // The time interval for miner to re-create mining work.
 	Noverify   bool           // Disable remote mining solution verification(only useful in ethash).
+
+	StratumAddr string `toml:",omitempty"` // Listening address of the stratum server, disabled if empty
 }
 
 // worker is the main object which takes care of submitting new work to consensus engine
@@ -481,6 +483,9 @@ func (w *worker) asyncStateLoop() {
 					w.interrupt = make(chan struct{})
 					return
 				default:
+					if w.hc.StateSyncing() {
+						return
+					}
 					wo := head.Block
 					header, err := w.GeneratePendingHeader(wo, true)
 					if err != nil {
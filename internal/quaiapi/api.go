@@ -872,12 +872,14 @@ func (s *PublicBlockChainAPI) EstimateGas(ctx context.Context, args TransactionA
 
 // ExecutionResult groups all structured logs emitted by the EVM
 // while replaying a transaction in debug mode as well as transaction
-// execution status, the amount of gas used and the return value
+// execution status, the amount of gas used, the return value and the
+// external transactions emitted
 type ExecutionResult struct {
-	Gas         uint64         `json:"gas"`
-	Failed      bool           `json:"failed"`
-	ReturnValue string         `json:"returnValue"`
-	StructLogs  []StructLogRes `json:"structLogs"`
+	Gas         uint64               `json:"gas"`
+	Failed      bool                 `json:"failed"`
+	ReturnValue string               `json:"returnValue"`
+	StructLogs  []StructLogRes       `json:"structLogs"`
+	Etxs        []*types.Transaction `json:"etxs,omitempty"`
 }
 
 // StructLogRes stores a structured log emitted by the EVM while replaying a
@@ -1228,7 +1230,7 @@ func AccessList(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrH
 		to = crypto.CreateAddress(args.from(nodeLocation), uint64(*args.Nonce), *args.Data, nodeLocation)
 	}
 	// Retrieve the precompiles since they don't need to be added to the access list
-	precompiles := vm.ActivePrecompiles(b.ChainConfig().Rules(header.Number(nodeCtx)), nodeLocation)
+	precompiles := vm.ActivePrecompiles(b.ChainConfig().Rules(header.Number(nodeCtx), header.ExpansionNumber()), nodeLocation)
 
 	// Create an initial tracer
 	prevTracer := vm.NewAccessListTracer(nil, args.from(nodeLocation), to, precompiles)
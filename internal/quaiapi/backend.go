@@ -21,10 +21,12 @@ import (
 	"context"
 	"math/big"
 
+	quai "github.com/dominant-strategies/go-quai"
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/consensus"
 	"github.com/dominant-strategies/go-quai/core"
 	"github.com/dominant-strategies/go-quai/core/bloombits"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
 	"github.com/dominant-strategies/go-quai/core/state"
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/core/vm"
@@ -45,10 +47,13 @@ type Backend interface {
 	// General Quai API
 	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
 	FeeHistory(ctx context.Context, blockCount int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error)
+	SuggestQiTipRate(ctx context.Context) (*big.Int, error)
+	QiFeeHistory(ctx context.Context, blockCount int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []uint64, []*big.Int, error)
 	ChainDb() ethdb.Database
 	ExtRPCEnabled() bool
 	RPCGasCap() uint64    // global gas cap for eth_call over rpc: DoS protection
 	RPCTxFeeCap() float64 // global tx fee cap for all transaction related APIs
+	SyncProgress() quai.SyncProgress
 
 	// Blockchain API
 	NodeLocation() common.Location
@@ -68,6 +73,8 @@ type Backend interface {
 	StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.WorkObject, error)
 	StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.WorkObject, error)
 	UTXOsByAddress(ctx context.Context, address common.Address) ([]*types.UtxoEntry, error)
+	OutpointsByAddress(ctx context.Context, address common.Address) ([]*types.OutpointAndDenomination, error)
+	AddressHistory(ctx context.Context, address common.Address, start []byte, end uint64, limit int) ([]*rawdb.AddressHistoryEntry, uint64, error)
 	GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error)
 	GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.WorkObject, vmConfig *vm.Config) (*vm.EVM, func() error, error)
 	SetCurrentExpansionNumber(expansionNumber uint8)
@@ -86,6 +93,8 @@ type Backend interface {
 	NewGenesisPendingHeader(pendingHeader *types.WorkObject, domTerminus common.Hash, hash common.Hash)
 	GetPendingHeader() (*types.WorkObject, error)
 	GetManifest(blockHash common.Hash) (types.BlockManifest, error)
+	GetTerminiByHash(hash common.Hash) *types.Termini
+	TrieNode(hash common.Hash) ([]byte, error)
 	GetSubManifest(slice common.Location, blockHash common.Hash) (types.BlockManifest, error)
 	AddPendingEtxs(pEtxs types.PendingEtxs) error
 	AddPendingEtxsRollup(pEtxsRollup types.PendingEtxsRollup) error
@@ -101,6 +110,11 @@ type Backend interface {
 	WriteGenesisBlock(block *types.WorkObject, location common.Location)
 	SendWorkShare(workShare *types.WorkObjectHeader) error
 	CheckIfValidWorkShare(workShare *types.WorkObjectHeader) bool
+	EtxLifecycle(ctx context.Context, etxHash common.Hash) ([]rawdb.EtxLifecycleEntry, error)
+
+	// Cross slice API
+	SetSliceBackends(slices SliceBackends)
+	SliceBackend(location common.Location) Backend
 
 	// Transaction pool API
 	SendTx(ctx context.Context, signedTx *types.Transaction) error
@@ -135,6 +149,14 @@ type Backend interface {
 	BroadcastWorkShare(workShare *types.WorkObjectHeader, location common.Location) error
 }
 
+// SliceBackends gives access to the backends of all the slices running in the
+// process, so that an API of one slice can follow data across the hierarchy.
+type SliceBackends interface {
+	// GetBackend returns the backend of the given location, or nil if the
+	// slice is not running
+	GetBackend(location common.Location) *Backend
+}
+
 func GetAPIs(apiBackend Backend) []rpc.API {
 	nodeCtx := apiBackend.NodeCtx()
 	nonceLock := new(AddrLocker)
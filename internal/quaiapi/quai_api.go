@@ -17,19 +17,26 @@
 package quaiapi
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
 	"math/big"
+	"sort"
 	"time"
 
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/common/hexutil"
 	"github.com/dominant-strategies/go-quai/consensus/misc"
 	"github.com/dominant-strategies/go-quai/core"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/crypto"
 	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/quai/gasprice"
 	"github.com/dominant-strategies/go-quai/rpc"
 	"github.com/dominant-strategies/go-quai/trie"
 )
@@ -93,6 +100,84 @@ func (s *PublicQuaiAPI) FeeHistory(ctx context.Context, blockCount rpc.DecimalOr
 	return results, nil
 }
 
+// qiFeeSuggestion is the tip rate a Qi transaction has to pay to be included
+// within a number of blocks.
+type qiFeeSuggestion struct {
+	Blocks     hexutil.Uint64 `json:"blocks"`
+	Percentile float64        `json:"percentile"`
+	TipRate    *hexutil.Big   `json:"tipRate"`
+}
+
+type qiFeeHistoryResult struct {
+	OldestBlock *hexutil.Big       `json:"oldestBlock"`
+	TipRateGas  hexutil.Uint64     `json:"tipRateGas"`
+	Reward      [][]*hexutil.Big   `json:"reward,omitempty"`
+	QiTxCount   []hexutil.Uint64   `json:"qiTxCount"`
+	Suggested   []*qiFeeSuggestion `json:"suggested"`
+}
+
+// QiFeeHistory returns the percentiles of the miner tips paid by the Qi
+// transactions of the given range of blocks, together with the tips suggested
+// for different confirmation targets. The tips are rates in qits per tipRateGas
+// units of gas, to be paid on top of the base fee.
+func (s *PublicQuaiAPI) QiFeeHistory(ctx context.Context, blockCount rpc.DecimalOrHex, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*qiFeeHistoryResult, error) {
+	oldest, reward, qiTxCount, suggested, err := s.b.QiFeeHistory(ctx, int(blockCount), lastBlock, rewardPercentiles)
+	if err != nil {
+		return nil, err
+	}
+	results := &qiFeeHistoryResult{
+		OldestBlock: (*hexutil.Big)(oldest),
+		TipRateGas:  gasprice.QiTipRateGas,
+		QiTxCount:   make([]hexutil.Uint64, len(qiTxCount)),
+	}
+	if reward != nil {
+		results.Reward = make([][]*hexutil.Big, len(reward))
+		for i, w := range reward {
+			results.Reward[i] = make([]*hexutil.Big, len(w))
+			for j, v := range w {
+				results.Reward[i][j] = (*hexutil.Big)(v)
+			}
+		}
+	}
+	for i, count := range qiTxCount {
+		results.QiTxCount[i] = hexutil.Uint64(count)
+	}
+	if suggested != nil {
+		for i, target := range gasprice.QiFeeTargets {
+			results.Suggested = append(results.Suggested, &qiFeeSuggestion{
+				Blocks:     hexutil.Uint64(target.Blocks),
+				Percentile: target.Percentile,
+				TipRate:    (*hexutil.Big)(suggested[i]),
+			})
+		}
+	}
+	return results, nil
+}
+
+// Syncing returns false in case the node is currently not syncing with the network. It can be up to date or has not
+// yet received the latest block headers from its peers. In case it is synchronizing:
+// - startingBlock: block number this node started to synchronise from
+// - currentBlock:  block number this node is currently importing
+// - highestBlock:  block number of the highest block header this node has received from peers
+// - pulledStates:  number of state entries processed until now
+// - knownStates:   number of known state entries that still need to be pulled
+func (s *PublicQuaiAPI) Syncing() (interface{}, error) {
+	progress := s.b.SyncProgress()
+
+	// Return not syncing if the synchronisation already completed
+	if progress.CurrentBlock >= progress.HighestBlock && progress.PulledStates >= progress.KnownStates {
+		return false, nil
+	}
+	// Otherwise gather the block sync stats
+	return map[string]interface{}{
+		"startingBlock": hexutil.Uint64(progress.StartingBlock),
+		"currentBlock":  hexutil.Uint64(progress.CurrentBlock),
+		"highestBlock":  hexutil.Uint64(progress.HighestBlock),
+		"pulledStates":  hexutil.Uint64(progress.PulledStates),
+		"knownStates":   hexutil.Uint64(progress.KnownStates),
+	}, nil
+}
+
 // PublicBlockChainQuaiAPI provides an API to access the Quai blockchain.
 // It offers only methods that operate on public data that is freely available to anyone.
 type PublicBlockChainQuaiAPI struct {
@@ -173,6 +258,393 @@ func (s *PublicBlockChainQuaiAPI) GetQiBalance(ctx context.Context, address comm
 	return (*hexutil.Big)(balance), nil
 }
 
+const (
+	// defaultUTXOPageSize is the number of utxos returned by getUTXOsByAddress
+	// when the caller does not ask for a specific page size.
+	defaultUTXOPageSize = 100
+
+	// maxUTXOPageSize is the maximum number of utxos returned by a single
+	// getUTXOsByAddress call.
+	maxUTXOPageSize = 1000
+)
+
+// UTXOFilter narrows down the utxos returned by getUTXOsByAddress. Cursor is
+// the opaque value returned as Next by a previous call, Locked selects only
+// locked (true) or only unlocked (false) entries when set.
+type UTXOFilter struct {
+	Cursor          *hexutil.Bytes  `json:"cursor"`
+	Limit           *hexutil.Uint64 `json:"limit"`
+	MinDenomination *hexutil.Uint   `json:"minDenomination"`
+	Locked          *bool           `json:"locked"`
+}
+
+// RPCUtxo represents an unspent Qi output that belongs to an address.
+type RPCUtxo struct {
+	TxHash       common.Hash    `json:"txHash"`
+	Index        hexutil.Uint64 `json:"index"`
+	Denomination hexutil.Uint   `json:"denomination"`
+	Lock         *hexutil.Big   `json:"lock"`
+	Spendable    bool           `json:"spendable"`
+}
+
+// RPCUtxoPage is a single page of the utxos of an address. Next is set when
+// more utxos are available and has to be passed as the cursor of the next call.
+type RPCUtxoPage struct {
+	Utxos []*RPCUtxo     `json:"utxos"`
+	Next  *hexutil.Bytes `json:"next,omitempty"`
+}
+
+// utxoCursor encodes the position of an outpoint in the utxo listing order.
+func utxoCursor(outpoint types.OutPoint) []byte {
+	cursor := make([]byte, common.HashLength+2)
+	copy(cursor, outpoint.TxHash.Bytes())
+	binary.BigEndian.PutUint16(cursor[common.HashLength:], outpoint.Index)
+	return cursor
+}
+
+// GetUTXOsByAddress returns the unspent Qi outputs of the given address at the
+// current head, ordered by outpoint. Locked outputs are reported as not
+// spendable until the block they unlock in.
+func (s *PublicBlockChainQuaiAPI) GetUTXOsByAddress(ctx context.Context, address common.MixedcaseAddress, filter *UTXOFilter) (*RPCUtxoPage, error) {
+	if !address.ValidChecksum() {
+		return nil, errors.New("address has invalid checksum")
+	}
+	nodeCtx := s.b.NodeCtx()
+	if nodeCtx != common.ZONE_CTX {
+		return nil, errors.New("getUTXOsByAddress call can only be made in zone chain")
+	}
+	if !s.b.ProcessingState() {
+		return nil, errors.New("getUTXOsByAddress call can only be made on chain processing the state")
+	}
+	if filter == nil {
+		filter = new(UTXOFilter)
+	}
+	limit := uint64(defaultUTXOPageSize)
+	if filter.Limit != nil {
+		limit = uint64(*filter.Limit)
+		if limit == 0 || limit > maxUTXOPageSize {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxUTXOPageSize)
+		}
+	}
+	var cursor []byte
+	if filter.Cursor != nil {
+		if len(*filter.Cursor) != common.HashLength+2 {
+			return nil, errors.New("invalid cursor")
+		}
+		cursor = *filter.Cursor
+	}
+	state, header, err := s.b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	outpoints, err := s.b.OutpointsByAddress(ctx, address.Address())
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(outpoints, func(i, j int) bool {
+		return bytes.Compare(utxoCursor(outpoints[i].OutPoint()), utxoCursor(outpoints[j].OutPoint())) < 0
+	})
+	// Outputs locked until the next block can already be spent in it
+	nextNumber := new(big.Int).Add(header.Number(nodeCtx), common.Big1)
+
+	page := &RPCUtxoPage{Utxos: make([]*RPCUtxo, 0)}
+	for _, outpoint := range outpoints {
+		position := utxoCursor(outpoint.OutPoint())
+		if cursor != nil && bytes.Compare(position, cursor) <= 0 {
+			continue
+		}
+		if filter.MinDenomination != nil && uint(outpoint.Denomination) < uint(*filter.MinDenomination) {
+			continue
+		}
+		// The index may lag behind the head, so confirm the output is unspent
+		utxo := state.GetUTXO(outpoint.TxHash, outpoint.Index)
+		if utxo == nil {
+			continue
+		}
+		lock := utxo.Lock
+		if lock == nil {
+			lock = new(big.Int)
+		}
+		locked := lock.Cmp(nextNumber) > 0
+		if filter.Locked != nil && *filter.Locked != locked {
+			continue
+		}
+		if uint64(len(page.Utxos)) == limit {
+			next := hexutil.Bytes(utxoCursor(page.lastOutPoint()))
+			page.Next = &next
+			break
+		}
+		page.Utxos = append(page.Utxos, &RPCUtxo{
+			TxHash:       outpoint.TxHash,
+			Index:        hexutil.Uint64(outpoint.Index),
+			Denomination: hexutil.Uint(utxo.Denomination),
+			Lock:         (*hexutil.Big)(lock),
+			Spendable:    !locked,
+		})
+	}
+	return page, state.Error()
+}
+
+// lastOutPoint returns the outpoint of the last utxo in the page.
+func (page *RPCUtxoPage) lastOutPoint() types.OutPoint {
+	last := page.Utxos[len(page.Utxos)-1]
+	return types.OutPoint{TxHash: last.TxHash, Index: uint16(last.Index)}
+}
+
+const (
+	// defaultAddressHistoryPageSize is the number of entries returned by
+	// getAddressHistory when the caller does not ask for a specific page size.
+	defaultAddressHistoryPageSize = 100
+
+	// maxAddressHistoryPageSize is the maximum number of entries returned by a
+	// single getAddressHistory call.
+	maxAddressHistoryPageSize = 1000
+)
+
+// AddressHistoryFilter narrows down the entries returned by getAddressHistory.
+// Cursor is the opaque value returned as Next by a previous call and takes
+// precedence over FromBlock.
+type AddressHistoryFilter struct {
+	FromBlock *hexutil.Uint64 `json:"fromBlock"`
+	ToBlock   *hexutil.Uint64 `json:"toBlock"`
+	Cursor    *hexutil.Bytes  `json:"cursor"`
+	Limit     *hexutil.Uint64 `json:"limit"`
+}
+
+// RPCAddressHistoryEntry is a transaction, ETX or coinbase output of a block
+// that sent from or paid to an address.
+type RPCAddressHistoryEntry struct {
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+	TransactionHash  common.Hash    `json:"transactionHash"`
+	Sent             bool           `json:"sent"`
+	Received         bool           `json:"received"`
+	Coinbase         bool           `json:"coinbase"`
+	Etx              bool           `json:"etx"`
+}
+
+// RPCAddressHistoryPage is a single page of the history of an address. The
+// history only covers the blocks below IndexedBlocks. Next is set when more
+// entries are available and has to be passed as the cursor of the next call.
+type RPCAddressHistoryPage struct {
+	Entries       []*RPCAddressHistoryEntry `json:"entries"`
+	IndexedBlocks hexutil.Uint64            `json:"indexedBlocks"`
+	Next          *hexutil.Bytes            `json:"next,omitempty"`
+}
+
+// GetAddressHistory returns the canonical transactions, ETXs and coinbase
+// outputs sent from or paid to the given address, ordered by block and
+// transaction index.
+func (s *PublicBlockChainQuaiAPI) GetAddressHistory(ctx context.Context, address common.MixedcaseAddress, filter *AddressHistoryFilter) (*RPCAddressHistoryPage, error) {
+	if !address.ValidChecksum() {
+		return nil, errors.New("address has invalid checksum")
+	}
+	if s.b.NodeCtx() != common.ZONE_CTX {
+		return nil, errors.New("getAddressHistory call can only be made in zone chain")
+	}
+	if filter == nil {
+		filter = new(AddressHistoryFilter)
+	}
+	limit := uint64(defaultAddressHistoryPageSize)
+	if filter.Limit != nil {
+		limit = uint64(*filter.Limit)
+		if limit == 0 || limit > maxAddressHistoryPageSize {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxAddressHistoryPageSize)
+		}
+	}
+	var start []byte
+	switch {
+	case filter.Cursor != nil:
+		if len(*filter.Cursor) != rawdb.AddressHistoryPositionLength {
+			return nil, errors.New("invalid cursor")
+		}
+		start = *filter.Cursor
+	case filter.FromBlock != nil:
+		start = rawdb.AddressHistoryPosition(uint64(*filter.FromBlock), 0)
+	}
+	end := uint64(math.MaxUint64)
+	if filter.ToBlock != nil {
+		end = uint64(*filter.ToBlock)
+	}
+	// Fetch one more entry than requested to know if there is a next page
+	entries, indexed, err := s.b.AddressHistory(ctx, address.Address(), start, end, int(limit)+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &RPCAddressHistoryPage{
+		Entries:       make([]*RPCAddressHistoryEntry, 0, len(entries)),
+		IndexedBlocks: hexutil.Uint64(indexed),
+	}
+	if uint64(len(entries)) > limit {
+		next := hexutil.Bytes(entries[limit].Position())
+		page.Next = &next
+		entries = entries[:limit]
+	}
+	for _, entry := range entries {
+		page.Entries = append(page.Entries, &RPCAddressHistoryEntry{
+			BlockNumber:      hexutil.Uint64(entry.BlockNumber),
+			BlockHash:        entry.BlockHash,
+			TransactionIndex: hexutil.Uint64(entry.TxIndex),
+			TransactionHash:  entry.TxHash,
+			Sent:             entry.Flags&rawdb.AddressHistorySent != 0,
+			Received:         entry.Flags&rawdb.AddressHistoryReceived != 0,
+			Coinbase:         entry.Flags&rawdb.AddressHistoryCoinbase != 0,
+			Etx:              entry.Flags&rawdb.AddressHistoryEtx != 0,
+		})
+	}
+	return page, nil
+}
+
+// Stages of the lifecycle of an ETX, reported by getEtxStatus.
+const (
+	EtxStatusEmitted         = "emitted"
+	EtxStatusRolledUp        = "rolledUp"
+	EtxStatusIncludedInPrime = "includedInPrime"
+	EtxStatusQueued          = "queued"
+	EtxStatusExecuted        = "executed"
+)
+
+// RPCEtxStage is the canonical block of a slice at which an ETX reached a
+// stage of its lifecycle.
+type RPCEtxStage struct {
+	Location    common.Location `json:"location"`
+	BlockHash   common.Hash     `json:"blockHash"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+}
+
+// RPCEtxStatus follows an ETX from the zone emitting it, through the region
+// rollup and the prime inclusion, to the destination zone executing it. The
+// stages the ETX did not reach yet, or that belong to a slice which is not
+// running in this process, are null.
+type RPCEtxStatus struct {
+	Status          string                 `json:"status"`
+	EtxHash         common.Hash            `json:"etxHash"`
+	Origin          common.Location        `json:"origin"`
+	Destination     common.Location        `json:"destination"`
+	Emitted         *RPCEtxStage           `json:"emitted"`
+	RolledUp        *RPCEtxStage           `json:"rolledUp"`
+	IncludedInPrime *RPCEtxStage           `json:"includedInPrime"`
+	Queued          *RPCEtxStage           `json:"queued"`
+	Executed        *RPCEtxStage           `json:"executed"`
+	Receipt         map[string]interface{} `json:"receipt"`
+}
+
+// GetEtxStatus returns the lifecycle of the ETX emitted at the given index by
+// the given transaction. Any slice running in this process can answer, as the
+// slices crossed by the ETX are looked up through their own backends. It
+// returns nil if the originating transaction or the ETX is not found.
+func (s *PublicBlockChainQuaiAPI) GetEtxStatus(ctx context.Context, originTxHash common.Hash, index hexutil.Uint64) (*RPCEtxStatus, error) {
+	// The origin bytes of a Qi transaction hash are not reliable, so the
+	// originating transaction is searched in every zone running
+	var (
+		origin      Backend
+		blockHash   common.Hash
+		blockNumber uint64
+	)
+	for _, location := range s.b.GetSlicesRunning() {
+		if location.Context() != common.ZONE_CTX {
+			continue
+		}
+		backend := s.b.SliceBackend(location)
+		if backend == nil || !backend.ProcessingState() {
+			continue
+		}
+		if _, hash, number, _, err := backend.GetTransaction(ctx, originTxHash); err == nil {
+			origin, blockHash, blockNumber = backend, hash, number
+			break
+		}
+	}
+	if origin == nil {
+		return nil, nil
+	}
+	block, err := origin.BlockByHash(ctx, blockHash)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	var etx *types.Transaction
+	for _, tx := range block.ExtTransactions() {
+		if tx.OriginatingTxHash() == originTxHash && uint64(tx.ETXIndex()) == uint64(index) {
+			etx = tx
+			break
+		}
+	}
+	if etx == nil || etx.To() == nil {
+		return nil, nil
+	}
+	originLocation := origin.NodeLocation()
+	destination := *etx.To().Location()
+	status := &RPCEtxStatus{
+		Status:      EtxStatusEmitted,
+		EtxHash:     etx.Hash(),
+		Origin:      originLocation,
+		Destination: destination,
+		Emitted: &RPCEtxStage{
+			Location:    originLocation,
+			BlockHash:   blockHash,
+			BlockNumber: hexutil.Uint64(blockNumber),
+		},
+	}
+	if status.RolledUp, err = etxStage(ctx, s.b.SliceBackend(common.Location{byte(originLocation.Region())}), status.EtxHash); err != nil {
+		return nil, err
+	}
+	if status.RolledUp != nil {
+		status.Status = EtxStatusRolledUp
+	}
+	if status.IncludedInPrime, err = etxStage(ctx, s.b.SliceBackend(common.Location{}), status.EtxHash); err != nil {
+		return nil, err
+	}
+	if status.IncludedInPrime != nil {
+		status.Status = EtxStatusIncludedInPrime
+	}
+	destBackend := s.b.SliceBackend(destination)
+	if destBackend == nil || !destBackend.ProcessingState() {
+		return status, nil
+	}
+	if status.Queued, err = etxStage(ctx, destBackend, status.EtxHash); err != nil {
+		return nil, err
+	}
+	if status.Queued != nil {
+		status.Status = EtxStatusQueued
+	}
+	if _, hash, number, _, err := destBackend.GetTransaction(ctx, status.EtxHash); err == nil {
+		status.Status = EtxStatusExecuted
+		status.Executed = &RPCEtxStage{
+			Location:    destination,
+			BlockHash:   hash,
+			BlockNumber: hexutil.Uint64(number),
+		}
+		status.Receipt, err = NewPublicTransactionPoolAPI(destBackend, nil).GetTransactionReceipt(ctx, status.EtxHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// etxStage returns the earliest canonical block of the given slice the ETX
+// crossed, or nil if the ETX did not reach the slice or it is not running.
+func etxStage(ctx context.Context, b Backend, etxHash common.Hash) (*RPCEtxStage, error) {
+	if b == nil {
+		return nil, nil
+	}
+	entries, err := b.EtxLifecycle(ctx, etxHash)
+	if err != nil {
+		return nil, err
+	}
+	var stage *RPCEtxStage
+	for _, entry := range entries {
+		if stage == nil || entry.BlockNumber < uint64(stage.BlockNumber) {
+			stage = &RPCEtxStage{
+				Location:    b.NodeLocation(),
+				BlockHash:   entry.BlockHash,
+				BlockNumber: hexutil.Uint64(entry.BlockNumber),
+			}
+		}
+	}
+	return stage, nil
+}
+
 // GetProof returns the Merkle-proof for a given account and optionally some storage keys.
 func (s *PublicBlockChainQuaiAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*AccountResult, error) {
 	nodeCtx := s.b.NodeCtx()
@@ -495,7 +967,8 @@ func (s *PublicBlockChainQuaiAPI) BaseFee(ctx context.Context, txType bool) (*bi
 }
 
 // EstimateFeeForQi returns an estimate of the amount of Qi in qits needed to execute the
-// given transaction against the current pending block.
+// given transaction against the current pending block. On top of the base fee, the
+// estimate includes the tip paid by the Qi transactions of the recent blocks.
 func (s *PublicBlockChainQuaiAPI) EstimateFeeForQi(ctx context.Context, args TransactionArgs) (*big.Int, error) {
 	// Estimate the gas
 	gas, err := args.CalculateQiTxGas(s.b.NodeLocation())
@@ -520,6 +993,12 @@ func (s *PublicBlockChainQuaiAPI) EstimateFeeForQi(ctx context.Context, args Tra
 		lastPrime = header
 	}
 	feeInQi := misc.QuaiToQi(lastPrime, feeInQuai)
+	// Add the tip the miners recently accepted, rounded up to the next qit
+	if tipRate, err := s.b.SuggestQiTipRate(ctx); err == nil && tipRate.Sign() > 0 {
+		tip := new(big.Int).Mul(tipRate, new(big.Int).SetUint64(uint64(gas)))
+		tip.Add(tip, big.NewInt(gasprice.QiTipRateGas-1))
+		feeInQi.Add(feeInQi, tip.Div(tip, big.NewInt(gasprice.QiTipRateGas)))
+	}
 	if feeInQi.Cmp(big.NewInt(0)) == 0 {
 		// Minimum fee is 1 qit or smallest unit
 		return types.Denominations[0], nil
@@ -884,6 +1363,15 @@ func (s *PublicBlockChainQuaiAPI) GetPendingHeader(ctx context.Context) (map[str
 	return marshaledPh, nil
 }
 
+// GetTerminiByHash returns the termini stored for the block with the given hash
+func (s *PublicBlockChainQuaiAPI) GetTerminiByHash(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
+	termini := s.b.GetTerminiByHash(hash)
+	if termini == nil {
+		return nil, fmt.Errorf("termini not found for block %s", hash)
+	}
+	return termini.RPCMarshalTermini(), nil
+}
+
 func (s *PublicBlockChainQuaiAPI) GetManifest(ctx context.Context, raw json.RawMessage) (types.BlockManifest, error) {
 	var blockHash common.Hash
 	if err := json.Unmarshal(raw, &blockHash); err != nil {
@@ -0,0 +1,304 @@
+package quaiapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/params"
+	"github.com/dominant-strategies/go-quai/quai/gasprice"
+)
+
+var testLocation = common.Location{0, 0}
+
+// feeTestBackend serves the head block and the Qi tip rate EstimateFeeForQi
+// needs, any other call panics
+type feeTestBackend struct {
+	Backend
+	config  *params.ChainConfig
+	head    *types.WorkObject
+	tipRate *big.Int
+	tipErr  error
+}
+
+func (b *feeTestBackend) NodeLocation() common.Location    { return testLocation }
+func (b *feeTestBackend) CurrentBlock() *types.WorkObject  { return b.head }
+func (b *feeTestBackend) ChainConfig() *params.ChainConfig { return b.config }
+func (b *feeTestBackend) SuggestQiTipRate(ctx context.Context) (*big.Int, error) {
+	return b.tipRate, b.tipErr
+}
+func (b *feeTestBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.WorkObject, error) {
+	return nil, errors.New("header not found")
+}
+
+func newFeeTestBackend(tipRate *big.Int, tipErr error) *feeTestBackend {
+	config := *params.TestChainConfig
+	config.Location = testLocation
+	head := types.EmptyHeader(common.ZONE_CTX)
+	head.WorkObjectHeader().SetLocation(testLocation)
+	head.Header().SetBaseFee(big.NewInt(params.GWei))
+	head.Header().SetGasLimit(params.GenesisGasLimit)
+	return &feeTestBackend{config: &config, head: head, tipRate: tipRate, tipErr: tipErr}
+}
+
+// newQiFeeArgs returns the arguments of a Qi transaction spending one output
+// into an output of the same zone
+func newQiFeeArgs() TransactionArgs {
+	to := make([]byte, common.AddressLength)
+	to[1] = 0x80 // Qi ledger of zone 0-0
+	prevOut := types.NewOutPoint(&common.Hash{0x01}, 0)
+	return TransactionArgs{
+		TxType: types.QiTxType,
+		TxIn:   types.TxIns{*types.NewTxIn(prevOut, make([]byte, 65), nil)},
+		TxOut:  types.TxOuts{*types.NewTxOut(1, to, big.NewInt(0))},
+	}
+}
+
+func TestEstimateFeeForQi(t *testing.T) {
+	args := newQiFeeArgs()
+	gas, err := args.CalculateQiTxGas(testLocation)
+	if err != nil {
+		t.Fatalf("failed to calculate the gas: %v", err)
+	}
+	// ceilTip is the tip paid at the given rate, rounded up to the next qit
+	ceilTip := func(rate int64) *big.Int {
+		tip := new(big.Int).Mul(big.NewInt(rate), new(big.Int).SetUint64(uint64(gas)))
+		tip.Add(tip, big.NewInt(gasprice.QiTipRateGas-1))
+		return tip.Div(tip, big.NewInt(gasprice.QiTipRateGas))
+	}
+	// The base fee converts to less than a qit, so only the tip and the
+	// minimum fee are left
+	var (
+		zeroFee = types.Denominations[0]
+		rate    = int64(gasprice.QiTipRateGas) + 1
+	)
+	if uint64(gas)%gasprice.QiTipRateGas == 0 {
+		t.Fatalf("gas %d is a multiple of the tip rate gas, the tip is not rounded", gas)
+	}
+	tests := []struct {
+		tipRate *big.Int
+		tipErr  error
+		expect  *big.Int
+	}{
+		{big.NewInt(0), nil, zeroFee},
+		{nil, errors.New("no tips"), zeroFee},
+		{big.NewInt(1), nil, ceilTip(1)},
+		{big.NewInt(rate), nil, ceilTip(rate)},
+	}
+	for i, test := range tests {
+		api := NewPublicBlockChainQuaiAPI(newFeeTestBackend(test.tipRate, test.tipErr))
+		fee, err := api.EstimateFeeForQi(context.Background(), args)
+		if err != nil {
+			t.Fatalf("test %d: failed to estimate the fee: %v", i, err)
+		}
+		if fee.Cmp(test.expect) != 0 {
+			t.Errorf("test %d: fee mismatch: have %v, want %v", i, fee, test.expect)
+		}
+	}
+}
+
+func TestEstimateFeeForQiNotQi(t *testing.T) {
+	api := NewPublicBlockChainQuaiAPI(newFeeTestBackend(big.NewInt(1), nil))
+	args := newQiFeeArgs()
+	args.TxType = types.QuaiTxType
+	if _, err := api.EstimateFeeForQi(context.Background(), args); err == nil {
+		t.Fatal("expected an error estimating the fee of a Quai transaction")
+	}
+}
+
+var errTxNotFound = errors.New("transaction not found")
+
+// etxTestTx is a transaction included in a block of a slice
+type etxTestTx struct {
+	tx          *types.Transaction
+	blockHash   common.Hash
+	blockNumber uint64
+}
+
+// etxTestBackend is a slice of a process running the backends of all the
+// slices, it serves the transactions, blocks and etx lifecycle entries
+// GetEtxStatus reads, any other call panics
+type etxTestBackend struct {
+	Backend
+	location   common.Location
+	running    *[]*etxTestBackend
+	processing bool
+
+	txs       map[common.Hash]etxTestTx
+	blocks    map[common.Hash]*types.WorkObject
+	lifecycle map[common.Hash][]rawdb.EtxLifecycleEntry
+	receipts  types.Receipts
+}
+
+func newEtxTestBackend(location common.Location, running *[]*etxTestBackend) *etxTestBackend {
+	b := &etxTestBackend{
+		location:   location,
+		running:    running,
+		processing: location.Context() == common.ZONE_CTX,
+		txs:        make(map[common.Hash]etxTestTx),
+		blocks:     make(map[common.Hash]*types.WorkObject),
+		lifecycle:  make(map[common.Hash][]rawdb.EtxLifecycleEntry),
+	}
+	*running = append(*running, b)
+	return b
+}
+
+func (b *etxTestBackend) NodeLocation() common.Location { return b.location }
+func (b *etxTestBackend) ProcessingState() bool         { return b.processing }
+func (b *etxTestBackend) ChainConfig() *params.ChainConfig {
+	config := *params.TestChainConfig
+	config.Location = b.location
+	return &config
+}
+func (b *etxTestBackend) GetSlicesRunning() []common.Location {
+	locations := make([]common.Location, 0, len(*b.running))
+	for _, slice := range *b.running {
+		locations = append(locations, slice.location)
+	}
+	return locations
+}
+func (b *etxTestBackend) SliceBackend(location common.Location) Backend {
+	for _, slice := range *b.running {
+		if slice.location.Equal(location) {
+			return slice
+		}
+	}
+	return nil
+}
+func (b *etxTestBackend) GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	if tx, ok := b.txs[txHash]; ok {
+		return tx.tx, tx.blockHash, tx.blockNumber, 0, nil
+	}
+	return nil, common.Hash{}, 0, 0, errTxNotFound
+}
+func (b *etxTestBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.WorkObject, error) {
+	return b.blocks[hash], nil
+}
+func (b *etxTestBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.WorkObject, error) {
+	return b.blocks[hash], nil
+}
+func (b *etxTestBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.receipts, nil
+}
+func (b *etxTestBackend) EtxLifecycle(ctx context.Context, etxHash common.Hash) ([]rawdb.EtxLifecycleEntry, error) {
+	return b.lifecycle[etxHash], nil
+}
+
+// newEtxTestBlock returns a zone block of the given slice holding the etxs
+func newEtxTestBlock(location common.Location, number uint64, etxs types.Transactions) *types.WorkObject {
+	block := types.EmptyHeader(common.ZONE_CTX)
+	block.Header().SetBaseFee(big.NewInt(params.GWei))
+	block.Body().SetExtTransactions(etxs)
+	block.WorkObjectHeader().SetLocation(location)
+	block.WorkObjectHeader().SetNumber(new(big.Int).SetUint64(number))
+	block.WorkObjectHeader().SetHeaderHash(block.Header().Hash())
+	return block
+}
+
+func TestGetEtxStatus(t *testing.T) {
+	var (
+		origin       = common.Location{0, 0}
+		destination  = common.Location{0, 1}
+		originTxHash = common.Hash{0x01}
+		from         = common.HexToAddress("0x0000000000000000000000000000000000000001", origin)
+		to           = common.HexToAddress("0x0100000000000000000000000000000000000001", destination)
+		etx          = types.NewTx(&types.ExternalTx{OriginatingTxHash: originTxHash, ETXIndex: 1, Gas: params.TxGas, To: &to, Value: big.NewInt(1), Sender: from})
+		other        = types.NewTx(&types.ExternalTx{OriginatingTxHash: originTxHash, ETXIndex: 0, Gas: params.TxGas, To: &to, Value: big.NewInt(2), Sender: from})
+		emitted      = newEtxTestBlock(origin, 5, types.Transactions{other, etx})
+		executed     = newEtxTestBlock(destination, 9, nil)
+
+		rolledUp = []rawdb.EtxLifecycleEntry{{BlockHash: common.Hash{0x12}, BlockNumber: 4}, {BlockHash: common.Hash{0x11}, BlockNumber: 3}}
+		included = []rawdb.EtxLifecycleEntry{{BlockHash: common.Hash{0x21}, BlockNumber: 2}}
+		queued   = []rawdb.EtxLifecycleEntry{{BlockHash: common.Hash{0x31}, BlockNumber: 8}}
+	)
+	stage := func(location common.Location, entry rawdb.EtxLifecycleEntry) *RPCEtxStage {
+		return &RPCEtxStage{Location: location, BlockHash: entry.BlockHash, BlockNumber: hexutil.Uint64(entry.BlockNumber)}
+	}
+	tests := []struct {
+		rolledUp, included, queued []rawdb.EtxLifecycleEntry
+		pending                    bool // the origin transaction is not included yet
+		executed                   bool
+		destination                bool // the destination zone is running and processing the state
+		index                      uint64
+
+		status string
+		stages [5]*RPCEtxStage // emitted, rolled up, included in prime, queued, executed
+	}{
+		// The origin transaction is pending, or didn't emit an etx at the index
+		{pending: true, index: 1, destination: true},
+		{index: 2, destination: true},
+		{index: 1, destination: true, status: EtxStatusEmitted},
+		// The earliest block of the region rolling up the etx is reported
+		{rolledUp: rolledUp, index: 1, destination: true, status: EtxStatusRolledUp,
+			stages: [5]*RPCEtxStage{1: stage(common.Location{0}, rolledUp[1])}},
+		{rolledUp: rolledUp, included: included, index: 1, destination: true, status: EtxStatusIncludedInPrime,
+			stages: [5]*RPCEtxStage{1: stage(common.Location{0}, rolledUp[1]), 2: stage(common.Location{}, included[0])}},
+		// The stages of the destination are unknown if it doesn't process the state
+		{rolledUp: rolledUp, included: included, queued: queued, executed: true, index: 1, status: EtxStatusIncludedInPrime,
+			stages: [5]*RPCEtxStage{1: stage(common.Location{0}, rolledUp[1]), 2: stage(common.Location{}, included[0])}},
+		{rolledUp: rolledUp, included: included, queued: queued, index: 1, destination: true, status: EtxStatusQueued,
+			stages: [5]*RPCEtxStage{1: stage(common.Location{0}, rolledUp[1]), 2: stage(common.Location{}, included[0]), 3: stage(destination, queued[0])}},
+		{rolledUp: rolledUp, included: included, queued: queued, executed: true, index: 1, destination: true, status: EtxStatusExecuted,
+			stages: [5]*RPCEtxStage{1: stage(common.Location{0}, rolledUp[1]), 2: stage(common.Location{}, included[0]), 3: stage(destination, queued[0]),
+				4: {Location: destination, BlockHash: executed.Hash(), BlockNumber: 9}}},
+	}
+	for i, test := range tests {
+		var running []*etxTestBackend
+		prime := newEtxTestBackend(common.Location{}, &running)
+		region := newEtxTestBackend(common.Location{0}, &running)
+		zone := newEtxTestBackend(origin, &running)
+		dest := newEtxTestBackend(destination, &running)
+		dest.processing = test.destination
+
+		if !test.pending {
+			zone.txs[originTxHash] = etxTestTx{blockHash: emitted.Hash(), blockNumber: 5}
+		}
+		zone.blocks[emitted.Hash()] = emitted
+		region.lifecycle[etx.Hash()] = test.rolledUp
+		prime.lifecycle[etx.Hash()] = test.included
+		dest.lifecycle[etx.Hash()] = test.queued
+		if test.executed {
+			dest.txs[etx.Hash()] = etxTestTx{tx: etx, blockHash: executed.Hash(), blockNumber: 9}
+			dest.blocks[executed.Hash()] = executed
+			dest.receipts = types.Receipts{{Type: types.ExternalTxType, Status: types.ReceiptStatusSuccessful, TxHash: etx.Hash(), GasUsed: params.TxGas}}
+		}
+		status, err := NewPublicBlockChainQuaiAPI(region).GetEtxStatus(context.Background(), originTxHash, hexutil.Uint64(test.index))
+		if err != nil {
+			t.Fatalf("test %d: failed to get the etx status: %v", i, err)
+		}
+		if test.status == "" {
+			if status != nil {
+				t.Errorf("test %d: status mismatch: have %s, want none", i, status.Status)
+			}
+			continue
+		}
+		if status == nil {
+			t.Fatalf("test %d: etx not found", i)
+		}
+		if status.Status != test.status {
+			t.Errorf("test %d: status mismatch: have %s, want %s", i, status.Status, test.status)
+		}
+		if status.EtxHash != etx.Hash() || !status.Origin.Equal(origin) || !status.Destination.Equal(destination) {
+			t.Errorf("test %d: etx mismatch: have %x from %v to %v", i, status.EtxHash, status.Origin, status.Destination)
+		}
+		// The emission is always known, the other stages depend on the test
+		test.stages[0] = &RPCEtxStage{Location: origin, BlockHash: emitted.Hash(), BlockNumber: 5}
+		for j, have := range []*RPCEtxStage{status.Emitted, status.RolledUp, status.IncludedInPrime, status.Queued, status.Executed} {
+			want := test.stages[j]
+			if (have == nil) != (want == nil) || have != nil && (!have.Location.Equal(want.Location) || have.BlockHash != want.BlockHash || have.BlockNumber != want.BlockNumber) {
+				t.Errorf("test %d: stage %d mismatch: have %+v, want %+v", i, j, have, want)
+			}
+		}
+		if want := test.stages[4] != nil; (status.Receipt != nil) != want {
+			t.Errorf("test %d: receipt presence mismatch: have %v, want %v", i, status.Receipt != nil, want)
+		}
+		if status.Receipt != nil && status.Receipt["status"] != hexutil.Uint(types.ReceiptStatusSuccessful) {
+			t.Errorf("test %d: receipt status mismatch: have %v", i, status.Receipt["status"])
+		}
+	}
+}
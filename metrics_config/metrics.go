@@ -27,6 +27,7 @@ var enabled bool
 
 var registeredGauges = make(map[string]*prometheus.GaugeVec)
 var registeredCounters = make(map[string]*prometheus.CounterVec)
+var registeredHistograms = make(map[string]*prometheus.HistogramVec)
 
 // Init enables or disables the metrics system. Since we need this to run before
 // any other code gets to create meters and timers, we'll actually do an ugly hack
@@ -99,19 +100,39 @@ func NewCounter(name string, help string) *prometheus.Counter {
 	return &counter
 }
 
-func NewCounterVec(name string, help string) *prometheus.CounterVec {
+// NewCounterVec registers a counter vector partitioned by the given labels, or
+// by a single "label" if none is given.
+func NewCounterVec(name string, help string, labels ...string) *prometheus.CounterVec {
 	if counterVec, exists := registeredCounters[name]; exists {
 		return counterVec
 	}
+	if len(labels) == 0 {
+		labels = []string{"label"}
+	}
 	counterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: name,
 		Help: help,
-	}, []string{"label"})
+	}, labels)
 	prometheus.Register(counterVec)
 	registeredCounters[name] = counterVec
 	return counterVec
 }
 
+// NewHistogramVec registers a histogram vector with the default buckets,
+// partitioned by the given labels.
+func NewHistogramVec(name string, help string, labels ...string) *prometheus.HistogramVec {
+	if histogramVec, exists := registeredHistograms[name]; exists {
+		return histogramVec
+	}
+	histogramVec := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: name,
+		Help: help,
+	}, labels)
+	prometheus.Register(histogramVec)
+	registeredHistograms[name] = histogramVec
+	return histogramVec
+}
+
 func NewTimer(name string, help string) *prometheus.Timer {
 	timeHistogram := prometheus.NewHistogram(prometheus.HistogramOpts{
 		Name: name,
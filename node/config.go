@@ -23,6 +23,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/dominant-strategies/go-quai/common"
 	log "github.com/dominant-strategies/go-quai/log"
@@ -82,6 +83,12 @@ type Config struct {
 	// USB enables hardware wallet monitoring and connectivity.
 	USB bool `toml:",omitempty"`
 
+	// IPCPath is the requested location to place the IPC endpoint. If the path is
+	// a simple file name, it is placed inside the data directory of the slice,
+	// otherwise the name of the slice is prefixed to the file name so the slices
+	// don't share a socket. If it is empty, no IPC endpoint will be started.
+	IPCPath string
+
 	// HTTPHost is the host interface on which to start the HTTP RPC server. If this
 	// field is empty, no HTTP API endpoint will be started.
 	HTTPHost string
@@ -156,9 +163,22 @@ type Config struct {
 	// AllowUnprotectedTxs allows non EIP-155 protected transactions to be send over RPC.
 	AllowUnprotectedTxs bool `toml:",omitempty"`
 
-	// JWTSecret is the path to the hex-encoded jwt secret.
+	// JWTSecret is the path to the hex-encoded jwt secret. If set, requests to
+	// the HTTP and WebSocket RPC endpoints must carry a HS256 signed token.
 	JWTSecret string `toml:",omitempty"`
 
+	// JWTNamespaces restricts the authentication to the given API namespaces.
+	// If empty, every request needs a token once JWTSecret is set.
+	JWTNamespaces []string `toml:",omitempty"`
+
+	// RPCLimits are the limits enforced on the requests to the HTTP and
+	// WebSocket RPC endpoints.
+	RPCLimits rpc.Limits `toml:",omitempty"`
+
+	// RPCSlowCallThreshold is the duration above which the calls to the HTTP
+	// and WebSocket RPC endpoints are logged, 0 disables the logging.
+	RPCSlowCallThreshold time.Duration `toml:",omitempty"`
+
 	// EnablePersonal enables the deprecated personal namespace.
 	EnablePersonal bool `toml:"-"`
 
@@ -168,6 +188,24 @@ type Config struct {
 	NodeLocation common.Location
 }
 
+// IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
+// account the set data folders as well as the slice location.
+func (c *Config) IPCEndpoint() string {
+	// Short circuit if IPC has not been enabled
+	if c.IPCPath == "" {
+		return ""
+	}
+	// Resolve names into the data directory, which is specific to the slice
+	if filepath.Base(c.IPCPath) == c.IPCPath {
+		if c.DataDir == "" {
+			return filepath.Join(os.TempDir(), c.NodeLocation.Name()+"-"+c.IPCPath)
+		}
+		return filepath.Join(c.DataDir, c.IPCPath)
+	}
+	// Explicit paths are shared by all the slices of the node
+	return filepath.Join(filepath.Dir(c.IPCPath), c.NodeLocation.Name()+"-"+filepath.Base(c.IPCPath))
+}
+
 // NodeDB returns the path to the discovery node database.
 func (c *Config) NodeDB() string {
 	if c.DataDir == "" {
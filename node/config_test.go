@@ -85,15 +85,16 @@ func TestIPCPathResolution(t *testing.T) {
 	}{
 		{"", "", false, ""},
 		{"data", "", false, ""},
-		{"", "quai.ipc", false, filepath.Join(os.TempDir(), "quai.ipc")},
+		{"", "quai.ipc", false, filepath.Join(os.TempDir(), "prime-quai.ipc")},
 		{"data", "quai.ipc", false, "data/quai.ipc"},
-		{"data", "./quai.ipc", false, "./quai.ipc"},
-		{"data", "/quai.ipc", false, "/quai.ipc"},
+		{"data", "./quai.ipc", false, "prime-quai.ipc"},
+		{"data", "/quai.ipc", false, "/prime-quai.ipc"},
+		{"data", "/run/quai/quai.ipc", false, "/run/quai/prime-quai.ipc"},
 		{"", "", true, ``},
 		{"data", "", true, ``},
 		{"", "quai.ipc", true, `\\.\pipe\quai.ipc`},
 		{"data", "quai.ipc", true, `\\.\pipe\quai.ipc`},
-		{"data", `\\.\pipe\quai.ipc`, true, `\\.\pipe\quai.ipc`},
+		{"data", `\\.\pipe\quai.ipc`, true, `\\.\pipe\prime-quai.ipc`},
 	}
 	for i, test := range tests {
 		// Only run when platform/test match
@@ -29,16 +29,20 @@ const (
 	DefaultHTTPPort = 8545        // Default TCP port for the HTTP RPC server
 	DefaultWSHost   = "localhost" // Default host interface for the websocket RPC server
 	DefaultWSPort   = 8546        // Default TCP port for the websocket RPC server
+	DefaultIPCPath  = "quai.ipc"  // Default file name of the IPC endpoint in the data directory of a slice
 )
 
 // DefaultConfig contains reasonable default settings.
 var DefaultConfig = Config{
-	DataDir:          filepath.Join(xdg.DataHome, constants.APP_NAME),
-	HTTPPort:         DefaultHTTPPort,
-	HTTPModules:      []string{"net", "web3"},
-	HTTPVirtualHosts: []string{"localhost"},
-	HTTPTimeouts:     rpc.DefaultHTTPTimeouts,
-	WSPort:           DefaultWSPort,
-	WSModules:        []string{"net", "web3"},
-	DBEngine:         "",
+	DataDir:              filepath.Join(xdg.DataHome, constants.APP_NAME),
+	IPCPath:              DefaultIPCPath,
+	HTTPPort:             DefaultHTTPPort,
+	HTTPModules:          []string{"net", "web3"},
+	HTTPVirtualHosts:     []string{"localhost"},
+	HTTPTimeouts:         rpc.DefaultHTTPTimeouts,
+	WSPort:               DefaultWSPort,
+	WSModules:            []string{"net", "web3"},
+	RPCLimits:            rpc.Limits{BatchItems: 1000, ResponseSize: 25 * 1000 * 1000},
+	RPCSlowCallThreshold: rpc.DefaultSlowCallThreshold,
+	DBEngine:             "",
 }
@@ -0,0 +1,117 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/rpc"
+)
+
+// jwtExpiryTimeout is the maximum drift allowed between the issued-at claim of
+// a token and the local time.
+const jwtExpiryTimeout = 60 * time.Second
+
+// jwtSecretLength is the length in bytes of the shared HS256 secret.
+const jwtSecretLength = 32
+
+// jwtHandler authenticates the requests served by the RPC handler it wraps
+// with HS256 signed JWTs.
+type jwtHandler struct {
+	keyFunc func(token *jwt.Token) (interface{}, error)
+	next    http.Handler // serves the authenticated requests
+	public  http.Handler // serves the requests without token, nil if all requests need one
+}
+
+// newJWTHandler wraps next in a handler that only lets through requests
+// carrying a valid token. If public is not nil, requests without any token
+// are served by it instead of being rejected.
+func newJWTHandler(secret []byte, next http.Handler, public http.Handler) http.Handler {
+	return &jwtHandler{
+		keyFunc: func(token *jwt.Token) (interface{}, error) {
+			return secret, nil
+		},
+		next:   next,
+		public: public,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (handler *jwtHandler) ServeHTTP(out http.ResponseWriter, r *http.Request) {
+	var (
+		strToken string
+		claims   jwt.StandardClaims
+	)
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		strToken = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if len(strToken) == 0 {
+		if handler.public != nil {
+			handler.public.ServeHTTP(out, r)
+			return
+		}
+		http.Error(out, "missing token", http.StatusUnauthorized)
+		return
+	}
+	// Only HS256 is accepted, and the time based claims are checked below
+	// as the library does not allow for any drift of the issued-at claim
+	parser := jwt.Parser{ValidMethods: []string{jwt.SigningMethodHS256.Alg()}, SkipClaimsValidation: true}
+	token, err := parser.ParseWithClaims(strToken, &claims, handler.keyFunc)
+	switch {
+	case err != nil:
+		http.Error(out, err.Error(), http.StatusUnauthorized)
+	case !token.Valid:
+		http.Error(out, "invalid token", http.StatusUnauthorized)
+	case !claims.VerifyExpiresAt(time.Now().Unix(), false):
+		http.Error(out, "token is expired", http.StatusUnauthorized)
+	case claims.IssuedAt == 0:
+		http.Error(out, "missing issued-at", http.StatusUnauthorized)
+	case time.Since(time.Unix(claims.IssuedAt, 0)) > jwtExpiryTimeout:
+		http.Error(out, "stale token", http.StatusUnauthorized)
+	case time.Until(time.Unix(claims.IssuedAt, 0)) > jwtExpiryTimeout:
+		http.Error(out, "future token", http.StatusUnauthorized)
+	default:
+		// The subject is the only claim stable across the tokens of a
+		// client, so the requests of the clients without one are limited
+		// per IP
+		if claims.Subject != "" {
+			r = r.WithContext(rpc.WithAuthenticatedClient(r.Context(), claims.Subject))
+		}
+		handler.next.ServeHTTP(out, r)
+	}
+}
+
+// NewJWTAuth creates an rpc.HTTPAuth which signs a fresh token with the given
+// secret for every request.
+func NewJWTAuth(secret []byte) rpc.HTTPAuth {
+	return func(h http.Header) error {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
+			IssuedAt: time.Now().Unix(),
+		})
+		s, err := token.SignedString(secret)
+		if err != nil {
+			return fmt.Errorf("failed to create JWT token: %w", err)
+		}
+		h.Set("Authorization", "Bearer "+s)
+		return nil
+	}
+}
+
+// ReadJWTSecret loads the hex encoded JWT secret stored in the given file.
+func ReadJWTSecret(fileName string) ([]byte, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT secret: %w", err)
+	}
+	secret := common.FromHex(strings.TrimSpace(string(data)))
+	if len(secret) != jwtSecretLength {
+		return nil, errors.New("invalid JWT secret, expected 32 hex encoded bytes")
+	}
+	return secret, nil
+}
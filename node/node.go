@@ -51,7 +51,9 @@ type Node struct {
 	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
 	http          *httpServer //
 	ws            *httpServer //
+	ipc           *ipcServer  // IPC endpoint serving all the RPC APIs
 	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
+	jwtSecret     []byte      // Secret authenticating the HTTP and WebSocket RPC requests, if enabled
 	location      []byte
 
 	databases map[*closeTrackingDB]struct{} // All open databases
@@ -95,6 +97,15 @@ func New(conf *Config, logger *log.Logger) (*Node, error) {
 		logger:        logger,
 	}
 
+	// Load the secret authenticating the RPC requests.
+	if conf.JWTSecret != "" {
+		secret, err := ReadJWTSecret(conf.JWTSecret)
+		if err != nil {
+			return nil, err
+		}
+		node.jwtSecret = secret
+	}
+
 	// Acquire the instance directory lock.
 	if err := node.openDataDir(); err != nil {
 		return nil, err
@@ -111,6 +122,7 @@ func New(conf *Config, logger *log.Logger) (*Node, error) {
 	// Configure RPC servers.
 	node.http = newHTTPServer(node.logger, conf.HTTPTimeouts)
 	node.ws = newHTTPServer(node.logger, rpc.DefaultHTTPTimeouts)
+	node.ipc = newIPCServer(node.logger, conf.IPCEndpoint())
 
 	return node, nil
 }
@@ -287,6 +299,13 @@ func (n *Node) startRPC() error {
 		return err
 	}
 
+	// Configure IPC.
+	if n.ipc.endpoint != "" {
+		if err := n.ipc.start(n.rpcAPIs); err != nil {
+			return err
+		}
+	}
+
 	// Configure HTTP.
 	if n.config.HTTPHost != "" {
 		config := httpConfig{
@@ -294,6 +313,10 @@ func (n *Node) startRPC() error {
 			Vhosts:             n.config.HTTPVirtualHosts,
 			Modules:            n.config.HTTPModules,
 			prefix:             n.config.HTTPPathPrefix,
+			jwtSecret:          n.jwtSecret,
+			jwtNamespaces:      n.config.JWTNamespaces,
+			limits:             n.config.RPCLimits,
+			slowCallThreshold:  n.config.RPCSlowCallThreshold,
 		}
 		if err := n.http.setListenAddr(n.config.HTTPHost, n.config.HTTPPort); err != nil {
 			return err
@@ -307,9 +330,13 @@ func (n *Node) startRPC() error {
 	if n.config.WSHost != "" {
 		server := n.wsServerForPort(n.config.WSPort)
 		config := wsConfig{
-			Modules: n.config.WSModules,
-			Origins: n.config.WSOrigins,
-			prefix:  n.config.WSPathPrefix,
+			Modules:           n.config.WSModules,
+			Origins:           n.config.WSOrigins,
+			prefix:            n.config.WSPathPrefix,
+			jwtSecret:         n.jwtSecret,
+			jwtNamespaces:     n.config.JWTNamespaces,
+			limits:            n.config.RPCLimits,
+			slowCallThreshold: n.config.RPCSlowCallThreshold,
 		}
 		if err := server.setListenAddr(n.config.WSHost, n.config.WSPort); err != nil {
 			return err
@@ -335,6 +362,7 @@ func (n *Node) wsServerForPort(port int) *httpServer {
 func (n *Node) stopRPC() {
 	n.http.stop()
 	n.ws.stop()
+	n.ipc.stop()
 	n.stopInProc()
 }
 
@@ -431,6 +459,11 @@ func (n *Node) InstanceDir() string {
 	return n.config.instanceDir()
 }
 
+// IPCEndpoint retrieves the current IPC endpoint used by the protocol stack.
+func (n *Node) IPCEndpoint() string {
+	return n.ipc.endpoint
+}
+
 // HTTPEndpoint returns the URL of the HTTP server. Note that this URL does not
 // contain the JSON-RPC path prefix set by HTTPPathPrefix.
 func (n *Node) HTTPEndpoint() string {
@@ -445,6 +478,15 @@ func (n *Node) WSEndpoint() string {
 	return "ws://" + n.ws.listenAddr() + n.ws.wsConfig.prefix
 }
 
+// RPCAuth returns the authentication provider that clients of the HTTP and
+// WebSocket endpoints of the node need, or nil if no authentication is required.
+func (n *Node) RPCAuth() rpc.HTTPAuth {
+	if n.jwtSecret == nil {
+		return nil
+	}
+	return NewJWTAuth(n.jwtSecret)
+}
+
 // EventMux retrieves the event multiplexer used by all the network services in
 // the current protocol stack.
 func (n *Node) EventMux() *event.TypeMux {
@@ -485,9 +527,10 @@ func (n *Node) OpenDatabase(name string, cache, handles int, namespace string, r
 // OpenDatabaseWithFreezer opens an existing database with the given name (or
 // creates one if no previous can be found) from within the node's data directory,
 // also attaching a chain freezer to it that moves ancient chain data from the
-// database to immutable append-only files. If the node is an ephemeral one, a
-// memory database is returned.
-func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, ancient, namespace string, readonly bool, location common.Location) (ethdb.Database, error) {
+// database to immutable append-only files. The blocks more than ancientThreshold
+// blocks below the prime terminus of the head are moved. If the node is an
+// ephemeral one, a memory database is returned.
+func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, ancient string, ancientThreshold uint64, namespace string, readonly bool, location common.Location) (ethdb.Database, error) {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 	if n.state == closedState {
@@ -502,6 +545,7 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, ancient,
 			Type:              n.config.DBEngine,
 			Directory:         n.ResolvePath(name),
 			AncientsDirectory: n.ResolveAncient(name, ancient),
+			AncientsThreshold: ancientThreshold,
 			Namespace:         namespace,
 			Cache:             cache,
 			Handles:           handles,
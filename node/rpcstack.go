@@ -28,6 +28,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	log "github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/rpc"
@@ -40,18 +41,35 @@ type httpConfig struct {
 	CorsAllowedOrigins []string
 	Vhosts             []string
 	prefix             string // path prefix on which to mount http handler
+	jwtSecret          []byte // optional JWT secret
+	jwtNamespaces      []string
+	limits             rpc.Limits
+	slowCallThreshold  time.Duration
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
-	Origins []string
-	Modules []string
-	prefix  string // path prefix on which to mount ws handler
+	Origins           []string
+	Modules           []string
+	prefix            string // path prefix on which to mount ws handler
+	jwtSecret         []byte // optional JWT secret
+	jwtNamespaces     []string
+	limits            rpc.Limits
+	slowCallThreshold time.Duration
 }
 
 type rpcHandler struct {
 	http.Handler
 	server *rpc.Server
+	public *rpc.Server // serves unauthenticated requests, nil unless only some namespaces require a token
+}
+
+// stop stops the RPC servers of the handler.
+func (h *rpcHandler) stop() {
+	h.server.Stop()
+	if h.public != nil {
+		h.public.Stop()
+	}
 }
 
 type httpServer struct {
@@ -257,11 +275,11 @@ func (h *httpServer) doStop() {
 	wsHandler := h.wsHandler.Load().(*rpcHandler)
 	if httpHandler != nil {
 		h.httpHandler.Store((*rpcHandler)(nil))
-		httpHandler.server.Stop()
+		httpHandler.stop()
 	}
 	if wsHandler != nil {
 		h.wsHandler.Store((*rpcHandler)(nil))
-		wsHandler.server.Stop()
+		wsHandler.stop()
 	}
 	h.server.Shutdown(context.Background())
 	h.listener.Close()
@@ -282,14 +300,23 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 	}
 
 	// Create RPC server and handler.
-	srv := rpc.NewServer(h.logger)
-	if err := RegisterApis(apis, config.Modules, srv, false, h.logger); err != nil {
+	srv, public, err := newRPCServers(apis, config.Modules, config.jwtSecret, config.jwtNamespaces, config.limits, config.slowCallThreshold, h.logger)
+	if err != nil {
 		return err
 	}
+	var handler http.Handler = srv
+	if config.jwtSecret != nil {
+		var publicHandler http.Handler
+		if public != nil {
+			publicHandler = public
+		}
+		handler = newJWTHandler(config.jwtSecret, srv, publicHandler)
+	}
 	h.httpConfig = config
 	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts),
+		Handler: NewHTTPHandlerStack(handler, config.CorsAllowedOrigins, config.Vhosts),
 		server:  srv,
+		public:  public,
 	})
 	return nil
 }
@@ -299,7 +326,7 @@ func (h *httpServer) disableRPC() bool {
 	handler := h.httpHandler.Load().(*rpcHandler)
 	if handler != nil {
 		h.httpHandler.Store((*rpcHandler)(nil))
-		handler.server.Stop()
+		handler.stop()
 	}
 	return handler != nil
 }
@@ -314,14 +341,23 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 	}
 
 	// Create RPC server and handler.
-	srv := rpc.NewServer(h.logger)
-	if err := RegisterApis(apis, config.Modules, srv, false, h.logger); err != nil {
+	srv, public, err := newRPCServers(apis, config.Modules, config.jwtSecret, config.jwtNamespaces, config.limits, config.slowCallThreshold, h.logger)
+	if err != nil {
 		return err
 	}
+	handler := srv.WebsocketHandler(config.Origins)
+	if config.jwtSecret != nil {
+		var publicHandler http.Handler
+		if public != nil {
+			publicHandler = public.WebsocketHandler(config.Origins)
+		}
+		handler = newJWTHandler(config.jwtSecret, handler, publicHandler)
+	}
 	h.wsConfig = config
 	h.wsHandler.Store(&rpcHandler{
-		Handler: srv.WebsocketHandler(config.Origins),
+		Handler: handler,
 		server:  srv,
+		public:  public,
 	})
 	return nil
 }
@@ -343,7 +379,7 @@ func (h *httpServer) disableWS() bool {
 	ws := h.wsHandler.Load().(*rpcHandler)
 	if ws != nil {
 		h.wsHandler.Store((*rpcHandler)(nil))
-		ws.server.Stop()
+		ws.stop()
 	}
 	return ws != nil
 }
@@ -473,6 +509,103 @@ func newGzipHandler(next http.Handler) http.Handler {
 	})
 }
 
+// newRPCServers creates the RPC server serving the given modules. If a JWT
+// secret is configured but only some of the modules require authentication,
+// it also creates the server answering the requests without a token, which
+// serves all the modules except for the protected namespaces. Both servers
+// enforce the given limits and log the calls slower than the threshold.
+func newRPCServers(apis []rpc.API, modules []string, jwtSecret []byte, jwtNamespaces []string, limits rpc.Limits, slowCallThreshold time.Duration, logger *log.Logger) (*rpc.Server, *rpc.Server, error) {
+	srv := rpc.NewServer(logger)
+	srv.SetLimits(limits)
+	srv.SetSlowCallThreshold(slowCallThreshold)
+	if err := RegisterApis(apis, modules, srv, false, logger); err != nil {
+		return nil, nil, err
+	}
+	if jwtSecret == nil || len(jwtNamespaces) == 0 {
+		return srv, nil, nil
+	}
+	protected := make(map[string]bool)
+	for _, namespace := range jwtNamespaces {
+		protected[namespace] = true
+	}
+	var (
+		publicApis    []rpc.API
+		publicModules []string
+	)
+	for _, api := range apis {
+		if !protected[api.Namespace] {
+			publicApis = append(publicApis, api)
+		}
+	}
+	for _, module := range modules {
+		if !protected[module] {
+			publicModules = append(publicModules, module)
+		}
+	}
+	if len(modules) > 0 && len(publicModules) == 0 {
+		// Every configured module is protected, so the requests without a
+		// token are rejected rather than served by an empty server
+		return srv, nil, nil
+	}
+	public := rpc.NewServer(logger)
+	public.SetLimits(limits)
+	public.SetSlowCallThreshold(slowCallThreshold)
+	if err := RegisterApis(publicApis, publicModules, public, false, logger); err != nil {
+		return nil, nil, err
+	}
+	return srv, public, nil
+}
+
+// ipcServer serves all the APIs of the node over a Unix domain socket.
+type ipcServer struct {
+	logger   *log.Logger
+	endpoint string
+
+	mu       sync.Mutex
+	listener net.Listener
+	srv      *rpc.Server
+}
+
+func newIPCServer(logger *log.Logger, endpoint string) *ipcServer {
+	return &ipcServer{logger: logger, endpoint: endpoint}
+}
+
+// start starts the IPC endpoint if it is configured and not already running.
+func (is *ipcServer) start(apis []rpc.API) error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	if is.endpoint == "" || is.listener != nil {
+		return nil // not configured or already running
+	}
+	listener, srv, err := rpc.StartIPCEndpoint(is.endpoint, apis, is.logger)
+	if err != nil {
+		is.logger.WithFields(log.Fields{
+			"path": is.endpoint,
+			"err":  err,
+		}).Warn("IPC opening failed")
+		return err
+	}
+	is.logger.WithField("url", is.endpoint).Info("IPC endpoint opened")
+	is.listener, is.srv = listener, srv
+	return nil
+}
+
+// stop closes the IPC endpoint.
+func (is *ipcServer) stop() error {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	if is.listener == nil {
+		return nil // not running
+	}
+	err := is.listener.Close()
+	is.srv.Stop()
+	is.listener, is.srv = nil, nil
+	is.logger.WithField("url", is.endpoint).Info("IPC endpoint closed")
+	return err
+}
+
 // RegisterApis checks the given modules' availability, generates an allowlist based on the allowed modules,
 // and then registers all of the APIs exposed by the services.
 func RegisterApis(apis []rpc.API, modules []string, srv *rpc.Server, exposeAll bool, logger *log.Logger) error {
@@ -14,7 +14,9 @@ import (
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/p2p"
 	"github.com/dominant-strategies/go-quai/p2p/node/peerManager"
+	"github.com/dominant-strategies/go-quai/p2p/node/peerManager/peerdb"
 	"github.com/dominant-strategies/go-quai/p2p/node/pubsubManager"
+	"github.com/dominant-strategies/go-quai/p2p/pb"
 	quaiprotocol "github.com/dominant-strategies/go-quai/p2p/protocol"
 	"github.com/dominant-strategies/go-quai/quai"
 	"github.com/dominant-strategies/go-quai/trie"
@@ -41,6 +43,9 @@ func (p *P2PNode) Start() error {
 		quaiprotocol.QuaiProtocolHandler(s, p)
 	})
 
+	// Register the fork ID handshake handler
+	p.peerManager.GetHost().SetStreamHandler(quaiprotocol.ForkIDProtocolVersion, p.handleForkIDStream)
+
 	// Start the pubsub manager
 	p.pubsub.SetReceiveHandler(p.handleBroadcast)
 
@@ -167,6 +172,7 @@ func (p *P2PNode) requestAndWait(ctx context.Context, peerID peer.ID, topic *pub
 	}()
 	var recvd interface{}
 	var err error
+	var respErr *pb.ResponseError
 	if recvd, err = p.requestFromPeer(peerID, topic, reqData, respDataType); err == nil {
 		log.Global.WithFields(log.Fields{
 			"peerId": peerID,
@@ -192,6 +198,13 @@ func (p *P2PNode) requestAndWait(ctx context.Context, peerID peer.ID, topic *pub
 				"message": "Channel is full, data not sent",
 			}).Warning("Missed data send")
 		}
+	} else if errors.As(err, &respErr) && respErr.NotFound() {
+		log.Global.WithFields(log.Fields{
+			"peerId": peerID,
+			"topic":  topic.String(),
+		}).Trace("Peer does not have the requested data")
+		// The peer answered, it only misses the data
+		p.peerManager.MarkNotFoundPeer(peerID, topic)
 	} else {
 		log.Global.WithFields(log.Fields{
 			"peerId": peerID,
@@ -321,10 +334,51 @@ func (p *P2PNode) GetBlockHashByNumber(number *big.Int, location common.Location
 	return p.consensus.LookupBlockHashByNumber(number, location)
 }
 
+func (p *P2PNode) GetTransaction(hash common.Hash, location common.Location) *types.Transaction {
+	return p.consensus.LookupTransaction(hash, location)
+}
+
 func (p *P2PNode) GetTrieNode(hash common.Hash, location common.Location) *trie.TrieNodeResponse {
 	return p.consensus.GetTrieNode(hash, location)
 }
 
+// Returns the genesis hash of the network the node runs
+func (p *P2PNode) GetGenesis() common.Hash {
+	return p.pubsub.GetGenesis()
+}
+
+// Returns the heads of all the chains running locally
+func (p *P2PNode) GetChainHeads() []pb.ChainHead {
+	if p.consensus == nil {
+		return nil
+	}
+	var heads []pb.ChainHead
+	for _, location := range common.GenerateLocations(common.MaxRegions, common.MaxZones) {
+		hash, entropy, err := p.consensus.GetHead(location)
+		if err != nil {
+			// The chain is not running at this location
+			continue
+		}
+		heads = append(heads, pb.ChainHead{Location: location, Hash: hash, Entropy: entropy})
+	}
+	return heads
+}
+
+// Records the status announced by a peer in the peer manager
+func (p *P2PNode) SetPeerStatus(peerID peer.ID, status *pb.PeerStatus) {
+	info := &peerdb.PeerInfo{
+		AddrInfo: peerdb.AddrInfo{AddrInfo: peer.AddrInfo{ID: peerID}},
+		Version:  status.Version,
+		Heads:    status.Heads,
+	}
+	if err := p.peerManager.SetPeerStatus(peerID, info); err != nil {
+		log.Global.WithFields(log.Fields{
+			"peer": peerID,
+			"err":  err,
+		}).Error("Error recording peer status")
+	}
+}
+
 func (p *P2PNode) handleBroadcast(sourcePeer peer.ID, topic string, data interface{}, nodeLocation common.Location) {
 	switch v := data.(type) {
 	case types.WorkObject:
@@ -85,8 +85,17 @@ func (p *P2PNode) eventLoop() {
 					peerAddresses := p.peerManager.GetHost().Peerstore().Addrs(peerID)
 					log.Global.Debugf("Event: 'Peer connectedness change' - Peer %s (peerInfo: %+v) is now %s, protocols: %v, addresses: %v", peerID.String(), peerInfo, e.Connectedness, peerProtocols, peerAddresses)
 
-					if e.Connectedness == network.NotConnected {
+					switch e.Connectedness {
+					case network.NotConnected:
 						p.peerManager.RemovePeer(peerID)
+					case network.Connected:
+						// The dialing side opens the fork ID handshake
+						for _, conn := range p.peerManager.GetHost().Network().ConnsToPeer(peerID) {
+							if conn.Stat().Direction == network.DirOutbound {
+								p.exchangeForkIDs(peerID)
+								break
+							}
+						}
 					}
 				case *event.EvtNATDeviceTypeChanged:
 					log.Global.Debugf("Event `NAT device type changed` - DeviceType %v, transport: %v", e.NatDeviceType.String(), e.TransportProtocol.String())
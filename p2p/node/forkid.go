@@ -0,0 +1,173 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/forkid"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/p2p/pb"
+	quaiprotocol "github.com/dominant-strategies/go-quai/p2p/protocol"
+)
+
+// c_forkIDTimeout is the time allowed to a peer to complete the fork ID handshake
+const c_forkIDTimeout = 10 * time.Second
+
+var errNoConsensusBackend = errors.New("consensus backend not set")
+
+// Returns the locations of all the chains running locally together with their fork IDs
+func (p *P2PNode) localForkIDs() ([]common.Location, []forkid.ID, error) {
+	if p.consensus == nil {
+		return nil, nil, errNoConsensusBackend
+	}
+	var (
+		locations []common.Location
+		ids       []forkid.ID
+	)
+	for _, location := range common.GenerateLocations(common.MaxRegions, common.MaxZones) {
+		id, err := p.consensus.ForkID(location)
+		if err != nil {
+			// The chain is not running at this location
+			continue
+		}
+		locations = append(locations, location)
+		ids = append(ids, id)
+	}
+	return locations, ids, nil
+}
+
+// Checks the fork IDs announced by a peer against the local chains running
+// at the same locations. Locations not running locally are ignored.
+func (p *P2PNode) validateForkIDs(locations []common.Location, ids []forkid.ID) error {
+	local, _, err := p.localForkIDs()
+	if err != nil {
+		return err
+	}
+	for i, location := range locations {
+		for _, localLocation := range local {
+			if !localLocation.Equal(location) {
+				continue
+			}
+			if err := p.consensus.ValidateForkID(location, ids[i]); err != nil {
+				return fmt.Errorf("location %s: %w", location.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Writes the local fork IDs to the stream
+func (p *P2PNode) writeForkIDs(stream network.Stream) error {
+	locations, ids, err := p.localForkIDs()
+	if err != nil {
+		return err
+	}
+	data, err := pb.EncodeForkIDs(locations, ids)
+	if err != nil {
+		return err
+	}
+	return common.WriteMessageToStream(stream, data)
+}
+
+// Checks the fork IDs received from a peer and disconnects the peer if it
+// runs an incompatible chain or sent a malformed announcement
+func (p *P2PNode) checkForkIDs(peerID peer.ID, data []byte) {
+	locations, ids, err := pb.DecodeForkIDs(data)
+	if err == nil {
+		err = p.validateForkIDs(locations, ids)
+	}
+	if err != nil {
+		p.DropIncompatiblePeer(peerID, err)
+	}
+}
+
+// Handles the fork ID handshake opened by a peer. The peer sends its fork IDs
+// first, and is answered with the local ones.
+func (p *P2PNode) handleForkIDStream(stream network.Stream) {
+	defer stream.Close()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Global.WithFields(log.Fields{
+				"error":      r,
+				"stacktrace": string(debug.Stack()),
+			}).Error("Go-Quai Panicked")
+		}
+	}()
+	if p.consensus == nil {
+		return
+	}
+	peerID := stream.Conn().RemotePeer()
+	stream.SetDeadline(time.Now().Add(c_forkIDTimeout))
+
+	data, err := common.ReadMessageFromStream(stream)
+	if err != nil {
+		log.Global.WithFields(log.Fields{
+			"peer": peerID,
+			"err":  err,
+		}).Debug("Error reading fork IDs from peer")
+		return
+	}
+	if err := p.writeForkIDs(stream); err != nil {
+		log.Global.WithFields(log.Fields{
+			"peer": peerID,
+			"err":  err,
+		}).Debug("Error sending fork IDs to peer")
+		return
+	}
+	p.checkForkIDs(peerID, data)
+}
+
+// Opens the fork ID handshake with a newly connected peer
+func (p *P2PNode) exchangeForkIDs(peerID peer.ID) {
+	if p.consensus == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(p.ctx, c_forkIDTimeout)
+	defer cancel()
+
+	stream, err := p.peerManager.GetHost().NewStream(ctx, peerID, quaiprotocol.ForkIDProtocolVersion)
+	if err != nil {
+		// Peers running an older version do not support the handshake
+		log.Global.WithFields(log.Fields{
+			"peer": peerID,
+			"err":  err,
+		}).Debug("Error opening fork ID stream")
+		return
+	}
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(c_forkIDTimeout))
+
+	if err := p.writeForkIDs(stream); err != nil {
+		log.Global.WithFields(log.Fields{
+			"peer": peerID,
+			"err":  err,
+		}).Debug("Error sending fork IDs to peer")
+		return
+	}
+	data, err := common.ReadMessageFromStream(stream)
+	if err != nil {
+		log.Global.WithFields(log.Fields{
+			"peer": peerID,
+			"err":  err,
+		}).Debug("Error reading fork IDs from peer")
+		return
+	}
+	p.checkForkIDs(peerID, data)
+}
+
+// Disconnects a peer which runs a network or a chain incompatible with the local one
+func (p *P2PNode) DropIncompatiblePeer(peerID peer.ID, err error) {
+	log.Global.WithFields(log.Fields{
+		"peer": peerID,
+		"err":  err,
+	}).Warn("Disconnecting incompatible peer")
+
+	p.peerManager.DisconnectIncompatiblePeer(peerID)
+}
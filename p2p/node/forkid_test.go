@@ -0,0 +1,72 @@
+package node
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/forkid"
+	"github.com/dominant-strategies/go-quai/params"
+	"github.com/dominant-strategies/go-quai/quai"
+)
+
+// testConsensus runs the chains with the given genesis hashes, the other
+// methods of ConsensusAPI panic
+type testConsensus struct {
+	quai.ConsensusAPI
+	genesis map[string]common.Hash
+}
+
+func (c *testConsensus) ForkID(location common.Location) (forkid.ID, error) {
+	genesis, ok := c.genesis[string(location)]
+	if !ok {
+		return forkid.ID{}, errors.New("no backend found")
+	}
+	return forkid.NewID(params.TestChainConfig, genesis, 0, 0), nil
+}
+
+func (c *testConsensus) ValidateForkID(location common.Location, id forkid.ID) error {
+	genesis, ok := c.genesis[string(location)]
+	if !ok {
+		return errors.New("no backend found")
+	}
+	return forkid.NewStaticFilter(params.TestChainConfig, genesis)(id)
+}
+
+func TestValidateForkIDs(t *testing.T) {
+	var (
+		region = common.Location{0}
+		zone   = common.Location{0, 0}
+		other  = common.Location{0, 1}
+	)
+	local := &P2PNode{consensus: &testConsensus{genesis: map[string]common.Hash{
+		string(region): {0x01},
+		string(zone):   {0x02},
+	}}}
+	remote := &testConsensus{genesis: map[string]common.Hash{
+		string(region): {0x01},
+		string(zone):   {0x02},
+		string(other):  {0x03},
+	}}
+	id := func(location common.Location) forkid.ID {
+		id, err := remote.ForkID(location)
+		require.NoError(t, err)
+		return id
+	}
+
+	// The fork IDs of the shared chains match, the chains only run by the
+	// peer are ignored
+	require.NoError(t, local.validateForkIDs(
+		[]common.Location{region, zone, other},
+		[]forkid.ID{id(region), id(zone), id(other)},
+	))
+	// A fork ID announced for another location doesn't match the local chain
+	err := local.validateForkIDs([]common.Location{zone}, []forkid.ID{id(other)})
+	require.True(t, errors.Is(err, forkid.ErrLocalIncompatibleOrStale), "have %v", err)
+
+	// A chain on another genesis doesn't match the local chain
+	err = local.validateForkIDs([]common.Location{region}, []forkid.ID{forkid.NewID(params.TestChainConfig, common.Hash{0x04}, 0, 0)})
+	require.True(t, errors.Is(err, forkid.ErrLocalIncompatibleOrStale), "have %v", err)
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/dominant-strategies/go-quai/p2p/node/streamManager"
 	"github.com/dominant-strategies/go-quai/p2p/protocol"
 	"github.com/dominant-strategies/go-quai/quai"
+	"github.com/dominant-strategies/go-quai/trie"
 )
 
 const (
@@ -296,6 +297,10 @@ func (p *P2PNode) cacheAdd(hash common.Hash, data interface{}, location common.L
 
 // Get a datagram from the corresponding cache
 func (p *P2PNode) cacheGet(hash common.Hash, datatype interface{}, location common.Location) (interface{}, bool) {
+	if _, ok := datatype.(trie.TrieNodeRequest); ok {
+		// Trie nodes are not kept in the caches
+		return nil, false
+	}
 	cache := p.pickCache(datatype, location)
 	return cache.Get(hash)
 }
@@ -11,6 +11,7 @@ import (
 
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/crypto"
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/p2p/node/peerManager"
 	"github.com/dominant-strategies/go-quai/p2p/node/pubsubManager"
@@ -82,6 +83,11 @@ func (p *P2PNode) requestFromPeer(peerID peer.ID, topic *pubsubManager.Topic, re
 		return nil, nil
 	}
 
+	// The peer could not serve the request, there is no data to check
+	if respErr, ok := recvdType.(*pb.ResponseError); ok {
+		return nil, respErr
+	}
+
 	// Check the received data type & hash matches the request
 	switch respDataType.(type) {
 	// First, check that the recvdType is the same as the expected type
@@ -121,6 +127,21 @@ func (p *P2PNode) requestFromPeer(peerID peer.ID, topic *pubsubManager.Topic, re
 			return nil, errors.Errorf("invalid response: got block with different number")
 		}
 		return nil, errors.New("block request invalid response")
+	case *types.WorkObjectHeaderViews:
+		headerRange, ok := reqData.(types.HeaderRangeRequest)
+		if !ok {
+			return nil, errors.New("header ranges can only be requested by range")
+		}
+		if headers, ok := recvdType.(types.WorkObjectHeaderViews); ok {
+			if err := verifyHeaderRange(headerRange, headers, topic.GetLocation().Context()); err != nil {
+				log.Global.WithFields(log.Fields{
+					"peerId": peerID,
+					"err":    err,
+				}).Warn("Peer returned an invalid header range")
+			} else {
+				return headers, nil
+			}
+		}
 	case *types.Header:
 		if header, ok := recvdType.(*types.Header); ok && header.Hash() == reqData.(common.Hash) {
 			return header, nil
@@ -133,8 +154,9 @@ func (p *P2PNode) requestFromPeer(peerID peer.ID, topic *pubsubManager.Topic, re
 		if hash, ok := recvdType.(common.Hash); ok {
 			return hash, nil
 		}
-	case *trie.TrieNodeRequest:
-		if trieNode, ok := recvdType.(*trie.TrieNodeResponse); ok {
+	case trie.TrieNodeRequest:
+		// Trie nodes and contract codes are addressed by the hash of their content
+		if trieNode, ok := recvdType.(*trie.TrieNodeResponse); ok && crypto.Keccak256Hash(trieNode.NodeData) == reqData.(common.Hash) {
 			return trieNode, nil
 		}
 	default:
@@ -146,6 +168,29 @@ func (p *P2PNode) requestFromPeer(peerID peer.ID, topic *pubsubManager.Topic, re
 	return nil, errors.New("invalid response")
 }
 
+// verifyHeaderRange checks that the headers returned by a peer are the ones asked
+// for in the range request, and that a contiguous range links up by parent hash
+func verifyHeaderRange(headerRange types.HeaderRangeRequest, headers types.WorkObjectHeaderViews, nodeCtx int) error {
+	if len(headers) == 0 || uint64(len(headers)) > headerRange.Count {
+		return errors.Errorf("invalid number of headers: got %d, requested %d", len(headers), headerRange.Count)
+	}
+	step := new(big.Int).SetUint64(headerRange.Skip + 1)
+	number := new(big.Int).Set(headerRange.Start)
+	for i, header := range headers {
+		if header == nil || header.WorkObject == nil {
+			return errors.New("nil header in range")
+		}
+		if header.Number(nodeCtx).Cmp(number) != 0 {
+			return errors.Errorf("header %d has number %s, expected %s", i, header.Number(nodeCtx), number)
+		}
+		if headerRange.Skip == 0 && i > 0 && header.ParentHash(nodeCtx) != headers[i-1].Hash() {
+			return errors.Errorf("header %d does not link to its predecessor", i)
+		}
+		number = new(big.Int).Add(number, step)
+	}
+	return nil
+}
+
 func (p *P2PNode) GetRequestManager() requestManager.RequestManager {
 	return p.requestManager
 }
@@ -42,6 +42,13 @@ const (
 
 	// The number of peers to return when querying for peers
 	C_peerCount = 3
+
+	// Name of the database holding the status announced by each peer
+	c_statusDBName = "peerStatusDB"
+
+	// Connection manager score of the peers found on an incompatible fork,
+	// which makes them the first to be pruned if they connect again
+	c_incompatiblePeerScore = -100
 )
 
 type PeerQuality int
@@ -88,9 +95,15 @@ type PeerManager interface {
 	// Removes a peer from all the quality buckets
 	RemovePeer(p2p.PeerID) error
 
+	// Records the status announced by the peer in the status handshake
+	SetPeerStatus(p2p.PeerID, *peerdb.PeerInfo) error
+	// Returns the status announced by the peer, or peerdb.ErrPeerNotFound
+	GetPeerStatus(p2p.PeerID) (*peerdb.PeerInfo, error)
+
 	// Returns c_peerCount peers starting at the requested quality level of peers
 	// If there are not enough peers at the requested quality, it will return lower quality peers
 	// If there still aren't enough peers, it will query the DHT for more
+	// Peers known to serve the location of the topic are preferred
 	GetPeers(topic *pubsubManager.Topic, quality PeerQuality) map[p2p.PeerID]struct{}
 
 	// Increases the peer's liveliness score
@@ -102,6 +115,9 @@ type PeerManager interface {
 	MarkResponsivePeer(peerID p2p.PeerID, topic *pubsubManager.Topic)
 	// Decreases the peer's liveliness score. Not exposed outside of NetworkingAPI
 	MarkUnresponsivePeer(peerID p2p.PeerID, topic *pubsubManager.Topic)
+	// Records that the peer answered a request without the data, because it does
+	// not have it. The peer still counts as responsive. Not exposed outside of NetworkingAPI
+	MarkNotFoundPeer(peerID p2p.PeerID, topic *pubsubManager.Topic)
 
 	// Protects the peer's connection from being disconnected
 	ProtectPeer(p2p.PeerID)
@@ -109,6 +125,8 @@ type PeerManager interface {
 	UnprotectPeer(p2p.PeerID)
 	// Bans the peer's connection from being re-established
 	BanPeer(p2p.PeerID)
+	// Lowers the peer's score and disconnects it for running an incompatible fork
+	DisconnectIncompatiblePeer(p2p.PeerID)
 
 	// Stops the peer manager
 	Stop() error
@@ -124,6 +142,9 @@ type BasicPeerManager struct {
 	// Tracks peers in different quality buckets
 	peerDBs map[string][]*peerdb.PeerDB
 
+	// Holds the status announced by each peer
+	statusDB *peerdb.PeerDB
+
 	// DHT instance
 	dht *dual.DHT
 
@@ -199,6 +220,11 @@ func NewManager(ctx context.Context, low int, high int, datastore datastore.Data
 		}
 	}
 
+	statusDB, err := peerdb.NewPeerDB(c_statusDBName, "peers")
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 
 	logger := log.NewLogger("peers.log", viper.GetString(utils.PeersLogLevelFlag.Name))
@@ -257,6 +283,7 @@ func NewManager(ctx context.Context, low int, high int, datastore datastore.Data
 		BasicConnectionGater: gater,
 		genesis:              utils.MakeGenesis().ToBlock(0).Hash(),
 		peerDBs:              peerDBs,
+		statusDB:             statusDB,
 		logger:               logger,
 	}, nil
 }
@@ -278,6 +305,10 @@ func (pm *BasicPeerManager) RemovePeer(peerID p2p.PeerID) error {
 	if err != nil {
 		return err
 	}
+	err = pm.removePeerStatus(peerID)
+	if err != nil {
+		return err
+	}
 	return pm.streamManager.CloseStream(peerID)
 }
 
@@ -303,6 +334,66 @@ func (pm *BasicPeerManager) removePeerFromTopic(peerID p2p.PeerID, topicStr stri
 	return nil
 }
 
+func (pm *BasicPeerManager) SetPeerStatus(peerID p2p.PeerID, info *peerdb.PeerInfo) error {
+	peerInfo, err := proto.Marshal(info.ProtoEncode())
+	if err != nil {
+		return errors.Wrap(err, "error marshaling peer info")
+	}
+	key := datastore.NewKey(peerID.String())
+	if exists, _ := pm.statusDB.Has(pm.ctx, key); exists {
+		// Keep the peer count of the database exact
+		if err := pm.statusDB.Delete(pm.ctx, key); err != nil {
+			return err
+		}
+	}
+	return pm.statusDB.Put(pm.ctx, key, peerInfo)
+}
+
+func (pm *BasicPeerManager) GetPeerStatus(peerID p2p.PeerID) (*peerdb.PeerInfo, error) {
+	data, err := pm.statusDB.Get(pm.ctx, datastore.NewKey(peerID.String()))
+	if err != nil {
+		if err == datastore.ErrNotFound {
+			return nil, peerdb.ErrPeerNotFound
+		}
+		return nil, err
+	}
+	protoInfo := &peerdb.ProtoPeerInfo{}
+	if err := proto.Unmarshal(data, protoInfo); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling peer info")
+	}
+	info := &peerdb.PeerInfo{}
+	if err := info.ProtoDecode(protoInfo); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Removes the status of the peer. Does not return an error if the peer is not found
+func (pm *BasicPeerManager) removePeerStatus(peerID p2p.PeerID) error {
+	key := datastore.NewKey(peerID.String())
+	if exists, _ := pm.statusDB.Has(pm.ctx, key); exists {
+		return pm.statusDB.Delete(pm.ctx, key)
+	}
+	return nil
+}
+
+// Drops the peers which announced they do not run the chain at the given
+// location, unless none of the peers is known to run it. Peers which have not
+// announced their status are kept.
+func (pm *BasicPeerManager) preferServingPeers(location common.Location, peerList map[p2p.PeerID]struct{}) map[p2p.PeerID]struct{} {
+	servingPeers := make(map[p2p.PeerID]struct{}, len(peerList))
+	for peerID := range peerList {
+		info, err := pm.GetPeerStatus(peerID)
+		if err != nil || info.Serves(location) {
+			servingPeers[peerID] = struct{}{}
+		}
+	}
+	if len(servingPeers) == 0 {
+		return peerList
+	}
+	return servingPeers
+}
+
 func (pm *BasicPeerManager) SetSelfID(selfID p2p.PeerID) {
 	pm.selfID = selfID
 }
@@ -344,6 +435,7 @@ func (pm *BasicPeerManager) GetPeers(topic *pubsubManager.Topic, quality PeerQua
 	default:
 		panic("Invalid peer quality")
 	}
+	peerList = pm.preferServingPeers(topic.GetLocation(), peerList)
 
 	lenPeer := len(peerList)
 	if lenPeer >= C_peerCount {
@@ -441,12 +533,18 @@ func (pm *BasicPeerManager) MarkUnresponsivePeer(peer p2p.PeerID, topic *pubsubM
 	pm.recategorizePeer(peer, topic)
 }
 
+func (pm *BasicPeerManager) MarkNotFoundPeer(peer p2p.PeerID, topic *pubsubManager.Topic) {
+	pm.TagPeer(peer, "responses_not_found", 1)
+	pm.recategorizePeer(peer, topic)
+}
+
 func (pm *BasicPeerManager) calculatePeerResponsiveness(peer p2p.PeerID) float64 {
 	peerTag := pm.GetTagInfo(peer)
 	if peerTag == nil {
 		return 0
 	}
-	responses := peerTag.Tags["responses_served"]
+	// Answering that the data is not found is still a response
+	responses := peerTag.Tags["responses_served"] + peerTag.Tags["responses_not_found"]
 	misses := peerTag.Tags["responses_missed"]
 	return float64(responses) / float64(misses)
 }
@@ -520,6 +618,20 @@ func (pm *BasicPeerManager) BanPeer(peer p2p.PeerID) {
 	pm.BlockPeer(peer)
 }
 
+func (pm *BasicPeerManager) DisconnectIncompatiblePeer(peerID p2p.PeerID) {
+	if peerID == pm.selfID {
+		return
+	}
+	pm.TagPeer(peerID, "incompatible_fork", c_incompatiblePeerScore)
+	if err := pm.RemovePeer(peerID); err != nil {
+		pm.logger.WithFields(log.Fields{
+			"peer": peerID,
+			"err":  err,
+		}).Debug("Error removing incompatible peer")
+	}
+	pm.GetHost().Network().ClosePeer(peerID)
+}
+
 func (pm *BasicPeerManager) Stop() error {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
@@ -528,6 +640,7 @@ func (pm *BasicPeerManager) Stop() error {
 	closeFuncs := []func() error{
 		pm.BasicConnMgr.Close,
 		pm.dht.Close,
+		pm.statusDB.Close,
 	}
 
 	wg.Add(len(closeFuncs))
@@ -0,0 +1,74 @@
+package peerManager
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dominant-strategies/go-quai/cmd/utils"
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/p2p/node/peerManager/peerdb"
+	"github.com/dominant-strategies/go-quai/p2p/pb"
+)
+
+// openStatusDB opens the peer status database in the data directory
+func openStatusDB(t *testing.T) *BasicPeerManager {
+	t.Helper()
+	statusDB, err := peerdb.NewPeerDB(c_statusDBName, "peers")
+	require.NoError(t, err)
+	return &BasicPeerManager{ctx: context.Background(), statusDB: statusDB}
+}
+
+func TestPeerStatusPersisted(t *testing.T) {
+	viper.GetViper().Set(utils.DataDirFlag.Name, t.TempDir())
+
+	var (
+		peerID = peer.ID("peer")
+		zone   = common.Location{0, 0}
+		heads  = []pb.ChainHead{
+			{Location: common.Location{0}, Hash: common.Hash{0x01}, Entropy: big.NewInt(1)},
+			{Location: zone, Hash: common.Hash{0x02}, Entropy: big.NewInt(2)},
+		}
+	)
+	pm := openStatusDB(t)
+	_, err := pm.GetPeerStatus(peerID)
+	require.ErrorIs(t, err, peerdb.ErrPeerNotFound)
+
+	// Overwriting the status keeps a single entry for the peer
+	require.NoError(t, pm.SetPeerStatus(peerID, &peerdb.PeerInfo{Version: 1}))
+	require.NoError(t, pm.SetPeerStatus(peerID, &peerdb.PeerInfo{Version: 2, Heads: heads}))
+	require.Equal(t, 1, pm.statusDB.GetPeerCount())
+	require.NoError(t, pm.statusDB.Close())
+
+	// The status announced by the peer is loaded back after a restart
+	pm = openStatusDB(t)
+	defer pm.statusDB.Close()
+
+	info, err := pm.GetPeerStatus(peerID)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), info.Version)
+	require.Len(t, info.Heads, len(heads))
+	for i, head := range heads {
+		require.True(t, head.Location.Equal(info.Heads[i].Location))
+		require.Equal(t, head.Hash, info.Heads[i].Hash)
+		require.Zero(t, head.Entropy.Cmp(info.Heads[i].Entropy))
+	}
+	require.True(t, info.Serves(zone))
+	require.False(t, info.Serves(common.Location{0, 1}))
+
+	// Peers without a status are kept, peers which announced they don't run
+	// the location are dropped
+	other := peer.ID("other")
+	peers := map[peer.ID]struct{}{peerID: {}, other: {}}
+	require.Equal(t, peers, pm.preferServingPeers(zone, peers))
+	require.NoError(t, pm.SetPeerStatus(other, &peerdb.PeerInfo{Version: 2, Heads: heads[:1]}))
+	require.Equal(t, map[peer.ID]struct{}{peerID: {}}, pm.preferServingPeers(zone, peers))
+
+	require.NoError(t, pm.removePeerStatus(peerID))
+	_, err = pm.GetPeerStatus(peerID)
+	require.ErrorIs(t, err, peerdb.ErrPeerNotFound)
+}
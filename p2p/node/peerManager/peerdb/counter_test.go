@@ -3,7 +3,6 @@ package peerdb
 import (
 	"context"
 	"encoding/json"
-	"os"
 	"testing"
 
 	"github.com/dominant-strategies/go-quai/cmd/utils"
@@ -14,7 +13,7 @@ import (
 )
 
 func TestCounter(t *testing.T) {
-	viper.GetViper().Set(utils.DataDirFlag.Name, os.TempDir())
+	viper.GetViper().Set(utils.DataDirFlag.Name, t.TempDir())
 	dbDir := "testdb"
 	location := common.Location{0, 0}
 	locationName := location.Name()
@@ -27,14 +26,9 @@ func TestCounter(t *testing.T) {
 
 	t.Cleanup(
 		func() {
-			// close the db
+			// close the db, the data directory is removed by the test
 			err := ps.Close()
 			require.NoError(t, err)
-
-			// remove the db file
-			dbFile := viper.GetString(utils.DataDirFlag.Name) + "/" + locationName + dbDir
-			err = os.RemoveAll(dbFile)
-			require.NoError(t, err)
 		},
 	)
 
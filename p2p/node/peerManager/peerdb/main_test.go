@@ -24,7 +24,7 @@ func TestMain(m *testing.M) {
 
 func setupDB(t *testing.T) (*PeerDB, func()) {
 	t.Helper()
-	viper.GetViper().Set(utils.DataDirFlag.Name, os.TempDir())
+	viper.GetViper().Set(utils.DataDirFlag.Name, t.TempDir())
 	dbDir := "testdb"
 	location := common.Location{0, 0}
 	locationName := location.Name()
@@ -34,14 +34,9 @@ func setupDB(t *testing.T) (*PeerDB, func()) {
 	require.NoError(t, err)
 
 	return ps, func() {
-		// close the db
+		// close the db, the data directory is removed by the test
 		err := ps.Close()
 		require.NoError(t, err)
-
-		// remove the db file
-		dbFile := viper.GetString(utils.DataDirFlag.Name) + "/" + locationName + dbDir
-		err = os.RemoveAll(dbFile)
-		require.NoError(t, err)
 	}
 }
 
@@ -71,10 +66,10 @@ func createPeers(t *testing.T, count int) []*PeerInfo {
 	for i := 0; i < count; i++ {
 		pubKey, peerID := generateKeyAndID(t)
 		peerInfo := &PeerInfo{
-			AddrInfo: peer.AddrInfo{
+			AddrInfo: AddrInfo{peer.AddrInfo{
 				ID:    peerID,
 				Addrs: addrs,
-			},
+			}},
 			PubKey:    pubKey,
 			Entropy:   entropy,
 			Protected: protected,
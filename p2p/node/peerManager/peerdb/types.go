@@ -6,6 +6,9 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
 	"github.com/syndtr/goleveldb/leveldb"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/p2p/pb"
 )
 
 // contains the information of a peer
@@ -15,6 +18,10 @@ type PeerInfo struct {
 	PubKey    []byte
 	Entropy   uint64
 	Protected bool
+	// Version of the status handshake run by the peer
+	Version uint32
+	// Heads of the chains the peer announced to run
+	Heads []pb.ChainHead
 }
 
 type AddrInfo struct {
@@ -33,12 +40,18 @@ type PeerDB struct {
 // ProtoEncode converts the hash into the ProtoHash type
 func (pi *PeerInfo) ProtoEncode() *ProtoPeerInfo {
 	addrInfo := pi.AddrInfo
+	heads := make([]*pb.ProtoChainHead, len(pi.Heads))
+	for i, head := range pi.Heads {
+		heads[i] = head.ProtoEncode()
+	}
 
 	return &ProtoPeerInfo{
 		AddrInfo:  addrInfo.ProtoEncode(),
 		PubKey:    pi.PubKey,
 		Entropy:   pi.Entropy,
 		Protected: pi.Protected,
+		Version:   pi.Version,
+		Heads:     heads,
 	}
 }
 
@@ -46,9 +59,26 @@ func (pi *PeerInfo) ProtoDecode(ppi *ProtoPeerInfo) error {
 	pi.PubKey = ppi.PubKey
 	pi.Entropy = ppi.Entropy
 	pi.Protected = ppi.Protected
+	pi.Version = ppi.Version
+	pi.Heads = make([]pb.ChainHead, len(ppi.Heads))
+	for i, head := range ppi.Heads {
+		if err := pi.Heads[i].ProtoDecode(head); err != nil {
+			return err
+		}
+	}
 	return pi.AddrInfo.ProtoDecode(ppi.AddrInfo)
 }
 
+// Serves returns true if the peer announced to run the chain at the given location
+func (pi *PeerInfo) Serves(location common.Location) bool {
+	for _, head := range pi.Heads {
+		if head.Location.Equal(location) {
+			return true
+		}
+	}
+	return false
+}
+
 func (addr *AddrInfo) ProtoEncode() *ProtoAddrInfo {
 	multiAddrs := make([]string, len(addr.Addrs))
 	for i, addr := range addr.Addrs {
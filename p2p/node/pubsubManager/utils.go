@@ -8,6 +8,7 @@ import (
 
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/trie"
 	"github.com/ipfs/go-cid"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -38,11 +39,11 @@ func (t *Topic) buildTopicString() string {
 	encodedLocation := strings.Join(parts, ",")
 	baseTopic := strings.Join([]string{t.genesis.String(), encodedLocation}, "/")
 	switch t.data.(type) {
-	case *types.WorkObjectHeaderView, *big.Int, common.Hash:
+	case *types.WorkObjectHeaderView, *types.WorkObjectHeaderViews, *big.Int, common.Hash:
 		return strings.Join([]string{baseTopic, C_headerType}, "/")
-	case *types.WorkObjectBlockView:
+	case *types.WorkObjectBlockView, trie.TrieNodeRequest:
 		return strings.Join([]string{baseTopic, C_workObjectType}, "/")
-	case *types.Transactions:
+	case *types.Transactions, *types.Transaction:
 		return strings.Join([]string{baseTopic, C_transactionType}, "/")
 	case *types.WorkObjectHeader:
 		return strings.Join([]string{baseTopic, C_workObjectHeaderType}, "/")
@@ -66,7 +67,7 @@ func (t *Topic) GetTopicType() interface{} {
 // gets the name of the topic for the given type of data
 func NewTopic(genesis common.Hash, location common.Location, data interface{}) (*Topic, error) {
 	switch data.(type) {
-	case *types.WorkObjectHeader, *types.WorkObjectHeaderView, *types.WorkObjectBlockView, common.Hash, *types.Transactions:
+	case *types.WorkObjectHeader, *types.WorkObjectHeaderView, *types.WorkObjectHeaderViews, *types.WorkObjectBlockView, common.Hash, *types.Transactions, *types.Transaction, trie.TrieNodeRequest:
 		t := &Topic{
 			genesis:  genesis,
 			location: location,
@@ -64,6 +64,14 @@ type basicStreamManager struct {
 type streamWrapper struct {
 	stream    network.Stream
 	semaphore chan struct{}
+	join      *joinResult
+}
+
+// joinResult is the outcome of the status handshake run on a new stream. done
+// is closed once the handshake completes, err is set before.
+type joinResult struct {
+	done chan struct{}
+	err  error
 }
 
 func NewStreamManager(peerCount int, node quaiprotocol.QuaiP2PNode, host host.Host) (*basicStreamManager, error) {
@@ -109,32 +117,62 @@ func (sm *basicStreamManager) CloseStream(peerID p2p.PeerID) error {
 
 func (sm *basicStreamManager) GetStream(peerID p2p.PeerID) (network.Stream, error) {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
 	wrappedStream, ok := sm.streamCache.Get(peerID)
-	var err error
-	if !ok {
-		// Create a new stream to the peer and register it in the cache
-		stream, err := sm.host.NewStream(sm.ctx, peerID, quaiprotocol.ProtocolVersion)
-		if err != nil {
-			// Explicitly return nil here to avoid casting a nil later
-			return nil, err
-		}
-		wrappedStream = streamWrapper{
-			stream:    stream,
-			semaphore: make(chan struct{}, c_maxPendingRequests),
+	if ok {
+		sm.mu.Unlock()
+		log.Global.Trace("Requested stream was found in cache")
+		// Wait for the handshake if the stream was just opened by another caller
+		<-wrappedStream.join.done
+		if wrappedStream.join.err != nil {
+			return nil, wrappedStream.join.err
 		}
-		sm.streamCache.Add(peerID, wrappedStream)
-		go quaiprotocol.QuaiProtocolHandler(stream, sm.p2pBackend)
-		log.Global.Debug("Had to create new stream")
-		if streamMetrics != nil {
-			streamMetrics.WithLabelValues("NumStreams").Inc()
+		return wrappedStream.stream, nil
+	}
+	// Create a new stream to the peer and register it in the cache
+	stream, err := sm.host.NewStream(sm.ctx, peerID, quaiprotocol.ProtocolVersion)
+	if err != nil {
+		sm.mu.Unlock()
+		// Explicitly return nil here to avoid casting a nil later
+		return nil, err
+	}
+	wrappedStream = streamWrapper{
+		stream:    stream,
+		semaphore: make(chan struct{}, c_maxPendingRequests),
+		join:      &joinResult{done: make(chan struct{})},
+	}
+	sm.streamCache.Add(peerID, wrappedStream)
+	log.Global.Debug("Had to create new stream")
+	if streamMetrics != nil {
+		streamMetrics.WithLabelValues("NumStreams").Inc()
+	}
+	sm.mu.Unlock()
+
+	// Join the peer's network before sending any request on the stream. The
+	// handshake runs without the lock, as it can take up to the status timeout
+	// and dropping an incompatible peer closes its stream.
+	err = quaiprotocol.SendJoinRequest(stream, sm.p2pBackend)
+	wrappedStream.join.err = err
+	close(wrappedStream.join.done)
+	if err != nil {
+		sm.removeStream(peerID, stream)
+		if errors.Is(err, quaiprotocol.ErrIncompatiblePeer) {
+			sm.p2pBackend.DropIncompatiblePeer(peerID, err)
 		}
-	} else {
-		log.Global.Trace("Requested stream was found in cache")
+		return nil, err
 	}
+	go quaiprotocol.QuaiProtocolHandler(stream, sm.p2pBackend)
+	return stream, nil
+}
 
-	return wrappedStream.stream, err
+// removeStream closes and removes the stream of the peer from the cache, unless
+// it was already replaced by another one.
+func (sm *basicStreamManager) removeStream(peerID p2p.PeerID, stream network.Stream) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if wrappedStream, ok := sm.streamCache.Peek(peerID); ok && wrappedStream.stream == stream {
+		sm.streamCache.Remove(peerID)
+	}
 }
 
 func (sm *basicStreamManager) SetP2PBackend(host quaiprotocol.QuaiP2PNode) {
@@ -21,4 +21,5 @@ func registerMetrics() {
 	messageMetrics.WithLabelValues("transactions")
 	messageMetrics.WithLabelValues("requests")
 	messageMetrics.WithLabelValues("responses")
+	messageMetrics.WithLabelValues("errors")
 }
@@ -7,6 +7,7 @@ import (
 	"google.golang.org/protobuf/proto"
 
 	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/forkid"
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/trie"
@@ -33,6 +34,11 @@ func EncodeQuaiRequest(id uint32, location common.Location, reqData interface{},
 		reqMsg.Data = &QuaiRequestMessage_Hash{Hash: d.ProtoEncode()}
 	case *big.Int:
 		reqMsg.Data = &QuaiRequestMessage_Number{Number: d.Bytes()}
+	case types.HeaderRangeRequest:
+		if d.Start == nil {
+			return nil, errors.New("header range start is nil")
+		}
+		reqMsg.Data = &QuaiRequestMessage_HeaderRange{HeaderRange: &ProtoHeaderRange{Start: d.Start.Bytes(), Count: d.Count, Skip: d.Skip}}
 	default:
 		return nil, errors.Errorf("unsupported request input data field type: %T", reqData)
 	}
@@ -48,6 +54,8 @@ func EncodeQuaiRequest(id uint32, location common.Location, reqData interface{},
 		reqMsg.Request = &QuaiRequestMessage_BlockHash{}
 	case trie.TrieNodeRequest:
 		reqMsg.Request = &QuaiRequestMessage_TrieNode{}
+	case *types.WorkObjectHeaderViews:
+		reqMsg.Request = &QuaiRequestMessage_WorkObjectHeaderViews{}
 	default:
 		return nil, errors.Errorf("unsupported request data type: %T", respDataType)
 	}
@@ -78,6 +86,15 @@ func DecodeQuaiRequest(reqMsg *QuaiRequestMessage) (uint32, interface{}, common.
 		reqData = hash
 	case *QuaiRequestMessage_Number:
 		reqData = new(big.Int).SetBytes(d.Number)
+	case *QuaiRequestMessage_HeaderRange:
+		if d.HeaderRange == nil {
+			return reqMsg.Id, nil, common.Location{}, common.Hash{}, errors.New("header range is nil")
+		}
+		reqData = &types.HeaderRangeRequest{
+			Start: new(big.Int).SetBytes(d.HeaderRange.Start),
+			Count: d.HeaderRange.Count,
+			Skip:  d.HeaderRange.Skip,
+		}
 	}
 
 	// Decode the request type
@@ -95,6 +112,8 @@ func DecodeQuaiRequest(reqMsg *QuaiRequestMessage) (uint32, interface{}, common.
 		reqType = blockHash
 	case *QuaiRequestMessage_TrieNode:
 		reqType = trie.TrieNodeRequest{}
+	case *QuaiRequestMessage_WorkObjectHeaderViews:
+		reqType = &types.WorkObjectHeaderViews{}
 	default:
 		return reqMsg.Id, nil, common.Location{}, common.Hash{}, errors.Errorf("unsupported request type: %T", reqMsg.Request)
 	}
@@ -102,6 +121,30 @@ func DecodeQuaiRequest(reqMsg *QuaiRequestMessage) (uint32, interface{}, common.
 	return reqMsg.Id, reqType, *location, reqData, nil
 }
 
+// ResponseError is the answer of a peer which could not serve a request. It is
+// returned by DecodeQuaiResponse in place of the requested data.
+type ResponseError struct {
+	Status  ResponseStatus
+	Message string
+}
+
+// NewResponseError creates a ResponseError with the given status
+func NewResponseError(status ResponseStatus, message string) *ResponseError {
+	return &ResponseError{Status: status, Message: message}
+}
+
+func (e *ResponseError) Error() string {
+	if e.Message == "" {
+		return e.Status.String()
+	}
+	return e.Status.String() + ": " + e.Message
+}
+
+// NotFound reports whether the peer answered that it does not have the requested data
+func (e *ResponseError) NotFound() bool {
+	return e.Status == ResponseStatus_RESPONSE_STATUS_NOT_FOUND
+}
+
 // EncodeResponse creates a marshaled protobuf message for a Quai Response.
 // Returns the serialized protobuf message.
 func EncodeQuaiResponse(id uint32, location common.Location, data interface{}) ([]byte, error) {
@@ -140,6 +183,20 @@ func EncodeQuaiResponse(id uint32, location common.Location, data interface{}) (
 	case *common.Hash:
 		respMsg.Response = &QuaiResponseMessage_BlockHash{BlockHash: data.ProtoEncode()}
 
+	case types.WorkObjectHeaderViews:
+		protoHeaders := &ProtoWorkObjectHeaderViews{WorkObjectHeaderViews: make([]*types.ProtoWorkObjectHeaderView, len(data))}
+		for i, header := range data {
+			protoHeader, err := header.ProtoEncode()
+			if err != nil {
+				return nil, err
+			}
+			protoHeaders.WorkObjectHeaderViews[i] = protoHeader
+		}
+		respMsg.Response = &QuaiResponseMessage_WorkObjectHeaderViews{WorkObjectHeaderViews: protoHeaders}
+
+	case *ResponseError:
+		respMsg.Response = &QuaiResponseMessage_Error{Error: &QuaiResponseError{Status: data.Status, Message: data.Message}}
+
 	default:
 		return nil, errors.Errorf("unsupported response data type: %T", data)
 	}
@@ -214,6 +271,28 @@ func DecodeQuaiResponse(respMsg *QuaiResponseMessage) (uint32, interface{}, erro
 		protoTrieNode := respMsg.GetTrieNode()
 		trieNode := &trie.TrieNodeResponse{NodeData: protoTrieNode.ProtoNodeData}
 		return id, trieNode, nil
+	case *QuaiResponseMessage_WorkObjectHeaderViews:
+		protoHeaders := respMsg.GetWorkObjectHeaderViews().GetWorkObjectHeaderViews()
+		headers := make(types.WorkObjectHeaderViews, len(protoHeaders))
+		for i, protoHeader := range protoHeaders {
+			header := &types.WorkObjectHeaderView{
+				WorkObject: &types.WorkObject{},
+			}
+			if err := header.ProtoDecode(protoHeader, *sourceLocation); err != nil {
+				return id, nil, err
+			}
+			headers[i] = header
+		}
+		if messageMetrics != nil {
+			messageMetrics.WithLabelValues("headers").Add(float64(len(headers)))
+		}
+		return id, headers, nil
+	case *QuaiResponseMessage_Error:
+		protoError := respMsg.GetError()
+		if messageMetrics != nil {
+			messageMetrics.WithLabelValues("errors").Inc()
+		}
+		return id, &ResponseError{Status: protoError.GetStatus(), Message: protoError.GetMessage()}, nil
 	default:
 		return id, nil, errors.Errorf("unsupported response type: %T", respMsg.Response)
 	}
@@ -358,3 +437,120 @@ func UnmarshalAndConvert(data []byte, sourceLocation common.Location, dataPtr *i
 		return errors.New("unsupported data type")
 	}
 }
+
+// EncodeForkIDs creates a marshaled protobuf message announcing the fork
+// identifiers of the chains running at the given locations.
+func EncodeForkIDs(locations []common.Location, ids []forkid.ID) ([]byte, error) {
+	if len(locations) != len(ids) {
+		return nil, errors.New("mismatching number of locations and fork ids")
+	}
+	msg := QuaiForkIDMessage{ForkIds: make([]*ProtoForkID, len(ids))}
+	for i, id := range ids {
+		msg.ForkIds[i] = &ProtoForkID{
+			Location: locations[i].ProtoEncode(),
+			Hash:     common.CopyBytes(id.Hash[:]),
+			Next:     id.Next,
+		}
+	}
+	return proto.Marshal(&msg)
+}
+
+// DecodeForkIDs unmarshals a fork identifier announcement.
+// Returns the locations and the fork identifiers of their chains.
+func DecodeForkIDs(data []byte) ([]common.Location, []forkid.ID, error) {
+	msg := &QuaiForkIDMessage{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, nil, err
+	}
+	locations := make([]common.Location, len(msg.ForkIds))
+	ids := make([]forkid.ID, len(msg.ForkIds))
+	for i, protoID := range msg.ForkIds {
+		if protoID.Location == nil {
+			return nil, nil, errors.New("location is nil")
+		}
+		if len(protoID.Hash) != len(ids[i].Hash) {
+			return nil, nil, errors.Errorf("invalid fork hash length %d", len(protoID.Hash))
+		}
+		locations[i].ProtoDecode(protoID.Location)
+		copy(ids[i].Hash[:], protoID.Hash)
+		ids[i].Next = protoID.Next
+	}
+	return locations, ids, nil
+}
+
+// ChainHead is the head of a chain run by a node
+type ChainHead struct {
+	Location common.Location
+	Hash     common.Hash
+	Entropy  *big.Int
+}
+
+// ProtoEncode converts the chain head into the ProtoChainHead type
+func (head *ChainHead) ProtoEncode() *ProtoChainHead {
+	var entropy []byte
+	if head.Entropy != nil {
+		entropy = head.Entropy.Bytes()
+	}
+	return &ProtoChainHead{
+		Location: head.Location.ProtoEncode(),
+		Hash:     head.Hash.ProtoEncode(),
+		Entropy:  entropy,
+	}
+}
+
+// ProtoDecode converts the ProtoChainHead type into the chain head
+func (head *ChainHead) ProtoDecode(protoHead *ProtoChainHead) error {
+	if protoHead.Location == nil || protoHead.Hash == nil {
+		return errors.New("chain head is incomplete")
+	}
+	head.Location.ProtoDecode(protoHead.Location)
+	head.Hash.ProtoDecode(protoHead.Hash)
+	head.Entropy = new(big.Int).SetBytes(protoHead.Entropy)
+	return nil
+}
+
+// PeerStatus is the status exchanged by two peers when a QuaiProtocol stream
+// opens. It lists the head of every chain the node runs.
+type PeerStatus struct {
+	Version uint32
+	Genesis common.Hash
+	Heads   []ChainHead
+}
+
+// EncodeStatus creates a marshaled protobuf message announcing the given status.
+func EncodeStatus(status *PeerStatus) ([]byte, error) {
+	msg := QuaiStatusMessage{
+		Version: status.Version,
+		Genesis: status.Genesis.ProtoEncode(),
+		Heads:   make([]*ProtoChainHead, len(status.Heads)),
+	}
+	for i, head := range status.Heads {
+		if head.Entropy == nil {
+			return nil, errors.New("head entropy is nil")
+		}
+		msg.Heads[i] = head.ProtoEncode()
+	}
+	return proto.Marshal(&msg)
+}
+
+// DecodeStatus unmarshals a status announced by a peer.
+func DecodeStatus(data []byte) (*PeerStatus, error) {
+	msg := &QuaiStatusMessage{}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	if msg.Genesis == nil {
+		return nil, errors.New("genesis is nil")
+	}
+	status := &PeerStatus{
+		Version: msg.Version,
+		Heads:   make([]ChainHead, len(msg.Heads)),
+	}
+	status.Genesis.ProtoDecode(msg.Genesis)
+	for i, protoHead := range msg.Heads {
+		if err := status.Heads[i].ProtoDecode(protoHead); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
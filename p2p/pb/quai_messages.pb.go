@@ -23,6 +23,59 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// ResponseStatus tells the requester why the requested data was not sent
+type ResponseStatus int32
+
+const (
+	ResponseStatus_RESPONSE_STATUS_UNSPECIFIED     ResponseStatus = 0
+	ResponseStatus_RESPONSE_STATUS_NOT_FOUND       ResponseStatus = 1
+	ResponseStatus_RESPONSE_STATUS_INVALID_REQUEST ResponseStatus = 2
+	ResponseStatus_RESPONSE_STATUS_INTERNAL_ERROR  ResponseStatus = 3
+)
+
+// Enum value maps for ResponseStatus.
+var (
+	ResponseStatus_name = map[int32]string{
+		0: "RESPONSE_STATUS_UNSPECIFIED",
+		1: "RESPONSE_STATUS_NOT_FOUND",
+		2: "RESPONSE_STATUS_INVALID_REQUEST",
+		3: "RESPONSE_STATUS_INTERNAL_ERROR",
+	}
+	ResponseStatus_value = map[string]int32{
+		"RESPONSE_STATUS_UNSPECIFIED":     0,
+		"RESPONSE_STATUS_NOT_FOUND":       1,
+		"RESPONSE_STATUS_INVALID_REQUEST": 2,
+		"RESPONSE_STATUS_INTERNAL_ERROR":  3,
+	}
+)
+
+func (x ResponseStatus) Enum() *ResponseStatus {
+	p := new(ResponseStatus)
+	*p = x
+	return p
+}
+
+func (x ResponseStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ResponseStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_p2p_pb_quai_messages_proto_enumTypes[0].Descriptor()
+}
+
+func (ResponseStatus) Type() protoreflect.EnumType {
+	return &file_p2p_pb_quai_messages_proto_enumTypes[0]
+}
+
+func (x ResponseStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ResponseStatus.Descriptor instead.
+func (ResponseStatus) EnumDescriptor() ([]byte, []int) {
+	return file_p2p_pb_quai_messages_proto_rawDescGZIP(), []int{0}
+}
+
 // GossipSub messages for broadcasting blocks and transactions
 type GossipWorkObject struct {
 	state         protoimpl.MessageState
@@ -130,6 +183,7 @@ type QuaiRequestMessage struct {
 	//
 	//	*QuaiRequestMessage_Hash
 	//	*QuaiRequestMessage_Number
+	//	*QuaiRequestMessage_HeaderRange
 	Data isQuaiRequestMessage_Data `protobuf_oneof:"data"`
 	// Types that are assignable to Request:
 	//
@@ -138,6 +192,7 @@ type QuaiRequestMessage struct {
 	//	*QuaiRequestMessage_Transaction
 	//	*QuaiRequestMessage_BlockHash
 	//	*QuaiRequestMessage_TrieNode
+	//	*QuaiRequestMessage_WorkObjectHeaderViews
 	Request isQuaiRequestMessage_Request `protobuf_oneof:"request"`
 }
 
@@ -208,6 +263,13 @@ func (x *QuaiRequestMessage) GetNumber() []byte {
 	return nil
 }
 
+func (x *QuaiRequestMessage) GetHeaderRange() *ProtoHeaderRange {
+	if x, ok := x.GetData().(*QuaiRequestMessage_HeaderRange); ok {
+		return x.HeaderRange
+	}
+	return nil
+}
+
 func (m *QuaiRequestMessage) GetRequest() isQuaiRequestMessage_Request {
 	if m != nil {
 		return m.Request
@@ -250,6 +312,13 @@ func (x *QuaiRequestMessage) GetTrieNode() *trie.ProtoTrieNode {
 	return nil
 }
 
+func (x *QuaiRequestMessage) GetWorkObjectHeaderViews() *ProtoWorkObjectHeaderViews {
+	if x, ok := x.GetRequest().(*QuaiRequestMessage_WorkObjectHeaderViews); ok {
+		return x.WorkObjectHeaderViews
+	}
+	return nil
+}
+
 type isQuaiRequestMessage_Data interface {
 	isQuaiRequestMessage_Data()
 }
@@ -262,10 +331,16 @@ type QuaiRequestMessage_Number struct {
 	Number []byte `protobuf:"bytes,4,opt,name=number,proto3,oneof"`
 }
 
+type QuaiRequestMessage_HeaderRange struct {
+	HeaderRange *ProtoHeaderRange `protobuf:"bytes,10,opt,name=header_range,json=headerRange,proto3,oneof"`
+}
+
 func (*QuaiRequestMessage_Hash) isQuaiRequestMessage_Data() {}
 
 func (*QuaiRequestMessage_Number) isQuaiRequestMessage_Data() {}
 
+func (*QuaiRequestMessage_HeaderRange) isQuaiRequestMessage_Data() {}
+
 type isQuaiRequestMessage_Request interface {
 	isQuaiRequestMessage_Request()
 }
@@ -290,6 +365,10 @@ type QuaiRequestMessage_TrieNode struct {
 	TrieNode *trie.ProtoTrieNode `protobuf:"bytes,9,opt,name=trie_node,json=trieNode,proto3,oneof"`
 }
 
+type QuaiRequestMessage_WorkObjectHeaderViews struct {
+	WorkObjectHeaderViews *ProtoWorkObjectHeaderViews `protobuf:"bytes,11,opt,name=work_object_header_views,json=workObjectHeaderViews,proto3,oneof"`
+}
+
 func (*QuaiRequestMessage_WorkObjectBlock) isQuaiRequestMessage_Request() {}
 
 func (*QuaiRequestMessage_WorkObjectHeader) isQuaiRequestMessage_Request() {}
@@ -300,6 +379,8 @@ func (*QuaiRequestMessage_BlockHash) isQuaiRequestMessage_Request() {}
 
 func (*QuaiRequestMessage_TrieNode) isQuaiRequestMessage_Request() {}
 
+func (*QuaiRequestMessage_WorkObjectHeaderViews) isQuaiRequestMessage_Request() {}
+
 // QuaiResponseMessage is the main 'envelope' for QuaiProtocol response messages
 type QuaiResponseMessage struct {
 	state         protoimpl.MessageState
@@ -315,6 +396,8 @@ type QuaiResponseMessage struct {
 	//	*QuaiResponseMessage_Transaction
 	//	*QuaiResponseMessage_BlockHash
 	//	*QuaiResponseMessage_TrieNode
+	//	*QuaiResponseMessage_Error
+	//	*QuaiResponseMessage_WorkObjectHeaderViews
 	Response isQuaiResponseMessage_Response `protobuf_oneof:"response"`
 }
 
@@ -406,6 +489,20 @@ func (x *QuaiResponseMessage) GetTrieNode() *trie.ProtoTrieNode {
 	return nil
 }
 
+func (x *QuaiResponseMessage) GetError() *QuaiResponseError {
+	if x, ok := x.GetResponse().(*QuaiResponseMessage_Error); ok {
+		return x.Error
+	}
+	return nil
+}
+
+func (x *QuaiResponseMessage) GetWorkObjectHeaderViews() *ProtoWorkObjectHeaderViews {
+	if x, ok := x.GetResponse().(*QuaiResponseMessage_WorkObjectHeaderViews); ok {
+		return x.WorkObjectHeaderViews
+	}
+	return nil
+}
+
 type isQuaiResponseMessage_Response interface {
 	isQuaiResponseMessage_Response()
 }
@@ -430,6 +527,14 @@ type QuaiResponseMessage_TrieNode struct {
 	TrieNode *trie.ProtoTrieNode `protobuf:"bytes,7,opt,name=trie_node,json=trieNode,proto3,oneof"`
 }
 
+type QuaiResponseMessage_Error struct {
+	Error *QuaiResponseError `protobuf:"bytes,8,opt,name=error,proto3,oneof"`
+}
+
+type QuaiResponseMessage_WorkObjectHeaderViews struct {
+	WorkObjectHeaderViews *ProtoWorkObjectHeaderViews `protobuf:"bytes,9,opt,name=work_object_header_views,json=workObjectHeaderViews,proto3,oneof"`
+}
+
 func (*QuaiResponseMessage_WorkObjectHeaderView) isQuaiResponseMessage_Response() {}
 
 func (*QuaiResponseMessage_WorkObjectBlockView) isQuaiResponseMessage_Response() {}
@@ -440,6 +545,180 @@ func (*QuaiResponseMessage_BlockHash) isQuaiResponseMessage_Response() {}
 
 func (*QuaiResponseMessage_TrieNode) isQuaiResponseMessage_Response() {}
 
+func (*QuaiResponseMessage_Error) isQuaiResponseMessage_Response() {}
+
+func (*QuaiResponseMessage_WorkObjectHeaderViews) isQuaiResponseMessage_Response() {}
+
+// ProtoHeaderRange requests count headers starting at the number start,
+// leaving out skip headers between two consecutive ones
+type ProtoHeaderRange struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Start []byte `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	Count uint64 `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Skip  uint64 `protobuf:"varint,3,opt,name=skip,proto3" json:"skip,omitempty"`
+}
+
+func (x *ProtoHeaderRange) Reset() {
+	*x = ProtoHeaderRange{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_p2p_pb_quai_messages_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProtoHeaderRange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProtoHeaderRange) ProtoMessage() {}
+
+func (x *ProtoHeaderRange) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_pb_quai_messages_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProtoHeaderRange.ProtoReflect.Descriptor instead.
+func (*ProtoHeaderRange) Descriptor() ([]byte, []int) {
+	return file_p2p_pb_quai_messages_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ProtoHeaderRange) GetStart() []byte {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *ProtoHeaderRange) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *ProtoHeaderRange) GetSkip() uint64 {
+	if x != nil {
+		return x.Skip
+	}
+	return 0
+}
+
+// ProtoWorkObjectHeaderViews holds the headers answering a header range request
+type ProtoWorkObjectHeaderViews struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	WorkObjectHeaderViews []*types.ProtoWorkObjectHeaderView `protobuf:"bytes,1,rep,name=work_object_header_views,json=workObjectHeaderViews,proto3" json:"work_object_header_views,omitempty"`
+}
+
+func (x *ProtoWorkObjectHeaderViews) Reset() {
+	*x = ProtoWorkObjectHeaderViews{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_p2p_pb_quai_messages_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProtoWorkObjectHeaderViews) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProtoWorkObjectHeaderViews) ProtoMessage() {}
+
+func (x *ProtoWorkObjectHeaderViews) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_pb_quai_messages_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProtoWorkObjectHeaderViews.ProtoReflect.Descriptor instead.
+func (*ProtoWorkObjectHeaderViews) Descriptor() ([]byte, []int) {
+	return file_p2p_pb_quai_messages_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ProtoWorkObjectHeaderViews) GetWorkObjectHeaderViews() []*types.ProtoWorkObjectHeaderView {
+	if x != nil {
+		return x.WorkObjectHeaderViews
+	}
+	return nil
+}
+
+// QuaiResponseError is sent in place of the requested data when the request
+// could not be served
+type QuaiResponseError struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Status  ResponseStatus `protobuf:"varint,1,opt,name=status,proto3,enum=quaiprotocol.ResponseStatus" json:"status,omitempty"`
+	Message string         `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *QuaiResponseError) Reset() {
+	*x = QuaiResponseError{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_p2p_pb_quai_messages_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *QuaiResponseError) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QuaiResponseError) ProtoMessage() {}
+
+func (x *QuaiResponseError) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_pb_quai_messages_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QuaiResponseError.ProtoReflect.Descriptor instead.
+func (*QuaiResponseError) Descriptor() ([]byte, []int) {
+	return file_p2p_pb_quai_messages_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *QuaiResponseError) GetStatus() ResponseStatus {
+	if x != nil {
+		return x.Status
+	}
+	return ResponseStatus_RESPONSE_STATUS_UNSPECIFIED
+}
+
+func (x *QuaiResponseError) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
 type QuaiMessage struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -455,7 +734,7 @@ type QuaiMessage struct {
 func (x *QuaiMessage) Reset() {
 	*x = QuaiMessage{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_p2p_pb_quai_messages_proto_msgTypes[4]
+		mi := &file_p2p_pb_quai_messages_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -468,7 +747,7 @@ func (x *QuaiMessage) String() string {
 func (*QuaiMessage) ProtoMessage() {}
 
 func (x *QuaiMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_p2p_pb_quai_messages_proto_msgTypes[4]
+	mi := &file_p2p_pb_quai_messages_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -481,7 +760,7 @@ func (x *QuaiMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use QuaiMessage.ProtoReflect.Descriptor instead.
 func (*QuaiMessage) Descriptor() ([]byte, []int) {
-	return file_p2p_pb_quai_messages_proto_rawDescGZIP(), []int{4}
+	return file_p2p_pb_quai_messages_proto_rawDescGZIP(), []int{7}
 }
 
 func (m *QuaiMessage) GetPayload() isQuaiMessage_Payload {
@@ -521,6 +800,247 @@ func (*QuaiMessage_Request) isQuaiMessage_Payload() {}
 
 func (*QuaiMessage_Response) isQuaiMessage_Payload() {}
 
+// ProtoForkID is the fork identifier of the chain running at a location
+type ProtoForkID struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Location *common.ProtoLocation `protobuf:"bytes,1,opt,name=location,proto3" json:"location,omitempty"`
+	Hash     []byte                `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	Next     uint64                `protobuf:"varint,3,opt,name=next,proto3" json:"next,omitempty"`
+}
+
+func (x *ProtoForkID) Reset() {
+	*x = ProtoForkID{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_p2p_pb_quai_messages_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProtoForkID) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProtoForkID) ProtoMessage() {}
+
+func (x *ProtoForkID) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_pb_quai_messages_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProtoForkID.ProtoReflect.Descriptor instead.
+func (*ProtoForkID) Descriptor() ([]byte, []int) {
+	return file_p2p_pb_quai_messages_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ProtoForkID) GetLocation() *common.ProtoLocation {
+	if x != nil {
+		return x.Location
+	}
+	return nil
+}
+
+func (x *ProtoForkID) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *ProtoForkID) GetNext() uint64 {
+	if x != nil {
+		return x.Next
+	}
+	return 0
+}
+
+// QuaiForkIDMessage announces the fork identifiers of all the chains a node runs
+type QuaiForkIDMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ForkIds []*ProtoForkID `protobuf:"bytes,1,rep,name=fork_ids,json=forkIds,proto3" json:"fork_ids,omitempty"`
+}
+
+func (x *QuaiForkIDMessage) Reset() {
+	*x = QuaiForkIDMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_p2p_pb_quai_messages_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *QuaiForkIDMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QuaiForkIDMessage) ProtoMessage() {}
+
+func (x *QuaiForkIDMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_pb_quai_messages_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QuaiForkIDMessage.ProtoReflect.Descriptor instead.
+func (*QuaiForkIDMessage) Descriptor() ([]byte, []int) {
+	return file_p2p_pb_quai_messages_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *QuaiForkIDMessage) GetForkIds() []*ProtoForkID {
+	if x != nil {
+		return x.ForkIds
+	}
+	return nil
+}
+
+// ProtoChainHead is the head of a chain run by a node
+type ProtoChainHead struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Location *common.ProtoLocation `protobuf:"bytes,1,opt,name=location,proto3" json:"location,omitempty"`
+	Hash     *common.ProtoHash     `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	Entropy  []byte                `protobuf:"bytes,3,opt,name=entropy,proto3" json:"entropy,omitempty"`
+}
+
+func (x *ProtoChainHead) Reset() {
+	*x = ProtoChainHead{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_p2p_pb_quai_messages_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProtoChainHead) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProtoChainHead) ProtoMessage() {}
+
+func (x *ProtoChainHead) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_pb_quai_messages_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProtoChainHead.ProtoReflect.Descriptor instead.
+func (*ProtoChainHead) Descriptor() ([]byte, []int) {
+	return file_p2p_pb_quai_messages_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ProtoChainHead) GetLocation() *common.ProtoLocation {
+	if x != nil {
+		return x.Location
+	}
+	return nil
+}
+
+func (x *ProtoChainHead) GetHash() *common.ProtoHash {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *ProtoChainHead) GetEntropy() []byte {
+	if x != nil {
+		return x.Entropy
+	}
+	return nil
+}
+
+// QuaiStatusMessage is exchanged by two peers when a QuaiProtocol stream
+// opens, before any request is sent on it
+type QuaiStatusMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Version uint32            `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Genesis *common.ProtoHash `protobuf:"bytes,2,opt,name=genesis,proto3" json:"genesis,omitempty"`
+	Heads   []*ProtoChainHead `protobuf:"bytes,3,rep,name=heads,proto3" json:"heads,omitempty"`
+}
+
+func (x *QuaiStatusMessage) Reset() {
+	*x = QuaiStatusMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_p2p_pb_quai_messages_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *QuaiStatusMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QuaiStatusMessage) ProtoMessage() {}
+
+func (x *QuaiStatusMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_pb_quai_messages_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QuaiStatusMessage.ProtoReflect.Descriptor instead.
+func (*QuaiStatusMessage) Descriptor() ([]byte, []int) {
+	return file_p2p_pb_quai_messages_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *QuaiStatusMessage) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *QuaiStatusMessage) GetGenesis() *common.ProtoHash {
+	if x != nil {
+		return x.Genesis
+	}
+	return nil
+}
+
+func (x *QuaiStatusMessage) GetHeads() []*ProtoChainHead {
+	if x != nil {
+		return x.Heads
+	}
+	return nil
+}
+
 var File_p2p_pb_quai_messages_proto protoreflect.FileDescriptor
 
 var file_p2p_pb_quai_messages_proto_rawDesc = []byte{
@@ -541,7 +1061,7 @@ var file_p2p_pb_quai_messages_proto_rawDesc = []byte{
 	0x12, 0x39, 0x0a, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18,
 	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x50, 0x72,
 	0x6f, 0x74, 0x6f, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b,
-	0x74, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0xf3, 0x03, 0x0a, 0x12,
+	0x74, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x9d, 0x05, 0x0a, 0x12,
 	0x51, 0x75, 0x61, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61,
 	0x67, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x02,
 	0x69, 0x64, 0x12, 0x31, 0x0a, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02,
@@ -551,70 +1071,148 @@ var file_p2p_pb_quai_messages_proto_rawDesc = []byte{
 	0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x50, 0x72, 0x6f,
 	0x74, 0x6f, 0x48, 0x61, 0x73, 0x68, 0x48, 0x00, 0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12, 0x18,
 	0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x00,
-	0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x4d, 0x0a, 0x11, 0x77, 0x6f, 0x72, 0x6b,
-	0x5f, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x05, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x50, 0x72, 0x6f, 0x74,
-	0x6f, 0x57, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x42, 0x6c, 0x6f, 0x63, 0x6b,
-	0x56, 0x69, 0x65, 0x77, 0x48, 0x01, 0x52, 0x0f, 0x77, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65,
-	0x63, 0x74, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x50, 0x0a, 0x12, 0x77, 0x6f, 0x72, 0x6b, 0x5f,
-	0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x5f, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18, 0x06, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x50, 0x72, 0x6f, 0x74,
-	0x6f, 0x57, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65,
-	0x72, 0x56, 0x69, 0x65, 0x77, 0x48, 0x01, 0x52, 0x10, 0x77, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a,
-	0x65, 0x63, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x3b, 0x0a, 0x0b, 0x74, 0x72, 0x61,
-	0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17,
+	0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x43, 0x0a, 0x0c, 0x68, 0x65, 0x61, 0x64,
+	0x65, 0x72, 0x5f, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e,
+	0x2e, 0x71, 0x75, 0x61, 0x69, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x50, 0x72,
+	0x6f, 0x74, 0x6f, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x48, 0x00,
+	0x52, 0x0b, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x4d, 0x0a,
+	0x11, 0x77, 0x6f, 0x72, 0x6b, 0x5f, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x5f, 0x62, 0x6c, 0x6f,
+	0x63, 0x6b, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x62, 0x6c, 0x6f, 0x63, 0x6b,
+	0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x57, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74,
+	0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x56, 0x69, 0x65, 0x77, 0x48, 0x01, 0x52, 0x0f, 0x77, 0x6f, 0x72,
+	0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x50, 0x0a, 0x12,
+	0x77, 0x6f, 0x72, 0x6b, 0x5f, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x5f, 0x68, 0x65, 0x61, 0x64,
+	0x65, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x62, 0x6c, 0x6f, 0x63, 0x6b,
+	0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x57, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74,
+	0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x56, 0x69, 0x65, 0x77, 0x48, 0x01, 0x52, 0x10, 0x77, 0x6f,
+	0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x3b,
+	0x0a, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x50, 0x72, 0x6f, 0x74,
+	0x6f, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x01, 0x52, 0x0b,
+	0x74, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x32, 0x0a, 0x0a, 0x62,
+	0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x11, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x48, 0x61,
+	0x73, 0x68, 0x48, 0x01, 0x52, 0x09, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x48, 0x61, 0x73, 0x68, 0x12,
+	0x32, 0x0a, 0x09, 0x74, 0x72, 0x69, 0x65, 0x5f, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x09, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x72, 0x69, 0x65, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x54,
+	0x72, 0x69, 0x65, 0x4e, 0x6f, 0x64, 0x65, 0x48, 0x01, 0x52, 0x08, 0x74, 0x72, 0x69, 0x65, 0x4e,
+	0x6f, 0x64, 0x65, 0x12, 0x63, 0x0a, 0x18, 0x77, 0x6f, 0x72, 0x6b, 0x5f, 0x6f, 0x62, 0x6a, 0x65,
+	0x63, 0x74, 0x5f, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x5f, 0x76, 0x69, 0x65, 0x77, 0x73, 0x18,
+	0x0b, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x71, 0x75, 0x61, 0x69, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x57, 0x6f, 0x72, 0x6b, 0x4f, 0x62,
+	0x6a, 0x65, 0x63, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x56, 0x69, 0x65, 0x77, 0x73, 0x48,
+	0x01, 0x52, 0x15, 0x77, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x48, 0x65, 0x61,
+	0x64, 0x65, 0x72, 0x56, 0x69, 0x65, 0x77, 0x73, 0x42, 0x06, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61,
+	0x42, 0x09, 0x0a, 0x07, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0xda, 0x04, 0x0a, 0x13,
+	0x51, 0x75, 0x61, 0x69, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x02, 0x69, 0x64, 0x12, 0x31, 0x0a, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x50,
+	0x72, 0x6f, 0x74, 0x6f, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x08, 0x6c, 0x6f,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x59, 0x0a, 0x17, 0x77, 0x6f, 0x72, 0x6b, 0x5f, 0x6f,
+	0x62, 0x6a, 0x65, 0x63, 0x74, 0x5f, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x5f, 0x76, 0x69, 0x65,
+	0x77, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x2e,
+	0x50, 0x72, 0x6f, 0x74, 0x6f, 0x57, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x48,
+	0x65, 0x61, 0x64, 0x65, 0x72, 0x56, 0x69, 0x65, 0x77, 0x48, 0x00, 0x52, 0x14, 0x77, 0x6f, 0x72,
+	0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x56, 0x69, 0x65,
+	0x77, 0x12, 0x56, 0x0a, 0x16, 0x77, 0x6f, 0x72, 0x6b, 0x5f, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74,
+	0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x76, 0x69, 0x65, 0x77, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1f, 0x2e, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x57,
+	0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x56, 0x69,
+	0x65, 0x77, 0x48, 0x00, 0x52, 0x13, 0x77, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74,
+	0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x56, 0x69, 0x65, 0x77, 0x12, 0x3b, 0x0a, 0x0b, 0x74, 0x72, 0x61,
+	0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17,
 	0x2e, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x54, 0x72, 0x61, 0x6e,
-	0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x01, 0x52, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73,
+	0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73,
 	0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x32, 0x0a, 0x0a, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f,
-	0x68, 0x61, 0x73, 0x68, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x63, 0x6f, 0x6d,
-	0x6d, 0x6f, 0x6e, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x48, 0x61, 0x73, 0x68, 0x48, 0x01, 0x52,
+	0x68, 0x61, 0x73, 0x68, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x63, 0x6f, 0x6d,
+	0x6d, 0x6f, 0x6e, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x48, 0x61, 0x73, 0x68, 0x48, 0x00, 0x52,
 	0x09, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x48, 0x61, 0x73, 0x68, 0x12, 0x32, 0x0a, 0x09, 0x74, 0x72,
-	0x69, 0x65, 0x5f, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e,
+	0x69, 0x65, 0x5f, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e,
 	0x74, 0x72, 0x69, 0x65, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x54, 0x72, 0x69, 0x65, 0x4e, 0x6f,
-	0x64, 0x65, 0x48, 0x01, 0x52, 0x08, 0x74, 0x72, 0x69, 0x65, 0x4e, 0x6f, 0x64, 0x65, 0x42, 0x06,
-	0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x42, 0x09, 0x0a, 0x07, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x22, 0xbc, 0x03, 0x0a, 0x13, 0x51, 0x75, 0x61, 0x69, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x02, 0x69, 0x64, 0x12, 0x31, 0x0a, 0x08, 0x6c, 0x6f, 0x63,
-	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x63, 0x6f,
-	0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x52, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x59, 0x0a, 0x17,
-	0x77, 0x6f, 0x72, 0x6b, 0x5f, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x5f, 0x68, 0x65, 0x61, 0x64,
-	0x65, 0x72, 0x5f, 0x76, 0x69, 0x65, 0x77, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e,
-	0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x57, 0x6f, 0x72, 0x6b, 0x4f,
-	0x62, 0x6a, 0x65, 0x63, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x56, 0x69, 0x65, 0x77, 0x48,
-	0x00, 0x52, 0x14, 0x77, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x48, 0x65, 0x61,
-	0x64, 0x65, 0x72, 0x56, 0x69, 0x65, 0x77, 0x12, 0x56, 0x0a, 0x16, 0x77, 0x6f, 0x72, 0x6b, 0x5f,
-	0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x5f, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x76, 0x69, 0x65,
-	0x77, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x2e,
-	0x50, 0x72, 0x6f, 0x74, 0x6f, 0x57, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x42,
-	0x6c, 0x6f, 0x63, 0x6b, 0x56, 0x69, 0x65, 0x77, 0x48, 0x00, 0x52, 0x13, 0x77, 0x6f, 0x72, 0x6b,
-	0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x56, 0x69, 0x65, 0x77, 0x12,
-	0x3b, 0x0a, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x50, 0x72, 0x6f,
-	0x74, 0x6f, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52,
-	0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x32, 0x0a, 0x0a,
-	0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x11, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x48,
-	0x61, 0x73, 0x68, 0x48, 0x00, 0x52, 0x09, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x48, 0x61, 0x73, 0x68,
-	0x12, 0x32, 0x0a, 0x09, 0x74, 0x72, 0x69, 0x65, 0x5f, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x07, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x74, 0x72, 0x69, 0x65, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f,
-	0x54, 0x72, 0x69, 0x65, 0x4e, 0x6f, 0x64, 0x65, 0x48, 0x00, 0x52, 0x08, 0x74, 0x72, 0x69, 0x65,
-	0x4e, 0x6f, 0x64, 0x65, 0x42, 0x0a, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
-	0x22, 0x97, 0x01, 0x0a, 0x0b, 0x51, 0x75, 0x61, 0x69, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65,
-	0x12, 0x3c, 0x0a, 0x07, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x20, 0x2e, 0x71, 0x75, 0x61, 0x69, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c,
-	0x2e, 0x51, 0x75, 0x61, 0x69, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x4d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x48, 0x00, 0x52, 0x07, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3f,
-	0x0a, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b,
-	0x32, 0x21, 0x2e, 0x71, 0x75, 0x61, 0x69, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e,
-	0x51, 0x75, 0x61, 0x69, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x4d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x48, 0x00, 0x52, 0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42,
-	0x09, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x42, 0x2f, 0x5a, 0x2d, 0x67, 0x69,
-	0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x6f, 0x6d, 0x69, 0x6e, 0x61, 0x6e,
-	0x74, 0x2d, 0x73, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x69, 0x65, 0x73, 0x2f, 0x67, 0x6f, 0x2d,
-	0x71, 0x75, 0x61, 0x69, 0x2f, 0x70, 0x32, 0x70, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x33,
+	0x64, 0x65, 0x48, 0x00, 0x52, 0x08, 0x74, 0x72, 0x69, 0x65, 0x4e, 0x6f, 0x64, 0x65, 0x12, 0x37,
+	0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1f, 0x2e,
+	0x71, 0x75, 0x61, 0x69, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x51, 0x75, 0x61,
+	0x69, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x48, 0x00,
+	0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x63, 0x0a, 0x18, 0x77, 0x6f, 0x72, 0x6b, 0x5f,
+	0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x5f, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x5f, 0x76, 0x69,
+	0x65, 0x77, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x28, 0x2e, 0x71, 0x75, 0x61, 0x69,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x57, 0x6f,
+	0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x56, 0x69,
+	0x65, 0x77, 0x73, 0x48, 0x00, 0x52, 0x15, 0x77, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63,
+	0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x56, 0x69, 0x65, 0x77, 0x73, 0x42, 0x0a, 0x0a, 0x08,
+	0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x52, 0x0a, 0x10, 0x50, 0x72, 0x6f, 0x74,
+	0x6f, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x52, 0x61, 0x6e, 0x67, 0x65, 0x12, 0x14, 0x0a, 0x05,
+	0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x73, 0x74, 0x61,
+	0x72, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x6b, 0x69, 0x70,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x73, 0x6b, 0x69, 0x70, 0x22, 0x77, 0x0a, 0x1a,
+	0x50, 0x72, 0x6f, 0x74, 0x6f, 0x57, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x48,
+	0x65, 0x61, 0x64, 0x65, 0x72, 0x56, 0x69, 0x65, 0x77, 0x73, 0x12, 0x59, 0x0a, 0x18, 0x77, 0x6f,
+	0x72, 0x6b, 0x5f, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x5f, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72,
+	0x5f, 0x76, 0x69, 0x65, 0x77, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x62,
+	0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x57, 0x6f, 0x72, 0x6b, 0x4f, 0x62,
+	0x6a, 0x65, 0x63, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x56, 0x69, 0x65, 0x77, 0x52, 0x15,
+	0x77, 0x6f, 0x72, 0x6b, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72,
+	0x56, 0x69, 0x65, 0x77, 0x73, 0x22, 0x63, 0x0a, 0x11, 0x51, 0x75, 0x61, 0x69, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x34, 0x0a, 0x06, 0x73, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1c, 0x2e, 0x71, 0x75, 0x61,
+	0x69, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0x97, 0x01, 0x0a, 0x0b, 0x51,
+	0x75, 0x61, 0x69, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x3c, 0x0a, 0x07, 0x72, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x71, 0x75,
+	0x61, 0x69, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x51, 0x75, 0x61, 0x69, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x48, 0x00, 0x52,
+	0x07, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x3f, 0x0a, 0x08, 0x72, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x21, 0x2e, 0x71, 0x75, 0x61,
+	0x69, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x51, 0x75, 0x61, 0x69, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x48, 0x00, 0x52,
+	0x08, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x09, 0x0a, 0x07, 0x70, 0x61, 0x79,
+	0x6c, 0x6f, 0x61, 0x64, 0x22, 0x68, 0x0a, 0x0b, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x46, 0x6f, 0x72,
+	0x6b, 0x49, 0x44, 0x12, 0x31, 0x0a, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x50,
+	0x72, 0x6f, 0x74, 0x6f, 0x4c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x08, 0x6c, 0x6f,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x65,
+	0x78, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x04, 0x6e, 0x65, 0x78, 0x74, 0x22, 0x49,
+	0x0a, 0x11, 0x51, 0x75, 0x61, 0x69, 0x46, 0x6f, 0x72, 0x6b, 0x49, 0x44, 0x4d, 0x65, 0x73, 0x73,
+	0x61, 0x67, 0x65, 0x12, 0x34, 0x0a, 0x08, 0x66, 0x6f, 0x72, 0x6b, 0x5f, 0x69, 0x64, 0x73, 0x18,
+	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x71, 0x75, 0x61, 0x69, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x46, 0x6f, 0x72, 0x6b, 0x49, 0x44,
+	0x52, 0x07, 0x66, 0x6f, 0x72, 0x6b, 0x49, 0x64, 0x73, 0x22, 0x84, 0x01, 0x0a, 0x0e, 0x50, 0x72,
+	0x6f, 0x74, 0x6f, 0x43, 0x68, 0x61, 0x69, 0x6e, 0x48, 0x65, 0x61, 0x64, 0x12, 0x31, 0x0a, 0x08,
+	0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15,
+	0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x4c, 0x6f, 0x63,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x08, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x25, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e,
+	0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x48, 0x61, 0x73, 0x68,
+	0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12, 0x18, 0x0a, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x6f, 0x70,
+	0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x6f, 0x70, 0x79,
+	0x22, 0x8e, 0x01, 0x0a, 0x11, 0x51, 0x75, 0x61, 0x69, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x4d,
+	0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x12, 0x2b, 0x0a, 0x07, 0x67, 0x65, 0x6e, 0x65, 0x73, 0x69, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x11, 0x2e, 0x63, 0x6f, 0x6d, 0x6d, 0x6f, 0x6e, 0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f,
+	0x48, 0x61, 0x73, 0x68, 0x52, 0x07, 0x67, 0x65, 0x6e, 0x65, 0x73, 0x69, 0x73, 0x12, 0x32, 0x0a,
+	0x05, 0x68, 0x65, 0x61, 0x64, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x71,
+	0x75, 0x61, 0x69, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2e, 0x50, 0x72, 0x6f, 0x74,
+	0x6f, 0x43, 0x68, 0x61, 0x69, 0x6e, 0x48, 0x65, 0x61, 0x64, 0x52, 0x05, 0x68, 0x65, 0x61, 0x64,
+	0x73, 0x2a, 0x99, 0x01, 0x0a, 0x0e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x53, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x12, 0x1f, 0x0a, 0x1b, 0x52, 0x45, 0x53, 0x50, 0x4f, 0x4e, 0x53, 0x45,
+	0x5f, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46,
+	0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x1d, 0x0a, 0x19, 0x52, 0x45, 0x53, 0x50, 0x4f, 0x4e, 0x53,
+	0x45, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x5f, 0x4e, 0x4f, 0x54, 0x5f, 0x46, 0x4f, 0x55,
+	0x4e, 0x44, 0x10, 0x01, 0x12, 0x23, 0x0a, 0x1f, 0x52, 0x45, 0x53, 0x50, 0x4f, 0x4e, 0x53, 0x45,
+	0x5f, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x5f, 0x49, 0x4e, 0x56, 0x41, 0x4c, 0x49, 0x44, 0x5f,
+	0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x10, 0x02, 0x12, 0x22, 0x0a, 0x1e, 0x52, 0x45, 0x53,
+	0x50, 0x4f, 0x4e, 0x53, 0x45, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x55, 0x53, 0x5f, 0x49, 0x4e, 0x54,
+	0x45, 0x52, 0x4e, 0x41, 0x4c, 0x5f, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x03, 0x42, 0x2f, 0x5a,
+	0x2d, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x6f, 0x6d, 0x69,
+	0x6e, 0x61, 0x6e, 0x74, 0x2d, 0x73, 0x74, 0x72, 0x61, 0x74, 0x65, 0x67, 0x69, 0x65, 0x73, 0x2f,
+	0x67, 0x6f, 0x2d, 0x71, 0x75, 0x61, 0x69, 0x2f, 0x70, 0x32, 0x70, 0x2f, 0x70, 0x62, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -629,44 +1227,65 @@ func file_p2p_pb_quai_messages_proto_rawDescGZIP() []byte {
 	return file_p2p_pb_quai_messages_proto_rawDescData
 }
 
-var file_p2p_pb_quai_messages_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_p2p_pb_quai_messages_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_p2p_pb_quai_messages_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_p2p_pb_quai_messages_proto_goTypes = []interface{}{
-	(*GossipWorkObject)(nil),                // 0: quaiprotocol.GossipWorkObject
-	(*GossipTransaction)(nil),               // 1: quaiprotocol.GossipTransaction
-	(*QuaiRequestMessage)(nil),              // 2: quaiprotocol.QuaiRequestMessage
-	(*QuaiResponseMessage)(nil),             // 3: quaiprotocol.QuaiResponseMessage
-	(*QuaiMessage)(nil),                     // 4: quaiprotocol.QuaiMessage
-	(*types.ProtoWorkObject)(nil),           // 5: block.ProtoWorkObject
-	(*types.ProtoTransaction)(nil),          // 6: block.ProtoTransaction
-	(*common.ProtoLocation)(nil),            // 7: common.ProtoLocation
-	(*common.ProtoHash)(nil),                // 8: common.ProtoHash
-	(*types.ProtoWorkObjectBlockView)(nil),  // 9: block.ProtoWorkObjectBlockView
-	(*types.ProtoWorkObjectHeaderView)(nil), // 10: block.ProtoWorkObjectHeaderView
-	(*trie.ProtoTrieNode)(nil),              // 11: trie.ProtoTrieNode
+	(ResponseStatus)(0),                     // 0: quaiprotocol.ResponseStatus
+	(*GossipWorkObject)(nil),                // 1: quaiprotocol.GossipWorkObject
+	(*GossipTransaction)(nil),               // 2: quaiprotocol.GossipTransaction
+	(*QuaiRequestMessage)(nil),              // 3: quaiprotocol.QuaiRequestMessage
+	(*QuaiResponseMessage)(nil),             // 4: quaiprotocol.QuaiResponseMessage
+	(*ProtoHeaderRange)(nil),                // 5: quaiprotocol.ProtoHeaderRange
+	(*ProtoWorkObjectHeaderViews)(nil),      // 6: quaiprotocol.ProtoWorkObjectHeaderViews
+	(*QuaiResponseError)(nil),               // 7: quaiprotocol.QuaiResponseError
+	(*QuaiMessage)(nil),                     // 8: quaiprotocol.QuaiMessage
+	(*ProtoForkID)(nil),                     // 9: quaiprotocol.ProtoForkID
+	(*QuaiForkIDMessage)(nil),               // 10: quaiprotocol.QuaiForkIDMessage
+	(*ProtoChainHead)(nil),                  // 11: quaiprotocol.ProtoChainHead
+	(*QuaiStatusMessage)(nil),               // 12: quaiprotocol.QuaiStatusMessage
+	(*types.ProtoWorkObject)(nil),           // 13: block.ProtoWorkObject
+	(*types.ProtoTransaction)(nil),          // 14: block.ProtoTransaction
+	(*common.ProtoLocation)(nil),            // 15: common.ProtoLocation
+	(*common.ProtoHash)(nil),                // 16: common.ProtoHash
+	(*types.ProtoWorkObjectBlockView)(nil),  // 17: block.ProtoWorkObjectBlockView
+	(*types.ProtoWorkObjectHeaderView)(nil), // 18: block.ProtoWorkObjectHeaderView
+	(*trie.ProtoTrieNode)(nil),              // 19: trie.ProtoTrieNode
 }
 var file_p2p_pb_quai_messages_proto_depIdxs = []int32{
-	5,  // 0: quaiprotocol.GossipWorkObject.work_object:type_name -> block.ProtoWorkObject
-	6,  // 1: quaiprotocol.GossipTransaction.transaction:type_name -> block.ProtoTransaction
-	7,  // 2: quaiprotocol.QuaiRequestMessage.location:type_name -> common.ProtoLocation
-	8,  // 3: quaiprotocol.QuaiRequestMessage.hash:type_name -> common.ProtoHash
-	9,  // 4: quaiprotocol.QuaiRequestMessage.work_object_block:type_name -> block.ProtoWorkObjectBlockView
-	10, // 5: quaiprotocol.QuaiRequestMessage.work_object_header:type_name -> block.ProtoWorkObjectHeaderView
-	6,  // 6: quaiprotocol.QuaiRequestMessage.transaction:type_name -> block.ProtoTransaction
-	8,  // 7: quaiprotocol.QuaiRequestMessage.block_hash:type_name -> common.ProtoHash
-	11, // 8: quaiprotocol.QuaiRequestMessage.trie_node:type_name -> trie.ProtoTrieNode
-	7,  // 9: quaiprotocol.QuaiResponseMessage.location:type_name -> common.ProtoLocation
-	10, // 10: quaiprotocol.QuaiResponseMessage.work_object_header_view:type_name -> block.ProtoWorkObjectHeaderView
-	9,  // 11: quaiprotocol.QuaiResponseMessage.work_object_block_view:type_name -> block.ProtoWorkObjectBlockView
-	6,  // 12: quaiprotocol.QuaiResponseMessage.transaction:type_name -> block.ProtoTransaction
-	8,  // 13: quaiprotocol.QuaiResponseMessage.block_hash:type_name -> common.ProtoHash
-	11, // 14: quaiprotocol.QuaiResponseMessage.trie_node:type_name -> trie.ProtoTrieNode
-	2,  // 15: quaiprotocol.QuaiMessage.request:type_name -> quaiprotocol.QuaiRequestMessage
-	3,  // 16: quaiprotocol.QuaiMessage.response:type_name -> quaiprotocol.QuaiResponseMessage
-	17, // [17:17] is the sub-list for method output_type
-	17, // [17:17] is the sub-list for method input_type
-	17, // [17:17] is the sub-list for extension type_name
-	17, // [17:17] is the sub-list for extension extendee
-	0,  // [0:17] is the sub-list for field type_name
+	13, // 0: quaiprotocol.GossipWorkObject.work_object:type_name -> block.ProtoWorkObject
+	14, // 1: quaiprotocol.GossipTransaction.transaction:type_name -> block.ProtoTransaction
+	15, // 2: quaiprotocol.QuaiRequestMessage.location:type_name -> common.ProtoLocation
+	16, // 3: quaiprotocol.QuaiRequestMessage.hash:type_name -> common.ProtoHash
+	5,  // 4: quaiprotocol.QuaiRequestMessage.header_range:type_name -> quaiprotocol.ProtoHeaderRange
+	17, // 5: quaiprotocol.QuaiRequestMessage.work_object_block:type_name -> block.ProtoWorkObjectBlockView
+	18, // 6: quaiprotocol.QuaiRequestMessage.work_object_header:type_name -> block.ProtoWorkObjectHeaderView
+	14, // 7: quaiprotocol.QuaiRequestMessage.transaction:type_name -> block.ProtoTransaction
+	16, // 8: quaiprotocol.QuaiRequestMessage.block_hash:type_name -> common.ProtoHash
+	19, // 9: quaiprotocol.QuaiRequestMessage.trie_node:type_name -> trie.ProtoTrieNode
+	6,  // 10: quaiprotocol.QuaiRequestMessage.work_object_header_views:type_name -> quaiprotocol.ProtoWorkObjectHeaderViews
+	15, // 11: quaiprotocol.QuaiResponseMessage.location:type_name -> common.ProtoLocation
+	18, // 12: quaiprotocol.QuaiResponseMessage.work_object_header_view:type_name -> block.ProtoWorkObjectHeaderView
+	17, // 13: quaiprotocol.QuaiResponseMessage.work_object_block_view:type_name -> block.ProtoWorkObjectBlockView
+	14, // 14: quaiprotocol.QuaiResponseMessage.transaction:type_name -> block.ProtoTransaction
+	16, // 15: quaiprotocol.QuaiResponseMessage.block_hash:type_name -> common.ProtoHash
+	19, // 16: quaiprotocol.QuaiResponseMessage.trie_node:type_name -> trie.ProtoTrieNode
+	7,  // 17: quaiprotocol.QuaiResponseMessage.error:type_name -> quaiprotocol.QuaiResponseError
+	6,  // 18: quaiprotocol.QuaiResponseMessage.work_object_header_views:type_name -> quaiprotocol.ProtoWorkObjectHeaderViews
+	18, // 19: quaiprotocol.ProtoWorkObjectHeaderViews.work_object_header_views:type_name -> block.ProtoWorkObjectHeaderView
+	0,  // 20: quaiprotocol.QuaiResponseError.status:type_name -> quaiprotocol.ResponseStatus
+	3,  // 21: quaiprotocol.QuaiMessage.request:type_name -> quaiprotocol.QuaiRequestMessage
+	4,  // 22: quaiprotocol.QuaiMessage.response:type_name -> quaiprotocol.QuaiResponseMessage
+	15, // 23: quaiprotocol.ProtoForkID.location:type_name -> common.ProtoLocation
+	9,  // 24: quaiprotocol.QuaiForkIDMessage.fork_ids:type_name -> quaiprotocol.ProtoForkID
+	15, // 25: quaiprotocol.ProtoChainHead.location:type_name -> common.ProtoLocation
+	16, // 26: quaiprotocol.ProtoChainHead.hash:type_name -> common.ProtoHash
+	16, // 27: quaiprotocol.QuaiStatusMessage.genesis:type_name -> common.ProtoHash
+	11, // 28: quaiprotocol.QuaiStatusMessage.heads:type_name -> quaiprotocol.ProtoChainHead
+	29, // [29:29] is the sub-list for method output_type
+	29, // [29:29] is the sub-list for method input_type
+	29, // [29:29] is the sub-list for extension type_name
+	29, // [29:29] is the sub-list for extension extendee
+	0,  // [0:29] is the sub-list for field type_name
 }
 
 func init() { file_p2p_pb_quai_messages_proto_init() }
@@ -724,6 +1343,42 @@ func file_p2p_pb_quai_messages_proto_init() {
 			}
 		}
 		file_p2p_pb_quai_messages_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ProtoHeaderRange); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_p2p_pb_quai_messages_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ProtoWorkObjectHeaderViews); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_p2p_pb_quai_messages_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*QuaiResponseError); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_p2p_pb_quai_messages_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*QuaiMessage); i {
 			case 0:
 				return &v.state
@@ -735,15 +1390,65 @@ func file_p2p_pb_quai_messages_proto_init() {
 				return nil
 			}
 		}
+		file_p2p_pb_quai_messages_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ProtoForkID); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_p2p_pb_quai_messages_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*QuaiForkIDMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_p2p_pb_quai_messages_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ProtoChainHead); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_p2p_pb_quai_messages_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*QuaiStatusMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	file_p2p_pb_quai_messages_proto_msgTypes[2].OneofWrappers = []interface{}{
 		(*QuaiRequestMessage_Hash)(nil),
 		(*QuaiRequestMessage_Number)(nil),
+		(*QuaiRequestMessage_HeaderRange)(nil),
 		(*QuaiRequestMessage_WorkObjectBlock)(nil),
 		(*QuaiRequestMessage_WorkObjectHeader)(nil),
 		(*QuaiRequestMessage_Transaction)(nil),
 		(*QuaiRequestMessage_BlockHash)(nil),
 		(*QuaiRequestMessage_TrieNode)(nil),
+		(*QuaiRequestMessage_WorkObjectHeaderViews)(nil),
 	}
 	file_p2p_pb_quai_messages_proto_msgTypes[3].OneofWrappers = []interface{}{
 		(*QuaiResponseMessage_WorkObjectHeaderView)(nil),
@@ -751,8 +1456,10 @@ func file_p2p_pb_quai_messages_proto_init() {
 		(*QuaiResponseMessage_Transaction)(nil),
 		(*QuaiResponseMessage_BlockHash)(nil),
 		(*QuaiResponseMessage_TrieNode)(nil),
+		(*QuaiResponseMessage_Error)(nil),
+		(*QuaiResponseMessage_WorkObjectHeaderViews)(nil),
 	}
-	file_p2p_pb_quai_messages_proto_msgTypes[4].OneofWrappers = []interface{}{
+	file_p2p_pb_quai_messages_proto_msgTypes[7].OneofWrappers = []interface{}{
 		(*QuaiMessage_Request)(nil),
 		(*QuaiMessage_Response)(nil),
 	}
@@ -761,13 +1468,14 @@ func file_p2p_pb_quai_messages_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_p2p_pb_quai_messages_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   5,
+			NumEnums:      1,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_p2p_pb_quai_messages_proto_goTypes,
 		DependencyIndexes: file_p2p_pb_quai_messages_proto_depIdxs,
+		EnumInfos:         file_p2p_pb_quai_messages_proto_enumTypes,
 		MessageInfos:      file_p2p_pb_quai_messages_proto_msgTypes,
 	}.Build()
 	File_p2p_pb_quai_messages_proto = out.File
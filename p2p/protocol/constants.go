@@ -6,5 +6,23 @@ import (
 
 const (
 	// ProtocolVersion is the current version of the Quai protocol
-	ProtocolVersion protocol.ID = "/quai/1.0.0"
+	ProtocolVersion protocol.ID = "/quai/2.0.0"
+
+	// ForkIDProtocolVersion is the protocol used to exchange the fork
+	// identifiers of the chains run by two peers once they connect
+	ForkIDProtocolVersion protocol.ID = "/quai/forkid/1.0.0"
+)
+
+const (
+	// StatusVersion is the version of the status handshake run when a
+	// QuaiProtocol stream opens
+	StatusVersion uint32 = 1
+
+	// MinStatusVersion is the oldest version of the status handshake still
+	// accepted from peers
+	MinStatusVersion uint32 = 1
 )
+
+// MaxHeaderRangeCount is the largest number of headers served in answer to a
+// single header range request
+const MaxHeaderRangeCount = 192
@@ -3,6 +3,7 @@ package protocol
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"math/big"
 	"runtime/debug"
@@ -44,6 +45,17 @@ func QuaiProtocolHandler(stream network.Stream, node QuaiP2PNode) {
 		// TODO: add logic to drop the peer
 		return
 	}
+	// The peer opening the stream has to join the network before sending
+	// requests. Outbound streams complete the handshake when they are opened.
+	if stream.Stat().Direction == network.DirInbound {
+		if err := processJoinRequest(stream, node); err != nil {
+			log.Global.WithFields(log.Fields{
+				"peer": stream.Conn().RemotePeer(),
+				"err":  err,
+			}).Debug("Rejected peer join request")
+			return
+		}
+	}
 	// Create a channel for messages
 	msgChan := make(chan []byte, msgChanSize)
 	full := 0
@@ -133,7 +145,7 @@ func handleRequest(quaiMsg *pb.QuaiRequestMessage, stream network.Stream, node Q
 	id, decodedType, loc, query, err := pb.DecodeQuaiRequest(quaiMsg)
 	if err != nil {
 		log.Global.WithField("err", err).Errorf("error decoding quai request")
-		// TODO: handle error
+		sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INVALID_REQUEST, err.Error(), stream)
 		return
 	}
 	switch query.(type) {
@@ -153,6 +165,14 @@ func handleRequest(quaiMsg *pb.QuaiRequestMessage, stream network.Stream, node Q
 			"number":      query,
 			"peer":        stream.Conn().RemotePeer(),
 		}).Debug("Received request by number to handle")
+	case *types.HeaderRangeRequest:
+		log.Global.WithFields(log.Fields{
+			"requestID":   id,
+			"decodedType": decodedType,
+			"location":    loc,
+			"range":       query,
+			"peer":        stream.Conn().RemotePeer(),
+		}).Debug("Received request by header range to handle")
 	default:
 		log.Global.Errorf("unsupported request input data field type: %T", query)
 	}
@@ -177,13 +197,13 @@ func handleRequest(quaiMsg *pb.QuaiRequestMessage, stream network.Stream, node Q
 			requestedHash = node.GetBlockHashByNumber(number, loc)
 			if requestedHash == nil {
 				log.Global.Debugf("block hash not found for block %s and location %s", number.String(), loc.Name())
-				// TODO: handle error
+				sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_NOT_FOUND, "block not found", stream)
 				return
 			}
-			log.Global.Tracef("Found hash for block %s and location: %s", number.String(), loc.Name(), requestedHash)
+			log.Global.Tracef("Found hash %s for block %s and location: %s", requestedHash, number.String(), loc.Name())
 		default:
 			log.Global.Errorf("unsupported query type %v", query)
-			// TODO: handle error
+			sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INVALID_REQUEST, "unsupported query type", stream)
 			return
 		}
 		err = handleBlockRequest(id, loc, *requestedHash, stream, node, requestedView)
@@ -193,39 +213,69 @@ func handleRequest(quaiMsg *pb.QuaiRequestMessage, stream network.Stream, node Q
 					"peer": stream.Conn().RemotePeer(),
 					"err":  err,
 				}).Error("error handling block request")
-			// TODO: handle error
+			sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INTERNAL_ERROR, "failed to send block", stream)
 			return
 		}
 		if messageMetrics != nil {
 			messageMetrics.WithLabelValues("blocks").Inc()
 		}
 	case *types.Transaction:
-		requestedHash := query.(*common.Hash)
+		requestedHash, ok := query.(*common.Hash)
+		if !ok {
+			log.Global.WithField("query", query).Error("transactions can only be requested by hash")
+			sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INVALID_REQUEST, "transactions can only be requested by hash", stream)
+			return
+		}
 		err = handleTransactionRequest(id, loc, *requestedHash, stream, node)
 		if err != nil {
 			log.Global.WithField("err", err).Error("error handling transaction request")
-			// TODO: handle error
+			sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INTERNAL_ERROR, "failed to send transaction", stream)
 			return
 		}
 		if messageMetrics != nil {
 			messageMetrics.WithLabelValues("transactions").Inc()
 		}
 	case *common.Hash:
-		number := query.(*big.Int)
+		number, ok := query.(*big.Int)
+		if !ok {
+			log.Global.WithField("query", query).Error("block hashes can only be requested by number")
+			sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INVALID_REQUEST, "block hashes can only be requested by number", stream)
+			return
+		}
 		err = handleBlockNumberRequest(id, loc, number, stream, node)
 		if err != nil {
 			log.Global.WithField("err", err).Error("error handling block number request")
+			sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INTERNAL_ERROR, "failed to send block hash", stream)
+			return
+		}
+	case *types.WorkObjectHeaderViews:
+		headerRange, ok := query.(*types.HeaderRangeRequest)
+		if !ok {
+			log.Global.WithField("query", query).Error("header ranges can only be requested by range")
+			sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INVALID_REQUEST, "header ranges can only be requested by range", stream)
+			return
+		}
+		err = handleHeaderRangeRequest(id, loc, headerRange, stream, node)
+		if err != nil {
+			log.Global.WithField("err", err).Error("error handling header range request")
+			sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INTERNAL_ERROR, "failed to send header range", stream)
 			return
 		}
 	case trie.TrieNodeRequest:
-		requestedHash := query.(*common.Hash)
+		requestedHash, ok := query.(*common.Hash)
+		if !ok {
+			log.Global.WithField("query", query).Error("trie nodes can only be requested by hash")
+			sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INVALID_REQUEST, "trie nodes can only be requested by hash", stream)
+			return
+		}
 		err := handleTrieNodeRequest(id, loc, *requestedHash, stream, node)
 		if err != nil {
 			log.Global.WithField("err", err).Error("error handling trie node request")
+			sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INTERNAL_ERROR, "failed to send trie node", stream)
 		}
 	default:
 		log.Global.WithField("request type", decodedType).Error("unsupported request data type")
-		// TODO: handle error
+		sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INVALID_REQUEST, "unsupported request data type", stream)
 		return
 
 	}
@@ -260,6 +310,7 @@ func handleBlockRequest(id uint32, loc common.Location, hash common.Hash, stream
 	fullWO := node.GetWorkObject(hash, loc)
 	if fullWO == nil {
 		log.Global.Debugf("block not found")
+		sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_NOT_FOUND, "block not found", stream)
 		return nil
 	}
 	log.Global.Debugf("block found %s", fullWO.Hash())
@@ -283,8 +334,26 @@ func handleBlockRequest(id uint32, loc common.Location, hash common.Hash, stream
 	return nil
 }
 
+// Seeks the transaction in the tx pool or database and sends it to the peer in a pb.QuaiResponseMessage
 func handleTransactionRequest(id uint32, loc common.Location, hash common.Hash, stream network.Stream, node QuaiP2PNode) error {
-	panic("TODO: implement")
+	tx := node.GetTransaction(hash, loc)
+	if tx == nil {
+		log.Global.Tracef("transaction not found")
+		sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_NOT_FOUND, "transaction not found", stream)
+		return nil
+	}
+	log.Global.Tracef("transaction found %s", tx.Hash())
+	// create a Quai Message Response with the transaction
+	data, err := pb.EncodeQuaiResponse(id, loc, tx)
+	if err != nil {
+		return err
+	}
+	err = common.WriteMessageToStream(stream, data)
+	if err != nil {
+		return err
+	}
+	log.Global.Tracef("Sent transaction %s to peer %s", hash, stream.Conn().RemotePeer())
+	return nil
 }
 
 // Seeks the block in the cache or database and sends it to the peer in a pb.QuaiResponseMessage
@@ -293,6 +362,7 @@ func handleBlockNumberRequest(id uint32, loc common.Location, number *big.Int, s
 	blockHash := node.GetBlockHashByNumber(number, loc)
 	if blockHash == nil {
 		log.Global.Tracef("block not found")
+		sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_NOT_FOUND, "block not found", stream)
 		return nil
 	}
 	log.Global.Tracef("block found %s", blockHash)
@@ -310,10 +380,53 @@ func handleBlockNumberRequest(id uint32, loc common.Location, number *big.Int, s
 	return nil
 }
 
+// Collects the headers of the requested range from the database and sends them to
+// the peer in a pb.QuaiResponseMessage. The range stops at the first missing header
+func handleHeaderRangeRequest(id uint32, loc common.Location, headerRange *types.HeaderRangeRequest, stream network.Stream, node QuaiP2PNode) error {
+	if headerRange.Count == 0 || headerRange.Count > MaxHeaderRangeCount {
+		sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_INVALID_REQUEST, fmt.Sprintf("header range count must be between 1 and %d", MaxHeaderRangeCount), stream)
+		return nil
+	}
+	step := new(big.Int).SetUint64(headerRange.Skip + 1)
+	number := new(big.Int).Set(headerRange.Start)
+	headers := make(types.WorkObjectHeaderViews, 0, headerRange.Count)
+	for i := uint64(0); i < headerRange.Count; i++ {
+		hash := node.GetBlockHashByNumber(number, loc)
+		if hash == nil {
+			break
+		}
+		wo := node.GetWorkObject(*hash, loc)
+		if wo == nil {
+			break
+		}
+		headers = append(headers, wo.ConvertToHeaderView())
+		number = new(big.Int).Add(number, step)
+	}
+	if len(headers) == 0 {
+		log.Global.Tracef("header range not found")
+		sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_NOT_FOUND, "header range not found", stream)
+		return nil
+	}
+	data, err := pb.EncodeQuaiResponse(id, loc, headers)
+	if err != nil {
+		return err
+	}
+	err = common.WriteMessageToStream(stream, data)
+	if err != nil {
+		return err
+	}
+	if messageMetrics != nil {
+		messageMetrics.WithLabelValues("headers").Add(float64(len(headers)))
+	}
+	log.Global.Tracef("Sent %d headers from %s to peer %s", len(headers), headerRange.Start, stream.Conn().RemotePeer())
+	return nil
+}
+
 func handleTrieNodeRequest(id uint32, loc common.Location, hash common.Hash, stream network.Stream, node QuaiP2PNode) error {
 	trieNode := node.GetTrieNode(hash, loc)
 	if trieNode == nil {
 		log.Global.Tracef("trie node not found")
+		sendErrorResponse(id, loc, pb.ResponseStatus_RESPONSE_STATUS_NOT_FOUND, "trie node not found", stream)
 		return nil
 	}
 	log.Global.Tracef("trie node found")
@@ -328,3 +441,22 @@ func handleTrieNodeRequest(id uint32, loc common.Location, hash common.Hash, str
 	log.Global.Tracef("Sent trie node to peer %s", stream.Conn().RemotePeer())
 	return nil
 }
+
+// Answers a request which can not be served with a pb.QuaiResponseMessage carrying
+// the reason, so that the requester does not have to wait for the request to time out
+func sendErrorResponse(id uint32, loc common.Location, status pb.ResponseStatus, message string, stream network.Stream) {
+	data, err := pb.EncodeQuaiResponse(id, loc, pb.NewResponseError(status, message))
+	if err != nil {
+		log.Global.WithField("err", err).Error("error encoding error response")
+		return
+	}
+	err = common.WriteMessageToStream(stream, data)
+	if err != nil {
+		log.Global.WithFields(log.Fields{
+			"peer": stream.Conn().RemotePeer(),
+			"err":  err,
+		}).Debug("error sending error response")
+		return
+	}
+	log.Global.Tracef("Sent %s response to peer %s", status, stream.Conn().RemotePeer())
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/p2p/node/requestManager"
+	"github.com/dominant-strategies/go-quai/p2p/pb"
 	"github.com/dominant-strategies/go-quai/trie"
 )
 
@@ -19,9 +20,21 @@ type QuaiP2PNode interface {
 	// Returns nil if the block is not found.
 	GetWorkObject(hash common.Hash, location common.Location) *types.WorkObject
 	GetBlockHashByNumber(number *big.Int, location common.Location) *common.Hash
+	// Search for a transaction in the node's tx pool, or in the transaction lookup index.
+	// Returns nil if the transaction is not found.
+	GetTransaction(hash common.Hash, location common.Location) *types.Transaction
 	GetTrieNode(hash common.Hash, location common.Location) *trie.TrieNodeResponse
 	GetRequestManager() requestManager.RequestManager
 
+	// Returns the genesis hash and the heads of the chains running locally, which
+	// are announced to peers when a QuaiProtocol stream opens
+	GetGenesis() common.Hash
+	GetChainHeads() []pb.ChainHead
+	// Records the status announced by a peer which passed the handshake
+	SetPeerStatus(peer.ID, *pb.PeerStatus)
+	// Disconnects a peer which is not compatible with the local node
+	DropIncompatiblePeer(peer.ID, error)
+
 	Connect(peer.AddrInfo) error
 	NewStream(peer.ID) (network.Stream, error)
 }
@@ -9,7 +9,7 @@ import (
 
 func TestMain(m *testing.M) {
 	// Comment / un comment below to see log output while testing
-	// log.ConfigureLogger(log.WithNullLogger())
-	log.ConfigureLogger(log.WithLevel("debug"))
+	// log.SetGlobalLogger("", "trace")
+	log.SetGlobalLogger("", "debug")
 	os.Exit(m.Run())
 }
@@ -0,0 +1,100 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/p2p/pb"
+)
+
+// c_statusTimeout is the time allowed to a peer to complete the status handshake
+const c_statusTimeout = 10 * time.Second
+
+var (
+	// ErrIncompatiblePeer is wrapped by the handshake errors of the peers
+	// which are not compatible with the local node
+	ErrIncompatiblePeer = errors.New("incompatible peer")
+
+	errGenesisMismatch     = fmt.Errorf("%w: genesis mismatch", ErrIncompatiblePeer)
+	errIncompatibleVersion = fmt.Errorf("%w: incompatible status version", ErrIncompatiblePeer)
+	errInvalidLocation     = fmt.Errorf("%w: invalid chain location", ErrIncompatiblePeer)
+)
+
+// SendJoinRequest runs the status handshake on a QuaiProtocol stream opened
+// to a peer. It must complete before any request is sent on the stream. The
+// caller is expected to disconnect the peer if the returned error wraps
+// ErrIncompatiblePeer.
+func SendJoinRequest(stream network.Stream, node QuaiP2PNode) error {
+	stream.SetDeadline(time.Now().Add(c_statusTimeout))
+	defer stream.SetDeadline(time.Time{})
+
+	if err := writeStatus(stream, node); err != nil {
+		return err
+	}
+	data, err := common.ReadMessageFromStream(stream)
+	if err != nil {
+		return err
+	}
+	status, err := pb.DecodeStatus(data)
+	if err != nil {
+		return err
+	}
+	return admitPeer(stream, node, status)
+}
+
+// Writes the local status to the stream
+func writeStatus(stream network.Stream, node QuaiP2PNode) error {
+	data, err := pb.EncodeStatus(&pb.PeerStatus{
+		Version: StatusVersion,
+		Genesis: node.GetGenesis(),
+		Heads:   node.GetChainHeads(),
+	})
+	if err != nil {
+		return err
+	}
+	return common.WriteMessageToStream(stream, data)
+}
+
+// Checks the status announced by the peer and records it if the peer is
+// compatible with the local node.
+func admitPeer(stream network.Stream, node QuaiP2PNode, status *pb.PeerStatus) error {
+	peerID := stream.Conn().RemotePeer()
+	if err := validateStatus(node, status); err != nil {
+		return err
+	}
+	log.Global.WithFields(log.Fields{
+		"peer":    peerID,
+		"version": status.Version,
+		"heads":   len(status.Heads),
+	}).Debug("Admitted peer")
+	node.SetPeerStatus(peerID, status)
+	return nil
+}
+
+func validateStatus(node QuaiP2PNode, status *pb.PeerStatus) error {
+	if genesis := node.GetGenesis(); status.Genesis != genesis {
+		return fmt.Errorf("%w: local %s, peer %s", errGenesisMismatch, genesis, status.Genesis)
+	}
+	if status.Version < MinStatusVersion {
+		return fmt.Errorf("%w: minimum %d, peer %d", errIncompatibleVersion, MinStatusVersion, status.Version)
+	}
+	// The heads are recorded to choose the peers serving a location, so only
+	// the locations of the hierarchy are accepted, once each
+	seen := make(map[string]struct{}, len(status.Heads))
+	for _, head := range status.Heads {
+		location := head.Location
+		if len(location) > common.HierarchyDepth-1 || location.Region() >= common.MaxRegions || location.Zone() >= common.MaxZones {
+			return fmt.Errorf("%w: %v", errInvalidLocation, location)
+		}
+		if _, ok := seen[string(location)]; ok {
+			return fmt.Errorf("%w: %v announced twice", errInvalidLocation, location)
+		}
+		seen[string(location)] = struct{}{}
+	}
+	return nil
+}
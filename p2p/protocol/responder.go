@@ -1,11 +1,35 @@
 package protocol
 
 import (
+	"time"
+
 	"github.com/libp2p/go-libp2p/core/network"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/p2p/pb"
 )
 
-// Handles a peer's request to join the Quai p2p network.
-func processJoinRequest(stream network.Stream) error {
-	// TODO: implement
+// Handles a peer's request to join the Quai p2p network. The peer opening the
+// stream sends its status first, and is answered with the local one. The peer
+// is disconnected if it runs another network or an incompatible version.
+func processJoinRequest(stream network.Stream, node QuaiP2PNode) error {
+	stream.SetDeadline(time.Now().Add(c_statusTimeout))
+	defer stream.SetDeadline(time.Time{})
+
+	data, err := common.ReadMessageFromStream(stream)
+	if err != nil {
+		return err
+	}
+	status, err := pb.DecodeStatus(data)
+	if err != nil {
+		return err
+	}
+	if err := writeStatus(stream, node); err != nil {
+		return err
+	}
+	if err := admitPeer(stream, node, status); err != nil {
+		node.DropIncompatiblePeer(stream.Conn().RemotePeer(), err)
+		return err
+	}
 	return nil
 }
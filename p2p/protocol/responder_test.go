@@ -0,0 +1,160 @@
+package protocol
+
+import (
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/p2p/pb"
+)
+
+// testNode serves the status of a node, the other methods of QuaiP2PNode panic
+type testNode struct {
+	QuaiP2PNode
+	genesis common.Hash
+	heads   []pb.ChainHead
+
+	statuses map[peer.ID]*pb.PeerStatus
+	dropped  map[peer.ID]error
+}
+
+func newTestNode(genesis common.Hash, locations ...common.Location) *testNode {
+	node := &testNode{
+		genesis:  genesis,
+		statuses: make(map[peer.ID]*pb.PeerStatus),
+		dropped:  make(map[peer.ID]error),
+	}
+	for _, location := range locations {
+		node.heads = append(node.heads, pb.ChainHead{Location: location, Hash: common.Hash{0x01}, Entropy: big.NewInt(1)})
+	}
+	return node
+}
+
+func (n *testNode) GetGenesis() common.Hash                    { return n.genesis }
+func (n *testNode) GetChainHeads() []pb.ChainHead              { return n.heads }
+func (n *testNode) DropIncompatiblePeer(id peer.ID, err error) { n.dropped[id] = err }
+func (n *testNode) SetPeerStatus(id peer.ID, status *pb.PeerStatus) {
+	n.statuses[id] = status
+}
+
+// testStream is one end of an in memory QuaiProtocol stream
+type testStream struct {
+	network.Stream
+	pipe   net.Conn
+	remote peer.ID
+}
+
+func (s *testStream) Read(b []byte) (int, error)         { return s.pipe.Read(b) }
+func (s *testStream) Write(b []byte) (int, error)        { return s.pipe.Write(b) }
+func (s *testStream) Close() error                       { return s.pipe.Close() }
+func (s *testStream) SetDeadline(t time.Time) error      { return s.pipe.SetDeadline(t) }
+func (s *testStream) SetReadDeadline(t time.Time) error  { return s.pipe.SetReadDeadline(t) }
+func (s *testStream) SetWriteDeadline(t time.Time) error { return s.pipe.SetWriteDeadline(t) }
+func (s *testStream) Conn() network.Conn                 { return &testConn{remote: s.remote} }
+
+// testConn is the connection of a testStream
+type testConn struct {
+	network.Conn
+	remote peer.ID
+}
+
+func (c *testConn) RemotePeer() peer.ID { return c.remote }
+
+// runHandshake runs the status handshake between a node opening a stream and
+// a node accepting it, returning the errors of both sides
+func runHandshake(t *testing.T, opener, acceptor *testNode) (error, error) {
+	t.Helper()
+	openerEnd, acceptorEnd := net.Pipe()
+	defer openerEnd.Close()
+	defer acceptorEnd.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		err := processJoinRequest(&testStream{pipe: acceptorEnd, remote: "opener"}, acceptor)
+		// Unblock the opener if the acceptor gave up before answering
+		acceptorEnd.Close()
+		errc <- err
+	}()
+	openErr := SendJoinRequest(&testStream{pipe: openerEnd, remote: "acceptor"}, opener)
+	return openErr, <-errc
+}
+
+func TestJoinRequest(t *testing.T) {
+	genesis := common.Hash{0x0a}
+	// Prime is announced with an empty location, which is decoded as nil
+	opener := newTestNode(genesis, nil, common.Location{0}, common.Location{0, 0})
+	acceptor := newTestNode(genesis, nil, common.Location{0}, common.Location{0, 1})
+
+	openErr, acceptErr := runHandshake(t, opener, acceptor)
+	require.NoError(t, openErr)
+	require.NoError(t, acceptErr)
+
+	// Both sides recorded the locations served by the other one
+	require.Equal(t, StatusVersion, acceptor.statuses["opener"].Version)
+	require.Equal(t, opener.heads, acceptor.statuses["opener"].Heads)
+	require.Equal(t, acceptor.heads, opener.statuses["acceptor"].Heads)
+	require.Empty(t, acceptor.dropped)
+}
+
+func TestJoinRequestRejected(t *testing.T) {
+	genesis := common.Hash{0x0a}
+	tests := []struct {
+		name   string
+		opener *testNode
+		err    error
+	}{
+		{
+			name:   "genesis mismatch",
+			opener: newTestNode(common.Hash{0x0b}, common.Location{0, 0}),
+			err:    errGenesisMismatch,
+		},
+		{
+			name:   "location outside the hierarchy",
+			opener: newTestNode(genesis, common.Location{0, common.MaxZones}),
+			err:    errInvalidLocation,
+		},
+		{
+			name:   "location below a zone",
+			opener: newTestNode(genesis, common.Location{0, 0, 0}),
+			err:    errInvalidLocation,
+		},
+		{
+			name:   "location announced twice",
+			opener: newTestNode(genesis, common.Location{0, 0}, common.Location{0, 0}),
+			err:    errInvalidLocation,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			acceptor := newTestNode(genesis, common.Location{0, 0})
+			openErr, acceptErr := runHandshake(t, test.opener, acceptor)
+
+			// The acceptor drops the opener without recording its status
+			require.True(t, errors.Is(acceptErr, test.err), "have %v, want %v", acceptErr, test.err)
+			require.True(t, errors.Is(acceptor.dropped["opener"], ErrIncompatiblePeer))
+			require.Empty(t, acceptor.statuses)
+
+			// The opener rejects the acceptor on a genesis mismatch as well
+			if test.err == errGenesisMismatch {
+				require.True(t, errors.Is(openErr, errGenesisMismatch), "have %v, want %v", openErr, errGenesisMismatch)
+				require.Empty(t, test.opener.statuses)
+			}
+		})
+	}
+}
+
+func TestValidateStatusVersion(t *testing.T) {
+	node := newTestNode(common.Hash{0x0a})
+	status := &pb.PeerStatus{Version: MinStatusVersion - 1, Genesis: node.genesis}
+	require.True(t, errors.Is(validateStatus(node, status), errIncompatibleVersion))
+
+	status.Version = MinStatusVersion
+	require.NoError(t, validateStatus(node, status))
+}
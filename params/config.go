@@ -115,7 +115,7 @@ var (
 	AllProgpowProtocolChanges = &ChainConfig{big.NewInt(1337), "progpow", new(Blake3powConfig), new(ProgpowConfig), common.Location{}, common.Hash{}}
 
 	TestChainConfig = &ChainConfig{big.NewInt(1), "progpow", new(Blake3powConfig), new(ProgpowConfig), common.Location{}, common.Hash{}}
-	TestRules       = TestChainConfig.Rules(new(big.Int))
+	TestRules       = TestChainConfig.Rules(new(big.Int), 0)
 )
 
 // ChainConfig is the core config which determines the blockchain settings.
@@ -131,6 +131,13 @@ type ChainConfig struct {
 	Progpow            *ProgpowConfig   `json:"progpow,omitempty"`
 	Location           common.Location
 	DefaultGenesisHash common.Hash
+
+	// Fork schedule. A fork is scheduled with an XxxBlock *big.Int field,
+	// activating it at a block number of the chain the config belongs to, and
+	// an XxxExpansion *uint8 field, activating it at an expansion number of
+	// the hierarchy, whichever comes first. A nil number means the fork is not
+	// scheduled, and zero that it is active from genesis. No fork is scheduled
+	// yet, every chain runs the genesis ruleset.
 }
 
 // SetLocation sets the location on the chain config
@@ -172,6 +179,70 @@ func (c *ChainConfig) String() string {
 	)
 }
 
+// GasTable returns the gas table of the fork active at the given block and
+// expansion numbers.
+func (c *ChainConfig) GasTable(num *big.Int, expansion uint8) GasTable {
+	return GasTableGenesis
+}
+
+// CheckCompatible checks whether scheduled fork transitions have been imported
+// with a mismatching chain configuration.
+func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64, expansion uint8) *ConfigCompatError {
+	bhead := new(big.Int).SetUint64(height)
+
+	// Iterate checkCompatible to find the lowest conflict.
+	var lasterr *ConfigCompatError
+	for {
+		err := c.checkCompatible(newcfg, bhead, expansion)
+		if err == nil || (lasterr != nil && err.RewindTo == lasterr.RewindTo) {
+			break
+		}
+		lasterr = err
+		bhead.SetUint64(err.RewindTo)
+	}
+	return lasterr
+}
+
+func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int, expansion uint8) *ConfigCompatError {
+	if c == nil || newcfg == nil {
+		return nil
+	}
+	// Every scheduled fork is checked here, in activation order, with
+	// isForkIncompatible returning a newCompatError for its block and
+	// isExpansionForkIncompatible a newExpansionCompatError for its expansion.
+	return nil
+}
+
+// isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
+// block s2 because head is already past the fork.
+func isForkIncompatible(s1, s2, head *big.Int) bool {
+	return (isForked(s1, head) || isForked(s2, head)) && !configNumEqual(s1, s2)
+}
+
+// isForked returns whether a fork scheduled at block s is active at the given head block.
+func isForked(s, head *big.Int) bool {
+	if s == nil || head == nil {
+		return false
+	}
+	return s.Cmp(head) <= 0
+}
+
+// isExpansionForkIncompatible returns true if a fork scheduled at expansion s1
+// cannot be rescheduled to expansion s2 because the hierarchy has already
+// expanded past the fork.
+func isExpansionForkIncompatible(s1, s2 *uint8, expansion uint8) bool {
+	return (isExpansionForked(s1, expansion) || isExpansionForked(s2, expansion)) && !configExpansionEqual(s1, s2)
+}
+
+// isExpansionForked returns whether a fork scheduled at expansion s is active
+// at the given expansion number.
+func isExpansionForked(s *uint8, expansion uint8) bool {
+	if s == nil {
+		return false
+	}
+	return *s <= expansion
+}
+
 func configNumEqual(x, y *big.Int) bool {
 	if x == nil {
 		return y == nil
@@ -182,12 +253,24 @@ func configNumEqual(x, y *big.Int) bool {
 	return x.Cmp(y) == 0
 }
 
+func configExpansionEqual(x, y *uint8) bool {
+	if x == nil {
+		return y == nil
+	}
+	if y == nil {
+		return x == nil
+	}
+	return *x == *y
+}
+
 // ConfigCompatError is raised if the locally-stored blockchain is initialised with a
 // ChainConfig that would alter the past.
 type ConfigCompatError struct {
 	What string
-	// block numbers of the stored and new configurations
+	// block numbers of the stored and new configurations if block based forking
 	StoredConfig, NewConfig *big.Int
+	// expansion numbers of the stored and new configurations if expansion based forking
+	StoredExpansion, NewExpansion *uint8
 	// the block number to which the local chain must be rewound to correct the error
 	RewindTo uint64
 }
@@ -202,17 +285,37 @@ func newCompatError(what string, storedblock, newblock *big.Int) *ConfigCompatEr
 	default:
 		rew = newblock
 	}
-	err := &ConfigCompatError{what, storedblock, newblock, 0}
+	err := &ConfigCompatError{What: what, StoredConfig: storedblock, NewConfig: newblock}
 	if rew != nil && rew.Sign() > 0 {
 		err.RewindTo = rew.Uint64() - 1
 	}
 	return err
 }
 
+// newExpansionCompatError returns the error of a fork rescheduled to another
+// expansion. The expansions of the hierarchy cannot be undone, so there is no
+// block the chain can be rewound to.
+func newExpansionCompatError(what string, storedexpansion, newexpansion *uint8) *ConfigCompatError {
+	return &ConfigCompatError{What: what, StoredExpansion: storedexpansion, NewExpansion: newexpansion}
+}
+
 func (err *ConfigCompatError) Error() string {
+	if err.StoredConfig == nil && err.NewConfig == nil && (err.StoredExpansion != nil || err.NewExpansion != nil) {
+		return fmt.Sprintf("mismatching %s in database (have %s, want %s)", err.What, fmtExpansion(err.StoredExpansion), fmtExpansion(err.NewExpansion))
+	}
 	return fmt.Sprintf("mismatching %s in database (have %d, want %d, rewindto %d)", err.What, err.StoredConfig, err.NewConfig, err.RewindTo)
 }
 
+// fmtExpansion formats an optional expansion number
+func fmtExpansion(expansion *uint8) string {
+	if expansion == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("%d", *expansion)
+}
+
+func newUint8(val uint8) *uint8 { return &val }
+
 // Rules wraps ChainConfig and is merely syntactic sugar or can be used for functions
 // that do not have or require information about the block.
 //
@@ -223,7 +326,7 @@ type Rules struct {
 }
 
 // Rules ensures c's ChainID is not nil.
-func (c *ChainConfig) Rules(num *big.Int) Rules {
+func (c *ChainConfig) Rules(num *big.Int, expansion uint8) Rules {
 	chainID := c.ChainID
 	if chainID == nil {
 		chainID = new(big.Int)
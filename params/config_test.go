@@ -17,6 +17,7 @@
 package params
 
 import (
+	"math/big"
 	"reflect"
 	"testing"
 )
@@ -25,6 +26,7 @@ func TestCheckCompatible(t *testing.T) {
 	type test struct {
 		stored, new *ChainConfig
 		head        uint64
+		expansion   uint8
 		wantErr     *ConfigCompatError
 	}
 	tests := []test{
@@ -37,9 +39,55 @@ func TestCheckCompatible(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		err := test.stored.CheckCompatible(test.new, test.head)
+		err := test.stored.CheckCompatible(test.new, test.head, test.expansion)
 		if !reflect.DeepEqual(err, test.wantErr) {
-			t.Errorf("error mismatch:\nstored: %v\nnew: %v\nhead: %v\nerr: %v\nwant: %v", test.stored, test.new, test.head, err, test.wantErr)
+			t.Errorf("error mismatch:\nstored: %v\nnew: %v\nhead: %v\nexpansion: %v\nerr: %v\nwant: %v", test.stored, test.new, test.head, test.expansion, err, test.wantErr)
 		}
 	}
 }
+
+func TestForkIncompatible(t *testing.T) {
+	tests := []struct {
+		stored, new *big.Int
+		head        int64
+		want        bool
+	}{
+		{stored: nil, new: big.NewInt(20), head: 9, want: false},
+		{stored: big.NewInt(10), new: big.NewInt(20), head: 9, want: false},
+		{stored: big.NewInt(10), new: big.NewInt(10), head: 15, want: false},
+		{stored: big.NewInt(10), new: nil, head: 15, want: true},
+		{stored: big.NewInt(30), new: big.NewInt(20), head: 25, want: true},
+	}
+	for _, test := range tests {
+		if have := isForkIncompatible(test.stored, test.new, big.NewInt(test.head)); have != test.want {
+			t.Errorf("stored %v new %v head %d: have %v, want %v", test.stored, test.new, test.head, have, test.want)
+		}
+	}
+	want := &ConfigCompatError{What: "fork block", StoredConfig: big.NewInt(30), NewConfig: big.NewInt(20), RewindTo: 19}
+	if err := newCompatError("fork block", big.NewInt(30), big.NewInt(20)); !reflect.DeepEqual(err, want) {
+		t.Errorf("error mismatch: have %v, want %v", err, want)
+	}
+}
+
+func TestExpansionForkIncompatible(t *testing.T) {
+	tests := []struct {
+		stored, new *uint8
+		expansion   uint8
+		want        bool
+	}{
+		{stored: nil, new: newUint8(2), expansion: 1, want: false},
+		{stored: newUint8(2), new: newUint8(3), expansion: 1, want: false},
+		{stored: newUint8(1), new: newUint8(1), expansion: 1, want: false},
+		{stored: newUint8(1), new: newUint8(2), expansion: 1, want: true},
+		{stored: nil, new: newUint8(0), expansion: 0, want: true},
+	}
+	for _, test := range tests {
+		if have := isExpansionForkIncompatible(test.stored, test.new, test.expansion); have != test.want {
+			t.Errorf("stored %s new %s expansion %d: have %v, want %v", fmtExpansion(test.stored), fmtExpansion(test.new), test.expansion, have, test.want)
+		}
+	}
+	err := newExpansionCompatError("fork expansion", newUint8(1), newUint8(2))
+	if want := "mismatching fork expansion in database (have 1, want 2)"; err.Error() != want {
+		t.Errorf("error mismatch: have %q, want %q", err.Error(), want)
+	}
+}
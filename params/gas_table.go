@@ -0,0 +1,47 @@
+// Copyright 2016 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package params
+
+// GasTable organizes the gas prices which can be changed by a fork.
+type GasTable struct {
+	Tx                 uint64 // Per transaction not creating a contract
+	TxContractCreation uint64 // Per transaction that creates a contract
+	TxDataZero         uint64 // Per byte of data attached to a transaction that equals zero
+	TxDataNonZero      uint64 // Per byte of data attached to a transaction that is not equal to zero
+	ETX                uint64 // Per ETX generated by opETX or normal cross-chain transfer
+
+	CallValueTransfer uint64 // Paid for CALL when the value transfer is non-zero, and per Qi to Quai conversion output
+	CallNewAccount    uint64 // Paid for CALL when the destination address didn't exist prior
+	SstoreSet         uint64 // Once per SSTORE operation from clean zero to non-zero
+	SstoreReset       uint64 // Once per SSTORE operation from clean non-zero to something else
+}
+
+var (
+	// GasTableGenesis contains the gas prices of the chain from genesis.
+	GasTableGenesis = GasTable{
+		Tx:                 TxGas,
+		TxContractCreation: TxGasContractCreation,
+		TxDataZero:         TxDataZeroGas,
+		TxDataNonZero:      TxDataNonZeroGas,
+		ETX:                ETXGas,
+
+		CallValueTransfer: CallValueTransferGas,
+		CallNewAccount:    CallNewAccountGas,
+		SstoreSet:         SstoreSetGas,
+		SstoreReset:       SstoreResetGas,
+	}
+)
@@ -32,6 +32,14 @@ const (
 	// considered probably final and its rotated bits are calculated.
 	BloomConfirms = 256
 
+	// AddressHistoryBlocks is the number of blocks a single address history
+	// index section contains.
+	AddressHistoryBlocks uint64 = 32
+
+	// AddressHistoryConfirms is the number of confirmation blocks before an
+	// address history section is indexed.
+	AddressHistoryConfirms = 8
+
 	// CHTFrequency is the block frequency for creating CHTs
 	CHTFrequency = 32768
 
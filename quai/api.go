@@ -121,6 +121,15 @@ func (api *PrivateMinerAPI) SetRecommitInterval(interval int) {
 	api.e.Core().SetRecommitInterval(time.Duration(interval) * time.Millisecond)
 }
 
+// StratumWorkers returns the statistics of the workers mining through the
+// stratum server.
+func (api *PrivateMinerAPI) StratumWorkers() ([]core.StratumWorkerStats, error) {
+	if api.e.stratum == nil {
+		return nil, errors.New("stratum server is not enabled")
+	}
+	return api.e.stratum.Workers(), nil
+}
+
 // PrivateAdminAPI is the collection of Quai full node-related APIs
 // exposed over the private admin endpoint.
 type PrivateAdminAPI struct {
@@ -133,9 +142,26 @@ func NewPrivateAdminAPI(quai *Quai) *PrivateAdminAPI {
 	return &PrivateAdminAPI{quai: quai}
 }
 
+// chainFormat returns the block export format selected by an optional admin
+// API argument. The RLP format is the default for compatibility.
+func chainFormat(format *string) (string, error) {
+	if format == nil {
+		return core.ExportFormatRLP, nil
+	}
+	if *format != core.ExportFormatRLP && *format != core.ExportFormatProto {
+		return "", fmt.Errorf("unknown block format %q, want %s or %s", *format, core.ExportFormatRLP, core.ExportFormatProto)
+	}
+	return *format, nil
+}
+
 // ExportChain exports the current blockchain into a local file,
-// or a range of blocks if first and last are non-nil
-func (api *PrivateAdminAPI) ExportChain(file string, first *uint64, last *uint64) (bool, error) {
+// or a range of blocks if first and last are non-nil. The blocks are
+// encoded in the optional format, RLP by default.
+func (api *PrivateAdminAPI) ExportChain(file string, first *uint64, last *uint64, format *string) (bool, error) {
+	exportFormat, err := chainFormat(format)
+	if err != nil {
+		return false, err
+	}
 	if first == nil && last != nil {
 		return false, errors.New("last cannot be specified without first")
 	}
@@ -163,10 +189,10 @@ func (api *PrivateAdminAPI) ExportChain(file string, first *uint64, last *uint64
 
 	// Export the blockchain
 	if first != nil {
-		if err := api.quai.Core().ExportN(writer, *first, *last); err != nil {
+		if err := api.quai.Core().ExportN(writer, *first, *last, exportFormat); err != nil {
 			return false, err
 		}
-	} else if err := api.quai.Core().Export(writer); err != nil {
+	} else if err := api.quai.Core().Export(writer, exportFormat); err != nil {
 		return false, err
 	}
 	return true, nil
@@ -181,8 +207,13 @@ func hasAllBlocks(chain *core.Core, bs []*types.WorkObject) bool {
 	return true
 }
 
-// ImportChain imports a blockchain from a local file.
-func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
+// ImportChain imports a blockchain from a local file, whose blocks are
+// encoded in the optional format, RLP by default.
+func (api *PrivateAdminAPI) ImportChain(file string, format *string) (bool, error) {
+	importFormat, err := chainFormat(format)
+	if err != nil {
+		return false, err
+	}
 	// Make sure the can access the file to import
 	in, err := os.Open(file)
 	if err != nil {
@@ -198,14 +229,17 @@ func (api *PrivateAdminAPI) ImportChain(file string) (bool, error) {
 	}
 
 	// Run actual the import in pre-configured batches
-	stream := rlp.NewStream(reader, 0)
+	stream, err := core.NewBlockDecoder(reader, importFormat, api.quai.core.NodeLocation())
+	if err != nil {
+		return false, err
+	}
 
 	blocks, index := make([]*types.WorkObject, 0, 2500), 0
 	for batch := 0; ; batch++ {
 		// Load a batch of blocks from the input file
 		for len(blocks) < cap(blocks) {
-			block := new(types.WorkObject)
-			if err := stream.Decode(block); err == io.EOF {
+			block, err := stream.Next()
+			if err == io.EOF {
 				break
 			} else if err != nil {
 				return false, fmt.Errorf("block %d: failed to parse: %v", index, err)
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"math/big"
 
+	goquai "github.com/dominant-strategies/go-quai"
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/consensus"
 	"github.com/dominant-strategies/go-quai/core"
@@ -31,6 +32,7 @@ import (
 	"github.com/dominant-strategies/go-quai/core/vm"
 	"github.com/dominant-strategies/go-quai/ethdb"
 	"github.com/dominant-strategies/go-quai/event"
+	"github.com/dominant-strategies/go-quai/internal/quaiapi"
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/params"
 	"github.com/dominant-strategies/go-quai/quai/gasprice"
@@ -43,6 +45,7 @@ type QuaiAPIBackend struct {
 	extRPCEnabled bool
 	quai          *Quai
 	gpo           *gasprice.Oracle
+	slices        quaiapi.SliceBackends
 }
 
 // ChainConfig returns the active chain configuration.
@@ -220,6 +223,45 @@ func (b *QuaiAPIBackend) UTXOsByAddress(ctx context.Context, address common.Addr
 	return b.quai.core.GetUTXOsByAddress(address)
 }
 
+func (b *QuaiAPIBackend) OutpointsByAddress(ctx context.Context, address common.Address) ([]*types.OutpointAndDenomination, error) {
+	return b.quai.core.GetOutpointsByAddress(address)
+}
+
+// AddressHistory returns up to limit canonical entries of the history of an
+// address, starting at the given position and up to the given block number,
+// together with the number of blocks indexed so far.
+func (b *QuaiAPIBackend) AddressHistory(ctx context.Context, address common.Address, start []byte, end uint64, limit int) ([]*rawdb.AddressHistoryEntry, uint64, error) {
+	if b.quai.addressHistoryIndexer == nil {
+		return nil, 0, errors.New("address history indexing is disabled")
+	}
+	sections, _, _ := b.quai.addressHistoryIndexer.Sections()
+	indexed := sections * params.AddressHistoryBlocks
+	if indexed == 0 {
+		return nil, 0, nil
+	}
+	if end >= indexed {
+		end = indexed - 1
+	}
+	db := b.quai.ChainDb()
+	var entries []*rawdb.AddressHistoryEntry
+	for len(entries) < limit {
+		wanted := limit - len(entries)
+		page := rawdb.ReadAddressHistory(db, address, start, end, wanted)
+		for _, entry := range page {
+			// Skip the entries of blocks that were reorged out
+			if rawdb.ReadCanonicalHash(db, entry.BlockNumber) == entry.BlockHash {
+				entries = append(entries, entry)
+			}
+		}
+		if len(page) < wanted {
+			break
+		}
+		last := page[len(page)-1]
+		start = rawdb.AddressHistoryPosition(last.BlockNumber, last.TxIndex+1)
+	}
+	return entries, indexed, nil
+}
+
 func (b *QuaiAPIBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
 	nodeCtx := b.quai.core.NodeCtx()
 	if nodeCtx != common.ZONE_CTX {
@@ -416,6 +458,27 @@ func (b *QuaiAPIBackend) FeeHistory(ctx context.Context, blockCount int, lastBlo
 	return b.gpo.FeeHistory(ctx, blockCount, lastBlock, rewardPercentiles)
 }
 
+func (b *QuaiAPIBackend) SuggestQiTipRate(ctx context.Context) (*big.Int, error) {
+	nodeCtx := b.quai.core.NodeCtx()
+	if nodeCtx != common.ZONE_CTX || b.gpo == nil {
+		return nil, errors.New("suggestQiTipRate can only be called in zone chain")
+	}
+	return b.gpo.SuggestQiTipRate(ctx)
+}
+
+func (b *QuaiAPIBackend) QiFeeHistory(ctx context.Context, blockCount int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []uint64, []*big.Int, error) {
+	nodeCtx := b.quai.core.NodeCtx()
+	if nodeCtx != common.ZONE_CTX || b.gpo == nil {
+		return nil, nil, nil, nil, errors.New("qiFeeHistory can only be called in zone chain")
+	}
+	return b.gpo.QiFeeHistory(ctx, blockCount, lastBlock, rewardPercentiles)
+}
+
+// ChainContext returns the chain used to validate the transactions
+func (b *QuaiAPIBackend) ChainContext() core.ChainContext {
+	return b.quai.core
+}
+
 func (b *QuaiAPIBackend) ChainDb() ethdb.Database {
 	return b.quai.ChainDb()
 }
@@ -444,6 +507,10 @@ func (b *QuaiAPIBackend) RPCTxFeeCap() float64 {
 	return b.quai.config.RPCTxFeeCap
 }
 
+func (b *QuaiAPIBackend) SyncProgress() goquai.SyncProgress {
+	return b.quai.SyncProgress()
+}
+
 func (b *QuaiAPIBackend) BloomStatus() (uint64, uint64) {
 	sections, _, _ := b.quai.bloomIndexer.Sections()
 	return params.BloomBitsBlocks, sections
@@ -535,6 +602,22 @@ func (b *QuaiAPIBackend) GetPendingHeader() (*types.WorkObject, error) {
 	return b.quai.core.GetPendingHeader()
 }
 
+func (b *QuaiAPIBackend) GetTerminiByHash(hash common.Hash) *types.Termini {
+	return b.quai.core.GetTerminiByHash(hash)
+}
+
+// TrieNode returns the trie node or the contract code with the given hash, both
+// being addressed by the hash of their content
+func (b *QuaiAPIBackend) TrieNode(hash common.Hash) ([]byte, error) {
+	if b.NodeCtx() != common.ZONE_CTX || !b.ProcessingState() {
+		return nil, errors.New("state is not available in this context")
+	}
+	if node, err := b.quai.core.TrieNode(hash); err == nil && len(node) > 0 {
+		return node, nil
+	}
+	return b.quai.core.ContractCode(hash)
+}
+
 func (b *QuaiAPIBackend) GetManifest(blockHash common.Hash) (types.BlockManifest, error) {
 	return b.quai.core.GetManifest(blockHash)
 }
@@ -579,6 +662,42 @@ func (b *QuaiAPIBackend) SetSubClient(client *quaiclient.Client, location common
 	b.quai.core.SetSubClient(client, location)
 }
 
+// EtxLifecycle returns the canonical blocks of the slice the given etx crossed
+func (b *QuaiAPIBackend) EtxLifecycle(ctx context.Context, etxHash common.Hash) ([]rawdb.EtxLifecycleEntry, error) {
+	if !b.quai.config.IndexEtxs {
+		return nil, errors.New("etx indexing is disabled")
+	}
+	db := b.quai.ChainDb()
+	var entries []rawdb.EtxLifecycleEntry
+	for _, entry := range rawdb.ReadEtxLifecycleEntries(db, etxHash) {
+		// Skip the entries of blocks that were reorged out
+		if rawdb.ReadCanonicalHash(db, entry.BlockNumber) == entry.BlockHash {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (b *QuaiAPIBackend) SetSliceBackends(slices quaiapi.SliceBackends) {
+	b.slices = slices
+}
+
+// SliceBackend returns the backend of the given slice, or nil if the slice is
+// not running in this process
+func (b *QuaiAPIBackend) SliceBackend(location common.Location) quaiapi.Backend {
+	if location.Equal(b.NodeLocation()) {
+		return b
+	}
+	if b.slices == nil {
+		return nil
+	}
+	backend := b.slices.GetBackend(location)
+	if backend == nil {
+		return nil
+	}
+	return *backend
+}
+
 func (b *QuaiAPIBackend) AddGenesisPendingEtxs(block *types.WorkObject) {
 	b.quai.core.AddGenesisPendingEtxs(block)
 }
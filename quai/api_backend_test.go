@@ -0,0 +1,81 @@
+package quai
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core"
+	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+func TestAddressHistoryReorg(t *testing.T) {
+	db := rawdb.NewMemoryDatabase(log.Global)
+	config := *params.TestChainConfig
+	config.Location = testLocation
+
+	// The first section of the history was indexed
+	var count [8]byte
+	binary.BigEndian.PutUint64(count[:], 1)
+	table := rawdb.NewTable(db, string(rawdb.AddressHistoryIndexPrefix), db.Location(), db.Logger())
+	if err := table.Put([]byte("count"), count[:]); err != nil {
+		t.Fatalf("failed to write the indexed sections: %v", err)
+	}
+	indexer := core.NewAddressHistoryIndexer(db, &config, params.AddressHistoryBlocks, params.AddressHistoryConfirms, log.Global)
+	defer indexer.Close()
+	backend := &QuaiAPIBackend{quai: &Quai{chainDb: db, addressHistoryIndexer: indexer}}
+
+	// Block #5 was indexed on a side chain, then reorged out by a block holding
+	// another transaction of the address
+	address := common.HexToAddress("0x0010000000000000000000000000000000000001", testLocation)
+	var (
+		canonical = &rawdb.AddressHistoryEntry{BlockNumber: 3, BlockHash: common.Hash{0x03}, TxHash: common.Hash{0x13}}
+		dropped   = &rawdb.AddressHistoryEntry{BlockNumber: 5, BlockHash: common.Hash{0x05}, TxHash: common.Hash{0x15}}
+		reorged   = &rawdb.AddressHistoryEntry{BlockNumber: 5, BlockHash: common.Hash{0x25}, TxIndex: 1, TxHash: common.Hash{0x25}}
+		later     = &rawdb.AddressHistoryEntry{BlockNumber: 7, BlockHash: common.Hash{0x07}, TxHash: common.Hash{0x17}}
+		unindexed = &rawdb.AddressHistoryEntry{BlockNumber: params.AddressHistoryBlocks, BlockHash: common.Hash{0x08}, TxHash: common.Hash{0x18}}
+	)
+	for _, entry := range []*rawdb.AddressHistoryEntry{canonical, dropped, reorged, later, unindexed} {
+		rawdb.WriteAddressHistoryEntry(db, address, entry)
+	}
+	for _, entry := range []*rawdb.AddressHistoryEntry{canonical, reorged, later, unindexed} {
+		rawdb.WriteCanonicalHash(db, entry.BlockHash, entry.BlockNumber)
+	}
+
+	tests := []struct {
+		start []byte
+		end   uint64
+		limit int
+		want  []*rawdb.AddressHistoryEntry
+	}{
+		// The entry of the dropped block is skipped, and the entries past the
+		// indexed sections are not served
+		{nil, 100, 10, []*rawdb.AddressHistoryEntry{canonical, reorged, later}},
+		// The page is filled up past the skipped entry
+		{nil, 100, 2, []*rawdb.AddressHistoryEntry{canonical, reorged}},
+		{nil, 4, 10, []*rawdb.AddressHistoryEntry{canonical}},
+		{dropped.Position(), 100, 1, []*rawdb.AddressHistoryEntry{reorged}},
+		{rawdb.AddressHistoryPosition(6, 0), 100, 10, []*rawdb.AddressHistoryEntry{later}},
+	}
+	for i, test := range tests {
+		entries, indexed, err := backend.AddressHistory(context.Background(), address, test.start, test.end, test.limit)
+		if err != nil {
+			t.Fatalf("test %d: failed to read the address history: %v", i, err)
+		}
+		if indexed != params.AddressHistoryBlocks {
+			t.Errorf("test %d: indexed blocks mismatch: have %d, want %d", i, indexed, params.AddressHistoryBlocks)
+		}
+		if len(entries) != len(test.want) {
+			t.Errorf("test %d: entries mismatch: have %d, want %d", i, len(entries), len(test.want))
+			continue
+		}
+		for j, entry := range entries {
+			if *entry != *test.want[j] {
+				t.Errorf("test %d: entry %d mismatch: have %+v, want %+v", i, j, entry, test.want[j])
+			}
+		}
+	}
+}
@@ -28,9 +28,19 @@ import (
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/core/rawdb"
 	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/crypto"
+	"github.com/dominant-strategies/go-quai/log"
 )
 
+var testLocation = common.Location{0, 0}
+
+// newTestState returns an empty state on top of the given database
+func newTestState(db state.Database) *state.StateDB {
+	statedb, _ := state.New(types.EmptyRootHash, types.EmptyRootHash, types.EmptyRootHash, db, db, db, nil, testLocation, log.Global)
+	return statedb
+}
+
 var dumper = spew.ConfigState{Indent: "    "}
 
 func accountRangeTest(t *testing.T, trie *state.Trie, statedb *state.StateDB, start common.Hash, requestedNum int, expectedNum int) state.IteratorDump {
@@ -46,7 +56,7 @@ func accountRangeTest(t *testing.T, trie *state.Trie, statedb *state.StateDB, st
 		t.Fatalf("expected %d results, got %d", expectedNum, len(result.Accounts))
 	}
 	for address := range result.Accounts {
-		if address == (common.Address{}) {
+		if address == (common.InternalAddress{}) {
 			t.Fatalf("empty address returned")
 		}
 		if !statedb.Exist(address) {
@@ -66,15 +76,18 @@ func TestAccountRange(t *testing.T) {
 	t.Parallel()
 
 	var (
-		statedb  = state.NewDatabaseWithConfig(rawdb.NewMemoryDatabase(), nil)
-		state, _ = state.New(common.Hash{}, statedb, nil)
-		addrs    = [AccountRangeMaxResults * 2]common.Address{}
-		m        = map[common.AddressBytes]bool{}
+		statedb = state.NewDatabaseWithConfig(rawdb.NewMemoryDatabase(log.Global), nil)
+		state   = newTestState(statedb)
+		addrs   = [AccountRangeMaxResults * 2]common.InternalAddress{}
+		m       = map[common.InternalAddress]bool{}
 	)
 
 	for i := range addrs {
 		hash := common.HexToHash(fmt.Sprintf("%x", i))
-		addr := common.BytesToAddress(crypto.Keccak256Hash(hash.Bytes()).Bytes())
+		var addr common.InternalAddress
+		copy(addr[:], crypto.Keccak256Hash(hash.Bytes()).Bytes())
+		// Move the address into the Quai ledger of the test zone
+		addr[0], addr[1] = testLocation.BytePrefix(), addr[1]&0x7f
 		addrs[i] = addr
 		state.SetBalance(addrs[i], big.NewInt(1))
 		if _, ok := m[addr]; ok {
@@ -83,8 +96,10 @@ func TestAccountRange(t *testing.T) {
 			m[addr] = true
 		}
 	}
-	state.Commit(true)
-	root := state.IntermediateRoot(true)
+	root, err := state.Commit(true)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	trie, err := statedb.OpenTrie(root)
 	if err != nil {
@@ -99,7 +114,7 @@ func TestAccountRange(t *testing.T) {
 	for addr1 := range firstResult.Accounts {
 		// If address is empty, then it makes no sense to compare
 		// them as they might be two different accounts.
-		if addr1 == (common.Address{}) {
+		if addr1 == (common.InternalAddress{}) {
 			continue
 		}
 		if _, duplicate := secondResult.Accounts[addr1]; duplicate {
@@ -137,8 +152,7 @@ func TestEmptyAccountRange(t *testing.T) {
 	t.Parallel()
 
 	var (
-		statedb = state.NewDatabase(rawdb.NewMemoryDatabase())
-		st, _   = state.New(common.Hash{}, statedb, nil)
+		st = newTestState(state.NewDatabase(rawdb.NewMemoryDatabase(log.Global)))
 	)
 	st.Commit(true)
 	st.IntermediateRoot(true)
@@ -159,11 +173,11 @@ func TestEmptyAccountRange(t *testing.T) {
 func TestStorageRangeAt(t *testing.T) {
 	t.Parallel()
 
-	// Create a state where account 0x010000... has a few storage entries.
+	// Create a state where account 0x000100... has a few storage entries.
 	var (
-		state, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
-		addr     = common.Address{0x01}
-		keys     = []common.Hash{ // hashes of Keys of storage
+		state = newTestState(state.NewDatabase(rawdb.NewMemoryDatabase(log.Global)))
+		addr  = common.InternalAddress{testLocation.BytePrefix(), 0x01}
+		keys  = []common.Hash{ // hashes of Keys of storage
 			common.HexToHash("340dd630ad21bf010b4e676dbfa9ba9a02175262d1fa356232cfde6cb5b47ef2"),
 			common.HexToHash("426fcb404ab2d5d8e61a3d918108006bbb0a9be65e92235bb10eefbdb6dcd053"),
 			common.HexToHash("48078cfed56339ea54962e72c37c7f588fc4f8e5bc173827ba75cb10a63a96a5"),
@@ -24,6 +24,7 @@ import (
 	"sync"
 	"time"
 
+	goquai "github.com/dominant-strategies/go-quai"
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/consensus"
 	"github.com/dominant-strategies/go-quai/core"
@@ -41,6 +42,7 @@ import (
 	"github.com/dominant-strategies/go-quai/quai/filters"
 	"github.com/dominant-strategies/go-quai/quai/gasprice"
 	"github.com/dominant-strategies/go-quai/quai/quaiconfig"
+	"github.com/dominant-strategies/go-quai/quai/tracers"
 	"github.com/dominant-strategies/go-quai/rpc"
 )
 
@@ -69,6 +71,10 @@ type Quai struct {
 	bloomIndexer      *core.ChainIndexer             // Bloom indexer operating during block imports
 	closeBloomHandler chan struct{}
 
+	addressHistoryIndexer *core.ChainIndexer // Address history indexer, nil unless enabled
+
+	stratum *core.StratumServer // Stratum server for external miners, nil unless enabled
+
 	APIBackend *QuaiAPIBackend
 
 	gasPrice  *big.Int
@@ -105,7 +111,7 @@ func New(stack *node.Node, p2p NetworkingAPI, config *quaiconfig.Config, nodeCtx
 	}).Info("Allocated trie memory caches")
 
 	// Assemble the Quai object
-	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false, config.NodeLocation)
+	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, config.DatabaseFreezerThreshold, "eth/db/chaindata/", false, config.NodeLocation)
 	if err != nil {
 		return nil, err
 	}
@@ -144,6 +150,15 @@ func New(stack *node.Node, p2p NetworkingAPI, config *quaiconfig.Config, nodeCtx
 				}
 				rawdb.WriteTermini(chainDb, genesisBlock.Hash(), genesisTermini)
 			}
+			genesisHash := common.Hash{}
+			if genesisBlock != nil {
+				genesisHash = genesisBlock.Hash()
+			} else if genesisHashes := rawdb.ReadGenesisHashes(chainDb); len(genesisHashes) > 0 {
+				genesisHash = genesisHashes[0]
+			}
+			if err := core.SetupChainConfig(chainDb, genesisHash, chainConfig, logger); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -241,6 +256,7 @@ func New(stack *node.Node, p2p NetworkingAPI, config *quaiconfig.Config, nodeCtx
 	var (
 		indexerConfig = &core.IndexerConfig{
 			IndexAddressUtxos: config.IndexAddressUtxos,
+			IndexEtxs:         config.IndexEtxs,
 		}
 	)
 
@@ -249,7 +265,7 @@ func New(stack *node.Node, p2p NetworkingAPI, config *quaiconfig.Config, nodeCtx
 	}
 
 	logger.WithField("url", quai.config.DomUrl).Info("Dom client")
-	quai.core, err = core.NewCore(chainDb, &config.Miner, quai.isLocalBlock, &config.TxPool, &config.TxLookupLimit, chainConfig, quai.config.SlicesRunning, currentExpansionNumber, genesisBlock, quai.config.DomUrl, quai.config.SubUrls, quai.engine, cacheConfig, vmConfig, indexerConfig, config.Genesis, logger)
+	quai.core, err = core.NewCore(chainDb, &config.Miner, quai.isLocalBlock, &config.TxPool, &config.TxLookupLimit, chainConfig, quai.config.SlicesRunning, currentExpansionNumber, genesisBlock, quai.config.DomUrl, quai.config.SubUrls, stack.RPCAuth(), quai.engine, cacheConfig, vmConfig, indexerConfig, config.Genesis, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -258,16 +274,21 @@ func New(stack *node.Node, p2p NetworkingAPI, config *quaiconfig.Config, nodeCtx
 	if quai.core.ProcessingState() && nodeCtx == common.ZONE_CTX {
 		quai.bloomIndexer = core.NewBloomIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms, chainConfig.Location.Context(), logger)
 		quai.bloomIndexer.Start(quai.Core().Slice().HeaderChain())
+
+		if config.IndexAddressHistory {
+			quai.addressHistoryIndexer = core.NewAddressHistoryIndexer(chainDb, chainConfig, params.AddressHistoryBlocks, params.AddressHistoryConfirms, logger)
+			quai.addressHistoryIndexer.Start(quai.Core().Slice().HeaderChain())
+		}
 	}
 
 	// Set the p2p Networking API
 	quai.p2p = p2p
 
-	quai.handler = newHandler(quai.p2p, quai.core, config.NodeLocation, logger)
+	quai.handler = newHandler(quai.p2p, quai.core, config.NodeLocation, config.StateSync, logger)
 	// Start the handler
 	quai.handler.Start()
 
-	quai.APIBackend = &QuaiAPIBackend{stack.Config().ExtRPCEnabled(), quai, nil}
+	quai.APIBackend = &QuaiAPIBackend{stack.Config().ExtRPCEnabled(), quai, nil, nil}
 	// Gasprice oracle is only initiated in zone chains
 	if nodeCtx == common.ZONE_CTX && quai.core.ProcessingState() {
 		gpoParams := config.GPO
@@ -275,6 +296,10 @@ func New(stack *node.Node, p2p NetworkingAPI, config *quaiconfig.Config, nodeCtx
 			gpoParams.Default = config.Miner.GasPrice
 		}
 		quai.APIBackend.gpo = gasprice.NewOracle(quai.APIBackend, gpoParams, logger)
+
+		if config.Miner.StratumAddr != "" {
+			quai.stratum = core.NewStratumServer(config.Miner.StratumAddr, quai.core, quai.APIBackend, logger)
+		}
 	}
 
 	// Register the backend on the node
@@ -289,6 +314,9 @@ func New(stack *node.Node, p2p NetworkingAPI, config *quaiconfig.Config, nodeCtx
 func (s *Quai) APIs() []rpc.API {
 	apis := quaiapi.GetAPIs(s.APIBackend)
 
+	// Append any APIs exposed explicitly by the tracers
+	apis = append(apis, tracers.APIs(s.APIBackend)...)
+
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -396,6 +424,9 @@ func (s *Quai) IsListening() bool                { return true } // Always liste
 func (s *Quai) ArchiveMode() bool                { return s.config.NoPruning }
 func (s *Quai) BloomIndexer() *core.ChainIndexer { return s.bloomIndexer }
 
+// SyncProgress returns the progress of the headers-first downloader
+func (s *Quai) SyncProgress() goquai.SyncProgress { return s.handler.SyncProgress() }
+
 // Start implements node.Lifecycle, starting all internal goroutines needed by the
 // Quai protocol implementation.
 func (s *Quai) Start() error {
@@ -404,6 +435,11 @@ func (s *Quai) Start() error {
 		// Start the bloom bits servicing goroutines
 		s.startBloomHandlers(params.BloomBitsBlocks)
 	}
+	if s.stratum != nil {
+		if err := s.stratum.Start(); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -411,11 +447,17 @@ func (s *Quai) Start() error {
 // Stop implements node.Lifecycle, terminating all internal goroutines used by the
 // Quai protocol.
 func (s *Quai) Stop() error {
+	if s.stratum != nil {
+		s.stratum.Stop()
+	}
 
 	if s.core.ProcessingState() && s.core.NodeCtx() == common.ZONE_CTX {
 		// Then stop everything else.
 		s.bloomIndexer.Close()
 		close(s.closeBloomHandler)
+		if s.addressHistoryIndexer != nil {
+			s.addressHistoryIndexer.Close()
+		}
 	}
 	s.core.Stop()
 	s.chainDb.Close()
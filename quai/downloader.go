@@ -0,0 +1,407 @@
+package quai
+
+import (
+	"math/big"
+	"runtime/debug"
+	"sync"
+
+	goquai "github.com/dominant-strategies/go-quai"
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/consensus"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/log"
+)
+
+const (
+	// c_headerRangeSize is the number of headers requested to fill the gap
+	// between two skeleton headers
+	c_headerRangeSize = 128
+	// c_zoneSkeletonSize is the number of skeleton headers requested in a zone
+	// sync round, so that a round stays within the zone append queue window
+	c_zoneSkeletonSize = 12
+	// c_regionSkeletonSize is the number of skeleton headers requested in a
+	// region sync round, so that a round stays within the region append queue window
+	c_regionSkeletonSize = 1
+	// c_bodyFetchers is the number of bodies requested from the peers in parallel
+	c_bodyFetchers = 16
+)
+
+// downloaderCore is the part of the core the downloader reads the local chain
+// from and writes the downloaded blocks to
+type downloaderCore interface {
+	CurrentHeader() *types.WorkObject
+	GetHeaderOrCandidateByHash(hash common.Hash) *types.WorkObject
+	GetBlockOrCandidateByHash(hash common.Hash) *types.WorkObject
+	GetTerminiByHash(hash common.Hash) *types.Termini
+	GetDomTerminiByHash(hash common.Hash) (*types.Termini, error)
+	IsGenesisHash(hash common.Hash) bool
+	CalcOrder(header *types.WorkObject) (*big.Int, int, error)
+	Engine() consensus.Engine
+	ProcessingState() bool
+	WriteBlock(block *types.WorkObject)
+}
+
+// downloader fetches the chain from the peers headers first. Every sync round
+// asks for a skeleton of headers spread over the range ahead of the local head,
+// fills the gaps of the skeleton from several peers in parallel, checks the
+// coincident headers against the termini of the dom chain and then downloads
+// the bodies of the verified headers in parallel before writing them to the core
+type downloader struct {
+	nodeLocation common.Location
+	p2pBackend   NetworkingAPI
+	core         downloaderCore
+	quitCh       chan struct{}
+	logger       *log.Logger
+
+	lastWritten    *types.WorkObject // last block handed to the core
+	lastCoincident common.Hash       // last written block which is coincident with the dom
+	lastHead       common.Hash       // local head at the start of the previous round
+
+	progressLock  sync.RWMutex
+	startingBlock uint64 // head number when the current sync started
+	highestBlock  uint64 // highest header number announced by the peers
+}
+
+func newDownloader(p2pBackend NetworkingAPI, core downloaderCore, nodeLocation common.Location, quitCh chan struct{}, logger *log.Logger) *downloader {
+	return &downloader{
+		nodeLocation: nodeLocation,
+		p2pBackend:   p2pBackend,
+		core:         core,
+		quitCh:       quitCh,
+		logger:       logger,
+	}
+}
+
+// Progress returns the current state of the synchronisation
+func (d *downloader) Progress() goquai.SyncProgress {
+	d.progressLock.RLock()
+	defer d.progressLock.RUnlock()
+
+	current := d.core.CurrentHeader().NumberU64(d.nodeLocation.Context())
+	highest := d.highestBlock
+	if highest < current {
+		highest = current
+	}
+	return goquai.SyncProgress{
+		StartingBlock: d.startingBlock,
+		CurrentBlock:  current,
+		HighestBlock:  highest,
+	}
+}
+
+// synchronise runs a single sync round from the local head, or from the last
+// written block if the core has not appended it yet. The round restarts from
+// the local head if the last written block does not extend it anymore, or if
+// the core has not appended any block since the previous round.
+func (d *downloader) synchronise() {
+	defer func() {
+		if r := recover(); r != nil {
+			d.logger.WithFields(log.Fields{
+				"error":      r,
+				"stacktrace": string(debug.Stack()),
+			}).Fatal("Go-Quai Panicked")
+		}
+	}()
+	nodeCtx := d.nodeLocation.Context()
+	head := d.core.CurrentHeader()
+	headNumber := head.NumberU64(nodeCtx)
+
+	if d.lastWritten != nil && d.lastWritten.NumberU64(nodeCtx) > headNumber {
+		if head.Hash() == d.lastHead {
+			d.logger.WithField("head", headNumber).Debug("Head has not advanced since the last round, syncing from the head")
+			d.lastWritten = nil
+		} else if !d.extendsHead(head) {
+			d.logger.WithField("head", headNumber).Debug("Last written block is not canonical anymore, syncing from the head")
+			d.lastWritten = nil
+		}
+	}
+	d.lastHead = head.Hash()
+
+	origin := head
+	if d.lastWritten != nil && d.lastWritten.NumberU64(nodeCtx) > headNumber {
+		if d.lastWritten.NumberU64(nodeCtx)-headNumber >= d.batchSize() {
+			// The core has not caught up with the previous round yet
+			return
+		}
+		origin = d.lastWritten
+	} else {
+		d.lastCoincident = common.Hash{}
+		if termini := d.core.GetTerminiByHash(head.Hash()); termini != nil {
+			d.lastCoincident = termini.DomTerminus(d.nodeLocation)
+		}
+	}
+
+	d.progressLock.Lock()
+	if d.highestBlock <= headNumber {
+		d.startingBlock = headNumber
+	}
+	d.progressLock.Unlock()
+
+	headers := d.fetchHeaders(origin)
+	if len(headers) == 0 || d.stopped() {
+		return
+	}
+	headers, lastCoincident := d.verifyHeaders(headers)
+	if len(headers) == 0 || d.stopped() {
+		return
+	}
+	blocks := d.fetchBodies(headers)
+	for i, block := range blocks {
+		d.core.WriteBlock(block)
+		d.lastWritten = block
+		if i == lastCoincident {
+			d.lastCoincident = block.Hash()
+		}
+	}
+	d.logger.WithFields(log.Fields{
+		"from":   origin.NumberU64(nodeCtx) + 1,
+		"blocks": len(blocks),
+	}).Info("Downloaded blocks from peers")
+}
+
+// extendsHead returns whether the last written block descends from the head
+func (d *downloader) extendsHead(head *types.WorkObject) bool {
+	nodeCtx := d.nodeLocation.Context()
+	block := d.lastWritten
+	for block.NumberU64(nodeCtx) > head.NumberU64(nodeCtx) {
+		block = d.core.GetHeaderOrCandidateByHash(block.ParentHash(nodeCtx))
+		if block == nil {
+			return false
+		}
+	}
+	return block.Hash() == head.Hash()
+}
+
+// fetchHeaders returns the contiguous headers following the origin which could be
+// downloaded in this round
+func (d *downloader) fetchHeaders(origin *types.WorkObject) []*types.WorkObject {
+	nodeCtx := d.nodeLocation.Context()
+	start := new(big.Int).SetUint64(origin.NumberU64(nodeCtx) + 1)
+
+	// The skeleton headers are the last headers of every range
+	skeleton := d.requestHeaders(types.HeaderRangeRequest{
+		Start: new(big.Int).Add(start, big.NewInt(c_headerRangeSize-1)),
+		Count: d.skeletonSize(),
+		Skip:  c_headerRangeSize - 1,
+	})
+	if len(skeleton) == 0 {
+		// The peers are less than a range ahead, only fetch the tail of the chain
+		tail := d.requestHeaders(types.HeaderRangeRequest{Start: start, Count: c_headerRangeSize})
+		if len(tail) == 0 || tail[0].ParentHash(nodeCtx) != origin.Hash() {
+			return nil
+		}
+		d.updateHighestBlock(tail[len(tail)-1].NumberU64(nodeCtx))
+		return tail
+	}
+	d.updateHighestBlock(skeleton[len(skeleton)-1].NumberU64(nodeCtx))
+
+	ranges := make([][]*types.WorkObject, len(skeleton))
+	var wg sync.WaitGroup
+	for i := range skeleton {
+		parent := origin.Hash()
+		if i > 0 {
+			parent = skeleton[i-1].Hash()
+		}
+		wg.Add(1)
+		go func(i int, parent common.Hash) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					d.logger.WithFields(log.Fields{
+						"error":      r,
+						"stacktrace": string(debug.Stack()),
+					}).Fatal("Go-Quai Panicked")
+				}
+			}()
+			ranges[i] = d.fillRange(new(big.Int).Add(start, big.NewInt(int64(i*c_headerRangeSize))), parent, skeleton[i].Hash())
+		}(i, parent)
+	}
+	wg.Wait()
+
+	headers := make([]*types.WorkObject, 0, len(skeleton)*c_headerRangeSize)
+	for _, headerRange := range ranges {
+		if headerRange == nil {
+			break
+		}
+		headers = append(headers, headerRange...)
+	}
+	return headers
+}
+
+// fillRange requests the headers between two skeleton headers and returns the
+// first answer which links the parent to the skeleton header
+func (d *downloader) fillRange(start *big.Int, parent common.Hash, last common.Hash) []*types.WorkObject {
+	nodeCtx := d.nodeLocation.Context()
+	resultCh := d.p2pBackend.Request(d.nodeLocation, types.HeaderRangeRequest{Start: start, Count: c_headerRangeSize}, &types.WorkObjectHeaderViews{})
+	for result := range resultCh {
+		headerViews, ok := result.(types.WorkObjectHeaderViews)
+		if !ok || len(headerViews) != c_headerRangeSize {
+			continue
+		}
+		headers := make([]*types.WorkObject, len(headerViews))
+		for i, headerView := range headerViews {
+			headers[i] = headerView.WorkObject
+		}
+		if headers[0].ParentHash(nodeCtx) == parent && headers[len(headers)-1].Hash() == last {
+			return headers
+		}
+	}
+	return nil
+}
+
+// requestHeaders requests a header range from the peers and returns the longest answer
+func (d *downloader) requestHeaders(headerRange types.HeaderRangeRequest) []*types.WorkObject {
+	var longest types.WorkObjectHeaderViews
+	resultCh := d.p2pBackend.Request(d.nodeLocation, headerRange, &types.WorkObjectHeaderViews{})
+	for result := range resultCh {
+		if headerViews, ok := result.(types.WorkObjectHeaderViews); ok && len(headerViews) > len(longest) {
+			longest = headerViews
+		}
+	}
+	headers := make([]*types.WorkObject, len(longest))
+	for i, headerView := range longest {
+		headers[i] = headerView.WorkObject
+	}
+	return headers
+}
+
+// verifyHeaders checks that the headers link to each other, the seal of the
+// headers, and that every header coincident with the dom follows the previous
+// coincident header in the dom termini. It returns the headers that can be
+// imported and the index of the last coincident one among them, or -1 if there
+// is none
+func (d *downloader) verifyHeaders(headers []*types.WorkObject) ([]*types.WorkObject, int) {
+	nodeCtx := d.nodeLocation.Context()
+	lastCoincident := d.lastCoincident
+	lastCoincidentIndex := -1
+	for i, header := range headers {
+		if i > 0 && header.ParentHash(nodeCtx) != headers[i-1].Hash() {
+			d.logger.WithFields(log.Fields{
+				"hash":   header.Hash(),
+				"number": header.NumberU64(nodeCtx),
+				"parent": header.ParentHash(nodeCtx),
+			}).Warn("Downloaded header does not link to the previous header")
+			return headers[:i], lastCoincidentIndex
+		}
+		if _, err := d.core.Engine().VerifySeal(header.WorkObjectHeader()); err != nil {
+			d.logger.WithFields(log.Fields{
+				"hash":   header.Hash(),
+				"number": header.NumberU64(nodeCtx),
+				"err":    err,
+			}).Warn("Downloaded header has an invalid seal")
+			return headers[:i], lastCoincidentIndex
+		}
+		_, order, err := d.core.CalcOrder(header)
+		if err != nil {
+			return headers[:i], lastCoincidentIndex
+		}
+		if order >= nodeCtx {
+			continue
+		}
+		termini, err := d.core.GetDomTerminiByHash(header.ParentHash(nodeCtx - 1))
+		if err != nil || termini == nil {
+			// The dom has not appended the previous dom block yet, write this
+			// header so that the dom is asked for it and stop there
+			return headers[:i+1], i
+		}
+		if lastCoincident != (common.Hash{}) && !d.core.IsGenesisHash(lastCoincident) && termini.SubTerminiAtIndex(d.subIndex()) != lastCoincident {
+			d.logger.WithFields(log.Fields{
+				"hash":     header.Hash(),
+				"number":   header.NumberU64(nodeCtx),
+				"expected": lastCoincident,
+				"termini":  termini.SubTerminiAtIndex(d.subIndex()),
+			}).Warn("Downloaded header does not match the dom termini")
+			return headers[:i], lastCoincidentIndex
+		}
+		lastCoincident = header.Hash()
+		lastCoincidentIndex = i
+	}
+	return headers, lastCoincidentIndex
+}
+
+// fetchBodies downloads the bodies of the headers in parallel and returns the
+// contiguous blocks that could be assembled
+func (d *downloader) fetchBodies(headers []*types.WorkObject) []*types.WorkObject {
+	if d.nodeLocation.Context() == common.ZONE_CTX && !d.core.ProcessingState() {
+		// Non processing zones only keep the headers
+		return headers
+	}
+	blocks := make([]*types.WorkObject, len(headers))
+	fetchers := make(chan struct{}, c_bodyFetchers)
+	var wg sync.WaitGroup
+	for i, header := range headers {
+		if block := d.core.GetBlockOrCandidateByHash(header.Hash()); block != nil {
+			blocks[i] = block
+			continue
+		}
+		if d.stopped() {
+			break
+		}
+		fetchers <- struct{}{}
+		wg.Add(1)
+		go func(i int, hash common.Hash) {
+			defer wg.Done()
+			defer func() { <-fetchers }()
+			defer func() {
+				if r := recover(); r != nil {
+					d.logger.WithFields(log.Fields{
+						"error":      r,
+						"stacktrace": string(debug.Stack()),
+					}).Fatal("Go-Quai Panicked")
+				}
+			}()
+			resultCh := d.p2pBackend.Request(d.nodeLocation, hash, &types.WorkObjectBlockView{})
+			for result := range resultCh {
+				if block, ok := result.(*types.WorkObjectBlockView); ok && block != nil {
+					blocks[i] = block.WorkObject
+					return
+				}
+			}
+		}(i, header.Hash())
+	}
+	wg.Wait()
+
+	for i, block := range blocks {
+		if block == nil {
+			return blocks[:i]
+		}
+	}
+	return blocks
+}
+
+func (d *downloader) updateHighestBlock(number uint64) {
+	d.progressLock.Lock()
+	defer d.progressLock.Unlock()
+	if number > d.highestBlock {
+		d.highestBlock = number
+	}
+}
+
+// batchSize is the largest number of blocks written in a single round
+func (d *downloader) batchSize() uint64 {
+	return d.skeletonSize() * c_headerRangeSize
+}
+
+func (d *downloader) skeletonSize() uint64 {
+	if d.nodeLocation.Context() == common.REGION_CTX {
+		return c_regionSkeletonSize
+	}
+	return c_zoneSkeletonSize
+}
+
+// subIndex is the index of this chain among the subordinate chains of its dom
+func (d *downloader) subIndex() int {
+	if d.nodeLocation.Context() == common.ZONE_CTX {
+		return d.nodeLocation.Zone()
+	}
+	return d.nodeLocation.Region()
+}
+
+func (d *downloader) stopped() bool {
+	select {
+	case <-d.quitCh:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,147 @@
+package quai
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/consensus"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/log"
+)
+
+var errTestSeal = errors.New("invalid seal")
+
+// testDownloaderCore serves the head and verifies the headers against a set of
+// badly sealed and dom coincident headers, the other methods of downloaderCore
+// panic
+type testDownloaderCore struct {
+	downloaderCore
+	head       *types.WorkObject
+	badSeals   map[common.Hash]bool
+	coincident map[common.Hash]bool
+	domTermini map[common.Hash]*types.Termini
+}
+
+func (c *testDownloaderCore) CurrentHeader() *types.WorkObject { return c.head }
+func (c *testDownloaderCore) Engine() consensus.Engine         { return &testSealEngine{badSeals: c.badSeals} }
+func (c *testDownloaderCore) IsGenesisHash(common.Hash) bool   { return false }
+func (c *testDownloaderCore) CalcOrder(header *types.WorkObject) (*big.Int, int, error) {
+	if c.coincident[header.Hash()] {
+		return big.NewInt(0), common.REGION_CTX, nil
+	}
+	return big.NewInt(0), common.ZONE_CTX, nil
+}
+func (c *testDownloaderCore) GetDomTerminiByHash(hash common.Hash) (*types.Termini, error) {
+	return c.domTermini[hash], nil
+}
+
+// testSealEngine rejects the seals of the given headers
+type testSealEngine struct {
+	consensus.Engine
+	badSeals map[common.Hash]bool
+}
+
+func (e *testSealEngine) VerifySeal(header *types.WorkObjectHeader) (common.Hash, error) {
+	if e.badSeals[header.Hash()] {
+		return common.Hash{}, errTestSeal
+	}
+	return common.Hash{}, nil
+}
+
+// makeTestHeaders returns a chain of zone headers following the parent, every
+// header has its own region parent
+func makeTestHeaders(parent common.Hash, first int64, count int) []*types.WorkObject {
+	headers := make([]*types.WorkObject, count)
+	for i := range headers {
+		header := types.EmptyHeader(common.ZONE_CTX)
+		header.Header().SetParentHash(common.BigToHash(big.NewInt(first+int64(i))), common.REGION_CTX)
+		header.WorkObjectHeader().SetLocation(common.Location{0, 0})
+		header.WorkObjectHeader().SetNumber(big.NewInt(first + int64(i)))
+		header.WorkObjectHeader().SetParentHash(parent)
+		header.WorkObjectHeader().SetHeaderHash(header.Header().Hash())
+		headers[i] = header
+		parent = header.Hash()
+	}
+	return headers
+}
+
+func newTestDownloader(core *testDownloaderCore) *downloader {
+	return newDownloader(nil, core, common.Location{0, 0}, make(chan struct{}), log.Global)
+}
+
+func TestVerifyHeaders(t *testing.T) {
+	headers := makeTestHeaders(common.Hash{0x01}, 1, 8)
+	d := newTestDownloader(&testDownloaderCore{})
+
+	verified, lastCoincident := d.verifyHeaders(headers)
+	if len(verified) != len(headers) || lastCoincident != -1 {
+		t.Errorf("verified headers mismatch: have %d and %d, want %d and -1", len(verified), lastCoincident, len(headers))
+	}
+}
+
+func TestVerifyHeadersNotLinking(t *testing.T) {
+	// The second range doesn't link to the last header of the first one
+	headers := makeTestHeaders(common.Hash{0x01}, 1, 4)
+	headers = append(headers, makeTestHeaders(common.Hash{0x02}, 5, 4)...)
+	d := newTestDownloader(&testDownloaderCore{})
+
+	verified, _ := d.verifyHeaders(headers)
+	if len(verified) != 4 {
+		t.Errorf("verified headers mismatch: have %d, want 4", len(verified))
+	}
+}
+
+func TestVerifyHeadersBadSeal(t *testing.T) {
+	headers := makeTestHeaders(common.Hash{0x01}, 1, 8)
+	d := newTestDownloader(&testDownloaderCore{
+		badSeals: map[common.Hash]bool{headers[5].Hash(): true},
+	})
+	verified, _ := d.verifyHeaders(headers)
+	if len(verified) != 5 {
+		t.Errorf("verified headers mismatch: have %d, want 5", len(verified))
+	}
+	// A badly sealed first header rejects the whole batch
+	d.core.(*testDownloaderCore).badSeals[headers[0].Hash()] = true
+	if verified, _ := d.verifyHeaders(headers); len(verified) != 0 {
+		t.Errorf("verified headers mismatch: have %d, want 0", len(verified))
+	}
+}
+
+func TestVerifyHeadersDomTermini(t *testing.T) {
+	headers := makeTestHeaders(common.Hash{0x01}, 1, 8)
+	termini := func(sub common.Hash) *types.Termini {
+		termini := types.EmptyTermini()
+		termini.SetSubTerminiAtIndex(sub, 0)
+		return &termini
+	}
+	core := &testDownloaderCore{
+		coincident: map[common.Hash]bool{headers[2].Hash(): true, headers[5].Hash(): true},
+		domTermini: map[common.Hash]*types.Termini{
+			headers[2].ParentHash(common.REGION_CTX): termini(common.Hash{0x03}),
+			headers[5].ParentHash(common.REGION_CTX): termini(headers[2].Hash()),
+		},
+	}
+	d := newTestDownloader(core)
+	d.lastCoincident = common.Hash{0x03}
+
+	// Both coincident headers follow the previous one in the dom termini
+	verified, lastCoincident := d.verifyHeaders(headers)
+	if len(verified) != 8 || lastCoincident != 5 {
+		t.Errorf("verified headers mismatch: have %d and %d, want 8 and 5", len(verified), lastCoincident)
+	}
+	// A coincident header skipping the last coincident header is rejected
+	core.domTermini[headers[5].ParentHash(common.REGION_CTX)] = termini(common.Hash{0x04})
+	verified, lastCoincident = d.verifyHeaders(headers)
+	if len(verified) != 5 || lastCoincident != 2 {
+		t.Errorf("verified headers mismatch: have %d and %d, want 5 and 2", len(verified), lastCoincident)
+	}
+	// The headers after a coincident header unknown to the dom are kept for
+	// the next round
+	delete(core.domTermini, headers[5].ParentHash(common.REGION_CTX))
+	verified, lastCoincident = d.verifyHeaders(headers)
+	if len(verified) != 6 || lastCoincident != 5 {
+		t.Errorf("verified headers mismatch: have %d and %d, want 6 and 5", len(verified), lastCoincident)
+	}
+}
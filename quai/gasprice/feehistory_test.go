@@ -19,9 +19,9 @@ package gasprice
 import (
 	"context"
 	"errors"
-	"math/big"
 	"testing"
 
+	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/rpc"
 )
 
@@ -56,8 +56,8 @@ func TestFeeHistory(t *testing.T) {
 			MaxHeaderHistory: c.maxHeader,
 			MaxBlockHistory:  c.maxBlock,
 		}
-		backend := newTestBackend(t, big.NewInt(16), c.pending)
-		oracle := NewOracle(backend, config)
+		backend := newTestBackend(t, c.pending)
+		oracle := NewOracle(backend, config, log.Global)
 
 		first, reward, baseFee, ratio, err := oracle.FeeHistory(context.Background(), c.count, c.last, c.percent)
 
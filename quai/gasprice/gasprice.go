@@ -23,10 +23,13 @@ import (
 	"sync"
 
 	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core"
+	"github.com/dominant-strategies/go-quai/core/state"
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/params"
 	"github.com/dominant-strategies/go-quai/rpc"
+	lru "github.com/hashicorp/golang-lru/v2"
 )
 
 const sampleNumber = 3 // Number of transactions sampled in a block
@@ -52,6 +55,8 @@ type OracleBackend interface {
 	BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.WorkObject, error)
 	GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error)
 	PendingBlockAndReceipts() (*types.WorkObject, types.Receipts)
+	StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.WorkObject, error)
+	ChainContext() core.ChainContext
 	ChainConfig() *params.ChainConfig
 }
 
@@ -66,6 +71,10 @@ type Oracle struct {
 	cacheLock   sync.RWMutex
 	fetchLock   sync.Mutex
 
+	lastQiHead    common.Hash
+	lastQiTipRate *big.Int
+	qiTipCache    *lru.Cache[common.Hash, []qiTip]
+
 	checkBlocks, percentile           int
 	maxHeaderHistory, maxBlockHistory int
 
@@ -116,6 +125,7 @@ func NewOracle(backend OracleBackend, params Config, logger *log.Logger) *Oracle
 	} else if ignorePrice.Int64() > 0 {
 		logger.WithField("threshold", ignorePrice).Info("Gasprice oracle is ignoring threshold set")
 	}
+	qiTipCache, _ := lru.New[common.Hash, []qiTip](qiTipCacheSize)
 	return &Oracle{
 		backend:          backend,
 		lastPrice:        params.Default,
@@ -125,6 +135,7 @@ func NewOracle(backend OracleBackend, params Config, logger *log.Logger) *Oracle
 		percentile:       percent,
 		maxHeaderHistory: params.MaxHeaderHistory,
 		maxBlockHistory:  params.MaxBlockHistory,
+		qiTipCache:       qiTipCache,
 		logger:           logger,
 	}
 }
@@ -0,0 +1,326 @@
+package gasprice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"runtime/debug"
+	"sort"
+	"sync/atomic"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/rpc"
+)
+
+const (
+	// QiTipRateGas is the amount of gas the Qi tip rates are expressed for. The
+	// tip paid by a Qi transaction per unit of gas is usually a fraction of a
+	// qit, so the rates are given in qits per QiTipRateGas units of gas.
+	QiTipRateGas = 1000
+
+	// qiTipCacheSize is the number of blocks whose Qi tips are cached, as
+	// computing them requires the state of the parent block.
+	qiTipCacheSize = 2048
+)
+
+// QiFeeTarget maps a confirmation target, in blocks, to the percentile of the
+// recent Qi tip rates a transaction has to pay to be included within it.
+type QiFeeTarget struct {
+	Blocks     uint64
+	Percentile float64
+}
+
+// QiFeeTargets are the confirmation targets Qi fees are suggested for.
+var QiFeeTargets = []QiFeeTarget{
+	{Blocks: 1, Percentile: 90},
+	{Blocks: 3, Percentile: 60},
+	{Blocks: 10, Percentile: 25},
+}
+
+// qiTip is the tip a Qi transaction paid to the miner, as computed by
+// ProcessQiTx, together with the gas it used.
+type qiTip struct {
+	gas  uint64
+	rate *big.Int // tip in qits per QiTipRateGas units of gas
+}
+
+// sortQiTips is sorted in ascending order based on the tip rate
+type sortQiTips []qiTip
+
+func (s sortQiTips) Len() int           { return len(s) }
+func (s sortQiTips) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortQiTips) Less(i, j int) bool { return s[i].rate.Cmp(s[j].rate) < 0 }
+
+// qiTips returns the tips paid by the Qi transactions of the given block. The
+// transactions are applied in order on top of the state of the parent block,
+// so that the inputs created earlier in the block are resolved, and the tips of
+// a block whose parent state is not available anymore cannot be computed.
+// Conversions to Quai are skipped as their whole fee goes to gas.
+func (oracle *Oracle) qiTips(ctx context.Context, block *types.WorkObject) ([]qiTip, error) {
+	if tips, ok := oracle.qiTipCache.Get(block.Hash()); ok {
+		return tips, nil
+	}
+	var (
+		config    = oracle.backend.ChainConfig()
+		location  = config.Location
+		nodeCtx   = location.Context()
+		chain     = oracle.backend.ChainContext()
+		signer    = types.MakeSigner(config, block.Number(nodeCtx))
+		gasTable  = config.GasTable(block.Number(nodeCtx), block.ExpansionNumber())
+		gp        = new(types.GasPool).AddGas(math.MaxUint64)
+		etxRLimit = math.MaxInt
+		etxPLimit = math.MaxInt
+		statedb   *state.StateDB
+		tips      = make([]qiTip, 0)
+	)
+	for _, tx := range block.Transactions() {
+		switch {
+		case tx.Type() == types.QiTxType:
+			if types.IsCoinBaseTx(tx, block.ParentHash(nodeCtx), location) {
+				continue
+			}
+		case tx.Type() == types.ExternalTxType && tx.To().IsInQiLedgerScope() && !tx.ETXSender().Location().Equal(*tx.To().Location()):
+			// The Qi sent from the other slices can be spent in the same block
+		default:
+			continue
+		}
+		if statedb == nil {
+			parentState, _, err := oracle.backend.StateAndHeaderByNumberOrHash(ctx, rpc.BlockNumberOrHashWithHash(block.ParentHash(nodeCtx), false))
+			if err != nil {
+				return nil, err
+			}
+			statedb = parentState
+		}
+		if tx.Type() == types.ExternalTxType {
+			utxo := types.NewUtxoEntry(types.NewTxOut(uint8(tx.Value().Uint64()), tx.To().Bytes(), big.NewInt(0)))
+			if err := statedb.CreateUTXO(tx.OriginatingTxHash(), tx.ETXIndex(), utxo); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var usedGas uint64
+		fee, _, err := core.ProcessQiTx(tx, chain, true, false, block, statedb, gp, &usedGas, signer, location, *config.ChainID, gasTable, &etxRLimit, &etxPLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to process qi tx %x: %w", tx.Hash(), err)
+		}
+		if isQiConversion(tx, location) {
+			continue
+		}
+		gas := types.CalculateQiTxGas(tx, location)
+		if gas == 0 {
+			continue
+		}
+		rate := new(big.Int).Mul(fee, big.NewInt(QiTipRateGas))
+		tips = append(tips, qiTip{gas: gas, rate: rate.Div(rate, new(big.Int).SetUint64(gas))})
+	}
+	sort.Sort(sortQiTips(tips))
+	oracle.qiTipCache.Add(block.Hash(), tips)
+	return tips, nil
+}
+
+// isQiConversion reports whether the Qi transaction converts to Quai
+func isQiConversion(tx *types.Transaction, location common.Location) bool {
+	for _, txOut := range tx.TxOut() {
+		toAddr := common.BytesToAddress(txOut.Address, location)
+		if toAddr.Location().Equal(location) && toAddr.IsInQuaiLedgerScope() {
+			return true
+		}
+	}
+	return false
+}
+
+// qiTipPercentiles returns the tip rates at the given percentiles of the
+// sorted tips, weighted by the gas used. A zero rate is returned for every
+// percentile if there are no tips.
+func qiTipPercentiles(tips []qiTip, percentiles []float64) []*big.Int {
+	rates := make([]*big.Int, len(percentiles))
+	if len(tips) == 0 {
+		for i := range rates {
+			rates[i] = new(big.Int)
+		}
+		return rates
+	}
+	var totalGas uint64
+	for _, tip := range tips {
+		totalGas += tip.gas
+	}
+	var index int
+	sumGas := tips[0].gas
+	for i, p := range percentiles {
+		thresholdGas := uint64(float64(totalGas) * p / 100)
+		for sumGas < thresholdGas && index < len(tips)-1 {
+			index++
+			sumGas += tips[index].gas
+		}
+		rates[i] = new(big.Int).Set(tips[index].rate)
+	}
+	return rates
+}
+
+// SuggestQiTipRate returns the tip rate, in qits per QiTipRateGas units of gas,
+// paid at the configured percentile by the Qi transactions of the recent blocks.
+func (oracle *Oracle) SuggestQiTipRate(ctx context.Context) (*big.Int, error) {
+	nodeCtx := oracle.backend.ChainConfig().Location.Context()
+	head, _ := oracle.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if head == nil {
+		return nil, errors.New("no header available")
+	}
+	headHash := head.Hash()
+
+	// If the latest tip rate is still available, return it.
+	oracle.cacheLock.RLock()
+	lastHead, lastRate := oracle.lastQiHead, oracle.lastQiTipRate
+	oracle.cacheLock.RUnlock()
+	if headHash == lastHead && lastRate != nil {
+		return new(big.Int).Set(lastRate), nil
+	}
+	var tips sortQiTips
+	number := head.NumberU64(nodeCtx)
+	for i := 0; i < oracle.checkBlocks && uint64(i) <= number; i++ {
+		block, err := oracle.backend.BlockByNumber(ctx, rpc.BlockNumber(number-uint64(i)))
+		if block == nil || err != nil {
+			break
+		}
+		blockTips, err := oracle.qiTips(ctx, block)
+		if err != nil {
+			// The state of the older blocks is not available anymore
+			break
+		}
+		tips = append(tips, blockTips...)
+	}
+	sort.Sort(tips)
+	rate := qiTipPercentiles(tips, []float64{float64(oracle.percentile)})[0]
+
+	oracle.cacheLock.Lock()
+	oracle.lastQiHead = headHash
+	oracle.lastQiTipRate = rate
+	oracle.cacheLock.Unlock()
+
+	return new(big.Int).Set(rate), nil
+}
+
+// QiFeeHistory returns the Qi tips paid in the specified range of blocks, like
+// FeeHistory does for the Quai transactions. The range is resolved the same
+// way. Three results are returned on top of the first block of the range:
+//   - reward: the requested percentiles of the tip rates of the Qi transactions
+//     in each block, sorted in ascending order and weighted by gas used
+//   - qiTxCount: the number of Qi transactions the tips were sampled from in
+//     each block
+//   - suggested: the tip rates to pay for each of the QiFeeTargets, computed
+//     from all the tips paid over the range
+//
+// The tip rates are given in qits per QiTipRateGas units of gas. The tips of
+// a block can only be computed as long as the state of its parent is available,
+// an error is returned if the range reaches older blocks.
+func (oracle *Oracle) QiFeeHistory(ctx context.Context, blocks int, unresolvedLastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []uint64, []*big.Int, error) {
+	nodeCtx := oracle.backend.ChainConfig().Location.Context()
+	if nodeCtx != common.ZONE_CTX {
+		return common.Big0, nil, nil, nil, errors.New("qiFeeHistory can only be called in zone chain")
+	}
+	if blocks < 1 {
+		return common.Big0, nil, nil, nil, nil // returning with no data and no error means there are no retrievable blocks
+	}
+	if blocks > maxFeeHistory {
+		oracle.logger.WithFields(log.Fields{
+			"requested": blocks,
+			"truncated": maxFeeHistory,
+		}).Warn("Sanitizing qi fee history length")
+		blocks = maxFeeHistory
+	}
+	for i, p := range rewardPercentiles {
+		if p < 0 || p > 100 {
+			return common.Big0, nil, nil, nil, fmt.Errorf("%w: %f", errInvalidPercentile, p)
+		}
+		if i > 0 && p < rewardPercentiles[i-1] {
+			return common.Big0, nil, nil, nil, fmt.Errorf("%w: #%d:%f > #%d:%f", errInvalidPercentile, i-1, rewardPercentiles[i-1], i, p)
+		}
+	}
+	pendingBlock, _, lastBlock, blocks, err := oracle.resolveBlockRange(ctx, unresolvedLastBlock, blocks, oracle.maxBlockHistory)
+	if err != nil || blocks == 0 {
+		return common.Big0, nil, nil, nil, err
+	}
+	oldestBlock := lastBlock + 1 - uint64(blocks)
+
+	type blockQiTips struct {
+		blockNumber uint64
+		block       *types.WorkObject
+		tips        []qiTip
+		err         error
+	}
+	var (
+		next    = oldestBlock
+		results = make(chan *blockQiTips, blocks)
+	)
+	for i := 0; i < maxBlockFetchers && i < blocks; i++ {
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					oracle.logger.WithFields(log.Fields{
+						"error":      r,
+						"stacktrace": string(debug.Stack()),
+					}).Error("Go-Quai Panicked")
+				}
+			}()
+			for {
+				// Retrieve the next block number to fetch with this goroutine
+				blockNumber := atomic.AddUint64(&next, 1) - 1
+				if blockNumber > lastBlock {
+					return
+				}
+				fees := &blockQiTips{blockNumber: blockNumber}
+				if pendingBlock != nil && blockNumber >= pendingBlock.NumberU64(nodeCtx) {
+					fees.block = pendingBlock
+				} else {
+					fees.block, fees.err = oracle.backend.BlockByNumber(ctx, rpc.BlockNumber(blockNumber))
+				}
+				if fees.block != nil && fees.err == nil {
+					fees.tips, fees.err = oracle.qiTips(ctx, fees.block)
+				}
+				// send to results even if empty to guarantee that blocks items are sent in total
+				results <- fees
+			}
+		}()
+	}
+	var (
+		reward       = make([][]*big.Int, blocks)
+		qiTxCount    = make([]uint64, blocks)
+		tips         sortQiTips
+		firstMissing = blocks
+	)
+	for ; blocks > 0; blocks-- {
+		fees := <-results
+		if fees.err != nil {
+			return common.Big0, nil, nil, nil, fees.err
+		}
+		i := int(fees.blockNumber - oldestBlock)
+		if fees.block != nil {
+			reward[i], qiTxCount[i] = qiTipPercentiles(fees.tips, rewardPercentiles), uint64(len(fees.tips))
+			tips = append(tips, fees.tips...)
+		} else {
+			// getting no block and no error means we are requesting into the future (might happen because of a reorg)
+			if i < firstMissing {
+				firstMissing = i
+			}
+		}
+	}
+	if firstMissing == 0 {
+		return common.Big0, nil, nil, nil, nil
+	}
+	if len(rewardPercentiles) != 0 {
+		reward = reward[:firstMissing]
+	} else {
+		reward = nil
+	}
+	sort.Sort(tips)
+	targets := make([]float64, len(QiFeeTargets))
+	for i, target := range QiFeeTargets {
+		targets[i] = target.Percentile
+	}
+	return new(big.Int).SetUint64(oldestBlock), reward, qiTxCount[:firstMissing], qiTipPercentiles(tips, targets), nil
+}
@@ -0,0 +1,280 @@
+package gasprice
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/crypto"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/rpc"
+)
+
+// qiKey is a key owning Qi outputs in the test zone
+type qiKey struct {
+	priv *btcec.PrivateKey
+	pub  []byte
+	addr common.Address
+}
+
+// newQiKey returns a key whose address is in the Qi ledger of the test zone
+func newQiKey(t *testing.T) *qiKey {
+	for {
+		priv, err := btcec.NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub := priv.PubKey().SerializeUncompressed()
+		addr := crypto.PubkeyBytesToAddress(pub, testLocation)
+		if addr.Location().Equal(testLocation) && addr.IsInQiLedgerScope() {
+			return &qiKey{priv: priv, pub: pub, addr: addr}
+		}
+	}
+}
+
+// newQiTx returns a signed Qi transaction spending an output of the key into
+// a single output of the given denomination
+func newQiTx(t *testing.T, backend *testBackend, from *qiKey, prevOut types.OutPoint, to *qiKey, denomination uint8) *types.Transaction {
+	inner := &types.QiTx{
+		ChainID: backend.config.ChainID,
+		TxIn:    types.TxIns{*types.NewTxIn(&prevOut, from.pub, nil)},
+		TxOut:   types.TxOuts{*types.NewTxOut(denomination, to.addr.Bytes(), big.NewInt(0))},
+	}
+	digest := types.LatestSigner(backend.config).Hash(types.NewTx(inner))
+	sig, err := schnorr.Sign(from.priv, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner.Signature = sig
+	return types.NewTx(inner)
+}
+
+// newQiEtx returns a Qi ETX sent from another zone to the key
+func newQiEtx(originatingTxHash common.Hash, to *qiKey, denomination uint8) *types.Transaction {
+	sender := common.BytesToAddress(common.InternalAddress{common.Location{1, 0}.BytePrefix(), 0x01}.Bytes(), testLocation)
+	return types.NewTx(&types.ExternalTx{
+		OriginatingTxHash: originatingTxHash,
+		ETXIndex:          0,
+		To:                &to.addr,
+		Value:             big.NewInt(int64(denomination)),
+		Sender:            sender,
+	})
+}
+
+// qiTipOf returns the tip rate paid by a Qi transaction with a single input
+// and output of the given denominations
+func qiTipOf(tx *types.Transaction, in, out uint8) qiTip {
+	gas := types.CalculateQiTxGas(tx, testLocation)
+	rate := new(big.Int).Sub(types.Denominations[in], types.Denominations[out])
+	rate.Mul(rate, big.NewInt(QiTipRateGas))
+	return qiTip{gas: gas, rate: rate.Div(rate, new(big.Int).SetUint64(gas))}
+}
+
+// qiTestChain is a chain of Qi blocks with the tips the Qi transactions of
+// every block paid, in ascending order
+type qiTestChain struct {
+	backend *testBackend
+	tips    [][]qiTip
+}
+
+// newQiTestChain builds a genesis block owning three outputs and two blocks
+// spending them. The first block spends the output of one of its transactions
+// and of one of its ETXs.
+func newQiTestChain(t *testing.T) *qiTestChain {
+	var (
+		backend = newEmptyTestBackend()
+		keys    = make([]*qiKey, 8)
+		chain   = &qiTestChain{backend: backend}
+	)
+	for i := range keys {
+		keys[i] = newQiKey(t)
+	}
+	commit := func(parentRoot common.Hash, update func(statedb *state.StateDB)) common.Hash {
+		statedb, err := state.New(types.EmptyRootHash, parentRoot, types.EmptyRootHash, backend.stateDb, backend.utxoDb, backend.etxDb, nil, testLocation, log.Global)
+		if err != nil {
+			t.Fatal(err)
+		}
+		update(statedb)
+		root, err := statedb.CommitUTXOs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := backend.utxoDb.TrieDB().Commit(root, false, nil); err != nil {
+			t.Fatal(err)
+		}
+		return root
+	}
+	// The genesis outputs
+	genesisOutputs := []types.OutPoint{
+		{TxHash: common.Hash{0x01}, Index: 0},
+		{TxHash: common.Hash{0x02}, Index: 0},
+		{TxHash: common.Hash{0x03}, Index: 0},
+	}
+	genesisRoot := commit(types.EmptyRootHash, func(statedb *state.StateDB) {
+		for i, outpoint := range genesisOutputs {
+			if err := statedb.CreateUTXO(outpoint.TxHash, outpoint.Index, types.NewUtxoEntry(types.NewTxOut(6, keys[i].addr.Bytes(), big.NewInt(0)))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	backend.addBlock(nil, nil, genesisRoot)
+	chain.tips = append(chain.tips, []qiTip{})
+
+	// The first block spends an output of the genesis, the output of that
+	// transaction and the output of an ETX
+	var (
+		txA  = newQiTx(t, backend, keys[0], genesisOutputs[0], keys[3], 5)
+		txB  = newQiTx(t, backend, keys[3], types.OutPoint{TxHash: txA.Hash(), Index: 0}, keys[4], 4)
+		etx  = newQiEtx(common.Hash{0x04}, keys[5], 3)
+		txC  = newQiTx(t, backend, keys[5], types.OutPoint{TxHash: etx.OriginatingTxHash(), Index: 0}, keys[6], 0)
+		root = commit(genesisRoot, func(statedb *state.StateDB) {
+			statedb.DeleteUTXO(genesisOutputs[0].TxHash, genesisOutputs[0].Index)
+			for _, tx := range []*types.Transaction{txB, txC} {
+				if err := statedb.CreateUTXO(tx.Hash(), 0, types.NewUtxoEntry(&tx.TxOut()[0])); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	)
+	backend.addBlock([]*types.Transaction{etx, txA, txB, txC}, nil, root)
+	chain.tips = append(chain.tips, []qiTip{qiTipOf(txC, 3, 0), qiTipOf(txB, 5, 4), qiTipOf(txA, 6, 5)})
+
+	// The second block spends another output of the genesis
+	txD := newQiTx(t, backend, keys[1], genesisOutputs[1], keys[7], 2)
+	root = commit(root, func(statedb *state.StateDB) {
+		statedb.DeleteUTXO(genesisOutputs[1].TxHash, genesisOutputs[1].Index)
+		if err := statedb.CreateUTXO(txD.Hash(), 0, types.NewUtxoEntry(&txD.TxOut()[0])); err != nil {
+			t.Fatal(err)
+		}
+	})
+	backend.addBlock([]*types.Transaction{txD}, nil, root)
+	chain.tips = append(chain.tips, []qiTip{qiTipOf(txD, 6, 2)})
+
+	backend.head = rpc.BlockNumber(len(backend.blocks) - 1)
+	return chain
+}
+
+func TestQiTipPercentiles(t *testing.T) {
+	tips := []qiTip{
+		{gas: 100, rate: big.NewInt(1)},
+		{gas: 100, rate: big.NewInt(2)},
+		{gas: 200, rate: big.NewInt(3)},
+		{gas: 600, rate: big.NewInt(4)},
+	}
+	var cases = []struct {
+		tips        []qiTip
+		percentiles []float64
+		expect      []int64
+	}{
+		{nil, []float64{0, 50, 100}, []int64{0, 0, 0}},
+		{tips[:1], []float64{0, 50, 100}, []int64{1, 1, 1}},
+		// The percentiles are weighted by the gas used: the last tip alone is
+		// 60% of the gas
+		{tips, []float64{0, 10, 20, 30, 40, 50, 100}, []int64{1, 1, 2, 3, 3, 4, 4}},
+	}
+	for i, c := range cases {
+		rates := qiTipPercentiles(c.tips, c.percentiles)
+		if len(rates) != len(c.expect) {
+			t.Fatalf("case %d: have %d rates, want %d", i, len(rates), len(c.expect))
+		}
+		for j, rate := range rates {
+			if rate.Int64() != c.expect[j] {
+				t.Errorf("case %d: rate at percentile %v is %v, want %d", i, c.percentiles[j], rate, c.expect[j])
+			}
+		}
+	}
+}
+
+// Tests that the tips of the Qi transactions spending the outputs created
+// earlier in their block are computed.
+func TestQiTipsInBlockSpends(t *testing.T) {
+	chain := newQiTestChain(t)
+	oracle := NewOracle(chain.backend, Config{}, log.Global)
+
+	for number, block := range chain.backend.blocks {
+		tips, err := oracle.qiTips(context.Background(), block)
+		if err != nil {
+			t.Fatalf("block %d: failed to compute the qi tips: %v", number, err)
+		}
+		want := chain.tips[number]
+		if len(tips) != len(want) {
+			t.Fatalf("block %d: have %d tips, want %d", number, len(tips), len(want))
+		}
+		for i := range tips {
+			if tips[i].gas != want[i].gas || tips[i].rate.Cmp(want[i].rate) != 0 {
+				t.Errorf("block %d: tip %d is %d at %v, want %d at %v", number, i, tips[i].gas, tips[i].rate, want[i].gas, want[i].rate)
+			}
+		}
+	}
+}
+
+func TestSuggestQiTipRate(t *testing.T) {
+	chain := newQiTestChain(t)
+	var tips sortQiTips
+	for _, blockTips := range chain.tips {
+		tips = append(tips, blockTips...)
+	}
+	sort.Sort(tips)
+
+	// All the transactions use the same gas, so the median of the four tips is
+	// the second one
+	var cases = []struct {
+		blocks, percentile int
+		expect             *big.Int
+	}{
+		{1, 50, chain.tips[2][0].rate},
+		{3, 50, tips[1].rate},
+		{3, 100, tips[3].rate},
+	}
+	for i, c := range cases {
+		oracle := NewOracle(chain.backend, Config{Blocks: c.blocks, Percentile: c.percentile}, log.Global)
+		got, err := oracle.SuggestQiTipRate(context.Background())
+		if err != nil {
+			t.Fatalf("case %d: failed to suggest a qi tip rate: %v", i, err)
+		}
+		if got.Cmp(c.expect) != 0 {
+			t.Errorf("case %d: qi tip rate mismatch, want %v, got %v", i, c.expect, got)
+		}
+	}
+}
+
+func TestQiFeeHistory(t *testing.T) {
+	chain := newQiTestChain(t)
+	oracle := NewOracle(chain.backend, Config{}, log.Global)
+
+	first, reward, qiTxCount, suggested, err := oracle.QiFeeHistory(context.Background(), 2, rpc.LatestBlockNumber, []float64{0, 100})
+	if err != nil {
+		t.Fatalf("failed to retrieve the qi fee history: %v", err)
+	}
+	if first.Uint64() != 1 {
+		t.Fatalf("first block mismatch, want 1, got %d", first)
+	}
+	if len(qiTxCount) != 2 || qiTxCount[0] != 3 || qiTxCount[1] != 1 {
+		t.Fatalf("qi tx count mismatch, want [3 1], got %v", qiTxCount)
+	}
+	if len(reward) != 2 || reward[0][0].Cmp(chain.tips[1][0].rate) != 0 || reward[0][1].Cmp(chain.tips[1][2].rate) != 0 {
+		t.Fatalf("reward mismatch, got %v", reward)
+	}
+	if len(suggested) != len(QiFeeTargets) {
+		t.Fatalf("have %d suggested rates, want %d", len(suggested), len(QiFeeTargets))
+	}
+
+	// The tips of a block can't be computed without the state of its parent
+	chain.backend.pruned[chain.backend.blocks[0].Hash()] = true
+	oracle = NewOracle(chain.backend, Config{}, log.Global)
+	if _, _, _, _, err := oracle.QiFeeHistory(context.Background(), 2, rpc.LatestBlockNumber, nil); err == nil {
+		t.Fatal("qi fee history over a pruned state succeeded")
+	}
+	if _, _, qiTxCount, _, err := oracle.QiFeeHistory(context.Background(), 1, rpc.LatestBlockNumber, nil); err != nil {
+		t.Fatalf("failed to retrieve the qi fee history above the pruned state: %v", err)
+	} else if len(qiTxCount) != 1 || qiTxCount[0] != 1 {
+		t.Fatalf("qi tx count mismatch, want [1], got %v", qiTxCount)
+	}
+}
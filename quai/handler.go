@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	goquai "github.com/dominant-strategies/go-quai"
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/core"
 	"github.com/dominant-strategies/go-quai/core/types"
@@ -29,6 +30,12 @@ const (
 	c_broadcastTransactionsInterval = 2 * time.Second
 	// c_maxTxBatchSize is the maximum number of transactions to broadcast at once
 	c_maxTxBatchSize = 100
+	// c_missingTxsChanSize is the size of channel listening to the MissingTxsEvent
+	c_missingTxsChanSize = 100
+	// c_recentTxReqCache is the size of the cache for the recent transaction requests
+	c_recentTxReqCache = 10000
+	// c_syncInterval is the interval between two rounds of the headers-first sync
+	c_syncInterval = 10 * time.Second
 )
 
 // handler manages the fetch requests from the core and tx pool also takes care of the tx broadcast
@@ -40,22 +47,30 @@ type handler struct {
 	missingBlockSub event.Subscription
 	txsCh           chan core.NewTxsEvent
 	txsSub          event.Subscription
+	missingTxsCh    chan core.MissingTxsEvent
+	missingTxsSub   event.Subscription
+	downloader      *downloader
+	stateSync       bool
+	stateSyncer     *stateSyncer
 	wg              sync.WaitGroup
 	quitCh          chan struct{}
 	logger          *log.Logger
 
 	recentBlockReqCache *expireLru.LRU[common.Hash, interface{}] // cache the latest requests on a 1 min timer
+	recentTxReqCache    *expireLru.LRU[common.Hash, interface{}] // cache the latest transaction requests on a 1 min timer
 }
 
-func newHandler(p2pBackend NetworkingAPI, core *core.Core, nodeLocation common.Location, logger *log.Logger) *handler {
+func newHandler(p2pBackend NetworkingAPI, core *core.Core, nodeLocation common.Location, stateSync bool, logger *log.Logger) *handler {
 	handler := &handler{
 		nodeLocation: nodeLocation,
 		p2pBackend:   p2pBackend,
 		core:         core,
+		stateSync:    stateSync,
 		quitCh:       make(chan struct{}),
 		logger:       logger,
 	}
 	handler.recentBlockReqCache = expireLru.NewLRU[common.Hash, interface{}](c_recentBlockReqCache, nil, c_recentBlockReqTimeout)
+	handler.recentTxReqCache = expireLru.NewLRU[common.Hash, interface{}](c_recentTxReqCache, nil, c_recentBlockReqTimeout)
 	return handler
 }
 
@@ -71,11 +86,30 @@ func (h *handler) Start() {
 		h.txsCh = make(chan core.NewTxsEvent, c_newTxsChanSize)
 		h.txsSub = h.core.SubscribeNewTxsEvent(h.txsCh)
 		go h.txBroadcastLoop()
+
+		h.wg.Add(1)
+		h.missingTxsCh = make(chan core.MissingTxsEvent, c_missingTxsChanSize)
+		h.missingTxsSub = h.core.SubscribeMissingTxsEvent(h.missingTxsCh)
+		go h.txFetchLoop()
 	}
 
 	if nodeCtx == common.PRIME_CTX {
 		h.wg.Add(1)
 		go h.checkNextPrimeBlock()
+	} else {
+		h.wg.Add(1)
+		h.downloader = newDownloader(h.p2pBackend, h.core, h.nodeLocation, h.quitCh, h.logger)
+		go h.syncLoop()
+	}
+
+	// A fresh zone downloads the state instead of processing the chain from
+	// genesis, an interrupted state sync is resumed
+	if nodeCtx == common.ZONE_CTX && h.core.ProcessingState() &&
+		(h.core.StateSyncing() || (h.stateSync && h.core.IsGenesisHash(h.core.CurrentHeader().Hash()))) {
+		h.core.StartStateSync()
+		h.wg.Add(1)
+		h.stateSyncer = newStateSyncer(h.p2pBackend, h.core, h.downloader, h.nodeLocation, h.quitCh, h.logger)
+		go h.stateSyncLoop()
 	}
 }
 
@@ -83,7 +117,8 @@ func (h *handler) Stop() {
 	h.missingBlockSub.Unsubscribe() // quits missingBlockLoop
 	nodeCtx := h.nodeLocation.Context()
 	if nodeCtx == common.ZONE_CTX && h.core.ProcessingState() {
-		h.txsSub.Unsubscribe() // quits the txBroadcastLoop
+		h.txsSub.Unsubscribe()        // quits the txBroadcastLoop
+		h.missingTxsSub.Unsubscribe() // quits the txFetchLoop
 	}
 	close(h.quitCh)
 	h.wg.Wait()
@@ -201,6 +236,120 @@ func (h *handler) broadcastTransactions(transactions types.Transactions) {
 	}
 }
 
+// txFetchLoop requests from the peers the transactions the tx pool found
+// missing, and adds the received ones to the tx pool.
+func (h *handler) txFetchLoop() {
+	defer h.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			h.logger.WithFields(log.Fields{
+				"error":      r,
+				"stacktrace": string(debug.Stack()),
+			}).Fatal("Go-Quai Panicked")
+		}
+	}()
+	for {
+		select {
+		case event := <-h.missingTxsCh:
+			for _, hash := range event.Hashes {
+				if _, exists := h.recentTxReqCache.Get(hash); exists {
+					// Don't ask for the same transaction multiple times within a min window
+					continue
+				}
+				if h.core.Get(hash) != nil || h.core.GetTransactionLookup(hash) != nil {
+					continue
+				}
+				h.recentTxReqCache.Add(hash, true)
+				go h.fetchTransaction(hash)
+			}
+		case <-h.missingTxsSub.Err():
+			return
+		case <-h.quitCh:
+			return
+		}
+	}
+}
+
+// fetchTransaction requests a transaction from the peers and adds it to the
+// tx pool if a peer has it.
+func (h *handler) fetchTransaction(hash common.Hash) {
+	defer func() {
+		if r := recover(); r != nil {
+			h.logger.WithFields(log.Fields{
+				"error":      r,
+				"stacktrace": string(debug.Stack()),
+			}).Fatal("Go-Quai Panicked")
+		}
+	}()
+	resultCh := h.p2pBackend.Request(h.nodeLocation, hash, &types.Transaction{})
+	data := <-resultCh
+	if tx, ok := data.(*types.Transaction); ok && tx != nil && tx.Hash() == hash {
+		h.core.AddRemotes(types.Transactions{tx})
+	}
+}
+
+// syncLoop runs a round of the headers-first sync every c_syncInterval
+func (h *handler) syncLoop() {
+	defer h.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			h.logger.WithFields(log.Fields{
+				"error":      r,
+				"stacktrace": string(debug.Stack()),
+			}).Fatal("Go-Quai Panicked")
+		}
+	}()
+	syncTicker := time.NewTicker(c_syncInterval)
+	defer syncTicker.Stop()
+	for {
+		select {
+		case <-syncTicker.C:
+			h.downloader.synchronise()
+		case <-h.quitCh:
+			return
+		}
+	}
+}
+
+// stateSyncLoop downloads the state of the zone from the peers
+func (h *handler) stateSyncLoop() {
+	defer h.wg.Done()
+	defer func() {
+		if r := recover(); r != nil {
+			h.logger.WithFields(log.Fields{
+				"error":      r,
+				"stacktrace": string(debug.Stack()),
+			}).Fatal("Go-Quai Panicked")
+		}
+	}()
+	for {
+		err := h.stateSyncer.run()
+		if err == nil {
+			return
+		}
+		h.logger.WithField("err", err).Error("Error processing the blocks on top of the state sync pivot, retrying the state sync")
+		if !h.stateSyncer.wait() {
+			return
+		}
+	}
+}
+
+// SyncProgress returns the progress of the headers-first sync and of the state
+// sync. Prime does not run the downloader and always reports the current head
+func (h *handler) SyncProgress() goquai.SyncProgress {
+	var progress goquai.SyncProgress
+	if h.downloader == nil {
+		number := h.core.CurrentHeader().NumberU64(h.nodeLocation.Context())
+		progress = goquai.SyncProgress{StartingBlock: number, CurrentBlock: number, HighestBlock: number}
+	} else {
+		progress = h.downloader.Progress()
+	}
+	if h.stateSyncer != nil && h.core.StateSyncing() {
+		progress.PulledStates, progress.KnownStates = h.stateSyncer.Progress()
+	}
+	return progress
+}
+
 // checkNextPrimeBlock runs every c_checkNextPrimeBlockInterval and ask the peer for the next Block
 func (h *handler) checkNextPrimeBlock() {
 	defer h.wg.Done()
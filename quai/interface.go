@@ -5,6 +5,7 @@ import (
 	"math/big"
 
 	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/forkid"
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/internal/quaiapi"
 	"github.com/dominant-strategies/go-quai/quaiclient"
@@ -20,6 +21,10 @@ type ConsensusAPI interface {
 	// Returns the current block height for the given location
 	GetHeight(common.Location) uint64
 
+	// Returns the hash and the entropy of the current head of the chain running
+	// at the given location, returns an error if the chain is not running locally
+	GetHead(common.Location) (common.Hash, *big.Int, error)
+
 	// Handle new data propagated from the gossip network. Should return quickly.
 	// Specify the peer which propagated the data to us, as well as the data itself.
 	// Return true if this data should be relayed to peers. False if it should be ignored.
@@ -34,6 +39,11 @@ type ConsensusAPI interface {
 
 	LookupBlockHashByNumber(*big.Int, common.Location) *common.Hash
 
+	// Asks the consensus backend to lookup a transaction by hash and location,
+	// first in the tx pool and then in the transaction lookup index.
+	// If the transaction is found, it should be returned. Otherwise, nil should be returned.
+	LookupTransaction(common.Hash, common.Location) *types.Transaction
+
 	// Asks the consensus backend to lookup a trie node by hash and location,
 	// and return the data in the trie node.
 	GetTrieNode(hash common.Hash, location common.Location) *trie.TrieNodeResponse
@@ -58,6 +68,13 @@ type ConsensusAPI interface {
 
 	// Returns if the location is processing state
 	ProcessingState(common.Location) bool
+
+	// Returns the fork identifier of the chain running at the given location
+	ForkID(common.Location) (forkid.ID, error)
+
+	// Checks the fork identifier announced by a peer for the given location
+	// against the local chain, returns an error if the chains are incompatible
+	ValidateForkID(common.Location, forkid.ID) error
 }
 
 // The networking backend will implement the following interface to enable consensus to communicate with other nodes.
@@ -2,13 +2,16 @@ package quai
 
 import (
 	"context"
+	"errors"
 	"math/big"
 
 	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/forkid"
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/internal/quaiapi"
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/p2p"
+	"github.com/dominant-strategies/go-quai/params"
 	"github.com/dominant-strategies/go-quai/quaiclient"
 	"github.com/dominant-strategies/go-quai/rpc"
 	"github.com/dominant-strategies/go-quai/trie"
@@ -39,6 +42,7 @@ func (qbe *QuaiBackend) SetP2PApiBackend(p2pBackend NetworkingAPI) {
 }
 
 func (qbe *QuaiBackend) SetApiBackend(apiBackend *quaiapi.Backend, location common.Location) {
+	(*apiBackend).SetSliceBackends(qbe)
 	switch location.Context() {
 	case common.PRIME_CTX:
 		qbe.SetPrimeApiBackend(apiBackend)
@@ -64,13 +68,21 @@ func (qbe *QuaiBackend) SetZoneApiBackend(zoneBackend *quaiapi.Backend, location
 	qbe.zoneApiBackends[location.Region()][location.Zone()] = zoneBackend
 }
 
+// GetBackend returns the api backend of the given location, or nil if there
+// is none. The location may come from a peer, so it is bounds checked.
 func (qbe *QuaiBackend) GetBackend(location common.Location) *quaiapi.Backend {
 	switch location.Context() {
 	case common.PRIME_CTX:
 		return qbe.primeApiBackend
 	case common.REGION_CTX:
+		if location.Region() >= len(qbe.regionApiBackends) {
+			return nil
+		}
 		return qbe.regionApiBackends[location.Region()]
 	case common.ZONE_CTX:
+		if location.Region() >= len(qbe.zoneApiBackends) || location.Zone() >= len(qbe.zoneApiBackends[location.Region()]) {
+			return nil
+		}
 		return qbe.zoneApiBackends[location.Region()][location.Zone()]
 	}
 	return nil
@@ -80,11 +92,12 @@ func (qbe *QuaiBackend) GetBackend(location common.Location) *quaiapi.Backend {
 func (qbe *QuaiBackend) OnNewBroadcast(sourcePeer p2p.PeerID, topic string, data interface{}, nodeLocation common.Location) bool {
 	switch data := data.(type) {
 	case types.WorkObject:
-		backend := *qbe.GetBackend(nodeLocation)
-		if backend == nil {
+		backendPtr := qbe.GetBackend(nodeLocation)
+		if backendPtr == nil {
 			log.Global.Error("no backend found")
 			return false
 		}
+		backend := *backendPtr
 		// TODO: Verify the Block before writing it
 		// TODO: Determine if the block information was lively or stale and rate
 		// the peer accordingly
@@ -92,11 +105,12 @@ func (qbe *QuaiBackend) OnNewBroadcast(sourcePeer p2p.PeerID, topic string, data
 		// If it was a good broadcast, mark the peer as lively
 		qbe.p2pBackend.MarkLivelyPeer(sourcePeer, topic)
 	case types.WorkObjectHeaderView:
-		backend := *qbe.GetBackend(nodeLocation)
-		if backend == nil {
+		backendPtr := qbe.GetBackend(nodeLocation)
+		if backendPtr == nil {
 			log.Global.Error("no backend found")
 			return false
 		}
+		backend := *backendPtr
 		// Only append this in the case of the slice
 		if !backend.ProcessingState() && backend.NodeCtx() == common.ZONE_CTX {
 			backend.WriteBlock(data.ConvertToBlockView().WorkObject)
@@ -104,21 +118,23 @@ func (qbe *QuaiBackend) OnNewBroadcast(sourcePeer p2p.PeerID, topic string, data
 		// If it was a good broadcast, mark the peer as lively
 		qbe.p2pBackend.MarkLivelyPeer(sourcePeer, topic)
 	case types.Transactions:
-		backend := *qbe.GetBackend(nodeLocation)
-		if backend == nil {
+		backendPtr := qbe.GetBackend(nodeLocation)
+		if backendPtr == nil {
 			log.Global.Error("no backend found")
 			return false
 		}
+		backend := *backendPtr
 		if backend.ProcessingState() {
 			backend.SendRemoteTxs(data)
 		}
 		// TODO: Handle the error here and mark the peers accordingly
 	case types.WorkObjectHeader:
-		backend := *qbe.GetBackend(nodeLocation)
-		if backend == nil {
+		backendPtr := qbe.GetBackend(nodeLocation)
+		if backendPtr == nil {
 			log.Global.Error("no backend found")
 			return false
 		}
+		backend := *backendPtr
 		backend.SendWorkShare(&data)
 		// If it was a good broadcast, mark the peer as lively
 		qbe.p2pBackend.MarkLivelyPeer(sourcePeer, topic)
@@ -136,8 +152,17 @@ func (qbe *QuaiBackend) OnNewBroadcast(sourcePeer p2p.PeerID, topic string, data
 
 // GetTrieNode returns the TrieNodeResponse for a given hash
 func (qbe *QuaiBackend) GetTrieNode(hash common.Hash, location common.Location) *trie.TrieNodeResponse {
-	// Example/mock implementation
-	panic("todo")
+	backendPtr := qbe.GetBackend(location)
+	if backendPtr == nil {
+		log.Global.Error("no backend found")
+		return nil
+	}
+	backend := *backendPtr
+	nodeData, err := backend.TrieNode(hash)
+	if err != nil || len(nodeData) == 0 {
+		return nil
+	}
+	return &trie.TrieNodeResponse{NodeData: nodeData}
 }
 
 // Returns the current block height for the given location
@@ -146,14 +171,28 @@ func (qbe *QuaiBackend) GetHeight(location common.Location) uint64 {
 	panic("todo")
 }
 
+// GetHead returns the hash and the entropy of the current head of the chain
+// running at the given location
+func (qbe *QuaiBackend) GetHead(location common.Location) (common.Hash, *big.Int, error) {
+	backendPtr := qbe.GetBackend(location)
+	if backendPtr == nil {
+		return common.Hash{}, nil, errors.New("no backend found")
+	}
+	backend := *backendPtr
+	head := backend.CurrentHeader()
+	if head == nil {
+		return common.Hash{}, nil, errors.New("head block not found")
+	}
+	return head.Hash(), backend.CurrentLogEntropy(), nil
+}
+
 func (qbe *QuaiBackend) ValidatorFunc() func(ctx context.Context, id p2p.PeerID, msg *pubsub.Message) pubsub.ValidationResult {
 	return func(ctx context.Context, id peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
 		var data interface{}
 		data = msg.Message.GetData()
 		switch data := data.(type) {
 		case types.WorkObject:
-			backend := *qbe.GetBackend(data.Location())
-			if backend == nil {
+			if qbe.GetBackend(data.Location()) == nil {
 				log.Global.WithFields(log.Fields{
 					"peer":     id,
 					"hash":     data.Hash(),
@@ -199,31 +238,34 @@ func (qbe *QuaiBackend) SetCurrentExpansionNumber(expansionNumber uint8) {
 
 // WriteGenesisBlock adds the genesis block to the database and also writes the block to the disk
 func (qbe *QuaiBackend) WriteGenesisBlock(block *types.WorkObject, location common.Location) {
-	backend := *qbe.GetBackend(location)
-	if backend == nil {
+	backendPtr := qbe.GetBackend(location)
+	if backendPtr == nil {
 		log.Global.Error("no backend found")
 		return
 	}
+	backend := *backendPtr
 	backend.WriteGenesisBlock(block, location)
 }
 
 // SetSubClient sets the sub client for the given subLocation
 func (qbe *QuaiBackend) SetSubClient(client *quaiclient.Client, nodeLocation common.Location, subLocation common.Location) {
-	backend := *qbe.GetBackend(nodeLocation)
-	if backend == nil {
+	backendPtr := qbe.GetBackend(nodeLocation)
+	if backendPtr == nil {
 		log.Global.Error("no backend found")
 		return
 	}
+	backend := *backendPtr
 	backend.SetSubClient(client, subLocation)
 }
 
 // AddGenesisPendingEtxs adds the genesis pending etxs for the given location
 func (qbe *QuaiBackend) AddGenesisPendingEtxs(block *types.WorkObject, location common.Location) {
-	backend := *qbe.GetBackend(location)
-	if backend == nil {
+	backendPtr := qbe.GetBackend(location)
+	if backendPtr == nil {
 		log.Global.Error("no backend found")
 		return
 	}
+	backend := *backendPtr
 	backend.AddGenesisPendingEtxs(block)
 }
 
@@ -231,23 +273,25 @@ func (qbe *QuaiBackend) LookupBlock(hash common.Hash, location common.Location)
 	if qbe == nil {
 		return nil
 	}
-	backend := *qbe.GetBackend(location)
-	if backend == nil {
+	backendPtr := qbe.GetBackend(location)
+	if backendPtr == nil {
 		log.Global.Error("no backend found")
 		return nil
 	}
+	backend := *backendPtr
 	return backend.BlockOrCandidateByHash(hash)
 }
 
 func (qbe *QuaiBackend) LookupBlockHashByNumber(number *big.Int, location common.Location) *common.Hash {
-	backend := *qbe.GetBackend(location)
-	if backend == nil {
+	backendPtr := qbe.GetBackend(location)
+	if backendPtr == nil {
 		log.Global.Error("no backend found")
 		return nil
 	}
+	backend := *backendPtr
 	block, err := backend.BlockByNumber(context.Background(), rpc.BlockNumber(number.Int64()))
 	if err != nil {
-		log.Global.Tracef("Error looking up the BlockByNumber", location)
+		log.Global.Tracef("Error looking up the BlockByNumber %v", location)
 	}
 	if block != nil {
 		blockHash := block.Hash()
@@ -257,11 +301,78 @@ func (qbe *QuaiBackend) LookupBlockHashByNumber(number *big.Int, location common
 	}
 }
 
+func (qbe *QuaiBackend) LookupTransaction(hash common.Hash, location common.Location) *types.Transaction {
+	backendPtr := qbe.GetBackend(location)
+	if backendPtr == nil {
+		log.Global.Error("no backend found")
+		return nil
+	}
+	backend := *backendPtr
+	if backend.ProcessingState() {
+		if tx := backend.GetPoolTransaction(hash); tx != nil {
+			return tx
+		}
+	}
+	tx, _, _, _, err := backend.GetTransaction(context.Background(), hash)
+	if err != nil {
+		log.Global.Tracef("Error looking up the transaction %s", hash)
+		return nil
+	}
+	return tx
+}
+
 func (qbe *QuaiBackend) ProcessingState(location common.Location) bool {
-	backend := *qbe.GetBackend(location)
-	if backend == nil {
+	backendPtr := qbe.GetBackend(location)
+	if backendPtr == nil {
 		log.Global.Error("no backend found")
 		return false
 	}
+	backend := *backendPtr
 	return backend.ProcessingState()
 }
+
+// ForkID returns the fork identifier of the chain running at the given location
+func (qbe *QuaiBackend) ForkID(location common.Location) (forkid.ID, error) {
+	chain, err := qbe.forkIDChain(location)
+	if err != nil {
+		return forkid.ID{}, err
+	}
+	return forkid.NewIDWithChain(chain), nil
+}
+
+// ValidateForkID checks the fork identifier announced by a peer for the given
+// location against the local chain
+func (qbe *QuaiBackend) ValidateForkID(location common.Location, id forkid.ID) error {
+	chain, err := qbe.forkIDChain(location)
+	if err != nil {
+		return err
+	}
+	return forkid.NewFilter(chain)(id)
+}
+
+func (qbe *QuaiBackend) forkIDChain(location common.Location) (*forkIDChain, error) {
+	backendPtr := qbe.GetBackend(location)
+	if backendPtr == nil {
+		return nil, errors.New("no backend found")
+	}
+	backend := *backendPtr
+	genesis, err := backend.HeaderByNumber(context.Background(), 0)
+	if err != nil {
+		return nil, err
+	}
+	if genesis == nil {
+		return nil, errors.New("genesis block not found")
+	}
+	return &forkIDChain{backend: backend, genesis: genesis}, nil
+}
+
+// forkIDChain adapts an api backend to the chain needed to compute fork
+// identifiers
+type forkIDChain struct {
+	backend quaiapi.Backend
+	genesis *types.WorkObject
+}
+
+func (c *forkIDChain) Config() *params.ChainConfig      { return c.backend.ChainConfig() }
+func (c *forkIDChain) Genesis() *types.WorkObject       { return c.genesis }
+func (c *forkIDChain) CurrentHeader() *types.WorkObject { return c.backend.CurrentHeader() }
@@ -71,6 +71,7 @@ var Defaults = Config{
 	NetworkId:                 1,
 	TxLookupLimit:             2350000,
 	DatabaseCache:             512,
+	DatabaseFreezerThreshold:  params.FullImmutabilityThreshold,
 	TrieCleanCache:            154,
 	TrieCleanCacheJournal:     "triecache",
 	UTXOTrieCleanCacheJournal: "utxotriecache",
@@ -121,6 +122,9 @@ type Config struct {
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
 	DatabaseFreezer    string
+	// Number of blocks below the most recent prime coincident block which are
+	// kept in the key-value store before being moved to the ancient store
+	DatabaseFreezerThreshold uint64
 
 	TrieCleanCache            int
 	TrieCleanCacheJournal     string        `toml:",omitempty"` // Disk journal directory for trie cache to survive node restarts
@@ -184,6 +188,16 @@ type Config struct {
 	// IndexAddressUtxos enables or disables address utxo indexing
 	IndexAddressUtxos bool
 
+	// IndexAddressHistory enables or disables address transaction history indexing
+	IndexAddressHistory bool
+
+	// IndexEtxs enables or disables the indexing of the slices crossed by the etxs
+	IndexEtxs bool
+
+	// StateSync downloads the state of a recent pivot block from the peers
+	// instead of processing every block since genesis
+	StateSync bool
+
 	// DefaultGenesisHash is the hard coded genesis hash
 	DefaultGenesisHash common.Hash
 }
@@ -0,0 +1,266 @@
+package quai
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/crypto"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/trie"
+)
+
+const (
+	// c_pivotDistance is the number of blocks behind the head the pivot block
+	// of the state sync is picked at, so that it is unlikely to be reorged
+	c_pivotDistance = 64
+	// c_pivotStaleDistance is the number of blocks the head can move past the
+	// pivot before the pivot is moved forward and the tries are healed
+	c_pivotStaleDistance = 256
+	// c_trieNodeBatchSize is the number of trie nodes requested in a round
+	c_trieNodeBatchSize = 384
+	// c_trieNodeFetchers is the number of trie nodes requested from the peers in parallel
+	c_trieNodeFetchers = 16
+	// c_stateSyncRetryInterval is the time waited before a new attempt when no
+	// peer could serve the requested trie nodes, or no pivot could be picked
+	c_stateSyncRetryInterval = 10 * time.Second
+)
+
+// stateSyncCore is the part of the core the state syncer picks the pivot from
+// and writes the downloaded tries to
+type stateSyncCore interface {
+	CurrentHeader() *types.WorkObject
+	GetBlockByNumber(number uint64) *types.WorkObject
+	GetCanonicalHash(number uint64) common.Hash
+	NewStateSync(block *types.WorkObject) []*trie.Sync
+	CommitStateSync(sched *trie.Sync, pulled uint64) error
+	StateSyncProgress() uint64
+	SetStateSyncPivot(pivot *types.WorkObject)
+	CompleteStateSync(pivot *types.WorkObject) error
+}
+
+// stateSyncer downloads the EVM, UTXO and ETX tries of a recent pivot block of
+// the zone through trie node requests. While it runs the zone appends blocks
+// without processing them. Whenever the head moves too far past the pivot, the
+// pivot is moved forward and only the trie nodes missing from the new state are
+// downloaded. Once the tries are complete the zone processes the blocks
+// appended on top of the pivot and switches back to full processing.
+type stateSyncer struct {
+	nodeLocation common.Location
+	p2pBackend   NetworkingAPI
+	core         stateSyncCore
+	downloader   *downloader
+	quitCh       chan struct{}
+	logger       *log.Logger
+
+	progressLock sync.RWMutex
+	pulled       uint64 // number of state entries downloaded
+	known        uint64 // number of state entries known to be required
+}
+
+func newStateSyncer(p2pBackend NetworkingAPI, core stateSyncCore, downloader *downloader, nodeLocation common.Location, quitCh chan struct{}, logger *log.Logger) *stateSyncer {
+	pulled := core.StateSyncProgress()
+	return &stateSyncer{
+		nodeLocation: nodeLocation,
+		p2pBackend:   p2pBackend,
+		core:         core,
+		downloader:   downloader,
+		quitCh:       quitCh,
+		logger:       logger,
+		pulled:       pulled,
+		known:        pulled,
+	}
+}
+
+// Progress returns the number of pulled and known state entries
+func (s *stateSyncer) Progress() (uint64, uint64) {
+	s.progressLock.RLock()
+	defer s.progressLock.RUnlock()
+	return s.pulled, s.known
+}
+
+// run downloads the state until it is complete or the node stops. An error is
+// returned if the blocks on top of the synced pivot fail to process, in which
+// case the chain is still state syncing and the sync has to be run again.
+func (s *stateSyncer) run() error {
+	nodeCtx := s.nodeLocation.Context()
+	for {
+		pivot := s.pickPivot()
+		if pivot == nil {
+			if !s.wait() {
+				return nil
+			}
+			continue
+		}
+		s.logger.WithFields(log.Fields{
+			"number": pivot.NumberU64(nodeCtx),
+			"hash":   pivot.Hash(),
+		}).Info("Starting state sync")
+
+		complete := s.syncState(pivot)
+		if s.stopped() {
+			return nil
+		}
+		if !complete {
+			// The pivot went stale, heal the tries from a more recent pivot
+			continue
+		}
+		if s.core.GetCanonicalHash(pivot.NumberU64(nodeCtx)) != pivot.Hash() {
+			s.logger.WithField("hash", pivot.Hash()).Warn("State sync pivot was reorged, picking a new one")
+			continue
+		}
+		if err := s.core.CompleteStateSync(pivot); err != nil {
+			return err
+		}
+		s.logger.WithFields(log.Fields{
+			"number": pivot.NumberU64(nodeCtx),
+			"hash":   pivot.Hash(),
+			"states": s.pulled,
+		}).Info("State sync complete, switching to full processing")
+		return nil
+	}
+}
+
+// pickPivot returns the canonical block c_pivotDistance behind the head, once
+// the headers-first sync has caught up with the peers
+func (s *stateSyncer) pickPivot() *types.WorkObject {
+	progress := s.downloader.Progress()
+	if progress.HighestBlock > progress.CurrentBlock+c_pivotDistance {
+		return nil
+	}
+	head := s.core.CurrentHeader().NumberU64(s.nodeLocation.Context())
+	if head <= c_pivotDistance {
+		return nil
+	}
+	pivot := s.core.GetBlockByNumber(head - c_pivotDistance)
+	if pivot == nil {
+		return nil
+	}
+	s.core.SetStateSyncPivot(pivot)
+	return pivot
+}
+
+// syncState downloads the tries of the pivot. It returns false if the download
+// was interrupted because the pivot went stale or the node stopped
+func (s *stateSyncer) syncState(pivot *types.WorkObject) bool {
+	for _, sched := range s.core.NewStateSync(pivot) {
+		var retry []common.Hash
+		for sched.Pending() > 0 {
+			if s.stopped() || s.pivotStale(pivot) {
+				return false
+			}
+			nodes, _, codes := sched.Missing(c_trieNodeBatchSize)
+			hashes := append(append(retry, nodes...), codes...)
+			if len(hashes) == 0 {
+				break
+			}
+			results := s.fetchTrieNodes(hashes)
+
+			retry = nil
+			var pulled uint64
+			for i, hash := range hashes {
+				if results[i] == nil {
+					retry = append(retry, hash)
+					continue
+				}
+				err := sched.Process(trie.SyncResult{Hash: hash, Data: results[i]})
+				switch err {
+				case nil:
+					pulled++
+				case trie.ErrAlreadyProcessed, trie.ErrNotRequested:
+					// Delivered twice, nothing left to do
+				default:
+					s.logger.WithFields(log.Fields{
+						"hash": hash,
+						"err":  err,
+					}).Warn("Invalid trie node received")
+					retry = append(retry, hash)
+				}
+			}
+			s.progressLock.Lock()
+			s.pulled += pulled
+			s.known = s.pulled + uint64(sched.Pending())
+			total := s.pulled
+			s.progressLock.Unlock()
+
+			if err := s.core.CommitStateSync(sched, total); err != nil {
+				s.logger.WithField("err", err).Error("Error writing the downloaded trie nodes")
+				return false
+			}
+			if pulled == 0 {
+				// None of the peers could serve the trie nodes, give them some time
+				if !s.wait() {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// fetchTrieNodes requests the trie nodes from the peers in parallel. Answers
+// which don't hash to the requested node are dropped, the result of a node that
+// could not be downloaded is nil
+func (s *stateSyncer) fetchTrieNodes(hashes []common.Hash) [][]byte {
+	results := make([][]byte, len(hashes))
+	fetchers := make(chan struct{}, c_trieNodeFetchers)
+	var wg sync.WaitGroup
+	for i, hash := range hashes {
+		fetchers <- struct{}{}
+		wg.Add(1)
+		go func(i int, hash common.Hash) {
+			defer wg.Done()
+			defer func() { <-fetchers }()
+			defer func() {
+				if r := recover(); r != nil {
+					s.logger.WithFields(log.Fields{
+						"error":      r,
+						"stacktrace": string(debug.Stack()),
+					}).Fatal("Go-Quai Panicked")
+				}
+			}()
+			resultCh := s.p2pBackend.Request(s.nodeLocation, hash, trie.TrieNodeRequest{})
+			for result := range resultCh {
+				trieNode, ok := result.(*trie.TrieNodeResponse)
+				if !ok || trieNode == nil {
+					continue
+				}
+				if crypto.Keccak256Hash(trieNode.NodeData) != hash {
+					s.logger.WithField("hash", hash).Warn("Invalid trie node received")
+					continue
+				}
+				results[i] = trieNode.NodeData
+				return
+			}
+		}(i, hash)
+	}
+	wg.Wait()
+	return results
+}
+
+// pivotStale reports whether the head moved too far past the pivot
+func (s *stateSyncer) pivotStale(pivot *types.WorkObject) bool {
+	nodeCtx := s.nodeLocation.Context()
+	return s.core.CurrentHeader().NumberU64(nodeCtx) > pivot.NumberU64(nodeCtx)+c_pivotDistance+c_pivotStaleDistance
+}
+
+// wait waits for c_stateSyncRetryInterval and returns false if the node stopped
+func (s *stateSyncer) wait() bool {
+	select {
+	case <-time.After(c_stateSyncRetryInterval):
+		return true
+	case <-s.quitCh:
+		return false
+	}
+}
+
+func (s *stateSyncer) stopped() bool {
+	select {
+	case <-s.quitCh:
+		return true
+	default:
+		return false
+	}
+}
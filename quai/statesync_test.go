@@ -0,0 +1,187 @@
+package quai
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/crypto"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/ethdb/memorydb"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/trie"
+)
+
+// testStateSyncCore downloads the tries of the pivot into a database, the
+// pivot is the only block of the chain below the head
+type testStateSyncCore struct {
+	head     *types.WorkObject
+	pivot    *types.WorkObject
+	db       ethdb.KeyValueStore
+	complete error
+
+	completed *types.WorkObject
+}
+
+func (c *testStateSyncCore) CurrentHeader() *types.WorkObject { return c.head }
+func (c *testStateSyncCore) StateSyncProgress() uint64        { return 0 }
+func (c *testStateSyncCore) SetStateSyncPivot(*types.WorkObject) {
+}
+func (c *testStateSyncCore) GetBlockByNumber(number uint64) *types.WorkObject {
+	if number == c.pivot.NumberU64(common.ZONE_CTX) {
+		return c.pivot
+	}
+	return nil
+}
+func (c *testStateSyncCore) GetCanonicalHash(number uint64) common.Hash {
+	if number == c.pivot.NumberU64(common.ZONE_CTX) {
+		return c.pivot.Hash()
+	}
+	return common.Hash{}
+}
+func (c *testStateSyncCore) NewStateSync(block *types.WorkObject) []*trie.Sync {
+	return []*trie.Sync{
+		state.NewStateSync(block.EVMRoot(), c.db, nil, nil),
+		trie.NewSync(block.UTXORoot(), c.db, nil, nil),
+		trie.NewSync(block.EtxSetRoot(), c.db, nil, nil),
+	}
+}
+func (c *testStateSyncCore) CommitStateSync(sched *trie.Sync, pulled uint64) error {
+	batch := c.db.NewBatch()
+	if err := sched.Commit(batch); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+func (c *testStateSyncCore) CompleteStateSync(pivot *types.WorkObject) error {
+	c.completed = pivot
+	return c.complete
+}
+
+// testTrieNodePeer serves the trie nodes of a database. The first answer for a
+// node other than the root is a node of the trie stored under another hash.
+type testTrieNodePeer struct {
+	NetworkingAPI
+	triedb *trie.Database
+	root   common.Hash
+
+	lock sync.Mutex
+	bad  common.Hash
+}
+
+func (p *testTrieNodePeer) Request(location common.Location, requestData interface{}, responseDataType interface{}) chan interface{} {
+	hash := requestData.(common.Hash)
+	resultCh := make(chan interface{}, 1)
+	defer close(resultCh)
+
+	data, err := p.triedb.Node(hash)
+	if err != nil {
+		return resultCh
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.bad == (common.Hash{}) && hash != p.root {
+		p.bad = hash
+		data, _ = p.triedb.Node(p.root)
+	}
+	resultCh <- &trie.TrieNodeResponse{NodeData: data}
+	return resultCh
+}
+
+// makeTestUtxoTrie returns a trie database holding a trie of a few hundred
+// entries, spread so that the root node is a full branch
+func makeTestUtxoTrie(t *testing.T) (*trie.Database, common.Hash, map[string][]byte) {
+	triedb := trie.NewDatabase(memorydb.New(log.Global))
+	tr, err := trie.New(common.Hash{}, triedb)
+	if err != nil {
+		t.Fatalf("failed to create trie: %v", err)
+	}
+	content := make(map[string][]byte)
+	for i := 0; i < 256; i++ {
+		key, val := crypto.Keccak256([]byte{byte(i)}), []byte{byte(i)}
+		content[string(key)] = val
+		tr.Update(key, val)
+	}
+	root, err := tr.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if err := triedb.Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to write trie: %v", err)
+	}
+	return triedb, root, content
+}
+
+func newTestStateSyncer(t *testing.T, complete error) (*stateSyncer, *testStateSyncCore, *testTrieNodePeer, map[string][]byte) {
+	triedb, root, content := makeTestUtxoTrie(t)
+
+	makeBlock := func(number int64) *types.WorkObject {
+		block := types.EmptyHeader(common.ZONE_CTX)
+		block.Header().SetUTXORoot(root)
+		block.WorkObjectHeader().SetLocation(common.Location{0, 0})
+		block.WorkObjectHeader().SetNumber(big.NewInt(number))
+		block.WorkObjectHeader().SetHeaderHash(block.Header().Hash())
+		return block
+	}
+	core := &testStateSyncCore{
+		head:     makeBlock(c_pivotDistance + 1),
+		pivot:    makeBlock(1),
+		db:       memorydb.New(log.Global),
+		complete: complete,
+	}
+	peer := &testTrieNodePeer{triedb: triedb, root: root}
+	location := common.Location{0, 0}
+	downloader := newDownloader(peer, &testDownloaderCore{head: core.head}, location, make(chan struct{}), log.Global)
+	return newStateSyncer(peer, core, downloader, location, make(chan struct{}), log.Global), core, peer, content
+}
+
+func TestStateSync(t *testing.T) {
+	syncer, core, peer, content := newTestStateSyncer(t, nil)
+	if err := syncer.run(); err != nil {
+		t.Fatalf("failed to sync state: %v", err)
+	}
+	if core.completed != core.pivot {
+		t.Fatalf("state sync of the pivot was not completed")
+	}
+	// The bad node was dropped and downloaded again
+	if peer.bad == (common.Hash{}) {
+		t.Fatalf("no bad node was served")
+	}
+	if data, _ := core.db.Get(peer.bad[:]); data == nil {
+		t.Errorf("node %x missing from the synced trie", peer.bad)
+	}
+	tr, err := trie.New(core.pivot.UTXORoot(), trie.NewDatabase(core.db))
+	if err != nil {
+		t.Fatalf("failed to open the synced trie: %v", err)
+	}
+	entries := 0
+	for it := trie.NewIterator(tr.NodeIterator(nil)); it.Next(); entries++ {
+		if want := content[string(it.Key)]; !bytes.Equal(it.Value, want) {
+			t.Errorf("entry %x mismatch: have %x, want %x", it.Key, it.Value, want)
+		}
+	}
+	if entries != len(content) {
+		t.Errorf("synced entries mismatch: have %d, want %d", entries, len(content))
+	}
+	if pulled, known := syncer.Progress(); pulled == 0 || pulled != known {
+		t.Errorf("progress mismatch: pulled %d, known %d", pulled, known)
+	}
+}
+
+func TestStateSyncCompleteFailed(t *testing.T) {
+	// The blocks on top of the pivot fail to process, the error is returned so
+	// that the sync is run again
+	errProcess := errors.New("failed to process")
+	syncer, core, _, _ := newTestStateSyncer(t, errProcess)
+	if err := syncer.run(); err != errProcess {
+		t.Fatalf("state sync error mismatch: have %v, want %v", err, errProcess)
+	}
+	if core.completed != core.pivot {
+		t.Fatalf("state sync of the pivot was not completed")
+	}
+}
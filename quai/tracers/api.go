@@ -0,0 +1,542 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"runtime/debug"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/consensus"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
+	"github.com/dominant-strategies/go-quai/core"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/core/vm"
+	"github.com/dominant-strategies/go-quai/ethdb"
+	"github.com/dominant-strategies/go-quai/internal/quaiapi"
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/params"
+	"github.com/dominant-strategies/go-quai/rpc"
+)
+
+const (
+	// defaultTraceTimeout is the amount of time a single transaction can execute
+	// by default before being forcefully aborted.
+	defaultTraceTimeout = 5 * time.Second
+
+	// defaultTraceReexec is the number of blocks the tracer is willing to go back
+	// and reexecute to produce missing historical state necessary to run a specific
+	// trace.
+	defaultTraceReexec = uint64(128)
+)
+
+// Backend interface provides the common API services (that are provided by
+// both full and light clients) with access to necessary functions.
+type Backend interface {
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.WorkObject, error)
+	HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.WorkObject, error)
+	BlockByHash(ctx context.Context, hash common.Hash) (*types.WorkObject, error)
+	BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.WorkObject, error)
+	GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error)
+	RPCGasCap() uint64
+	ChainConfig() *params.ChainConfig
+	Engine() consensus.Engine
+	ChainDb() ethdb.Database
+	ChainContext() core.ChainContext
+	NodeLocation() common.Location
+	NodeCtx() int
+	Logger() *log.Logger
+	GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.WorkObject, vmConfig *vm.Config) (*vm.EVM, func() error, error)
+	StateAtBlock(ctx context.Context, block *types.WorkObject, reexec uint64, base *state.StateDB, checkLive bool) (*state.StateDB, error)
+	StateAtTransaction(ctx context.Context, block *types.WorkObject, txIndex int, reexec uint64) (core.Message, vm.BlockContext, *state.StateDB, error)
+}
+
+// API is the collection of tracing APIs exposed over the private debugging endpoint.
+type API struct {
+	backend Backend
+}
+
+// NewAPI creates a new API definition for the tracing methods of the Quai service.
+func NewAPI(backend Backend) *API {
+	return &API{backend: backend}
+}
+
+// blockByNumber is the wrapper of the chain access function offered by the backend.
+// It will return an error if the block is not found.
+func (api *API) blockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.WorkObject, error) {
+	block, err := api.backend.BlockByNumber(ctx, number)
+	if err != nil || block == nil {
+		return nil, fmt.Errorf("block #%d not found", number)
+	}
+	return block, nil
+}
+
+// blockByHash is the wrapper of the chain access function offered by the backend.
+// It will return an error if the block is not found.
+func (api *API) blockByHash(ctx context.Context, hash common.Hash) (*types.WorkObject, error) {
+	block, err := api.backend.BlockByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %s not found", hash.Hex())
+	}
+	return block, nil
+}
+
+// blockByNumberAndHash is the wrapper of the chain access function offered by
+// the backend. It will return an error if the block is not found.
+//
+// Note this function is friendly for the light client which can only retrieve the
+// historical(before the CHT) header/block by number.
+func (api *API) blockByNumberAndHash(ctx context.Context, number rpc.BlockNumber, hash common.Hash) (*types.WorkObject, error) {
+	block, err := api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if block.Hash() == hash {
+		return block, nil
+	}
+	return api.blockByHash(ctx, hash)
+}
+
+// TraceConfig holds extra parameters to trace functions.
+type TraceConfig struct {
+	*vm.LogConfig
+	Tracer  *string
+	Timeout *string
+	Reexec  *uint64
+}
+
+// TraceCallConfig is the config for traceCall API. It holds one more
+// field to override the state for tracing.
+type TraceCallConfig struct {
+	*vm.LogConfig
+	Tracer         *string
+	Timeout        *string
+	Reexec         *uint64
+	StateOverrides *quaiapi.StateOverride
+}
+
+// txTraceResult is the result of a single transaction trace.
+type txTraceResult struct {
+	Result interface{} `json:"result,omitempty"` // Trace results produced by the tracer
+	Error  string      `json:"error,omitempty"`  // Trace failure produced by the tracer
+}
+
+// blockTraceResult represents the results of tracing a single block when an entire
+// chain is being traced.
+type blockTraceResult struct {
+	Block  hexutil.Uint64   `json:"block"`  // Block number corresponding to this trace
+	Hash   common.Hash      `json:"hash"`   // Block hash corresponding to this trace
+	Traces []*txTraceResult `json:"traces"` // Trace results produced by the task
+}
+
+// reexec returns the number of blocks the tracer may reexecute to regenerate
+// missing historical state.
+func (config *TraceConfig) reexec() uint64 {
+	if config != nil && config.Reexec != nil {
+		return *config.Reexec
+	}
+	return defaultTraceReexec
+}
+
+// TraceChain returns the structured logs created during the execution of EVM
+// between two blocks (excluding start) and returns them as a JSON object.
+func (api *API) TraceChain(ctx context.Context, start, end rpc.BlockNumber, config *TraceConfig) (*rpc.Subscription, error) {
+	from, err := api.blockByNumber(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	to, err := api.blockByNumber(ctx, end)
+	if err != nil {
+		return nil, err
+	}
+	nodeCtx := api.backend.NodeCtx()
+	if from.NumberU64(nodeCtx) >= to.NumberU64(nodeCtx) {
+		return nil, fmt.Errorf("end block (#%d) needs to come after start block (#%d)", end, start)
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				api.backend.Logger().WithFields(log.Fields{
+					"error":      r,
+					"stacktrace": string(debug.Stack()),
+				}).Error("Go-Quai Panicked")
+			}
+		}()
+		begin := time.Now()
+		for number := from.NumberU64(nodeCtx) + 1; number <= to.NumberU64(nodeCtx); number++ {
+			select {
+			case <-notifier.Closed():
+				return
+			case <-sub.Err():
+				return
+			default:
+			}
+			block, err := api.blockByNumber(context.Background(), rpc.BlockNumber(number))
+			if err != nil {
+				api.backend.Logger().WithField("err", err).Warn("Chain tracing failed")
+				return
+			}
+			traces, err := api.traceBlock(context.Background(), block, config)
+			if err != nil {
+				api.backend.Logger().WithFields(log.Fields{
+					"number": number,
+					"err":    err,
+				}).Warn("Chain tracing failed")
+				return
+			}
+			notifier.Notify(sub.ID, &blockTraceResult{
+				Block:  hexutil.Uint64(number),
+				Hash:   block.Hash(),
+				Traces: traces,
+			})
+		}
+		api.backend.Logger().WithFields(log.Fields{
+			"start":   from.NumberU64(nodeCtx),
+			"end":     to.NumberU64(nodeCtx),
+			"elapsed": common.PrettyDuration(time.Since(begin)),
+		}).Info("Chain tracing finished")
+	}()
+	return sub, nil
+}
+
+// TraceBlockByNumber returns the structured logs created during the execution of
+// EVM and returns them as a JSON object.
+func (api *API) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceConfig) ([]*txTraceResult, error) {
+	block, err := api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return api.traceBlock(ctx, block, config)
+}
+
+// TraceBlockByHash returns the structured logs created during the execution of
+// EVM and returns them as a JSON object.
+func (api *API) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceConfig) ([]*txTraceResult, error) {
+	block, err := api.blockByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	return api.traceBlock(ctx, block, config)
+}
+
+// TraceTransaction returns the structured logs created during the execution of EVM
+// and returns them as a JSON object.
+func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *TraceConfig) (interface{}, error) {
+	tx, blockHash, blockNumber, index, err := api.backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	// It shouldn't happen in practice.
+	if blockNumber == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	block, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(blockNumber), blockHash)
+	if err != nil {
+		return nil, err
+	}
+	msg, vmctx, statedb, err := api.backend.StateAtTransaction(ctx, block, int(index), config.reexec())
+	if err != nil {
+		return nil, err
+	}
+	// Coinbase, Qi and Qi ledger ETX entries are not run through the EVM
+	if msg == nil {
+		return api.traceUtxoTx(ctx, block, int(index), tx, statedb)
+	}
+	txctx := &Context{
+		BlockHash: blockHash,
+		TxIndex:   int(index),
+		TxHash:    hash,
+	}
+	return api.traceTx(ctx, msg, txctx, vmctx, statedb, config)
+}
+
+// TraceCall lets you trace a given quai_call. It collects the structured logs
+// created during the execution of EVM if the given transaction was added on
+// top of the provided block and returns them as a JSON object.
+func (api *API) TraceCall(ctx context.Context, args quaiapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallConfig) (interface{}, error) {
+	if api.backend.NodeCtx() != common.ZONE_CTX {
+		return nil, errors.New("traceCall can only be called in zone chain")
+	}
+	// Try to retrieve the specified block
+	var (
+		err   error
+		block *types.WorkObject
+	)
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		block, err = api.blockByHash(ctx, hash)
+	} else if number, ok := blockNrOrHash.Number(); ok {
+		block, err = api.blockByNumber(ctx, number)
+	} else {
+		return nil, errors.New("invalid arguments; neither block nor hash specified")
+	}
+	if err != nil {
+		return nil, err
+	}
+	// try to recompute the state
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	statedb, err := api.backend.StateAtBlock(ctx, block, reexec, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	// Apply the customized state rules if required.
+	if config != nil {
+		if err := config.StateOverrides.Apply(statedb, api.backend.NodeLocation()); err != nil {
+			return nil, err
+		}
+	}
+	// Reset to and from in case of type unmarshal error
+	nodeLocation := api.backend.NodeLocation()
+	if args.To != nil {
+		to := common.BytesToAddress(args.To.Bytes(), nodeLocation)
+		args.To = &to
+	}
+	if args.From != nil {
+		from := common.BytesToAddress(args.From.Bytes(), nodeLocation)
+		args.From = &from
+		if args.Nonce == nil {
+			if internal, err := from.InternalAndQuaiAddress(); err == nil {
+				nonce := statedb.GetNonce(internal)
+				args.Nonce = (*hexutil.Uint64)(&nonce)
+			}
+		}
+	}
+	// Execute the trace
+	msg, err := args.ToMessage(api.backend.RPCGasCap(), block.BaseFee(), nodeLocation)
+	if err != nil {
+		return nil, err
+	}
+	evm, _, err := api.backend.GetEVM(ctx, msg, statedb, block, &vm.Config{NoBaseFee: true})
+	if err != nil {
+		return nil, err
+	}
+	var traceConfig *TraceConfig
+	if config != nil {
+		traceConfig = &TraceConfig{
+			LogConfig: config.LogConfig,
+			Tracer:    config.Tracer,
+			Timeout:   config.Timeout,
+			Reexec:    config.Reexec,
+		}
+	}
+	return api.traceTx(ctx, msg, new(Context), evm.Context, statedb, traceConfig)
+}
+
+// traceBlock configures a new tracer according to the provided configuration, and
+// executes all the transactions contained within. The return value will be one item
+// per transaction, dependent on the requested tracer.
+func (api *API) traceBlock(ctx context.Context, block *types.WorkObject, config *TraceConfig) ([]*txTraceResult, error) {
+	nodeCtx := api.backend.NodeCtx()
+	if block.NumberU64(nodeCtx) == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	txs := block.Transactions()
+	if len(txs) == 0 {
+		return []*txTraceResult{}, nil
+	}
+	// The state before the first transaction is the post state of the parent
+	_, vmctx, statedb, err := api.backend.StateAtTransaction(ctx, block, 0, config.reexec())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		signer  = types.MakeSigner(api.backend.ChainConfig(), block.Number(nodeCtx))
+		results = make([]*txTraceResult, len(txs))
+	)
+	for i, tx := range txs {
+		result, err := api.traceBlockTx(ctx, block, i, tx, signer, vmctx, statedb, config)
+		if err != nil {
+			results[i] = &txTraceResult{Error: err.Error()}
+		} else {
+			results[i] = &txTraceResult{Result: result}
+		}
+		// Finalize the state so any modifications are written to the trie
+		statedb.Finalise(true)
+	}
+	return results, nil
+}
+
+// traceBlockTx traces a single transaction of a block on top of the running
+// block state, advancing the state past the transaction.
+func (api *API) traceBlockTx(ctx context.Context, block *types.WorkObject, index int, tx *types.Transaction, signer types.Signer, vmctx vm.BlockContext, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
+	var (
+		nodeCtx      = api.backend.NodeCtx()
+		nodeLocation = api.backend.NodeLocation()
+	)
+	if index == 0 && types.IsCoinBaseTx(tx, block.ParentHash(nodeCtx), nodeLocation) {
+		return api.traceUtxoTx(ctx, block, index, tx, statedb)
+	}
+	if tx.Type() == types.QiTxType || (tx.Type() == types.ExternalTxType && tx.To().IsInQiLedgerScope()) {
+		result, err := api.traceUtxoTx(ctx, block, index, tx, statedb)
+		if err != nil {
+			return nil, err
+		}
+		if err := api.applyUtxoTx(ctx, block, tx, statedb); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	msg, err := tx.AsMessage(signer, block.BaseFee())
+	if err != nil {
+		return nil, err
+	}
+	if tx.Type() == types.ExternalTxType {
+		prevZeroBal, err := api.prepareEtx(ctx, block, tx, &msg, statedb)
+		if err != nil {
+			return nil, err
+		}
+		defer statedb.SetBalance(common.ZeroInternal(nodeLocation), prevZeroBal)
+	}
+	txctx := &Context{
+		BlockHash: block.Hash(),
+		TxIndex:   index,
+		TxHash:    tx.Hash(),
+	}
+	return api.traceTx(ctx, msg, txctx, vmctx, statedb, config)
+}
+
+// prepareEtx adjusts the message of an ETX executed by the EVM the same way
+// the state processor does and funds the zero address that acts as its
+// sender. It returns the previous balance of the zero address, which has to
+// be restored once the ETX has been applied.
+func (api *API) prepareEtx(ctx context.Context, block *types.WorkObject, tx *types.Transaction, msg *types.Message, statedb *state.StateDB) (*big.Int, error) {
+	var (
+		nodeCtx      = api.backend.NodeCtx()
+		nodeLocation = api.backend.NodeLocation()
+	)
+	if tx.ETXSender().Location().Equal(*tx.To().Location()) { // Qi->Quai Conversion
+		primeTerminus, err := api.backend.HeaderByHash(ctx, block.PrimeTerminus())
+		if err != nil {
+			return nil, err
+		}
+		if primeTerminus == nil {
+			return nil, fmt.Errorf("could not find prime terminus header %032x", block.PrimeTerminus())
+		}
+		msg.SetLock(new(big.Int).Add(block.Number(nodeCtx), big.NewInt(params.ConversionLockPeriod)))
+		msg.SetValue(misc.QiToQuai(primeTerminus, tx.Value()))
+		msg.SetData([]byte{})
+	}
+	zero := common.ZeroInternal(nodeLocation)
+	prevZeroBal := statedb.GetBalance(zero)
+	statedb.SetBalance(zero, msg.Value())
+	return prevZeroBal, nil
+}
+
+// traceTx configures a new tracer according to the provided configuration, and
+// executes the given message in the provided environment. The return value will
+// be tracer dependent.
+func (api *API) traceTx(ctx context.Context, message core.Message, txctx *Context, vmctx vm.BlockContext, statedb *state.StateDB, config *TraceConfig) (interface{}, error) {
+	// Assemble the structured logger or the JavaScript tracer
+	var (
+		tracer    vm.Tracer
+		err       error
+		txContext = core.NewEVMTxContext(message)
+	)
+	switch {
+	case config == nil:
+		tracer = vm.NewStructLogger(nil)
+	case config.Tracer != nil:
+		// Define a meaningful timeout of a single transaction trace
+		timeout := defaultTraceTimeout
+		if config.Timeout != nil {
+			if timeout, err = time.ParseDuration(*config.Timeout); err != nil {
+				return nil, err
+			}
+		}
+		t, err := New(*config.Tracer, txctx)
+		if err != nil {
+			return nil, err
+		}
+		if ts, ok := t.(txStartTracer); ok {
+			ts.CaptureTxStart(message.Gas())
+		}
+		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
+		go func() {
+			<-deadlineCtx.Done()
+			if errors.Is(deadlineCtx.Err(), context.DeadlineExceeded) {
+				t.Stop(errors.New("execution timeout"))
+			}
+		}()
+		defer cancel()
+		tracer = t
+	default:
+		tracer = vm.NewStructLogger(config.LogConfig)
+	}
+	// Run the transaction with tracing enabled.
+	vmenv := vm.NewEVM(vmctx, txContext, statedb, api.backend.ChainConfig(), vm.Config{Debug: true, Tracer: tracer, NoBaseFee: true})
+
+	// Call Prepare to clear out the statedb access list
+	statedb.Prepare(txctx.TxHash, txctx.TxIndex)
+
+	result, err := core.ApplyMessage(vmenv, message, new(types.GasPool).AddGas(message.Gas()))
+	if err != nil {
+		return nil, fmt.Errorf("tracing failed: %w", err)
+	}
+
+	// Depending on the tracer type, format and return the output.
+	switch tracer := tracer.(type) {
+	case *vm.StructLogger:
+		// If the result contains a revert reason, return it.
+		returnVal := fmt.Sprintf("%x", result.Return())
+		if len(result.Revert()) > 0 {
+			returnVal = fmt.Sprintf("%x", result.Revert())
+		}
+		return &quaiapi.ExecutionResult{
+			Gas:         result.UsedGas,
+			Failed:      result.Failed(),
+			ReturnValue: returnVal,
+			StructLogs:  quaiapi.FormatLogs(tracer.StructLogs()),
+			Etxs:        result.Etxs,
+		}, nil
+
+	case Tracer:
+		return tracer.GetResult()
+
+	default:
+		panic(fmt.Sprintf("bad tracer type %T", tracer))
+	}
+}
+
+// APIs return the collection of RPC services the tracer package offers.
+func APIs(backend Backend) []rpc.API {
+	// Append all the local APIs and return
+	return []rpc.API{
+		{
+			Namespace: "debug",
+			Version:   "1.0",
+			Service:   NewAPI(backend),
+			Public:    false,
+		},
+	}
+}
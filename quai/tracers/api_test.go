@@ -23,16 +23,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"reflect"
 	"sort"
 	"testing"
-	"time"
 
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/common/hexutil"
 	"github.com/dominant-strategies/go-quai/consensus"
 	"github.com/dominant-strategies/go-quai/consensus/blake3pow"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
 	"github.com/dominant-strategies/go-quai/core"
 	"github.com/dominant-strategies/go-quai/core/rawdb"
 	"github.com/dominant-strategies/go-quai/core/state"
@@ -41,6 +42,7 @@ import (
 	"github.com/dominant-strategies/go-quai/crypto"
 	"github.com/dominant-strategies/go-quai/ethdb"
 	"github.com/dominant-strategies/go-quai/internal/quaiapi"
+	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/params"
 	"github.com/dominant-strategies/go-quai/rpc"
 )
@@ -51,75 +53,263 @@ var (
 	errTransactionNotFound = errors.New("transaction not found")
 )
 
+var (
+	testLocation       = common.Location{0, 0}
+	testCoinbase       = common.BytesToAddress([]byte{0x00, 0x01}, testLocation)
+	testCoinbaseKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+)
+
+// txLookup is the position of a transaction in the test chain.
+type txLookup struct {
+	blockHash common.Hash
+	number    uint64
+	index     uint64
+}
+
+// testBackend is a chain of zone blocks built in memory, which serves both as
+// the tracing backend and as the chain context the blocks are executed in.
 type testBackend struct {
 	chainConfig *params.ChainConfig
 	engine      consensus.Engine
 	chaindb     ethdb.Database
-	chain       *core.BlockChain
+	stateDb     state.Database
+	utxoDb      state.Database
+	etxDb       state.Database
+	terminus    *types.WorkObject // prime terminus of every block
+	blocks      []*types.WorkObject
+	txs         map[common.Hash]txLookup
+}
+
+// blockGen is handed to the generator of a test chain to fill a block.
+type blockGen struct {
+	t       *testing.T
+	backend *testBackend
+	header  *types.WorkObject
+	statedb *state.StateDB
+	gp      *types.GasPool
+	usedGas uint64
+	txs     []*types.Transaction
 }
 
-func newTestBackend(t *testing.T, n int, gspec *core.Genesis, generator func(i int, b *core.BlockGen)) *testBackend {
+// BaseFee returns the base fee of the block being generated.
+func (b *blockGen) BaseFee() *big.Int {
+	return new(big.Int).Set(b.header.BaseFee())
+}
+
+// Number returns the number of the block being generated.
+func (b *blockGen) Number() *big.Int {
+	return b.header.Number(testLocation.Context())
+}
+
+// AddTx executes the transaction on top of the block state and appends it to
+// the block.
+func (b *blockGen) AddTx(tx *types.Transaction) {
+	b.t.Helper()
+	if err := b.backend.applyTx(b.header, b.statedb, len(b.txs), tx, b.gp, &b.usedGas); err != nil {
+		b.t.Fatalf("block %d: failed to apply transaction %d: %v", b.Number(), len(b.txs), err)
+	}
+	b.txs = append(b.txs, tx)
+}
+
+// newTestChainConfig returns the chain config of the zone the test blocks are
+// built in.
+func newTestChainConfig() *params.ChainConfig {
+	config := *params.TestChainConfig
+	config.Location = testLocation
+	return &config
+}
+
+// newTestBackend builds a genesis block with the state set up by genesis and
+// n blocks on top of it, filled by the generator.
+func newTestBackend(t *testing.T, n int, genesis func(statedb *state.StateDB), generator func(i int, b *blockGen)) *testBackend {
+	db := rawdb.NewMemoryDatabase(log.Global)
 	backend := &testBackend{
-		chainConfig: params.TestChainConfig,
+		chainConfig: newTestChainConfig(),
 		engine:      blake3pow.NewFaker(),
-		chaindb:     rawdb.NewMemoryDatabase(),
+		chaindb:     db,
+		stateDb:     state.NewDatabase(db),
+		utxoDb:      state.NewDatabase(db),
+		etxDb:       state.NewDatabase(db),
+		terminus:    types.EmptyHeader(common.PRIME_CTX),
+		txs:         make(map[common.Hash]txLookup),
 	}
-	// Generate blocks for testing
-	gspec.Config = backend.chainConfig
-	var (
-		gendb   = rawdb.NewMemoryDatabase()
-		genesis = gspec.MustCommit(gendb)
-	)
-	blocks, _ := core.GenerateChain(backend.chainConfig, genesis, backend.engine, gendb, n, generator)
-
-	// Import the canonical chain
-	gspec.MustCommit(backend.chaindb)
-	cacheConfig := &core.CacheConfig{
-		TrieCleanLimit:    256,
-		TrieDirtyLimit:    256,
-		TrieTimeLimit:     5 * time.Minute,
-		SnapshotLimit:     0,
-		TrieDirtyDisabled: true, // Archive mode
-	}
-	chain, err := core.NewBlockChain(backend.chaindb, cacheConfig, backend.chainConfig, backend.engine, vm.Config{}, nil, nil)
+	statedb, err := state.New(types.EmptyRootHash, types.EmptyRootHash, types.EmptyRootHash, backend.stateDb, backend.utxoDb, backend.etxDb, nil, testLocation, log.Global)
 	if err != nil {
-		t.Fatalf("failed to create tester chain: %v", err)
+		t.Fatalf("failed to create genesis state: %v", err)
+	}
+	if genesis != nil {
+		genesis(statedb)
 	}
-	if n, err := chain.InsertChain(blocks); err != nil {
-		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	backend.commit(t, backend.newHeader(), statedb, nil)
+
+	for i := 0; i < n; i++ {
+		parent := backend.blocks[len(backend.blocks)-1]
+		statedb, err := backend.stateAt(parent)
+		if err != nil {
+			t.Fatalf("failed to create state of block %d: %v", i+1, err)
+		}
+		gen := &blockGen{t: t, backend: backend, header: backend.newHeader(), statedb: statedb}
+		gen.gp = new(types.GasPool).AddGas(gen.header.GasLimit())
+		gen.txs = []*types.Transaction{backend.newCoinbaseTx(t, gen.header)}
+		if generator != nil {
+			generator(i, gen)
+		}
+		// The block reward is paid once all the transactions are applied
+		coinbase, _ := testCoinbase.InternalAndQuaiAddress()
+		statedb.AddBalance(coinbase, gen.txs[0].Value())
+		gen.header.Header().SetGasUsed(gen.usedGas)
+		backend.commit(t, gen.header, statedb, gen.txs)
 	}
-	backend.chain = chain
 	return backend
 }
 
-func (b *testBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
-	return b.chain.GetHeaderByHash(hash), nil
+// newHeader returns the header of the next block of the chain.
+func (b *testBackend) newHeader() *types.WorkObject {
+	nodeCtx := testLocation.Context()
+	header := types.EmptyHeader(nodeCtx)
+	if n := len(b.blocks); n > 0 {
+		parent := b.blocks[n-1]
+		header.SetParentHash(parent.Hash(), nodeCtx)
+		header.SetNumber(big.NewInt(int64(n)), nodeCtx)
+		header.WorkObjectHeader().SetTime(parent.Time() + 10)
+	}
+	header.WorkObjectHeader().SetLocation(testLocation)
+	header.Header().SetCoinbase(testCoinbase)
+	header.Header().SetPrimeTerminus(b.terminus.Hash())
+	header.Header().SetBaseFee(big.NewInt(params.GWei))
+	header.Header().SetGasLimit(params.GenesisGasLimit)
+	return header
 }
 
-func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
-	if number == rpc.PendingBlockNumber || number == rpc.LatestBlockNumber {
-		return b.chain.CurrentHeader(), nil
+// newCoinbaseTx returns the Quai coinbase transaction every block starts with.
+func (b *testBackend) newCoinbaseTx(t *testing.T, header *types.WorkObject) *types.Transaction {
+	tx, err := types.SignTx(types.NewTx(&types.QuaiTx{
+		ChainID: b.chainConfig.ChainID,
+		To:      &testCoinbase,
+		Value:   misc.CalculateReward(header),
+		Data:    common.Hex2Bytes("Quai block reward"),
+	}), types.LatestSigner(b.chainConfig), testCoinbaseKey)
+	if err != nil {
+		t.Fatalf("failed to sign coinbase transaction: %v", err)
+	}
+	return tx
+}
+
+// commit writes the state of the block, seals it and appends it to the chain.
+func (b *testBackend) commit(t *testing.T, header *types.WorkObject, statedb *state.StateDB, txs []*types.Transaction) {
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	utxoRoot, err := statedb.CommitUTXOs()
+	if err != nil {
+		t.Fatalf("failed to commit utxos: %v", err)
 	}
-	return b.chain.GetHeaderByNumber(uint64(number)), nil
+	etxRoot, err := statedb.CommitETXs()
+	if err != nil {
+		t.Fatalf("failed to commit etxs: %v", err)
+	}
+	header.Header().SetEVMRoot(root)
+	header.Header().SetUTXORoot(utxoRoot)
+	header.Header().SetEtxSetRoot(etxRoot)
+	header.Body().SetTransactions(txs)
+	header.WorkObjectHeader().SetHeaderHash(header.Header().Hash())
+
+	number := header.NumberU64(testLocation.Context())
+	for i, tx := range txs {
+		b.txs[tx.Hash()] = txLookup{blockHash: header.Hash(), number: number, index: uint64(i)}
+	}
+	b.blocks = append(b.blocks, header)
 }
 
-func (b *testBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
-	return b.chain.GetBlockByHash(hash), nil
+// stateAt returns the state at the end of the given block.
+func (b *testBackend) stateAt(block *types.WorkObject) (*state.StateDB, error) {
+	return state.New(block.EVMRoot(), block.UTXORoot(), block.EtxSetRoot(), b.stateDb, b.utxoDb, b.etxDb, nil, testLocation, log.Global)
+}
+
+// applyTx executes a transaction of the given block on top of its state the
+// way the state processor does.
+func (b *testBackend) applyTx(header *types.WorkObject, statedb *state.StateDB, index int, tx *types.Transaction, gp *types.GasPool, usedGas *uint64) error {
+	var (
+		nodeCtx  = testLocation.Context()
+		signer   = types.MakeSigner(b.chainConfig, header.Number(nodeCtx))
+		gasTable = b.chainConfig.GasTable(header.Number(nodeCtx), header.ExpansionNumber())
+	)
+	// Coinbase outputs are only added after all transactions are processed
+	if index == 0 && types.IsCoinBaseTx(tx, header.ParentHash(nodeCtx), testLocation) {
+		return nil
+	}
+	if tx.Type() == types.QiTxType {
+		etxRLimit, etxPLimit := math.MaxInt, math.MaxInt
+		_, _, err := core.ProcessQiTx(tx, b, true, false, header, statedb, gp, usedGas, signer, testLocation, *b.chainConfig.ChainID, gasTable, &etxRLimit, &etxPLimit)
+		return err
+	}
+	if tx.Type() == types.ExternalTxType && tx.To().IsInQiLedgerScope() {
+		if tx.ETXSender().Location().Equal(*tx.To().Location()) {
+			lock := new(big.Int).Add(header.Number(nodeCtx), big.NewInt(params.ConversionLockPeriod))
+			value := misc.QuaiToQi(b.terminus, tx.Value())
+			for i, denomination := range core.ConversionOutputs(value, tx.Gas(), gasTable) {
+				if err := statedb.CreateUTXO(tx.Hash(), uint16(i), types.NewUtxoEntry(types.NewTxOut(denomination, tx.To().Bytes(), lock))); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return statedb.CreateUTXO(tx.OriginatingTxHash(), tx.ETXIndex(), types.NewUtxoEntry(types.NewTxOut(uint8(tx.Value().Uint64()), tx.To().Bytes(), big.NewInt(0))))
+	}
+	msg, err := tx.AsMessage(signer, header.BaseFee())
+	if err != nil {
+		return err
+	}
+	context, err := core.NewEVMBlockContext(header, b, nil)
+	if err != nil {
+		return err
+	}
+	vmenv := vm.NewEVM(context, core.NewEVMTxContext(msg), statedb, b.chainConfig, vm.Config{})
+	statedb.Prepare(tx.Hash(), index)
+	result, err := core.ApplyMessage(vmenv, msg, gp)
+	if err != nil {
+		return err
+	}
+	*usedGas += result.UsedGas
+	statedb.Finalise(true)
+	return nil
+}
+
+func (b *testBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.WorkObject, error) {
+	return b.GetHeaderByHash(hash), nil
+}
+
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.WorkObject, error) {
+	return b.BlockByNumber(ctx, number)
+}
+
+func (b *testBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.WorkObject, error) {
+	for _, block := range b.blocks {
+		if block.Hash() == hash {
+			return block, nil
+		}
+	}
+	return nil, nil
 }
 
-func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+func (b *testBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.WorkObject, error) {
 	if number == rpc.PendingBlockNumber || number == rpc.LatestBlockNumber {
-		return b.chain.CurrentBlock(), nil
+		return b.blocks[len(b.blocks)-1], nil
+	}
+	if number < 0 || int(number) >= len(b.blocks) {
+		return nil, nil
 	}
-	return b.chain.GetBlockByNumber(uint64(number)), nil
+	return b.blocks[number], nil
 }
 
 func (b *testBackend) GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
-	tx, hash, blockNumber, index := rawdb.ReadTransaction(b.chaindb, txHash)
-	if tx == nil {
+	lookup, ok := b.txs[txHash]
+	if !ok {
 		return nil, common.Hash{}, 0, 0, errTransactionNotFound
 	}
-	return tx, hash, blockNumber, index, nil
+	tx := b.blocks[lookup.number].Transactions()[lookup.index]
+	return tx, lookup.blockHash, lookup.number, lookup.index, nil
 }
 
 func (b *testBackend) RPCGasCap() uint64 {
@@ -138,65 +328,146 @@ func (b *testBackend) ChainDb() ethdb.Database {
 	return b.chaindb
 }
 
-func (b *testBackend) StateAtBlock(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, checkLive bool) (*state.StateDB, error) {
-	statedb, err := b.chain.StateAt(block.EVMRoot(), block.UTXORoot())
+func (b *testBackend) ChainContext() core.ChainContext {
+	return b
+}
+
+func (b *testBackend) NodeLocation() common.Location {
+	return testLocation
+}
+
+func (b *testBackend) NodeCtx() int {
+	return testLocation.Context()
+}
+
+func (b *testBackend) Logger() *log.Logger {
+	return log.Global
+}
+
+func (b *testBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.WorkObject, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
+	vmError := func() error { return nil }
+	context, err := core.NewEVMBlockContext(header, b, nil)
+	if err != nil {
+		return nil, vmError, err
+	}
+	return vm.NewEVM(context, core.NewEVMTxContext(msg), state, b.chainConfig, *vmConfig), vmError, nil
+}
+
+func (b *testBackend) StateAtBlock(ctx context.Context, block *types.WorkObject, reexec uint64, base *state.StateDB, checkLive bool) (*state.StateDB, error) {
+	statedb, err := b.stateAt(block)
 	if err != nil {
 		return nil, errStateNotFound
 	}
 	return statedb, nil
 }
 
-func (b *testBackend) StateAtTransaction(ctx context.Context, block *types.Block, txIndex int, reexec uint64) (core.Message, vm.BlockContext, *state.StateDB, error) {
-	parent := b.chain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+func (b *testBackend) StateAtTransaction(ctx context.Context, block *types.WorkObject, txIndex int, reexec uint64) (core.Message, vm.BlockContext, *state.StateDB, error) {
+	nodeCtx := testLocation.Context()
+	parent := b.GetHeaderOrCandidate(block.ParentHash(nodeCtx), block.NumberU64(nodeCtx)-1)
 	if parent == nil {
 		return nil, vm.BlockContext{}, nil, errBlockNotFound
 	}
-	statedb, err := b.chain.StateAt(parent.EVMRoot(), parent.UTXORoot())
+	statedb, err := b.stateAt(parent)
 	if err != nil {
 		return nil, vm.BlockContext{}, nil, errStateNotFound
 	}
 	if txIndex == 0 && len(block.Transactions()) == 0 {
 		return nil, vm.BlockContext{}, statedb, nil
 	}
+	context, err := core.NewEVMBlockContext(block, b, nil)
+	if err != nil {
+		return nil, vm.BlockContext{}, nil, fmt.Errorf("failed to create block context: %v", err)
+	}
+	var (
+		signer  = types.MakeSigner(b.chainConfig, block.Number(nodeCtx))
+		gp      = new(types.GasPool).AddGas(block.GasLimit())
+		usedGas uint64
+	)
 	// Recompute transactions up to the target index.
-	signer := types.MakeSigner(b.chainConfig, block.Number())
 	for idx, tx := range block.Transactions() {
-		msg, _ := tx.AsMessage(signer, block.BaseFee())
-		txContext := core.NewEVMTxContext(msg)
-		context, err := core.NewEVMBlockContext(block.Header(), b.chain, nil)
-		if err != nil {
-			return nil, vm.BlockContext{}, nil, fmt.Errorf("failed to create block context: %v", err)
-		}
 		if idx == txIndex {
+			if idx == 0 && types.IsCoinBaseTx(tx, block.ParentHash(nodeCtx), testLocation) {
+				return nil, context, statedb, nil
+			}
+			if tx.Type() == types.QiTxType || (tx.Type() == types.ExternalTxType && tx.To().IsInQiLedgerScope()) {
+				return nil, context, statedb, nil
+			}
+			msg, err := tx.AsMessage(signer, block.BaseFee())
+			if err != nil {
+				return nil, vm.BlockContext{}, nil, err
+			}
 			return msg, context, statedb, nil
 		}
-		vmenv := vm.NewEVM(context, txContext, statedb, b.chainConfig, vm.Config{})
-		if _, err := core.ApplyMessage(vmenv, b.chain, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+		if err := b.applyTx(block, statedb, idx, tx, gp, &usedGas); err != nil {
 			return nil, vm.BlockContext{}, nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
 		}
-		statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
 	}
 	return nil, vm.BlockContext{}, nil, fmt.Errorf("transaction index %d out of range for block %#x", txIndex, block.Hash())
 }
 
+func (b *testBackend) GetHeaderOrCandidate(hash common.Hash, number uint64) *types.WorkObject {
+	if number >= uint64(len(b.blocks)) || b.blocks[number].Hash() != hash {
+		return nil
+	}
+	return b.blocks[number]
+}
+
+func (b *testBackend) IsGenesisHash(hash common.Hash) bool {
+	return b.blocks[0].Hash() == hash
+}
+
+func (b *testBackend) GetHeaderByHash(hash common.Hash) *types.WorkObject {
+	if hash == b.terminus.Hash() {
+		return b.terminus
+	}
+	block, _ := b.BlockByHash(context.Background(), hash)
+	return block
+}
+
+func (b *testBackend) CheckIfEtxIsEligible(etxEligibleSlices common.Hash, location common.Location) bool {
+	return true
+}
+
+// fundAccounts returns a genesis funding each account with one Quai.
+func fundAccounts(accounts Accounts) func(statedb *state.StateDB) {
+	return func(statedb *state.StateDB) {
+		for _, account := range accounts {
+			statedb.SetBalance(account.internal(), big.NewInt(params.Ether))
+		}
+	}
+}
+
+// newTransfer returns a transfer of 1000 wei from one account to another
+// paying the base fee of the block only.
+func newTransfer(b *blockGen, nonce uint64, from, to Account) *types.Transaction {
+	b.t.Helper()
+	tx, err := types.SignTx(types.NewTx(&types.QuaiTx{
+		ChainID:    b.backend.chainConfig.ChainID,
+		Nonce:      nonce,
+		To:         &to.addr,
+		Value:      big.NewInt(1000),
+		Gas:        params.TxGas,
+		GasFeeCap:  b.BaseFee(),
+		GasTipCap:  new(big.Int),
+		AccessList: types.AccessList{},
+	}), types.LatestSigner(b.backend.chainConfig), from.key)
+	if err != nil {
+		b.t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
 func TestTraceCall(t *testing.T) {
 	t.Parallel()
 
 	// Initialize test accounts
 	accounts := newAccounts(3)
-	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
-		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
-		accounts[1].addr: {Balance: big.NewInt(params.Ether)},
-		accounts[2].addr: {Balance: big.NewInt(params.Ether)},
-	}}
 	genBlocks := 10
-	signer := types.HomesteadSigner{}
-	api := NewAPI(newTestBackend(t, genBlocks, genesis, func(i int, b *core.BlockGen) {
+	api := NewAPI(newTestBackend(t, genBlocks, fundAccounts(accounts), func(i int, b *blockGen) {
 		// Transfer from account[0] to account[1]
 		//    value: 1000 wei
-		//    fee:   0 wei
-		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
-		b.AddTx(tx)
+		//    fee:   base fee
+		b.AddTx(newTransfer(b, uint64(i), accounts[0], accounts[1]))
 	}))
 
 	var testSuite = []struct {
@@ -221,6 +492,7 @@ func TestTraceCall(t *testing.T) {
 				Failed:      false,
 				ReturnValue: "",
 				StructLogs:  []quaiapi.StructLogRes{},
+				Etxs:        []*types.Transaction{},
 			},
 		},
 		// Standard JSON trace upon the head, plain transfer.
@@ -238,6 +510,7 @@ func TestTraceCall(t *testing.T) {
 				Failed:      false,
 				ReturnValue: "",
 				StructLogs:  []quaiapi.StructLogRes{},
+				Etxs:        []*types.Transaction{},
 			},
 		},
 		// Standard JSON trace upon the non-existent block, error expects
@@ -267,6 +540,7 @@ func TestTraceCall(t *testing.T) {
 				Failed:      false,
 				ReturnValue: "",
 				StructLogs:  []quaiapi.StructLogRes{},
+				Etxs:        []*types.Transaction{},
 			},
 		},
 		// Standard JSON trace upon the pending block
@@ -284,6 +558,7 @@ func TestTraceCall(t *testing.T) {
 				Failed:      false,
 				ReturnValue: "",
 				StructLogs:  []quaiapi.StructLogRes{},
+				Etxs:        []*types.Transaction{},
 			},
 		},
 	}
@@ -314,19 +589,12 @@ func TestOverriddenTraceCall(t *testing.T) {
 
 	// Initialize test accounts
 	accounts := newAccounts(3)
-	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
-		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
-		accounts[1].addr: {Balance: big.NewInt(params.Ether)},
-		accounts[2].addr: {Balance: big.NewInt(params.Ether)},
-	}}
 	genBlocks := 10
-	signer := types.HomesteadSigner{}
-	api := NewAPI(newTestBackend(t, genBlocks, genesis, func(i int, b *core.BlockGen) {
+	api := NewAPI(newTestBackend(t, genBlocks, fundAccounts(accounts), func(i int, b *blockGen) {
 		// Transfer from account[0] to account[1]
 		//    value: 1000 wei
-		//    fee:   0 wei
-		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
-		b.AddTx(tx)
+		//    fee:   base fee
+		b.AddTx(newTransfer(b, uint64(i), accounts[0], accounts[1]))
 	}))
 	randomAccounts, tracer := newAccounts(3), "callTracer"
 
@@ -348,7 +616,7 @@ func TestOverriddenTraceCall(t *testing.T) {
 			config: &TraceCallConfig{
 				Tracer: &tracer,
 				StateOverrides: &quaiapi.StateOverride{
-					randomAccounts[0].addr: quaiapi.OverrideAccount{Balance: newRPCBalance(new(big.Int).Mul(big.NewInt(1), big.NewInt(params.Ether)))},
+					randomAccounts[0].addr.Bytes20(): quaiapi.OverrideAccount{Balance: newRPCBalance(new(big.Int).Mul(big.NewInt(1), big.NewInt(params.Ether)))},
 				},
 			},
 			expectErr: nil,
@@ -356,7 +624,7 @@ func TestOverriddenTraceCall(t *testing.T) {
 				Type:    "CALL",
 				From:    randomAccounts[0].addr,
 				To:      randomAccounts[1].addr,
-				Gas:     newRPCUint64(24979000),
+				Gas:     newRPCUint64(25000000),
 				GasUsed: newRPCUint64(0),
 				Value:   (*hexutil.Big)(big.NewInt(1000)),
 			},
@@ -401,7 +669,7 @@ func TestOverriddenTraceCall(t *testing.T) {
 			config: &TraceCallConfig{
 				Tracer: &tracer,
 				StateOverrides: &quaiapi.StateOverride{
-					randomAccounts[2].addr: quaiapi.OverrideAccount{
+					randomAccounts[2].addr.Bytes20(): quaiapi.OverrideAccount{
 						Code:      newRPCBytes(common.Hex2Bytes("6080604052348015600f57600080fd5b506004361060285760003560e01c80638381f58a14602d575b600080fd5b60336049565b6040518082815260200191505060405180910390f35b6000548156fea2646970667358221220eab35ffa6ab2adfe380772a48b8ba78e82a1b820a18fcb6f59aa4efb20a5f60064736f6c63430007040033")),
 						StateDiff: newStates([]common.Hash{{}}, []common.Hash{common.BigToHash(big.NewInt(123))}),
 					},
@@ -414,7 +682,7 @@ func TestOverriddenTraceCall(t *testing.T) {
 				To:      randomAccounts[2].addr,
 				Input:   hexutil.Bytes(common.Hex2Bytes("8381f58a")),
 				Output:  hexutil.Bytes(common.BigToHash(big.NewInt(123)).Bytes()),
-				Gas:     newRPCUint64(24978936),
+				Gas:     newRPCUint64(25000000),
 				GasUsed: newRPCUint64(2283),
 				Value:   (*hexutil.Big)(big.NewInt(0)),
 			},
@@ -455,17 +723,12 @@ func TestTraceTransaction(t *testing.T) {
 
 	// Initialize test accounts
 	accounts := newAccounts(2)
-	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
-		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
-		accounts[1].addr: {Balance: big.NewInt(params.Ether)},
-	}}
 	target := common.Hash{}
-	signer := types.HomesteadSigner{}
-	api := NewAPI(newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+	api := NewAPI(newTestBackend(t, 1, fundAccounts(accounts), func(i int, b *blockGen) {
 		// Transfer from account[0] to account[1]
 		//    value: 1000 wei
-		//    fee:   0 wei
-		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+		//    fee:   base fee
+		tx := newTransfer(b, uint64(i), accounts[0], accounts[1])
 		b.AddTx(tx)
 		target = tx.Hash()
 	}))
@@ -478,6 +741,7 @@ func TestTraceTransaction(t *testing.T) {
 		Failed:      false,
 		ReturnValue: "",
 		StructLogs:  []quaiapi.StructLogRes{},
+		Etxs:        []*types.Transaction{},
 	}) {
 		t.Error("Transaction tracing result is different")
 	}
@@ -488,19 +752,12 @@ func TestTraceBlock(t *testing.T) {
 
 	// Initialize test accounts
 	accounts := newAccounts(3)
-	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
-		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
-		accounts[1].addr: {Balance: big.NewInt(params.Ether)},
-		accounts[2].addr: {Balance: big.NewInt(params.Ether)},
-	}}
 	genBlocks := 10
-	signer := types.HomesteadSigner{}
-	api := NewAPI(newTestBackend(t, genBlocks, genesis, func(i int, b *core.BlockGen) {
+	api := NewAPI(newTestBackend(t, genBlocks, fundAccounts(accounts), func(i int, b *blockGen) {
 		// Transfer from account[0] to account[1]
 		//    value: 1000 wei
-		//    fee:   0 wei
-		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
-		b.AddTx(tx)
+		//    fee:   base fee
+		b.AddTx(newTransfer(b, uint64(i), accounts[0], accounts[1]))
 	}))
 
 	var testSuite = []struct {
@@ -522,12 +779,16 @@ func TestTraceBlock(t *testing.T) {
 			config:      nil,
 			expectErr:   nil,
 			expect: []*txTraceResult{
+				{
+					Result: &utxoTrace{Type: "coinbase"},
+				},
 				{
 					Result: &quaiapi.ExecutionResult{
 						Gas:         params.TxGas,
 						Failed:      false,
 						ReturnValue: "",
 						StructLogs:  []quaiapi.StructLogRes{},
+						Etxs:        []*types.Transaction{},
 					},
 				},
 			},
@@ -545,12 +806,16 @@ func TestTraceBlock(t *testing.T) {
 			config:      nil,
 			expectErr:   nil,
 			expect: []*txTraceResult{
+				{
+					Result: &utxoTrace{Type: "coinbase"},
+				},
 				{
 					Result: &quaiapi.ExecutionResult{
 						Gas:         params.TxGas,
 						Failed:      false,
 						ReturnValue: "",
 						StructLogs:  []quaiapi.StructLogRes{},
+						Etxs:        []*types.Transaction{},
 					},
 				},
 			},
@@ -561,12 +826,16 @@ func TestTraceBlock(t *testing.T) {
 			config:      nil,
 			expectErr:   nil,
 			expect: []*txTraceResult{
+				{
+					Result: &utxoTrace{Type: "coinbase"},
+				},
 				{
 					Result: &quaiapi.ExecutionResult{
 						Gas:         params.TxGas,
 						Failed:      false,
 						ReturnValue: "",
 						StructLogs:  []quaiapi.StructLogRes{},
+						Etxs:        []*types.Transaction{},
 					},
 				},
 			},
@@ -588,7 +857,9 @@ func TestTraceBlock(t *testing.T) {
 				continue
 			}
 			if !reflect.DeepEqual(result, testspec.expect) {
-				t.Errorf("Result mismatch, want %v, get %v", testspec.expect, result)
+				have, _ := json.Marshal(result)
+				want, _ := json.Marshal(testspec.expect)
+				t.Errorf("Result mismatch, want %s, get %s", want, have)
 			}
 		}
 	}
@@ -605,10 +876,23 @@ func (a Accounts) Len() int           { return len(a) }
 func (a Accounts) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a Accounts) Less(i, j int) bool { return bytes.Compare(a[i].addr.Bytes(), a[j].addr.Bytes()) < 0 }
 
+// internal returns the address of the account in the Quai ledger.
+func (a Account) internal() common.InternalAddress {
+	internal, err := a.addr.InternalAndQuaiAddress()
+	if err != nil {
+		panic(err)
+	}
+	return internal
+}
+
+// newAccounts returns n accounts of the Quai ledger of the test zone.
 func newAccounts(n int) (accounts Accounts) {
-	for i := 0; i < n; i++ {
+	for len(accounts) < n {
 		key, _ := crypto.GenerateKey()
-		addr := crypto.PubkeyToAddress(key.PublicKey)
+		addr := crypto.PubkeyToAddress(key.PublicKey, testLocation)
+		if !addr.Location().Equal(testLocation) || !addr.IsInQuaiLedgerScope() {
+			continue
+		}
 		accounts = append(accounts, Account{key: key, addr: addr})
 	}
 	sort.Sort(accounts)
@@ -0,0 +1,346 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/core/vm"
+)
+
+func init() {
+	register("callTracer", newCallTracer)
+}
+
+// callFrame is a single call in the call tree reported by the callTracer.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      common.Address  `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     *hexutil.Uint64 `json:"gas,omitempty"`
+	GasUsed *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  hexutil.Bytes   `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Calls   []callFrame     `json:"calls,omitempty"`
+
+	// Bookkeeping used while the frame is still open
+	gasIn   uint64
+	gasCost uint64
+	outOff  uint64
+	outLen  uint64
+}
+
+// callTracer reconstructs the call tree of a transaction, including the
+// external transactions it emits towards other chains, from the opcode
+// stream reported by the EVM.
+type callTracer struct {
+	env         *vm.EVM
+	callstack   []callFrame
+	precompiles []common.Address
+	gasLimit    uint64
+	descended   bool // whether the last opcode entered a new call frame
+	pendingEtx  bool // whether the last opcode was ETX or CONVERT
+	interrupt   uint32
+	reason      error
+}
+
+// newCallTracer returns a native go tracer which tracks the call frames of
+// a transaction and yields them as a nested tree.
+func newCallTracer(ctx *Context) Tracer {
+	// The first frame is the transaction itself and is filled in by CaptureStart
+	return &callTracer{callstack: make([]callFrame, 1)}
+}
+
+// CaptureTxStart records the gas limit bought by the transaction.
+func (t *callTracer) CaptureTxStart(gasLimit uint64) {
+	t.gasLimit = gasLimit
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (t *callTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+	t.precompiles = vm.ActivePrecompiles(env.ChainConfig().Rules(env.Context.BlockNumber, env.Context.ExpansionNumber), env.ChainConfig().Location)
+
+	if t.gasLimit == 0 {
+		t.gasLimit = gas
+	}
+	typ := vm.CALL.String()
+	if create {
+		typ = vm.CREATE.String()
+	}
+	t.callstack[0] = callFrame{
+		Type:  typ,
+		From:  from,
+		To:    to,
+		Input: common.CopyBytes(input),
+		Gas:   (*hexutil.Uint64)(&t.gasLimit),
+	}
+	if value != nil {
+		t.callstack[0].Value = (*hexutil.Big)(new(big.Int).Set(value))
+	}
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM execution.
+func (t *callTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error, nodeLocation common.Location) {
+	// Skip if tracing was interrupted
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		env.Cancel()
+		return
+	}
+	if err != nil {
+		t.fault(err)
+		return
+	}
+	stack := scope.Stack
+	// If the previous opcode entered a call, this is the first step of the
+	// callee and the remaining gas is what the callee was handed
+	if t.descended {
+		if depth >= len(t.callstack) {
+			childGas := hexutil.Uint64(gas)
+			t.callstack[len(t.callstack)-1].Gas = &childGas
+		}
+		t.descended = false
+	}
+	// If the previous opcode emitted an ETX, its success flag is on the stack
+	if t.pendingEtx {
+		parent := &t.callstack[len(t.callstack)-1]
+		if len(parent.Calls) > 0 && stack.Back(0).IsZero() {
+			parent.Calls[len(parent.Calls)-1].Error = "etx not emitted"
+		}
+		t.pendingEtx = false
+	}
+	switch op {
+	case vm.CREATE, vm.CREATE2:
+		inOff, inLen := stack.Back(1).Uint64(), stack.Back(2).Uint64()
+		t.callstack = append(t.callstack, callFrame{
+			Type:    op.String(),
+			From:    scope.Contract.Address(),
+			Input:   scope.Memory.GetCopy(int64(inOff), int64(inLen)),
+			Value:   (*hexutil.Big)(stack.Back(0).ToBig()),
+			gasIn:   gas,
+			gasCost: cost,
+		})
+		t.descended = true
+		return
+
+	case vm.SELFDESTRUCT:
+		var (
+			from    = scope.Contract.Address()
+			balance = new(big.Int)
+		)
+		if internal, err := from.InternalAndQuaiAddress(); err == nil {
+			balance = env.StateDB.GetBalance(internal)
+		}
+		gasIn, gasUsed := hexutil.Uint64(gas), hexutil.Uint64(cost)
+		parent := &t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, callFrame{
+			Type:    op.String(),
+			From:    from,
+			To:      common.Bytes20ToAddress(stack.Back(0).Bytes20(), nodeLocation),
+			Value:   (*hexutil.Big)(balance),
+			Gas:     &gasIn,
+			GasUsed: &gasUsed,
+			Input:   hexutil.Bytes{},
+		})
+		return
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		to := common.Bytes20ToAddress(stack.Back(1).Bytes20(), nodeLocation)
+		if t.isPrecompiled(to) {
+			return
+		}
+		off := 0
+		if op == vm.CALL || op == vm.CALLCODE {
+			off = 1
+		}
+		inOff, inLen := stack.Back(2+off).Uint64(), stack.Back(3+off).Uint64()
+		frame := callFrame{
+			Type:    op.String(),
+			From:    scope.Contract.Address(),
+			To:      to,
+			Input:   scope.Memory.GetCopy(int64(inOff), int64(inLen)),
+			gasIn:   gas,
+			gasCost: cost,
+			outOff:  stack.Back(4 + off).Uint64(),
+			outLen:  stack.Back(5 + off).Uint64(),
+		}
+		if off == 1 {
+			frame.Value = (*hexutil.Big)(stack.Back(2).ToBig())
+		}
+		// A plain call to an address outside of this chain or into the Qi
+		// ledger is turned into an external transaction by the EVM
+		if _, err := to.InternalAndQuaiAddress(); err != nil && op == vm.CALL {
+			frame.Type = vm.ETX.String()
+		}
+		t.callstack = append(t.callstack, frame)
+		t.descended = true
+		return
+
+	case vm.ETX:
+		// Stack layout: gas, addr, value, etxGasLimit, gasTipCap, gasFeeCap, inOffset, inSize, ...
+		inOff, inLen := stack.Back(6).Uint64(), stack.Back(7).Uint64()
+		etxGas := hexutil.Uint64(stack.Back(3).Uint64())
+		t.emitEtx(callFrame{
+			Type:  op.String(),
+			From:  scope.Contract.Address(),
+			To:    common.Bytes20ToAddress(stack.Back(1).Bytes20(), nodeLocation),
+			Value: (*hexutil.Big)(stack.Back(2).ToBig()),
+			Gas:   &etxGas,
+			Input: scope.Memory.GetCopy(int64(inOff), int64(inLen)),
+		})
+		return
+
+	case vm.CONVERT:
+		// Stack layout: gas, addr, value, etxGasLimit
+		etxGas := hexutil.Uint64(stack.Back(3).Uint64())
+		t.emitEtx(callFrame{
+			Type:  op.String(),
+			From:  scope.Contract.Address(),
+			To:    common.Bytes20ToAddress(stack.Back(1).Bytes20(), nodeLocation),
+			Value: (*hexutil.Big)(stack.Back(2).ToBig()),
+			Gas:   &etxGas,
+			Input: hexutil.Bytes{},
+		})
+		return
+
+	case vm.REVERT:
+		t.callstack[len(t.callstack)-1].Error = vm.ErrExecutionReverted.Error()
+		return
+	}
+	// If we've just returned from a call, finalize its frame
+	if depth == len(t.callstack)-1 {
+		call := t.callstack[len(t.callstack)-1]
+		t.callstack = t.callstack[:len(t.callstack)-1]
+
+		ret := stack.Back(0)
+		if call.Type == vm.CREATE.String() || call.Type == vm.CREATE2.String() {
+			gasUsed := hexutil.Uint64(call.gasIn - call.gasCost - gas)
+			call.GasUsed = &gasUsed
+
+			if !ret.IsZero() {
+				call.To = common.Bytes20ToAddress(ret.Bytes20(), nodeLocation)
+				if internal, err := call.To.InternalAndQuaiAddress(); err == nil {
+					call.Output = env.StateDB.GetCode(internal)
+				}
+			} else if call.Error == "" {
+				call.Error = "internal failure"
+			}
+		} else {
+			if call.Gas != nil {
+				gasUsed := hexutil.Uint64(call.gasIn - call.gasCost + uint64(*call.Gas) - gas)
+				call.GasUsed = &gasUsed
+			}
+			if !ret.IsZero() {
+				call.Output = scope.Memory.GetCopy(int64(call.outOff), int64(call.outLen))
+			} else if call.Error == "" {
+				call.Error = "internal failure"
+			}
+		}
+		parent := &t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, call)
+	}
+}
+
+// CaptureFault implements the vm.Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *callTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	t.fault(err)
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *callTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) {
+	call := &t.callstack[0]
+	used := hexutil.Uint64(gasUsed)
+	call.GasUsed = &used
+	call.Output = common.CopyBytes(output)
+	if err != nil {
+		call.Error = err.Error()
+		if call.Type == vm.CREATE.String() {
+			call.To = common.Address{}
+		}
+	}
+}
+
+// GetResult returns the json-encoded nested list of call traces, and any
+// error arising from the encoding or forceful termination (via `Stop`).
+func (t *callTracer) GetResult() (json.RawMessage, error) {
+	if len(t.callstack) != 1 {
+		return nil, errors.New("incorrect number of top-level calls")
+	}
+	res, err := json.Marshal(t.callstack[0])
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *callTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
+
+// fault closes the innermost open frame with the given execution error.
+func (t *callTracer) fault(err error) {
+	// If the topmost call already reverted, don't handle the additional fault again
+	if t.callstack[len(t.callstack)-1].Error != "" {
+		return
+	}
+	call := t.callstack[len(t.callstack)-1]
+	t.callstack = t.callstack[:len(t.callstack)-1]
+
+	call.Error = err.Error()
+	// Consume all available gas and clean any leftovers
+	if call.Gas != nil {
+		gasUsed := *call.Gas
+		call.GasUsed = &gasUsed
+	}
+	// Flatten the failed call into its parent
+	if len(t.callstack) > 0 {
+		parent := &t.callstack[len(t.callstack)-1]
+		parent.Calls = append(parent.Calls, call)
+		return
+	}
+	// Last call failed too, leave it in the stack
+	t.callstack = append(t.callstack, call)
+}
+
+// emitEtx records an external transaction emitted by the current frame. The
+// EVM does not descend into ETXs, so they are appended as leaf calls.
+func (t *callTracer) emitEtx(etx callFrame) {
+	parent := &t.callstack[len(t.callstack)-1]
+	parent.Calls = append(parent.Calls, etx)
+	t.pendingEtx = true
+}
+
+// isPrecompiled reports whether the address is one of the active precompiles.
+func (t *callTracer) isPrecompiled(addr common.Address) bool {
+	for _, p := range t.precompiles {
+		if p.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/core/vm"
+	"github.com/dominant-strategies/go-quai/crypto"
+)
+
+func init() {
+	register("prestateTracer", newPrestateTracer)
+}
+
+// prestate is the collection of accounts touched by a transaction, keyed by
+// their hex encoded address.
+type prestate = map[string]*account
+
+// account is the state of a single account before the transaction executed.
+type account struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   uint64                      `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage"`
+}
+
+// prestateTracer records the state of every account and storage slot a
+// transaction touches, as it was before the transaction executed.
+type prestateTracer struct {
+	env       *vm.EVM
+	prestate  prestate
+	create    bool
+	to        common.Address
+	gasLimit  uint64
+	interrupt uint32
+	reason    error
+}
+
+// newPrestateTracer returns a native go tracer which reassembles the
+// pre-transaction state of all accounts touched by a transaction.
+func newPrestateTracer(ctx *Context) Tracer {
+	return &prestateTracer{prestate: make(prestate)}
+}
+
+// CaptureTxStart records the gas limit bought by the transaction.
+func (t *prestateTracer) CaptureTxStart(gasLimit uint64) {
+	t.gasLimit = gasLimit
+}
+
+// CaptureStart implements the vm.Tracer interface to initialize the tracing operation.
+func (t *prestateTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+	t.create = create
+	t.to = to
+
+	t.lookupAccount(from)
+	t.lookupAccount(to)
+	t.lookupAccount(env.Context.Coinbase)
+
+	// The recipient balance already includes the value transferred
+	if acc, ok := t.prestate[prestateKey(to)]; ok && value != nil {
+		acc.Balance = (*hexutil.Big)(new(big.Int).Sub(acc.Balance.ToInt(), value))
+	}
+	// ETXs are funded by their origin chain, so only regular transactions
+	// need the value, the bought gas and the nonce bump undone
+	if env.TxContext.TxType == types.ExternalTxType {
+		return
+	}
+	if acc, ok := t.prestate[prestateKey(from)]; ok {
+		if t.gasLimit == 0 {
+			t.gasLimit = gas
+		}
+		fromBal := new(big.Int).Set(acc.Balance.ToInt())
+		if value != nil {
+			fromBal.Add(fromBal, value)
+		}
+		if env.TxContext.GasPrice != nil {
+			fromBal.Add(fromBal, new(big.Int).Mul(env.TxContext.GasPrice, new(big.Int).SetUint64(t.gasLimit)))
+		}
+		acc.Balance = (*hexutil.Big)(fromBal)
+		if acc.Nonce > 0 {
+			acc.Nonce--
+		}
+	}
+}
+
+// CaptureState implements the vm.Tracer interface to trace a single step of VM execution.
+func (t *prestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error, nodeLocation common.Location) {
+	// Skip if tracing was interrupted
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		env.Cancel()
+		return
+	}
+	if err != nil {
+		return
+	}
+	var (
+		stack      = scope.Stack
+		caller     = scope.Contract.Address()
+		stackLen   = len(stack.Data())
+		addressFor = func(n int) common.Address {
+			return common.Bytes20ToAddress(stack.Back(n).Bytes20(), nodeLocation)
+		}
+	)
+	switch {
+	case stackLen >= 1 && (op == vm.SLOAD || op == vm.SSTORE):
+		t.lookupStorage(caller, common.Hash(stack.Back(0).Bytes32()))
+	case stackLen >= 1 && (op == vm.EXTCODECOPY || op == vm.EXTCODEHASH || op == vm.EXTCODESIZE || op == vm.BALANCE || op == vm.SELFDESTRUCT):
+		t.lookupAccount(addressFor(0))
+	case stackLen >= 5 && (op == vm.DELEGATECALL || op == vm.CALL || op == vm.STATICCALL || op == vm.CALLCODE):
+		t.lookupAccount(addressFor(1))
+	case stackLen >= 2 && (op == vm.ETX || op == vm.CONVERT):
+		t.lookupAccount(caller)
+	case stackLen >= 4 && op == vm.CREATE2:
+		// The address of a CREATE2 is known upfront, unlike the ground
+		// address of a CREATE
+		offset, size := stack.Back(1).Uint64(), stack.Back(2).Uint64()
+		inithash := crypto.Keccak256(scope.Memory.GetCopy(int64(offset), int64(size)))
+		t.lookupAccount(crypto.CreateAddress2(caller, stack.Back(3).Bytes32(), inithash, nodeLocation))
+	}
+}
+
+// CaptureFault implements the vm.Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *prestateTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) {
+	// A freshly created contract did not exist before the transaction
+	if t.create {
+		delete(t.prestate, prestateKey(t.to))
+	}
+}
+
+// GetResult returns the json-encoded prestate of all touched accounts, and
+// any error arising from the encoding or forceful termination (via `Stop`).
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	res, err := json.Marshal(t.prestate)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *prestateTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
+
+// lookupAccount fetches details of an account and adds it to the prestate
+// if it doesn't exist there yet. Addresses outside of this chain's Quai
+// ledger have no account state and are skipped.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.prestate[prestateKey(addr)]; ok {
+		return
+	}
+	internal, err := addr.InternalAndQuaiAddress()
+	if err != nil {
+		return
+	}
+	t.prestate[prestateKey(addr)] = &account{
+		Balance: (*hexutil.Big)(new(big.Int).Set(t.env.StateDB.GetBalance(internal))),
+		Nonce:   t.env.StateDB.GetNonce(internal),
+		Code:    common.CopyBytes(t.env.StateDB.GetCode(internal)),
+		Storage: make(map[common.Hash]common.Hash),
+	}
+}
+
+// lookupStorage fetches the requested storage slot and adds it to the
+// prestate of the given contract, looking up the contract first if needed.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+	acc, ok := t.prestate[prestateKey(addr)]
+	if !ok {
+		return
+	}
+	if _, ok := acc.Storage[key]; ok {
+		return
+	}
+	internal, err := addr.InternalAndQuaiAddress()
+	if err != nil {
+		return
+	}
+	acc.Storage[key] = t.env.StateDB.GetState(internal, key)
+}
+
+// prestateKey returns the lower case hex encoding of an address used to key
+// the prestate.
+func prestateKey(addr common.Address) string {
+	return hexutil.Encode(addr.Bytes())
+}
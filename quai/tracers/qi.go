@@ -0,0 +1,221 @@
+package tracers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
+	"github.com/dominant-strategies/go-quai/core"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/params"
+)
+
+// utxoTrace is the trace of a transaction that is settled in the UTXO ledger
+// and therefore never runs through the EVM. It reports the outpoints spent
+// and created by the transaction together with the fee it paid.
+type utxoTrace struct {
+	Type    string        `json:"type"`
+	Inputs  []*utxoInput  `json:"inputs,omitempty"`
+	Outputs []*utxoOutput `json:"outputs,omitempty"`
+	Fee     *hexutil.Big  `json:"fee,omitempty"`
+}
+
+// utxoInput is a single outpoint consumed by a traced transaction.
+type utxoInput struct {
+	TxHash       common.Hash    `json:"txHash"`
+	Index        hexutil.Uint64 `json:"index"`
+	Denomination *hexutil.Uint  `json:"denomination,omitempty"`
+	Address      hexutil.Bytes  `json:"address,omitempty"`
+	Lock         *hexutil.Big   `json:"lock,omitempty"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// utxoOutput is a single output created by a traced transaction, at the
+// outpoint TxHash and Index. Kind is "utxo" for outputs settled in this
+// slice, "etx" for outputs emitted to another slice and "conversion" for
+// outputs converted into Quai, or into Qi by a conversion ETX.
+type utxoOutput struct {
+	TxHash       common.Hash    `json:"txHash"`
+	Index        hexutil.Uint64 `json:"index"`
+	Denomination hexutil.Uint   `json:"denomination"`
+	Address      hexutil.Bytes  `json:"address"`
+	Lock         *hexutil.Big   `json:"lock,omitempty"`
+	Kind         string         `json:"kind"`
+}
+
+// Kinds of outputs created by a traced UTXO transaction.
+const (
+	utxoKindUtxo       = "utxo"
+	utxoKindEtx        = "etx"
+	utxoKindConversion = "conversion"
+)
+
+// traceUtxoTx assembles the trace of a coinbase, Qi or Qi ledger ETX
+// transaction from the state right before its execution.
+func (api *API) traceUtxoTx(ctx context.Context, block *types.WorkObject, index int, tx *types.Transaction, statedb *state.StateDB) (*utxoTrace, error) {
+	var (
+		nodeCtx      = api.backend.NodeCtx()
+		nodeLocation = api.backend.NodeLocation()
+	)
+	switch {
+	case index == 0 && types.IsCoinBaseTx(tx, block.ParentHash(nodeCtx), nodeLocation):
+		trace := &utxoTrace{Type: "coinbase"}
+		if tx.Type() == types.QiTxType {
+			trace.Outputs = utxoOutputs(tx, nodeLocation)
+		}
+		return trace, nil
+
+	case tx.Type() == types.ExternalTxType:
+		if tx.ETXSender().Location().Equal(*tx.To().Location()) {
+			outputs, err := api.conversionOutputs(ctx, block, tx)
+			if err != nil {
+				return nil, err
+			}
+			return &utxoTrace{Type: "etx", Outputs: outputs}, nil
+		}
+		return &utxoTrace{
+			Type: "etx",
+			Outputs: []*utxoOutput{{
+				TxHash:       tx.OriginatingTxHash(),
+				Index:        hexutil.Uint64(tx.ETXIndex()),
+				Denomination: hexutil.Uint(tx.Value().Uint64()),
+				Address:      tx.To().Bytes(),
+				Kind:         utxoKindUtxo,
+			}},
+		}, nil
+	}
+	trace := &utxoTrace{
+		Type:    "qi",
+		Outputs: utxoOutputs(tx, nodeLocation),
+	}
+	totalIn := new(big.Int)
+	for _, txIn := range tx.TxIn() {
+		input := &utxoInput{
+			TxHash: txIn.PreviousOutPoint.TxHash,
+			Index:  hexutil.Uint64(txIn.PreviousOutPoint.Index),
+		}
+		utxo := statedb.GetUTXO(txIn.PreviousOutPoint.TxHash, txIn.PreviousOutPoint.Index)
+		if utxo == nil {
+			input.Error = "utxo not found"
+			trace.Inputs = append(trace.Inputs, input)
+			continue
+		}
+		denomination := hexutil.Uint(utxo.Denomination)
+		input.Denomination = &denomination
+		input.Address = common.CopyBytes(utxo.Address)
+		if utxo.Lock != nil {
+			input.Lock = (*hexutil.Big)(new(big.Int).Set(utxo.Lock))
+		}
+		if utxo.Denomination <= types.MaxDenomination {
+			totalIn.Add(totalIn, types.Denominations[utxo.Denomination])
+		}
+		trace.Inputs = append(trace.Inputs, input)
+	}
+	totalOut := new(big.Int)
+	for _, output := range trace.Outputs {
+		if uint8(output.Denomination) <= types.MaxDenomination {
+			totalOut.Add(totalOut, types.Denominations[uint8(output.Denomination)])
+		}
+	}
+	if totalIn.Cmp(totalOut) >= 0 {
+		trace.Fee = (*hexutil.Big)(new(big.Int).Sub(totalIn, totalOut))
+	}
+	return trace, nil
+}
+
+// utxoOutputs classifies the outputs of a Qi transaction the same way
+// ProcessQiTx settles them.
+func utxoOutputs(tx *types.Transaction, location common.Location) []*utxoOutput {
+	outputs := make([]*utxoOutput, 0, len(tx.TxOut()))
+	for i, txOut := range tx.TxOut() {
+		output := &utxoOutput{
+			TxHash:       tx.Hash(),
+			Index:        hexutil.Uint64(i),
+			Denomination: hexutil.Uint(txOut.Denomination),
+			Address:      common.CopyBytes(txOut.Address),
+			Kind:         utxoKindUtxo,
+		}
+		if txOut.Lock != nil && txOut.Lock.Sign() > 0 {
+			output.Lock = (*hexutil.Big)(new(big.Int).Set(txOut.Lock))
+		}
+		toAddr := common.BytesToAddress(txOut.Address, location)
+		switch {
+		case toAddr.Location().Equal(location) && toAddr.IsInQuaiLedgerScope():
+			output.Kind = utxoKindConversion
+		case !toAddr.Location().Equal(location):
+			output.Kind = utxoKindEtx
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs
+}
+
+// conversionOutputs returns the locked outputs a Quai to Qi conversion ETX
+// creates, the way the state processor creates them.
+func (api *API) conversionOutputs(ctx context.Context, block *types.WorkObject, tx *types.Transaction) ([]*utxoOutput, error) {
+	nodeCtx := api.backend.NodeCtx()
+	primeTerminus, err := api.backend.HeaderByHash(ctx, block.PrimeTerminus())
+	if err != nil {
+		return nil, err
+	}
+	if primeTerminus == nil {
+		return nil, fmt.Errorf("could not find prime terminus header %032x", block.PrimeTerminus())
+	}
+	var (
+		value    = misc.QuaiToQi(primeTerminus, tx.Value())
+		gasTable = api.backend.ChainConfig().GasTable(block.Number(nodeCtx), block.ExpansionNumber())
+		lock     = new(big.Int).Add(block.Number(nodeCtx), big.NewInt(params.ConversionLockPeriod))
+		outputs  []*utxoOutput
+	)
+	for i, denomination := range core.ConversionOutputs(value, tx.Gas(), gasTable) {
+		outputs = append(outputs, &utxoOutput{
+			TxHash:       tx.Hash(),
+			Index:        hexutil.Uint64(i),
+			Denomination: hexutil.Uint(denomination),
+			Address:      tx.To().Bytes(),
+			Lock:         (*hexutil.Big)(new(big.Int).Set(lock)),
+			Kind:         utxoKindConversion,
+		})
+	}
+	return outputs, nil
+}
+
+// applyUtxoTx advances the state past a Qi or Qi ledger ETX transaction of
+// an already validated block, the way the state processor settles it.
+func (api *API) applyUtxoTx(ctx context.Context, block *types.WorkObject, tx *types.Transaction, statedb *state.StateDB) error {
+	var (
+		nodeCtx      = api.backend.NodeCtx()
+		nodeLocation = api.backend.NodeLocation()
+		config       = api.backend.ChainConfig()
+	)
+	if tx.Type() == types.QiTxType {
+		var (
+			gp      = new(types.GasPool).AddGas(math.MaxUint64)
+			usedGas uint64
+			// The block has already been validated, so the ETX limits do not
+			// need to be enforced again while replaying it
+			etxRLimit = math.MaxInt
+			etxPLimit = math.MaxInt
+		)
+		_, _, err := core.ProcessQiTx(tx, api.backend.ChainContext(), true, false, block, statedb, gp, &usedGas, types.MakeSigner(config, block.Number(nodeCtx)), nodeLocation, *config.ChainID, config.GasTable(block.Number(nodeCtx), block.ExpansionNumber()), &etxRLimit, &etxPLimit)
+		return err
+	}
+	if tx.ETXSender().Location().Equal(*tx.To().Location()) {
+		outputs, err := api.conversionOutputs(ctx, block, tx)
+		if err != nil {
+			return err
+		}
+		for _, output := range outputs {
+			if err := statedb.CreateUTXO(output.TxHash, uint16(output.Index), types.NewUtxoEntry(types.NewTxOut(uint8(output.Denomination), output.Address, (*big.Int)(output.Lock)))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return statedb.CreateUTXO(tx.OriginatingTxHash(), tx.ETXIndex(), types.NewUtxoEntry(types.NewTxOut(uint8(tx.Value().Uint64()), tx.To().Bytes(), big.NewInt(0))))
+}
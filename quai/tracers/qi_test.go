@@ -0,0 +1,261 @@
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/common/hexutil"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
+	"github.com/dominant-strategies/go-quai/core"
+	"github.com/dominant-strategies/go-quai/core/state"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/crypto"
+	"github.com/dominant-strategies/go-quai/params"
+	"github.com/dominant-strategies/go-quai/rpc"
+)
+
+// qiKey is a key owning Qi outputs in the test zone
+type qiKey struct {
+	priv *btcec.PrivateKey
+	pub  []byte
+	addr common.Address
+}
+
+// newQiKey returns a key whose address is in the Qi ledger of the test zone
+func newQiKey(t *testing.T) *qiKey {
+	for {
+		priv, err := btcec.NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub := priv.PubKey().SerializeUncompressed()
+		addr := crypto.PubkeyBytesToAddress(pub, testLocation)
+		if addr.Location().Equal(testLocation) && addr.IsInQiLedgerScope() {
+			return &qiKey{priv: priv, pub: pub, addr: addr}
+		}
+	}
+}
+
+// newQiTx returns a signed Qi transaction spending an output of the key into
+// a single output of the given denomination
+func newQiTx(t *testing.T, config *params.ChainConfig, from *qiKey, prevOut types.OutPoint, to *qiKey, denomination uint8) *types.Transaction {
+	inner := &types.QiTx{
+		ChainID: config.ChainID,
+		TxIn:    types.TxIns{*types.NewTxIn(&prevOut, from.pub, nil)},
+		TxOut:   types.TxOuts{*types.NewTxOut(denomination, to.addr.Bytes(), big.NewInt(0))},
+	}
+	digest := types.LatestSigner(config).Hash(types.NewTx(inner))
+	sig, err := schnorr.Sign(from.priv, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner.Signature = sig
+	return types.NewTx(inner)
+}
+
+// qiTestChain is a chain whose only block after the genesis holds Qi
+// transactions, a Qi ETX and a conversion
+type qiTestChain struct {
+	api     *API
+	backend *testBackend
+	keys    []*qiKey
+
+	genesisOut types.OutPoint
+	txA, txB   *types.Transaction // txB spends the output of txA
+	etx, txC   *types.Transaction // txC spends the output of the ETX
+	conversion *types.Transaction
+}
+
+// newQiTestChain builds a genesis block owning a Qi output and a block
+// spending it, along with the outputs created earlier in the block.
+func newQiTestChain(t *testing.T) *qiTestChain {
+	chain := &qiTestChain{
+		keys:       make([]*qiKey, 7),
+		genesisOut: types.OutPoint{TxHash: common.Hash{0x01}, Index: 0},
+	}
+	for i := range chain.keys {
+		chain.keys[i] = newQiKey(t)
+	}
+	var (
+		keys   = chain.keys
+		config = newTestChainConfig()
+		// The ETX is sent from another zone, the conversion from this one
+		remote = common.BytesToAddress(common.InternalAddress{common.Location{1, 0}.BytePrefix(), 0x01}.Bytes(), testLocation)
+		local  = newAccounts(1)[0].addr
+	)
+	chain.txA = newQiTx(t, config, keys[0], chain.genesisOut, keys[1], 5)
+	chain.txB = newQiTx(t, config, keys[1], types.OutPoint{TxHash: chain.txA.Hash(), Index: 0}, keys[2], 4)
+	chain.etx = types.NewTx(&types.ExternalTx{
+		OriginatingTxHash: common.Hash{0x02},
+		ETXIndex:          0,
+		To:                &keys[3].addr,
+		Value:             big.NewInt(3),
+		Sender:            remote,
+	})
+	chain.txC = newQiTx(t, config, keys[3], types.OutPoint{TxHash: chain.etx.OriginatingTxHash(), Index: 0}, keys[4], 2)
+	chain.conversion = types.NewTx(&types.ExternalTx{
+		OriginatingTxHash: common.Hash{0x03},
+		ETXIndex:          1,
+		Gas:               100000,
+		To:                &keys[5].addr,
+		Value:             big.NewInt(params.Ether),
+		Sender:            local,
+	})
+	genesis := func(statedb *state.StateDB) {
+		if err := statedb.CreateUTXO(chain.genesisOut.TxHash, chain.genesisOut.Index, types.NewUtxoEntry(types.NewTxOut(6, keys[0].addr.Bytes(), big.NewInt(0)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chain.backend = newTestBackend(t, 1, genesis, func(i int, b *blockGen) {
+		for _, tx := range []*types.Transaction{chain.txA, chain.txB, chain.etx, chain.txC, chain.conversion} {
+			b.AddTx(tx)
+		}
+	})
+	chain.api = NewAPI(chain.backend)
+	return chain
+}
+
+// conversionOutputs returns the outputs the state processor creates for the
+// conversion of the chain
+func (chain *qiTestChain) conversionOutputs() []*utxoOutput {
+	var (
+		block    = chain.backend.blocks[1]
+		number   = block.Number(testLocation.Context())
+		gasTable = chain.backend.chainConfig.GasTable(number, block.ExpansionNumber())
+		value    = misc.QuaiToQi(chain.backend.terminus, chain.conversion.Value())
+		lock     = new(big.Int).Add(number, big.NewInt(params.ConversionLockPeriod))
+		outputs  []*utxoOutput
+	)
+	for i, denomination := range core.ConversionOutputs(value, chain.conversion.Gas(), gasTable) {
+		outputs = append(outputs, &utxoOutput{
+			TxHash:       chain.conversion.Hash(),
+			Index:        hexutil.Uint64(i),
+			Denomination: hexutil.Uint(denomination),
+			Address:      chain.keys[5].addr.Bytes(),
+			Lock:         (*hexutil.Big)(lock),
+			Kind:         utxoKindConversion,
+		})
+	}
+	return outputs
+}
+
+// checkTrace fails the test if the trace does not encode to the expected one
+func checkTrace(t *testing.T, name string, have interface{}, want *utxoTrace) {
+	t.Helper()
+	haveJSON, err := json.Marshal(have)
+	if err != nil {
+		t.Fatalf("%s: failed to encode trace: %v", name, err)
+	}
+	wantJSON, _ := json.Marshal(want)
+	if string(haveJSON) != string(wantJSON) {
+		t.Errorf("%s: trace mismatch\nhave %s\nwant %s", name, haveJSON, wantJSON)
+	}
+}
+
+// qiSpend returns the trace of a Qi transaction spending an unlocked output
+// into a single output of the next key
+func qiSpend(tx *types.Transaction, prevOut types.OutPoint, from *qiKey, in uint8, to *qiKey, out uint8) *utxoTrace {
+	denomination := hexutil.Uint(in)
+	return &utxoTrace{
+		Type: "qi",
+		Inputs: []*utxoInput{{
+			TxHash:       prevOut.TxHash,
+			Index:        hexutil.Uint64(prevOut.Index),
+			Denomination: &denomination,
+			Address:      from.addr.Bytes(),
+			Lock:         (*hexutil.Big)(new(big.Int)),
+		}},
+		Outputs: []*utxoOutput{{
+			TxHash:       tx.Hash(),
+			Index:        0,
+			Denomination: hexutil.Uint(out),
+			Address:      to.addr.Bytes(),
+			Kind:         utxoKindUtxo,
+		}},
+		Fee: (*hexutil.Big)(new(big.Int).Sub(types.Denominations[in], types.Denominations[out])),
+	}
+}
+
+func TestTraceQiTransaction(t *testing.T) {
+	t.Parallel()
+
+	chain := newQiTestChain(t)
+	keys := chain.keys
+
+	// The output spent by txB is created by txA earlier in the block
+	result, err := chain.api.TraceTransaction(context.Background(), chain.txB.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	checkTrace(t, "txB", result, qiSpend(chain.txB, types.OutPoint{TxHash: chain.txA.Hash(), Index: 0}, keys[1], 5, keys[2], 4))
+
+	// The conversion outputs are keyed by the hash of the ETX and locked
+	result, err = chain.api.TraceTransaction(context.Background(), chain.conversion.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to trace conversion: %v", err)
+	}
+	checkTrace(t, "conversion", result, &utxoTrace{Type: "etx", Outputs: chain.conversionOutputs()})
+}
+
+func TestTraceQiBlock(t *testing.T) {
+	t.Parallel()
+
+	chain := newQiTestChain(t)
+	keys := chain.keys
+
+	results, err := chain.api.TraceBlockByNumber(context.Background(), rpc.BlockNumber(1), nil)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	want := []*utxoTrace{
+		{Type: "coinbase"},
+		qiSpend(chain.txA, chain.genesisOut, keys[0], 6, keys[1], 5),
+		qiSpend(chain.txB, types.OutPoint{TxHash: chain.txA.Hash(), Index: 0}, keys[1], 5, keys[2], 4),
+		{
+			Type: "etx",
+			Outputs: []*utxoOutput{{
+				TxHash:       chain.etx.OriginatingTxHash(),
+				Index:        hexutil.Uint64(chain.etx.ETXIndex()),
+				Denomination: 3,
+				Address:      keys[3].addr.Bytes(),
+				Kind:         utxoKindUtxo,
+			}},
+		},
+		qiSpend(chain.txC, types.OutPoint{TxHash: chain.etx.OriginatingTxHash(), Index: 0}, keys[3], 3, keys[4], 2),
+		{Type: "etx", Outputs: chain.conversionOutputs()},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("have %d traces, want %d", len(results), len(want))
+	}
+	for i, result := range results {
+		if result.Error != "" {
+			t.Errorf("tx %d: failed to trace: %v", i, result.Error)
+			continue
+		}
+		checkTrace(t, chain.backend.blocks[1].Transactions()[i].Hash().Hex(), result.Result, want[i])
+	}
+	// The traced outputs are the ones the block created
+	statedb, err := chain.backend.stateAt(chain.backend.blocks[1])
+	if err != nil {
+		t.Fatalf("failed to open block state: %v", err)
+	}
+	for _, output := range chain.conversionOutputs() {
+		if statedb.GetUTXO(output.TxHash, uint16(output.Index)) == nil {
+			t.Errorf("conversion output %d missing", output.Index)
+		}
+	}
+	if statedb.GetUTXO(chain.conversion.OriginatingTxHash(), chain.conversion.ETXIndex()) != nil {
+		t.Error("conversion created an output at its originating outpoint")
+	}
+	for _, spent := range []types.OutPoint{chain.genesisOut, {TxHash: chain.txA.Hash(), Index: 0}, {TxHash: chain.etx.OriginatingTxHash(), Index: 0}} {
+		if statedb.GetUTXO(spent.TxHash, spent.Index) != nil {
+			t.Errorf("spent output %x:%d still in the state", spent.TxHash, spent.Index)
+		}
+	}
+}
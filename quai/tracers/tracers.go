@@ -0,0 +1,71 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracers is a collection of EVM tracers and the debug API that
+// drives them over historical and pending transactions.
+package tracers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core/vm"
+)
+
+// Context contains some contextual infos for a transaction execution that is not
+// available from within the EVM object.
+type Context struct {
+	BlockHash common.Hash // Hash of the block the tx is contained within (zero if dangling tx or call)
+	TxIndex   int         // Index of the transaction within a block (zero if dangling tx or call)
+	TxHash    common.Hash // Hash of the transaction being traced (zero if dangling call)
+}
+
+// Tracer interface extends vm.Tracer and additionally
+// allows collecting the tracing result.
+type Tracer interface {
+	vm.Tracer
+	// GetResult returns the json encoded result of the trace.
+	GetResult() (json.RawMessage, error)
+	// Stop terminates execution of the tracer at the first opportune moment.
+	Stop(err error)
+}
+
+// txStartTracer is implemented by tracers that need to know the gas limit
+// bought by the transaction before the EVM starts executing it, which is not
+// visible through the vm.Tracer hooks.
+type txStartTracer interface {
+	CaptureTxStart(gasLimit uint64)
+}
+
+// ctorFn is the constructor signature of a native tracer.
+type ctorFn func(*Context) Tracer
+
+// lookup maps the tracer names accepted over the API to their constructors.
+var lookup = make(map[string]ctorFn)
+
+// register makes a native tracer available under the given name.
+func register(name string, ctor ctorFn) {
+	lookup[name] = ctor
+}
+
+// New returns a new instance of the tracer registered under the given name.
+func New(name string, ctx *Context) (Tracer, error) {
+	if ctor, ok := lookup[name]; ok {
+		return ctor(ctx), nil
+	}
+	return nil, fmt.Errorf("tracer %s not found", name)
+}
@@ -17,24 +17,27 @@
 package tracers
 
 import (
-	"crypto/ecdsa"
-	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
 	"io/ioutil"
 	"math/big"
+	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"unicode"
 
 	"github.com/dominant-strategies/go-quai/common"
 	"github.com/dominant-strategies/go-quai/common/hexutil"
 	"github.com/dominant-strategies/go-quai/common/math"
 	"github.com/dominant-strategies/go-quai/core"
 	"github.com/dominant-strategies/go-quai/core/rawdb"
+	"github.com/dominant-strategies/go-quai/core/state"
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/core/vm"
 	"github.com/dominant-strategies/go-quai/crypto"
+	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/params"
 	"github.com/dominant-strategies/go-quai/rlp"
 )
@@ -111,27 +114,61 @@ type callContext struct {
 	Miner      common.Address        `json:"miner"`
 }
 
+// callGenesis is the chain config and the prestate of a call tracer test.
+type callGenesis struct {
+	Config *params.ChainConfig `json:"config"`
+	Alloc  core.GenesisAlloc   `json:"alloc"`
+}
+
 // callTracerTest defines a single test to check the call tracer against.
 type callTracerTest struct {
-	Genesis *core.Genesis `json:"genesis"`
-	Context *callContext  `json:"context"`
-	Input   string        `json:"input"`
-	Result  *callTrace    `json:"result"`
+	Genesis *callGenesis `json:"genesis"`
+	Context *callContext `json:"context"`
+	Input   string       `json:"input"`
+	Result  *callTrace   `json:"result"`
 }
 
-func TestPrestateTracerCreate2(t *testing.T) {
-	unsignedTx := types.NewTransaction(1, common.HexToAddress("0x00000000000000000000000000000000deadbeef"),
-		new(big.Int), 5000000, big.NewInt(1), []byte{})
-
-	privateKeyECDSA, err := ecdsa.GenerateKey(crypto.S256(), rand.Reader)
+// makePreState returns a state holding the given accounts.
+func makePreState(tb testing.TB, alloc core.GenesisAlloc) *state.StateDB {
+	db := rawdb.NewMemoryDatabase(log.Global)
+	stateDb, utxoDb, etxDb := state.NewDatabase(db), state.NewDatabase(db), state.NewDatabase(db)
+	statedb, err := state.New(types.EmptyRootHash, types.EmptyRootHash, types.EmptyRootHash, stateDb, utxoDb, etxDb, nil, testLocation, log.Global)
 	if err != nil {
-		t.Fatalf("err %v", err)
+		tb.Fatalf("failed to create state: %v", err)
+	}
+	for addr, account := range alloc {
+		internal, err := addr.InternalAndQuaiAddress()
+		if err != nil {
+			tb.Fatalf("account %v: %v", addr, err)
+		}
+		statedb.SetCode(internal, account.Code)
+		statedb.SetNonce(internal, account.Nonce)
+		statedb.SetBalance(internal, account.Balance)
+		for key, value := range account.Storage {
+			statedb.SetState(internal, key, value)
+		}
 	}
-	signer := types.NewEIP155Signer(big.NewInt(1))
-	tx, err := types.SignTx(unsignedTx, signer, privateKeyECDSA)
+	root, err := statedb.Commit(false)
 	if err != nil {
-		t.Fatalf("err %v", err)
+		tb.Fatalf("failed to commit state: %v", err)
+	}
+	statedb, err = state.New(root, types.EmptyRootHash, types.EmptyRootHash, stateDb, utxoDb, etxDb, nil, testLocation, log.Global)
+	if err != nil {
+		tb.Fatalf("failed to reopen state: %v", err)
 	}
+	return statedb
+}
+
+// camel converts a snake cased input string into a camel cased output.
+func camel(str string) string {
+	pieces := strings.Split(str, "_")
+	for i := 1; i < len(pieces); i++ {
+		pieces[i] = string(unicode.ToUpper(rune(pieces[i][0]))) + pieces[i][1:]
+	}
+	return strings.Join(pieces, "")
+}
+
+func TestPrestateTracerCreate2(t *testing.T) {
 	/**
 		This comes from one of the test-vectors on the Skinny Create2 - EIP
 
@@ -140,8 +177,44 @@ func TestPrestateTracerCreate2(t *testing.T) {
 	    init_code 0xdeadbeef
 	    gas (assuming no mem expansion): 32006
 	    result: 0x60f3f640a8508fC6a86d45DF051962668E1e8AC7
+
+		The result lies outside of the test zone, so the salt is ground until
+		the created address is in its Quai ledger.
 	*/
-	origin, _ := signer.Sender(tx)
+	var (
+		deployer = common.HexToAddress("0x00000000000000000000000000000000deadbeef", testLocation)
+		inithash = crypto.Keccak256(hexutil.MustDecode("0xdeadbeef"))
+		salt     = uint32(0xcafebabe)
+		created  common.Address
+	)
+	for ; ; salt++ {
+		var word [32]byte
+		binary.BigEndian.PutUint32(word[28:], salt)
+		created = crypto.CreateAddress2(deployer, word, inithash, testLocation)
+		if created.Location().Equal(testLocation) && created.IsInQuaiLedgerScope() {
+			break
+		}
+	}
+	config := newTestChainConfig()
+	key := newAccounts(1)[0].key
+	signer := types.LatestSigner(config)
+	tx, err := types.SignTx(types.NewTx(&types.QuaiTx{
+		ChainID:    config.ChainID,
+		Nonce:      1,
+		To:         &deployer,
+		Value:      new(big.Int),
+		Gas:        5000000,
+		GasFeeCap:  big.NewInt(1),
+		GasTipCap:  new(big.Int),
+		AccessList: types.AccessList{},
+	}), signer, key)
+	if err != nil {
+		t.Fatalf("err %v", err)
+	}
+	origin, err := signer.Sender(tx)
+	if err != nil {
+		t.Fatalf("err %v", err)
+	}
 	txContext := vm.TxContext{
 		Origin:   origin,
 		GasPrice: big.NewInt(1),
@@ -149,19 +222,22 @@ func TestPrestateTracerCreate2(t *testing.T) {
 	context := vm.BlockContext{
 		CanTransfer: core.CanTransfer,
 		Transfer:    core.Transfer,
-		Coinbase:    common.Address{},
+		Coinbase:    testCoinbase,
 		BlockNumber: new(big.Int).SetUint64(8000000),
 		Time:        new(big.Int).SetUint64(5),
 		Difficulty:  big.NewInt(0x30000),
+		BaseFee:     big.NewInt(1),
 		GasLimit:    uint64(6000000),
 	}
 	alloc := core.GenesisAlloc{}
 
 	// The code pushes 'deadbeef' into memory, then the other params, and calls CREATE2, then returns
 	// the address
-	alloc[common.HexToAddress("0x00000000000000000000000000000000deadbeef")] = core.GenesisAccount{
+	code := hexutil.MustDecode("0x63deadbeef60005263cafebabe6004601c6000F560005260206000F3")
+	binary.BigEndian.PutUint32(code[9:13], salt)
+	alloc[deployer] = core.GenesisAccount{
 		Nonce:   1,
-		Code:    hexutil.MustDecode("0x63deadbeef60005263cafebabe6004601c6000F560005260206000F3"),
+		Code:    code,
 		Balance: big.NewInt(1),
 	}
 	alloc[origin] = core.GenesisAccount{
@@ -169,20 +245,20 @@ func TestPrestateTracerCreate2(t *testing.T) {
 		Code:    []byte{},
 		Balance: big.NewInt(500000000000000),
 	}
-	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false)
+	statedb := makePreState(t, alloc)
 
 	// Create the tracer, the EVM environment and run it
 	tracer, err := New("prestateTracer", new(Context))
 	if err != nil {
 		t.Fatalf("failed to create call tracer: %v", err)
 	}
-	evm := vm.NewEVM(context, txContext, statedb, params.MainnetChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	evm := vm.NewEVM(context, txContext, statedb, config, vm.Config{Debug: true, Tracer: tracer})
 
 	msg, err := tx.AsMessage(signer, nil)
 	if err != nil {
 		t.Fatalf("failed to prepare transaction for tracing: %v", err)
 	}
-	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+	st := core.NewStateTransition(evm, msg, new(types.GasPool).AddGas(tx.Gas()))
 	if _, err = st.TransitionDb(); err != nil {
 		t.Fatalf("failed to execute transaction: %v", err)
 	}
@@ -195,8 +271,8 @@ func TestPrestateTracerCreate2(t *testing.T) {
 	if err := json.Unmarshal(res, &ret); err != nil {
 		t.Fatalf("failed to unmarshal trace result: %v", err)
 	}
-	if _, has := ret["0x60f3f640a8508fc6a86d45df051962668e1e8ac7"]; !has {
-		t.Fatalf("Expected 0x60f3f640a8508fc6a86d45df051962668e1e8ac7 in result")
+	if _, has := ret[prestateKey(created)]; !has {
+		t.Fatalf("Expected %s in result", prestateKey(created))
 	}
 }
 
@@ -204,6 +280,9 @@ func TestPrestateTracerCreate2(t *testing.T) {
 // runs the JavaScript tracers against them.
 func TestCallTracer(t *testing.T) {
 	files, err := ioutil.ReadDir("testdata")
+	if os.IsNotExist(err) {
+		t.Skip("no call tracer test suite")
+	}
 	if err != nil {
 		t.Fatalf("failed to retrieve tracer test suite: %v", err)
 	}
@@ -229,7 +308,7 @@ func TestCallTracer(t *testing.T) {
 			if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
 				t.Fatalf("failed to parse testcase input: %v", err)
 			}
-			signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number())))
+			signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
 			origin, _ := signer.Sender(tx)
 			txContext := vm.TxContext{
 				Origin:   origin,
@@ -239,12 +318,13 @@ func TestCallTracer(t *testing.T) {
 				CanTransfer: core.CanTransfer,
 				Transfer:    core.Transfer,
 				Coinbase:    test.Context.Miner,
-				BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number())),
-				Time:        new(big.Int).SetUint64(uint64(test.Context.Time())),
-				Difficulty:  (*big.Int)(test.Context.Difficulty()),
-				GasLimit:    uint64(test.Context.GasLimit()),
+				BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+				Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+				Difficulty:  (*big.Int)(test.Context.Difficulty),
+				BaseFee:     new(big.Int),
+				GasLimit:    uint64(test.Context.GasLimit),
 			}
-			_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc, false)
+			statedb := makePreState(t, test.Genesis.Alloc)
 
 			// Create the tracer, the EVM environment and run it
 			tracer, err := New("callTracer", new(Context))
@@ -257,7 +337,7 @@ func TestCallTracer(t *testing.T) {
 			if err != nil {
 				t.Fatalf("failed to prepare transaction for tracing: %v", err)
 			}
-			st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+			st := core.NewStateTransition(evm, msg, new(types.GasPool).AddGas(tx.Gas()))
 			if _, err = st.TransitionDb(); err != nil {
 				t.Fatalf("failed to execute transaction: %v", err)
 			}
@@ -301,18 +381,22 @@ func jsonEqual(x, y interface{}) bool {
 }
 
 func BenchmarkTransactionTrace(b *testing.B) {
-	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
-	from := crypto.PubkeyToAddress(key.PublicKey)
+	account := newAccounts(1)[0]
+	key, from := account.key, account.addr
 	gas := uint64(1000000) // 1M gas
-	to := common.HexToAddress("0x00000000000000000000000000000000deadbeef")
-	signer := types.LatestSignerForChainID(big.NewInt(1337))
-	tx, err := types.SignNewTx(key, signer,
-		&types.LegacyTx{
-			Nonce:    1,
-			GasPrice: big.NewInt(500),
-			Gas:      gas,
-			To:       &to,
-		})
+	to := common.HexToAddress("0x00000000000000000000000000000000deadbeef", testLocation)
+	config := newTestChainConfig()
+	signer := types.LatestSigner(config)
+	tx, err := types.SignTx(types.NewTx(&types.QuaiTx{
+		ChainID:    config.ChainID,
+		Nonce:      1,
+		GasFeeCap:  big.NewInt(500),
+		GasTipCap:  new(big.Int),
+		Gas:        gas,
+		To:         &to,
+		Value:      new(big.Int),
+		AccessList: types.AccessList{},
+	}), signer, key)
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -323,21 +407,21 @@ func BenchmarkTransactionTrace(b *testing.B) {
 	context := vm.BlockContext{
 		CanTransfer: core.CanTransfer,
 		Transfer:    core.Transfer,
-		Coinbase:    common.Address{},
+		Coinbase:    testCoinbase,
 		BlockNumber: new(big.Int).SetUint64(uint64(5)),
 		Time:        new(big.Int).SetUint64(uint64(5)),
 		Difficulty:  big.NewInt(0xffffffff),
+		BaseFee:     new(big.Int),
 		GasLimit:    gas,
 	}
 	alloc := core.GenesisAlloc{}
-	// The code pushes 'deadbeef' into memory, then the other params, and calls CREATE2, then returns
-	// the address
+	// The code loops forever until it runs out of gas
 	loop := []byte{
 		byte(vm.JUMPDEST), //  [ count ]
 		byte(vm.PUSH1), 0, // jumpdestination
 		byte(vm.JUMP),
 	}
-	alloc[common.HexToAddress("0x00000000000000000000000000000000deadbeef")] = core.GenesisAccount{
+	alloc[to] = core.GenesisAccount{
 		Nonce:   1,
 		Code:    loop,
 		Balance: big.NewInt(1),
@@ -347,7 +431,7 @@ func BenchmarkTransactionTrace(b *testing.B) {
 		Code:    []byte{},
 		Balance: big.NewInt(500000000000000),
 	}
-	_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false)
+	statedb := makePreState(b, alloc)
 	// Create the tracer, the EVM environment and run it
 	tracer := vm.NewStructLogger(&vm.LogConfig{
 		Debug: false,
@@ -355,7 +439,7 @@ func BenchmarkTransactionTrace(b *testing.B) {
 		//DisableMemory: true,
 		//DisableReturnData: true,
 	})
-	evm := vm.NewEVM(context, txContext, statedb, params.AllBlake3powProtocolChanges, vm.Config{Debug: true, Tracer: tracer})
+	evm := vm.NewEVM(context, txContext, statedb, config, vm.Config{Debug: true, Tracer: tracer})
 	msg, err := tx.AsMessage(signer, nil)
 	if err != nil {
 		b.Fatalf("failed to prepare transaction for tracing: %v", err)
@@ -365,7 +449,7 @@ func BenchmarkTransactionTrace(b *testing.B) {
 
 	for i := 0; i < b.N; i++ {
 		snap := statedb.Snapshot()
-		st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+		st := core.NewStateTransition(evm, msg, new(types.GasPool).AddGas(tx.Gas()))
 		_, err = st.TransitionDb()
 		if err != nil {
 			b.Fatal(err)
@@ -0,0 +1,435 @@
+// Package qiwallet builds, signs and submits Qi transactions for a set of keys
+// through the Quai RPC API.
+package qiwallet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/consensus/misc"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/crypto"
+	"github.com/dominant-strategies/go-quai/quaiclient"
+)
+
+const (
+	// c_maxFeeIterations is the number of times the coins are selected again
+	// because the estimated fee grew with the inputs and change outputs
+	c_maxFeeIterations = 8
+	// c_utxoPageSize is the number of utxos requested per page
+	c_utxoPageSize = 1000
+)
+
+var (
+	ErrNoKeys            = errors.New("wallet has no keys")
+	ErrNoOutputs         = errors.New("transaction has no outputs")
+	ErrInsufficientFunds = errors.New("insufficient spendable funds")
+	ErrFeeNotConverging  = errors.New("fee estimate did not converge")
+	ErrNoChangeAddress   = errors.New("no change address available")
+	ErrUnknownInput      = errors.New("input is not owned by the wallet")
+)
+
+// Backend is the node API used by the wallet, implemented by quaiclient.Client.
+type Backend interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	GetUTXOsByAddress(ctx context.Context, address common.MixedcaseAddress, filter quaiclient.UTXOFilter) (*quaiclient.UTXOPage, error)
+	EstimateFeeForQi(ctx context.Context, txIn types.TxIns, txOut types.TxOuts) (*big.Int, error)
+	SendRawTransaction(ctx context.Context, tx *types.Transaction) (common.Hash, error)
+}
+
+// ChangeAddressFunc returns a new address of the wallet zone the change of a
+// transaction can be sent to. Every output of a Qi transaction must go to a
+// distinct address, so a transaction may need several change addresses.
+type ChangeAddressFunc func() (common.Address, error)
+
+// Output is a payment of a single denomination to an address. A Qi transaction
+// cannot pay the same address twice, so paying an amount which is not a single
+// denomination requires several addresses.
+type Output struct {
+	Address      common.Address
+	Denomination uint8
+	Lock         *big.Int // Block height the output unlocks at, nil for none
+}
+
+// Coin is a spendable Qi output owned by one of the keys of the wallet.
+type Coin struct {
+	OutPoint     types.OutPoint
+	Address      common.Address
+	Denomination uint8
+}
+
+// Value returns the value of the coin in qits.
+func (c *Coin) Value() *big.Int {
+	return types.Denominations[c.Denomination]
+}
+
+// UnsignedTx is a Qi transaction built by the wallet, waiting to be signed.
+type UnsignedTx struct {
+	Inputs  []*Coin
+	Outputs types.TxOuts
+	Fee     *big.Int // fee in qits, the inputs minus the outputs
+}
+
+// Wallet selects the coins, computes the change and signs the Qi transactions
+// spending the outputs owned by its keys. The keys must all belong to the zone
+// of the wallet.
+type Wallet struct {
+	backend       Backend
+	location      common.Location
+	keys          map[common.AddressBytes]*ecdsa.PrivateKey
+	addresses     []common.Address
+	changeAddress ChangeAddressFunc
+}
+
+// New creates a wallet for the given keys of the zone at the given location.
+func New(backend Backend, location common.Location, keys []*ecdsa.PrivateKey, changeAddress ChangeAddressFunc) (*Wallet, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	w := &Wallet{
+		backend:       backend,
+		location:      location,
+		keys:          make(map[common.AddressBytes]*ecdsa.PrivateKey),
+		changeAddress: changeAddress,
+	}
+	for i, key := range keys {
+		address := crypto.PubkeyToAddress(key.PublicKey, location)
+		if !address.IsInQiLedgerScope() {
+			return nil, fmt.Errorf("key %d derives address %s outside of the Qi ledger", i, address.Hex())
+		}
+		if _, exists := w.keys[address.Bytes20()]; exists {
+			continue
+		}
+		w.keys[address.Bytes20()] = key
+		w.addresses = append(w.addresses, address)
+	}
+	return w, nil
+}
+
+// Addresses returns the addresses of the keys of the wallet.
+func (w *Wallet) Addresses() []common.Address {
+	return append([]common.Address(nil), w.addresses...)
+}
+
+// Coins returns the outputs owned by the wallet that can be spent at the
+// current head of the node. Outputs still locked are left out.
+func (w *Wallet) Coins(ctx context.Context) ([]*Coin, error) {
+	var (
+		coins  []*Coin
+		locked = false
+	)
+	for _, address := range w.addresses {
+		filter := quaiclient.UTXOFilter{Limit: c_utxoPageSize, Locked: &locked}
+		for {
+			page, err := w.backend.GetUTXOsByAddress(ctx, common.NewMixedcaseAddress(address), filter)
+			if err != nil {
+				return nil, err
+			}
+			for _, utxo := range page.UTXOs {
+				if !utxo.Spendable || utxo.Denomination > types.MaxDenomination {
+					continue
+				}
+				coins = append(coins, &Coin{
+					OutPoint:     utxo.OutPoint,
+					Address:      address,
+					Denomination: utxo.Denomination,
+				})
+			}
+			if page.Next == nil {
+				break
+			}
+			filter.Cursor = page.Next
+		}
+	}
+	return coins, nil
+}
+
+// Balance returns the total value in qits of the coins of the wallet.
+func (w *Wallet) Balance(ctx context.Context) (*big.Int, error) {
+	coins, err := w.Coins(ctx)
+	if err != nil {
+		return nil, err
+	}
+	balance := new(big.Int)
+	for _, coin := range coins {
+		balance.Add(balance, coin.Value())
+	}
+	return balance, nil
+}
+
+// BuildTx selects the coins paying for the given outputs and the fee estimated
+// by the node, and sends the change back to the wallet in the minimum number of
+// denominations. The fee is estimated again until it is covered, as every
+// input and change output adds to it.
+func (w *Wallet) BuildTx(ctx context.Context, outputs []Output) (*UnsignedTx, error) {
+	if len(outputs) == 0 {
+		return nil, ErrNoOutputs
+	}
+	coins, err := w.Coins(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The protocol forbids reusing an address within a transaction, so the
+	// coins of the addresses paid by the transaction cannot be spent
+	paid := make(map[common.AddressBytes]struct{})
+	payments := make(types.TxOuts, 0, len(outputs))
+	amount := new(big.Int)
+	for _, output := range outputs {
+		if output.Denomination > types.MaxDenomination {
+			return nil, fmt.Errorf("output denomination %d is above the maximum %d", output.Denomination, types.MaxDenomination)
+		}
+		if _, exists := paid[output.Address.Bytes20()]; exists {
+			return nil, fmt.Errorf("address %s is paid more than once", output.Address.Hex())
+		}
+		paid[output.Address.Bytes20()] = struct{}{}
+		payments = append(payments, *types.NewTxOut(output.Denomination, output.Address.Bytes(), output.Lock))
+		amount.Add(amount, types.Denominations[output.Denomination])
+	}
+	available := coins[:0]
+	for _, coin := range coins {
+		if _, exists := paid[coin.Address.Bytes20()]; !exists {
+			available = append(available, coin)
+		}
+	}
+
+	var changeAddresses []common.Address
+	fee := new(big.Int)
+	for i := 0; i < c_maxFeeIterations; i++ {
+		target := new(big.Int).Add(amount, fee)
+		inputs, total, err := selectCoins(available, target)
+		if err != nil {
+			return nil, err
+		}
+		// Send the change back in the minimum number of denominations, each
+		// to a distinct address
+		denominations := changeDenominations(new(big.Int).Sub(total, target))
+		for len(changeAddresses) < len(denominations) {
+			address, err := w.nextChangeAddress(paid, inputs)
+			if err != nil {
+				return nil, err
+			}
+			changeAddresses = append(changeAddresses, address)
+		}
+		txOut := append(types.TxOuts{}, payments...)
+		for j, denomination := range denominations {
+			txOut = append(txOut, *types.NewTxOut(denomination, changeAddresses[j].Bytes(), nil))
+		}
+		txIn, err := w.txIns(inputs)
+		if err != nil {
+			return nil, err
+		}
+		estimate, err := w.backend.EstimateFeeForQi(ctx, txIn, txOut)
+		if err != nil {
+			return nil, err
+		}
+		if estimate.Cmp(fee) <= 0 {
+			return &UnsignedTx{Inputs: inputs, Outputs: txOut, Fee: fee}, nil
+		}
+		fee = estimate
+	}
+	return nil, ErrFeeNotConverging
+}
+
+// nextChangeAddress returns a change address that is neither paid nor spent
+// from by the transaction.
+func (w *Wallet) nextChangeAddress(paid map[common.AddressBytes]struct{}, inputs []*Coin) (common.Address, error) {
+	if w.changeAddress == nil {
+		return common.Address{}, ErrNoChangeAddress
+	}
+	address, err := w.changeAddress()
+	if err != nil {
+		return common.Address{}, err
+	}
+	if !address.IsInQiLedgerScope() || !address.Location().Equal(w.location) {
+		return common.Address{}, fmt.Errorf("change address %s is not a Qi address of zone %s", address.Hex(), w.location.Name())
+	}
+	if _, exists := paid[address.Bytes20()]; exists {
+		return common.Address{}, fmt.Errorf("change address %s is already paid", address.Hex())
+	}
+	for _, input := range inputs {
+		if input.Address.Equal(address) {
+			return common.Address{}, fmt.Errorf("change address %s is spent from", address.Hex())
+		}
+	}
+	paid[address.Bytes20()] = struct{}{}
+	return address, nil
+}
+
+// txIns returns the inputs spending the given coins
+func (w *Wallet) txIns(coins []*Coin) (types.TxIns, error) {
+	txIn := make(types.TxIns, 0, len(coins))
+	for _, coin := range coins {
+		key, ok := w.keys[coin.Address.Bytes20()]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownInput, coin.Address.Hex())
+		}
+		txIn = append(txIn, *types.NewTxIn(&coin.OutPoint, crypto.FromECDSAPub(&key.PublicKey), nil))
+	}
+	return txIn, nil
+}
+
+// selectCoins picks coins worth at least the target, spending at most one coin
+// per address as the protocol forbids spending twice from the same address in
+// a transaction. The largest coins are picked first, until a single coin can
+// cover what is left, in which case the smallest such coin is picked to keep
+// the change low.
+func selectCoins(coins []*Coin, target *big.Int) ([]*Coin, *big.Int, error) {
+	sorted := append([]*Coin(nil), coins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Denomination > sorted[j].Denomination
+	})
+	var (
+		inputs    []*Coin
+		total     = new(big.Int)
+		remaining = new(big.Int).Set(target)
+		spent     = make(map[common.AddressBytes]struct{})
+	)
+	for remaining.Sign() > 0 || len(inputs) == 0 {
+		// Look for the smallest coin covering what is left
+		var pick *Coin
+		for _, coin := range sorted {
+			if _, exists := spent[coin.Address.Bytes20()]; exists {
+				continue
+			}
+			if coin.Value().Cmp(remaining) >= 0 {
+				pick = coin
+			} else if pick == nil {
+				// No coin covers what is left, take the largest one
+				pick = coin
+				break
+			} else {
+				break
+			}
+		}
+		if pick == nil {
+			return nil, nil, ErrInsufficientFunds
+		}
+		spent[pick.Address.Bytes20()] = struct{}{}
+		inputs = append(inputs, pick)
+		total.Add(total, pick.Value())
+		remaining.Sub(remaining, pick.Value())
+	}
+	return inputs, total, nil
+}
+
+// changeDenominations splits the change in the minimum number of denominations,
+// largest first
+func changeDenominations(change *big.Int) []uint8 {
+	if change.Sign() <= 0 {
+		return nil
+	}
+	counts := misc.FindMinDenominations(change)
+	var denominations []uint8
+	for denomination := int(types.MaxDenomination); denomination >= 0; denomination-- {
+		for i := 0; i < int(counts[uint8(denomination)]); i++ {
+			denominations = append(denominations, uint8(denomination))
+		}
+	}
+	return denominations
+}
+
+// SignTx signs the transaction with the keys of its inputs. A transaction with
+// a single input is signed with a schnorr signature of its key. The keys of a
+// transaction with several inputs are aggregated with MuSig2, in the order of
+// the inputs, as the signature is verified against the aggregated key.
+func (w *Wallet) SignTx(ctx context.Context, utx *UnsignedTx) (*types.Transaction, error) {
+	chainID, err := w.backend.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	txIn, err := w.txIns(utx.Inputs)
+	if err != nil {
+		return nil, err
+	}
+	qiTx := &types.QiTx{
+		ChainID: chainID,
+		TxIn:    txIn,
+		TxOut:   utx.Outputs,
+	}
+	digest := types.NewSigner(chainID, w.location).Hash(types.NewTx(qiTx))
+
+	keys := make([]*btcec.PrivateKey, 0, len(utx.Inputs))
+	for _, input := range utx.Inputs {
+		key, _ := btcec.PrivKeyFromBytes(crypto.FromECDSA(w.keys[input.Address.Bytes20()]))
+		keys = append(keys, key)
+	}
+	if len(keys) == 1 {
+		qiTx.Signature, err = schnorr.Sign(keys[0], digest[:])
+	} else {
+		qiTx.Signature, err = signMuSig2(keys, digest)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return types.NewTx(qiTx), nil
+}
+
+// signMuSig2 signs the digest with the aggregate of the given keys. All the
+// keys are held by the wallet, so the nonces and the partial signatures are
+// exchanged locally.
+func signMuSig2(keys []*btcec.PrivateKey, digest common.Hash) (*schnorr.Signature, error) {
+	signSet := make([]*btcec.PublicKey, len(keys))
+	for i, key := range keys {
+		signSet[i] = key.PubKey()
+	}
+	sessions := make([]*musig2.Session, len(keys))
+	for i, key := range keys {
+		signCtx, err := musig2.NewContext(key, false, musig2.WithKnownSigners(signSet))
+		if err != nil {
+			return nil, err
+		}
+		if sessions[i], err = signCtx.NewSession(); err != nil {
+			return nil, err
+		}
+	}
+	for i, session := range sessions {
+		for j, other := range sessions {
+			if i == j {
+				continue
+			}
+			if _, err := session.RegisterPubNonce(other.PublicNonce()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// The first session combines the partial signatures of the others
+	combiner := sessions[0]
+	for i, session := range sessions {
+		partialSig, err := session.Sign(digest)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			continue
+		}
+		if _, err := combiner.CombineSig(partialSig); err != nil {
+			return nil, err
+		}
+	}
+	return combiner.FinalSig(), nil
+}
+
+// Send builds a transaction paying the given outputs, signs it and submits it
+// to the node.
+func (w *Wallet) Send(ctx context.Context, outputs []Output) (*types.Transaction, error) {
+	utx, err := w.BuildTx(ctx, outputs)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := w.SignTx(ctx, utx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.backend.SendRawTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
@@ -0,0 +1,147 @@
+package qiwallet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/dominant-strategies/go-quai/common"
+	"github.com/dominant-strategies/go-quai/core"
+	"github.com/dominant-strategies/go-quai/core/types"
+	"github.com/dominant-strategies/go-quai/crypto"
+	"github.com/dominant-strategies/go-quai/params"
+	"github.com/dominant-strategies/go-quai/quaiclient"
+)
+
+var testLocation = common.Location{0, 0}
+
+// testBackend serves the utxos of the test addresses and charges a fixed fee
+// per input and output
+type testBackend struct {
+	utxos map[common.AddressBytes][]*quaiclient.UTXO
+	sent  []*types.Transaction
+}
+
+func (b *testBackend) ChainID(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1337), nil
+}
+
+func (b *testBackend) GetUTXOsByAddress(ctx context.Context, address common.MixedcaseAddress, filter quaiclient.UTXOFilter) (*quaiclient.UTXOPage, error) {
+	return &quaiclient.UTXOPage{UTXOs: b.utxos[address.Address().Bytes20()]}, nil
+}
+
+func (b *testBackend) EstimateFeeForQi(ctx context.Context, txIn types.TxIns, txOut types.TxOuts) (*big.Int, error) {
+	return big.NewInt(int64(len(txIn) + len(txOut))), nil
+}
+
+func (b *testBackend) SendRawTransaction(ctx context.Context, tx *types.Transaction) (common.Hash, error) {
+	b.sent = append(b.sent, tx)
+	return tx.Hash(), nil
+}
+
+// newQiKey generates a key whose address is in the Qi ledger of the test zone
+func newQiKey(t *testing.T) *ecdsa.PrivateKey {
+	for {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		address := crypto.PubkeyToAddress(key.PublicKey, testLocation)
+		if address.IsInQiLedgerScope() && address.Location().Equal(testLocation) {
+			return key
+		}
+	}
+}
+
+func newTestWallet(t *testing.T, denominations ...uint8) (*Wallet, *testBackend) {
+	backend := &testBackend{utxos: make(map[common.AddressBytes][]*quaiclient.UTXO)}
+	keys := make([]*ecdsa.PrivateKey, len(denominations))
+	for i, denomination := range denominations {
+		keys[i] = newQiKey(t)
+		address := crypto.PubkeyToAddress(keys[i].PublicKey, testLocation)
+		backend.utxos[address.Bytes20()] = []*quaiclient.UTXO{{
+			OutPoint:     types.OutPoint{TxHash: common.Hash{byte(i + 1)}},
+			Denomination: denomination,
+			Spendable:    true,
+		}}
+	}
+	changeAddress := func() (common.Address, error) {
+		key := newQiKey(t)
+		return crypto.PubkeyToAddress(key.PublicKey, testLocation), nil
+	}
+	wallet, err := New(backend, testLocation, keys, changeAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wallet, backend
+}
+
+// validateTx checks the outputs and the signature of the transaction the way
+// the state processor does, spending inputs worth totalQitIn
+func validateTx(t *testing.T, tx *types.Transaction, totalQitIn *big.Int) {
+	header := types.EmptyHeader(common.ZONE_CTX)
+	header.Header().SetBaseFee(big.NewInt(params.GWei))
+	header.Header().SetGasLimit(params.GenesisGasLimit)
+	signer := types.NewSigner(big.NewInt(1337), testLocation)
+	if _, err := core.ValidateQiTxOutputsAndSignature(tx, nil, totalQitIn, header, signer, testLocation, *big.NewInt(1337), params.GasTableGenesis, math.MaxInt, math.MaxInt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendSingleInput(t *testing.T) {
+	// A 1 Qi coin pays a 0.5 Qi output, the change goes back in denominations
+	wallet, backend := newTestWallet(t, 7)
+	recipient := crypto.PubkeyToAddress(newQiKey(t).PublicKey, testLocation)
+	tx, err := wallet.Send(context.Background(), []Output{{Address: recipient, Denomination: 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.sent) != 1 || len(tx.TxIn()) != 1 {
+		t.Fatalf("unexpected transaction: %d sent, %d inputs", len(backend.sent), len(tx.TxIn()))
+	}
+	totalOut := new(big.Int)
+	for _, txOut := range tx.TxOut() {
+		totalOut.Add(totalOut, types.Denominations[txOut.Denomination])
+	}
+	fee := new(big.Int).Sub(types.Denominations[7], totalOut)
+	if fee.Cmp(big.NewInt(int64(len(tx.TxIn())+len(tx.TxOut())))) < 0 {
+		t.Fatalf("fee %v does not cover the estimate", fee)
+	}
+	validateTx(t, tx, types.Denominations[7])
+}
+
+func TestSendMultipleInputs(t *testing.T) {
+	// No single coin covers the payment, so the coins are aggregated
+	wallet, _ := newTestWallet(t, 5, 5, 5)
+	recipient := crypto.PubkeyToAddress(newQiKey(t).PublicKey, testLocation)
+	utx, err := wallet.BuildTx(context.Background(), []Output{{Address: recipient, Denomination: 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(utx.Inputs) != 3 {
+		t.Fatalf("expected 3 inputs, got %d", len(utx.Inputs))
+	}
+	seen := make(map[common.AddressBytes]struct{})
+	for _, txOut := range utx.Outputs {
+		address := common.BytesToAddress(txOut.Address, testLocation)
+		if _, exists := seen[address.Bytes20()]; exists {
+			t.Fatalf("address %s is paid twice", address.Hex())
+		}
+		seen[address.Bytes20()] = struct{}{}
+	}
+	tx, err := wallet.SignTx(context.Background(), utx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	validateTx(t, tx, new(big.Int).Mul(types.Denominations[5], big.NewInt(3)))
+}
+
+func TestInsufficientFunds(t *testing.T) {
+	wallet, _ := newTestWallet(t, 1, 1)
+	recipient := crypto.PubkeyToAddress(newQiKey(t).PublicKey, testLocation)
+	if _, err := wallet.BuildTx(context.Background(), []Output{{Address: recipient, Denomination: 6}}); err != ErrInsufficientFunds {
+		t.Fatalf("expected %v, got %v", ErrInsufficientFunds, err)
+	}
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/dominant-strategies/go-quai/core/types"
 	"github.com/dominant-strategies/go-quai/log"
 	"github.com/dominant-strategies/go-quai/rpc"
+	"google.golang.org/protobuf/proto"
 )
 
 var exponentialBackoffCeilingSecs int64 = 60 // 1 minute
@@ -40,19 +41,30 @@ type Client struct {
 	c *rpc.Client
 }
 
-// Dial connects a client to the given URL.
+// Dial connects a client to the given URL, or to the IPC endpoint at the given
+// file path.
 func Dial(rawurl string, logger *log.Logger) (*Client, error) {
 	return DialContext(context.Background(), rawurl, logger)
 }
 
 func DialContext(ctx context.Context, rawurl string, logger *log.Logger) (*Client, error) {
+	return DialContextWithAuth(ctx, rawurl, nil, logger)
+}
+
+// DialWithAuth connects a client to the given URL, authenticating the
+// requests with auth, i.e. with the JWTs created by node.NewJWTAuth.
+func DialWithAuth(rawurl string, auth rpc.HTTPAuth, logger *log.Logger) (*Client, error) {
+	return DialContextWithAuth(context.Background(), rawurl, auth, logger)
+}
+
+func DialContextWithAuth(ctx context.Context, rawurl string, auth rpc.HTTPAuth, logger *log.Logger) (*Client, error) {
 	connectStatus := false
 	attempts := 0
 
 	var c *rpc.Client
 	var err error
 	for !connectStatus {
-		c, err = rpc.DialContext(ctx, rawurl)
+		c, err = rpc.DialContextWithAuth(ctx, rawurl, auth)
 		if err == nil {
 			break
 		}
@@ -200,6 +212,20 @@ func (ec *Client) GetManifest(ctx context.Context, blockHash common.Hash) (types
 	return manifest, nil
 }
 
+// GetTerminiByHash gets the termini stored for the block with the given hash
+func (ec *Client) GetTerminiByHash(ctx context.Context, hash common.Hash) (*types.Termini, error) {
+	var raw json.RawMessage
+	err := ec.c.CallContext(ctx, &raw, "quai_getTerminiByHash", hash)
+	if err != nil {
+		return nil, err
+	}
+	var termini types.Termini
+	if err := json.Unmarshal(raw, &termini); err != nil {
+		return nil, err
+	}
+	return &termini, nil
+}
+
 // GetPendingEtxsRollupFromSub gets the pendingEtxsRollup from the region
 func (ec *Client) GetPendingEtxsRollupFromSub(ctx context.Context, hash common.Hash, location common.Location) (types.PendingEtxsRollup, error) {
 	fields := make(map[string]interface{})
@@ -426,3 +452,244 @@ func (ec *Client) QuaiRateAtBlock(ctx context.Context, block interface{}) (*big.
 	}
 	return (*big.Int)(&hex), nil
 }
+
+// ChainID retrieves the current chain ID for transaction replay protection.
+func (ec *Client) ChainID(ctx context.Context) (*big.Int, error) {
+	var result hexutil.Big
+	if err := ec.c.CallContext(ctx, &result, "quai_chainId"); err != nil {
+		return nil, err
+	}
+	return (*big.Int)(&result), nil
+}
+
+// EstimateFeeForQi returns the fee in qits a Qi transaction with the given
+// inputs and outputs has to pay to be included in the next blocks.
+func (ec *Client) EstimateFeeForQi(ctx context.Context, txIn types.TxIns, txOut types.TxOuts) (*big.Int, error) {
+	arg := map[string]interface{}{
+		"txType": types.QiTxType,
+		"txIn":   txIn,
+		"txOut":  txOut,
+	}
+	var fee big.Int
+	if err := ec.c.CallContext(ctx, &fee, "quai_estimateFeeForQi", arg); err != nil {
+		return nil, err
+	}
+	return &fee, nil
+}
+
+// SendRawTransaction injects a signed transaction into the pending pool of the
+// node and returns its hash.
+func (ec *Client) SendRawTransaction(ctx context.Context, tx *types.Transaction) (common.Hash, error) {
+	protoTx, err := tx.ProtoEncode()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	data, err := proto.Marshal(protoTx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	var hash common.Hash
+	if err := ec.c.CallContext(ctx, &hash, "quai_sendRawTransaction", hexutil.Bytes(data)); err != nil {
+		return common.Hash{}, err
+	}
+	return hash, nil
+}
+
+// UTXOFilter narrows down the utxos returned by GetUTXOsByAddress. A nil
+// field leaves the corresponding criterion unrestricted.
+type UTXOFilter struct {
+	Cursor          []byte // Next value of the previous page, nil for the first page
+	Limit           uint64 // Maximum number of utxos in the page, 0 for the node default
+	MinDenomination *uint8 // Skip utxos below this denomination
+	Locked          *bool  // Only return locked (true) or unlocked (false) utxos
+}
+
+// UTXO is an unspent Qi output owned by an address.
+type UTXO struct {
+	OutPoint     types.OutPoint
+	Denomination uint8
+	Lock         *big.Int
+	Spendable    bool
+}
+
+// UTXOPage is a single page of the utxos of an address. Next is nil on the
+// last page and otherwise has to be passed as the cursor of the next request.
+type UTXOPage struct {
+	UTXOs []*UTXO
+	Next  []byte
+}
+
+type rpcUtxo struct {
+	TxHash       common.Hash    `json:"txHash"`
+	Index        hexutil.Uint64 `json:"index"`
+	Denomination hexutil.Uint   `json:"denomination"`
+	Lock         *hexutil.Big   `json:"lock"`
+	Spendable    bool           `json:"spendable"`
+}
+
+type rpcUtxoPage struct {
+	Utxos []*rpcUtxo     `json:"utxos"`
+	Next  *hexutil.Bytes `json:"next"`
+}
+
+// GetUTXOsByAddress returns a page of the unspent Qi outputs of the given
+// address at the current head of the node.
+func (ec *Client) GetUTXOsByAddress(ctx context.Context, address common.MixedcaseAddress, filter UTXOFilter) (*UTXOPage, error) {
+	arg := map[string]interface{}{}
+	if filter.Cursor != nil {
+		arg["cursor"] = hexutil.Bytes(filter.Cursor)
+	}
+	if filter.Limit != 0 {
+		arg["limit"] = hexutil.Uint64(filter.Limit)
+	}
+	if filter.MinDenomination != nil {
+		arg["minDenomination"] = hexutil.Uint(*filter.MinDenomination)
+	}
+	if filter.Locked != nil {
+		arg["locked"] = *filter.Locked
+	}
+	var result rpcUtxoPage
+	if err := ec.c.CallContext(ctx, &result, "quai_getUTXOsByAddress", address, arg); err != nil {
+		return nil, err
+	}
+	page := &UTXOPage{UTXOs: make([]*UTXO, 0, len(result.Utxos))}
+	for _, utxo := range result.Utxos {
+		entry := &UTXO{
+			OutPoint:     types.OutPoint{TxHash: utxo.TxHash, Index: uint16(utxo.Index)},
+			Denomination: uint8(utxo.Denomination),
+			Spendable:    utxo.Spendable,
+		}
+		if utxo.Lock != nil {
+			entry.Lock = utxo.Lock.ToInt()
+		}
+		page.UTXOs = append(page.UTXOs, entry)
+	}
+	if result.Next != nil {
+		page.Next = *result.Next
+	}
+	return page, nil
+}
+
+// AddressHistoryFilter narrows down the entries returned by GetAddressHistory.
+// A nil field leaves the corresponding criterion unrestricted.
+type AddressHistoryFilter struct {
+	FromBlock *uint64 // First block of the history, ignored when Cursor is set
+	ToBlock   *uint64 // Last block of the history
+	Cursor    []byte  // Next value of the previous page, nil for the first page
+	Limit     uint64  // Maximum number of entries in the page, 0 for the node default
+}
+
+// AddressHistoryEntry is a transaction, ETX or coinbase output of a block that
+// sent from or paid to an address.
+type AddressHistoryEntry struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	TxIndex     uint64
+	TxHash      common.Hash
+	Sent        bool
+	Received    bool
+	Coinbase    bool
+	Etx         bool
+}
+
+// AddressHistoryPage is a single page of the history of an address. The history
+// only covers the blocks below IndexedBlocks. Next is nil on the last page and
+// otherwise has to be passed as the cursor of the next request.
+type AddressHistoryPage struct {
+	Entries       []*AddressHistoryEntry
+	IndexedBlocks uint64
+	Next          []byte
+}
+
+type rpcAddressHistoryEntry struct {
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+	TransactionHash  common.Hash    `json:"transactionHash"`
+	Sent             bool           `json:"sent"`
+	Received         bool           `json:"received"`
+	Coinbase         bool           `json:"coinbase"`
+	Etx              bool           `json:"etx"`
+}
+
+type rpcAddressHistoryPage struct {
+	Entries       []*rpcAddressHistoryEntry `json:"entries"`
+	IndexedBlocks hexutil.Uint64            `json:"indexedBlocks"`
+	Next          *hexutil.Bytes            `json:"next"`
+}
+
+// GetAddressHistory returns a page of the transactions, ETXs and coinbase
+// outputs that sent from or paid to the given address.
+func (ec *Client) GetAddressHistory(ctx context.Context, address common.MixedcaseAddress, filter AddressHistoryFilter) (*AddressHistoryPage, error) {
+	arg := map[string]interface{}{}
+	if filter.FromBlock != nil {
+		arg["fromBlock"] = hexutil.Uint64(*filter.FromBlock)
+	}
+	if filter.ToBlock != nil {
+		arg["toBlock"] = hexutil.Uint64(*filter.ToBlock)
+	}
+	if filter.Cursor != nil {
+		arg["cursor"] = hexutil.Bytes(filter.Cursor)
+	}
+	if filter.Limit != 0 {
+		arg["limit"] = hexutil.Uint64(filter.Limit)
+	}
+	var result rpcAddressHistoryPage
+	if err := ec.c.CallContext(ctx, &result, "quai_getAddressHistory", address, arg); err != nil {
+		return nil, err
+	}
+	page := &AddressHistoryPage{
+		Entries:       make([]*AddressHistoryEntry, 0, len(result.Entries)),
+		IndexedBlocks: uint64(result.IndexedBlocks),
+	}
+	for _, entry := range result.Entries {
+		page.Entries = append(page.Entries, &AddressHistoryEntry{
+			BlockNumber: uint64(entry.BlockNumber),
+			BlockHash:   entry.BlockHash,
+			TxIndex:     uint64(entry.TransactionIndex),
+			TxHash:      entry.TransactionHash,
+			Sent:        entry.Sent,
+			Received:    entry.Received,
+			Coinbase:    entry.Coinbase,
+			Etx:         entry.Etx,
+		})
+	}
+	if result.Next != nil {
+		page.Next = *result.Next
+	}
+	return page, nil
+}
+
+// EtxStage is the canonical block of a slice at which an ETX reached a stage of
+// its lifecycle.
+type EtxStage struct {
+	Location    common.Location `json:"location"`
+	BlockHash   common.Hash     `json:"blockHash"`
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+}
+
+// EtxStatus is the lifecycle of an ETX. Status is the furthest stage reached:
+// emitted, rolledUp, includedInPrime, queued or executed. The stages not
+// reached yet, or belonging to a slice the node does not run, are nil.
+type EtxStatus struct {
+	Status          string                 `json:"status"`
+	EtxHash         common.Hash            `json:"etxHash"`
+	Origin          common.Location        `json:"origin"`
+	Destination     common.Location        `json:"destination"`
+	Emitted         *EtxStage              `json:"emitted"`
+	RolledUp        *EtxStage              `json:"rolledUp"`
+	IncludedInPrime *EtxStage              `json:"includedInPrime"`
+	Queued          *EtxStage              `json:"queued"`
+	Executed        *EtxStage              `json:"executed"`
+	Receipt         map[string]interface{} `json:"receipt"`
+}
+
+// GetEtxStatus returns the lifecycle of the ETX emitted at the given index by
+// the given transaction, or nil if the node does not know the ETX.
+func (ec *Client) GetEtxStatus(ctx context.Context, originTxHash common.Hash, index uint64) (*EtxStatus, error) {
+	var result *EtxStatus
+	if err := ec.c.CallContext(ctx, &result, "quai_getEtxStatus", originTxHash, hexutil.Uint64(index)); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"reflect"
 	"runtime/debug"
@@ -78,8 +79,11 @@ type Client struct {
 	idgen    func() ID // for subscriptions
 	isHTTP   bool
 	services *serviceRegistry
+	limiter  *limiter // limits of the server serving the connection, nil for clients
 	log      *log.Logger
 
+	slowCallThreshold time.Duration // calls served longer than this are logged, 0 for clients
+
 	idCounter uint32
 
 	// This function, if non-nil, is called when the connection is lost.
@@ -114,6 +118,13 @@ type clientConn struct {
 func (c *Client) newClientConn(conn ServerCodec) *clientConn {
 	ctx := context.WithValue(context.Background(), clientContextKey{}, c)
 	handler := newHandler(ctx, conn, c.idgen, c.services, c.log)
+	handler.limiter = c.limiter
+	handler.slowCallThreshold = c.slowCallThreshold
+	if keyer, ok := conn.(limitKeyer); ok {
+		handler.limitKey = keyer.limitKey()
+	} else {
+		handler.limitKey = remoteIP(conn.remoteAddr())
+	}
 	return &clientConn{conn, handler}
 }
 
@@ -154,7 +165,7 @@ func (op *requestOp) wait(ctx context.Context, c *Client) (*jsonrpcMessage, erro
 //
 // The currently supported URL schemes are "http", "https", "ws" and "wss". If rawurl is a
 // file name with no URL scheme, a local socket connection is established using UNIX
-// domain sockets on supported platforms and named pipes on Windows. If you want to
+// domain sockets on supported platforms. If you want to
 // configure transport options, use DialHTTP, DialWebsocket.
 //
 // For websocket connections, the origin is set to the local host name.
@@ -180,11 +191,35 @@ func DialContext(ctx context.Context, rawurl string) (*Client, error) {
 		return DialWebsocket(ctx, rawurl, "")
 	case "stdio":
 		return DialStdIO(ctx)
+	case "":
+		return DialIPC(ctx, rawurl)
 	default:
 		return nil, fmt.Errorf("no known transport for URL scheme %q", u.Scheme)
 	}
 }
 
+// HTTPAuth is a function that adds authentication headers to the HTTP requests
+// of a client. For websocket connections it is called for the handshake of
+// every (re)connection.
+type HTTPAuth func(h http.Header) error
+
+// DialContextWithAuth creates a new RPC client just like DialContext, and
+// authenticates its HTTP requests and websocket handshakes with auth. The auth
+// function is ignored by the other transports.
+func DialContextWithAuth(ctx context.Context, rawurl string, auth HTTPAuth) (*Client, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return dialHTTP(rawurl, new(http.Client), auth)
+	case "ws", "wss":
+		return dialWebsocket(ctx, rawurl, "", defaultWebsocketDialer(), auth)
+	}
+	return DialContext(ctx, rawurl)
+}
+
 // Client retrieves the client from the context, if any. This can be used to perform
 // 'reverse calls' in a handler method.
 func ClientFromContext(ctx context.Context) (*Client, bool) {
@@ -197,12 +232,12 @@ func newClient(initctx context.Context, connect reconnectFunc) (*Client, error)
 	if err != nil {
 		return nil, err
 	}
-	c := initClient(conn, randomIDGenerator(), new(serviceRegistry), log.Global)
+	c := initClient(conn, randomIDGenerator(), new(serviceRegistry), nil, 0, log.Global)
 	c.reconnectFunc = connect
 	return c, nil
 }
 
-func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry, log *log.Logger) *Client {
+func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry, limiter *limiter, slowCallThreshold time.Duration, log *log.Logger) *Client {
 	_, isHTTP := conn.(*httpConn)
 	c := &Client{
 		idgen:       idgen,
@@ -218,7 +253,10 @@ func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry, lo
 		reqInit:     make(chan *requestOp),
 		reqSent:     make(chan error, 1),
 		reqTimeout:  make(chan *requestOp),
+		limiter:     limiter,
 		log:         log,
+
+		slowCallThreshold: slowCallThreshold,
 	}
 	if !isHTTP {
 		go c.dispatch(conn)
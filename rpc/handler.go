@@ -19,6 +19,7 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"runtime/debug"
 	"strconv"
@@ -61,6 +62,10 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            *log.Logger
 	allowSubscribe bool
+	limiter        *limiter // limits enforced on the requests, nil for none
+	limitKey       string   // client the request rate is limited by
+
+	slowCallThreshold time.Duration // calls taking longer are logged, 0 to disable
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
@@ -98,6 +103,13 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 		})
 		return
 	}
+	// Reject the batches over the limit of the server
+	if limit := h.limiter.batchItems(); limit > 0 && len(msgs) > limit {
+		h.startCallProc(func(cp *callProc) {
+			h.conn.writeJSON(cp.ctx, errorMessage(&limitExceededError{message: fmt.Sprintf("batch too large (%d > %d)", len(msgs), limit)}))
+		})
+		return
+	}
 
 	// Handle non-call messages first:
 	calls := make([]*jsonrpcMessage, 0, len(msgs))
@@ -111,10 +123,20 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
-		answers := make([]*jsonrpcMessage, 0, len(msgs))
+		var (
+			answers = make([]*jsonrpcMessage, 0, len(msgs))
+			size    int
+		)
 		for _, msg := range calls {
+			// Stop serving the calls once the response is over the limit
+			if limit := h.limiter.responseSize(); limit > 0 && size > limit {
+				if msg.isCall() {
+					answers = append(answers, msg.errorResponse(&responseTooLargeError{limit}))
+				}
+				continue
+			}
 			if answer := h.handleCallMsg(cp, msg); answer != nil {
-				answers = append(answers, answer)
+				answers = append(answers, h.limitResponse(answer, &size))
 			}
 		}
 		h.addSubscriptions(cp.notifiers)
@@ -133,10 +155,11 @@ func (h *handler) handleMsg(msg *jsonrpcMessage) {
 		return
 	}
 	h.startCallProc(func(cp *callProc) {
+		var size int
 		answer := h.handleCallMsg(cp, msg)
 		h.addSubscriptions(cp.notifiers)
 		if answer != nil {
-			h.conn.writeJSON(cp.ctx, answer)
+			h.conn.writeJSON(cp.ctx, h.limitResponse(answer, &size))
 		}
 		for _, n := range cp.notifiers {
 			n.activate()
@@ -144,6 +167,21 @@ func (h *handler) handleMsg(msg *jsonrpcMessage) {
 	})
 }
 
+// limitResponse adds the size of the answer to the size of the response. The
+// answer is replaced by an error if it grows the response over the limit of
+// the server.
+func (h *handler) limitResponse(answer *jsonrpcMessage, size *int) *jsonrpcMessage {
+	limit := h.limiter.responseSize()
+	if limit == 0 || answer.Result == nil {
+		return answer
+	}
+	*size += len(answer.Result)
+	if *size > limit {
+		return answer.errorResponse(&responseTooLargeError{limit})
+	}
+	return answer
+}
+
 // close cancels all requests except for inflightReq and waits for
 // call goroutines to shut down.
 func (h *handler) close(err error, inflightReq *requestOp) {
@@ -197,6 +235,7 @@ func (h *handler) addSubscriptions(nn []*Notifier) {
 	for _, n := range nn {
 		if sub := n.takeSubscription(); sub != nil {
 			h.serverSubs[sub.ID] = sub
+			subscriptionAdded(sub.namespace)
 		}
 	}
 }
@@ -210,6 +249,7 @@ func (h *handler) cancelServerSubscriptions(err error) {
 		s.err <- err
 		close(s.err)
 		delete(h.serverSubs, id)
+		subscriptionRemoved(s.namespace)
 	}
 }
 
@@ -307,10 +347,16 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 	start := time.Now()
 	switch {
 	case msg.isNotification():
+		if err := h.limiter.allow(h.limitKey, msg.Method); err != nil {
+			return nil
+		}
 		h.handleCall(ctx, msg)
 		h.log.WithField("t", time.Since(start)).Debug("Served " + msg.Method)
 		return nil
 	case msg.isCall():
+		if err := h.limiter.allow(h.limitKey, msg.Method); err != nil {
+			return msg.errorResponse(err)
+		}
 		resp := h.handleCall(ctx, msg)
 		var ctx []interface{}
 		ctx = append(ctx, "reqid", idForLog{msg.ID}, "t", time.Since(start))
@@ -388,11 +434,18 @@ func (h *handler) handleSubscribe(cp *callProc, msg *jsonrpcMessage) *jsonrpcMes
 
 // runMethod runs the Go callback for an RPC method.
 func (h *handler) runMethod(ctx context.Context, msg *jsonrpcMessage, callb *callback, args []reflect.Value) *jsonrpcMessage {
+	var (
+		start  = time.Now()
+		answer *jsonrpcMessage
+	)
 	result, err := callb.call(ctx, msg.Method, args, h.log)
 	if err != nil {
-		return msg.errorResponse(err)
+		answer = msg.errorResponse(err)
+	} else {
+		answer = msg.response(result)
 	}
-	return msg.response(result)
+	h.recordCall(msg, answer, time.Since(start))
+	return answer
 }
 
 // unsubscribe is the callback function for all *_unsubscribe calls.
@@ -406,6 +459,7 @@ func (h *handler) unsubscribe(ctx context.Context, id ID) (bool, error) {
 	}
 	close(s.err)
 	delete(h.serverSubs, id)
+	subscriptionRemoved(s.namespace)
 	return true, nil
 }
 
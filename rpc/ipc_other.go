@@ -0,0 +1,25 @@
+//go:build !(darwin || dragonfly || freebsd || linux || nacl || netbsd || openbsd || solaris)
+// +build !darwin,!dragonfly,!freebsd,!linux,!nacl,!netbsd,!openbsd,!solaris
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/dominant-strategies/go-quai/log"
+)
+
+// errIPCUnsupported is returned on the platforms without Unix domain sockets.
+var errIPCUnsupported = errors.New("IPC is not supported on this platform")
+
+// ipcListen is not supported on this platform.
+func ipcListen(endpoint string, logger *log.Logger) (net.Listener, error) {
+	return nil, errIPCUnsupported
+}
+
+// newIPCConnection is not supported on this platform.
+func newIPCConnection(ctx context.Context, endpoint string) (net.Conn, error) {
+	return nil, errIPCUnsupported
+}
@@ -0,0 +1,234 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// bucketSweepInterval is how often the buckets of the idle clients are
+	// dropped by the rate limiter
+	bucketSweepInterval = time.Minute
+)
+
+// DefaultMethodCosts are the costs of the methods which are more expensive to
+// serve than a plain lookup. The methods not listed cost 1.
+var DefaultMethodCosts = map[string]int{
+	"quai_getLogs":            20,
+	"quai_getFilterLogs":      20,
+	"quai_getAddressHistory":  10,
+	"quai_getUTXOsByAddress":  10,
+	"quai_feeHistory":         10,
+	"quai_qiFeeHistory":       10,
+	"quai_call":               5,
+	"quai_estimateGas":        5,
+	"quai_estimateFeeForQi":   5,
+	"quai_createAccessList":   5,
+	"quai_getProof":           5,
+	"quai_getEtxStatus":       5,
+	"quai_sendRawTransaction": 2,
+}
+
+// Limits are the limits a server enforces on the requests of its clients. The
+// zero value enforces no limit.
+type Limits struct {
+	BatchItems   int  // Maximum number of requests in a batch, 0 for no limit
+	ResponseSize int  // Maximum size in bytes of the results of a call or a batch, 0 for no limit
+	RateByToken  bool // Limit the requests per authenticated client rather than per IP, see WithAuthenticatedClient
+
+	// RequestRate is the cost of the requests a client is credited with every
+	// second, and RequestBurst the cost it can spend at once. The rate of the
+	// requests is not limited if RequestRate is 0.
+	RequestRate  float64
+	RequestBurst int
+
+	// MethodCosts are the costs of the methods, DefaultMethodCosts if nil
+	MethodCosts map[string]int
+}
+
+// limitExceededError is returned for the requests over the limits of the
+// server. A retry hint is given to the requests over the rate limit.
+type limitExceededError struct {
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *limitExceededError) ErrorCode() int { return -32005 }
+
+func (e *limitExceededError) Error() string { return e.message }
+
+func (e *limitExceededError) ErrorData() interface{} {
+	if e.retryAfter == 0 {
+		return nil
+	}
+	return map[string]interface{}{"retryAfter": int(math.Ceil(e.retryAfter.Seconds()))}
+}
+
+// responseTooLargeError is returned instead of the results over the response
+// size limit of the server.
+type responseTooLargeError struct{ limit int }
+
+func (e *responseTooLargeError) ErrorCode() int { return -32003 }
+
+func (e *responseTooLargeError) Error() string {
+	return fmt.Sprintf("response too large, limit is %d bytes", e.limit)
+}
+
+// limitKeyContextKey is the context key of the client the requests of a
+// HTTP connection are limited by.
+type limitKeyContextKey struct{}
+
+// authClientContextKey is the context key of the client a request was
+// authenticated for.
+type authClientContextKey struct{}
+
+// limitKeyer is implemented by the codecs which identified their client when
+// the connection was established.
+type limitKeyer interface {
+	limitKey() string
+}
+
+// bucket holds the cost a client can still spend
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter enforces the limits of a server. The rate of the requests is limited
+// with a token bucket per client.
+type limiter struct {
+	limits Limits
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newLimiter(limits Limits) *limiter {
+	if limits.MethodCosts == nil {
+		limits.MethodCosts = DefaultMethodCosts
+	}
+	if limits.RequestRate > 0 && limits.RequestBurst < 1 {
+		limits.RequestBurst = int(math.Ceil(limits.RequestRate))
+	}
+	return &limiter{
+		limits:    limits,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// batchItems returns the maximum number of requests in a batch, 0 for no limit
+func (l *limiter) batchItems() int {
+	if l == nil {
+		return 0
+	}
+	return l.limits.BatchItems
+}
+
+// responseSize returns the maximum size of a response, 0 for no limit
+func (l *limiter) responseSize() int {
+	if l == nil {
+		return 0
+	}
+	return l.limits.ResponseSize
+}
+
+// clientKey returns the key identifying the client of the request for the
+// rate limiting: the client its token was verified for if the rate is limited
+// per token, its IP otherwise. The bearer token itself is not used, as any
+// client could pick a new one for each request.
+func (l *limiter) clientKey(r *http.Request) string {
+	if l == nil {
+		return ""
+	}
+	if l.limits.RateByToken {
+		if client, ok := r.Context().Value(authClientContextKey{}).(string); ok && client != "" {
+			return "token:" + client
+		}
+	}
+	return remoteIP(r.RemoteAddr)
+}
+
+// WithAuthenticatedClient returns a copy of the context of a request whose
+// token was verified, carrying the client the token was issued to. The rate of
+// the requests of such a client is limited per client rather than per IP when
+// Limits.RateByToken is set.
+func WithAuthenticatedClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, authClientContextKey{}, client)
+}
+
+// remoteIP strips the port from the remote address
+func remoteIP(remote string) string {
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return host
+	}
+	return remote
+}
+
+// limitKeyFromContext returns the client key stored in the context, if any
+func limitKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(limitKeyContextKey{}).(string)
+	return key
+}
+
+// allow charges the cost of the method to the client. An error carrying the
+// time until the client can afford the call is returned if it is over its
+// rate.
+func (l *limiter) allow(key string, method string) error {
+	if l == nil || l.limits.RequestRate <= 0 {
+		return nil
+	}
+	cost := 1
+	if c, ok := l.limits.MethodCosts[method]; ok {
+		cost = c
+	}
+	if cost <= 0 {
+		return nil
+	}
+	burst := float64(l.limits.RequestBurst)
+	// A method costing more than the burst is charged the whole burst
+	need := math.Min(float64(cost), burst)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > bucketSweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.limits.RequestRate)
+		b.last = now
+	}
+	if b.tokens < need {
+		wait := time.Duration((need - b.tokens) / l.limits.RequestRate * float64(time.Second))
+		return &limitExceededError{
+			message:    "request rate limit exceeded, retry in " + wait.Round(time.Millisecond).String(),
+			retryAfter: wait,
+		}
+	}
+	b.tokens -= need
+	return nil
+}
+
+// sweep drops the buckets of the clients which have been idle long enough to
+// refill them. The caller must hold l.mu.
+func (l *limiter) sweep(now time.Time) {
+	refill := time.Duration(float64(l.limits.RequestBurst) / l.limits.RequestRate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > refill {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
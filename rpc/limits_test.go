@@ -0,0 +1,213 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/log"
+)
+
+// newLimitedTestServer returns a HTTP server serving the test service with the
+// given limits
+func newLimitedTestServer(t *testing.T, limits Limits) *httptest.Server {
+	server := newTestServer()
+	server.SetLimits(limits)
+	ts := httptest.NewServer(server)
+	t.Cleanup(func() {
+		ts.Close()
+		server.Stop()
+	})
+	return ts
+}
+
+// postJSON posts the body to the server and returns the decoded response
+func postJSON(t *testing.T, url string, body string) interface{} {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var result interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	return result
+}
+
+// errorCode returns the code of the error of a response, 0 if it succeeded
+func errorCode(t *testing.T, response interface{}) int {
+	msg, ok := response.(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected response %v", response)
+	}
+	e, ok := msg["error"].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	return int(e["code"].(float64))
+}
+
+func TestLimiterClientKey(t *testing.T) {
+	newRequest := func(token string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.RemoteAddr = "10.0.0.1:30303"
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return r
+	}
+	authenticated := func(r *http.Request, client string) *http.Request {
+		return r.WithContext(WithAuthenticatedClient(r.Context(), client))
+	}
+	byIP := newLimiter(Limits{RequestRate: 1})
+	byToken := newLimiter(Limits{RequestRate: 1, RateByToken: true})
+
+	tests := []struct {
+		limiter *limiter
+		request *http.Request
+		key     string
+	}{
+		{byIP, newRequest(""), "10.0.0.1"},
+		{byIP, authenticated(newRequest("a"), "alice"), "10.0.0.1"},
+		{byToken, newRequest(""), "10.0.0.1"},
+		// An unverified token is not trusted
+		{byToken, newRequest("a"), "10.0.0.1"},
+		{byToken, authenticated(newRequest("a"), "alice"), "token:alice"},
+		// The tokens of a client share its key
+		{byToken, authenticated(newRequest("b"), "alice"), "token:alice"},
+		{byToken, authenticated(newRequest("a"), ""), "10.0.0.1"},
+	}
+	for i, test := range tests {
+		if key := test.limiter.clientKey(test.request); key != test.key {
+			t.Errorf("test %d: key mismatch: have %q, want %q", i, key, test.key)
+		}
+	}
+	var nilLimiter *limiter
+	if key := nilLimiter.clientKey(newRequest("a")); key != "" {
+		t.Errorf("nil limiter returned key %q", key)
+	}
+}
+
+func TestLimiterAllow(t *testing.T) {
+	l := newLimiter(Limits{
+		RequestRate:  1,
+		RequestBurst: 3,
+		MethodCosts:  map[string]int{"test_cheap": 1, "test_costly": 10, "test_free": 0},
+	})
+	for i := 0; i < 3; i++ {
+		if err := l.allow("a", "test_cheap"); err != nil {
+			t.Fatalf("call %d within the burst rejected: %v", i, err)
+		}
+	}
+	err := l.allow("a", "test_cheap")
+	if err == nil {
+		t.Fatal("call over the burst allowed")
+	}
+	limitErr, ok := err.(*limitExceededError)
+	if !ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if limitErr.retryAfter <= 0 || limitErr.retryAfter > time.Second {
+		t.Errorf("retry hint %v out of range", limitErr.retryAfter)
+	}
+	// Each client has its own bucket and the free methods are never limited
+	if err := l.allow("b", "test_cheap"); err != nil {
+		t.Errorf("call of another client rejected: %v", err)
+	}
+	if err := l.allow("a", "test_free"); err != nil {
+		t.Errorf("free call rejected: %v", err)
+	}
+	// A method costing more than the burst is charged the whole burst
+	if err := l.allow("c", "test_costly"); err != nil {
+		t.Errorf("costly call with a full bucket rejected: %v", err)
+	}
+	if err := l.allow("c", "test_cheap"); err == nil {
+		t.Error("call after a costly one allowed")
+	}
+	// The rate is not limited without a rate
+	unlimited := newLimiter(Limits{BatchItems: 1})
+	for i := 0; i < 100; i++ {
+		if err := unlimited.allow("a", "test_costly"); err != nil {
+			t.Fatalf("call %d rejected without a rate: %v", i, err)
+		}
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := newLimiter(Limits{RequestRate: 1, RequestBurst: 1})
+	l.allow("a", "test_echo")
+	l.allow("b", "test_echo")
+	l.buckets["a"].last = time.Now().Add(-2 * time.Second)
+	l.lastSweep = time.Now().Add(-2 * bucketSweepInterval)
+	l.allow("c", "test_echo")
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("bucket of the idle client not dropped")
+	}
+	if _, ok := l.buckets["b"]; !ok {
+		t.Error("bucket of the active client dropped")
+	}
+}
+
+func TestLimitRetryHint(t *testing.T) {
+	ts := newLimitedTestServer(t, Limits{RequestRate: 0.5, RequestBurst: 1})
+	const body = `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]}`
+
+	if code := errorCode(t, postJSON(t, ts.URL, body)); code != 0 {
+		t.Fatalf("first call failed with code %d", code)
+	}
+	response := postJSON(t, ts.URL, body)
+	if code := errorCode(t, response); code != -32005 {
+		t.Fatalf("code mismatch: have %d, want -32005", code)
+	}
+	data, ok := response.(map[string]interface{})["error"].(map[string]interface{})["data"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("missing retry hint in %v", response)
+	}
+	// The bucket refills in two seconds at most, rounded up to whole seconds
+	if retry := data["retryAfter"].(float64); retry < 1 || retry > 2 {
+		t.Errorf("retry hint %v out of range", retry)
+	}
+}
+
+func TestLimitBatchItems(t *testing.T) {
+	ts := newLimitedTestServer(t, Limits{BatchItems: 2})
+	const call = `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]}`
+
+	response := postJSON(t, ts.URL, "["+call+","+call+"]")
+	if answers, ok := response.([]interface{}); !ok || len(answers) != 2 {
+		t.Fatalf("batch within the limit not served: %v", response)
+	}
+	response = postJSON(t, ts.URL, "["+call+","+call+","+call+"]")
+	if code := errorCode(t, response); code != -32005 {
+		t.Fatalf("code mismatch: have %d, want -32005", code)
+	}
+}
+
+func TestLimitResponseSize(t *testing.T) {
+	server := NewServer(log.Global)
+	defer server.Stop()
+	server.RegisterName("test", largeRespService{100})
+	server.SetLimits(Limits{ResponseSize: 64})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	const call = `{"jsonrpc":"2.0","id":1,"method":"test_largeResp"}`
+	if code := errorCode(t, postJSON(t, ts.URL, call)); code != -32003 {
+		t.Fatalf("code mismatch: have %d, want -32003", code)
+	}
+	// The answers of a batch are dropped once the limit is reached
+	response := postJSON(t, ts.URL, "["+call+","+call+"]")
+	answers, ok := response.([]interface{})
+	if !ok || len(answers) != 2 {
+		t.Fatalf("unexpected batch response %v", response)
+	}
+	for i, answer := range answers {
+		if code := errorCode(t, answer); code != -32003 {
+			t.Errorf("answer %d: code mismatch: have %d, want -32003", i, code)
+		}
+	}
+}
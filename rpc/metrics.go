@@ -0,0 +1,72 @@
+package rpc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics_config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultSlowCallThreshold is the duration above which the calls to the HTTP
+// and WebSocket endpoints are logged by default
+const DefaultSlowCallThreshold = 5 * time.Second
+
+var (
+	rpcDurations     *prometheus.HistogramVec
+	rpcCalls         *prometheus.CounterVec
+	rpcSubscriptions *prometheus.GaugeVec
+)
+
+func init() {
+	registerMetrics()
+}
+
+func registerMetrics() {
+	rpcDurations = metrics_config.NewHistogramVec("RPCDurations", "Time spent serving the RPC calls in seconds", "namespace", "method")
+	rpcCalls = metrics_config.NewCounterVec("RPCCalls", "Number of RPC calls served", "namespace", "method", "status")
+	rpcSubscriptions = metrics_config.NewGaugeVec("RPCSubscriptions", "Number of active RPC subscriptions per namespace")
+}
+
+// recordCall updates the metrics of the method of a call served by the
+// handler, and logs the call if it took longer than the slow call threshold.
+// Only the methods found in the registry are recorded, so the labels of the
+// metrics are bounded.
+func (h *handler) recordCall(msg *jsonrpcMessage, answer *jsonrpcMessage, elapsed time.Duration) {
+	if metrics_config.MetricsEnabled() {
+		namespace := msg.namespace()
+		method := strings.TrimPrefix(msg.Method, namespace+serviceMethodSeparator)
+		status := "success"
+		if answer.Error != nil {
+			status = "error"
+		}
+		rpcDurations.WithLabelValues(namespace, method).Observe(elapsed.Seconds())
+		rpcCalls.WithLabelValues(namespace, method, status).Inc()
+	}
+	if h.slowCallThreshold > 0 && elapsed >= h.slowCallThreshold {
+		paramsHash := sha256.Sum256(msg.Params)
+		h.log.WithFields(log.Fields{
+			"method": msg.Method,
+			"params": hex.EncodeToString(paramsHash[:8]),
+			"reqid":  idForLog{msg.ID},
+			"t":      elapsed,
+		}).Warn("Slow RPC call")
+	}
+}
+
+// subscriptionAdded counts a subscription of the namespace as active
+func subscriptionAdded(namespace string) {
+	if metrics_config.MetricsEnabled() {
+		rpcSubscriptions.WithLabelValues(namespace).Inc()
+	}
+}
+
+// subscriptionRemoved counts a subscription of the namespace as inactive
+func subscriptionRemoved(namespace string) {
+	if metrics_config.MetricsEnabled() {
+		rpcSubscriptions.WithLabelValues(namespace).Dec()
+	}
+}
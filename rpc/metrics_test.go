@@ -0,0 +1,110 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dominant-strategies/go-quai/log"
+	"github.com/dominant-strategies/go-quai/metrics_config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+// durationSamples returns the number of calls of the method observed by the
+// duration histogram
+func durationSamples(t *testing.T, namespace, method string) uint64 {
+	var m dto.Metric
+	if err := rpcDurations.WithLabelValues(namespace, method).(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestNewServerSlowCallThreshold(t *testing.T) {
+	server := NewServer(log.Global)
+	defer server.Stop()
+	if server.slowCallThreshold != 0 {
+		t.Fatalf("slow calls logged by default above %v", server.slowCallThreshold)
+	}
+	server.SetSlowCallThreshold(DefaultSlowCallThreshold)
+	if server.slowCallThreshold != DefaultSlowCallThreshold {
+		t.Fatalf("threshold mismatch: have %v, want %v", server.slowCallThreshold, DefaultSlowCallThreshold)
+	}
+}
+
+func TestRecordCall(t *testing.T) {
+	metrics_config.EnableMetrics()
+
+	logger, hook := logtest.NewNullLogger()
+	h := &handler{log: logger, slowCallThreshold: time.Second}
+	msg := &jsonrpcMessage{ID: json.RawMessage("1"), Method: "test_echo", Params: json.RawMessage(`["x",1]`)}
+
+	var (
+		successes = testutil.ToFloat64(rpcCalls.WithLabelValues("test", "echo", "success"))
+		errors    = testutil.ToFloat64(rpcCalls.WithLabelValues("test", "echo", "error"))
+		samples   = durationSamples(t, "test", "echo")
+	)
+	h.recordCall(msg, msg.response("x"), time.Millisecond)
+	h.recordCall(msg, msg.errorResponse(&invalidParamsError{"bad"}), time.Millisecond)
+
+	if have := testutil.ToFloat64(rpcCalls.WithLabelValues("test", "echo", "success")); have != successes+1 {
+		t.Errorf("successful calls mismatch: have %v, want %v", have, successes+1)
+	}
+	if have := testutil.ToFloat64(rpcCalls.WithLabelValues("test", "echo", "error")); have != errors+1 {
+		t.Errorf("failed calls mismatch: have %v, want %v", have, errors+1)
+	}
+	if have := durationSamples(t, "test", "echo"); have != samples+2 {
+		t.Errorf("duration samples mismatch: have %d, want %d", have, samples+2)
+	}
+	if len(hook.Entries) != 0 {
+		t.Fatalf("fast calls logged: %v", hook.AllEntries())
+	}
+	// Only the calls over the threshold are logged, never with a zero threshold
+	h.recordCall(msg, msg.response("x"), 2*time.Second)
+	if len(hook.Entries) != 1 || hook.LastEntry().Level != logrus.WarnLevel {
+		t.Fatalf("slow call not logged: %v", hook.AllEntries())
+	}
+	if method := hook.LastEntry().Data["method"]; method != "test_echo" {
+		t.Errorf("logged method mismatch: have %v, want test_echo", method)
+	}
+	h.slowCallThreshold = 0
+	h.recordCall(msg, msg.response("x"), time.Hour)
+	if len(hook.Entries) != 1 {
+		t.Fatalf("call logged without a threshold: %v", hook.AllEntries())
+	}
+}
+
+func TestSubscriptionGauge(t *testing.T) {
+	metrics_config.EnableMetrics()
+
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	gauge := rpcSubscriptions.WithLabelValues("nftest")
+	active := testutil.ToFloat64(gauge)
+
+	sub, err := client.Subscribe(context.Background(), "nftest", make(chan int), "someSubscription", 0, 0)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if have := testutil.ToFloat64(gauge); have != active+1 {
+		t.Fatalf("active subscriptions mismatch: have %v, want %v", have, active+1)
+	}
+	sub.Unsubscribe()
+
+	// The subscription is removed from the server in the background
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(gauge) != active {
+		if time.Now().After(deadline) {
+			t.Fatalf("active subscriptions mismatch: have %v, want %v", testutil.ToFloat64(gauge), active)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
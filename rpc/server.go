@@ -21,6 +21,7 @@ import (
 	"io"
 	"runtime/debug"
 	"sync/atomic"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 
@@ -49,9 +50,13 @@ type Server struct {
 	run      int32
 	codecs   mapset.Set
 	log      *log.Logger
+	limiter  *limiter // nil when no limit is set
+
+	slowCallThreshold time.Duration // calls taking longer are logged, 0 to disable
 }
 
-// NewServer creates a new server instance with no registered handlers.
+// NewServer creates a new server instance with no registered handlers. The
+// slow calls are not logged until a threshold is set.
 func NewServer(log *log.Logger) *Server {
 	server := &Server{idgen: randomIDGenerator(), codecs: mapset.NewSet(), run: 1, log: log}
 	// Register the default service providing meta information about the RPC service such
@@ -61,6 +66,19 @@ func NewServer(log *log.Logger) *Server {
 	return server
 }
 
+// SetLimits sets the limits enforced on the requests of the clients. It must be
+// called before the server starts serving requests.
+func (s *Server) SetLimits(limits Limits) {
+	s.limiter = newLimiter(limits)
+}
+
+// SetSlowCallThreshold sets the duration above which the calls are logged, 0
+// disables the logging. It must be called before the server starts serving
+// requests.
+func (s *Server) SetSlowCallThreshold(threshold time.Duration) {
+	s.slowCallThreshold = threshold
+}
+
 // RegisterName creates a service for the given receiver type under the given name. When no
 // methods on the given receiver match the criteria to be either a RPC method or a
 // subscription an error is returned. Otherwise a new service is created and added to the
@@ -94,7 +112,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services, s.log)
+	c := initClient(codec, s.idgen, &s.services, s.limiter, s.slowCallThreshold, s.log)
 	<-codec.closed()
 	c.Close()
 }
@@ -110,6 +128,8 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 
 	h := newHandler(ctx, codec, s.idgen, &s.services, s.log)
 	h.allowSubscribe = false
+	h.limiter, h.limitKey = s.limiter, limitKeyFromContext(ctx)
+	h.slowCallThreshold = s.slowCallThreshold
 	defer h.close(io.EOF, nil)
 
 	reqs, batch, err := codec.readBatch()